@@ -0,0 +1,353 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const adminUsage = `Usage: passwall-server admin [-data-dir dir] <command> [flags]
+
+Commands:
+  create-user          Create a new user with its schema
+  reset-password       Set a new master password for a user
+  disable-2fa          Disable two factor authentication of a user
+  exempt-security-key  Let a user sign in without a security key for a while
+  list-subscriptions   List all subscriptions
+  purge-tenant         Delete a user with all vault data
+  purge-expired        Delete the data older than the retention policy
+  reencrypt            Re-encrypt all vaults after a key rotation or cipher upgrade
+
+Run "passwall-server admin <command> -h" for the flags of a command.
+`
+
+var errMissingEmail = errors.New("email is required")
+
+// runAdmin runs the admin subcommands against the configured database
+func runAdmin(args []string) error {
+	fs := flag.NewFlagSet("admin", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "data folder of a server running in single binary mode")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, adminUsage) }
+	fs.Parse(args)
+	args = fs.Args()
+
+	if len(args) < 1 {
+		fmt.Fprint(os.Stderr, adminUsage)
+		return errors.New("admin command is missing")
+	}
+
+	commands := map[string]func(storage.Store, []string) error{
+		"create-user":         adminCreateUser,
+		"reset-password":      adminResetPassword,
+		"disable-2fa":         adminDisable2FA,
+		"exempt-security-key": adminExemptSecurityKey,
+		"list-subscriptions":  adminListSubscriptions,
+		"purge-tenant":        adminPurgeTenant,
+		"purge-expired":       adminPurgeExpired,
+		"reencrypt":           adminReencrypt,
+	}
+
+	command, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprint(os.Stderr, adminUsage)
+		return fmt.Errorf("unknown admin command %q", args[0])
+	}
+
+	if *dataDir != "" {
+		if err := config.SetDataDir(*dataDir); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := config.SetupConfigDefaults()
+	if err != nil {
+		return err
+	}
+
+	db, err := storage.DBConn(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s := storage.New(db)
+	app.MigrateSystemTables(s)
+
+	return command(s, args[1:])
+}
+
+func adminCreateUser(s storage.Store, args []string) error {
+	fs := flag.NewFlagSet("create-user", flag.ExitOnError)
+	name := fs.String("name", "", "name of the user")
+	email := fs.String("email", "", "email of the user")
+	password := fs.String("password", "", "master password, asked if empty")
+	admin := fs.Bool("admin", false, "give admin role to the user")
+	fs.Parse(args)
+
+	if *email == "" {
+		return errMissingEmail
+	}
+
+	if _, err := s.Users().FindByEmail(*email); err == nil {
+		return fmt.Errorf("user %s already exists", *email)
+	}
+
+	masterPassword, err := passwordFlagOrPrompt(*password)
+	if err != nil {
+		return err
+	}
+
+	userDTO := &model.UserDTO{
+		Name:           *name,
+		Email:          *email,
+		MasterPassword: masterPassword,
+	}
+
+	user, err := app.SetupUser(s, userDTO)
+	if err != nil {
+		return err
+	}
+
+	if *admin {
+		user.Role = "Admin"
+		if _, err := s.Users().Save(user); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("User %s created with schema %s\n", user.Email, user.Schema)
+	return nil
+}
+
+func adminResetPassword(s storage.Store, args []string) error {
+	fs := flag.NewFlagSet("reset-password", flag.ExitOnError)
+	email := fs.String("email", "", "email of the user")
+	password := fs.String("password", "", "new master password, asked if empty")
+	fs.Parse(args)
+
+	user, err := findUserByEmailFlag(s, *email)
+	if err != nil {
+		return err
+	}
+
+	masterPassword, err := passwordFlagOrPrompt(*password)
+	if err != nil {
+		return err
+	}
+
+	if _, err := app.ResetMasterPassword(s, user, masterPassword); err != nil {
+		return err
+	}
+
+	fmt.Printf("Master password of %s is reset and all sessions are ended\n", user.Email)
+	return nil
+}
+
+func adminDisable2FA(s storage.Store, args []string) error {
+	fs := flag.NewFlagSet("disable-2fa", flag.ExitOnError)
+	email := fs.String("email", "", "email of the user")
+	fs.Parse(args)
+
+	user, err := findUserByEmailFlag(s, *email)
+	if err != nil {
+		return err
+	}
+	methods, err := app.TwoFactorMethods(s, user)
+	if err != nil {
+		return err
+	}
+	if len(methods) == 0 && user.TOTPSecret == "" {
+		return fmt.Errorf("%s has no two factor authentication", user.Email)
+	}
+
+	if _, err := app.ResetTwoFactor(s, user, "admin cli"); err != nil {
+		return err
+	}
+
+	fmt.Printf("Two factor authentication of %s is disabled\n", user.Email)
+	return nil
+}
+
+func adminExemptSecurityKey(s storage.Store, args []string) error {
+	fs := flag.NewFlagSet("exempt-security-key", flag.ExitOnError)
+	email := fs.String("email", "", "email of the user")
+	period := fs.String("period", "1d", "how long the exemption lasts, e.g. 12h or 7d")
+	reason := fs.String("reason", "", "why the requirement is overridden, written to the audit log")
+	remove := fs.Bool("remove", false, "end the exemption")
+	fs.Parse(args)
+
+	user, err := findUserByEmailFlag(s, *email)
+	if err != nil {
+		return err
+	}
+
+	if *remove {
+		if _, err := app.RemoveSecurityKeyExemption(s, user.ID, "admin cli"); err != nil {
+			return err
+		}
+		fmt.Printf("%s has to sign in with a security key again\n", user.Email)
+		return nil
+	}
+
+	if *reason == "" {
+		return errors.New("reason is required")
+	}
+
+	dto := &model.SecurityKeyExemptionDTO{Period: *period, Reason: *reason}
+	policy, err := app.ExemptFromSecurityKey(s, user.ID, dto, "admin cli")
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("%s can sign in without a security key until %s\n", user.Email, policy.SecurityKeyExemptUntil.Format(time.RFC3339))
+	return nil
+}
+
+func adminListSubscriptions(s storage.Store, args []string) error {
+	fs := flag.NewFlagSet("list-subscriptions", flag.ExitOnError)
+	fs.Parse(args)
+
+	subscriptions, err := s.Subscriptions().All()
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSUBSCRIPTION ID\tPLAN ID\tEMAIL\tSTATUS\tNEXT BILL DATE")
+	for _, sub := range subscriptions {
+		fmt.Fprintf(tw, "%d\t%d\t%d\t%s\t%s\t%s\n",
+			sub.ID, sub.SubscriptionID, sub.PlanID, sub.Email, sub.Status, sub.NextBillDate.Format("2006-01-02"))
+	}
+	return tw.Flush()
+}
+
+func adminPurgeTenant(s storage.Store, args []string) error {
+	fs := flag.NewFlagSet("purge-tenant", flag.ExitOnError)
+	email := fs.String("email", "", "email of the user")
+	yes := fs.Bool("yes", false, "confirm deleting the user and all of its data")
+	fs.Parse(args)
+
+	user, err := findUserByEmailFlag(s, *email)
+	if err != nil {
+		return err
+	}
+
+	if !*yes {
+		return fmt.Errorf("this deletes %s and schema %s permanently, run again with -yes to confirm", user.Email, user.Schema)
+	}
+
+	if err := app.PurgeUser(s, user); err != nil {
+		return err
+	}
+
+	fmt.Printf("User %s and schema %s are deleted\n", user.Email, user.Schema)
+	return nil
+}
+
+func adminPurgeExpired(s storage.Store, args []string) error {
+	fs := flag.NewFlagSet("purge-expired", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "only report what would be deleted")
+	fs.Parse(args)
+
+	report, err := app.PurgeExpiredData(s, time.Now(), *dryRun)
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "DATA\tRETENTION\tBEFORE\tROWS")
+	for _, row := range []struct {
+		name  string
+		count model.RetentionCount
+	}{
+		{"trash", report.Trash},
+		{"audit logs", report.AuditLogs},
+		{"tombstones", report.Tombstones},
+		{"sessions", report.Sessions},
+	} {
+		retention, before := "forever", "-"
+		if row.count.Before != nil {
+			retention, before = row.count.Retention, row.count.Before.Format(time.RFC3339)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\n", row.name, retention, before, row.count.Rows)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if *dryRun {
+		fmt.Println("Dry run, nothing is deleted")
+	}
+	return nil
+}
+
+func findUserByEmailFlag(s storage.Store, email string) (*model.User, error) {
+	if email == "" {
+		return nil, errMissingEmail
+	}
+
+	user, err := s.Users().FindByEmail(email)
+	if err != nil {
+		return nil, fmt.Errorf("user %s could not be found: %w", email, err)
+	}
+	return user, nil
+}
+
+// passwordFlagOrPrompt returns the flag value or reads the password from the terminal
+func passwordFlagOrPrompt(password string) (string, error) {
+	if password == "" {
+		fmt.Print("Master password: ")
+		b, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return "", err
+		}
+		password = string(b)
+	}
+
+	if len(password) < 6 {
+		return "", errors.New("master password should be at least 6 characters")
+	}
+	return password, nil
+}
+
+// adminReencrypt queues a job for the worker of the running server, or shows the progress of the jobs
+func adminReencrypt(s storage.Store, args []string) error {
+	fs := flag.NewFlagSet("reencrypt", flag.ExitOnError)
+	reason := fs.String("reason", model.ReencryptKeyRotation, "key_rotation, cipher_upgrade or blind_index")
+	status := fs.Bool("status", false, "only show the progress of the jobs")
+	fs.Parse(args)
+
+	if !*status {
+		if *reason != model.ReencryptKeyRotation && *reason != model.ReencryptCipherUpgrade && *reason != model.ReencryptBlindIndex {
+			return fmt.Errorf("unknown reason %q", *reason)
+		}
+		job, err := app.QueueReencryption(s, *reason, 0)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Re-encryption job %d is queued, the server starts it within a minute\n", job.ID)
+	}
+
+	report, err := app.ReencryptionStatus(s, time.Now())
+	if err != nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tREASON\tUSER\tSTATUS\tPROGRESS\tROWS\tREENCRYPTED\tERROR")
+	for _, job := range report.Jobs {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t%s\t%d%%\t%d/%d\t%d\t%s\n",
+			job.ID, job.Reason, job.UserID, job.Status, job.Progress, job.Done, job.Total, job.Reencrypted, job.Error)
+	}
+	return tw.Flush()
+}
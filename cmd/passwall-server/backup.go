@@ -0,0 +1,158 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage"
+)
+
+const backupUsage = `Usage: passwall-server backup [-data-dir dir] <command> [flags]
+
+Commands:
+  verify <file>   Decrypt a backup file and check if it can be restored
+  restore <file>  Restore a backup file or an export archive into the vault of a user
+
+Run "passwall-server backup <command> -h" for the flags of a command.
+`
+
+// runBackup runs the backup subcommands
+func runBackup(args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "data folder of a server running in single binary mode")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, backupUsage) }
+	fs.Parse(args)
+	args = fs.Args()
+
+	if len(args) < 1 || args[0] != "verify" && args[0] != "restore" {
+		fmt.Fprint(os.Stderr, backupUsage)
+		return errors.New("unknown backup command")
+	}
+
+	if *dataDir != "" {
+		if err := config.SetDataDir(*dataDir); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := config.SetupConfigDefaults()
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "restore" {
+		return backupRestore(cfg, args[1:])
+	}
+	return backupVerify(cfg, args[1:])
+}
+
+func backupVerify(cfg *config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	restore := fs.Bool("restore", false, "restore into a temporary database to prove recoverability")
+	passphrase := fs.String("passphrase", "", "passphrase of the backup, server passphrase if empty")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("backup file is required")
+	}
+
+	if *passphrase == "" {
+		*passphrase = cfg.Server.Passphrase
+	}
+
+	report, err := app.VerifyBackup(fs.Arg(0), *passphrase, *restore)
+	if report != nil {
+		fmt.Printf("File:        %s\n", report.File)
+		fmt.Printf("Items:       %d\n", report.Rows)
+		if report.Compatible() {
+			fmt.Println("Schema:      compatible")
+		} else {
+			fmt.Printf("Schema:      unknown fields %s\n", strings.Join(report.UnknownFields, ", "))
+		}
+		if *restore {
+			fmt.Printf("Restored:    %d of %d items\n", report.RestoredRows, report.Rows)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if !report.Compatible() {
+		return errors.New("backup has fields which are not supported by this server version")
+	}
+	if *restore && report.RestoredRows != report.Rows {
+		return errors.New("backup could not be restored completely")
+	}
+
+	fmt.Println("Backup is OK")
+	return nil
+}
+
+func backupRestore(cfg *config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	email := fs.String("email", "", "email of the user whose vault is restored")
+	mode := fs.String("mode", app.RestoreMerge, "merge into the vault, or wipe the vault first")
+	dryRun := fs.Bool("dry-run", false, "report what would be restored without changing the vault")
+	passphrase := fs.String("passphrase", "", "passphrase of the backup, server passphrase if empty")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		return errors.New("backup file is required")
+	}
+	if *mode != app.RestoreMerge && *mode != app.RestoreWipe {
+		return fmt.Errorf("mode should be %s or %s", app.RestoreMerge, app.RestoreWipe)
+	}
+	if *passphrase == "" {
+		*passphrase = cfg.Server.Passphrase
+	}
+
+	data, err := ioutil.ReadFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	archive, err := app.OpenBackupArchive(data, *passphrase)
+	if err != nil {
+		return err
+	}
+
+	db, err := storage.DBConn(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	s := storage.New(db)
+	app.MigrateSystemTables(s)
+
+	user, err := findUserByEmailFlag(s, *email)
+	if err != nil {
+		return err
+	}
+
+	report, err := app.RestoreBackup(s, archive, *mode, *dryRun, user.Schema)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Folders:     %d deleted, %d restored\n", report.Deleted.Folders, report.Restored.Folders)
+	fmt.Printf("Tags:        %d deleted, %d restored\n", report.Deleted.Tags, report.Restored.Tags)
+	for _, itemType := range app.ItemTypes {
+		if report.Deleted.Items[itemType] == 0 && report.Restored.Items[itemType] == 0 {
+			continue
+		}
+		fmt.Printf("%-15s%d deleted, %d restored\n", strings.Title(itemType)+":", report.Deleted.Items[itemType], report.Restored.Items[itemType])
+	}
+	for _, skipped := range report.Skipped {
+		fmt.Printf("Skipped:     entry %d %q, %s\n", skipped.Entry, skipped.Title, skipped.Reason)
+	}
+
+	if *dryRun {
+		fmt.Println("Dry run, nothing is restored")
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+const keyUsage = `Usage: passwall-server key [-data-dir dir] <command> [flags]
+
+Commands:
+  split    Split the server passphrase into shares for the operators
+  wrap     Wrap the server passphrase with the kms and remove its plaintext
+
+Run "passwall-server key <command> -h" for the flags of a command.
+`
+
+// runKey runs the server key subcommands
+func runKey(args []string) error {
+	fs := flag.NewFlagSet("key", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "data folder of a server running in single binary mode")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, keyUsage) }
+	fs.Parse(args)
+	args = fs.Args()
+
+	if len(args) < 1 || (args[0] != "split" && args[0] != "wrap") {
+		fmt.Fprint(os.Stderr, keyUsage)
+		return errors.New("unknown key command")
+	}
+
+	if *dataDir != "" {
+		if err := config.SetDataDir(*dataDir); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := config.SetupConfigDefaults()
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "wrap" {
+		return keyWrap(cfg)
+	}
+	return keySplit(cfg, args[1:])
+}
+
+func keySplit(cfg *config.Configuration, args []string) error {
+	fs := flag.NewFlagSet("split", flag.ExitOnError)
+	shares := fs.Int("shares", 5, "number of shares to create, one for each operator")
+	threshold := fs.Int("threshold", 3, "number of shares required to recover the key")
+	remove := fs.Bool("remove", false, "remove the passphrase from the configuration file and require shares at startup")
+	fs.Parse(args)
+
+	if cfg.Server.KeyThreshold > 0 {
+		return errors.New("server key is already split, the passphrase isn't in the configuration")
+	}
+	if app.KMSEnabled() {
+		return app.ErrKMSWithKeyShares
+	}
+
+	keyShares, err := app.SplitServerKey(cfg.Server.Passphrase, *shares, *threshold)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Give each share to a different operator, any %d of them recover the server key:\n\n", *threshold)
+	for i, share := range keyShares {
+		fmt.Printf("Share %d: %s\n", i+1, share)
+	}
+	fmt.Println()
+
+	if !*remove {
+		fmt.Println("The passphrase is still in the configuration file, run with -remove to take it out.")
+		return nil
+	}
+
+	viper.Set("server.passphrase", "")
+	viper.Set("server.keyThreshold", *threshold)
+	if err := viper.WriteConfig(); err != nil {
+		return err
+	}
+
+	fmt.Printf("The passphrase is removed from %s, the server asks for %d shares at startup.\n", viper.ConfigFileUsed(), *threshold)
+	if os.Getenv("PW_SERVER_PASSPHRASE") != "" {
+		fmt.Println("PW_SERVER_PASSPHRASE is set, remove it from the environment of the server too.")
+	}
+	return nil
+}
+
+func keyWrap(cfg *config.Configuration) error {
+	if !app.KMSEnabled() {
+		return errors.New("kms.provider isn't set, configure the kms first")
+	}
+	if cfg.KMS.WrappedKey != "" {
+		return errors.New("server passphrase is already wrapped")
+	}
+	if err := app.WrapServerKey(); err != nil {
+		return err
+	}
+
+	fmt.Printf("The passphrase is wrapped with the %s kms and removed from %s.\n", cfg.KMS.Provider, viper.ConfigFileUsed())
+	if os.Getenv("PW_SERVER_PASSPHRASE") != "" {
+		fmt.Println("PW_SERVER_PASSPHRASE is set, remove it from the environment of the server too.")
+	}
+	return nil
+}
+
+// unsealServerKey recovers the server passphrase from the key shares of the operators
+// when server.keyThreshold is set, or unwraps it with the kms when kms.provider is
+func unsealServerKey(cfg *config.Configuration) error {
+	if app.KMSEnabled() {
+		if cfg.Server.KeyThreshold > 0 {
+			return app.ErrKMSWithKeyShares
+		}
+		if err := app.UnwrapServerKey(); err != nil {
+			return err
+		}
+		cfg.Server.Passphrase = viper.GetString("server.passphrase")
+		cfg.Server.PreviousPassphrase = viper.GetString("server.previousPassphrase")
+		return nil
+	}
+	if cfg.Server.KeyThreshold == 0 {
+		return nil
+	}
+
+	shares, err := readKeyShares(os.Stdin, cfg.Server.KeyThreshold)
+	if err != nil {
+		return err
+	}
+
+	passphrase, err := app.RecoverServerKey(shares)
+	if err != nil {
+		return err
+	}
+
+	viper.Set("server.passphrase", passphrase)
+	cfg.Server.Passphrase = passphrase
+	return nil
+}
+
+// readKeyShares reads k shares, one per line. They are prompted for without
+// echo on a terminal, otherwise they can be piped.
+func readKeyShares(in *os.File, k int) ([]string, error) {
+	shares := make([]string, 0, k)
+
+	if terminal.IsTerminal(int(in.Fd())) {
+		for len(shares) < k {
+			fmt.Printf("Key share %d of %d: ", len(shares)+1, k)
+			b, err := terminal.ReadPassword(int(in.Fd()))
+			fmt.Println()
+			if err != nil {
+				return nil, err
+			}
+			if share := strings.TrimSpace(string(b)); share != "" {
+				shares = append(shares, share)
+			}
+		}
+		return shares, nil
+	}
+
+	scanner := bufio.NewScanner(in)
+	for len(shares) < k && scanner.Scan() {
+		if share := strings.TrimSpace(scanner.Text()); share != "" {
+			shares = append(shares, share)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(shares) < k {
+		return nil, fmt.Errorf("%d key shares are required, got %d: %w", k, len(shares), io.ErrUnexpectedEOF)
+	}
+	return shares, nil
+}
@@ -1,34 +1,154 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
+	"github.com/passwall/passwall-server/internal/app"
 	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/i18n"
+	"github.com/passwall/passwall-server/internal/proxyproto"
 	"github.com/passwall/passwall-server/internal/router"
 	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
 
 	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
 )
 
 func main() {
+	commands := map[string]func([]string) error{
+		"admin":      runAdmin,
+		"backup":     runBackup,
+		"key":        runKey,
+		"migrate":    runMigrate,
+		"rotate-key": runRotateKey,
+	}
+
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			if err := command(os.Args[2:]); err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	// passwall-server [serve] [-data-dir dir] [-read-only] [-demo]
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "serve" {
+		args = args[1:]
+	}
+
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "run with an embedded SQLite database and keep all data in this folder")
+	readOnly := fs.Bool("read-only", false, "serve reads only and reject writes, e.g. from a read replica")
+	demo := fs.Bool("demo", false, "keep all data in memory, it is lost when the server stops")
+	fs.Parse(args)
+
+	if *dataDir != "" {
+		if err := config.SetDataDir(*dataDir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
 	cfg, err := config.SetupConfigDefaults()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if err := unsealServerKey(cfg); err != nil {
+		log.Fatal(err)
+	}
+
 	logFile, err := config.SetupLogger(cfg)
 	if err != nil {
 		log.Fatalf("Log folder %s doesn't exist", cfg.Server.LogPath)
 	}
 	defer logFile.Close()
 
-	db, err := storage.DBConn(&cfg.Database)
+	// Catalogs of the operator add languages or override the built in messages
+	if dir := viper.GetString("i18n.dir"); dir != "" {
+		if err := i18n.LoadDir(dir); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Spans of the requests, services and queries are exported to an OpenTelemetry collector
+	if cfg.Tracing.Endpoint != "" {
+		exporter := tracing.NewExporter(tracing.Config{
+			Endpoint:    cfg.Tracing.Endpoint,
+			ServiceName: cfg.Tracing.ServiceName,
+			SampleRatio: cfg.Tracing.SampleRatio,
+			Headers:     tracing.ParseHeaders(cfg.Tracing.Headers),
+		})
+		tracing.Use(exporter)
+		defer exporter.Shutdown()
+	}
+
+	s, err := openStore(&cfg.Database, *demo)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	s := storage.New(db)
+	if *readOnly {
+		viper.Set("server.readOnly", true)
+	}
+
+	// Create missing tables of system and user schemas
+	// A read-only server can't write to its database, the primary migrates it
+	if viper.GetBool("server.readOnly") {
+		log.Info("running in read-only mode")
+	} else {
+		if cfg.Database.AutoMigrate {
+			app.MigrateSystemTables(s)
+			app.MigrateAllUserTables(s)
+		}
+
+		// Credentials are rotated by the primary too
+		if err := app.StartRotationJob(s); err != nil {
+			log.Fatal(err)
+		}
+
+		// Audit events are chained in the database with signed checkpoints
+		log.AddHook(app.NewAuditHook(s))
+		if err := app.StartAuditCheckpointJob(s); err != nil {
+			log.Fatal(err)
+		}
+
+		// Data older than the retention periods of the server policy is purged
+		if err := app.StartRetentionJob(s); err != nil {
+			log.Fatal(err)
+		}
+
+		// Rows are re-encrypted after key rotations, master password changes and cipher upgrades
+		if err := app.StartReencryptionWorker(s); err != nil {
+			log.Fatal(err)
+		}
+
+		// Exports are built by background workers and kept in the blob store
+		if err := app.StartExportWorkers(s); err != nil {
+			log.Fatal(err)
+		}
+
+		// Every vault is backed up to encrypted files at the times of the backup schedule
+		if err := app.StartBackupJob(s); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	shutdownTimeout, err := time.ParseDuration(cfg.Server.ShutdownTimeout)
+	if err != nil {
+		log.Fatalf("server.shutdownTimeout: %v", err)
+	}
 
 	srv := &http.Server{
 		MaxHeaderBytes: 10, // 10 MB
@@ -39,8 +159,70 @@ func main() {
 		Handler:        router.New(s),
 	}
 
+	listener, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Load balancers in front of the server send the client address in a PROXY protocol header
+	if cfg.Server.ProxyProtocol {
+		if len(app.TrustedProxies()) == 0 {
+			log.Fatal("proxy protocol requires the networks of the load balancers in server.trustedProxies")
+		}
+		listener = &proxyproto.Listener{Listener: listener, Trusted: app.TrustedProxy}
+	}
+
+	// SIGHUP reloads the log level, rate limit, email and CORS settings of the configuration file
+	go func() {
+		reloads := make(chan os.Signal, 1)
+		signal.Notify(reloads, syscall.SIGHUP)
+		for range reloads {
+			app.ReloadConfig()
+		}
+	}()
+
+	// SIGTERM and SIGINT close the listener, in-flight requests finish within server.shutdownTimeout
+	drained := make(chan struct{})
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+		log.WithField("signal", (<-signals).String()).Info("shutting down")
+
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(ctx); err != nil {
+			log.WithError(err).Warn("in-flight requests are cut off")
+		}
+		close(drained)
+	}()
+
 	log.Infof("listening on %s", cfg.Server.Port)
-	if err := srv.ListenAndServe(); err != nil {
+	if err := srv.Serve(listener); err != http.ErrServerClosed {
 		log.Fatal(err)
 	}
+	<-drained
+
+	// Background jobs end their runs and alerts are sent before the database is closed
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := app.Shutdown(ctx); err != nil {
+		log.WithError(err).Warn("background jobs are cut off")
+	}
+	if err := s.Close(); err != nil {
+		log.WithError(err).Warn("database connection couldn't be closed")
+	}
+	log.Info("server is stopped")
+}
+
+// openStore connects to the database of the configuration, the demo keeps its data in memory
+func openStore(cfg *config.DatabaseConfiguration, demo bool) (*storage.Database, error) {
+	if demo {
+		log.Warn("running the demo, all data is lost when the server stops")
+		return storage.NewMemory()
+	}
+	db, err := storage.DBConn(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return storage.New(db), nil
 }
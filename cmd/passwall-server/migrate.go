@@ -0,0 +1,115 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage"
+)
+
+const migrateUsage = `Usage: passwall-server migrate [-data-dir dir] <command> [flags]
+
+Commands:
+  up      Apply the pending migrations of the system schema and of all user schemas
+  down    Revert the migrations of a schema after a version
+  status  List the migration version and the pending migrations of each schema
+
+Run "passwall-server migrate <command> -h" for the flags of a command.
+`
+
+// runMigrate runs the migrate subcommands against the configured database
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "data folder of a server running in single binary mode")
+	fs.Usage = func() { fmt.Fprint(os.Stderr, migrateUsage) }
+	fs.Parse(args)
+	args = fs.Args()
+
+	commands := map[string]func(storage.Store, []string) error{
+		"up":     migrateUp,
+		"down":   migrateDown,
+		"status": migrateStatus,
+	}
+	if len(args) < 1 || commands[args[0]] == nil {
+		fmt.Fprint(os.Stderr, migrateUsage)
+		return errors.New("unknown migrate command")
+	}
+
+	if *dataDir != "" {
+		if err := config.SetDataDir(*dataDir); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := config.SetupConfigDefaults()
+	if err != nil {
+		return err
+	}
+
+	db, err := storage.DBConn(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	return commands[args[0]](storage.New(db), args[1:])
+}
+
+func migrateUp(s storage.Store, args []string) error {
+	fs := flag.NewFlagSet("up", flag.ExitOnError)
+	fs.Parse(args)
+
+	// The users are read from the system schema, it is migrated first
+	if err := app.MigrateUp(s, app.SystemSchema); err != nil {
+		return err
+	}
+	statuses, err := app.MigrationStatus(s)
+	if err != nil {
+		return err
+	}
+	for _, status := range statuses {
+		if len(status.Pending) == 0 {
+			continue
+		}
+		if err := app.MigrateUp(s, status.Schema); err != nil {
+			return err
+		}
+		fmt.Printf("%s: applied %s\n", status.Schema, strings.Join(status.Pending, ", "))
+	}
+	return nil
+}
+
+func migrateDown(s storage.Store, args []string) error {
+	fs := flag.NewFlagSet("down", flag.ExitOnError)
+	schema := fs.String("schema", "", "schema to revert, public for the system tables")
+	version := fs.Int("to", -1, "version the schema is reverted to, 0 reverts all migrations")
+	fs.Parse(args)
+
+	if *schema == "" || *version < 0 {
+		return errors.New("schema and version are required")
+	}
+	return app.MigrateDown(s, *schema, *version)
+}
+
+func migrateStatus(s storage.Store, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ExitOnError)
+	fs.Parse(args)
+
+	statuses, err := app.MigrationStatus(s)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SCHEMA\tVERSION\tLATEST\tPENDING")
+	for _, status := range statuses {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\n", status.Schema, status.Version, status.Latest, strings.Join(status.Pending, ", "))
+	}
+	return w.Flush()
+}
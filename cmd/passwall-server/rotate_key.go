@@ -0,0 +1,167 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+// rotateKeyProgress is how often the progress of the re-encryption is printed
+const rotateKeyProgress = 5 * time.Second
+
+// runRotateKey replaces the server passphrase and re-encrypts the vaults with the new one.
+// An interrupted rotation resumes from the cursor of its job when the command is run again.
+func runRotateKey(args []string) error {
+	fs := flag.NewFlagSet("rotate-key", flag.ExitOnError)
+	dataDir := fs.String("data-dir", "", "data folder of a server running in single binary mode")
+	generate := fs.Bool("generate", false, "generate the new passphrase instead of asking for it")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: passwall-server rotate-key [-data-dir dir] [-generate]\n\n"+
+			"Stop the server first, or use POST /admin/rotate-key while it runs.\n\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	if *dataDir != "" {
+		if err := config.SetDataDir(*dataDir); err != nil {
+			return err
+		}
+	}
+
+	cfg, err := config.SetupConfigDefaults()
+	if err != nil {
+		return err
+	}
+	if err := unsealServerKey(cfg); err != nil {
+		return err
+	}
+	if err := app.CheckCipher(); err != nil {
+		return err
+	}
+	batchSize := cfg.Reencryption.BatchSize
+	if batchSize <= 0 {
+		batchSize = 100
+	}
+
+	db, err := storage.DBConn(&cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	s := storage.New(db)
+	app.MigrateSystemTables(s)
+
+	if viper.GetString("server.previousPassphrase") != "" {
+		// The unfinished job resumes from its cursor, a failed one is started over
+		fmt.Println("Resuming the rotation to the current passphrase")
+		jobs, err := s.Reencryption().FindUnfinished()
+		if err != nil {
+			return err
+		}
+		if len(jobs) == 0 {
+			if _, err := app.QueueReencryption(s, model.ReencryptKeyRotation, 0); err != nil {
+				return err
+			}
+		}
+	} else {
+		passphrase, err := newPassphrase(*generate)
+		if err != nil {
+			return err
+		}
+		job, err := app.RotateServerKey(s, passphrase)
+		if err != nil {
+			return err
+		}
+		if *generate {
+			fmt.Printf("New server passphrase: %s\n", passphrase)
+		}
+		fmt.Printf("The passphrase is rotated, job %d re-encrypts the vaults\n", job.ID)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go printRotationProgress(s, done)
+
+	for {
+		ran, err := app.RunNextReencryption(s, batchSize, 0)
+		if err != nil {
+			return fmt.Errorf("re-encryption stopped, run rotate-key again to resume: %w", err)
+		}
+		if !ran {
+			break
+		}
+	}
+
+	if err := app.FinishKeyRotation(s); err != nil {
+		return err
+	}
+	fmt.Println("The vaults are re-encrypted and the previous passphrase is dropped")
+	return nil
+}
+
+// newPassphrase generates a passphrase or reads it twice without echo
+func newPassphrase(generate bool) (string, error) {
+	if generate {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(b), nil
+	}
+
+	fmt.Print("New server passphrase: ")
+	first, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	fmt.Print("Repeat the passphrase: ")
+	second, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Println()
+	if err != nil {
+		return "", err
+	}
+	if string(first) != string(second) {
+		return "", fmt.Errorf("passphrases don't match")
+	}
+	return strings.TrimSpace(string(first)), nil
+}
+
+// printRotationProgress prints the progress of the running job until done is closed
+func printRotationProgress(s storage.Store, done chan struct{}) {
+	ticker := time.NewTicker(rotateKeyProgress)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		report, err := app.ReencryptionStatus(s, time.Now())
+		if err != nil || !report.Running {
+			continue
+		}
+		for _, job := range report.Jobs {
+			if job.Status != model.ReencryptionRunning {
+				continue
+			}
+			line := fmt.Sprintf("Job %d: %d%%, %d/%d rows, %d re-encrypted", job.ID, job.Progress, job.Done, job.Total, job.Reencrypted)
+			if job.ETA != nil {
+				line += ", done around " + job.ETA.Format("15:04:05")
+			}
+			fmt.Println(line)
+		}
+	}
+}
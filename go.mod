@@ -15,6 +15,8 @@ require (
 	github.com/gorilla/mux v1.7.4
 	github.com/heroku/x v0.0.22
 	github.com/jinzhu/gorm v1.9.12
+	github.com/lib/pq v1.1.1
+	github.com/mattn/go-sqlite3 v2.0.1+incompatible
 	github.com/patrickmn/go-cache v2.1.0+incompatible // indirect
 	github.com/satori/go.uuid v1.2.0
 	github.com/sendgrid/rest v2.6.2+incompatible // indirect
@@ -24,6 +26,7 @@ require (
 	github.com/stretchr/testify v1.5.1
 	github.com/urfave/negroni v1.0.0
 	golang.org/x/crypto v0.0.0-20200604202706-70a84ac30bf9
+	golang.org/x/net v0.0.0-20190827160401-ba9fcec4b297
 	golang.org/x/sys v0.0.0-20200420163511-1957bb5e6d1f // indirect
 	gopkg.in/check.v1 v1.0.0-20190902080502-41f04d3bba15 // indirect
 	gopkg.in/yaml.v2 v2.2.8
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	accessTokenDeleteSuccess = "Personal access token revoked successfully!"
+	accessTokenNotFound      = "Personal access token not found"
+	accessTokenNotAllowed    = "Personal access tokens can't be created in this session"
+)
+
+// FindAllAccessTokens lists the personal access tokens of the user without the tokens themselves
+func FindAllAccessTokens(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := uint(r.Context().Value("id").(float64))
+		tokens, err := s.AccessTokens().FindAllByUserID(userID)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		// Personal access tokens open the real vault
+		if isDuress(r) {
+			tokens = []model.PersonalAccessToken{}
+		}
+
+		respondAccessToken(w, r, model.ToPersonalAccessTokenDTOs(tokens))
+	}
+}
+
+// CreateAccessToken creates a personal access token, the token and its transmission key
+// are only in this response. Tokens can't create other tokens.
+func CreateAccessToken(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := r.Context().Value("accessToken").(uint); ok || isDuress(r) {
+			RespondWithError(w, http.StatusForbidden, accessTokenNotAllowed)
+			return
+		}
+
+		payload, err := ToPayload(r)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		// Decrypt payload
+		dto := new(model.PersonalAccessTokenDTO)
+		key := r.Context().Value("transmissionKey").(string)
+		if err := app.DecryptJSON(key, []byte(payload.Data), dto); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		user, err := s.Users().FindByID(uint(r.Context().Value("id").(float64)))
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		token, raw, err := app.CreateAccessToken(s, user, dto)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		tokenDTO := model.ToPersonalAccessTokenDTO(token)
+		tokenDTO.Token = raw
+		tokenDTO.TransmissionKey = token.TransmissionKey
+
+		respondAccessToken(w, r, tokenDTO)
+	}
+}
+
+// DeleteAccessToken revokes a personal access token of the user
+func DeleteAccessToken(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		token, err := s.AccessTokens().FindByID(uint(id))
+		if err != nil || token.UserID != uint(r.Context().Value("id").(float64)) || isDuress(r) {
+			RespondWithError(w, http.StatusNotFound, accessTokenNotFound)
+			return
+		}
+
+		if err := app.RevokeAccessToken(s, token); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: accessTokenDeleteSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+func respondAccessToken(w http.ResponseWriter, r *http.Request, v interface{}) {
+	// Encrypt payload
+	var payload model.Payload
+	key := r.Context().Value("transmissionKey").(string)
+	encrypted, err := app.EncryptJSON(key, v)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	payload.Data = string(encrypted)
+
+	RespondWithJSON(w, http.StatusOK, payload)
+}
@@ -0,0 +1,251 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	attachmentDeleteSuccess = "Attachment deleted successfully!"
+	attachmentNoFile        = "Request should be multipart/form-data with a file field"
+)
+
+// FindAttachments finds the attachments of an item of any type
+func FindAttachments(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemType, itemID, ok := attachmentItem(s, w, r)
+		if !ok {
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		attachments, err := app.FindAttachments(s, itemType, itemID, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondAttachment(w, r, http.StatusOK, attachments)
+	}
+}
+
+// CreateAttachment streams the file field of a multipart form into a new attachment of
+// the item, files beyond the limits of the user get 413
+func CreateAttachment(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		itemType, itemID, ok := attachmentItem(s, w, r)
+		if !ok {
+			return
+		}
+
+		mr, err := r.MultipartReader()
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, attachmentNoFile)
+			return
+		}
+		defer r.Body.Close()
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				RespondWithError(w, http.StatusBadRequest, attachmentNoFile)
+				return
+			}
+			if part.FormName() != "file" {
+				part.Close()
+				continue
+			}
+
+			userID := uint(r.Context().Value("id").(float64))
+			schema := r.Context().Value("schema").(string)
+			attachment, err := app.CreateAttachment(s, userID, itemType, itemID, part.FileName(), part.Header.Get("Content-Type"), part, schema)
+			if errors.Is(err, app.ErrAttachmentTooLarge) || errors.Is(err, app.ErrAttachmentQuota) {
+				RespondWithError(w, http.StatusRequestEntityTooLarge, err.Error())
+				return
+			}
+			if err != nil {
+				RespondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			respondAttachment(w, r, http.StatusCreated, model.ToAttachmentDTO(attachment))
+			return
+		}
+	}
+}
+
+// DownloadAttachment sends the decrypted content of an attachment
+func DownloadAttachment(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attachment, ok := findAttachment(s, w, r)
+		if !ok {
+			return
+		}
+
+		sum, _ := hex.DecodeString(attachment.SHA256)
+		w.Header().Set("Content-Type", attachment.ContentType)
+		w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Name}))
+		w.Header().Set("Content-Length", strconv.FormatInt(attachment.Size, 10))
+		w.Header().Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum))
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "sandbox")
+		w.WriteHeader(http.StatusOK)
+
+		// The status is sent already, a damaged file ends the response early
+		if err := app.OpenAttachment(w, attachment); err != nil {
+			log.WithFields(log.Fields{"attachment_id": attachment.ID, "error": err.Error()}).Error("attachment download failed")
+		}
+	}
+}
+
+// DeleteAttachment deletes an attachment and its content
+func DeleteAttachment(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		attachment, ok := findAttachment(s, w, r)
+		if !ok {
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		if err := app.DeleteAttachment(s, attachment, schema); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: attachmentDeleteSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// AttachmentUsage returns the space the attachments of the vault use and the limits of
+// the user
+func AttachmentUsage(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := uint(r.Context().Value("id").(float64))
+		schema := r.Context().Value("schema").(string)
+		usage, err := app.AttachmentUsage(s, userID, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, usage)
+	}
+}
+
+// SetAttachmentLimits lets an admin change the attachment limits of a user
+func SetAttachmentLimits(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		dto := new(model.AttachmentLimitsDTO)
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		if _, err := s.Users().FindByID(uint(id)); err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		policy, err := app.SetAttachmentLimits(s, uint(id), dto, adminName(r))
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, model.ToPolicyDTO(policy))
+	}
+}
+
+// attachmentItem returns the type and id of the item of an attachment request, it answers
+// 404 when the vault has no such item
+func attachmentItem(s storage.Store, w http.ResponseWriter, r *http.Request) (string, uint, bool) {
+	vars := mux.Vars(r)
+	id, err := strconv.Atoi(vars["id"])
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return "", 0, false
+	}
+
+	schema := r.Context().Value("schema").(string)
+	item, err := app.FindItem(s, vars["type"], uint(id), schema)
+	if err != nil {
+		RespondWithError(w, http.StatusNotFound, err.Error())
+		return "", 0, false
+	}
+
+	tripCanaries(s, r, item, app.CanaryRead)
+	return vars["type"], uint(id), true
+}
+
+// findAttachment returns the attachment of the item of the request
+func findAttachment(s storage.Store, w http.ResponseWriter, r *http.Request) (*model.Attachment, bool) {
+	itemType, itemID, ok := attachmentItem(s, w, r)
+	if !ok {
+		return nil, false
+	}
+	id, err := strconv.Atoi(mux.Vars(r)["attachment"])
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	schema := r.Context().Value("schema").(string)
+	attachment, err := app.FindAttachment(s, itemType, itemID, uint(id), schema)
+	if errors.Is(err, app.ErrAttachmentNotFound) {
+		RespondWithError(w, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+	return attachment, true
+}
+
+func respondAttachment(w http.ResponseWriter, r *http.Request, code int, v interface{}) {
+	// Encrypt payload
+	var payload model.Payload
+	key := r.Context().Value("transmissionKey").(string)
+	encrypted, err := app.EncryptJSON(key, v)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	payload.Data = string(encrypted)
+
+	RespondWithJSON(w, code, payload)
+}
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// FindAuditEvents finds the audit events of the vault, newest first. They can be
+// filtered by the Since and Until dates and the Action and are paged with Limit and Offset.
+func FindAuditEvents(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := app.ParseAuditFilter(r.FormValue("Since"), r.FormValue("Until"), r.FormValue("Action"))
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		_, argsInt := SetArgs(r, nil)
+		filter.Limit, filter.Offset = argsInt["limit"], argsInt["offset"]
+
+		schema := r.Context().Value("schema").(string)
+		events, err := s.AuditEvents().FindAll(filter, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Encrypt payload
+		var payload model.Payload
+		key := r.Context().Value("transmissionKey").(string)
+		encrypted, err := app.EncryptJSON(key, events)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
+
+// ExportAuditEvents streams the audit events of all vaults as CSV or JSON Lines, only
+// admins can do it. Since, Until and Action filter them like FindAuditEvents.
+func ExportAuditEvents(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		format := r.FormValue("format")
+		if format == "" {
+			format = app.AuditExportCSV
+		}
+		if err := app.CheckAuditExportFormat(format); err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		filter, err := app.ParseAuditFilter(r.FormValue("Since"), r.FormValue("Until"), r.FormValue("Action"))
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		contentType := "text/csv; charset=utf-8"
+		if format == app.AuditExportJSONL {
+			contentType = "application/x-ndjson"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-logs-%s.%s"`, time.Now().Format("20060102"), format))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+
+		// The status is sent, a failure can only cut the stream short
+		if err := app.ExportAuditEvents(s, w, format, filter); err != nil {
+			log.WithError(err).Error("audit log export failed")
+		}
+	}
+}
+
+// VerifyAuditLog checks the chain and the checkpoints of the audit trail, only admins can do it
+func VerifyAuditLog(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		report, err := app.VerifyAuditLog(s)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, report)
+	}
+}
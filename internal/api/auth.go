@@ -2,8 +2,9 @@ package api
 
 import (
 	"encoding/json"
-	"errors"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,21 +12,31 @@ import (
 	"github.com/go-playground/validator/v10"
 	"github.com/gorilla/mux"
 	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/i18n"
 	"github.com/passwall/passwall-server/internal/storage"
 	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
 var (
-	userLoginErr   = "User email or master password is wrong."
-	userVerifyErr  = "Please verify your email first."
-	invalidUser    = "Invalid user"
-	validToken     = "Token is valid"
-	invalidToken   = "Token is expired or not valid!"
-	noToken        = "Token could not found! "
-	tokenCreateErr = "Token could not be created"
-	signupSuccess  = "User created successfully"
-	verifySuccess  = "Email verified successfully"
+	userLoginErr         = "User email or master password is wrong."
+	userVerifyErr        = "Please verify your email first."
+	accountLockedErr     = "Account is locked after too many failed sign ins, try again later"
+	signinThrottledErr   = "Too many failed sign ins, try again later"
+	captchaVerifyErr     = "Captcha couldn't be verified, try again later"
+	invalidUser          = "Invalid user"
+	validToken           = "Token is valid"
+	invalidToken         = "Token is expired or not valid!"
+	noToken              = "Token could not found! "
+	tokenCreateErr       = "Token could not be created"
+	tokenRevokeSuccess   = "Token revoked successfully!"
+	signupSuccess        = "User created successfully"
+	verifySuccess        = "Email verified successfully"
+	verifyResendSuccess  = "A new verification link is sent if the account isn't verified yet"
+	verifyResendLimitErr = "Too many verification emails, try again later"
+	recoverSuccess       = "A password reset link is sent if the account exists"
+	resetSuccess         = "Master password is reset, sign in with the new one"
 )
 
 // Signup ...
@@ -44,20 +55,41 @@ func Signup(s storage.Store) http.HandlerFunc {
 		validate := validator.New()
 		validateError := validate.Struct(userSignup)
 		if validateError != nil {
-			errs := GetErrors(validateError.(validator.ValidationErrors))
+			errs := GetErrors(w, validateError.(validator.ValidationErrors))
 			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
 			return
 		}
 
-		// 2. Check and verify the recaptcha response token.
-		if err := CheckRecaptcha(userSignup.Recaptcha); err != nil {
-			RespondWithError(w, http.StatusUnauthorized, err.Error())
+		// 2. Check and verify the captcha response token, when the captcha is on
+		ip := ""
+		if clientIP := app.ClientIP(r); clientIP != nil {
+			ip = clientIP.String()
+		}
+		if err := app.VerifyCaptcha(userSignup.CaptchaResponse(), ip); err != nil {
+			switch err {
+			case app.ErrCaptchaRequired:
+				RespondWithErrors(w, http.StatusBadRequest, err.Error(), []string{app.SignupCaptchaRequired})
+			case app.ErrCaptchaInvalid:
+				RespondWithErrors(w, http.StatusBadRequest, err.Error(), []string{app.SignupCaptchaInvalid})
+			default:
+				log.WithError(err).Error("captcha couldn't be verified")
+				RespondWithError(w, http.StatusServiceUnavailable, captchaVerifyErr)
+			}
 			return
 		}
 
 		// 3. Check if user exist in database
 		userDTO := model.ConvertUserDTO(userSignup)
-		_, err := s.Users().FindByEmail(userDTO.Email)
+		err := app.CheckSignupEmail(s, userDTO.Email)
+		if err == app.ErrDisposableEmail {
+			RespondWithErrors(w, http.StatusBadRequest, err.Error(), []string{app.SignupDisposableEmail})
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		_, err = s.Users().FindByEmail(userDTO.Email)
 		if err == nil {
 			RespondWithError(w, http.StatusBadRequest, "User couldn't created!")
 			return
@@ -70,8 +102,8 @@ func Signup(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		confirmationCode := app.RandomMD5Hash()
-		createdUser.ConfirmationCode = confirmationCode
+		createdUser.ConfirmationCode = app.RandomMD5Hash()
+		createdUser.Locale = i18n.LocaleOf(w)
 
 		// 5. Update user once to generate schema
 		updatedUser, err := app.GenerateSchema(s, createdUser)
@@ -101,16 +133,10 @@ func Signup(s storage.Store) http.HandlerFunc {
 			subject,
 			body)
 
-		// 9. Send confirmation email to new user
-		confirmationSubject := "Passwall Email Confirmation"
-		confirmationBody := "Last step for use Passwall\n\n"
-		confirmationBody += "Confirmation link: " + viper.GetString("server.domain")
-		confirmationBody += "/auth/confirm/" + userDTO.Email + "/" + confirmationCode
-		app.SendMail(
-			userDTO.Name,
-			userDTO.Email,
-			confirmationSubject,
-			confirmationBody)
+		// 9. Send the verification link to new user in the language of the signup
+		if err := app.SendVerificationEmail(s, updatedUser); err != nil {
+			log.WithError(err).Error("verification email couldn't be sent")
+		}
 
 		// Return success message
 		response := model.Response{
@@ -122,48 +148,168 @@ func Signup(s storage.Store) http.HandlerFunc {
 	}
 }
 
-func CheckRecaptcha(gCaptchaValue string) error {
+// VerifyEmail verifies the account of the signed link in the verification email
+func VerifyEmail(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if _, err := app.VerifyEmail(s, r.FormValue("token")); err != nil {
+			if err == app.ErrVerificationToken {
+				RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
 
-	type SiteVerifyResponse struct {
-		Success     bool     `json:"success"`
-		ChallengeTS string   `json:"challenge_ts"`
-		Hostname    string   `json:"hostname"`
-		ErrorCodes  []string `json:"error-codes"`
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: verifySuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
 	}
+}
 
-	const siteVerifyURL = "https://www.google.com/recaptcha/api/siteverify"
+// ResendVerification sends a new verification link to an unverified account. The response
+// is the same for all addresses, so it doesn't tell which accounts exist.
+func ResendVerification(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var dto model.VerificationResendDTO
+		if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+			RespondWithError(w, http.StatusUnprocessableEntity, InvalidJSON)
+			return
+		}
+		defer r.Body.Close()
 
-	// Create new request
-	req, err := http.NewRequest(http.MethodPost, siteVerifyURL, nil)
-	if err != nil {
-		return err
-	}
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
 
-	// Add necessary request
-	q := req.URL.Query()
-	q.Add("secret", viper.GetString("server.recaptcha"))
-	q.Add("response", gCaptchaValue)
-	req.URL.RawQuery = q.Encode()
+		status, err := app.ResendVerificationEmail(s, dto.Email, time.Now())
+		if !status.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(status.RetryAfter.Seconds())))
+			RespondWithErrors(w, http.StatusTooManyRequests, verifyResendLimitErr, []string{"BUDGET_EXCEEDED"})
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("verification email couldn't be sent")
+		}
 
-	// Make request
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: verifyResendSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
 	}
-	defer resp.Body.Close()
+}
+
+// Prelogin returns the key derivation function of the account before its sign in,
+// unknown emails get the one of the server
+func Prelogin(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var dto model.PreloginDTO
+		if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+			RespondWithError(w, http.StatusUnprocessableEntity, InvalidJSON)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
 
-	// Decode response.
-	var body SiteVerifyResponse
-	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
-		return err
+		RespondWithJSON(w, http.StatusOK, app.Prelogin(s, dto.Email))
 	}
+}
+
+// RecoverPassword emails a reset link of the master password. The response is the same
+// for all addresses, so it doesn't tell which accounts exist.
+func RecoverPassword(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var dto model.PasswordRecoverDTO
+		if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+			RespondWithError(w, http.StatusUnprocessableEntity, InvalidJSON)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
 
-	// Check recaptcha verification success.
-	if !body.Success {
-		return errors.New("Unsuccessful recaptcha verify request")
+		status, err := app.RecoverPassword(s, dto.Email, time.Now())
+		if err == app.ErrPasswordResetOff {
+			RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if !status.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(status.RetryAfter.Seconds())))
+			RespondWithErrors(w, http.StatusTooManyRequests, verifyResendLimitErr, []string{"BUDGET_EXCEEDED"})
+			return
+		}
+		if err != nil {
+			log.WithError(err).Error("password reset email couldn't be sent")
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: recoverSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
 	}
+}
 
-	return nil
+// ResetPassword sets a new master password with the token of a reset link
+func ResetPassword(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var dto model.PasswordResetDTO
+		if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+			RespondWithError(w, http.StatusUnprocessableEntity, InvalidJSON)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		_, err := app.ResetPassword(s, &dto)
+		switch err {
+		case nil:
+		case app.ErrPasswordResetOff:
+			RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		case app.ErrPasswordResetToken:
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		case app.ErrVaultWipeRequired:
+			RespondWithErrors(w, http.StatusConflict, err.Error(), []string{app.ResetVaultWipeRequired})
+			return
+		default:
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: resetSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
 }
 
 // Confirm ...
@@ -229,51 +375,140 @@ func Signin(s storage.Store) http.HandlerFunc {
 		validate := validator.New()
 		validateError := validate.Struct(loginDTO)
 		if validateError != nil {
-			errs := GetErrors(validateError.(validator.ValidationErrors))
+			errs := GetErrors(w, validateError.(validator.ValidationErrors))
 			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
 			return
 		}
 
+		// Locked accounts and addresses aren't checked, failures make the next sign in wait
+		ip := ""
+		if clientIP := app.ClientIP(r); clientIP != nil {
+			ip = clientIP.String()
+		}
+		attempt, throttle := app.StartSignin(s, loginDTO.Email, ip, time.Now())
+		if throttle != nil {
+			respondSigninThrottle(w, throttle)
+			return
+		}
+
 		// Check if user exist in database and credentials are true
-		user, err := s.Users().FindByCredentials(loginDTO.Email, loginDTO.MasterPassword)
+		user, duress, err := app.Authenticate(s, loginDTO.Email, loginDTO.MasterPassword, ip)
 		if err != nil {
+			attempt.Fail(time.Now())
+			if known, err := s.Users().FindByEmail(loginDTO.Email); err == nil {
+				app.RecordSignin(s, r, known, false, app.AuditFailure)
+			}
 			RespondWithError(w, http.StatusUnauthorized, userLoginErr)
 			return
 		}
 
 		// Check if users email is verified
-		// if user.EmailVerifiedAt.IsZero() {
-		// 	RespondWithError(w, http.StatusForbidden, userVerifyErr)
-		// 	return
-		// }
+		if app.EmailVerificationRequired(user) {
+			attempt.Succeed()
+			RespondWithErrors(w, http.StatusForbidden, userVerifyErr, []string{app.SigninEmailNotVerified})
+			return
+		}
+
+		continueSignin(s, w, r, user, duress, &loginDTO.DeviceSigninDTO, attempt)
+	}
+}
 
-		// Check if user has an active subscription
-		subscription, _ := s.Subscriptions().FindByEmail(user.Email)
+// respondSigninThrottle tells the client when its next sign in is checked, locked
+// accounts get 423 and waiting ones 429
+func respondSigninThrottle(w http.ResponseWriter, throttle *app.SigninThrottle) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttle.RetryAfter.Seconds()))))
+	if throttle.Locked {
+		RespondWithErrors(w, http.StatusLocked, accountLockedErr, []string{"ACCOUNT_LOCKED"})
+		return
+	}
+	RespondWithErrors(w, http.StatusTooManyRequests, signinThrottledErr, []string{"SIGNIN_THROTTLED"})
+}
 
-		//create token
-		token, err := app.CreateToken(user)
+// continueSignin starts the session of the authenticated user, or asks for the second
+// factor first when the user has one and the device isn't trusted. The attempt of a master
+// password sign in only succeeds without a second factor, sign ins of identity providers
+// have none.
+func continueSignin(s storage.Store, w http.ResponseWriter, r *http.Request, user *model.User, duress bool, device *model.DeviceSigninDTO, attempt *app.SigninAttempt) {
+	methods, err := app.TwoFactorMethods(s, user)
+	if err != nil {
+		if attempt != nil {
+			attempt.Continue()
+		}
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	// Trusted devices verified the second factor before
+	var trusted *model.TrustedDevice
+	if len(methods) > 0 {
+		if device, ok := app.FindTrustedDevice(s, user, device, duress); ok {
+			trusted = device
+		}
+	}
+	if attempt != nil {
+		if len(methods) > 0 && trusted == nil {
+			attempt.Continue()
+		} else {
+			attempt.Succeed()
+		}
+	}
+	if trusted != nil {
+		session := &app.Session{Start: time.Now(), Duress: duress, TwoFactor: true, SecurityKey: trusted.SecurityKey}
+		if !checkAccess(s, w, r, user.ID, session) {
+			return
+		}
+		respondWithSession(s, w, r, user, session, "")
+		return
+	}
+
+	// Tokens wait for the second factor, the challenge continues the sign in
+	if len(methods) > 0 {
+		challenge, err := app.CreateTwoFactorChallenge(user, duress)
 		if err != nil {
 			RespondWithError(w, http.StatusInternalServerError, tokenCreateErr)
 			return
 		}
+		RespondWithJSON(w, http.StatusOK, model.AuthLoginResponse{
+			TwoFactorRequired: true,
+			TwoFactorToken:    challenge,
+			TwoFactorMethods:  methods,
+		})
+		return
+	}
 
-		//delete tokens from db
-		s.Tokens().Delete(int(user.ID))
+	if !checkAccess(s, w, r, user.ID, &app.Session{}) {
+		return
+	}
+	respondWithSession(s, w, r, user, &app.Session{Start: time.Now(), Duress: duress}, "")
+}
 
-		//create tokens on db
-		s.Tokens().Save(int(user.ID), token.AtUUID, token.AccessToken, token.AtExpiresTime, token.TransmissionKey)
-		s.Tokens().Save(int(user.ID), token.RtUUID, token.RefreshToken, token.RtExpiresTime, "")
+// respondWithSession creates the tokens of a new session, the sessions of other devices go on.
+// The device token of a device trusted with the sign in is returned with them.
+func respondWithSession(s storage.Store, w http.ResponseWriter, r *http.Request, user *model.User, session *app.Session, deviceToken string) {
+	// Check if user has an active subscription
+	subscription, _ := s.Subscriptions().FindByEmail(user.Email)
 
-		authLoginResponse := model.AuthLoginResponse{
-			AccessToken:         token.AccessToken,
-			RefreshToken:        token.RefreshToken,
-			TransmissionKey:     token.TransmissionKey,
-			UserDTO:             model.ToUserDTO(user),
-			SubscriptionAuthDTO: model.ToSubscriptionAuthDTO(subscription),
-		}
+	//create token
+	token, err := app.CreateSessionToken(user, session)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, tokenCreateErr)
+		return
+	}
 
-		RespondWithJSON(w, 200, authLoginResponse)
+	//create tokens of a new session family on db
+	app.SaveSessionTokens(s, r, user.ID, token, session, "")
+	app.RecordSignin(s, r, user, session.Duress, app.AuditSuccess)
+
+	authLoginResponse := model.AuthLoginResponse{
+		AccessToken:         token.AccessToken,
+		RefreshToken:        token.RefreshToken,
+		TransmissionKey:     token.TransmissionKey,
+		UserDTO:             model.ToUserDTO(user),
+		SubscriptionAuthDTO: model.ToSubscriptionAuthDTO(subscription),
+		DeviceToken:         deviceToken,
 	}
+
+	RespondWithJSON(w, 200, authLoginResponse)
 }
 
 // RefreshToken ...
@@ -293,29 +528,60 @@ func RefreshToken(s storage.Store) http.HandlerFunc {
 		token, err := app.TokenValid(mapToken["refresh_token"])
 
 		if err != nil {
+			// An expired refresh token ends its session, the stored token tells it isn't forged
 			if token != nil {
-				claims := token.Claims.(jwt.MapClaims)
-				userid := claims["user_id"].(float64)
-				s.Tokens().Delete(int(userid))
+				claims, _ := token.Claims.(jwt.MapClaims)
+				uuid, _ := claims["uuid"].(string)
+				if tokenRow, ok := s.Tokens().Any(uuid); ok && tokenRow.Token == mapToken["refresh_token"] {
+					app.RevokeSession(s, &tokenRow)
+				}
 			}
 			RespondWithError(w, http.StatusUnauthorized, err.Error())
 			return
 		}
 
-		claims := token.Claims.(jwt.MapClaims)
-		uuid := claims["uuid"].(string)
+		// Tokens of machine accounts and tokens without a session aren't refreshed
+		claims, _ := token.Claims.(jwt.MapClaims)
+		uuid, ok := claims["uuid"].(string)
+		if !ok || app.IsMachineToken(claims) {
+			RespondWithError(w, http.StatusUnauthorized, invalidToken)
+			return
+		}
 
-		//Check from tokens db table
-		_, tokenExist := s.Tokens().Any(uuid)
+		//Check from tokens db table, revoked sessions aren't in it
+		tokenRow, tokenExist := s.Tokens().Any(uuid)
 		if !tokenExist {
-			userid := claims["user_id"].(float64)
-			s.Tokens().Delete(int(userid))
+			RespondWithError(w, http.StatusUnauthorized, invalidToken)
+			return
+		}
+
+		// Access tokens of a session family can't refresh it
+		if !tokenRow.Refresh && tokenRow.Family != "" {
 			RespondWithError(w, http.StatusUnauthorized, invalidToken)
 			return
 		}
 
 		// Get user info
-		userid := claims["user_id"].(float64)
+		userid, ok := claims["user_id"].(float64)
+		if !ok {
+			RespondWithError(w, http.StatusUnauthorized, invalidToken)
+			return
+		}
+
+		// Refreshing doesn't extend locked or expired sessions
+		session := app.SessionOf(claims)
+		if err := app.CheckSession(s, uint(userid), tokenRow.LastUsedAt, session.Start); err != nil {
+			app.RevokeSession(s, &tokenRow)
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if session.Start.IsZero() {
+			session.Start = time.Now()
+		}
+		if !checkAccess(s, w, r, uint(userid), session) {
+			return
+		}
+
 		user, err := s.Users().FindByID(uint(userid))
 		if err != nil {
 			RespondWithError(w, http.StatusUnauthorized, invalidUser)
@@ -323,18 +589,23 @@ func RefreshToken(s storage.Store) http.HandlerFunc {
 		}
 
 		//create token
-		newtoken, err := app.CreateToken(user)
+		newtoken, err := app.CreateSessionToken(user, session)
 		if err != nil {
 			RespondWithError(w, http.StatusInternalServerError, tokenCreateErr)
 			return
 		}
 
-		//delete tokens from db
-		s.Tokens().Delete(int(userid))
+		// Each refresh token is used once, the new tokens continue its family
+		if err := app.RotateRefreshToken(s, &tokenRow); err != nil {
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		app.SaveSessionTokens(s, r, user.ID, newtoken, session, tokenRow.Family)
 
-		//create tokens on db
-		s.Tokens().Save(int(userid), newtoken.AtUUID, newtoken.AccessToken, newtoken.AtExpiresTime, newtoken.TransmissionKey)
-		s.Tokens().Save(int(userid), newtoken.RtUUID, newtoken.RefreshToken, newtoken.RtExpiresTime, "")
+		// A refresh by itself isn't activity of the user
+		if !tokenRow.LastUsedAt.IsZero() {
+			app.TouchSessionAt(s, &tokenRow, tokenRow.LastUsedAt)
+		}
 
 		authLoginResponse := model.AuthLoginResponse{
 			AccessToken:     newtoken.AccessToken,
@@ -347,6 +618,31 @@ func RefreshToken(s storage.Store) http.HandlerFunc {
 	}
 }
 
+// RevokeToken ends the session of the access or refresh token in the body with all of its
+// refreshed tokens. Unknown tokens get the same response, so it tells nothing about them.
+func RevokeToken(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		mapToken := map[string]string{}
+
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&mapToken); err != nil {
+			errs := []string{"REVOKE_TOKEN_ERROR"}
+			RespondWithErrors(w, http.StatusUnprocessableEntity, InvalidJSON, errs)
+			return
+		}
+		defer r.Body.Close()
+
+		app.RevokeSessionToken(s, mapToken["token"])
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: tokenRevokeSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
 // CheckToken ...
 func CheckToken(s storage.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -368,8 +664,12 @@ func CheckToken(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		claims := token.Claims.(jwt.MapClaims)
-		userID := claims["user_id"].(float64)
+		claims, _ := token.Claims.(jwt.MapClaims)
+		userID, ok := claims["user_id"].(float64)
+		if !ok || app.IsMachineToken(claims) {
+			RespondWithError(w, http.StatusUnauthorized, invalidToken)
+			return
+		}
 
 		// Check if user exist in database and credentials are true
 		user, err := s.Users().FindByID(uint(userID))
@@ -383,3 +683,17 @@ func CheckToken(s storage.Store) http.HandlerFunc {
 		RespondWithJSON(w, http.StatusOK, response)
 	}
 }
+
+// checkAccess evaluates the conditional access rules of the user for a new token
+func checkAccess(s storage.Store, w http.ResponseWriter, r *http.Request, userID uint, session *app.Session) bool {
+	err := app.CheckAccess(s, userID, app.NewAccessRequest(r, session))
+	if denied, ok := err.(*app.AccessDeniedError); ok {
+		RespondWithErrors(w, http.StatusForbidden, denied.Reason, []string{denied.Code})
+		return false
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	return true
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// ListBackups returns the backup files of the vaults, the newest first
+func ListBackups(w http.ResponseWriter, r *http.Request) {
+	if !r.Context().Value("authorized").(bool) {
+		RespondWithError(w, http.StatusForbidden, adminOnly)
+		return
+	}
+
+	backups, err := app.ListBackups()
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, backups)
+}
+
+// CreateBackups backs up every vault now, without waiting for the backup schedule
+func CreateBackups(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		backups, err := app.BackupVaults(s, time.Now())
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		RespondWithJSON(w, http.StatusCreated, backups)
+	}
+}
+
+// DownloadBackup sends the encrypted backup file with the name after checking its checksum
+func DownloadBackup(w http.ResponseWriter, r *http.Request) {
+	if !r.Context().Value("authorized").(bool) {
+		RespondWithError(w, http.StatusForbidden, adminOnly)
+		return
+	}
+
+	name := mux.Vars(r)["name"]
+	data, err := app.ReadBackup(name)
+	if errors.Is(err, app.ErrBackupNotFound) {
+		RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"event":  "backup_download",
+		"backup": name,
+		"ip":     app.ClientIP(r).String(),
+	}).Info("backup file is downloaded")
+
+	sum := sha256.Sum256(data)
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
+	w.Header().Set("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum[:]))
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	w.Write(data)
+}
+
+// RestoreBackup restores a backup of the vault or an uploaded backup file into the vault
+// and answers the encrypted report. Wiping the vault needs a recent re-authentication.
+func RestoreBackup(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := ToPayload(r)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		// Decrypt payload
+		var dto model.RestoreDTO
+		key := r.Context().Value("transmissionKey").(string)
+		err = app.DecryptJSON(key, []byte(payload.Data), &dto)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		if dto.Mode == app.RestoreWipe && !dto.DryRun {
+			userID := uint(r.Context().Value("id").(float64))
+			err := app.VerifyReauth(r.Header.Get(app.ReauthHeader), userID, reauthSession(r), time.Now())
+			if err != nil {
+				RespondWithError(w, http.StatusForbidden, err.Error())
+				return
+			}
+		}
+
+		schema := r.Context().Value("schema").(string)
+		archive, err := app.OpenRestoreArchive(&dto, schema)
+		if errors.Is(err, app.ErrRestoreSource) || errors.Is(err, app.ErrRestoreFile) {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, app.ErrBackupNotFound) {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		report, err := app.RestoreBackup(s, archive, dto.Mode, dto.DryRun, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Restores are batches like imports, clients sync the changed types
+		if !report.DryRun {
+			for itemType := range report.Deleted.Items {
+				app.PublishChange(schema, model.ChangeDTO{Type: itemType, Operation: app.AuditDelete})
+			}
+			for itemType := range report.Restored.Items {
+				app.PublishChange(schema, model.ChangeDTO{Type: itemType, Operation: app.AuditCreate})
+			}
+		}
+
+		// Encrypt payload
+		encrypted, err := app.EncryptJSON(key, report)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
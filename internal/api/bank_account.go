@@ -22,8 +22,12 @@ func FindAllBankAccounts(s storage.Store) http.HandlerFunc {
 		var err error
 		var bankAccounts []model.BankAccount
 
-		fields := []string{"id", "created_at", "updated_at", "bank_name", "bank_code", "account_name", "account_number", "iban", "currency"}
-		argsStr, argsInt := SetArgs(r, fields)
+		fields := []string{"id", "created_at", "updated_at", "bank_name", "bank_code", "account_name", "account_number", "iban", "currency", "sort_order"}
+		filters := map[string]string{"title": "bank_name", "bank_code": "bank_code"}
+		argsStr, argsInt, ok := SetListArgs(w, r, fields, filters)
+		if !ok {
+			return
+		}
 
 		schema := r.Context().Value("schema").(string)
 		bankAccounts, err = s.BankAccounts().FindAll(argsStr, argsInt, schema)
@@ -31,15 +35,13 @@ func FindAllBankAccounts(s storage.Store) http.HandlerFunc {
 			RespondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
+		next := nextCursor(&bankAccounts, argsStr, argsInt)
 
-		// Decrypt server side encrypted fields
-		for i := range bankAccounts {
-			decBankAccount, err := app.DecryptModel(&bankAccounts[i])
-			if err != nil {
-				RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-			bankAccounts[i] = *decBankAccount.(*model.BankAccount)
+		tripCanaries(s, r, bankAccounts, app.CanaryRead)
+
+		if err := app.LoadItemTags(s, bankAccounts, schema); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
 		}
 
 		// Encrypt payload
@@ -51,6 +53,7 @@ func FindAllBankAccounts(s storage.Store) http.HandlerFunc {
 			return
 		}
 		payload.Data = string(encrypted)
+		payload.NextCursor = next
 
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
@@ -73,14 +76,14 @@ func FindBankAccountByID(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		// Decrypt server side encrypted fields
-		decBankAccount, err := app.DecryptModel(bankAccount)
-		if err != nil {
+		tripCanaries(s, r, bankAccount, app.CanaryRead)
+
+		if err := app.LoadItemTags(s, bankAccount, schema); err != nil {
 			RespondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		bankAccountDTO := model.ToBankAccountDTO(decBankAccount.(*model.BankAccount))
+		bankAccountDTO := model.ToBankAccountDTO(bankAccount)
 
 		// Encrypt payload
 		var payload model.Payload
@@ -92,6 +95,7 @@ func FindBankAccountByID(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, bankAccount)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -122,6 +126,7 @@ func CreateBankAccount(s storage.Store) http.HandlerFunc {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		app.AuditItem(r, createdBankAccount)
 
 		createdBankAccountDTO := model.ToBankAccountDTO(createdBankAccount)
 
@@ -172,12 +177,21 @@ func UpdateBankAccount(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		updatedBankAccount, err := app.UpdateBankAccount(s, bankAccount, &bankAccountDTO, schema)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+		tripCanaries(s, r, bankAccount, app.CanaryUpdate)
+
+		if !matchETag(w, r, bankAccount) {
+			return
+		}
+		if !matchRevision(s, w, r, bankAccount, &bankAccountDTO) {
 			return
 		}
 
+		updated, ok := updateItem(s, w, r, bankAccount, &bankAccountDTO)
+		if !ok {
+			return
+		}
+		updatedBankAccount := updated.(*model.BankAccount)
+
 		updatedBankAccountDTO := model.ToBankAccountDTO(updatedBankAccount)
 
 		// Encrypt payload
@@ -188,6 +202,7 @@ func UpdateBankAccount(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, updatedBankAccount)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -209,6 +224,8 @@ func DeleteBankAccount(s storage.Store) http.HandlerFunc {
 			return
 		}
 
+		tripCanaries(s, r, bankAccount, app.CanaryDelete)
+
 		err = s.BankAccounts().Delete(bankAccount.ID, schema)
 		if err != nil {
 			RespondWithError(w, http.StatusNotFound, err.Error())
@@ -0,0 +1,181 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/model"
+	"golang.org/x/net/websocket"
+)
+
+const (
+	streamUnsupported = "Streaming is not supported by the connection"
+
+	changePingInterval = 30 * time.Second
+	changeWriteTimeout = 10 * time.Second
+	changeRetry        = 5 * time.Second // EventSource reconnects after it
+)
+
+// WatchChanges pushes the changes of the items of the vault over a WebSocket connection
+// until the client closes it or its token expires. Each message is a payload of an
+// encrypted model.ChangeDTO.
+func WatchChanges(w http.ResponseWriter, r *http.Request) {
+	schema := r.Context().Value("schema").(string)
+	key := r.Context().Value("transmissionKey").(string)
+	expiresAt, _ := r.Context().Value("expiresAt").(time.Time)
+
+	// Changes during the handshake are pushed too
+	changes, unsubscribe := app.SubscribeChanges(schema)
+	defer unsubscribe()
+
+	server := websocket.Server{
+		// The bearer token authorizes the connection, not cookies, so any origin may connect
+		Handshake: func(*websocket.Config, *http.Request) error { return nil },
+		Handler: func(ws *websocket.Conn) {
+			pushChanges(&webSocket{ws}, changes, key, expiresAt)
+		},
+	}
+	server.ServeHTTP(w, r)
+}
+
+// StreamChanges pushes the changes like WatchChanges as Server-Sent Events, for clients
+// behind proxies which don't pass WebSockets. The data of each change event is a payload
+// of an encrypted model.ChangeDTO.
+func StreamChanges(w http.ResponseWriter, r *http.Request) {
+	schema := r.Context().Value("schema").(string)
+	key := r.Context().Value("transmissionKey").(string)
+	expiresAt, _ := r.Context().Value("expiresAt").(time.Time)
+
+	// The stream outlives the write timeout of the server, so it takes over the connection
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		RespondWithError(w, http.StatusInternalServerError, streamUnsupported)
+		return
+	}
+
+	changes, unsubscribe := app.SubscribeChanges(schema)
+	defer unsubscribe()
+
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, streamUnsupported)
+		return
+	}
+
+	// Proxies like nginx buffer responses unless they're told not to
+	header := w.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "close")
+	header.Set("X-Accel-Buffering", "no")
+	rw.WriteString("HTTP/1.1 200 OK\r\n")
+	header.Write(rw)
+	rw.WriteString("\r\n")
+	fmt.Fprintf(rw, "retry: %d\n\n", changeRetry/time.Millisecond)
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return
+	}
+
+	pushChanges(&eventStream{Conn: conn, w: rw.Writer}, changes, key, expiresAt)
+}
+
+// changeConn is a connection the changes are pushed to
+type changeConn interface {
+	io.ReadCloser
+	SetDeadline(t time.Time) error
+	SetWriteDeadline(t time.Time) error
+	ping() error
+	send(payload model.Payload) error
+}
+
+// webSocket pushes the changes as text frames
+type webSocket struct {
+	*websocket.Conn
+}
+
+func (ws *webSocket) ping() error {
+	ws.PayloadType = websocket.PingFrame
+	_, err := ws.Write(nil)
+	return err
+}
+
+func (ws *webSocket) send(payload model.Payload) error {
+	return websocket.JSON.Send(ws.Conn, payload)
+}
+
+// eventStream pushes the changes as change events, pings are comments
+type eventStream struct {
+	net.Conn
+	w *bufio.Writer
+}
+
+func (es *eventStream) ping() error {
+	es.w.WriteString(": ping\n\n")
+	return es.w.Flush()
+}
+
+func (es *eventStream) send(payload model.Payload) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(es.w, "event: change\ndata: %s\n\n", data)
+	return es.w.Flush()
+}
+
+// pushChanges writes the changes to the connection until it ends
+func pushChanges(conn changeConn, changes <-chan model.ChangeDTO, key string, expiresAt time.Time) {
+	defer conn.Close()
+
+	// The deadlines of the server timeouts stay on the hijacked connection. Reads answer
+	// the pings of the client and notice its close, its messages are ignored.
+	conn.SetDeadline(time.Time{})
+	closed := make(chan struct{})
+	go func() {
+		io.Copy(ioutil.Discard, conn)
+		close(closed)
+	}()
+
+	var expired <-chan time.Time
+	if !expiresAt.IsZero() {
+		timer := time.NewTimer(time.Until(expiresAt))
+		defer timer.Stop()
+		expired = timer.C
+	}
+	ping := time.NewTicker(changePingInterval)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-closed:
+			return
+		case <-expired:
+			return
+		case <-ping.C:
+			conn.SetWriteDeadline(time.Now().Add(changeWriteTimeout))
+			if err := conn.ping(); err != nil {
+				return
+			}
+		case change, ok := <-changes:
+			if !ok {
+				return
+			}
+			encrypted, err := app.EncryptJSON(key, change)
+			if err != nil {
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(changeWriteTimeout))
+			if err := conn.send(model.Payload{Data: string(encrypted)}); err != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,27 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/model"
+)
+
+// ReloadConfig applies the log level, rate limit, email, alert and CORS settings of the
+// configuration file without a restart
+func ReloadConfig(w http.ResponseWriter, r *http.Request) {
+	if !r.Context().Value("authorized").(bool) {
+		RespondWithError(w, http.StatusForbidden, adminOnly)
+		return
+	}
+
+	changed, err := app.ReloadConfig()
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	if changed == nil {
+		changed = []string{}
+	}
+	RespondWithJSON(w, http.StatusOK, model.ConfigReloadDTO{Changed: changed})
+}
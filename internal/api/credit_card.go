@@ -27,8 +27,12 @@ func FindAllCreditCards(s storage.Store) http.HandlerFunc {
 		var err error
 		var creditCards []model.CreditCard
 
-		fields := []string{"id", "created_at", "updated_at", "bank_name", "bank_code", "account_name", "account_number", "iban", "currency"}
-		argsStr, argsInt := SetArgs(r, fields)
+		fields := []string{"id", "created_at", "updated_at", "card_name", "sort_order"}
+		filters := map[string]string{"title": "card_name"}
+		argsStr, argsInt, ok := SetListArgs(w, r, fields, filters)
+		if !ok {
+			return
+		}
 
 		schema := r.Context().Value("schema").(string)
 		creditCards, err = s.CreditCards().FindAll(argsStr, argsInt, schema)
@@ -36,15 +40,13 @@ func FindAllCreditCards(s storage.Store) http.HandlerFunc {
 			RespondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
+		next := nextCursor(&creditCards, argsStr, argsInt)
+
+		tripCanaries(s, r, creditCards, app.CanaryRead)
 
-		// Decrypt server side encrypted fields
-		for i := range creditCards {
-			decCreditCard, err := app.DecryptModel(&creditCards[i])
-			if err != nil {
-				RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-			creditCards[i] = *decCreditCard.(*model.CreditCard)
+		if err := app.LoadItemTags(s, creditCards, schema); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
 		}
 
 		// Encrypt payload
@@ -56,6 +58,7 @@ func FindAllCreditCards(s storage.Store) http.HandlerFunc {
 			return
 		}
 		payload.Data = string(encrypted)
+		payload.NextCursor = next
 
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
@@ -78,14 +81,14 @@ func FindCreditCardByID(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		// Decrypt server side encrypted fields
-		decCreditCard, err := app.DecryptModel(creditCard)
-		if err != nil {
+		tripCanaries(s, r, creditCard, app.CanaryRead)
+
+		if err := app.LoadItemTags(s, creditCard, schema); err != nil {
 			RespondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		creditCardDTO := model.ToCreditCardDTO(decCreditCard.(*model.CreditCard))
+		creditCardDTO := model.ToCreditCardDTO(creditCard)
 
 		// Encrypt payload
 		var payload model.Payload
@@ -97,6 +100,7 @@ func FindCreditCardByID(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, creditCard)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -121,12 +125,19 @@ func CreateCreditCard(s storage.Store) http.HandlerFunc {
 			return
 		}
 
+		// Validate card number and expiry date
+		if errs := app.ValidateCreditCard(&creditCardDTO); len(errs) > 0 {
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
 		schema := r.Context().Value("schema").(string)
 		createdCreditCard, err := app.CreateCreditCard(s, &creditCardDTO, schema)
 		if err != nil {
 			RespondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		app.AuditItem(r, createdCreditCard)
 
 		createdCreditCardDTO := model.ToCreditCardDTO(createdCreditCard)
 
@@ -170,6 +181,12 @@ func UpdateCreditCard(s storage.Store) http.HandlerFunc {
 			return
 		}
 
+		// Validate card number and expiry date
+		if errs := app.ValidateCreditCard(&creditCardDTO); len(errs) > 0 {
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
 		schema := r.Context().Value("schema").(string)
 		creditCard, err := s.CreditCards().FindByID(uint(id), schema)
 		if err != nil {
@@ -177,12 +194,21 @@ func UpdateCreditCard(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		updatedCreditCard, err := app.UpdateCreditCard(s, creditCard, &creditCardDTO, schema)
-		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+		tripCanaries(s, r, creditCard, app.CanaryUpdate)
+
+		if !matchETag(w, r, creditCard) {
+			return
+		}
+		if !matchRevision(s, w, r, creditCard, &creditCardDTO) {
 			return
 		}
 
+		updated, ok := updateItem(s, w, r, creditCard, &creditCardDTO)
+		if !ok {
+			return
+		}
+		updatedCreditCard := updated.(*model.CreditCard)
+
 		updatedCreditCardDTO := model.ToCreditCardDTO(updatedCreditCard)
 
 		// Encrypt payload
@@ -193,6 +219,7 @@ func UpdateCreditCard(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, updatedCreditCard)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -214,6 +241,8 @@ func DeleteCreditCard(s storage.Store) http.HandlerFunc {
 			return
 		}
 
+		tripCanaries(s, r, creditCard, app.CanaryDelete)
+
 		err = s.CreditCards().Delete(creditCard.ID, schema)
 		if err != nil {
 			RespondWithError(w, http.StatusNotFound, err.Error())
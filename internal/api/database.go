@@ -0,0 +1,21 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+// DatabasePools returns the utilization of the connection pools of the primary and the
+// replicas, a wait_count that keeps growing calls for a larger database.maxOpenConns
+func DatabasePools(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, model.ToDatabasePoolDTOs(s.PoolStats()))
+	}
+}
@@ -0,0 +1,122 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const duressRemoveSuccess = "Duress password removed successfully!"
+
+// FindDuress tells if the user has a duress password, duress sessions never see one
+func FindDuress(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.Users().FindByID(uint(r.Context().Value("id").(float64)))
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		response := model.DuressStatusDTO{
+			Enabled: user.DuressPassword != "" && !isDuress(r),
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// SetDuress sets the duress password which opens the decoy vault
+func SetDuress(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto := new(model.DuressDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		user, ok := findDuressUser(s, w, r)
+		if !ok {
+			return
+		}
+
+		_, err := app.SetDuressPassword(s, user, dto)
+		if errors.Is(err, app.ErrMasterPassword) {
+			RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, model.DuressStatusDTO{Enabled: true})
+	}
+}
+
+// RemoveDuress removes the duress password and deletes the decoy vault
+func RemoveDuress(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto := new(model.DuressDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		user, ok := findDuressUser(s, w, r)
+		if !ok {
+			return
+		}
+
+		_, err := app.RemoveDuressPassword(s, user, dto.MasterPassword)
+		if errors.Is(err, app.ErrMasterPassword) {
+			RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: duressRemoveSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// findDuressUser finds the user of the request. Duress sessions get the same
+// error as a wrong master password, so the decoy vault can't be told apart.
+func findDuressUser(s storage.Store, w http.ResponseWriter, r *http.Request) (*model.User, bool) {
+	if isDuress(r) {
+		RespondWithError(w, http.StatusForbidden, app.ErrMasterPassword.Error())
+		return nil, false
+	}
+
+	user, err := s.Users().FindByID(uint(r.Context().Value("id").(float64)))
+	if err != nil {
+		RespondWithError(w, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+	return user, true
+}
+
+// isDuress reports whether the request comes from a session of the duress password
+func isDuress(r *http.Request) bool {
+	duress, _ := r.Context().Value("duress").(bool)
+	return duress
+}
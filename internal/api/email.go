@@ -18,8 +18,12 @@ func FindAllEmails(s storage.Store) http.HandlerFunc {
 		var err error
 		emails := []model.Email{}
 
-		fields := []string{"id", "created_at", "updated_at", "email"}
-		argsStr, argsInt := SetArgs(r, fields)
+		fields := []string{"id", "created_at", "updated_at", "email", "sort_order"}
+		filters := map[string]string{"title": "title"}
+		argsStr, argsInt, ok := SetListArgs(w, r, fields, filters)
+		if !ok {
+			return
+		}
 
 		schema := r.Context().Value("schema").(string)
 		emails, err = s.Emails().FindAll(argsStr, argsInt, schema)
@@ -27,15 +31,13 @@ func FindAllEmails(s storage.Store) http.HandlerFunc {
 			RespondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
+		next := nextCursor(&emails, argsStr, argsInt)
 
-		// Decrypt server side encrypted fields
-		for i := range emails {
-			decEmail, err := app.DecryptModel(&emails[i])
-			if err != nil {
-				RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-			emails[i] = *decEmail.(*model.Email)
+		tripCanaries(s, r, emails, app.CanaryRead)
+
+		if err := app.LoadItemTags(s, emails, schema); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
 		}
 
 		// Encrypt payload
@@ -47,6 +49,7 @@ func FindAllEmails(s storage.Store) http.HandlerFunc {
 			return
 		}
 		payload.Data = string(encrypted)
+		payload.NextCursor = next
 
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
@@ -69,14 +72,14 @@ func FindEmailByID(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		// Decrypt server side encrypted fields
-		decEmail, err := app.DecryptModel(email)
-		if err != nil {
+		tripCanaries(s, r, email, app.CanaryRead)
+
+		if err := app.LoadItemTags(s, email, schema); err != nil {
 			RespondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		emailDTO := model.ToEmailDTO(decEmail.(*model.Email))
+		emailDTO := model.ToEmailDTO(email)
 
 		// Encrypt payload
 		var payload model.Payload
@@ -88,6 +91,7 @@ func FindEmailByID(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, email)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -118,6 +122,7 @@ func CreateEmail(s storage.Store) http.HandlerFunc {
 			w.WriteHeader(http.StatusInternalServerError)
 			return
 		}
+		app.AuditItem(r, createdEmail)
 
 		createdEmailDTO := model.ToEmailDTO(createdEmail)
 
@@ -168,12 +173,21 @@ func UpdateEmail(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		updatedEmail, err := app.UpdateEmail(s, email, &emailDTO, schema)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+		tripCanaries(s, r, email, app.CanaryUpdate)
+
+		if !matchETag(w, r, email) {
+			return
+		}
+		if !matchRevision(s, w, r, email, &emailDTO) {
 			return
 		}
 
+		updated, ok := updateItem(s, w, r, email, &emailDTO)
+		if !ok {
+			return
+		}
+		updatedEmail := updated.(*model.Email)
+
 		updatedEmailDTO := model.ToEmailDTO(updatedEmail)
 
 		// Encrypt payload
@@ -184,6 +198,7 @@ func UpdateEmail(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, updatedEmail)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -205,6 +220,8 @@ func DeleteEmail(s storage.Store) http.HandlerFunc {
 			return
 		}
 
+		tripCanaries(s, r, email, app.CanaryDelete)
+
 		err = s.Emails().Delete(email.ID, schema)
 		if err != nil {
 			RespondWithError(w, http.StatusNotFound, err.Error())
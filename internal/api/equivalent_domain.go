@@ -0,0 +1,173 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	equivalentDomainDeleteSuccess = "Equivalent domains deleted successfully!"
+	adminOnly                     = "Only admins can do this operation"
+)
+
+// FindAllEquivalentDomains lists built-in, server wide and user equivalent domains
+func FindAllEquivalentDomains(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serverDomains, err := s.EquivalentDomains().All("public")
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.EquivalentDomainsDTO{
+			Defaults: app.DefaultEquivalentDomains,
+			Server:   model.ToEquivalentDomainDTOs(serverDomains),
+			User:     []*model.EquivalentDomainDTO{},
+		}
+
+		// Server wide endpoint lists only the server wide sets
+		schema, _ := equivalentDomainSchema(r)
+		if schema != "public" {
+			userDomains, err := s.EquivalentDomains().All(schema)
+			if err != nil {
+				RespondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+			response.User = model.ToEquivalentDomainDTOs(userDomains)
+		}
+
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// CreateEquivalentDomain creates an equivalent domain set
+func CreateEquivalentDomain(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schema, allowed := equivalentDomainSchema(r)
+		if !allowed {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		dto, ok := decodeEquivalentDomain(w, r)
+		if !ok {
+			return
+		}
+
+		created, err := app.SaveEquivalentDomain(s, &model.EquivalentDomain{}, dto, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, model.ToEquivalentDomainDTO(created))
+	}
+}
+
+// UpdateEquivalentDomain updates an equivalent domain set
+func UpdateEquivalentDomain(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		schema, allowed := equivalentDomainSchema(r)
+		if !allowed {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		dto, ok := decodeEquivalentDomain(w, r)
+		if !ok {
+			return
+		}
+
+		equivalentDomain, err := s.EquivalentDomains().FindByID(uint(id), schema)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		updated, err := app.SaveEquivalentDomain(s, equivalentDomain, dto, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, model.ToEquivalentDomainDTO(updated))
+	}
+}
+
+// DeleteEquivalentDomain deletes an equivalent domain set
+func DeleteEquivalentDomain(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		schema, allowed := equivalentDomainSchema(r)
+		if !allowed {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		equivalentDomain, err := s.EquivalentDomains().FindByID(uint(id), schema)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		err = s.EquivalentDomains().Delete(equivalentDomain.ID, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: equivalentDomainDeleteSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// equivalentDomainSchema returns the schema of the equivalent domain endpoint.
+// Server wide sets are stored in the public schema and only admins can change them.
+func equivalentDomainSchema(r *http.Request) (string, bool) {
+	if strings.HasPrefix(r.URL.Path, "/api/system/") {
+		return "public", r.Context().Value("authorized").(bool)
+	}
+	return r.Context().Value("schema").(string), true
+}
+
+func decodeEquivalentDomain(w http.ResponseWriter, r *http.Request) (*model.EquivalentDomainDTO, bool) {
+	dto := new(model.EquivalentDomainDTO)
+	decoder := json.NewDecoder(r.Body)
+	if err := decoder.Decode(&dto); err != nil {
+		RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		errs := GetErrors(w, err.(validator.ValidationErrors))
+		RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+		return nil, false
+	}
+
+	return dto, true
+}
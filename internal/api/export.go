@@ -0,0 +1,148 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/blob"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const exportNotFound = "Export not found"
+
+// CreateExport queues an export of the vault and returns the job to poll
+func CreateExport(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto := new(model.ExportRequestDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		userID := uint(r.Context().Value("id").(float64))
+		schema := r.Context().Value("schema").(string)
+		job, err := app.CreateExportJob(s, userID, schema, dto)
+		if errors.Is(err, app.ErrExportQueueFull) {
+			RespondWithError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusAccepted, model.ToExportJobDTO(job))
+	}
+}
+
+// FindExport returns the progress of an export job of the user,
+// with a signed download link when the archive is ready
+func FindExport(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := s.ExportJobs().FindByUUID(mux.Vars(r)["id"])
+		userID := uint(r.Context().Value("id").(float64))
+		if err != nil || job.UserID != userID {
+			RespondWithError(w, http.StatusNotFound, exportNotFound)
+			return
+		}
+
+		dto := model.ToExportJobDTO(job)
+		if job.Status == model.ExportDone {
+			dto.DownloadURL = app.ExportDownloadURL(job, time.Now())
+		}
+		RespondWithJSON(w, http.StatusOK, dto)
+	}
+}
+
+// DownloadExport streams the archive of a signed download link, it needs no session
+func DownloadExport(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		now := time.Now()
+		if err := app.VerifyExportDownload(id, r.FormValue("expires"), r.FormValue("signature"), now); err != nil {
+			RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+
+		job, err := s.ExportJobs().FindByUUID(id)
+		if err != nil || job.Status != model.ExportDone || job.ExpiresAt == nil || now.After(*job.ExpiresAt) {
+			RespondWithError(w, http.StatusNotFound, exportNotFound)
+			return
+		}
+
+		blobs, err := blob.FromConfig()
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		archive, err := blobs.Get(job.BlobKey)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, exportNotFound)
+			return
+		}
+		defer archive.Close()
+
+		log.WithFields(log.Fields{
+			"event":   "export_download",
+			"user_id": job.UserID,
+			"job":     id,
+			"ip":      app.ClientIP(r).String(),
+		}).Info("export archive is downloaded")
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="passwall-export-%s.pwx"`, job.CreatedAt.Format("2006-01-02")))
+		w.WriteHeader(http.StatusOK)
+		io.Copy(w, archive)
+	}
+}
+
+// ExportItems streams the decrypted items of the type as CSV. The items leave the vault
+// in plaintext, so the request needs a recent re-authentication.
+func ExportItems(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := uint(r.Context().Value("id").(float64))
+		err := app.VerifyReauth(r.Header.Get(app.ReauthHeader), userID, reauthSession(r), time.Now())
+		if err != nil {
+			RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+
+		format := r.FormValue("format")
+		if format == "" {
+			format = app.ItemExportCSV
+		}
+		if err := app.CheckItemExportFormat(format); err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		itemType := mux.Vars(r)["type"]
+		w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.csv"`, itemType, time.Now().Format("20060102")))
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+
+		// The status is sent, a failure can only cut the stream short
+		schema := r.Context().Value("schema").(string)
+		if err := app.ExportItemsCSV(s, w, itemType, userID, schema); err != nil {
+			log.WithError(err).Error("item export failed")
+		}
+	}
+}
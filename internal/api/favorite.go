@@ -0,0 +1,47 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+// FindFavorites finds the favorite items of all types
+func FindFavorites(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schema := r.Context().Value("schema").(string)
+		items, err := app.Favorites(s, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Encrypt payload
+		var payload model.Payload
+		key := r.Context().Value("transmissionKey").(string)
+		encrypted, err := app.EncryptJSON(key, toTypedItems(s, r, items))
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
+
+// toTypedItems converts the item pointers of all types to DTOs with their type, the
+// canaries among them are tripped as read
+func toTypedItems(s storage.Store, r *http.Request, items []interface{}) []model.TypedItemDTO {
+	typed := make([]model.TypedItemDTO, len(items))
+	for i, item := range items {
+		tripCanaries(s, r, item, app.CanaryRead)
+		typed[i] = model.TypedItemDTO{
+			Type: app.ItemTypeOf(item),
+			Item: app.ToItemDTO(item),
+		}
+	}
+	return typed
+}
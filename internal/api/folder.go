@@ -0,0 +1,215 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	folderDeleteSuccess = "Folder deleted successfully!"
+	itemMoveSuccess     = "Items moved successfully!"
+)
+
+// FindAllFolders finds all folders
+func FindAllFolders(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schema := r.Context().Value("schema").(string)
+		folders, err := s.Folders().All(schema)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		respondFolder(w, r, model.ToFolderDTOs(folders))
+	}
+}
+
+// FindFolderByID finds a folder by id
+func FindFolderByID(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		folder, ok := findFolder(s, w, r)
+		if !ok {
+			return
+		}
+
+		respondFolder(w, r, model.ToFolderDTO(folder))
+	}
+}
+
+// CreateFolder creates a folder
+func CreateFolder(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto, ok := decryptFolder(w, r)
+		if !ok {
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		folder, err := app.CreateFolder(s, dto, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		respondFolder(w, r, model.ToFolderDTO(folder))
+	}
+}
+
+// UpdateFolder renames a folder
+func UpdateFolder(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		folder, ok := findFolder(s, w, r)
+		if !ok {
+			return
+		}
+		dto, ok := decryptFolder(w, r)
+		if !ok {
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		folder, err := app.UpdateFolder(s, folder, dto, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		respondFolder(w, r, model.ToFolderDTO(folder))
+	}
+}
+
+// DeleteFolder deletes a folder, its items are kept without a folder
+func DeleteFolder(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		folder, ok := findFolder(s, w, r)
+		if !ok {
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		if err := app.DeleteFolder(s, folder, schema); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: folderDeleteSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// MoveItems moves items of any type to a folder at once
+func MoveItems(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := ToPayload(r)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		// Decrypt payload
+		dto := new(model.ItemMoveDTO)
+		key := r.Context().Value("transmissionKey").(string)
+		if err := app.DecryptJSON(key, []byte(payload.Data), dto); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		items, err := app.MoveItems(s, dto, schema)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		tripCanaries(s, r, items, app.CanaryUpdate)
+		app.PublishItemChanges(schema, items, app.AuditUpdate)
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: itemMoveSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// findFolder finds the folder of the id in the path, it responds when it isn't found
+func findFolder(s storage.Store, w http.ResponseWriter, r *http.Request) (*model.Folder, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	schema := r.Context().Value("schema").(string)
+	folder, err := s.Folders().FindByID(uint(id), schema)
+	if err != nil {
+		RespondWithError(w, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+	return folder, true
+}
+
+// decryptFolder decrypts and validates the folder of the payload, it responds when it's invalid
+func decryptFolder(w http.ResponseWriter, r *http.Request) (*model.FolderDTO, bool) {
+	payload, err := ToPayload(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	// Decrypt payload
+	dto := new(model.FolderDTO)
+	key := r.Context().Value("transmissionKey").(string)
+	if err := app.DecryptJSON(key, []byte(payload.Data), dto); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		errs := GetErrors(w, err.(validator.ValidationErrors))
+		RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+		return nil, false
+	}
+	return dto, true
+}
+
+func respondFolder(w http.ResponseWriter, r *http.Request, v interface{}) {
+	// Encrypt payload
+	var payload model.Payload
+	key := r.Context().Value("transmissionKey").(string)
+	encrypted, err := app.EncryptJSON(key, v)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	payload.Data = string(encrypted)
+
+	RespondWithJSON(w, http.StatusOK, payload)
+}
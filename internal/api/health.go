@@ -3,6 +3,7 @@ package api
 import (
 	"net/http"
 
+	"github.com/passwall/passwall-server/internal/app"
 	"github.com/passwall/passwall-server/internal/storage"
 )
 
@@ -70,3 +71,20 @@ func getStatus(state int, err error) *HealthProp {
 		Err:        err,
 	}
 }
+
+// Liveness answers as long as the server serves requests, e.g. for the liveness probe of Kubernetes
+func Liveness(w http.ResponseWriter, r *http.Request) {
+	RespondWithJSON(w, http.StatusOK, map[string]string{"status": app.HealthOK})
+}
+
+// Readiness returns the status of each dependency, with 503 Service Unavailable if one fails
+func Readiness(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		readiness := app.CheckReadiness(s)
+		code := http.StatusOK
+		if readiness.Status != app.HealthOK {
+			code = http.StatusServiceUnavailable
+		}
+		RespondWithJSON(w, code, readiness)
+	}
+}
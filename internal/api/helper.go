@@ -1,16 +1,39 @@
 package api
 
 import (
+	"encoding/base64"
 	"encoding/json"
 	"github.com/passwall/passwall-server/model"
 	"net/http"
+	"reflect"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/storage/filter"
 )
 
+const (
+	// defaultPageSize is the limit of the pages of lists paged by cursors without a Limit
+	defaultPageSize = 100
+
+	invalidCursor   = "Invalid cursor"
+	invalidListTime = "Times should be RFC 3339 times or dates like 2020-05-01"
+	itemChanged     = "Item was changed since it was read"
+	itemConflict    = "Item was changed since the revision it was edited at"
+)
+
+// listTimes are the params of the time filters of the item lists and their arguments
+var listTimes = map[string]string{
+	"CreatedAfter":  "created_after",
+	"CreatedBefore": "created_before",
+	"UpdatedAfter":  "updated_after",
+	"UpdatedBefore": "updated_before",
+}
+
 // SetArgs ...
 func SetArgs(r *http.Request, fields []string) (map[string]string, map[string]int) {
 
@@ -18,9 +41,15 @@ func SetArgs(r *http.Request, fields []string) (map[string]string, map[string]in
 	search := r.FormValue("Search")
 	sort := r.FormValue("Sort")
 	order := r.FormValue("Order")
+	folderID := r.FormValue("FolderID")
+	tags := r.FormValue("Tags")
+	isFavorite := r.FormValue("IsFavorite")
 	argsStr := map[string]string{
-		"search": search,
-		"order":  setOrder(fields, sort, order),
+		"search":      search,
+		"order":       setOrder(fields, sort, order),
+		"folder_id":   setFolderID(folderID),
+		"tags":        setTags(tags),
+		"is_favorite": setIsFavorite(isFavorite),
 	}
 
 	// Integer type query params
@@ -34,6 +63,130 @@ func SetArgs(r *http.Request, fields []string) (map[string]string, map[string]in
 	return argsStr, argsInt
 }
 
+// SetListArgs is SetArgs for the item lists. CreatedAfter, CreatedBefore, UpdatedAfter and
+// UpdatedBefore filter them by RFC 3339 times or dates, which cover the whole day, and the
+// params of the filters, like url, by the equality of their column. They can be paged by a
+// Cursor instead of Offset. The first page has an empty cursor, the payload of each page has
+// the cursor of the next one. It responds when a time or the cursor is invalid.
+func SetListArgs(w http.ResponseWriter, r *http.Request, fields []string, filters map[string]string) (map[string]string, map[string]int, bool) {
+	argsStr, argsInt := SetArgs(r, fields)
+
+	for param, arg := range listTimes {
+		t, err := parseListTime(r.FormValue(param), strings.HasSuffix(param, "Before"))
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, invalidListTime)
+			return nil, nil, false
+		}
+		if !t.IsZero() {
+			argsStr[arg] = t.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	for param, column := range filters {
+		if values, ok := r.Form[param]; ok {
+			argsStr[filter.FieldPrefix+column] = values[0]
+		}
+	}
+
+	if _, ok := r.Form["Cursor"]; !ok {
+		return argsStr, argsInt, true
+	}
+
+	id, err := decodeCursor(r.FormValue("Cursor"))
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, invalidCursor)
+		return nil, nil, false
+	}
+	argsStr["cursor"] = strconv.FormatUint(id, 10)
+	if argsInt["limit"] < 1 {
+		argsInt["limit"] = defaultPageSize
+	}
+	// The item after the page tells whether there is a next one
+	argsInt["limit"]++
+	argsInt["offset"] = -1
+	return argsStr, argsInt, true
+}
+
+// parseListTime parses an RFC 3339 time or a date, the end of the day for dates of before
+// filters. Empty values are the zero time.
+func parseListTime(value string, before bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if before {
+		t = t.Add(24 * time.Hour)
+	}
+	return t, nil
+}
+
+// nextCursor drops the item after the page from the list a list of SetListArgs points to
+// and returns the cursor of the next page, empty on the last page
+func nextCursor(list interface{}, argsStr map[string]string, argsInt map[string]int) string {
+	v := reflect.ValueOf(list).Elem()
+	if argsStr["cursor"] == "" || v.Len() < argsInt["limit"] {
+		return ""
+	}
+	v.Set(v.Slice(0, argsInt["limit"]-1))
+	return encodeCursor(v.Index(v.Len() - 1).FieldByName("ID").Uint())
+}
+
+// encodeCursor returns the cursor after the item of the id
+func encodeCursor(id uint64) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatUint(id, 10)))
+}
+
+// decodeCursor returns the id of the item of the cursor, 0 for the empty cursor of the
+// first page
+func decodeCursor(cursor string) (uint64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	id, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(string(id), 10, 32)
+}
+
+// setIsFavorite returns "true" when only the favorite items are listed
+func setIsFavorite(isFavorite string) string {
+	if favorite, err := strconv.ParseBool(isFavorite); err == nil && favorite {
+		return "true"
+	}
+	return ""
+}
+
+// setFolderID returns the folder the items are filtered by, 0 are the items without a
+// folder and empty doesn't filter
+func setFolderID(folderID string) string {
+	id, err := strconv.ParseUint(folderID, 10, 32)
+	if err != nil {
+		return ""
+	}
+	return strconv.FormatUint(id, 10)
+}
+
+// setTags returns the distinct tag ids of a comma separated list, invalid ids are left out
+func setTags(tags string) string {
+	ids := []string{}
+	seen := map[uint64]bool{}
+	for _, tag := range strings.Split(tags, ",") {
+		id, err := strconv.ParseUint(strings.TrimSpace(tag), 10, 32)
+		if err != nil || seen[id] {
+			continue
+		}
+		seen[id] = true
+		ids = append(ids, strconv.FormatUint(id, 10))
+	}
+	return strings.Join(ids, ",")
+}
+
 // Offset returns the starting number of result for pagination
 func setOffset(offset string) int {
 	offsetInt, err := strconv.Atoi(offset)
@@ -64,10 +217,31 @@ func setLimit(limit string) int {
 	return limitInt
 }
 
-// SortOrder returns the string for sorting and ordering data
+// SortOrder returns the string for sorting and ordering data. Sort is a field and Order its
+// direction, or a list of fields and directions like updated_at:desc,title:asc where the
+// direction is asc without one. Fields which aren't in fields fall back to updated_at desc.
 func setOrder(fields []string, sort, order string) string {
 	orderValues := []string{"desc", "asc"}
 
+	if strings.ContainsAny(sort, ":,") {
+		orders := []string{}
+		seen := map[string]bool{}
+		for _, part := range strings.Split(sort, ",") {
+			field, direction := part, "asc"
+			if i := strings.Index(part, ":"); i >= 0 {
+				field, direction = part[:i], part[i+1:]
+			}
+			field = ToSnakeCase(strings.TrimSpace(field))
+			direction = ToSnakeCase(strings.TrimSpace(direction))
+			if !include(fields, field) || !include(orderValues, direction) || seen[field] {
+				return "updated_at desc"
+			}
+			seen[field] = true
+			orders = append(orders, field+" "+direction)
+		}
+		return strings.Join(orders, ", ")
+	}
+
 	if include(fields, ToSnakeCase(sort)) && include(orderValues, ToSnakeCase(order)) {
 		return ToSnakeCase(sort) + " " + ToSnakeCase(order)
 	}
@@ -100,3 +274,96 @@ func ToPayload(r *http.Request) (model.Payload, error) {
 	}
 	return payload, nil
 }
+
+// tripCanaries alerts about the canary items in items which the request touches
+func tripCanaries(s storage.Store, r *http.Request, items interface{}, action string) {
+	userID := uint(r.Context().Value("id").(float64))
+	app.TripCanaries(s, userID, action, app.ClientIP(r).String(), items)
+}
+
+// setETag sets the ETag of the response to the revision of the item pointer
+func setETag(w http.ResponseWriter, item interface{}) {
+	w.Header().Set("ETag", app.ItemETag(item))
+}
+
+// matchETag reports if the If-Match of the request is missing or matches the item pointer.
+// It responds 412 with the current ETag when the item was changed since the client read it.
+func matchETag(w http.ResponseWriter, r *http.Request, item interface{}) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		return true
+	}
+	etag := app.ItemETag(item)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		if tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/"); tag == "*" || tag == etag {
+			return true
+		}
+	}
+	setETag(w, item)
+	RespondWithError(w, http.StatusPreconditionFailed, itemChanged)
+	return false
+}
+
+// matchRevision reports if the update DTO pointer was edited at the current revision of
+// the item pointer. It responds 409 with the versions of the conflict otherwise, so the
+// client can merge them instead of overwriting the other edit.
+func matchRevision(s storage.Store, w http.ResponseWriter, r *http.Request, item interface{}, dto interface{}) bool {
+	schema := r.Context().Value("schema").(string)
+	conflict, err := app.ItemConflict(s, item, dto, schema)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	if conflict == nil {
+		return true
+	}
+
+	key := r.Context().Value("transmissionKey").(string)
+	encrypted, err := app.EncryptJSON(key, conflict)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return false
+	}
+	setETag(w, item)
+	RespondWithJSON(w, http.StatusConflict, ErrorResponseDTO{
+		Code:    http.StatusConflict,
+		Status:  "Error",
+		Message: itemConflict,
+		Data:    string(encrypted),
+	})
+	return false
+}
+
+// updateItem updates the item pointer with the DTO pointer in a transaction, so an update
+// which loses against a concurrent one leaves no version or password history behind. The
+// loser gets 409 with the conflict when the DTO has the revision it was edited at, and 412
+// with the current ETag otherwise, like the checks before the update.
+func updateItem(s storage.Store, w http.ResponseWriter, r *http.Request, item interface{}, dto interface{}) (interface{}, bool) {
+	schema := r.Context().Value("schema").(string)
+	id := uint(reflect.ValueOf(item).Elem().FieldByName("ID").Uint())
+	var updated interface{}
+	err := s.Transaction(func(tx storage.Store) error {
+		var err error
+		updated, err = app.UpdateItem(tx, item, dto, schema)
+		return err
+	})
+	if err == nil {
+		return updated, true
+	}
+	if err != app.ErrItemChanged {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	current, err := app.FindItem(s, app.ItemTypeOf(item), id, schema)
+	if err != nil {
+		RespondWithError(w, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+	if r.Header.Get("If-Match") == "" && !matchRevision(s, w, r, current, dto) {
+		return nil, false
+	}
+	setETag(w, current)
+	RespondWithError(w, http.StatusPreconditionFailed, itemChanged)
+	return nil, false
+}
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+// importer imports the content of an export file to the vault of the schema
+type importer func(s storage.Store, dto *model.ImportFileDTO, schema string) (*model.ImportReportDTO, error)
+
+// ImportBitwarden imports the JSON or CSV export of a Bitwarden vault
+func ImportBitwarden(s storage.Store) http.HandlerFunc {
+	return importFile(s, app.ImportBitwarden)
+}
+
+// ImportLastPass imports the CSV export of a LastPass vault
+func ImportLastPass(s storage.Store) http.HandlerFunc {
+	return importFile(s, app.ImportLastPass)
+}
+
+// ImportOnePassword imports the base64 encoded 1PUX or the CSV export of 1Password
+func ImportOnePassword(s storage.Store) http.HandlerFunc {
+	return importFile(s, app.ImportOnePassword)
+}
+
+// ImportKeePass imports the base64 encoded KDBX file of a KeePass database opened with the
+// password, or the XML export of the database
+func ImportKeePass(s storage.Store) http.HandlerFunc {
+	return importFile(s, app.ImportKeePass)
+}
+
+// ImportBrowser imports the CSV export of the passwords of Chrome, Edge, Firefox or Safari
+func ImportBrowser(s storage.Store) http.HandlerFunc {
+	return importFile(s, app.ImportBrowser)
+}
+
+// ImportCSV imports a CSV of any layout with the mapping of its columns to the item fields
+func ImportCSV(s storage.Store) http.HandlerFunc {
+	return importFile(s, app.ImportCSV)
+}
+
+// importFile imports the export file of the encrypted model.ImportFileDTO with the importer
+// and answers the encrypted report of the imported and skipped entries
+func importFile(s storage.Store, importer importer) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := ToPayload(r)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		// Decrypt payload
+		var dto model.ImportFileDTO
+		key := r.Context().Value("transmissionKey").(string)
+		err = app.DecryptJSON(key, []byte(payload.Data), &dto)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		report, err := importer(s, &dto, schema)
+		if errors.Is(err, app.ErrImportFile) || errors.Is(err, app.ErrImportEncrypted) ||
+			errors.Is(err, app.ErrImportPassword) || errors.Is(err, app.ErrImportUnsupported) ||
+			errors.Is(err, app.ErrImportMapping) {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Imports are batches, clients sync the imported types
+		if !report.DryRun {
+			for itemType := range report.Imported {
+				app.PublishChange(schema, model.ChangeDTO{Type: itemType, Operation: app.AuditCreate})
+			}
+		}
+
+		// Encrypt payload
+		encrypted, err := app.EncryptJSON(key, report)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
@@ -0,0 +1,290 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	itemOrderSuccess = "Item order updated successfully!"
+	emptyBatch       = "Batch has no items"
+	emptyIDs         = "No ids to delete"
+	batchTooLarge    = "Batch has more than %d items"
+
+	// maxBatchItems is the most items a batch create accepts
+	maxBatchItems = 1000
+)
+
+// CloneItem copies an item of any type with a "(copy)" suffix, the optional payload moves
+// the copy to another folder of the vault
+func CloneItem(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		// Decrypt payload
+		dto := new(model.ItemCloneDTO)
+		key := r.Context().Value("transmissionKey").(string)
+		if r.ContentLength != 0 {
+			payload, err := ToPayload(r)
+			if err != nil {
+				RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+				return
+			}
+			defer r.Body.Close()
+			if err := app.DecryptJSON(key, []byte(payload.Data), dto); err != nil {
+				RespondWithError(w, http.StatusInternalServerError, err.Error())
+				return
+			}
+		}
+
+		schema := r.Context().Value("schema").(string)
+		item, err := app.FindItem(s, vars["type"], uint(id), schema)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		tripCanaries(s, r, item, app.CanaryRead)
+
+		clonedItem, err := app.CloneItem(s, vars["type"], uint(id), dto.FolderID, schema)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		app.AuditItem(r, clonedItem)
+
+		// Encrypt payload
+		var payload model.Payload
+		encrypted, err := app.EncryptJSON(key, app.ToItemDTO(clonedItem))
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
+
+// PatchItem updates an item of any type with only the changed fields, the fields of the
+// payload are merged into the stored item
+func PatchItem(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		payload, err := ToPayload(r)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		schema := r.Context().Value("schema").(string)
+		item, err := app.FindItem(s, vars["type"], uint(id), schema)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		tripCanaries(s, r, item, app.CanaryUpdate)
+
+		if !matchETag(w, r, item) {
+			return
+		}
+
+		// Decrypt payload over the DTO of the stored item, fields it leaves out are kept
+		dto := app.ToItemDTO(item)
+		key := r.Context().Value("transmissionKey").(string)
+		err = app.DecryptJSON(key, []byte(payload.Data), dto)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if err := app.ValidateItemDTO(dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		if !matchRevision(s, w, r, item, dto) {
+			return
+		}
+
+		updated, ok := updateItem(s, w, r, item, dto)
+		if !ok {
+			return
+		}
+
+		// Encrypt payload
+		encrypted, err := app.EncryptJSON(key, app.ToItemDTO(updated))
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		setETag(w, updated)
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
+
+// CreateItems creates a batch of items of any type in a single transaction, with a
+// result for each item
+func CreateItems(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := ToPayload(r)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		// Decrypt payload
+		var dtos []json.RawMessage
+		key := r.Context().Value("transmissionKey").(string)
+		err = app.DecryptJSON(key, []byte(payload.Data), &dtos)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(dtos) == 0 {
+			RespondWithError(w, http.StatusBadRequest, emptyBatch)
+			return
+		}
+		if len(dtos) > maxBatchItems {
+			RespondWithError(w, http.StatusBadRequest, fmt.Sprintf(batchTooLarge, maxBatchItems))
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		results, err := app.CreateItems(s, mux.Vars(r)["type"], dtos, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Encrypt payload
+		encrypted, err := app.EncryptJSON(key, results)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
+
+// DeleteItems deletes a batch of items of any type by their ids in a single transaction,
+// with a summary of the deleted and failed ids
+func DeleteItems(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := ToPayload(r)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		// Decrypt payload
+		var ids []uint
+		key := r.Context().Value("transmissionKey").(string)
+		err = app.DecryptJSON(key, []byte(payload.Data), &ids)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if len(ids) == 0 {
+			RespondWithError(w, http.StatusBadRequest, emptyIDs)
+			return
+		}
+		if len(ids) > maxBatchItems {
+			RespondWithError(w, http.StatusBadRequest, fmt.Sprintf(batchTooLarge, maxBatchItems))
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		items, failed := app.FindItemsByID(s, mux.Vars(r)["type"], ids, schema)
+
+		tripCanaries(s, r, items, app.CanaryDelete)
+
+		deleted, err := app.DeleteItems(s, items, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		summary := model.BatchDeleteDTO{Deleted: deleted, Failed: failed}
+
+		// Encrypt payload
+		encrypted, err := app.EncryptJSON(key, summary)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
+
+// UpdateItemOrders pins and reorders the items of a type
+func UpdateItemOrders(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		payload, err := ToPayload(r)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		// Decrypt payload
+		var orders []model.ItemOrderDTO
+		key := r.Context().Value("transmissionKey").(string)
+		err = app.DecryptJSON(key, []byte(payload.Data), &orders)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		err = app.SetItemOrders(s, mux.Vars(r)["type"], orders, schema)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: itemOrderSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
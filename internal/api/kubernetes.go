@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+)
+
+const k8sMaxWait = 20 * time.Second
+
+// k8sPollInterval is how often a waiting request checks the vault for changes
+var k8sPollInterval = time.Second
+
+// K8sSecrets returns the items of the machine account as Kubernetes Secrets.
+// With since set to the version of the last response, the request waits up to
+// wait seconds for a change, e.g. GET /k8s/secrets?namespace=apps&since=3f2a...&wait=20
+func K8sSecrets(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := machineAccountOfToken(s, w, r)
+		if !ok {
+			return
+		}
+
+		wait := time.Duration(0)
+		if r.FormValue("wait") != "" {
+			seconds, err := strconv.Atoi(r.FormValue("wait"))
+			if err != nil || seconds < 0 {
+				RespondWithError(w, http.StatusBadRequest, "wait should be a number of seconds")
+				return
+			}
+			wait = time.Duration(seconds) * time.Second
+		}
+		if wait > k8sMaxWait {
+			wait = k8sMaxWait
+		}
+		deadline := time.Now().Add(wait)
+
+		revision, err := app.MachineVaultRevision(s, account)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for {
+			list, items, err := app.RenderK8sSecrets(s, account, r.FormValue("namespace"))
+			if err != nil {
+				RespondWithError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+
+			// Only the sent list reads the items
+			if list.Version != r.FormValue("since") || !time.Now().Before(deadline) {
+				app.TripMachineCanaries(s, account, items)
+				app.AuditMachineReads(s, r, account, items)
+				w.Header().Set("Cache-Control", "no-store")
+				RespondWithJSON(w, http.StatusOK, list)
+				return
+			}
+
+			// The list is rendered again after a change of the vault or at the deadline
+			for changed := false; !changed && time.Now().Before(deadline); {
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(k8sPollInterval):
+				}
+				current, err := app.MachineVaultRevision(s, account)
+				if err != nil {
+					RespondWithError(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+				changed, revision = current != revision, current
+			}
+		}
+	}
+}
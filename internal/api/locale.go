@@ -0,0 +1,66 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/passwall/passwall-server/internal/i18n"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const localeNotSupported = "Locale is not supported"
+
+// FindLocale returns the preferred locale of the user and the locale of the request
+func FindLocale(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.Users().FindByID(uint(r.Context().Value("id").(float64)))
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, localeDTO(w, user))
+	}
+}
+
+// UpdateLocale sets the preferred locale of the user, sessions get it with their next token
+func UpdateLocale(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto := new(model.LocaleDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		if dto.Preferred != "" && !i18n.Supported(dto.Preferred) {
+			RespondWithError(w, http.StatusBadRequest, localeNotSupported)
+			return
+		}
+
+		user, err := s.Users().FindByID(uint(r.Context().Value("id").(float64)))
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		user.Locale = dto.Preferred
+		if user, err = s.Users().Save(user); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		if user.Locale != "" {
+			w.Header().Set(i18n.Header, user.Locale)
+		}
+		RespondWithJSON(w, http.StatusOK, localeDTO(w, user))
+	}
+}
+
+func localeDTO(w http.ResponseWriter, user *model.User) *model.LocaleDTO {
+	return &model.LocaleDTO{
+		Preferred: user.Locale,
+		Current:   i18n.LocaleOf(w),
+		Supported: i18n.Locales(),
+	}
+}
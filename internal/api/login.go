@@ -22,8 +22,12 @@ func FindAllLogins(s storage.Store) http.HandlerFunc {
 		var err error
 		var loginList []model.Login
 
-		fields := []string{"id", "created_at", "updated_at", "title"}
-		argsStr, argsInt := SetArgs(r, fields)
+		fields := []string{"id", "created_at", "updated_at", "title", "sort_order"}
+		filters := map[string]string{"title": "title", "url": "url"}
+		argsStr, argsInt, ok := SetListArgs(w, r, fields, filters)
+		if !ok {
+			return
+		}
 
 		schema := r.Context().Value("schema").(string)
 		loginList, err = s.Logins().FindAll(argsStr, argsInt, schema)
@@ -31,15 +35,51 @@ func FindAllLogins(s storage.Store) http.HandlerFunc {
 			RespondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
+		next := nextCursor(&loginList, argsStr, argsInt)
+
+		tripCanaries(s, r, loginList, app.CanaryRead)
 
-		// Decrypt server side encrypted fields
-		for i := range loginList {
-			uLogin, err := app.DecryptModel(&loginList[i])
-			if err != nil {
-				RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-			loginList[i] = *uLogin.(*model.Login)
+		if err := app.LoadItemTags(s, loginList, schema); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Encrypt payload
+		var payload model.Payload
+		key := r.Context().Value("transmissionKey").(string)
+		encrypted, err := app.EncryptJSON(key, loginList)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+		payload.NextCursor = next
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
+
+// FindAutofillLogins finds the logins of the site in url query param honoring equivalent domains
+func FindAutofillLogins(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		rawURL := r.FormValue("url")
+		if rawURL == "" {
+			RespondWithError(w, http.StatusBadRequest, "url is required")
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		loginList, err := app.FindAutofillLogins(s, rawURL, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		tripCanaries(s, r, loginList, app.CanaryRead)
+
+		if err := app.LoadItemTags(s, loginList, schema); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
 		}
 
 		// Encrypt payload
@@ -76,15 +116,15 @@ func FindLoginsByID(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		// Decrypt server side encrypted fields
-		uLogin, err := app.DecryptModel(login)
-		if err != nil {
+		tripCanaries(s, r, login, app.CanaryRead)
+
+		if err := app.LoadItemTags(s, login, schema); err != nil {
 			RespondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
 		// Create DTO
-		loginDTO := model.ToLoginDTO(uLogin.(*model.Login))
+		loginDTO := model.ToLoginDTO(login)
 
 		// Encrypt payload
 		var payload model.Payload
@@ -96,6 +136,7 @@ func FindLoginsByID(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, login)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -120,6 +161,11 @@ func CreateLogin(s storage.Store) http.HandlerFunc {
 			return
 		}
 
+		if err := app.ValidateRotation(&loginDTO); err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		// Add new login to db
 		schema := r.Context().Value("schema").(string)
 		createdLogin, err := app.CreateLogin(s, &loginDTO, schema)
@@ -127,6 +173,7 @@ func CreateLogin(s storage.Store) http.HandlerFunc {
 			RespondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		app.AuditItem(r, createdLogin)
 
 		// Create DTO
 		createdLoginDTO := model.ToLoginDTO(createdLogin)
@@ -171,6 +218,11 @@ func UpdateLogin(s storage.Store) http.HandlerFunc {
 			return
 		}
 
+		if err := app.ValidateRotation(&loginDTO); err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
 		schema := r.Context().Value("schema").(string)
 		login, err := s.Logins().FindByID(uint(id), schema)
 		if err != nil {
@@ -178,12 +230,21 @@ func UpdateLogin(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		updatedLogin, err := app.UpdateLogin(s, login, &loginDTO, schema)
-		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+		tripCanaries(s, r, login, app.CanaryUpdate)
+
+		if !matchETag(w, r, login) {
+			return
+		}
+		if !matchRevision(s, w, r, login, &loginDTO) {
 			return
 		}
 
+		updated, ok := updateItem(s, w, r, login, &loginDTO)
+		if !ok {
+			return
+		}
+		updatedLogin := updated.(*model.Login)
+
 		// Create DTO
 		updatedLoginDTO := model.ToLoginDTO(updatedLogin)
 
@@ -195,6 +256,7 @@ func UpdateLogin(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, updatedLogin)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -216,12 +278,20 @@ func DeleteLogin(s storage.Store) http.HandlerFunc {
 			return
 		}
 
+		tripCanaries(s, r, login, app.CanaryDelete)
+
 		err = s.Logins().Delete(login.ID, schema)
 		if err != nil {
 			RespondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
 
+		err = s.PasswordHistories().DeleteByLoginID(login.ID, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
 		response := model.Response{
 			Code:    http.StatusOK,
 			Status:  Success,
@@ -231,6 +301,91 @@ func DeleteLogin(s storage.Store) http.HandlerFunc {
 	}
 }
 
+// FindLoginPasswordHistory finds the previous passwords of a login
+func FindLoginPasswordHistory(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		login, err := s.Logins().FindByID(uint(id), schema)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		tripCanaries(s, r, login, app.CanaryRead)
+
+		histories, err := app.FindPasswordHistory(s, login.ID, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		// Encrypt payload
+		var payload model.Payload
+		key := r.Context().Value("transmissionKey").(string)
+		encrypted, err := app.EncryptJSON(key, model.ToPasswordHistoryDTOs(histories))
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
+
+// RotateLogin replaces the credential of a login with its rotation provider
+func RotateLogin(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		login, err := s.Logins().FindByID(uint(id), schema)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		if login.RotationProvider == "" {
+			RespondWithError(w, http.StatusBadRequest, app.ErrNoRotationProvider.Error())
+			return
+		}
+
+		rotatedLogin, err := app.RotateLogin(s, login, schema)
+		if err == app.ErrZeroKnowledge {
+			RespondWithError(w, http.StatusConflict, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusBadGateway, err.Error())
+			return
+		}
+
+		// Encrypt payload
+		var payload model.Payload
+		key := r.Context().Value("transmissionKey").(string)
+		encrypted, err := app.EncryptJSON(key, model.ToLoginDTO(rotatedLogin))
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
+
 // TestLogin login endpoint for test purposes
 func TestLogin(s storage.Store) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,268 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	machineAccountDeleteSuccess = "Machine account deleted successfully!"
+	machineAccountNotFound      = "Machine account not found"
+	machineAccountNotAllowed    = "Machine accounts can't be created in this session"
+)
+
+// FindAllMachineAccounts lists the machine accounts of the user
+func FindAllMachineAccounts(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := uint(r.Context().Value("id").(float64))
+		accounts, err := s.MachineAccounts().FindAllByUserID(userID)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		// Machine accounts read the real vault
+		if isDuress(r) {
+			accounts = []model.MachineAccount{}
+		}
+
+		// Encrypt payload
+		var payload model.Payload
+		key := r.Context().Value("transmissionKey").(string)
+		encrypted, err := app.EncryptJSON(key, model.ToMachineAccountDTOs(accounts))
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
+
+// CreateMachineAccount creates a machine account, its secret is only in this response
+func CreateMachineAccount(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if isDuress(r) {
+			RespondWithError(w, http.StatusForbidden, machineAccountNotAllowed)
+			return
+		}
+
+		dto, ok := decryptMachineAccountDTO(w, r)
+		if !ok {
+			return
+		}
+
+		user, err := s.Users().FindByID(uint(r.Context().Value("id").(float64)))
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		account, secret, err := app.CreateMachineAccount(s, user, dto)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		accountDTO := model.ToMachineAccountDTO(account)
+		accountDTO.Secret = secret
+
+		respondMachineAccount(w, r, accountDTO)
+	}
+}
+
+// UpdateMachineAccount changes the name and the items of a machine account
+func UpdateMachineAccount(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := findMachineAccount(s, w, r)
+		if !ok {
+			return
+		}
+
+		dto, ok := decryptMachineAccountDTO(w, r)
+		if !ok {
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		updatedAccount, err := app.UpdateMachineAccount(s, account, dto, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		respondMachineAccount(w, r, model.ToMachineAccountDTO(updatedAccount))
+	}
+}
+
+// DeleteMachineAccount deletes a machine account, its tokens stop working
+func DeleteMachineAccount(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := findMachineAccount(s, w, r)
+		if !ok {
+			return
+		}
+
+		if err := s.MachineAccounts().Delete(account.ID); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: machineAccountDeleteSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// CreateMachineToken exchanges the credentials of a machine account for a short-lived token
+func CreateMachineToken(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req model.MachineTokenRequestDTO
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(req); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		token, err := app.CreateMachineToken(s, req.ClientID, req.Secret)
+		if errors.Is(err, app.ErrMachineCredentials) {
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, token)
+	}
+}
+
+// Inject returns the items of the machine account as dotenv or JSON,
+// e.g. GET /inject?items=logins/3,notes/5&format=dotenv
+func Inject(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		account, ok := machineAccountOfToken(s, w, r)
+		if !ok {
+			return
+		}
+
+		// All items of the account are read without items
+		var items []string
+		if r.FormValue("items") != "" {
+			items = strings.Split(r.FormValue("items"), ",")
+		}
+
+		format := r.FormValue("format")
+		if format == "" {
+			format = app.InjectDotenv
+		}
+
+		secrets, read, err := app.InjectSecrets(s, account, items, format)
+		if errors.Is(err, app.ErrItemNotAllowed) {
+			RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		app.AuditMachineReads(s, r, account, read)
+
+		w.Header().Set("Cache-Control", "no-store")
+		if format == app.InjectJSON {
+			w.Header().Set("Content-Type", "application/json")
+		} else {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write(secrets)
+	}
+}
+
+// machineAccountOfToken finds the machine account of the bearer token
+func machineAccountOfToken(s storage.Store, w http.ResponseWriter, r *http.Request) (*model.MachineAccount, bool) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	account, err := app.FindMachineAccountByToken(s, token)
+	if err != nil {
+		RespondWithError(w, http.StatusUnauthorized, invalidToken)
+		return nil, false
+	}
+	return account, true
+}
+
+// findMachineAccount finds the machine account of the path if it belongs to the user
+func findMachineAccount(s storage.Store, w http.ResponseWriter, r *http.Request) (*model.MachineAccount, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	account, err := s.MachineAccounts().FindByID(uint(id))
+	if err != nil || account.UserID != uint(r.Context().Value("id").(float64)) || isDuress(r) {
+		RespondWithError(w, http.StatusNotFound, machineAccountNotFound)
+		return nil, false
+	}
+	return account, true
+}
+
+func decryptMachineAccountDTO(w http.ResponseWriter, r *http.Request) (*model.MachineAccountDTO, bool) {
+	payload, err := ToPayload(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	// Decrypt payload
+	dto := new(model.MachineAccountDTO)
+	key := r.Context().Value("transmissionKey").(string)
+	if err := app.DecryptJSON(key, []byte(payload.Data), dto); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		errs := GetErrors(w, err.(validator.ValidationErrors))
+		RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+		return nil, false
+	}
+	return dto, true
+}
+
+func respondMachineAccount(w http.ResponseWriter, r *http.Request, dto *model.MachineAccountDTO) {
+	// Encrypt payload
+	var payload model.Payload
+	key := r.Context().Value("transmissionKey").(string)
+	encrypted, err := app.EncryptJSON(key, dto)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	payload.Data = string(encrypted)
+
+	RespondWithJSON(w, http.StatusOK, payload)
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+)
+
+// MigrationStatus returns the migration version and the pending migrations of the
+// system schema and of every user schema
+func MigrationStatus(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		statuses, err := app.MigrationStatus(s)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, statuses)
+	}
+}
@@ -22,8 +22,12 @@ func FindAllNotes(s storage.Store) http.HandlerFunc {
 		var err error
 		noteList := []model.Note{}
 
-		fields := []string{"id", "created_at", "updated_at", "note"}
-		argsStr, argsInt := SetArgs(r, fields)
+		fields := []string{"id", "created_at", "updated_at", "note", "sort_order"}
+		filters := map[string]string{"title": "title"}
+		argsStr, argsInt, ok := SetListArgs(w, r, fields, filters)
+		if !ok {
+			return
+		}
 
 		schema := r.Context().Value("schema").(string)
 		noteList, err = s.Notes().FindAll(argsStr, argsInt, schema)
@@ -31,15 +35,13 @@ func FindAllNotes(s storage.Store) http.HandlerFunc {
 			RespondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
+		next := nextCursor(&noteList, argsStr, argsInt)
 
-		// Decrypt server side encrypted fields
-		for i := range noteList {
-			decNote, err := app.DecryptModel(&noteList[i])
-			if err != nil {
-				RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-			noteList[i] = *decNote.(*model.Note)
+		tripCanaries(s, r, noteList, app.CanaryRead)
+
+		if err := app.LoadItemTags(s, noteList, schema); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
 		}
 
 		// Encrypt payload
@@ -51,6 +53,7 @@ func FindAllNotes(s storage.Store) http.HandlerFunc {
 			return
 		}
 		payload.Data = string(encrypted)
+		payload.NextCursor = next
 
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
@@ -73,14 +76,14 @@ func FindNoteByID(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		// Decrypt server side encrypted fields
-		decNote, err := app.DecryptModel(note)
-		if err != nil {
+		tripCanaries(s, r, note, app.CanaryRead)
+
+		if err := app.LoadItemTags(s, note, schema); err != nil {
 			RespondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		noteDTO := model.ToNoteDTO(decNote.(*model.Note))
+		noteDTO := model.ToNoteDTO(note)
 
 		// Encrypt payload
 		var payload model.Payload
@@ -92,6 +95,7 @@ func FindNoteByID(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, note)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -122,6 +126,7 @@ func CreateNote(s storage.Store) http.HandlerFunc {
 			RespondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		app.AuditItem(r, createdNote)
 
 		createdNoteDTO := model.ToNoteDTO(createdNote)
 
@@ -172,12 +177,21 @@ func UpdateNote(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		updatedNote, err := app.UpdateNote(s, note, &noteDTO, schema)
-		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+		tripCanaries(s, r, note, app.CanaryUpdate)
+
+		if !matchETag(w, r, note) {
+			return
+		}
+		if !matchRevision(s, w, r, note, &noteDTO) {
 			return
 		}
 
+		updated, ok := updateItem(s, w, r, note, &noteDTO)
+		if !ok {
+			return
+		}
+		updatedNote := updated.(*model.Note)
+
 		updatedNoteDTO := model.ToNoteDTO(updatedNote)
 
 		// Encrypt payload
@@ -188,6 +202,7 @@ func UpdateNote(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, updatedNote)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -209,6 +224,8 @@ func DeleteNote(s storage.Store) http.HandlerFunc {
 			return
 		}
 
+		tripCanaries(s, r, note, app.CanaryDelete)
+
 		err = s.Notes().Delete(note.ID, schema)
 		if err != nil {
 			RespondWithError(w, http.StatusNotFound, err.Error())
@@ -0,0 +1,258 @@
+package api
+
+import (
+	"errors"
+	"html/template"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+var (
+	errOIDCSecurityKey = errors.New("Add an authenticator app to sign in here, security keys are only supported by the Passwall apps")
+	errOIDCUnverified  = errors.New("email address is not verified")
+	errOIDCDuress      = errors.New("duress password can't sign in to clients")
+)
+
+var oidcLoginTemplate = template.Must(template.New("oidc-login").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Sign in with Passwall</title></head>
+<body>
+<h1>Sign in to {{.ClientName}} with Passwall</h1>
+{{if .Error}}<p>{{.Error}}</p>{{end}}
+<form method="post">
+<input type="hidden" name="response_type" value="{{.Request.ResponseType}}">
+<input type="hidden" name="client_id" value="{{.Request.ClientID}}">
+<input type="hidden" name="redirect_uri" value="{{.Request.RedirectURI}}">
+<input type="hidden" name="scope" value="{{.Request.Scope}}">
+<input type="hidden" name="state" value="{{.Request.State}}">
+<input type="hidden" name="nonce" value="{{.Request.Nonce}}">
+<input type="hidden" name="code_challenge" value="{{.Request.CodeChallenge}}">
+<input type="hidden" name="code_challenge_method" value="{{.Request.CodeChallengeMethod}}">
+<p><input type="email" name="email" placeholder="Email" required autofocus></p>
+<p><input type="password" name="master_password" placeholder="Master password" required></p>
+<p><input type="text" name="totp_code" placeholder="Two factor code, if enabled" inputmode="numeric" autocomplete="one-time-code"></p>
+<p><button type="submit">Sign in</button></p>
+</form>
+</body>
+</html>
+`))
+
+// OIDCDiscovery serves the OpenID Connect provider metadata
+func OIDCDiscovery(w http.ResponseWriter, r *http.Request) {
+	RespondWithJSON(w, http.StatusOK, app.OIDCDiscovery())
+}
+
+// OIDCJWKS serves the public keys of the id tokens
+func OIDCJWKS(w http.ResponseWriter, r *http.Request) {
+	jwks, err := app.OIDCJWKS()
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, jwks)
+}
+
+// OIDCAuthorize shows the sign in form to the user and redirects back to the
+// client with an authorization code after a successful sign in
+func OIDCAuthorize(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		req := &model.OIDCAuthorizeDTO{
+			ResponseType:        r.FormValue("response_type"),
+			ClientID:            r.FormValue("client_id"),
+			RedirectURI:         r.FormValue("redirect_uri"),
+			Scope:               r.FormValue("scope"),
+			State:               r.FormValue("state"),
+			Nonce:               r.FormValue("nonce"),
+			CodeChallenge:       r.FormValue("code_challenge"),
+			CodeChallengeMethod: r.FormValue("code_challenge_method"),
+		}
+
+		client, err := app.ValidateOIDCAuthorize(req)
+		if client == nil {
+			// The redirect uri can't be trusted, so the user sees the error
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			redirectOIDC(w, r, req, url.Values{"error": {err.(*model.OIDCError).Code}})
+			return
+		}
+
+		view := struct {
+			ClientName string
+			Request    *model.OIDCAuthorizeDTO
+			Error      string
+		}{ClientName: client.Name, Request: req}
+		if view.ClientName == "" {
+			view.ClientName = client.ID
+		}
+
+		status := http.StatusOK
+		if r.Method == http.MethodPost {
+			user, session, err := signinOIDC(s, r)
+			if err == nil {
+				if err := app.CheckAccess(s, user.ID, app.NewAccessRequest(r, session)); err != nil {
+					redirectOIDC(w, r, req, url.Values{"error": {"access_denied"}, "error_description": {err.Error()}})
+					return
+				}
+				code, err := app.CreateOIDCCode(req, user)
+				if err != nil {
+					redirectOIDC(w, r, req, url.Values{"error": {"server_error"}})
+					return
+				}
+				app.RecordSignin(s, r, user, false, app.AuditSuccess)
+				redirectOIDC(w, r, req, url.Values{"code": {code}})
+				return
+			}
+
+			view.Error = userLoginErr
+			var throttle *app.SigninThrottle
+			switch {
+			case errors.As(err, &throttle):
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(throttle.RetryAfter.Seconds()))))
+				status, view.Error = http.StatusTooManyRequests, signinThrottledErr
+				if throttle.Locked {
+					status, view.Error = http.StatusLocked, accountLockedErr
+				}
+			case errors.Is(err, errOIDCUnverified):
+				status, view.Error = http.StatusForbidden, userVerifyErr
+			case errors.Is(err, app.ErrTOTPCode) || errors.Is(err, errOIDCSecurityKey):
+				view.Error = err.Error()
+			}
+		}
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		oidcLoginTemplate.Execute(w, view)
+	}
+}
+
+// signinOIDC checks the sign in of the form like Signin and SigninTOTP do, with the lockout
+// of the account and the address. Duress passwords and unverified accounts don't sign in to
+// clients, the form can't tell the decoy vault apart.
+func signinOIDC(s storage.Store, r *http.Request) (*model.User, *app.Session, error) {
+	email := r.FormValue("email")
+	ip := ""
+	if clientIP := app.ClientIP(r); clientIP != nil {
+		ip = clientIP.String()
+	}
+	attempt, throttle := app.StartSignin(s, email, ip, time.Now())
+	if throttle != nil {
+		return nil, nil, throttle
+	}
+
+	user, duress, err := app.Authenticate(s, email, r.FormValue("master_password"), ip)
+	if err != nil {
+		attempt.Fail(time.Now())
+		if known, err := s.Users().FindByEmail(email); err == nil {
+			app.RecordSignin(s, r, known, false, app.AuditFailure)
+		}
+		return nil, nil, err
+	}
+	if duress {
+		attempt.Continue()
+		app.RecordSignin(s, r, user, true, app.AuditFailure)
+		return nil, nil, errOIDCDuress
+	}
+	if app.EmailVerificationRequired(user) {
+		attempt.Succeed()
+		return nil, nil, errOIDCUnverified
+	}
+
+	session := &app.Session{Start: time.Now()}
+	if user.TwoFactorEnabled {
+		if err := app.VerifyTOTP(s, user, r.FormValue("totp_code"), time.Now()); err != nil {
+			attempt.Fail(time.Now())
+			app.RecordSignin(s, r, user, false, app.AuditFailure)
+			return nil, nil, err
+		}
+		session.TwoFactor = true
+	} else if methods, err := app.TwoFactorMethods(s, user); err != nil {
+		attempt.Continue()
+		return nil, nil, err
+	} else if len(methods) > 0 {
+		// The form can't ask for security keys, only the authenticator app
+		attempt.Continue()
+		return nil, nil, errOIDCSecurityKey
+	}
+	attempt.Succeed()
+	return user, session, nil
+}
+
+// OIDCToken exchanges an authorization code for tokens
+func OIDCToken(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+
+		if r.FormValue("grant_type") != "authorization_code" {
+			respondOIDCError(w, http.StatusBadRequest, &model.OIDCError{Code: "unsupported_grant_type"})
+			return
+		}
+
+		clientID, secret, ok := r.BasicAuth()
+		if !ok {
+			clientID, secret = r.FormValue("client_id"), r.FormValue("client_secret")
+		}
+
+		client, err := app.AuthenticateOIDCClient(clientID, secret)
+		if err != nil {
+			respondOIDCError(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		token, err := app.ExchangeOIDCCode(s, client, r.FormValue("code"), r.FormValue("redirect_uri"), r.FormValue("code_verifier"))
+		if err != nil {
+			respondOIDCError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, token)
+	}
+}
+
+// OIDCUserInfo returns the claims of the user of the access token
+func OIDCUserInfo(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		accessToken := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		info, err := app.OIDCUserInfo(s, accessToken)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			respondOIDCError(w, http.StatusUnauthorized, err)
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, info)
+	}
+}
+
+// redirectOIDC sends the user back to the client with the params and the request state
+func redirectOIDC(w http.ResponseWriter, r *http.Request, req *model.OIDCAuthorizeDTO, params url.Values) {
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+
+	separator := "?"
+	if strings.Contains(req.RedirectURI, "?") {
+		separator = "&"
+	}
+	http.Redirect(w, r, req.RedirectURI+separator+params.Encode(), http.StatusFound)
+}
+
+func respondOIDCError(w http.ResponseWriter, code int, err error) {
+	oidcErr, ok := err.(*model.OIDCError)
+	if !ok {
+		code = http.StatusInternalServerError
+		oidcErr = &model.OIDCError{Code: "server_error"}
+	}
+	RespondWithJSON(w, code, oidcErr)
+}
@@ -0,0 +1,159 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+// FindPolicies returns the server and user policies and the effective rules of the user
+func FindPolicies(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := uint(r.Context().Value("id").(float64))
+
+		server, err := app.FindPolicy(s, app.ServerPolicyID)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		user, err := app.FindPolicy(s, userID)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		effective, err := app.EffectivePolicy(s, userID)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.PoliciesDTO{
+			Server:    model.ToPolicyDTO(server),
+			User:      model.ToPolicyDTO(user),
+			Effective: effective,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// UpdatePolicy replaces the user policy, or the server policy on the system endpoint
+func UpdatePolicy(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID, allowed := policyUserID(r)
+		if !allowed {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		dto := new(model.PolicyDTO)
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		if err := app.ValidatePolicy(dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		policy, err := app.SavePolicy(s, userID, dto)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, model.ToPolicyDTO(policy))
+	}
+}
+
+// ExemptFromSecurityKey lets an admin override the security key requirement of a user
+func ExemptFromSecurityKey(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		dto := new(model.SecurityKeyExemptionDTO)
+		decoder := json.NewDecoder(r.Body)
+		if err := decoder.Decode(&dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		if _, err := s.Users().FindByID(uint(id)); err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		policy, err := app.ExemptFromSecurityKey(s, uint(id), dto, adminName(r))
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, model.ToPolicyDTO(policy))
+	}
+}
+
+// RemoveSecurityKeyExemption ends the override of the security key requirement of a user
+func RemoveSecurityKeyExemption(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		policy, err := app.RemoveSecurityKeyExemption(s, uint(id), adminName(r))
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, model.ToPolicyDTO(policy))
+	}
+}
+
+// adminName identifies the admin of the request in audit entries
+func adminName(r *http.Request) string {
+	return fmt.Sprintf("user %v", r.Context().Value("id"))
+}
+
+// policyUserID returns the user id of the policy endpoint.
+// The server policy has app.ServerPolicyID and only admins can change it.
+func policyUserID(r *http.Request) (uint, bool) {
+	if strings.HasPrefix(r.URL.Path, "/api/system/") {
+		return app.ServerPolicyID, r.Context().Value("authorized").(bool)
+	}
+	return uint(r.Context().Value("id").(float64)), true
+}
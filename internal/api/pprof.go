@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"net/http/pprof"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Profiles serves the CPU, heap and other runtime profiles of net/http/pprof to admins.
+// Profiles can hold secrets from memory, every download is logged.
+func Profiles() http.HandlerFunc {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		log.WithFields(log.Fields{
+			"event":   "profile_read",
+			"user_id": r.Context().Value("id"),
+			"path":    r.URL.Path,
+		}).Warn("runtime profile is read")
+		mux.ServeHTTP(w, r)
+	}
+}
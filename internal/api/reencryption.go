@@ -0,0 +1,133 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const keyRotationFinished = "Key rotation is finished, the previous passphrase is dropped"
+
+// ReencryptionStatus returns the progress and ETA of the re-encryption jobs
+func ReencryptionStatus(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		status, err := app.ReencryptionStatus(s, time.Now())
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, status)
+	}
+}
+
+// StartReencryption queues a job which re-encrypts all vaults, e.g. after a key rotation
+func StartReencryption(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		dto := new(model.ReencryptionRequestDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		job, err := app.QueueReencryption(s, dto.Reason, 0)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusAccepted, model.ToReencryptionJobDTO(job))
+	}
+}
+
+// RotateServerKey makes the passphrase of the request the server passphrase and queues the
+// re-encryption of all vaults with it
+func RotateServerKey(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		dto := new(model.KeyRotationDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		job, err := app.RotateServerKey(s, dto.Passphrase)
+		if !respondKeyRotationError(w, err) {
+			return
+		}
+
+		RespondWithJSON(w, http.StatusAccepted, model.ToReencryptionJobDTO(job))
+	}
+}
+
+// FinishKeyRotation drops the previous server passphrase after the re-encryption is done
+func FinishKeyRotation(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		if !respondKeyRotationError(w, app.FinishKeyRotation(s)) {
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: keyRotationFinished,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// respondKeyRotationError responds with the error of a key rotation, it's false if there was one
+func respondKeyRotationError(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, app.ErrPassphraseWeak):
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+	case errors.Is(err, app.ErrKeyRotationRunning), errors.Is(err, app.ErrPassphraseFromEnv), errors.Is(err, app.ErrServerKeySplit):
+		RespondWithError(w, http.StatusConflict, err.Error())
+	default:
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+	}
+	return false
+}
@@ -8,6 +8,7 @@ import (
 	"text/template"
 
 	"github.com/go-playground/validator/v10"
+	"github.com/passwall/passwall-server/internal/i18n"
 	"github.com/passwall/passwall-server/model"
 )
 
@@ -17,10 +18,13 @@ type ErrorResponseDTO struct {
 	Status  string   `json:"status"`
 	Message string   `json:"message"`
 	Errors  []string `json:"errors"`
+	// Data is an encrypted payload of the details of the error, e.g. of a conflict
+	Data string `json:"data,omitempty"`
 }
 
 type fieldError struct {
-	err validator.FieldError
+	err    validator.FieldError
+	locale string
 }
 
 // RespondWithError ...
@@ -33,8 +37,18 @@ func RespondWithErrors(w http.ResponseWriter, code int, message string, errors [
 	RespondWithJSON(w, code, ErrorResponseDTO{Code: code, Status: "Error", Message: message, Errors: errors})
 }
 
-// RespondWithJSON write json
+// RespondWithJSON write json, messages of responses are translated to the locale of the request
 func RespondWithJSON(w http.ResponseWriter, code int, payload interface{}) {
+	locale := i18n.LocaleOf(w)
+	switch p := payload.(type) {
+	case ErrorResponseDTO:
+		p.Message = i18n.T(locale, p.Message)
+		payload = p
+	case model.Response:
+		p.Message = i18n.T(locale, p.Message)
+		payload = p
+	}
+
 	response, _ := json.Marshal(payload)
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -61,11 +75,11 @@ func RespondWithHTML(w http.ResponseWriter, code int, payload interface{}) {
 	t.Execute(w, payload.(model.Response))
 }
 
-// GetErrors ...
-func GetErrors(errs []validator.FieldError) []string {
+// GetErrors describes the validation errors in the locale of the response
+func GetErrors(w http.ResponseWriter, errs []validator.FieldError) []string {
 	var arr []string
 	for _, fe := range errs {
-		arr = append(arr, (fieldError{fe}.String()))
+		arr = append(arr, (fieldError{fe, i18n.LocaleOf(w)}.String()))
 	}
 	return arr
 }
@@ -73,8 +87,8 @@ func GetErrors(errs []validator.FieldError) []string {
 func (q fieldError) String() string {
 	var sb strings.Builder
 
-	sb.WriteString("validation failed on field '" + q.err.Field() + "'")
-	sb.WriteString(", condition: " + q.err.ActualTag())
+	sb.WriteString(i18n.Sprintf(q.locale, "validation failed on field '%s'", q.err.Field()))
+	sb.WriteString(i18n.Sprintf(q.locale, ", condition: %s", q.err.ActualTag()))
 
 	// Print condition parameters, e.g. oneof=red blue -> { red blue }
 	if q.err.Param() != "" {
@@ -82,7 +96,7 @@ func (q fieldError) String() string {
 	}
 
 	if q.err.Value() != nil && q.err.Value() != "" {
-		sb.WriteString(fmt.Sprintf(", actual: %v", q.err.Value()))
+		sb.WriteString(i18n.Sprintf(q.locale, ", actual: %v", q.err.Value()))
 	}
 
 	return sb.String()
@@ -0,0 +1,36 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+)
+
+// RetentionReport returns what the retention policy would purge now without deleting it
+func RetentionReport(s storage.Store) http.HandlerFunc {
+	return purgeExpiredData(s, true)
+}
+
+// PurgeExpiredData purges the data which is older than the retention policy right away
+func PurgeExpiredData(s storage.Store) http.HandlerFunc {
+	return purgeExpiredData(s, false)
+}
+
+func purgeExpiredData(s storage.Store, dryRun bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !r.Context().Value("authorized").(bool) {
+			RespondWithError(w, http.StatusForbidden, adminOnly)
+			return
+		}
+
+		report, err := app.PurgeExpiredData(s, time.Now(), dryRun)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		RespondWithJSON(w, http.StatusOK, report)
+	}
+}
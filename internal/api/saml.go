@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+// SAMLMetadata returns the service provider metadata the identity provider is configured with
+func SAMLMetadata(w http.ResponseWriter, r *http.Request) {
+	metadata, err := app.SAMLMetadata(mux.Vars(r)["provider"])
+	if err != nil {
+		RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.WriteHeader(http.StatusOK)
+	w.Write(metadata)
+}
+
+// BeginSAML returns the url of the identity provider the user signs in on
+func BeginSAML(w http.ResponseWriter, r *http.Request) {
+	authorization, err := app.BeginSAML(mux.Vars(r)["provider"])
+	if err != nil {
+		RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, authorization)
+}
+
+// ConsumeSAML is the assertion consumer service the browser posts the response of the
+// identity provider to, it's redirected to the client with a code and the state
+func ConsumeSAML(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.PostForm.Get("SAMLResponse") == "" {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+
+		redirectURL, err := app.ConsumeSAML(s, mux.Vars(r)["provider"], r.PostForm.Get("SAMLResponse"), r.PostForm.Get("RelayState"))
+		if errors.Is(err, app.ErrSSOState) || errors.Is(err, app.ErrSSOUser) || errors.Is(err, app.ErrSAMLResponse) {
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		http.Redirect(w, r, redirectURL, http.StatusSeeOther)
+	}
+}
+
+// FinishSAML signs the user in with the code and state the client was redirected with,
+// the session starts like the one of Signin
+func FinishSAML(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto := new(model.SSOCallbackDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusUnprocessableEntity, InvalidJSON)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		user, err := app.FinishSAML(s, mux.Vars(r)["provider"], dto)
+		if errors.Is(err, app.ErrSSOState) {
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		continueSignin(s, w, r, user, false, &model.DeviceSigninDTO{}, nil)
+	}
+}
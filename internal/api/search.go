@@ -0,0 +1,50 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	searchQueryRequired = "Search query is required"
+)
+
+// Search finds the items of all types matching the q query param
+func Search(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.FormValue("q")
+		if query == "" {
+			RespondWithError(w, http.StatusBadRequest, searchQueryRequired)
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		offset := setOffset(r.FormValue("Offset"))
+		limit := setLimit(r.FormValue("Limit"))
+		items, total, err := app.Search(s, query, offset, limit, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		result := model.SearchResultDTO{
+			Total: total,
+			Items: toTypedItems(s, r, items),
+		}
+
+		// Encrypt payload
+		var payload model.Payload
+		key := r.Context().Value("transmissionKey").(string)
+		encrypted, err := app.EncryptJSON(key, result)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
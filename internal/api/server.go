@@ -24,8 +24,12 @@ func FindAllServers(s storage.Store) http.HandlerFunc {
 		var err error
 		var serverList []model.Server
 
-		fields := []string{"id", "created_at", "updated_at", "title", "ip", "url"}
-		argsStr, argsInt := SetArgs(r, fields)
+		fields := []string{"id", "created_at", "updated_at", "title", "ip", "url", "sort_order"}
+		filters := map[string]string{"title": "title", "url": "url"}
+		argsStr, argsInt, ok := SetListArgs(w, r, fields, filters)
+		if !ok {
+			return
+		}
 
 		schema := r.Context().Value("schema").(string)
 		serverList, err = s.Servers().FindAll(argsStr, argsInt, schema)
@@ -33,15 +37,13 @@ func FindAllServers(s storage.Store) http.HandlerFunc {
 			RespondWithError(w, http.StatusNotFound, err.Error())
 			return
 		}
+		next := nextCursor(&serverList, argsStr, argsInt)
 
-		// Decrypt server side encrypted fields
-		for i := range serverList {
-			decServer, err := app.DecryptModel(&serverList[i])
-			if err != nil {
-				RespondWithError(w, http.StatusInternalServerError, err.Error())
-				return
-			}
-			serverList[i] = *decServer.(*model.Server)
+		tripCanaries(s, r, serverList, app.CanaryRead)
+
+		if err := app.LoadItemTags(s, serverList, schema); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
 		}
 
 		// Encrypt payload
@@ -53,6 +55,7 @@ func FindAllServers(s storage.Store) http.HandlerFunc {
 			return
 		}
 		payload.Data = string(encrypted)
+		payload.NextCursor = next
 
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
@@ -75,14 +78,14 @@ func FindServerByID(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		// Decrypt server side encrypted fields
-		decServer, err := app.DecryptModel(server)
-		if err != nil {
+		tripCanaries(s, r, server, app.CanaryRead)
+
+		if err := app.LoadItemTags(s, server, schema); err != nil {
 			RespondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
 
-		serverDTO := model.ToServerDTO(decServer.(*model.Server))
+		serverDTO := model.ToServerDTO(server)
 
 		// Encrypt payload
 		var payload model.Payload
@@ -94,6 +97,7 @@ func FindServerByID(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, server)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -125,6 +129,7 @@ func CreateServer(s storage.Store) http.HandlerFunc {
 			RespondWithError(w, http.StatusInternalServerError, err.Error())
 			return
 		}
+		app.AuditItem(r, createdServer)
 
 		createdServerDTO := model.ToServerDTO(createdServer)
 
@@ -175,12 +180,21 @@ func UpdateServer(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		updatedServer, err := app.UpdateServer(s, server, &serverDTO, schema)
-		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
+		tripCanaries(s, r, server, app.CanaryUpdate)
+
+		if !matchETag(w, r, server) {
+			return
+		}
+		if !matchRevision(s, w, r, server, &serverDTO) {
 			return
 		}
 
+		updated, ok := updateItem(s, w, r, server, &serverDTO)
+		if !ok {
+			return
+		}
+		updatedServer := updated.(*model.Server)
+
 		updatedServerDTO := model.ToServerDTO(updatedServer)
 
 		// Encrypt payload
@@ -191,6 +205,7 @@ func UpdateServer(s storage.Store) http.HandlerFunc {
 		}
 		payload.Data = string(encrypted)
 
+		setETag(w, updatedServer)
 		RespondWithJSON(w, http.StatusOK, payload)
 	}
 }
@@ -212,6 +227,8 @@ func DeleteServer(s storage.Store) http.HandlerFunc {
 			return
 		}
 
+		tripCanaries(s, r, server, app.CanaryDelete)
+
 		err = s.Servers().Delete(server.ID, schema)
 		if err != nil {
 			RespondWithError(w, http.StatusNotFound, err.Error())
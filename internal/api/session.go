@@ -0,0 +1,132 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const sessionDeleteSuccess = "Session revoked successfully!"
+
+// FindAllSessions lists the devices the user is signed in on
+func FindAllSessions(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := uint(r.Context().Value("id").(float64))
+		sessions, err := app.FindSessions(s, userID, isDuress(r))
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		current, _ := r.Context().Value("session").(string)
+		respondSessions(w, r, model.ToSessionDTOs(sessions, current))
+	}
+}
+
+// DeleteSession signs out a device, its access and refresh tokens stop working
+func DeleteSession(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := uint(r.Context().Value("id").(float64))
+		err := app.DeleteSession(s, userID, mux.Vars(r)["id"], isDuress(r))
+		if err == app.ErrSessionNotFound {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: sessionDeleteSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// Reauthenticate confirms the master password and returns the token which allows sensitive
+// requests of the session, like exports, for a few minutes. Failures are throttled like
+// the ones of Signin.
+func Reauthenticate(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto := new(model.ReauthDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		user, err := s.Users().FindByID(uint(r.Context().Value("id").(float64)))
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		// Wrong master passwords count against the lockout of sign ins, a stolen token
+		// isn't a way to guess it
+		ip := ""
+		if clientIP := app.ClientIP(r); clientIP != nil {
+			ip = clientIP.String()
+		}
+		attempt, throttle := app.StartSignin(s, user.Email, ip, time.Now())
+		if throttle != nil {
+			respondSigninThrottle(w, throttle)
+			return
+		}
+
+		token, err := app.Reauthenticate(user, dto.MasterPassword, isDuress(r), reauthSession(r), time.Now())
+		if errors.Is(err, app.ErrMasterPassword) {
+			attempt.Fail(time.Now())
+			RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if err != nil {
+			attempt.Continue()
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		attempt.Succeed()
+		RespondWithJSON(w, http.StatusOK, token)
+	}
+}
+
+// reauthSession identifies the session of the request for re-authentications, requests
+// of personal access tokens by their token
+func reauthSession(r *http.Request) string {
+	if id, ok := r.Context().Value("accessToken").(uint); ok {
+		return fmt.Sprintf("token:%d", id)
+	}
+	session, _ := r.Context().Value("session").(string)
+	return session
+}
+
+func respondSessions(w http.ResponseWriter, r *http.Request, v interface{}) {
+	// Encrypt payload
+	var payload model.Payload
+	key := r.Context().Value("transmissionKey").(string)
+	encrypted, err := app.EncryptJSON(key, v)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	payload.Data = string(encrypted)
+
+	RespondWithJSON(w, http.StatusOK, payload)
+}
@@ -0,0 +1,65 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+// FindSSOProviders lists the single sign-on providers for the sign in page
+func FindSSOProviders(w http.ResponseWriter, r *http.Request) {
+	providers, err := app.SSOProviders()
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, providers)
+}
+
+// BeginSSO returns the url of the provider the user signs in on
+func BeginSSO(w http.ResponseWriter, r *http.Request) {
+	authorization, err := app.BeginSSO(mux.Vars(r)["provider"])
+	if err != nil {
+		RespondWithError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, authorization)
+}
+
+// FinishSSO signs the user in with the code and state the provider redirected with,
+// the session starts like the one of Signin
+func FinishSSO(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto := new(model.SSOCallbackDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusUnprocessableEntity, InvalidJSON)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		user, err := app.FinishSSO(s, mux.Vars(r)["provider"], dto)
+		if errors.Is(err, app.ErrSSOState) || errors.Is(err, app.ErrSSOUser) || errors.Is(err, app.ErrSSOProvider) {
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		continueSignin(s, w, r, user, false, &model.DeviceSigninDTO{}, nil)
+	}
+}
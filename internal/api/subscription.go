@@ -119,14 +119,7 @@ func FindSubscriptionByID(s storage.Store) http.HandlerFunc {
 			return
 		}
 
-		// Decrypt subscription side encrypted fields
-		decSubscription, err := app.DecryptModel(subscription)
-		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-
-		subscriptionDTO := model.ToSubscriptionDTO(decSubscription.(*model.Subscription))
+		subscriptionDTO := model.ToSubscriptionDTO(subscription)
 
 		// Encrypt payload
 		var payload model.Payload
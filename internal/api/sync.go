@@ -0,0 +1,69 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	invalidSyncRevision = "Invalid sync revision"
+)
+
+// Sync finds the changes of the items of all types since the sync token of the previous sync
+func Sync(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schema := r.Context().Value("schema").(string)
+		token := r.FormValue("token")
+
+		// Clients of the sync before tokens send the revision as since, it's deprecated
+		// and removed in the next release
+		if value := r.FormValue("since"); token == "" && value != "" {
+			since, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || since < 0 {
+				RespondWithError(w, http.StatusBadRequest, invalidSyncRevision)
+				return
+			}
+			if since > 0 {
+				if token, err = app.SyncRevisionToken(s, since, schema); err != nil {
+					RespondWithError(w, http.StatusInternalServerError, err.Error())
+					return
+				}
+			}
+		}
+
+		changes, err := app.Sync(s, token, schema)
+		if errors.Is(err, app.ErrInvalidSyncToken) {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		sync := model.SyncDTO{
+			Token:    changes.Token,
+			Reset:    changes.Reset,
+			Revision: changes.Revision,
+			Changed:  toTypedItems(s, r, changes.Changed),
+			Deleted:  changes.Deleted,
+		}
+
+		// Encrypt payload
+		var payload model.Payload
+		key := r.Context().Value("transmissionKey").(string)
+		encrypted, err := app.EncryptJSON(key, sync)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
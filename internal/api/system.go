@@ -1,7 +1,6 @@
 package api
 
 import (
-	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -14,19 +13,14 @@ import (
 	"github.com/passwall/passwall-server/internal/app"
 	"github.com/passwall/passwall-server/internal/storage"
 	"github.com/passwall/passwall-server/model"
-	"github.com/spf13/viper"
 	"gopkg.in/yaml.v2"
 )
 
 const (
 	//InvalidJSON represents a message for invalid json
 	InvalidJSON = "Invalid json provided"
-	//RestoreBackupSuccess represents a message when restoring from backap successfully
-	RestoreBackupSuccess = "Restore from backup completed successfully!"
 	//ImportSuccess represents when inporting successgully
 	ImportSuccess = "Import finished successfully!"
-	//BackupSuccess represents when backup completed successfully
-	BackupSuccess = "Backup completed successfully!"
 )
 
 // CheckUpdate generates new password
@@ -195,86 +189,6 @@ func Import(s storage.Store) http.HandlerFunc {
 	}
 } */
 
-// Restore restores logins from backup file ./store/passwall-{BACKUP_DATE}.bak
-func Restore(s storage.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		var restoreDTO model.RestoreDTO
-
-		// get restoreDTO
-		decoder := json.NewDecoder(r.Body)
-		if err := decoder.Decode(&restoreDTO); err != nil {
-			RespondWithError(w, http.StatusUnprocessableEntity, InvalidJSON)
-			return
-		}
-		defer r.Body.Close()
-
-		backupFolder := viper.GetString("backup.folder")
-		backupFile := restoreDTO.Name
-		// add extension if there is no
-		if len(filepath.Ext(restoreDTO.Name)) <= 0 {
-			backupFile = fmt.Sprintf("%s%s", restoreDTO.Name, ".bak")
-		}
-		backupPath := filepath.Join(backupFolder, backupFile)
-
-		_, err := os.Open(backupPath)
-		if err != nil {
-			RespondWithError(w, http.StatusNotFound, err.Error())
-			return
-		}
-
-		loginsByte := app.DecryptFile(backupPath, viper.GetString("server.passphrase"))
-
-		var loginDTOs []model.LoginDTO
-		json.Unmarshal(loginsByte, &loginDTOs)
-		schema := r.Context().Value("schema").(string)
-		for i := range loginDTOs {
-
-			login := &model.Login{
-				URL:      loginDTOs[i].URL,
-				Username: loginDTOs[i].Username,
-				Password: base64.StdEncoding.EncodeToString(app.Encrypt(loginDTOs[i].Password, viper.GetString("server.passphrase"))),
-			}
-
-			s.Logins().Save(login, schema)
-		}
-
-		response := model.Response{Code: http.StatusOK, Status: Success, Message: RestoreBackupSuccess}
-		RespondWithJSON(w, http.StatusOK, response)
-	}
-}
-
-// Backup backups the store
-/* func Backup(s storage.Store) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		err := app.BackupData(s)
-
-		if err != nil {
-			RespondWithError(w, http.StatusInternalServerError, err.Error())
-			return
-		}
-
-		response := model.Response{http.StatusOK, Success, BackupSuccess}
-		RespondWithJSON(w, http.StatusOK, response)
-	}
-} */
-
-// ListBackup all backups
-/* func ListBackup(w http.ResponseWriter, r *http.Request) {
-	backupFiles, err := app.GetBackupFiles()
-
-	if err != nil {
-		RespondWithError(w, http.StatusInternalServerError, err.Error())
-		return
-	}
-
-	var response []model.Backup
-	for _, backupFile := range backupFiles {
-		response = append(response, model.Backup{Name: backupFile.Name(), CreatedAt: backupFile.ModTime()})
-	}
-
-	RespondWithJSON(w, http.StatusOK, response)
-} */
-
 func upload(r *http.Request) (*os.File, error) {
 
 	// Max 10 MB
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	tagDeleteSuccess = "Tag deleted successfully!"
+)
+
+// FindAllTags finds all tags
+func FindAllTags(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schema := r.Context().Value("schema").(string)
+		tags, err := s.Tags().All(schema)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		respondTag(w, r, model.ToTagDTOs(tags))
+	}
+}
+
+// FindTagByID finds a tag by id
+func FindTagByID(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tag, ok := findTag(s, w, r)
+		if !ok {
+			return
+		}
+
+		respondTag(w, r, model.ToTagDTO(tag))
+	}
+}
+
+// CreateTag creates a tag
+func CreateTag(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto, ok := decryptTag(w, r)
+		if !ok {
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		tag, err := app.CreateTag(s, dto, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		respondTag(w, r, model.ToTagDTO(tag))
+	}
+}
+
+// UpdateTag renames a tag
+func UpdateTag(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tag, ok := findTag(s, w, r)
+		if !ok {
+			return
+		}
+		dto, ok := decryptTag(w, r)
+		if !ok {
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		tag, err := app.UpdateTag(s, tag, dto, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		respondTag(w, r, model.ToTagDTO(tag))
+	}
+}
+
+// DeleteTag deletes a tag and removes it from its items
+func DeleteTag(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tag, ok := findTag(s, w, r)
+		if !ok {
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		if err := s.Tags().Delete(tag.ID, schema); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: tagDeleteSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// findTag finds the tag of the id in the path, it responds when it isn't found
+func findTag(s storage.Store, w http.ResponseWriter, r *http.Request) (*model.Tag, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["id"])
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return nil, false
+	}
+
+	schema := r.Context().Value("schema").(string)
+	tag, err := s.Tags().FindByID(uint(id), schema)
+	if err != nil {
+		RespondWithError(w, http.StatusNotFound, err.Error())
+		return nil, false
+	}
+	return tag, true
+}
+
+// decryptTag decrypts and validates the tag of the payload, it responds when it's invalid
+func decryptTag(w http.ResponseWriter, r *http.Request) (*model.TagDTO, bool) {
+	payload, err := ToPayload(r)
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+		return nil, false
+	}
+	defer r.Body.Close()
+
+	// Decrypt payload
+	dto := new(model.TagDTO)
+	key := r.Context().Value("transmissionKey").(string)
+	if err := app.DecryptJSON(key, []byte(payload.Data), dto); err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return nil, false
+	}
+
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		errs := GetErrors(w, err.(validator.ValidationErrors))
+		RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+		return nil, false
+	}
+	return dto, true
+}
+
+func respondTag(w http.ResponseWriter, r *http.Request, v interface{}) {
+	// Encrypt payload
+	var payload model.Payload
+	key := r.Context().Value("transmissionKey").(string)
+	encrypted, err := app.EncryptJSON(key, v)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	payload.Data = string(encrypted)
+
+	RespondWithJSON(w, http.StatusOK, payload)
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	itemRestoreSuccess = "Item restored successfully!"
+	itemPurgeSuccess   = "Item purged successfully!"
+	itemNotInTrash     = "Item isn't in the trash"
+)
+
+// FindTrash finds the deleted items of all types
+func FindTrash(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schema := r.Context().Value("schema").(string)
+		trash, err := app.Trash(s, schema)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		for i := range trash {
+			tripCanaries(s, r, trash[i].Item, app.CanaryRead)
+			trash[i].Item = app.ToItemDTO(trash[i].Item)
+		}
+
+		// Encrypt payload
+		var payload model.Payload
+		key := r.Context().Value("transmissionKey").(string)
+		encrypted, err := app.EncryptJSON(key, trash)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		payload.Data = string(encrypted)
+
+		RespondWithJSON(w, http.StatusOK, payload)
+	}
+}
+
+// RestoreItem moves a deleted item of any type back into the vault
+func RestoreItem(s storage.Store) http.HandlerFunc {
+	return trashAction(s, app.RestoreItem, itemRestoreSuccess)
+}
+
+// PurgeItem deletes a deleted item of any type permanently
+func PurgeItem(s storage.Store) http.HandlerFunc {
+	return trashAction(s, app.PurgeItem, itemPurgeSuccess)
+}
+
+func trashAction(s storage.Store, action func(storage.Store, string, uint, string) error, message string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		schema := r.Context().Value("schema").(string)
+		err = action(s, vars["type"], uint(id), schema)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			RespondWithError(w, http.StatusNotFound, itemNotInTrash)
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: message,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
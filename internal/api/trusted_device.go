@@ -0,0 +1,63 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	trustedDeviceDeleteSuccess = "Trusted device revoked successfully!"
+	trustedDeviceNotFound      = "Trusted device not found"
+)
+
+// FindAllTrustedDevices lists the devices which skip the second factor
+func FindAllTrustedDevices(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.Users().FindByID(uint(r.Context().Value("id").(float64)))
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		devices, err := app.FindTrustedDevices(s, user, isDuress(r))
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, model.ToTrustedDeviceDTOs(devices))
+	}
+}
+
+// DeleteTrustedDevice revokes a trusted device of the user
+func DeleteTrustedDevice(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id, err := strconv.Atoi(mux.Vars(r)["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		device, err := s.TrustedDevices().FindByID(uint(id))
+		if err != nil || device.UserID != uint(r.Context().Value("id").(float64)) || (isDuress(r) && !device.Duress) {
+			RespondWithError(w, http.StatusNotFound, trustedDeviceNotFound)
+			return
+		}
+
+		if err := app.RevokeTrustedDevice(s, device); err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: trustedDeviceDeleteSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
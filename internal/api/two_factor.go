@@ -0,0 +1,165 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+// SigninTOTP continues a sign in of a user with two factor authentication, the tokens
+// are created after the code of the authenticator app is verified. Wrong codes count
+// against the lockout of the account and end the challenge after a few of them.
+func SigninTOTP(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto := new(model.TwoFactorSigninDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusUnprocessableEntity, InvalidJSON)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		user, duress, err := app.VerifyTwoFactorChallenge(s, dto.TwoFactorToken)
+		if err != nil {
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		attempt, ok := startSecondFactor(w, r, s, user)
+		if !ok {
+			return
+		}
+		if err := app.VerifyTOTP(s, user, dto.Code, time.Now()); err != nil {
+			attempt.Fail(time.Now())
+			app.FailTwoFactorChallenge(s, dto.TwoFactorToken, time.Now())
+			app.RecordSignin(s, r, user, duress, app.AuditFailure)
+			RespondWithError(w, http.StatusUnauthorized, app.ErrTOTPCode.Error())
+			return
+		}
+		attempt.Succeed()
+
+		session := &app.Session{Start: time.Now(), Duress: duress, TwoFactor: true}
+		if !checkAccess(s, w, r, user.ID, session) {
+			return
+		}
+		deviceToken, err := app.TrustDevice(s, user, &dto.TrustDeviceDTO, session)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondWithSession(s, w, r, user, session, deviceToken)
+	}
+}
+
+// startSecondFactor checks the lockout of the account and the address before a second
+// factor is, like Signin does before the master password
+func startSecondFactor(w http.ResponseWriter, r *http.Request, s storage.Store, user *model.User) (*app.SigninAttempt, bool) {
+	ip := ""
+	if clientIP := app.ClientIP(r); clientIP != nil {
+		ip = clientIP.String()
+	}
+	attempt, throttle := app.StartSignin(s, user.Email, ip, time.Now())
+	if throttle != nil {
+		respondSigninThrottle(w, throttle)
+		return nil, false
+	}
+	return attempt, true
+}
+
+// FindTwoFactor tells which second factors the user has
+func FindTwoFactor(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, err := s.Users().FindByID(uint(r.Context().Value("id").(float64)))
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		respondWithTwoFactor(s, w, user)
+	}
+}
+
+// EnrollTOTP generates the secret of the authenticator app, two factor authentication
+// is on after VerifyTOTP confirms a code of it
+func EnrollTOTP(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := findDuressUser(s, w, r)
+		if !ok {
+			return
+		}
+
+		enrollment, err := app.EnrollTOTP(s, user)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, enrollment)
+	}
+}
+
+// VerifyTOTP enables two factor authentication with a code of the enrolled secret
+func VerifyTOTP(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		updateTOTP(s, w, r, app.EnableTOTP)
+	}
+}
+
+// DisableTOTP disables two factor authentication, a current code confirms it
+func DisableTOTP(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		updateTOTP(s, w, r, app.DisableTOTP)
+	}
+}
+
+func updateTOTP(s storage.Store, w http.ResponseWriter, r *http.Request, update func(storage.Store, *model.User, string) (*model.User, error)) {
+	dto := new(model.TOTPCodeDTO)
+	if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+		RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+		return
+	}
+	defer r.Body.Close()
+
+	validate := validator.New()
+	if err := validate.Struct(dto); err != nil {
+		errs := GetErrors(w, err.(validator.ValidationErrors))
+		RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+		return
+	}
+
+	user, ok := findDuressUser(s, w, r)
+	if !ok {
+		return
+	}
+
+	user, err := update(s, user, dto.Code)
+	if errors.Is(err, app.ErrTOTPCode) {
+		RespondWithError(w, http.StatusForbidden, err.Error())
+		return
+	}
+	if err != nil {
+		RespondWithError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	respondWithTwoFactor(s, w, user)
+}
+
+func respondWithTwoFactor(s storage.Store, w http.ResponseWriter, user *model.User) {
+	methods, err := app.TwoFactorMethods(s, user)
+	if err != nil {
+		RespondWithError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	RespondWithJSON(w, http.StatusOK, model.TwoFactorStatusDTO{Enabled: len(methods) > 0, Methods: methods})
+}
@@ -77,7 +77,7 @@ func CreateUser(s storage.Store) http.HandlerFunc {
 		validate := validator.New()
 		validateError := validate.Struct(userDTO)
 		if validateError != nil {
-			errs := GetErrors(validateError.(validator.ValidationErrors))
+			errs := GetErrors(w, validateError.(validator.ValidationErrors))
 			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
 			return
 		}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/gorilla/mux"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+)
+
+var (
+	securityKeyDeleteSuccess = "Security key deleted successfully!"
+)
+
+// BeginSecurityKeyRegistration returns the options of navigator.credentials.create()
+// for a new security key of the user
+func BeginSecurityKeyRegistration(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		user, ok := findDuressUser(s, w, r)
+		if !ok {
+			return
+		}
+
+		options, err := app.BeginWebAuthnRegistration(s, user)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, options)
+	}
+}
+
+// FinishSecurityKeyRegistration saves the security key the browser created for the options
+func FinishSecurityKeyRegistration(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto := new(model.WebAuthnRegistrationDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusBadRequest, InvalidRequestPayload)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		user, ok := findDuressUser(s, w, r)
+		if !ok {
+			return
+		}
+
+		credential, err := app.FinishWebAuthnRegistration(s, user, dto)
+		if errors.Is(err, app.ErrSecurityKey) {
+			RespondWithError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, model.ToWebAuthnCredentialDTO(credential))
+	}
+}
+
+// FindAllSecurityKeys lists the security keys of the user
+func FindAllSecurityKeys(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		schema := r.Context().Value("schema").(string)
+		credentials, err := s.WebAuthnCredentials().All(schema)
+		if err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		dtos := make([]*model.WebAuthnCredentialDTO, 0, len(credentials))
+		for i := range credentials {
+			dtos = append(dtos, model.ToWebAuthnCredentialDTO(&credentials[i]))
+		}
+		RespondWithJSON(w, http.StatusOK, dtos)
+	}
+}
+
+// DeleteSecurityKey removes a security key of the user
+func DeleteSecurityKey(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		vars := mux.Vars(r)
+		id, err := strconv.Atoi(vars["id"])
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+
+		user, ok := findDuressUser(s, w, r)
+		if !ok {
+			return
+		}
+
+		if err := app.RemoveWebAuthnCredential(s, user, uint(id)); err != nil {
+			RespondWithError(w, http.StatusNotFound, err.Error())
+			return
+		}
+
+		response := model.Response{
+			Code:    http.StatusOK,
+			Status:  Success,
+			Message: securityKeyDeleteSuccess,
+		}
+		RespondWithJSON(w, http.StatusOK, response)
+	}
+}
+
+// BeginSecurityKeySignin returns the options of navigator.credentials.get() for the
+// two factor token of Signin
+func BeginSecurityKeySignin(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto := new(model.TwoFactorTokenDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusUnprocessableEntity, InvalidJSON)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		user, _, err := app.VerifyTwoFactorChallenge(s, dto.TwoFactorToken)
+		if err != nil {
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		options, err := app.BeginWebAuthnSignin(s, user)
+		if err != nil {
+			RespondWithError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		RespondWithJSON(w, http.StatusOK, options)
+	}
+}
+
+// SigninSecurityKey continues a sign in of a user with two factor authentication, the
+// tokens are created after the assertion of a security key is verified
+func SigninSecurityKey(s storage.Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		dto := new(model.WebAuthnSigninDTO)
+		if err := json.NewDecoder(r.Body).Decode(dto); err != nil {
+			RespondWithError(w, http.StatusUnprocessableEntity, InvalidJSON)
+			return
+		}
+		defer r.Body.Close()
+
+		validate := validator.New()
+		if err := validate.Struct(dto); err != nil {
+			errs := GetErrors(w, err.(validator.ValidationErrors))
+			RespondWithErrors(w, http.StatusBadRequest, InvalidRequestPayload, errs)
+			return
+		}
+
+		user, duress, err := app.VerifyTwoFactorChallenge(s, dto.TwoFactorToken)
+		if err != nil {
+			RespondWithError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		attempt, ok := startSecondFactor(w, r, s, user)
+		if !ok {
+			return
+		}
+		if err := app.FinishWebAuthnSignin(s, user, dto.Credential); err != nil {
+			attempt.Fail(time.Now())
+			app.FailTwoFactorChallenge(s, dto.TwoFactorToken, time.Now())
+			RespondWithError(w, http.StatusUnauthorized, app.ErrSecurityKey.Error())
+			return
+		}
+		attempt.Succeed()
+
+		session := &app.Session{Start: time.Now(), Duress: duress, TwoFactor: true, SecurityKey: true}
+		if !checkAccess(s, w, r, user.ID, session) {
+			return
+		}
+		deviceToken, err := app.TrustDevice(s, user, &dto.TrustDeviceDTO, session)
+		if err != nil {
+			RespondWithError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		respondWithSession(s, w, r, user, session, deviceToken)
+	}
+}
@@ -0,0 +1,214 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Denial codes of conditional access rules
+const (
+	AccessCountryBlocked    = "COUNTRY_BLOCKED"
+	AccessTwoFactorRequired = "TWO_FACTOR_REQUIRED"
+	AccessOutsideHours      = "OUTSIDE_ACCESS_HOURS"
+	AccessSecurityKey       = "SECURITY_KEY_REQUIRED"
+)
+
+var (
+	errCountryCode = errors.New("blocked countries should be ISO 3166 codes like TR")
+	errAccessHours = errors.New("access hours should be like 09:00-18:00")
+	errAccessDay   = errors.New("access days should be like mon, tue, wed")
+
+	weekDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+// AccessDeniedError is returned when a conditional access rule denies a request
+type AccessDeniedError struct {
+	Code   string
+	Reason string
+}
+
+func (e *AccessDeniedError) Error() string {
+	return e.Reason
+}
+
+// AccessRequest is where and when a user signs in or calls the API
+type AccessRequest struct {
+	IP          net.IP
+	Country     string // ISO 3166 code, empty when unknown
+	Time        time.Time
+	TwoFactor   bool
+	SecurityKey bool
+}
+
+// NewAccessRequest returns the access request of r in the session. The country comes
+// from the header set in server.countryHeader by a proxy or CDN like CF-IPCountry.
+func NewAccessRequest(r *http.Request, session *Session) *AccessRequest {
+	req := &AccessRequest{
+		IP:          ClientIP(r),
+		Time:        time.Now(),
+		TwoFactor:   session.TwoFactor,
+		SecurityKey: session.SecurityKey,
+	}
+	if header := viper.GetString("server.countryHeader"); header != "" {
+		req.Country = strings.ToUpper(strings.TrimSpace(r.Header.Get(header)))
+	}
+	return req
+}
+
+// CheckAccess evaluates the access rules of the server and user policies,
+// a request has to pass both of them. Denials are written to the log.
+func CheckAccess(s storage.Store, userID uint, req *AccessRequest) error {
+	for _, id := range []uint{ServerPolicyID, userID} {
+		policy, err := FindPolicy(s, id)
+		if err != nil {
+			return err
+		}
+
+		if denied := checkPolicyAccess(policy, req); denied != nil {
+			logAccessDenied(denied, userID, policyName(id), req)
+			return denied
+		}
+	}
+
+	effective, err := EffectivePolicy(s, userID)
+	if err != nil {
+		return err
+	}
+	if denied := checkSecurityKey(effective, req); denied != nil {
+		logAccessDenied(denied, userID, "effective", req)
+		return denied
+	}
+	return nil
+}
+
+func logAccessDenied(denied *AccessDeniedError, userID uint, policy string, req *AccessRequest) {
+	log.WithFields(log.Fields{
+		"event":   "access_denied",
+		"code":    denied.Code,
+		"user_id": userID,
+		"policy":  policy,
+		"ip":      req.IP.String(),
+		"country": req.Country,
+	}).Warn(denied.Reason)
+}
+
+// checkPolicyAccess returns the first rule of the policy which denies the request
+func checkPolicyAccess(policy *model.Policy, req *AccessRequest) *AccessDeniedError {
+	if req.Country != "" {
+		for _, country := range policy.Countries() {
+			if country == req.Country {
+				return &AccessDeniedError{AccessCountryBlocked, fmt.Sprintf("Access from %s is blocked", req.Country)}
+			}
+		}
+	}
+
+	if policy.RequireTwoFactorOutsideOffice && !req.TwoFactor && !inNetworks(req.IP, policy.CIDRs()) {
+		return &AccessDeniedError{AccessTwoFactorRequired, "Two factor authentication is required outside the office network"}
+	}
+
+	if !inAccessTime(policy, req.Time) {
+		return &AccessDeniedError{AccessOutsideHours, "Access is not allowed at this time"}
+	}
+	return nil
+}
+
+// validateAccessRules checks the conditional access rules of the policy
+func validateAccessRules(dto *model.PolicyDTO) error {
+	for _, country := range dto.BlockedCountries {
+		if len(country) != 2 {
+			return errCountryCode
+		}
+	}
+	for _, cidr := range dto.OfficeCIDRs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("office cidr %q is not valid", cidr)
+		}
+	}
+	if dto.AccessHours != "" {
+		if _, _, err := parseAccessHours(dto.AccessHours); err != nil {
+			return err
+		}
+	}
+	for _, day := range dto.AccessDays {
+		if weekDay(strings.ToLower(day)) < 0 {
+			return errAccessDay
+		}
+	}
+	if _, err := time.LoadLocation(dto.TimeZone); err != nil {
+		return fmt.Errorf("time zone %q is not valid", dto.TimeZone)
+	}
+	return nil
+}
+
+// inAccessTime reports whether t is in the access days and hours of the policy
+// in its time zone. Hours like 22:00-06:00 span midnight.
+func inAccessTime(policy *model.Policy, t time.Time) bool {
+	if location, err := time.LoadLocation(policy.TimeZone); err == nil {
+		t = t.In(location)
+	}
+
+	if days := policy.Days(); len(days) > 0 {
+		allowed := false
+		for _, day := range days {
+			allowed = allowed || weekDay(day) == int(t.Weekday())
+		}
+		if !allowed {
+			return false
+		}
+	}
+
+	start, end, err := parseAccessHours(policy.AccessHours)
+	if err != nil {
+		return true
+	}
+	minute := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// parseAccessHours returns the minutes of the day of hours like 09:00-18:00
+func parseAccessHours(hours string) (int, int, error) {
+	var startHour, startMinute, endHour, endMinute int
+	n, _ := fmt.Sscanf(hours, "%d:%d-%d:%d", &startHour, &startMinute, &endHour, &endMinute)
+	if n != 4 || startHour < 0 || startHour > 23 || endHour < 0 || endHour > 24 ||
+		startMinute < 0 || startMinute > 59 || endMinute < 0 || endMinute > 59 {
+		return 0, 0, errAccessHours
+	}
+	return startHour*60 + startMinute, endHour*60 + endMinute, nil
+}
+
+func inNetworks(ip net.IP, cidrs []string) bool {
+	for _, cidr := range cidrs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func weekDay(day string) int {
+	for i, d := range weekDays {
+		if d == day {
+			return i
+		}
+	}
+	return -1
+}
+
+func policyName(id uint) string {
+	if id == ServerPolicyID {
+		return "server"
+	}
+	return "user"
+}
@@ -0,0 +1,74 @@
+package app
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckPolicyAccess(t *testing.T) {
+	policy := &model.Policy{
+		BlockedCountries:              "KP,RU",
+		OfficeCIDRs:                   "10.0.0.0/8",
+		RequireTwoFactorOutsideOffice: true,
+	}
+	// Monday noon
+	monday := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	office := &AccessRequest{IP: net.ParseIP("10.1.2.3"), Country: "TR", Time: monday}
+	assert.Nil(t, checkPolicyAccess(policy, office))
+
+	blocked := &AccessRequest{IP: net.ParseIP("10.1.2.3"), Country: "RU", Time: monday}
+	assert.Equal(t, AccessCountryBlocked, checkPolicyAccess(policy, blocked).Code)
+
+	remote := &AccessRequest{IP: net.ParseIP("203.0.113.9"), Time: monday}
+	assert.Equal(t, AccessTwoFactorRequired, checkPolicyAccess(policy, remote).Code)
+
+	remote.TwoFactor = true
+	assert.Nil(t, checkPolicyAccess(policy, remote))
+}
+
+func TestInAccessTime(t *testing.T) {
+	policy := &model.Policy{AccessHours: "09:00-18:00", AccessDays: "mon,tue,wed,thu,fri", TimeZone: "UTC"}
+	assert.True(t, inAccessTime(policy, time.Date(2020, 6, 1, 9, 0, 0, 0, time.UTC)))
+	assert.False(t, inAccessTime(policy, time.Date(2020, 6, 1, 18, 0, 0, 0, time.UTC)))
+	assert.False(t, inAccessTime(policy, time.Date(2020, 6, 6, 12, 0, 0, 0, time.UTC)))
+
+	night := &model.Policy{AccessHours: "22:00-06:00"}
+	assert.True(t, inAccessTime(night, time.Date(2020, 6, 1, 23, 30, 0, 0, time.UTC)))
+	assert.True(t, inAccessTime(night, time.Date(2020, 6, 2, 5, 59, 0, 0, time.UTC)))
+	assert.False(t, inAccessTime(night, time.Date(2020, 6, 2, 12, 0, 0, 0, time.UTC)))
+}
+
+func TestValidateAccessRules(t *testing.T) {
+	assert.NoError(t, validateAccessRules(&model.PolicyDTO{
+		BlockedCountries: []string{"ru"},
+		OfficeCIDRs:      []string{"192.168.1.0/24"},
+		AccessHours:      "08:30-17:30",
+		AccessDays:       []string{"Mon"},
+		TimeZone:         "Europe/Istanbul",
+	}))
+	assert.Equal(t, errCountryCode, validateAccessRules(&model.PolicyDTO{BlockedCountries: []string{"Russia"}}))
+	assert.Error(t, validateAccessRules(&model.PolicyDTO{OfficeCIDRs: []string{"10.0.0.1"}}))
+	assert.Equal(t, errAccessHours, validateAccessRules(&model.PolicyDTO{AccessHours: "9-18"}))
+	assert.Equal(t, errAccessDay, validateAccessRules(&model.PolicyDTO{AccessDays: []string{"monday"}}))
+	assert.Error(t, validateAccessRules(&model.PolicyDTO{TimeZone: "Mars/Olympus"}))
+}
+
+func TestCheckAccess(t *testing.T) {
+	mocks := storagetest.NewMocks()
+	mocks.Policies.On("FindByUserID", uint(ServerPolicyID)).Return(&model.Policy{BlockedCountries: "KP"}, nil)
+	mocks.Policies.On("FindByUserID", uint(1)).Return(nil, gorm.ErrRecordNotFound)
+
+	err := CheckAccess(mocks.Store, 1, &AccessRequest{Country: "KP", Time: time.Now()})
+	denied, ok := err.(*AccessDeniedError)
+	assert.True(t, ok)
+	assert.Equal(t, AccessCountryBlocked, denied.Code)
+
+	assert.NoError(t, CheckAccess(mocks.Store, 1, &AccessRequest{Country: "TR", Time: time.Now()}))
+}
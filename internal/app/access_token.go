@@ -0,0 +1,114 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// AccessTokenPrefix starts every personal access token, so they're told apart from JWTs
+	AccessTokenPrefix = "pw_"
+
+	accessTokenBytes      = 32
+	accessTokenPrefixSize = 10
+)
+
+var errAccessTokenExpiry = errors.New("Expiry of the token has to be in the future")
+
+// IsAccessToken tells if the bearer token is a personal access token
+func IsAccessToken(bearerToken string) bool {
+	return strings.HasPrefix(bearerToken, AccessTokenPrefix)
+}
+
+// CreateAccessToken creates a personal access token of the user. The returned token isn't
+// stored, only its hash is, so it can't be shown again.
+func CreateAccessToken(s storage.Store, user *model.User, dto *model.PersonalAccessTokenDTO) (*model.PersonalAccessToken, string, error) {
+	if dto.ExpiresAt != nil && !dto.ExpiresAt.After(time.Now()) {
+		return nil, "", errAccessTokenExpiry
+	}
+
+	b := make([]byte, accessTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return nil, "", err
+	}
+	raw := AccessTokenPrefix + base64.RawURLEncoding.EncodeToString(b)
+
+	transmissionKey, err := NewTransmissionKey()
+	if err != nil {
+		return nil, "", err
+	}
+
+	token, err := s.AccessTokens().Save(&model.PersonalAccessToken{
+		UserID:          user.ID,
+		Name:            dto.Name,
+		Prefix:          raw[:accessTokenPrefixSize],
+		Hash:            hashAccessToken(raw),
+		TransmissionKey: transmissionKey,
+		ExpiresAt:       dto.ExpiresAt,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	log.WithFields(log.Fields{
+		"event":    "access_token_created",
+		"user_id":  user.ID,
+		"token_id": token.ID,
+		"name":     token.Name,
+	}).Info("personal access token is created")
+	return token, raw, nil
+}
+
+// FindAccessToken returns the personal access token of the bearer token if it isn't expired
+func FindAccessToken(s storage.Store, bearerToken string) (*model.PersonalAccessToken, error) {
+	if !IsAccessToken(bearerToken) {
+		return nil, ErrUnauthorized
+	}
+	token, err := s.AccessTokens().FindByHash(hashAccessToken(bearerToken))
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+	now := time.Now()
+	if token.ExpiresAt != nil && !now.Before(*token.ExpiresAt) {
+		return nil, ErrExpiredToken
+	}
+
+	// Like sessions the activity is written once per interval
+	if !ReadOnly() && (token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= sessionTouchInterval) {
+		token.LastUsedAt = &now
+		if _, err := s.AccessTokens().Save(token); err != nil {
+			log.WithError(err).WithField("access_token_id", token.ID).Error("last use of personal access token couldn't be saved")
+		}
+	}
+	return token, nil
+}
+
+// RevokeAccessToken deletes the personal access token, it stops working right away
+func RevokeAccessToken(s storage.Store, token *model.PersonalAccessToken) error {
+	if err := s.AccessTokens().Delete(token.ID); err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"event":    "access_token_revoked",
+		"user_id":  token.UserID,
+		"token_id": token.ID,
+		"name":     token.Name,
+	}).Info("personal access token is revoked")
+	return nil
+}
+
+// hashAccessToken returns the hash the token is looked up by. Tokens are random,
+// so a fast hash doesn't make them easier to guess.
+func hashAccessToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
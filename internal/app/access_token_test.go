@@ -0,0 +1,49 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFindAccessToken(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Second)
+	past := now.Add(-time.Minute)
+	active := &model.PersonalAccessToken{ID: 1, LastUsedAt: &recent}
+	idle := &model.PersonalAccessToken{ID: 2}
+	expired := &model.PersonalAccessToken{ID: 3, ExpiresAt: &past}
+
+	mocks := storagetest.NewMocks()
+	mocks.AccessTokens.On("FindByHash", hashAccessToken("pw_active")).Return(active, nil)
+	mocks.AccessTokens.On("FindByHash", hashAccessToken("pw_idle")).Return(idle, nil)
+	mocks.AccessTokens.On("FindByHash", hashAccessToken("pw_expired")).Return(expired, nil)
+	mocks.AccessTokens.On("FindByHash", mock.Anything).Return(nil, errors.New("record not found"))
+	mocks.AccessTokens.On("Save", idle).Return(idle, nil).Once()
+
+	// Recent use isn't written again
+	token, err := FindAccessToken(mocks.Store, "pw_active")
+	assert.NoError(t, err)
+	assert.Equal(t, active, token)
+	assert.Equal(t, &recent, token.LastUsedAt)
+
+	token, err = FindAccessToken(mocks.Store, "pw_idle")
+	assert.NoError(t, err)
+	assert.NotNil(t, token.LastUsedAt)
+
+	_, err = FindAccessToken(mocks.Store, "pw_expired")
+	assert.Equal(t, ErrExpiredToken, err)
+	_, err = FindAccessToken(mocks.Store, "pw_unknown")
+	assert.Equal(t, ErrUnauthorized, err)
+
+	// JWTs aren't looked up
+	_, err = FindAccessToken(mocks.Store, "eyJhbGciOiJIUzI1NiJ9.e30.sig")
+	assert.Equal(t, ErrUnauthorized, err)
+	mocks.AccessTokens.AssertNotCalled(t, "FindByHash", hashAccessToken("eyJhbGciOiJIUzI1NiJ9.e30.sig"))
+	mocks.AssertExpectations(t)
+}
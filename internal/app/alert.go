@@ -0,0 +1,114 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/i18n"
+	"github.com/passwall/passwall-server/internal/logging"
+	"github.com/passwall/passwall-server/internal/tracing"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Alert is a security event which is sent through all notification channels
+type Alert struct {
+	Event   string            `json:"event"`
+	Subject string            `json:"subject"`
+	Message string            `json:"message"`
+	Name    string            `json:"name"`
+	Email   string            `json:"email"`
+	Time    time.Time         `json:"time"`
+	Fields  map[string]string `json:"fields"`
+	Locale  string            `json:"locale,omitempty"` // of the user, admins get the alert in English
+
+	// format and args of Message, so it can be translated
+	format string
+	args   []interface{}
+}
+
+// Notifier sends an alert through a notification channel
+type Notifier func(alert *Alert) error
+
+// Notifiers are the notification channels of alerts
+var Notifiers = []Notifier{EmailNotifier, WebhookNotifier}
+
+// SendAlert sends the alert through all notification channels in the background
+func SendAlert(alert *Alert) {
+	// The errors are logged with the request id of the request which raised the alert
+	requestID := logging.RequestID()
+	for _, notify := range Notifiers {
+		notify := notify
+		goBackground(func() {
+			defer logging.Bind(requestID)()
+			if err := notify(alert); err != nil {
+				log.WithField("event", alert.Event).Error(err)
+			}
+		})
+	}
+}
+
+// EmailNotifier mails the alert to the user and to the admin address in alert.email
+func EmailNotifier(alert *Alert) error {
+	if viper.GetString("email.apiKey") == "" {
+		return nil
+	}
+
+	admin := viper.GetString("alert.email")
+	if admin == "" {
+		admin = viper.GetString("email.fromEmail")
+	}
+
+	if alert.Email != "" {
+		SendMail(alert.Name, alert.Email, i18n.T(alert.Locale, alert.Subject), alert.body(alert.Locale))
+	}
+	SendMail(viper.GetString("email.fromName"), admin, alert.Subject, alert.body(i18n.Default))
+	return nil
+}
+
+// body is the text of the alert email in the locale
+func (alert *Alert) body(locale string) string {
+	message := i18n.T(locale, alert.Message)
+	if alert.format != "" {
+		message = i18n.Sprintf(locale, alert.format, alert.args...)
+	}
+
+	body := message + "\n\n"
+	for key, value := range alert.Fields {
+		body += i18n.T(locale, key) + ": " + value + "\n"
+	}
+	body += i18n.T(locale, "Time") + ": " + alert.Time.UTC().Format(time.RFC3339) + "\n"
+	return body
+}
+
+// WebhookNotifier posts the alert as JSON to alert.webhookURL.
+// The text field makes it readable in Slack and Mattermost channels.
+func WebhookNotifier(alert *Alert) error {
+	url := viper.GetString("alert.webhookURL")
+	if url == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(struct {
+		*Alert
+		Text string `json:"text"`
+	}{alert, alert.Subject + ": " + alert.Message})
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Transport: tracing.Transport(nil), Timeout: 10 * time.Second}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("alert webhook returned %s", resp.Status)
+	}
+	return nil
+}
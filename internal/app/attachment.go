@@ -0,0 +1,426 @@
+package app
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"mime"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/blob"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	// attachmentChunk is the size of the chunks which are encrypted on their own, so files
+	// are streamed without being kept in memory
+	attachmentChunk = 64 << 10
+	// attachmentPrefix is the prefix of the blob keys of the attachments of all vaults
+	attachmentPrefix = "attachments/"
+	// maxAttachmentName is the longest file name which is kept
+	maxAttachmentName = 255
+)
+
+var attachmentMagic = []byte("PWATTACH1")
+
+var (
+	// ErrAttachmentNotFound is returned when the item has no attachment with the id
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	// ErrAttachmentTooLarge is returned for files larger than the max size of the user
+	ErrAttachmentTooLarge = errors.New("attachment is larger than the max size")
+	// ErrAttachmentQuota is returned when a file doesn't fit in the quota of the user
+	ErrAttachmentQuota = errors.New("attachments of the vault would exceed the quota")
+	// errAttachmentDamaged is returned for content which doesn't decrypt with its key
+	errAttachmentDamaged = errors.New("attachment content is damaged")
+)
+
+// AttachmentUsage returns the space the attachments of the vault in schema use and the
+// limits of the user
+func AttachmentUsage(s storage.Store, userID uint, schema string) (*model.AttachmentUsageDTO, error) {
+	policy, err := FindPolicy(s, userID)
+	if err != nil {
+		return nil, err
+	}
+	used, err := s.Attachments().TotalSize(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := &model.AttachmentUsageDTO{
+		Used:    used,
+		MaxSize: viper.GetInt64("attachment.maxSize"),
+		Quota:   viper.GetInt64("attachment.quota"),
+	}
+	if policy.AttachmentMaxSize > 0 {
+		usage.MaxSize = policy.AttachmentMaxSize
+	}
+	if policy.AttachmentQuota > 0 {
+		usage.Quota = policy.AttachmentQuota
+	}
+	return usage, nil
+}
+
+// SetAttachmentLimits lets an admin change the attachment limits of a user, 0 sets a
+// limit back to the server configuration
+func SetAttachmentLimits(s storage.Store, userID uint, dto *model.AttachmentLimitsDTO, admin string) (*model.Policy, error) {
+	if userID == ServerPolicyID {
+		return nil, errors.New("attachment limits of the server are set in its configuration")
+	}
+	policy, err := FindPolicy(s, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.AttachmentMaxSize = dto.MaxSize
+	policy.AttachmentQuota = dto.Quota
+	if policy, err = s.Policies().Save(policy); err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"event":    "attachment_limits",
+		"user_id":  userID,
+		"admin":    admin,
+		"max_size": dto.MaxSize,
+		"quota":    dto.Quota,
+	}).Warn("attachment limits are changed")
+	return policy, nil
+}
+
+// CreateAttachment encrypts the file of r with a new key into the blob store and adds it
+// to the item. Files beyond the max size or the rest of the quota of the user are
+// rejected while they are read.
+func CreateAttachment(s storage.Store, userID uint, itemType string, itemID uint, name, contentType string, r io.Reader, schema string) (*model.Attachment, error) {
+	defer tracing.Start("app.CreateAttachment").End()
+
+	usage, err := AttachmentUsage(s, userID, schema)
+	if err != nil {
+		return nil, err
+	}
+	limit, errLimit := usage.MaxSize, ErrAttachmentTooLarge
+	if limit <= 0 {
+		limit = math.MaxInt64 - 1
+	}
+	if usage.Quota > 0 && usage.Quota-usage.Used < limit {
+		limit, errLimit = usage.Quota-usage.Used, ErrAttachmentQuota
+	}
+	if limit < 0 {
+		return nil, ErrAttachmentQuota
+	}
+
+	store, err := blob.FromConfig()
+	if err != nil {
+		return nil, err
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	attachment := &model.Attachment{
+		ItemType:    itemType,
+		ItemID:      itemID,
+		Name:        attachmentName(name),
+		ContentType: attachmentContentType(contentType),
+		BlobKey:     attachmentPrefix + schema + "/" + uuid.NewV4().String(),
+		Key:         base64.StdEncoding.EncodeToString(key),
+	}
+
+	// The content is encrypted while the store reads it
+	hash := sha256.New()
+	content := &limitedReader{r: io.TeeReader(r, hash), n: limit, err: errLimit}
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(sealAttachment(pw, content, key))
+	}()
+	err = store.Put(attachment.BlobKey, pr)
+	pr.CloseWithError(err)
+	if err != nil {
+		if content.exceeded {
+			return nil, errLimit
+		}
+		return nil, err
+	}
+
+	attachment.Size = limit - content.n
+	attachment.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	if err := s.Attachments().Create(attachment, schema); err != nil {
+		store.Delete(attachment.BlobKey)
+		return nil, err
+	}
+	return attachment, nil
+}
+
+// FindAttachments returns the attachments of the item
+func FindAttachments(s storage.Store, itemType string, itemID uint, schema string) ([]model.AttachmentDTO, error) {
+	attachments, err := s.Attachments().FindByItem(itemType, itemID, schema)
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]model.AttachmentDTO, len(attachments))
+	for i := range attachments {
+		dtos[i] = *model.ToAttachmentDTO(&attachments[i])
+	}
+	return dtos, nil
+}
+
+// FindAttachment returns the attachment with the id of the item
+func FindAttachment(s storage.Store, itemType string, itemID, id uint, schema string) (*model.Attachment, error) {
+	attachment, err := s.Attachments().FindByID(id, schema)
+	if errors.Is(err, gorm.ErrRecordNotFound) || err == nil && (attachment.ItemType != itemType || attachment.ItemID != itemID) {
+		return nil, ErrAttachmentNotFound
+	}
+	return attachment, err
+}
+
+// OpenAttachment writes the decrypted content of the attachment to w. Each chunk is
+// checked before it is written, but a damaged file stops in the middle.
+func OpenAttachment(w io.Writer, attachment *model.Attachment) error {
+	defer tracing.Start("app.OpenAttachment").End()
+
+	key, err := base64.StdEncoding.DecodeString(attachment.Key)
+	if err != nil {
+		return errAttachmentDamaged
+	}
+	store, err := blob.FromConfig()
+	if err != nil {
+		return err
+	}
+	r, err := store.Get(attachment.BlobKey)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	return openAttachment(w, r, key)
+}
+
+// DeleteAttachment deletes the attachment and its content
+func DeleteAttachment(s storage.Store, attachment *model.Attachment, schema string) error {
+	if err := s.Attachments().Delete(attachment.ID, schema); err != nil {
+		return err
+	}
+	deleteAttachmentBlob(attachment)
+	return nil
+}
+
+// deleteItemAttachments deletes the attachments of a purged item
+func deleteItemAttachments(s storage.Store, itemType string, itemID uint, schema string) error {
+	attachments, err := s.Attachments().FindByItem(itemType, itemID, schema)
+	if err != nil {
+		return err
+	}
+	for i := range attachments {
+		if err := DeleteAttachment(s, &attachments[i], schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeOrphanAttachments deletes the attachments of the items which were purged from the
+// trash of the vault
+func purgeOrphanAttachments(s storage.Store, schema string) error {
+	for _, itemType := range ItemTypes {
+		attachments, err := s.Attachments().FindOrphans(itemType, itemTable(itemType, schema), schema)
+		if err != nil {
+			return err
+		}
+		for i := range attachments {
+			if err := DeleteAttachment(s, &attachments[i], schema); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// deleteVaultAttachments deletes the content of all attachments of the vault before its
+// schema is dropped
+func deleteVaultAttachments(schema string) {
+	store, err := blob.FromConfig()
+	var objects []blob.Object
+	if err == nil {
+		objects, err = store.List(attachmentPrefix + schema + "/")
+	}
+	if err != nil {
+		log.WithFields(log.Fields{"schema": schema, "error": err.Error()}).Error("attachments of the vault can't be listed")
+		return
+	}
+	for _, object := range objects {
+		deleteAttachmentBlob(&model.Attachment{BlobKey: object.Key})
+	}
+}
+
+// deleteAttachmentBlob deletes the content of the attachment, a content which is left is
+// logged, it can't be read without the key of the attachment
+func deleteAttachmentBlob(attachment *model.Attachment) {
+	store, err := blob.FromConfig()
+	if err == nil {
+		err = store.Delete(attachment.BlobKey)
+	}
+	if err != nil {
+		log.WithFields(log.Fields{"blob": attachment.BlobKey, "error": err.Error()}).Error("attachment content can't be deleted")
+	}
+}
+
+// attachmentName returns the base name of the file without control characters
+func attachmentName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filepath.Base(strings.Replace(name, `\`, "/", -1)))
+	if runes := []rune(name); len(runes) > maxAttachmentName {
+		name = string(runes[:maxAttachmentName])
+	}
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	return name
+}
+
+// attachmentContentType returns the media type, application/octet-stream when it isn't one
+func attachmentContentType(contentType string) string {
+	if _, _, err := mime.ParseMediaType(contentType); err != nil {
+		return "application/octet-stream"
+	}
+	return contentType
+}
+
+// limitedReader reads n bytes at most, more fail with err
+type limitedReader struct {
+	r        io.Reader
+	n        int64
+	err      error
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > l.n+1 {
+		p = p[:l.n+1]
+	}
+	n, err := l.r.Read(p)
+	if int64(n) > l.n {
+		l.exceeded = true
+		return 0, l.err
+	}
+	l.n -= int64(n)
+	return n, err
+}
+
+// sealAttachment encrypts r to w with AES-256-GCM in chunks of attachmentChunk. The nonce
+// of a chunk is its number and a flag of the last chunk, so chunks can't be reordered,
+// dropped or cut off at the end.
+func sealAttachment(w io.Writer, r io.Reader, key []byte) error {
+	gcm, err := attachmentCipher(key)
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(attachmentMagic); err != nil {
+		return err
+	}
+
+	br := bufio.NewReaderSize(r, attachmentChunk)
+	buf := make([]byte, attachmentChunk)
+	for chunk := uint64(0); ; chunk++ {
+		n, err := io.ReadFull(br, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last, err := lastChunk(br, err)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(gcm.Seal(nil, chunkNonce(chunk, last), buf[:n], nil)); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// openAttachment decrypts the content of sealAttachment from r to w
+func openAttachment(w io.Writer, r io.Reader, key []byte) error {
+	gcm, err := attachmentCipher(key)
+	if err != nil {
+		return err
+	}
+	magic := make([]byte, len(attachmentMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || string(magic) != string(attachmentMagic) {
+		return errAttachmentDamaged
+	}
+
+	size := attachmentChunk + gcm.Overhead()
+	br := bufio.NewReaderSize(r, size)
+	buf := make([]byte, size)
+	for chunk := uint64(0); ; chunk++ {
+		n, err := io.ReadFull(br, buf)
+		if err == io.EOF {
+			return errAttachmentDamaged
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last, err := lastChunk(br, err)
+		if err != nil {
+			return err
+		}
+		plain, err := gcm.Open(buf[:0], chunkNonce(chunk, last), buf[:n], nil)
+		if err != nil {
+			return errAttachmentDamaged
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// lastChunk is true when the chunk which was read with the error of io.ReadFull is the
+// last one of the reader
+func lastChunk(br *bufio.Reader, readErr error) (bool, error) {
+	if readErr != nil {
+		return true, nil
+	}
+	_, err := br.Peek(1)
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
+
+func chunkNonce(chunk uint64, last bool) []byte {
+	nonce := make([]byte, 12)
+	binary.BigEndian.PutUint64(nonce, chunk)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+func attachmentCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachmentCipher(t *testing.T) {
+	key := make([]byte, 32)
+	rand.Read(key)
+
+	for _, size := range []int{0, 1, attachmentChunk - 1, attachmentChunk, attachmentChunk + 1, 3 * attachmentChunk} {
+		content := make([]byte, size)
+		rand.Read(content)
+
+		var sealed bytes.Buffer
+		assert.NoError(t, sealAttachment(&sealed, bytes.NewReader(content), key))
+		var opened bytes.Buffer
+		assert.NoError(t, openAttachment(&opened, bytes.NewReader(sealed.Bytes()), key), "size %d", size)
+		assert.True(t, bytes.Equal(content, opened.Bytes()), "size %d", size)
+	}
+
+	content := make([]byte, 2*attachmentChunk)
+	var sealed bytes.Buffer
+	assert.NoError(t, sealAttachment(&sealed, bytes.NewReader(content), key))
+	data := sealed.Bytes()
+
+	// Files cut off after a chunk are missing their last chunk
+	cut := len(attachmentMagic) + attachmentChunk + 16
+	assert.Equal(t, errAttachmentDamaged, openAttachment(new(bytes.Buffer), bytes.NewReader(data[:cut]), key))
+
+	other := make([]byte, 32)
+	rand.Read(other)
+	assert.Equal(t, errAttachmentDamaged, openAttachment(new(bytes.Buffer), bytes.NewReader(data), other))
+
+	data[len(data)-1] ^= 1
+	assert.Equal(t, errAttachmentDamaged, openAttachment(new(bytes.Buffer), bytes.NewReader(data), key))
+	assert.Equal(t, errAttachmentDamaged, openAttachment(new(bytes.Buffer), bytes.NewReader([]byte("PWATT")), key))
+}
+
+func TestAttachmentName(t *testing.T) {
+	assert.Equal(t, "codes.txt", attachmentName("codes.txt"))
+	assert.Equal(t, "passwd", attachmentName("../../etc/passwd"))
+	assert.Equal(t, "codes.txt", attachmentName(`C:\Users\me\codes.txt`))
+	assert.Equal(t, "codes.txt", attachmentName("codes\n.txt"))
+	assert.Equal(t, "attachment", attachmentName(""))
+	assert.Equal(t, "attachment", attachmentName("/"))
+	assert.Len(t, []rune(attachmentName(string(bytes.Repeat([]byte("ü"), 300)))), maxAttachmentName)
+}
+
+func TestLimitedReader(t *testing.T) {
+	var buf bytes.Buffer
+	r := &limitedReader{r: bytes.NewReader(make([]byte, 10)), n: 10, err: ErrAttachmentTooLarge}
+	_, err := buf.ReadFrom(r)
+	assert.NoError(t, err)
+	assert.False(t, r.exceeded)
+
+	r = &limitedReader{r: bytes.NewReader(make([]byte, 11)), n: 10, err: ErrAttachmentTooLarge}
+	_, err = buf.ReadFrom(r)
+	assert.Equal(t, ErrAttachmentTooLarge, err)
+	assert.True(t, r.exceeded)
+}
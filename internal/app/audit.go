@@ -0,0 +1,254 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// AuditHook writes the log entries with an event field, like canary_read or
+// access_denied, to the audit trail. Each entry is chained to the previous one.
+type AuditHook struct {
+	store storage.Store
+	mu    sync.Mutex
+}
+
+// NewAuditHook returns the hook which keeps the audit trail in the store
+func NewAuditHook(s storage.Store) *AuditHook {
+	return &AuditHook{store: s}
+}
+
+// Levels ...
+func (h *AuditHook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire ...
+func (h *AuditHook) Fire(entry *log.Entry) error {
+	event, ok := entry.Data["event"].(string)
+	if !ok {
+		return nil
+	}
+
+	fields := map[string]string{}
+	for key, value := range entry.Data {
+		if key != "event" {
+			fields[key] = fmt.Sprint(value)
+		}
+	}
+	encoded, _ := json.Marshal(fields)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	// The logger is locked while hooks run, errors can't be logged
+	if _, err := AppendAuditLog(h.store, &model.AuditLog{
+		CreatedAt: entry.Time,
+		Level:     entry.Level.String(),
+		Event:     event,
+		Message:   entry.Message,
+		Fields:    string(encoded),
+	}); err != nil {
+		fmt.Fprintf(os.Stderr, "audit log entry %s couldn't be saved: %v\n", event, err)
+	}
+	return nil
+}
+
+// AppendAuditLog chains the entry to the last one of the trail and saves it.
+// Callers have to serialize, AuditHook does it for the server.
+func AppendAuditLog(s storage.Store, entry *model.AuditLog) (*model.AuditLog, error) {
+	last, err := s.AuditLogs().Last()
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		entry.PrevHash = last.Hash
+	} else if checkpoint, err := s.AuditLogs().LastCheckpoint(); err == nil {
+		// All entries are purged, the chain goes on from the anchor
+		entry.PrevHash = checkpoint.Hash
+	}
+
+	// Databases don't keep nanoseconds, the hash has to match the saved time
+	entry.CreatedAt = entry.CreatedAt.UTC().Truncate(time.Microsecond)
+	entry.Hash = auditHash(entry)
+	return s.AuditLogs().Create(entry)
+}
+
+// CreateAuditCheckpoint signs the hash of the last entry of the trail.
+// Nothing is saved when there is no new entry since the last checkpoint.
+func CreateAuditCheckpoint(s storage.Store) (*model.AuditCheckpoint, error) {
+	last, err := s.AuditLogs().Last()
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := s.AuditLogs().LastCheckpoint()
+	if err == nil && previous.AuditLogID == last.ID {
+		return previous, nil
+	}
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	checkpoint := &model.AuditCheckpoint{AuditLogID: last.ID, Hash: last.Hash}
+	checkpoint.Signature = auditSignature(checkpoint)
+	return s.AuditLogs().CreateCheckpoint(checkpoint)
+}
+
+// StartAuditCheckpointJob saves a checkpoint every audit.checkpointPeriod
+func StartAuditCheckpointJob(s storage.Store) error {
+	period, err := parsePeriod(viper.GetString("audit.checkpointPeriod"))
+	if err != nil {
+		return fmt.Errorf("audit.checkpointPeriod: %w", err)
+	}
+
+	every(period, func(time.Time) {
+		if _, err := CreateAuditCheckpoint(s); err != nil {
+			log.WithError(err).Error("audit checkpoint couldn't be saved")
+		}
+	})
+	return nil
+}
+
+// VerifyAuditLog recomputes the chain of the audit trail and checks the checkpoints.
+// A changed or deleted entry breaks the chain, a truncated trail misses checkpointed entries.
+// Entries after the last checkpoint can be truncated unnoticed.
+func VerifyAuditLog(s storage.Store) (*model.AuditVerifyDTO, error) {
+	entries, err := s.AuditLogs().All()
+	if err != nil {
+		return nil, err
+	}
+	checkpoints, err := s.AuditLogs().Checkpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.AuditVerifyDTO{Entries: len(entries), Checkpoints: len(checkpoints), Problems: []string{}}
+	hashes := make(map[uint]string, len(entries))
+	prevHash := ""
+
+	// The chain starts from the newest anchor when old entries are purged
+	if anchor := auditAnchor(checkpoints); anchor != nil {
+		report.PurgedUntil = anchor.AuditLogID
+		hashes[anchor.AuditLogID] = anchor.Hash
+		prevHash = anchor.Hash
+	}
+
+	for i := range entries {
+		entry := &entries[i]
+		if entry.ID <= report.PurgedUntil {
+			report.Entries--
+			continue
+		}
+		entry.CreatedAt = entry.CreatedAt.UTC()
+		if entry.PrevHash != prevHash {
+			report.Problems = append(report.Problems, fmt.Sprintf("entry %d doesn't follow the previous entry", entry.ID))
+		}
+		if entry.Hash != auditHash(entry) {
+			report.Problems = append(report.Problems, fmt.Sprintf("entry %d is modified", entry.ID))
+		}
+		hashes[entry.ID] = entry.Hash
+		prevHash = entry.Hash
+		report.LastID = entry.ID
+	}
+
+	for i := range checkpoints {
+		checkpoint := &checkpoints[i]
+		if checkpoint.AuditLogID < report.PurgedUntil {
+			continue
+		}
+		if !hmac.Equal([]byte(checkpoint.Signature), []byte(auditSignature(checkpoint))) {
+			report.Problems = append(report.Problems, fmt.Sprintf("checkpoint %d has a wrong signature", checkpoint.ID))
+			continue
+		}
+		hash, ok := hashes[checkpoint.AuditLogID]
+		switch {
+		case !ok:
+			report.Problems = append(report.Problems, fmt.Sprintf("entry %d of checkpoint %d is missing, the trail is truncated", checkpoint.AuditLogID, checkpoint.ID))
+		case hash != checkpoint.Hash:
+			report.Problems = append(report.Problems, fmt.Sprintf("entry %d doesn't match checkpoint %d", checkpoint.AuditLogID, checkpoint.ID))
+		}
+	}
+
+	report.Valid = len(report.Problems) == 0
+	return report, nil
+}
+
+// auditAnchor returns the anchor of the newest purge, anchors with a wrong signature are ignored
+func auditAnchor(checkpoints []model.AuditCheckpoint) *model.AuditCheckpoint {
+	var anchor *model.AuditCheckpoint
+	for i := range checkpoints {
+		checkpoint := &checkpoints[i]
+		if !checkpoint.Anchor || !hmac.Equal([]byte(checkpoint.Signature), []byte(auditSignature(checkpoint))) {
+			continue
+		}
+		if anchor == nil || checkpoint.AuditLogID > anchor.AuditLogID {
+			anchor = checkpoint
+		}
+	}
+	return anchor
+}
+
+// purgeAuditLog deletes the entries created before the time and anchors the chain at the last
+// of them. It returns the count of the entries, a dry run only counts them.
+func purgeAuditLog(s storage.Store, before time.Time, dryRun bool) (int, error) {
+	last, err := s.AuditLogs().LastBefore(before)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := s.AuditLogs().CountUntil(last.ID)
+	if err != nil || dryRun {
+		return count, err
+	}
+
+	anchor := &model.AuditCheckpoint{AuditLogID: last.ID, Hash: last.Hash, Anchor: true}
+	anchor.Signature = auditSignature(anchor)
+	if _, err := s.AuditLogs().CreateCheckpoint(anchor); err != nil {
+		return 0, err
+	}
+	return count, s.AuditLogs().DeleteUntil(last.ID)
+}
+
+// auditHash is the hash of the entry together with the hash of the previous entry
+func auditHash(entry *model.AuditLog) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		entry.PrevHash,
+		entry.CreatedAt.UTC().Format(time.RFC3339Nano),
+		entry.Level,
+		entry.Event,
+		entry.Message,
+		entry.Fields,
+	}, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// auditSignature signs the checkpoint with a key derived from server.secret
+func auditSignature(checkpoint *model.AuditCheckpoint) string {
+	key := sha256.Sum256([]byte("audit-checkpoint:" + viper.GetString("server.secret")))
+	mac := hmac.New(sha256.New, key[:])
+	fmt.Fprintf(mac, "%d\n%s", checkpoint.AuditLogID, checkpoint.Hash)
+	if checkpoint.Anchor {
+		fmt.Fprint(mac, "\nanchor")
+	}
+	return hex.EncodeToString(mac.Sum(nil))
+}
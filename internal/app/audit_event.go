@@ -0,0 +1,159 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// Actions of the audit events of a vault
+const (
+	AuditCreate = "create"
+	AuditRead   = "read"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+	AuditSignin = "signin"
+)
+
+// Results of the audit events
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditActions lists the actions the audit log can be queried by
+var AuditActions = []string{AuditCreate, AuditRead, AuditUpdate, AuditDelete, AuditSignin}
+
+// maxUserAgent is the size of the user agent column, longer ones are cut
+const maxUserAgent = 255
+
+var (
+	errAuditTime   = errors.New("since and until should be dates like 2006-01-02 or RFC 3339 times")
+	errAuditAction = errors.New("action should be create, read, update, delete or signin")
+)
+
+// NewAuditEvent starts the audit event of a request, its result is set once it's answered
+func NewAuditEvent(r *http.Request, actor, action, itemType string) *model.AuditEvent {
+	event := &model.AuditEvent{
+		Actor:     actor,
+		Action:    action,
+		ItemType:  itemType,
+		UserAgent: r.UserAgent(),
+	}
+	if ip := ClientIP(r); ip != nil {
+		event.IP = ip.String()
+	}
+	if len(event.UserAgent) > maxUserAgent {
+		event.UserAgent = event.UserAgent[:maxUserAgent]
+	}
+	return event
+}
+
+// AuditActor names who sent the authenticated request, a personal access token or its user
+func AuditActor(ctx context.Context) string {
+	if id, ok := ctx.Value("accessToken").(uint); ok {
+		return fmt.Sprintf("token:%d", id)
+	}
+	id, _ := ctx.Value("id").(float64)
+	return fmt.Sprintf("user:%v", id)
+}
+
+// WithAuditEvent adds the audit event of the request to its context, so the handler can
+// complete it with AuditItem
+func WithAuditEvent(r *http.Request, event *model.AuditEvent) *http.Request {
+	return r.WithContext(context.WithValue(r.Context(), "audit", event))
+}
+
+// AuditItem sets the id of the item a request created, the path only has it for the others
+func AuditItem(r *http.Request, item interface{}) {
+	if event, ok := r.Context().Value("audit").(*model.AuditEvent); ok {
+		event.ItemID = uint(reflect.ValueOf(item).Elem().FieldByName("ID").Uint())
+	}
+}
+
+// RecordAuditEvent appends the event to the audit log of the schema. A failure is logged,
+// the request it audits isn't undone. Read-only servers only log the event.
+func RecordAuditEvent(s storage.Store, event *model.AuditEvent, schema string) {
+	if ReadOnly() {
+		log.WithFields(log.Fields{
+			"schema":    schema,
+			"actor":     event.Actor,
+			"action":    event.Action,
+			"item_type": event.ItemType,
+			"item_id":   event.ItemID,
+			"result":    event.Result,
+		}).Info("audit event of a read-only server")
+		return
+	}
+	if _, err := s.AuditEvents().Create(event, schema); err != nil {
+		log.WithError(err).Error("audit event couldn't be saved")
+	}
+}
+
+// RecordSignin records a sign in of the user with its result. Sign ins with the duress
+// password go to the audit log of the decoy vault.
+func RecordSignin(s storage.Store, r *http.Request, user *model.User, duress bool, result string) {
+	event := NewAuditEvent(r, fmt.Sprintf("user:%d", user.ID), AuditSignin, "")
+	event.Result = result
+	schema := user.Schema
+	if duress {
+		schema = DecoySchema(schema)
+	}
+	RecordAuditEvent(s, event, schema)
+}
+
+// AuditMachineReads records the reads of the items by the machine account in the audit
+// log of its user
+func AuditMachineReads(s storage.Store, r *http.Request, account *model.MachineAccount, items []interface{}) {
+	user, err := s.Users().FindByID(account.UserID)
+	if err != nil {
+		log.WithError(err).Error("audit event couldn't be saved")
+		return
+	}
+	for _, item := range items {
+		event := NewAuditEvent(r, "machine:"+account.UUID.String(), AuditRead, ItemTypeOf(item))
+		event.ItemID = uint(reflect.ValueOf(item).Elem().FieldByName("ID").Uint())
+		event.Result = AuditSuccess
+		RecordAuditEvent(s, event, user.Schema)
+	}
+}
+
+// ParseAuditFilter parses the since, until and action query of the audit log. Dates
+// without a time cover the whole day, so until=2006-01-02 includes that day.
+func ParseAuditFilter(since, until, action string) (*model.AuditEventFilter, error) {
+	filter := &model.AuditEventFilter{Action: action}
+	if action != "" && FindIndex(AuditActions, action) < 0 {
+		return nil, errAuditAction
+	}
+
+	var err error
+	if filter.Since, err = parseAuditTime(since, 0); err != nil {
+		return nil, err
+	}
+	if filter.Until, err = parseAuditTime(until, 24*time.Hour); err != nil {
+		return nil, err
+	}
+	return filter, nil
+}
+
+// parseAuditTime parses an RFC 3339 time or a date, day is added to dates
+func parseAuditTime(value string, day time.Duration) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, errAuditTime
+	}
+	return t.Add(day), nil
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestParseAuditFilter(t *testing.T) {
+	filter, err := ParseAuditFilter("2020-05-01", "2020-05-31", AuditSignin)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2020, 5, 1, 0, 0, 0, 0, time.UTC), filter.Since)
+	assert.Equal(t, time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC), filter.Until)
+	assert.Equal(t, AuditSignin, filter.Action)
+
+	// Times are used as they are
+	filter, err = ParseAuditFilter("", "2020-05-31T12:00:00Z", "")
+	assert.NoError(t, err)
+	assert.True(t, filter.Since.IsZero())
+	assert.Equal(t, time.Date(2020, 5, 31, 12, 0, 0, 0, time.UTC), filter.Until)
+
+	_, err = ParseAuditFilter("yesterday", "", "")
+	assert.Equal(t, errAuditTime, err)
+	_, err = ParseAuditFilter("", "", "export")
+	assert.Equal(t, errAuditAction, err)
+}
+
+func TestExportAuditEvents(t *testing.T) {
+	full := make([]model.AuditEvent, auditExportBatch)
+	for i := range full {
+		full[i] = model.AuditEvent{ID: uint(i + 1), Action: AuditRead, Result: AuditSuccess}
+	}
+
+	mocks := storagetest.NewMocks()
+	mocks.Users.On("All").Return([]model.User{{ID: 1, Schema: "user1"}, {ID: 2}}, nil)
+	mocks.AuditEvents.On("FindAfter", mock.Anything, uint(0), "user1").Return(full, nil)
+	mocks.AuditEvents.On("FindAfter", mock.Anything, uint(auditExportBatch), "user1").Return([]model.AuditEvent{{ID: 501, Action: AuditDelete, ItemType: NoteItem, ItemID: 4}}, nil)
+
+	// Full batches are followed by the next one, users without a schema are skipped
+	var out bytes.Buffer
+	assert.NoError(t, ExportAuditEvents(mocks.Store, &out, AuditExportCSV, &model.AuditEventFilter{}))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, auditExportBatch+2)
+	assert.Equal(t, "1,501,0001-01-01T00:00:00Z,,delete,notes,4,,,", lines[len(lines)-1])
+	mocks.AssertExpectations(t)
+
+	assert.Equal(t, errAuditExportFormat, ExportAuditEvents(mocks.Store, &out, "xml", &model.AuditEventFilter{}))
+}
@@ -0,0 +1,123 @@
+package app
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Formats of the audit log export
+const (
+	AuditExportCSV   = "csv"
+	AuditExportJSONL = "jsonl"
+)
+
+// auditExportBatch is the number of events read from a schema at once
+const auditExportBatch = 500
+
+var (
+	errAuditExportFormat = errors.New("format should be csv or jsonl")
+
+	auditExportColumns = []string{"user_id", "id", "created_at", "actor", "action", "item_type", "item_id", "ip", "user_agent", "result"}
+)
+
+// auditExportRow is an exported event with the user of its vault
+type auditExportRow struct {
+	UserID uint `json:"user_id"`
+	model.AuditEvent
+}
+
+// CheckAuditExportFormat checks the format before the export starts writing
+func CheckAuditExportFormat(format string) error {
+	if format != AuditExportCSV && format != AuditExportJSONL {
+		return errAuditExportFormat
+	}
+	return nil
+}
+
+// ExportAuditEvents writes the audit events of every vault matching the filter to w,
+// user by user and oldest first. The events are read in batches and w is flushed after
+// each one, so large logs stream without being held in memory. Decoy vaults aren't
+// exported, they would tell which users have a duress password.
+func ExportAuditEvents(s storage.Store, w io.Writer, format string, filter *model.AuditEventFilter) error {
+	defer tracing.Start("app.ExportAuditEvents").End()
+
+	if err := CheckAuditExportFormat(format); err != nil {
+		return err
+	}
+	users, err := s.Users().All()
+	if err != nil {
+		return err
+	}
+
+	rows := csv.NewWriter(w)
+	lines := json.NewEncoder(w)
+	if format == AuditExportCSV {
+		if err := rows.Write(auditExportColumns); err != nil {
+			return err
+		}
+	}
+
+	batch := *filter
+	batch.Limit, batch.Offset = auditExportBatch, 0
+	for _, user := range users {
+		if user.Schema == "" {
+			continue
+		}
+		for afterID := uint(0); ; {
+			events, err := s.AuditEvents().FindAfter(&batch, afterID, user.Schema)
+			if err != nil {
+				return err
+			}
+			for i := range events {
+				if format == AuditExportJSONL {
+					err = lines.Encode(auditExportRow{UserID: user.ID, AuditEvent: events[i]})
+				} else {
+					err = rows.Write(auditExportRecord(user.ID, &events[i]))
+				}
+				if err != nil {
+					return err
+				}
+			}
+
+			rows.Flush()
+			if err := rows.Error(); err != nil {
+				return err
+			}
+			if f, ok := w.(interface{ Flush() }); ok {
+				f.Flush()
+			}
+			if len(events) < auditExportBatch {
+				break
+			}
+			afterID = events[len(events)-1].ID
+		}
+	}
+	return nil
+}
+
+func auditExportRecord(userID uint, event *model.AuditEvent) []string {
+	itemID := ""
+	if event.ItemID != 0 {
+		itemID = strconv.FormatUint(uint64(event.ItemID), 10)
+	}
+	return []string{
+		strconv.FormatUint(uint64(userID), 10),
+		strconv.FormatUint(uint64(event.ID), 10),
+		event.CreatedAt.UTC().Format(time.RFC3339),
+		event.Actor,
+		event.Action,
+		event.ItemType,
+		itemID,
+		event.IP,
+		event.UserAgent,
+		event.Result,
+	}
+}
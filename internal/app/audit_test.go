@@ -0,0 +1,114 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// auditChain returns a valid trail of n entries
+func auditChain(n int) []model.AuditLog {
+	entries := make([]model.AuditLog, n)
+	prevHash := ""
+	for i := range entries {
+		entries[i] = model.AuditLog{
+			ID:        uint(i + 1),
+			CreatedAt: time.Date(2020, 6, 1, 12, i, 0, 0, time.UTC),
+			Level:     "warning",
+			Event:     "access_denied",
+			Message:   "Access from KP is blocked",
+			Fields:    `{"user_id":"1"}`,
+			PrevHash:  prevHash,
+		}
+		entries[i].Hash = auditHash(&entries[i])
+		prevHash = entries[i].Hash
+	}
+	return entries
+}
+
+func checkpointOf(id uint, entry *model.AuditLog) model.AuditCheckpoint {
+	checkpoint := model.AuditCheckpoint{ID: id, AuditLogID: entry.ID, Hash: entry.Hash}
+	checkpoint.Signature = auditSignature(&checkpoint)
+	return checkpoint
+}
+
+func TestVerifyAuditLog(t *testing.T) {
+	entries := auditChain(4)
+	checkpoints := []model.AuditCheckpoint{checkpointOf(1, &entries[3])}
+
+	mocks := storagetest.NewMocks()
+	mocks.AuditLogs.On("All").Return(entries, nil)
+	mocks.AuditLogs.On("Checkpoints").Return(checkpoints, nil)
+
+	report, err := VerifyAuditLog(mocks.Store)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid)
+	assert.Equal(t, 4, report.Entries)
+	assert.Equal(t, uint(4), report.LastID)
+}
+
+func TestVerifyAuditLogTampered(t *testing.T) {
+	entries := auditChain(4)
+	checkpoints := []model.AuditCheckpoint{checkpointOf(1, &entries[3])}
+
+	modified := append([]model.AuditLog{}, entries...)
+	modified[1].Message = "nothing happened"
+	deleted := append(append([]model.AuditLog{}, entries[:1]...), entries[2:]...)
+	truncated := entries[:3]
+	forged := append([]model.AuditLog{}, entries...)
+	forgedCheckpoints := []model.AuditCheckpoint{checkpoints[0]}
+	forgedCheckpoints[0].Hash = entries[2].Hash
+
+	cases := map[string]struct {
+		entries     []model.AuditLog
+		checkpoints []model.AuditCheckpoint
+		problem     string
+	}{
+		"modified":  {modified, checkpoints, "entry 2 is modified"},
+		"deleted":   {deleted, checkpoints, "entry 3 doesn't follow the previous entry"},
+		"truncated": {truncated, checkpoints, "entry 4 of checkpoint 1 is missing, the trail is truncated"},
+		"forged":    {forged, forgedCheckpoints, "checkpoint 1 has a wrong signature"},
+	}
+	for name, c := range cases {
+		mocks := storagetest.NewMocks()
+		mocks.AuditLogs.On("All").Return(c.entries, nil)
+		mocks.AuditLogs.On("Checkpoints").Return(c.checkpoints, nil)
+
+		report, err := VerifyAuditLog(mocks.Store)
+		assert.NoError(t, err, name)
+		assert.False(t, report.Valid, name)
+		assert.Contains(t, report.Problems, c.problem, name)
+	}
+}
+
+func TestVerifyAuditLogPurged(t *testing.T) {
+	entries := auditChain(4)
+	anchor := model.AuditCheckpoint{ID: 2, AuditLogID: 2, Hash: entries[1].Hash, Anchor: true}
+	anchor.Signature = auditSignature(&anchor)
+	checkpoints := []model.AuditCheckpoint{anchor, checkpointOf(3, &entries[3])}
+
+	mocks := storagetest.NewMocks()
+	mocks.AuditLogs.On("All").Return(entries[2:], nil)
+	mocks.AuditLogs.On("Checkpoints").Return(checkpoints, nil)
+
+	report, err := VerifyAuditLog(mocks.Store)
+	assert.NoError(t, err)
+	assert.True(t, report.Valid, report.Problems)
+	assert.Equal(t, uint(2), report.PurgedUntil)
+	assert.Equal(t, 2, report.Entries)
+
+	// An anchor can't be made out of a plain checkpoint, its signature doesn't match
+	forged := checkpointOf(2, &entries[1])
+	forged.Anchor = true
+	mocks = storagetest.NewMocks()
+	mocks.AuditLogs.On("All").Return(entries[2:], nil)
+	mocks.AuditLogs.On("Checkpoints").Return([]model.AuditCheckpoint{forged, checkpointOf(3, &entries[3])}, nil)
+
+	report, err = VerifyAuditLog(mocks.Store)
+	assert.NoError(t, err)
+	assert.False(t, report.Valid)
+	assert.Contains(t, report.Problems, "entry 3 doesn't follow the previous entry")
+}
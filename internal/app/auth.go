@@ -12,6 +12,9 @@ import (
 	"github.com/spf13/viper"
 )
 
+// transmissionKeyBytes is the random bytes of a transmission key
+const transmissionKeyBytes = 32
+
 var (
 	//ErrExpiredToken represents message for expired token
 	ErrExpiredToken = fmt.Errorf("Token expired or invalid")
@@ -19,8 +22,21 @@ var (
 	ErrUnauthorized = fmt.Errorf("Unauthorized")
 )
 
+//Session is the sign in which the access and refresh tokens carry on
+type Session struct {
+	Start       time.Time
+	Duress      bool // signed in with the duress password, the decoy vault is open
+	TwoFactor   bool // a second factor was verified
+	SecurityKey bool // the second factor was a security key
+}
+
 //CreateToken ...
 func CreateToken(user *model.User) (*model.TokenDetailsDTO, error) {
+	return CreateSessionToken(user, &Session{Start: time.Now()})
+}
+
+//CreateSessionToken creates the tokens of the session
+func CreateSessionToken(user *model.User, session *Session) (*model.TokenDetailsDTO, error) {
 
 	var err error
 	accessSecret := viper.GetString("server.secret")
@@ -39,12 +55,16 @@ func CreateToken(user *model.User) (*model.TokenDetailsDTO, error) {
 	atClaims := jwt.MapClaims{}
 
 	atClaims["authorized"] = false
-	if user.Role == "Admin" {
+	if user.Role == "Admin" && !session.Duress {
 		atClaims["authorized"] = true
 	}
 	atClaims["user_id"] = user.ID
 	atClaims["exp"] = td.AtExpiresTime.Unix()
 	atClaims["uuid"] = td.AtUUID.String()
+	if user.Locale != "" {
+		atClaims["locale"] = user.Locale
+	}
+	session.addClaims(atClaims)
 	at := jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims)
 	td.AccessToken, err = at.SignedString([]byte(accessSecret))
 	if err != nil {
@@ -56,6 +76,7 @@ func CreateToken(user *model.User) (*model.TokenDetailsDTO, error) {
 	rtClaims["user_id"] = user.ID
 	rtClaims["exp"] = td.RtExpiresTime.Unix()
 	rtClaims["uuid"] = td.RtUUID.String()
+	session.addClaims(rtClaims)
 
 	rt := jwt.NewWithClaims(jwt.SigningMethodHS256, rtClaims)
 	td.RefreshToken, err = rt.SignedString([]byte(accessSecret))
@@ -63,15 +84,48 @@ func CreateToken(user *model.User) (*model.TokenDetailsDTO, error) {
 		return nil, err
 	}
 
-	generatedPass, err := GenerateSecureKey(viper.GetInt("server.generatedPasswordLength"))
+	// Each sign in and refresh gets its own key, it's kept with the access token only
+	td.TransmissionKey, err = NewTransmissionKey()
 	if err != nil {
 		return nil, err
 	}
-	td.TransmissionKey = generatedPass
 
 	return td, nil
 }
 
+// NewTransmissionKey generates the key the payloads of a session or personal access token
+// are encrypted with. Its length doesn't follow server.generatedPasswordLength.
+func NewTransmissionKey() (string, error) {
+	return GenerateSecureKey(transmissionKeyBytes)
+}
+
+//SessionOf returns the session of the token claims,
+//its start is zero for tokens created before the claim existed
+func SessionOf(claims jwt.MapClaims) *Session {
+	session := &Session{}
+	if start, ok := claims["session_start"].(float64); ok {
+		session.Start = time.Unix(int64(start), 0)
+	}
+	session.Duress, _ = claims["duress"].(bool)
+	session.TwoFactor, _ = claims["two_factor"].(bool)
+	session.SecurityKey, _ = claims["security_key"].(bool)
+	return session
+}
+
+// addClaims adds the session to the token claims, SessionOf reads them back
+func (session *Session) addClaims(claims jwt.MapClaims) {
+	claims["session_start"] = session.Start.Unix()
+	if session.Duress {
+		claims["duress"] = true
+	}
+	if session.TwoFactor {
+		claims["two_factor"] = true
+	}
+	if session.SecurityKey {
+		claims["security_key"] = true
+	}
+}
+
 //TokenValid ...
 func TokenValid(bearerToken string) (*jwt.Token, error) {
 	token, err := verifyToken(bearerToken)
@@ -1,13 +1,26 @@
 package app
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/passwall/passwall-server/internal/blob"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 )
 
@@ -16,41 +29,284 @@ const (
 )
 
 var (
+	// ErrBackupNotFound is returned when there is no backup file with the name
+	ErrBackupNotFound = errors.New("backup not found")
+	// ErrBackupChecksum is returned for backup files which don't match their checksum
+	ErrBackupChecksum = errors.New("backup file doesn't match its checksum, it is damaged")
+
 	errBackup           = errors.New("error occurred while backing up data")
 	errNoBackupFilesErr = errors.New("no backup file  provided")
+	errBackupDecrypt    = errors.New("backup file could not be decrypted, check the passphrase")
 )
 
-// BackupData ...
-/* func BackupData(s storage.Store) error {
-	backupFolder := viper.GetString("backup.folder")
-	backupPath := fmt.Sprintf("%s/passwall-%s.bak", backupFolder, time.Now().Format(timeFormat))
-
-	var loginList []model.Login
-	s.Find(&loginList)
-	loginList = DecryptLoginPasswords(loginList)
-
-	// Struct to []byte vs. vs.
-	loginBytes := new(bytes.Buffer)
-	json.NewEncoder(loginBytes).Encode(loginList)
-
-	if _, err := os.Stat(backupFolder); os.IsNotExist(err) {
-		//http://permissions-calculator.org/
-		//0755 Commonly used on web servers. The owner can read, write, execute.
-		//Everyone else can read and execute but not modify the file.
-		os.Mkdir(backupFolder, 0755)
-	} else if err == nil {
-		// is exist folder
-	} else {
-		err := BackupError
+// backupPrefix and backupSuffix surround the schema and the time in the names of the
+// backup files, like passwall-user1-2026-01-02T03-00-00.bak. The names sort by the time
+// and never change, so the lifecycle rules of buckets can expire or archive them.
+const (
+	backupPrefix = "passwall-"
+	backupSuffix = ".bak"
+	// backupChecksum is the suffix of the file with the SHA-256 of a backup, in the
+	// format of sha256sum
+	backupChecksum = ".sha256"
+)
+
+// Targets of backups
+const (
+	BackupFolder = "folder"
+	BackupS3     = "s3"
+)
+
+// StartBackupJob backs up every vault at the times of the backup.schedule cron expression,
+// backups are off when it is empty
+func StartBackupJob(s storage.Store) error {
+	if viper.GetString("backup.schedule") == "" {
+		return nil
+	}
+	schedule, err := parseCron(viper.GetString("backup.schedule"))
+	if err != nil {
+		return fmt.Errorf("backup.schedule: %w", err)
+	}
+	if _, err := backupStore(); err != nil {
 		return err
 	}
 
-	EncryptFile(backupPath, loginBytes.Bytes(), viper.GetString("server.passphrase"))
+	onSchedule(schedule, func(now time.Time) {
+		if _, err := BackupVaults(s, now); err != nil {
+			log.WithError(err).Error("vaults couldn't be backed up")
+		}
+	})
+	return nil
+}
+
+// backupStore returns the store of backup.target, backup.folder or a bucket of S3 or of
+// a compatible service
+func backupStore() (blob.Store, error) {
+	switch target := viper.GetString("backup.target"); target {
+	case "", BackupFolder:
+		return &blob.Disk{Dir: viper.GetString("backup.folder")}, nil
+	case BackupS3:
+		return blob.S3FromConfig("backup")
+	default:
+		return nil, fmt.Errorf("backup.target: unknown target %q", target)
+	}
+}
 
-	rotateBackup()
+// BackupVaults writes a backup of each vault to backup.target and keeps the last
+// backup.rotation backups of each vault. A vault which fails doesn't stop the others.
+func BackupVaults(s storage.Store, now time.Time) ([]model.Backup, error) {
+	defer tracing.Start("app.BackupVaults").End()
 
+	store, err := backupStore()
+	if err != nil {
+		return nil, err
+	}
+	schemas, err := vaultSchemas(s)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []model.Backup{}
+	failed := 0
+	for _, schema := range schemas {
+		backup, err := backupVault(s, store, schema, now)
+		if err != nil {
+			log.WithError(err).WithField("schema", schema).Error("vault couldn't be backed up")
+			failed++
+			continue
+		}
+		backups = append(backups, *backup)
+	}
+
+	log.WithFields(log.Fields{
+		"event":    "backup",
+		"target":   viper.GetString("backup.target"),
+		"vaults":   len(backups),
+		"failures": failed,
+	}).Info("vaults are backed up")
+	if failed > 0 {
+		return backups, fmt.Errorf("%d of %d vaults: %w", failed, len(schemas), errBackup)
+	}
+	return backups, nil
+}
+
+// BackupVault writes the folders, tags and items of the vault in schema to a backup file
+// of backup.target. The content is an export archive sealed with the server passphrase.
+func BackupVault(s storage.Store, schema string, now time.Time) (*model.Backup, error) {
+	store, err := backupStore()
+	if err != nil {
+		return nil, err
+	}
+	return backupVault(s, store, schema, now)
+}
+
+func backupVault(s storage.Store, store blob.Store, schema string, now time.Time) (*model.Backup, error) {
+	archive := &model.ExportArchive{Version: exportVersion, CreatedAt: now.UTC(), Items: map[string]interface{}{}}
+	folders, err := s.Folders().All(schema)
+	if err != nil {
+		return nil, err
+	}
+	archive.Folders = model.ToFolderDTOs(folders)
+	tags, err := s.Tags().All(schema)
+	if err != nil {
+		return nil, err
+	}
+	archive.Tags = model.ToTagDTOs(tags)
+
+	for _, itemType := range ItemTypes {
+		items, err := AllItems(s, itemType, schema)
+		if err != nil {
+			return nil, err
+		}
+		if err := LoadItemTags(s, items, schema); err != nil {
+			return nil, err
+		}
+		archive.Items[itemType] = itemDTOs(items)
+	}
+	if archive.PasswordHistory, err = passwordHistoryDTOs(s, archive.Items[LoginItem].([]interface{}), schema); err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(archive)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := SealExportArchive(data, viper.GetString("server.passphrase"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The checksum is written last, a backup without one is incomplete
+	name := backupPrefix + schema + "-" + now.UTC().Format(timeFormat) + backupSuffix
+	sum := sha256.Sum256(sealed)
+	checksum := hex.EncodeToString(sum[:])
+	if err := store.Put(name, bytes.NewReader(sealed)); err != nil {
+		return nil, err
+	}
+	if err := store.Put(name+backupChecksum, strings.NewReader(checksum+"  "+name+"\n")); err != nil {
+		return nil, err
+	}
+
+	if err := rotateVaultBackups(store, schema); err != nil {
+		return nil, err
+	}
+	return &model.Backup{Name: name, Schema: schema, Size: int64(len(sealed)), SHA256: checksum, CreatedAt: now.UTC()}, nil
+}
+
+// rotateVaultBackups deletes all but the last backup.rotation backups of the vault,
+// 0 keeps all of them, e.g. for the lifecycle rules of a bucket
+func rotateVaultBackups(store blob.Store, schema string) error {
+	rotation := viper.GetInt("backup.rotation")
+	if rotation <= 0 {
+		return nil
+	}
+	backups, err := listBackups(store)
+	if err != nil {
+		return err
+	}
+
+	kept := 0
+	for _, backup := range backups {
+		if backup.Schema != schema {
+			continue
+		}
+		if kept++; kept <= rotation {
+			continue
+		}
+		if err := store.Delete(backup.Name); err != nil {
+			return err
+		}
+		if err := store.Delete(backup.Name + backupChecksum); err != nil {
+			return err
+		}
+	}
 	return nil
-} */
+}
+
+// ListBackups returns the backups of backup.target, the newest first
+func ListBackups() ([]model.Backup, error) {
+	store, err := backupStore()
+	if err != nil {
+		return nil, err
+	}
+	return listBackups(store)
+}
+
+func listBackups(store blob.Store) ([]model.Backup, error) {
+	objects, err := store.List(backupPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	backups := []model.Backup{}
+	for _, object := range objects {
+		if strings.Contains(object.Key, "/") || !strings.HasSuffix(object.Key, backupSuffix) {
+			continue
+		}
+		backup := model.Backup{Name: object.Key, Size: object.Size, CreatedAt: object.ModTime.UTC()}
+		backup.Schema, backup.CreatedAt = parseBackupName(object.Key, backup.CreatedAt)
+		backups = append(backups, backup)
+	}
+	sort.SliceStable(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+	return backups, nil
+}
+
+// ReadBackup returns the backup file with the name after checking its checksum,
+// backups of older versions have none
+func ReadBackup(name string) ([]byte, error) {
+	if strings.Contains(name, "/") || !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+		return nil, ErrBackupNotFound
+	}
+	store, err := backupStore()
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := readBlob(store, name)
+	if err == blob.ErrNotFound {
+		return nil, ErrBackupNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	checksum, err := readBlob(store, name+backupChecksum)
+	if err == blob.ErrNotFound {
+		return data, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if fields := strings.Fields(string(checksum)); len(fields) == 0 || fields[0] != hex.EncodeToString(sum[:]) {
+		return nil, ErrBackupChecksum
+	}
+	return data, nil
+}
+
+func readBlob(store blob.Store, key string) ([]byte, error) {
+	r, err := store.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// parseBackupName returns the schema and the time in the name of a backup file, names
+// of older backups have no schema and get the modification time
+func parseBackupName(name string, modTime time.Time) (string, time.Time) {
+	name = strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+	if len(name) <= len(timeFormat)+1 {
+		return "", modTime
+	}
+	created, err := time.Parse(timeFormat, name[len(name)-len(timeFormat):])
+	if err != nil {
+		return "", modTime
+	}
+	return name[:len(name)-len(timeFormat)-1], created
+}
 
 // Rotate backup files
 func rotateBackup(backupFiles []os.FileInfo) error {
@@ -91,3 +347,111 @@ func GetBackupFiles() ([]os.FileInfo, error) {
 
 	return backupFiles, nil
 }
+
+// VerifyBackup decrypts the backup file and checks that its items can be read as items
+// of this server version. With restore the items are also restored into a temporary
+// SQLite database and read back to prove the backup is recoverable.
+func VerifyBackup(path, passphrase string, restore bool) (*model.BackupReport, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	archive, err := OpenBackupArchive(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.BackupReport{File: filepath.Base(path), UnknownFields: []string{}}
+	seen := map[string]bool{}
+	for _, itemType := range ItemTypes {
+		var rows []map[string]interface{}
+		if raw, ok := archive.Items[itemType]; ok {
+			if err := json.Unmarshal(raw, &rows); err != nil {
+				return nil, fmt.Errorf("backup content is not a list of %s: %w", itemType, err)
+			}
+		}
+		report.Rows += len(rows)
+		for _, field := range unknownFields(rows, reflect.ValueOf(newItemDTO(itemType)).Elem().Interface()) {
+			if !seen[field] {
+				seen[field] = true
+				report.UnknownFields = append(report.UnknownFields, field)
+			}
+		}
+	}
+	sort.Strings(report.UnknownFields)
+
+	if !restore {
+		return report, nil
+	}
+
+	report.RestoredRows, err = testRestore(archive)
+	if err != nil {
+		return report, err
+	}
+	report.Restored = true
+
+	return report, nil
+}
+
+// unknownFields returns the keys of the rows which are not a json field of dto
+func unknownFields(rows []map[string]interface{}, dto interface{}) []string {
+	known := map[string]bool{}
+	t := reflect.TypeOf(dto)
+	for i := 0; i < t.NumField(); i++ {
+		known[strings.Split(t.Field(i).Tag.Get("json"), ",")[0]] = true
+	}
+
+	seen := map[string]bool{}
+	unknown := []string{}
+	for _, row := range rows {
+		for key := range row {
+			if !known[key] && !seen[key] {
+				seen[key] = true
+				unknown = append(unknown, key)
+			}
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// testRestore restores the archive into a temporary database and returns the number of
+// items which could be read and decrypted again
+func testRestore(archive *BackupArchive) (int, error) {
+	dir, err := ioutil.TempDir("", "passwall-verify")
+	if err != nil {
+		return 0, err
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := storage.DBConn(&config.DatabaseConfiguration{
+		Driver: "sqlite",
+		Path:   filepath.Join(dir, "passwall.db"),
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	s := storage.New(db)
+	schema := "user1"
+	if err := s.Users().CreateSchema(schema); err != nil {
+		return 0, err
+	}
+	MigrateUserTables(s, schema)
+
+	if _, err := RestoreBackup(s, archive, RestoreWipe, false, schema); err != nil {
+		return 0, err
+	}
+
+	// The store decrypts the items, so reading them back proves they restore
+	restored := 0
+	for _, itemType := range ItemTypes {
+		items, err := AllItems(s, itemType, schema)
+		if err != nil {
+			return 0, err
+		}
+		restored += reflect.ValueOf(items).Len()
+	}
+	return restored, nil
+}
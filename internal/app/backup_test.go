@@ -4,8 +4,14 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"reflect"
 	"testing"
+	"time"
 
+	"github.com/passwall/passwall-server/internal/blob"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/spf13/viper"
@@ -89,3 +95,112 @@ func TestRotateBackup(t *testing.T) {
 	}
 
 }
+
+func TestVerifyBackup(t *testing.T) {
+	viper.Set("server.passphrase", "backup-passphrase")
+	tests := []struct {
+		name       string
+		data       string
+		passphrase string
+		rows       int
+		unknown    []string
+		wantErr    bool
+	}{
+		{name: "Compatible backup", data: `[{"id":3,"url":"passwall.io","username":"a","password":"b"},{"id":5,"title":"c"}]`, passphrase: "backup-passphrase", rows: 2, unknown: []string{}},
+		{name: "Unknown fields", data: `[{"url":"passwall.io","totp":"x"}]`, passphrase: "backup-passphrase", rows: 1, unknown: []string{"totp"}},
+		{name: "Wrong passphrase", data: `[]`, passphrase: "wrong", wantErr: true},
+		{name: "Not a login list", data: `{"url":"passwall.io"}`, passphrase: "backup-passphrase", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, err := ioutil.TempFile("", "passwall.*.bak")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer os.Remove(file.Name())
+			EncryptFile(file.Name(), []byte(tt.data), "backup-passphrase")
+
+			report, err := VerifyBackup(file.Name(), tt.passphrase, true)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("VerifyBackup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if report.Rows != tt.rows || report.RestoredRows != tt.rows || !report.Restored {
+				t.Errorf("VerifyBackup() rows = %d, restored %d, want %d", report.Rows, report.RestoredRows, tt.rows)
+			}
+			if !reflect.DeepEqual(report.UnknownFields, tt.unknown) {
+				t.Errorf("VerifyBackup() unknown fields = %v, want %v", report.UnknownFields, tt.unknown)
+			}
+		})
+	}
+}
+
+func TestBackupPasswordHistory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passwall-backup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viper.Set("server.passphrase", "backup-passphrase")
+	viper.Set("backup.rotation", 0)
+
+	db, err := storage.DBConn(&config.DatabaseConfiguration{Driver: "sqlite", Path: filepath.Join(dir, "passwall.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	s := storage.New(db)
+	for _, schema := range []string{"user1", "user2"} {
+		if err := s.Users().CreateSchema(schema); err != nil {
+			t.Fatal(err)
+		}
+		MigrateUserTables(s, schema)
+	}
+
+	login, err := CreateLogin(s, &model.LoginDTO{Title: "Mail", Password: "first"}, "user1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UpdateLogin(s, login, &model.LoginDTO{Title: "Mail", Password: "second"}, "user1"); err != nil {
+		t.Fatal(err)
+	}
+	changedAt := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	s.PasswordHistories().Save(&model.PasswordHistory{CreatedAt: changedAt, LoginID: login.ID, Password: "zeroth"}, "user1")
+
+	backup, err := backupVault(s, &blob.Disk{Dir: dir}, "user1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, backup.Name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	archive, err := OpenBackupArchive(data, "backup-passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := RestoreBackup(s, archive, RestoreMerge, false, "user2"); err != nil {
+		t.Fatal(err)
+	}
+
+	logins, err := s.Logins().All("user2")
+	if err != nil || len(logins) != 1 {
+		t.Fatalf("restored logins = %v, %v", logins, err)
+	}
+	histories, err := FindPasswordHistory(s, logins[0].ID, "user2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	passwords := []string{}
+	for _, history := range histories {
+		passwords = append(passwords, history.Password)
+	}
+	if !reflect.DeepEqual(passwords, []string{"first", "zeroth"}) {
+		t.Errorf("restored password history = %v", passwords)
+	}
+	if !histories[1].CreatedAt.Equal(changedAt) {
+		t.Errorf("restored change time = %v, want %v", histories[1].CreatedAt, changedAt)
+	}
+}
@@ -2,39 +2,60 @@ package app
 
 import (
 	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
 	"github.com/passwall/passwall-server/model"
 )
 
 // CreateBankAccount creates a new bank account and saves it to the store
 func CreateBankAccount(s storage.Store, dto *model.BankAccountDTO, schema string) (*model.BankAccount, error) {
+	defer tracing.Start("app.CreateBankAccount").End()
+
 	rawModel := model.ToBankAccount(dto)
-	encModel := EncryptModel(rawModel)
 
-	createdBankAccount, err := s.BankAccounts().Save(encModel.(*model.BankAccount), schema)
+	createdBankAccount, err := s.BankAccounts().Save(rawModel, schema)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := SetItemTags(s, createdBankAccount, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return createdBankAccount, nil
 }
 
 // UpdateBankAccount updates the account with the dto and applies the changes in the store
 func UpdateBankAccount(s storage.Store, bankAccount *model.BankAccount, dto *model.BankAccountDTO, schema string) (*model.BankAccount, error) {
+	defer tracing.Start("app.UpdateBankAccount").End()
+
+	if err := saveItemVersion(s, bankAccount, schema); err != nil {
+		return nil, err
+	}
+
 	rawModel := model.ToBankAccount(dto)
-	encModel := EncryptModel(rawModel).(*model.BankAccount)
 
-	bankAccount.BankName = encModel.BankName
-	bankAccount.BankCode = encModel.BankCode
-	bankAccount.AccountName = encModel.AccountName
-	bankAccount.AccountNumber = encModel.AccountNumber
-	bankAccount.IBAN = encModel.IBAN
-	bankAccount.Currency = encModel.Currency
-	bankAccount.Password = encModel.Password
+	bankAccount.BankName = rawModel.BankName
+	bankAccount.BankCode = rawModel.BankCode
+	bankAccount.AccountName = rawModel.AccountName
+	bankAccount.AccountNumber = rawModel.AccountNumber
+	bankAccount.IBAN = rawModel.IBAN
+	bankAccount.Currency = rawModel.Currency
+	bankAccount.Password = rawModel.Password
+	bankAccount.Pinned = rawModel.Pinned
+	bankAccount.IsFavorite = rawModel.IsFavorite
+	bankAccount.SortOrder = rawModel.SortOrder
+	bankAccount.FolderID = rawModel.FolderID
+	bankAccount.Reprompt = rawModel.Reprompt
+	bankAccount.Canary = rawModel.Canary
 
 	updatedBankAccount, err := s.BankAccounts().Save(bankAccount, schema)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := SetItemTags(s, updatedBankAccount, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return updatedBankAccount, nil
 }
@@ -0,0 +1,166 @@
+package app
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// CreateItems creates the items of the type from their DTOs and returns a result for each
+// of them in order. Invalid DTOs get their error, the others are created in a single
+// transaction so none of them is created when the store fails.
+func CreateItems(s storage.Store, itemType string, dtos []json.RawMessage, schema string) ([]model.BatchResultDTO, error) {
+	defer tracing.Start("app.CreateItems").End()
+
+	results := make([]model.BatchResultDTO, len(dtos))
+	valid := make([]interface{}, len(dtos))
+	for i := range dtos {
+		results[i].Index = i
+		dto, err := decodeItemDTO(itemType, dtos[i])
+		if err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		valid[i] = dto
+	}
+
+	err := s.Transaction(func(tx storage.Store) error {
+		for i, dto := range valid {
+			if dto == nil {
+				continue
+			}
+			item, err := createItem(tx, dto, schema)
+			if err != nil {
+				return err
+			}
+			results[i].Item = ToItemDTO(item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// FindItemsByID finds the items of the type with the ids, each id once. The ids which
+// aren't found are returned as failures.
+func FindItemsByID(s storage.Store, itemType string, ids []uint, schema string) ([]interface{}, []model.BatchFailureDTO) {
+	defer tracing.Start("app.FindItemsByID").End()
+
+	items := []interface{}{}
+	failed := []model.BatchFailureDTO{}
+	seen := map[uint]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		item, err := FindItem(s, itemType, id, schema)
+		if err != nil {
+			failed = append(failed, model.BatchFailureDTO{ID: id, Error: err.Error()})
+			continue
+		}
+		items = append(items, item)
+	}
+	return items, failed
+}
+
+// DeleteItems moves the item pointers to the trash in a single transaction, so none of
+// them is deleted when the store fails. It returns the ids of the deleted items.
+func DeleteItems(s storage.Store, items []interface{}, schema string) ([]uint, error) {
+	defer tracing.Start("app.DeleteItems").End()
+
+	ids := []uint{}
+	err := s.Transaction(func(tx storage.Store) error {
+		for _, item := range items {
+			if err := deleteItem(tx, item, schema); err != nil {
+				return err
+			}
+			ids = append(ids, uint(reflect.ValueOf(item).Elem().FieldByName("ID").Uint()))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ids, nil
+}
+
+// decodeItemDTO decodes and validates the DTO of the item type as its create endpoint does
+func decodeItemDTO(itemType string, data json.RawMessage) (interface{}, error) {
+	dto := newItemDTO(itemType)
+	if dto == nil {
+		return nil, errUnknownItemType
+	}
+	if err := json.Unmarshal(data, dto); err != nil {
+		return nil, err
+	}
+	if err := ValidateItemDTO(dto); err != nil {
+		return nil, err
+	}
+	return dto, nil
+}
+
+// createItem creates the item of the DTO pointer with the create of its type
+func createItem(s storage.Store, dto interface{}, schema string) (interface{}, error) {
+	switch v := dto.(type) {
+	case *model.LoginDTO:
+		return CreateLogin(s, v, schema)
+	case *model.CreditCardDTO:
+		return CreateCreditCard(s, v, schema)
+	case *model.BankAccountDTO:
+		return CreateBankAccount(s, v, schema)
+	case *model.NoteDTO:
+		return CreateNote(s, v, schema)
+	case *model.EmailDTO:
+		return CreateEmail(s, v, schema)
+	case *model.ServerDTO:
+		return CreateServer(s, v, schema)
+	}
+	return nil, errUnknownItemType
+}
+
+// newItemDTO returns a pointer to a new DTO of the item type, nil for unknown types
+func newItemDTO(itemType string) interface{} {
+	switch itemType {
+	case LoginItem:
+		return new(model.LoginDTO)
+	case CreditCardItem:
+		return new(model.CreditCardDTO)
+	case BankAccountItem:
+		return new(model.BankAccountDTO)
+	case NoteItem:
+		return new(model.NoteDTO)
+	case EmailItem:
+		return new(model.EmailDTO)
+	case ServerItem:
+		return new(model.ServerDTO)
+	}
+	return nil
+}
+
+// deleteItem moves the item pointer to the trash with the delete of its type
+func deleteItem(s storage.Store, item interface{}, schema string) error {
+	switch v := item.(type) {
+	case *model.Login:
+		if err := s.Logins().Delete(v.ID, schema); err != nil {
+			return err
+		}
+		return s.PasswordHistories().DeleteByLoginID(v.ID, schema)
+	case *model.CreditCard:
+		return s.CreditCards().Delete(v.ID, schema)
+	case *model.BankAccount:
+		return s.BankAccounts().Delete(v.ID, schema)
+	case *model.Note:
+		return s.Notes().Delete(v.ID, schema)
+	case *model.Email:
+		return s.Emails().Delete(v.ID, schema)
+	case *model.Server:
+		return s.Servers().Delete(v.ID, schema)
+	}
+	return errUnknownItemType
+}
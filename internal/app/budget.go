@@ -0,0 +1,122 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// Classes of expensive endpoints, each has its own budget per user in budget.<class>
+const (
+	BudgetExport = "export"
+	BudgetImport = "import"
+	BudgetReport = "report"
+	BudgetSearch = "search"
+)
+
+// BudgetVerification is the class of verification emails, its budget is of each address
+// instead of each user
+const BudgetVerification = "verification"
+
+// budgetPruneSize is the count of buckets which starts dropping the full ones
+const budgetPruneSize = 10000
+
+var budgets = struct {
+	sync.Mutex
+	buckets map[string]*budgetBucket
+}{buckets: map[string]*budgetBucket{}}
+
+// budgetBucket refills its tokens continuously, a request spends one
+type budgetBucket struct {
+	tokens float64
+	last   time.Time
+	full   time.Time
+}
+
+// BudgetStatus is the budget of a user for a class after a request
+type BudgetStatus struct {
+	Allowed    bool
+	Limit      int           // requests per period, 0 is unlimited
+	Remaining  int           // requests which can be made now
+	Reset      time.Duration // until the budget is full again
+	RetryAfter time.Duration // until the next request is allowed, when it isn't
+}
+
+// SpendBudget spends a request of the budget of the user for the class.
+// Budgets like "10/1h" allow bursts of 10 requests and refill one every 6 minutes.
+func SpendBudget(userID uint, class string, now time.Time) *BudgetStatus {
+	return spendBudget(strconv.FormatUint(uint64(userID), 10), class, now)
+}
+
+// spendBudget spends a request of the budget of the key for the class, keys are user ids
+// or other identities like email addresses
+func spendBudget(owner, class string, now time.Time) *BudgetStatus {
+	limit, period, err := parseBudget(viper.GetString("budget." + class))
+	if err != nil {
+		return &BudgetStatus{Allowed: true}
+	}
+	rate := float64(limit) / period.Seconds()
+
+	budgets.Lock()
+	defer budgets.Unlock()
+
+	key := fmt.Sprintf("%s|%s", owner, class)
+	bucket, ok := budgets.buckets[key]
+	if !ok {
+		if len(budgets.buckets) >= budgetPruneSize {
+			pruneBudgets(now)
+		}
+		bucket = &budgetBucket{tokens: float64(limit), last: now}
+		budgets.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(float64(limit), bucket.tokens+now.Sub(bucket.last).Seconds()*rate)
+	bucket.last = now
+
+	status := &BudgetStatus{Limit: limit}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		status.Allowed = true
+	} else {
+		status.RetryAfter = secondsDuration((1 - bucket.tokens) / rate)
+	}
+	status.Remaining = int(bucket.tokens)
+	status.Reset = secondsDuration((float64(limit) - bucket.tokens) / rate)
+	bucket.full = now.Add(status.Reset)
+	return status
+}
+
+// pruneBudgets drops the buckets which are full again, they are the same as new ones
+func pruneBudgets(now time.Time) {
+	for key, bucket := range budgets.buckets {
+		if now.After(bucket.full) {
+			delete(budgets.buckets, key)
+		}
+	}
+}
+
+// parseBudget parses budgets like "10/1h", an empty budget is unlimited
+func parseBudget(budget string) (int, time.Duration, error) {
+	parts := strings.Split(budget, "/")
+	if len(parts) != 2 {
+		return 0, 0, errPeriod
+	}
+	limit, err := strconv.Atoi(parts[0])
+	if err != nil || limit <= 0 {
+		return 0, 0, errPeriod
+	}
+	period, err := parsePeriod(parts[1])
+	if err != nil {
+		return 0, 0, err
+	}
+	return limit, period, nil
+}
+
+func secondsDuration(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds)) * time.Second
+}
@@ -0,0 +1,42 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpendBudget(t *testing.T) {
+	viper.Set("budget.report", "2/1m")
+	defer viper.Set("budget.report", "30/1h")
+
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	first := SpendBudget(7, BudgetReport, now)
+	assert.True(t, first.Allowed)
+	assert.Equal(t, 2, first.Limit)
+	assert.Equal(t, 1, first.Remaining)
+
+	assert.True(t, SpendBudget(7, BudgetReport, now).Allowed)
+	denied := SpendBudget(7, BudgetReport, now)
+	assert.False(t, denied.Allowed)
+	assert.Equal(t, 30*time.Second, denied.RetryAfter)
+	assert.Equal(t, time.Minute, denied.Reset)
+
+	// Other users have their own budget
+	assert.True(t, SpendBudget(8, BudgetReport, now).Allowed)
+
+	// A request is refilled every 30 seconds
+	assert.True(t, SpendBudget(7, BudgetReport, now.Add(30*time.Second)).Allowed)
+	assert.False(t, SpendBudget(7, BudgetReport, now.Add(30*time.Second)).Allowed)
+}
+
+func TestSpendBudgetUnlimited(t *testing.T) {
+	viper.Set("budget.report", "")
+	defer viper.Set("budget.report", "30/1h")
+
+	status := SpendBudget(7, BudgetReport, time.Now())
+	assert.True(t, status.Allowed)
+	assert.Equal(t, 0, status.Limit)
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+// Actions on canary items
+const (
+	CanaryRead   = "read"
+	CanaryUpdate = "updated"
+	CanaryDelete = "deleted"
+)
+
+// canaryAlertInterval limits the alerts of the same canary, action and source,
+// so clients polling a list don't flood the channels. Audit entries aren't limited.
+const canaryAlertInterval = time.Minute
+
+// canaryPruneSize is the count of sent alerts which starts dropping the old ones
+const canaryPruneSize = 10000
+
+var canaryAlerts = struct {
+	sync.Mutex
+	sent map[string]time.Time
+}{sent: map[string]time.Time{}}
+
+// TripCanaries alerts about every canary in items, which is an item pointer or a slice of items
+// or item pointers.
+// Canaries are planted items nobody should touch, so any action on them is reported.
+// source tells where the action came from, like an ip address or a machine account.
+func TripCanaries(s storage.Store, userID uint, action, source string, items interface{}) {
+	v := reflect.ValueOf(items)
+	if v.Kind() == reflect.Ptr {
+		v = reflect.Append(reflect.MakeSlice(reflect.SliceOf(v.Type()), 0, 1), v)
+	}
+	if v.Kind() != reflect.Slice {
+		return
+	}
+
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		if item.Kind() == reflect.Interface {
+			item = item.Elem()
+		}
+		if item.Kind() != reflect.Ptr {
+			item = item.Addr()
+		}
+		if canary := item.Elem().FieldByName("Canary"); canary.IsValid() && canary.Bool() {
+			tripCanary(s, userID, action, source, item.Interface())
+		}
+	}
+}
+
+func tripCanary(s storage.Store, userID uint, action, source string, item interface{}) {
+	itemType := ItemTypeOf(item)
+	id := reflect.ValueOf(item).Elem().FieldByName("ID").Uint()
+	path := fmt.Sprintf("%s/%d", itemType, id)
+
+	// Audit entry
+	log.WithFields(log.Fields{
+		"event":   "canary_" + action,
+		"user_id": userID,
+		"item":    path,
+		"source":  source,
+	}).Error("canary item is touched")
+
+	if !canaryAlertDue(path+"|"+action+"|"+source, time.Now()) {
+		return
+	}
+
+	alert := &Alert{
+		Event:   "canary_" + action,
+		Subject: "Passwall Canary Alert",
+		format:  "The canary item %s was %s. Nobody uses it, the vault may be compromised.",
+		args:    []interface{}{path, action},
+		Time:    time.Now(),
+		Fields: map[string]string{
+			"User ID": strconv.FormatUint(uint64(userID), 10),
+			"Item":    path,
+			"Source":  source,
+		},
+	}
+	if title := titleField(item); title.IsValid() {
+		alert.Fields["Title"] = title.String()
+	}
+	if user, err := s.Users().FindByID(userID); err == nil {
+		alert.Name = user.Name
+		alert.Email = user.Email
+		alert.Locale = user.Locale
+	}
+	alert.Message = fmt.Sprintf(alert.format, alert.args...)
+	SendAlert(alert)
+}
+
+// canaryAlertDue reports whether the alert wasn't sent in the last interval and marks it as sent
+func canaryAlertDue(key string, now time.Time) bool {
+	canaryAlerts.Lock()
+	defer canaryAlerts.Unlock()
+
+	last, ok := canaryAlerts.sent[key]
+	if ok && now.Sub(last) < canaryAlertInterval {
+		return false
+	}
+	if !ok && len(canaryAlerts.sent) >= canaryPruneSize {
+		pruneCanaryAlerts(now)
+	}
+	canaryAlerts.sent[key] = now
+	return true
+}
+
+// pruneCanaryAlerts drops the alerts sent before the interval, they don't limit any alert anymore
+func pruneCanaryAlerts(now time.Time) {
+	for key, last := range canaryAlerts.sent {
+		if now.Sub(last) >= canaryAlertInterval {
+			delete(canaryAlerts.sent, key)
+		}
+	}
+}
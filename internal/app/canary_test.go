@@ -0,0 +1,85 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTripCanaries(t *testing.T) {
+	alerts := make(chan *Alert, 10)
+	defer func(notifiers []Notifier) { Notifiers = notifiers }(Notifiers)
+	Notifiers = []Notifier{func(alert *Alert) error {
+		alerts <- alert
+		return nil
+	}}
+
+	mocks := storagetest.NewMocks()
+	mocks.Users.On("FindByID", uint(1)).Return(&model.User{ID: 1, Name: "Test", Email: "test@passwall.io"}, nil)
+
+	logins := []model.Login{{ID: 1, Title: "Real"}, {ID: 2, Title: "Prod DB root", Canary: true}}
+	TripCanaries(mocks.Store, 1, CanaryRead, "203.0.113.9", logins)
+
+	select {
+	case alert := <-alerts:
+		assert.Equal(t, "canary_read", alert.Event)
+		assert.Equal(t, "test@passwall.io", alert.Email)
+		assert.Equal(t, "logins/2", alert.Fields["Item"])
+		assert.Equal(t, "Prod DB root", alert.Fields["Title"])
+		assert.Equal(t, "203.0.113.9", alert.Fields["Source"])
+	case <-time.After(time.Second):
+		t.Fatal("no alert")
+	}
+
+	// The same read again is in the audit log only
+	TripCanaries(mocks.Store, 1, CanaryRead, "203.0.113.9", &logins[1])
+	TripCanaries(mocks.Store, 1, CanaryDelete, "203.0.113.9", &logins[1])
+	select {
+	case alert := <-alerts:
+		assert.Equal(t, "canary_deleted", alert.Event)
+	case <-time.After(time.Second):
+		t.Fatal("no alert")
+	}
+	assert.Len(t, alerts, 0)
+}
+
+func TestCanaryAlertDue(t *testing.T) {
+	defer func() { canaryAlerts.sent = map[string]time.Time{} }()
+	now := time.Now()
+	assert.True(t, canaryAlertDue("logins/1|read|203.0.113.9", now))
+	assert.False(t, canaryAlertDue("logins/1|read|203.0.113.9", now.Add(time.Second)))
+
+	// Alerts older than the interval are dropped once the map is full
+	for i := 0; i < canaryPruneSize; i++ {
+		canaryAlertDue(fmt.Sprintf("logins/%d|read|198.51.100.1", i+2), now)
+	}
+	later := now.Add(canaryAlertInterval)
+	assert.True(t, canaryAlertDue("notes/1|read|203.0.113.9", later))
+	canaryAlerts.Lock()
+	assert.Len(t, canaryAlerts.sent, 1)
+	canaryAlerts.Unlock()
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var body map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	viper.Set("alert.webhookURL", srv.URL)
+	defer viper.Set("alert.webhookURL", "")
+
+	err := WebhookNotifier(&Alert{Event: "canary_read", Subject: "Passwall Canary Alert", Message: "logins/2 was read"})
+	assert.NoError(t, err)
+	assert.Equal(t, "canary_read", body["event"])
+	assert.Equal(t, "Passwall Canary Alert: logins/2 was read", body["text"])
+}
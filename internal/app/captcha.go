@@ -0,0 +1,106 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+
+	"github.com/passwall/passwall-server/internal/tracing"
+)
+
+// Captcha providers of captcha.provider, empty turns the captcha off
+const (
+	CaptchaRecaptcha = "recaptcha"
+	CaptchaHCaptcha  = "hcaptcha"
+	CaptchaTurnstile = "turnstile"
+)
+
+// Error codes of signups without a valid captcha
+const (
+	SignupCaptchaRequired = "CAPTCHA_REQUIRED"
+	SignupCaptchaInvalid  = "CAPTCHA_INVALID"
+)
+
+// legacyRecaptchaSecret is the placeholder older configurations have in server.recaptcha
+const legacyRecaptchaSecret = "GoogleRecaptchaSecret"
+
+var (
+	// ErrCaptchaRequired is returned for signups without a challenge token
+	ErrCaptchaRequired = errors.New("Captcha is required")
+	// ErrCaptchaInvalid is returned when the provider rejects the challenge token
+	ErrCaptchaInvalid = errors.New("Captcha is not valid")
+
+	captchaVerifyURLs = map[string]string{
+		CaptchaRecaptcha: "https://www.google.com/recaptcha/api/siteverify",
+		CaptchaHCaptcha:  "https://api.hcaptcha.com/siteverify",
+		CaptchaTurnstile: "https://challenges.cloudflare.com/turnstile/v0/siteverify",
+	}
+	captchaHTTPClient = &http.Client{Transport: tracing.Transport(nil), Timeout: 10 * time.Second}
+)
+
+// CaptchaProvider returns the configured captcha provider and its secret. A secret in
+// server.recaptcha of older configurations turns reCAPTCHA on.
+func CaptchaProvider() (provider, secret string) {
+	provider = strings.ToLower(viper.GetString("captcha.provider"))
+	secret = viper.GetString("captcha.secret")
+	if provider != "" {
+		return provider, secret
+	}
+	if legacy := viper.GetString("server.recaptcha"); legacy != "" && legacy != legacyRecaptchaSecret {
+		return CaptchaRecaptcha, legacy
+	}
+	return "", ""
+}
+
+// VerifyCaptcha checks the challenge token of a signup with the captcha provider.
+// It passes when the captcha is off.
+func VerifyCaptcha(token, remoteIP string) error {
+	provider, secret := CaptchaProvider()
+	if provider == "" {
+		return nil
+	}
+	verifyURL := viper.GetString("captcha.verifyURL")
+	if verifyURL == "" {
+		verifyURL = captchaVerifyURLs[provider]
+	}
+	if verifyURL == "" {
+		return fmt.Errorf("unknown captcha provider %q", provider)
+	}
+	if token == "" {
+		return ErrCaptchaRequired
+	}
+
+	form := url.Values{"secret": {secret}, "response": {token}}
+	if remoteIP != "" {
+		form.Set("remoteip", remoteIP)
+	}
+	resp, err := captchaHTTPClient.PostForm(verifyURL, form)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("captcha provider responded with %d", resp.StatusCode)
+	}
+
+	// The providers answer the same way
+	var body struct {
+		Success    bool     `json:"success"`
+		ErrorCodes []string `json:"error-codes"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return err
+	}
+	if !body.Success {
+		log.WithField("error_codes", body.ErrorCodes).Debug("captcha is rejected")
+		return ErrCaptchaInvalid
+	}
+	return nil
+}
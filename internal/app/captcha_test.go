@@ -0,0 +1,55 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyCaptcha(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("secret") == "site-secret" && r.FormValue("response") == "solved" && r.FormValue("remoteip") == "203.0.113.7" {
+			w.Write([]byte(`{"success": true}`))
+			return
+		}
+		w.Write([]byte(`{"success": false, "error-codes": ["invalid-input-response"]}`))
+	}))
+	defer provider.Close()
+
+	// Signups pass without a provider
+	assert.NoError(t, VerifyCaptcha("", ""))
+
+	viper.Set("captcha.provider", CaptchaTurnstile)
+	viper.Set("captcha.secret", "site-secret")
+	viper.Set("captcha.verifyURL", provider.URL)
+	defer viper.Set("captcha.provider", "")
+	defer viper.Set("captcha.secret", "")
+	defer viper.Set("captcha.verifyURL", "")
+
+	assert.NoError(t, VerifyCaptcha("solved", "203.0.113.7"))
+	assert.Equal(t, ErrCaptchaInvalid, VerifyCaptcha("guessed", "203.0.113.7"))
+	assert.Equal(t, ErrCaptchaRequired, VerifyCaptcha("", "203.0.113.7"))
+
+	// Unreachable providers and unknown ones aren't passed
+	viper.Set("captcha.verifyURL", "http://127.0.0.1:1")
+	assert.Error(t, VerifyCaptcha("solved", "203.0.113.7"))
+	viper.Set("captcha.provider", "other")
+	viper.Set("captcha.verifyURL", "")
+	assert.Error(t, VerifyCaptcha("solved", "203.0.113.7"))
+}
+
+func TestCaptchaProvider(t *testing.T) {
+	defer viper.Set("server.recaptcha", "")
+
+	// The placeholder of older configurations doesn't turn reCAPTCHA on, a real secret does
+	viper.Set("server.recaptcha", legacyRecaptchaSecret)
+	provider, _ := CaptchaProvider()
+	assert.Empty(t, provider)
+	viper.Set("server.recaptcha", "legacy-secret")
+	provider, secret := CaptchaProvider()
+	assert.Equal(t, CaptchaRecaptcha, provider)
+	assert.Equal(t, "legacy-secret", secret)
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ClientIP returns the ip address of the client of r. The router resolves it behind
+// trusted proxies before handlers run, see ResolveClientIP.
+func ClientIP(r *http.Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// ResolveClientIP returns the real client ip of a request which came through the proxies
+// in server.trustedProxies. X-Forwarded-For is read from the right and the first address
+// which isn't a trusted proxy is the client, the addresses left of it can be forged.
+// X-Real-IP is used when there is no X-Forwarded-For. Headers of other peers are ignored.
+func ResolveClientIP(r *http.Request) net.IP {
+	ip := ClientIP(r)
+	if !TrustedProxy(ip) {
+		return ip
+	}
+
+	if forwarded := r.Header.Values("X-Forwarded-For"); len(forwarded) > 0 {
+		hops := strings.Split(strings.Join(forwarded, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := net.ParseIP(strings.TrimSpace(hops[i]))
+			if hop == nil {
+				return ip
+			}
+			ip = hop
+			if !TrustedProxy(hop) {
+				return hop
+			}
+		}
+		return ip
+	}
+
+	if realIP := net.ParseIP(strings.TrimSpace(r.Header.Get("X-Real-IP"))); realIP != nil {
+		return realIP
+	}
+	return ip
+}
+
+// TrustedProxy reports whether ip is in the networks of server.trustedProxies
+func TrustedProxy(ip net.IP) bool {
+	return inNetworks(ip, TrustedProxies())
+}
+
+// TrustedProxies returns the networks of the reverse proxies in front of the server
+func TrustedProxies() []string {
+	proxies := []string{}
+	for _, cidr := range strings.Split(viper.GetString("server.trustedProxies"), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr != "" {
+			proxies = append(proxies, cidr)
+		}
+	}
+	return proxies
+}
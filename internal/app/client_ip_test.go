@@ -0,0 +1,37 @@
+package app
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResolveClientIP(t *testing.T) {
+	viper.Set("server.trustedProxies", "10.0.0.0/8, 192.168.1.1/32")
+	defer viper.Set("server.trustedProxies", "")
+
+	cases := []struct {
+		remote, forwardedFor, realIP, client string
+	}{
+		{"203.0.113.7:4000", "198.51.100.1", "", "203.0.113.7"},                     // untrusted peer can't forge
+		{"10.0.0.2:4000", "", "", "10.0.0.2"},                                       // proxy without headers
+		{"10.0.0.2:4000", "198.51.100.1", "", "198.51.100.1"},                       // one proxy
+		{"10.0.0.2:4000", "6.6.6.6, 198.51.100.1, 192.168.1.1", "", "198.51.100.1"}, // forged hop on the left
+		{"10.0.0.2:4000", "", "198.51.100.1", "198.51.100.1"},                       // nginx X-Real-IP
+		{"10.0.0.2:4000", "10.1.1.1", "", "10.1.1.1"},                               // only proxies
+		{"10.0.0.2:4000", "not-an-ip, 198.51.100.1", "", "198.51.100.1"},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = c.remote
+		if c.forwardedFor != "" {
+			r.Header.Set("X-Forwarded-For", c.forwardedFor)
+		}
+		if c.realIP != "" {
+			r.Header.Set("X-Real-IP", c.realIP)
+		}
+		assert.Equal(t, c.client, ResolveClientIP(r).String(), c.forwardedFor)
+	}
+}
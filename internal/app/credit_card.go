@@ -1,39 +1,192 @@
 package app
 
 import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+
 	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
 	"github.com/passwall/passwall-server/model"
 )
 
 // CreateCreditCard creates a new credit card and saves it to the store
 func CreateCreditCard(s storage.Store, dto *model.CreditCardDTO, schema string) (*model.CreditCard, error) {
+	defer tracing.Start("app.CreateCreditCard").End()
+
+	dto.Brand = CardBrand(dto.Number)
 	rawModel := model.ToCreditCard(dto)
-	encModel := EncryptModel(rawModel)
 
-	createdCreditCard, err := s.CreditCards().Save(encModel.(*model.CreditCard), schema)
+	createdCreditCard, err := s.CreditCards().Save(rawModel, schema)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := SetItemTags(s, createdCreditCard, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return createdCreditCard, nil
 }
 
 // UpdateCreditCard updates the credit card with the dto and applies the changes in the store
 func UpdateCreditCard(s storage.Store, creditCard *model.CreditCard, dto *model.CreditCardDTO, schema string) (*model.CreditCard, error) {
+	defer tracing.Start("app.UpdateCreditCard").End()
+
+	if err := saveItemVersion(s, creditCard, schema); err != nil {
+		return nil, err
+	}
+
+	dto.Brand = CardBrand(dto.Number)
 	rawModel := model.ToCreditCard(dto)
-	encModel := EncryptModel(rawModel).(*model.CreditCard)
 
-	creditCard.CardName = encModel.CardName
-	creditCard.CardholderName = encModel.CardholderName
-	creditCard.Type = encModel.Type
-	creditCard.Number = encModel.Number
-	creditCard.VerificationNumber = encModel.VerificationNumber
-	creditCard.ExpiryDate = encModel.ExpiryDate
+	creditCard.CardName = rawModel.CardName
+	creditCard.CardholderName = rawModel.CardholderName
+	creditCard.Type = rawModel.Type
+	creditCard.Number = rawModel.Number
+	creditCard.VerificationNumber = rawModel.VerificationNumber
+	creditCard.ExpiryDate = rawModel.ExpiryDate
+	creditCard.Brand = rawModel.Brand
+	creditCard.Pinned = rawModel.Pinned
+	creditCard.IsFavorite = rawModel.IsFavorite
+	creditCard.SortOrder = rawModel.SortOrder
+	creditCard.FolderID = rawModel.FolderID
+	creditCard.Reprompt = rawModel.Reprompt
+	creditCard.Canary = rawModel.Canary
 
 	updatedCreditCard, err := s.CreditCards().Save(creditCard, schema)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := SetItemTags(s, updatedCreditCard, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return updatedCreditCard, nil
 }
+
+// Credit card brands detected from card numbers
+const (
+	CardBrandVisa       = "Visa"
+	CardBrandMastercard = "Mastercard"
+	CardBrandAmex       = "American Express"
+	CardBrandDiscover   = "Discover"
+	CardBrandDiners     = "Diners Club"
+	CardBrandJCB        = "JCB"
+	CardBrandUnionPay   = "UnionPay"
+	CardBrandMaestro    = "Maestro"
+	CardBrandTroy       = "Troy"
+	CardBrandUnknown    = "Unknown"
+)
+
+var (
+	errCardNumber = errors.New("card number is not valid")
+	errExpiryDate = errors.New("expiry date should be in MM/YY or MM/YYYY format")
+
+	expiryDateRegex = regexp.MustCompile(`^(0[1-9]|1[0-2])/([0-9]{2}|[0-9]{4})$`)
+
+	// cardBrandRanges are checked in order, so specific ranges
+	// must come before the wider ones they overlap with
+	cardBrandRanges = []struct {
+		brand    string
+		min, max int
+		digits   int
+		lengths  []int
+	}{
+		{CardBrandAmex, 34, 34, 2, []int{15}},
+		{CardBrandAmex, 37, 37, 2, []int{15}},
+		{CardBrandTroy, 9792, 9792, 4, []int{16}},
+		{CardBrandDiners, 300, 305, 3, []int{14, 15, 16, 17, 18, 19}},
+		{CardBrandDiners, 36, 36, 2, []int{14, 15, 16, 17, 18, 19}},
+		{CardBrandDiners, 38, 39, 2, []int{14, 15, 16, 17, 18, 19}},
+		{CardBrandJCB, 3528, 3589, 4, []int{16, 17, 18, 19}},
+		{CardBrandVisa, 4, 4, 1, []int{13, 16, 19}},
+		{CardBrandMastercard, 51, 55, 2, []int{16}},
+		{CardBrandMastercard, 2221, 2720, 4, []int{16}},
+		{CardBrandDiscover, 6011, 6011, 4, []int{16, 17, 18, 19}},
+		{CardBrandDiscover, 622126, 622925, 6, []int{16, 17, 18, 19}},
+		{CardBrandDiscover, 644, 649, 3, []int{16, 17, 18, 19}},
+		{CardBrandDiscover, 65, 65, 2, []int{16, 17, 18, 19}},
+		{CardBrandUnionPay, 62, 62, 2, []int{16, 17, 18, 19}},
+		{CardBrandMaestro, 50, 50, 2, []int{12, 13, 14, 15, 16, 17, 18, 19}},
+		{CardBrandMaestro, 56, 69, 2, []int{12, 13, 14, 15, 16, 17, 18, 19}},
+	}
+)
+
+// ValidateCreditCard checks the card number with Luhn algorithm and the expiry date format.
+// Empty fields are accepted since cards can be saved partially.
+func ValidateCreditCard(dto *model.CreditCardDTO) []string {
+	errs := []string{}
+
+	if dto.Number != "" && !LuhnValid(cardDigits(dto.Number)) {
+		errs = append(errs, errCardNumber.Error())
+	}
+
+	if dto.ExpiryDate != "" && !expiryDateRegex.MatchString(strings.TrimSpace(dto.ExpiryDate)) {
+		errs = append(errs, errExpiryDate.Error())
+	}
+
+	return errs
+}
+
+// LuhnValid checks the digits with Luhn (mod 10) algorithm
+func LuhnValid(digits string) bool {
+	if len(digits) < 12 || len(digits) > 19 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	return sum%10 == 0
+}
+
+// CardBrand detects the brand of the card from its number
+func CardBrand(number string) string {
+	digits := cardDigits(number)
+
+	for _, r := range cardBrandRanges {
+		if len(digits) < r.digits {
+			continue
+		}
+		prefix, err := strconv.Atoi(digits[:r.digits])
+		if err != nil {
+			return CardBrandUnknown
+		}
+		if prefix >= r.min && prefix <= r.max && includeInt(r.lengths, len(digits)) {
+			return r.brand
+		}
+	}
+
+	return CardBrandUnknown
+}
+
+// cardDigits removes spaces and dashes from the card number
+func cardDigits(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+func includeInt(vs []int, t int) bool {
+	for _, v := range vs {
+		if v == t {
+			return true
+		}
+	}
+	return false
+}
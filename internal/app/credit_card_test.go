@@ -0,0 +1,76 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLuhnValid(t *testing.T) {
+	tests := []struct {
+		name     string
+		digits   string
+		expected bool
+	}{
+		{name: "Valid visa", digits: "4111111111111111", expected: true},
+		{name: "Valid amex", digits: "378282246310005", expected: true},
+		{name: "Wrong check digit", digits: "4111111111111112", expected: false},
+		{name: "Too short", digits: "42", expected: false},
+		{name: "Not a number", digits: "4111a11111111111", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, LuhnValid(tt.digits))
+		})
+	}
+}
+
+func TestCardBrand(t *testing.T) {
+	tests := []struct {
+		number   string
+		expected string
+	}{
+		{number: "4111 1111 1111 1111", expected: CardBrandVisa},
+		{number: "5555-5555-5555-4444", expected: CardBrandMastercard},
+		{number: "2223003122003222", expected: CardBrandMastercard},
+		{number: "378282246310005", expected: CardBrandAmex},
+		{number: "6011111111111117", expected: CardBrandDiscover},
+		{number: "30569309025904", expected: CardBrandDiners},
+		{number: "3530111333300000", expected: CardBrandJCB},
+		{number: "6200000000000005", expected: CardBrandUnionPay},
+		{number: "9792000000000001", expected: CardBrandTroy},
+		{number: "1234", expected: CardBrandUnknown},
+		{number: "", expected: CardBrandUnknown},
+	}
+	for _, tt := range tests {
+		t.Run(tt.number, func(t *testing.T) {
+			assert.Equal(t, tt.expected, CardBrand(tt.number))
+		})
+	}
+}
+
+func TestValidateCreditCard(t *testing.T) {
+	tests := []struct {
+		name    string
+		dto     model.CreditCardDTO
+		errsLen int
+	}{
+		{name: "Empty card", dto: model.CreditCardDTO{}, errsLen: 0},
+		{name: "Valid card", dto: model.CreditCardDTO{Number: "4111 1111 1111 1111", ExpiryDate: "12/2022"}, errsLen: 0},
+		{name: "Short expiry year", dto: model.CreditCardDTO{ExpiryDate: "01/25"}, errsLen: 0},
+		{name: "Invalid number", dto: model.CreditCardDTO{Number: "1234-5678-1234-5678"}, errsLen: 1},
+		{name: "Invalid month", dto: model.CreditCardDTO{ExpiryDate: "13/2022"}, errsLen: 1},
+		{name: "Invalid both", dto: model.CreditCardDTO{Number: "1", ExpiryDate: "2022-12"}, errsLen: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Len(t, ValidateCreditCard(&tt.dto), tt.errsLen)
+		})
+	}
+}
+
+func TestMaskCardNumber(t *testing.T) {
+	assert.Equal(t, "************1111", model.MaskCardNumber("4111 1111 1111 1111"))
+	assert.Equal(t, "123", model.MaskCardNumber("123"))
+}
@@ -1,9 +1,171 @@
 package app
 
-// StartCronJob ...
-/* func StartCronJob(s storage.Store) {
-	backupPeriod := viper.GetString("backup.period")
-	cron := cron.New()
-	cron.AddFunc(fmt.Sprintf("@every %s", backupPeriod), func() { BackupData(s) })
-	cron.Start()
-} */
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var errCron = errors.New("schedule should be a cron expression like \"0 3 * * *\" or @daily")
+
+// cronMacros are the shorthands of the common schedules
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSchedule is a cron expression of the minute, hour, day of month, month and day of
+// week fields. A bit of a field is set for each value which matches.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// A day matches one of the day fields when both are restricted, like in cron
+	domAll, dowAll bool
+}
+
+// parseCron parses a cron expression like "30 2 * * 1-5" in the time zone of the server.
+// Fields take *, values, ranges and steps like */15 or 1-10/2, separated by commas.
+// Days of the week are 0 to 7, both 0 and 7 are Sunday.
+func parseCron(expr string) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if macro, ok := cronMacros[expr]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, errCron
+	}
+
+	c := &cronSchedule{}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, err
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, err
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, err
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, err
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, err
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	c.domAll = strings.HasPrefix(fields[2], "*")
+	c.dowAll = strings.HasPrefix(fields[4], "*")
+
+	if c.next(time.Now()).IsZero() {
+		return nil, errCron
+	}
+	return c, nil
+}
+
+// parseCronField returns the bits of the values of the field between min and max
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, errCron
+			}
+			step, part = n, part[:i]
+		}
+
+		from, to := min, max
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if from, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, errCron
+			}
+			if to, err = strconv.Atoi(bounds[1]); err != nil {
+				return 0, errCron
+			}
+		default:
+			n, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, errCron
+			}
+			from, to = n, n
+			// A step after a value runs from the value to the end, like 5/10
+			if step > 1 {
+				to = max
+			}
+		}
+		if from < min || to > max || from > to {
+			return 0, errCron
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// next returns the first time of the schedule after t, or the zero time when the
+// schedule has no time in the next five years, like on February 30
+func (c *cronSchedule) next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+
+	for t.Before(end) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+func (c *cronSchedule) matchDay(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAll || c.dowAll {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// onSchedule runs the job at the times of the schedule until the shutdown
+func onSchedule(c *cronSchedule, job func(now time.Time)) {
+	goBackground(func() {
+		for {
+			timer := time.NewTimer(time.Until(c.next(time.Now())))
+			select {
+			case <-background.stopping:
+				timer.Stop()
+				return
+			case now := <-timer.C:
+				job(now)
+			}
+		}
+	})
+}
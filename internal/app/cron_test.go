@@ -0,0 +1,46 @@
+package app
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *", "0 0 30 2 *"} {
+		if _, err := parseCron(expr); err == nil {
+			t.Errorf("parseCron(%q) is valid", expr)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 2026-01-07 is a Wednesday
+	from := time.Date(2026, 1, 7, 10, 30, 15, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{expr: "* * * * *", want: time.Date(2026, 1, 7, 10, 31, 0, 0, time.UTC)},
+		{expr: "0 3 * * *", want: time.Date(2026, 1, 8, 3, 0, 0, 0, time.UTC)},
+		{expr: "@daily", want: time.Date(2026, 1, 8, 0, 0, 0, 0, time.UTC)},
+		{expr: "@hourly", want: time.Date(2026, 1, 7, 11, 0, 0, 0, time.UTC)},
+		{expr: "*/20 * * * *", want: time.Date(2026, 1, 7, 10, 40, 0, 0, time.UTC)},
+		{expr: "15,45 9-17 * * *", want: time.Date(2026, 1, 7, 10, 45, 0, 0, time.UTC)},
+		{expr: "0 12 * * 1-5", want: time.Date(2026, 1, 7, 12, 0, 0, 0, time.UTC)},
+		{expr: "0 0 * * 7", want: time.Date(2026, 1, 11, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 1 */3 *", want: time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{expr: "0 0 29 2 *", want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Either day field matches when both are restricted
+		{expr: "0 0 20 * 5", want: time.Date(2026, 1, 9, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		c, err := parseCron(tt.expr)
+		if err != nil {
+			t.Errorf("parseCron(%q) error = %v", tt.expr, err)
+			continue
+		}
+		if got := c.next(from); !got.Equal(tt.want) {
+			t.Errorf("next of %q = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package crypto
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// awsKMS wraps with the Encrypt and Decrypt actions of AWS KMS, requests are signed with
+// Signature Version 4
+type awsKMS struct {
+	keyID, region, endpoint     string
+	accessKey, secretKey, token string
+}
+
+func newAWS(cfg Config) (*awsKMS, error) {
+	k := &awsKMS{
+		keyID:     cfg.KeyID,
+		region:    cfg.Region,
+		endpoint:  cfg.Endpoint,
+		accessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		secretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		token:     os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if k.region == "" {
+		k.region = os.Getenv("AWS_REGION")
+	}
+	if k.endpoint == "" {
+		k.endpoint = "https://kms." + k.region + ".amazonaws.com/"
+	}
+	if k.region == "" || k.accessKey == "" || k.secretKey == "" {
+		return nil, errCredentials
+	}
+	return k, nil
+}
+
+func (k *awsKMS) Wrap(ctx context.Context, plaintext []byte) (string, error) {
+	var out struct{ CiphertextBlob string }
+	in := map[string]string{"KeyId": k.keyID, "Plaintext": base64.StdEncoding.EncodeToString(plaintext)}
+	if err := k.call(ctx, "Encrypt", in, &out); err != nil {
+		return "", err
+	}
+	return out.CiphertextBlob, nil
+}
+
+func (k *awsKMS) Unwrap(ctx context.Context, wrapped string) ([]byte, error) {
+	var out struct{ Plaintext string }
+	in := map[string]string{"KeyId": k.keyID, "CiphertextBlob": wrapped}
+	if err := k.call(ctx, "Decrypt", in, &out); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Plaintext)
+}
+
+func (k *awsKMS) call(ctx context.Context, action string, in, out interface{}) error {
+	return postJSON(ctx, k.endpoint, in, out, func(req *http.Request, body []byte) error {
+		req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+		req.Header.Set("X-Amz-Target", "TrentService."+action)
+		if k.token != "" {
+			req.Header.Set("X-Amz-Security-Token", k.token)
+		}
+		signV4(req, body, "kms", k.region, k.accessKey, k.secretKey, time.Now())
+		return nil
+	})
+}
+
+// signV4 signs the request and all of its headers with Signature Version 4 of AWS
+func signV4(req *http.Request, body []byte, service, region, accessKey, secretKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hexSHA256(body),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, awsEscape(key)+"="+awsEscape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// awsEscape percent-encodes like RFC 3986, url.QueryEscape writes spaces as +
+func awsEscape(s string) string {
+	return strings.Replace(url.QueryEscape(s), "+", "%20", -1)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
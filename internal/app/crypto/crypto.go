@@ -0,0 +1,98 @@
+// Package crypto wraps the data key of the server with a key management service, so the
+// key which encrypts the vaults isn't kept in plaintext. The wrapping key never leaves the
+// KMS or HSM, the server only holds the unwrapped data key in memory.
+package crypto
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/tracing"
+)
+
+// Providers of kms.provider
+const (
+	ProviderAWS   = "aws"
+	ProviderGCP   = "gcp"
+	ProviderVault = "vault"
+)
+
+var (
+	errUnknownProvider = errors.New("unknown key provider")
+	errMissingKeyID    = errors.New("key id of the key provider is required")
+	errCredentials     = errors.New("credentials of the key provider are missing")
+)
+
+// KeyProvider wraps and unwraps data keys with a key of the KMS or HSM
+type KeyProvider interface {
+	Wrap(ctx context.Context, plaintext []byte) (string, error)
+	Unwrap(ctx context.Context, wrapped string) ([]byte, error)
+}
+
+// Config selects the provider and its key. Credentials which are empty are read from the
+// environment the way the tools of the provider do.
+type Config struct {
+	Provider string // aws, gcp or vault
+	KeyID    string // key id, ARN or alias of AWS, resource name of GCP, transit key of Vault
+	Region   string // region of AWS, AWS_REGION if empty
+	Endpoint string // url of the API, the public one of the provider or VAULT_ADDR if empty
+	Token    string // Vault token or GCP access token
+	Mount    string // mount of the Vault transit engine, transit if empty
+}
+
+// httpClient sends the requests to the providers
+var httpClient = &http.Client{Transport: tracing.Transport(nil), Timeout: 10 * time.Second}
+
+// New returns the key provider of the configuration
+func New(cfg Config) (KeyProvider, error) {
+	if cfg.KeyID == "" {
+		return nil, errMissingKeyID
+	}
+	switch cfg.Provider {
+	case ProviderAWS:
+		return newAWS(cfg)
+	case ProviderGCP:
+		return newGCP(cfg), nil
+	case ProviderVault:
+		return newVault(cfg)
+	}
+	return nil, fmt.Errorf("%w %q", errUnknownProvider, cfg.Provider)
+}
+
+// postJSON posts in to the url and decodes the answer into out, header adds the
+// credentials of the provider to the request
+func postJSON(ctx context.Context, url string, in, out interface{}, header func(*http.Request, []byte) error) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if err := header(req, body); err != nil {
+		return err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("key provider responded with %d: %s", resp.StatusCode, bytes.TrimSpace(data))
+	}
+	return json.Unmarshal(data, out)
+}
@@ -0,0 +1,151 @@
+package crypto
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// kmsServer wraps by reversing the base64 of the plaintext and checks the request with check
+func kmsServer(t *testing.T, check func(r *http.Request, in map[string]string) map[string]interface{}) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		out := check(r, in)
+		if out == nil {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		json.NewEncoder(w).Encode(out)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// setenv sets the environment variable until the test ends
+func setenv(t *testing.T, key, value string) {
+	old, ok := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv(key, old)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+func assertRoundTrip(t *testing.T, provider KeyProvider) {
+	wrapped, err := provider.Wrap(context.Background(), []byte("server passphrase"))
+	require.NoError(t, err)
+	assert.NotContains(t, wrapped, base64.StdEncoding.EncodeToString([]byte("server passphrase")))
+
+	plaintext, err := provider.Unwrap(context.Background(), wrapped)
+	require.NoError(t, err)
+	assert.Equal(t, "server passphrase", string(plaintext))
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Config{Provider: ProviderVault})
+	assert.Equal(t, errMissingKeyID, err)
+
+	_, err = New(Config{Provider: "file", KeyID: "key"})
+	assert.True(t, strings.Contains(err.Error(), errUnknownProvider.Error()))
+
+	setenv(t, "VAULT_ADDR", "")
+	setenv(t, "VAULT_TOKEN", "")
+	_, err = New(Config{Provider: ProviderVault, KeyID: "key"})
+	assert.Equal(t, errCredentials, err)
+}
+
+func TestAWS(t *testing.T) {
+	setenv(t, "AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	setenv(t, "AWS_SECRET_ACCESS_KEY", "secret")
+	setenv(t, "AWS_SESSION_TOKEN", "")
+	server := kmsServer(t, func(r *http.Request, in map[string]string) map[string]interface{} {
+		assert.Equal(t, "application/x-amz-json-1.1", r.Header.Get("Content-Type"))
+		assert.Contains(t, r.Header.Get("Authorization"), "Credential=AKIDEXAMPLE/")
+		assert.Contains(t, r.Header.Get("Authorization"), "/eu-west-1/kms/aws4_request")
+		assert.Equal(t, "alias/passwall", in["KeyId"])
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.Encrypt":
+			return map[string]interface{}{"CiphertextBlob": reverse(in["Plaintext"])}
+		case "TrentService.Decrypt":
+			return map[string]interface{}{"Plaintext": reverse(in["CiphertextBlob"])}
+		}
+		return nil
+	})
+
+	provider, err := New(Config{Provider: ProviderAWS, KeyID: "alias/passwall", Region: "eu-west-1", Endpoint: server.URL})
+	require.NoError(t, err)
+	assertRoundTrip(t, provider)
+}
+
+func TestSignV4(t *testing.T) {
+	// get-vanilla of the Signature Version 4 test suite
+	req, _ := http.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	now, _ := time.Parse("20060102T150405Z", "20150830T123600Z")
+	signV4(req, nil, "service", "us-east-1", "AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", now)
+
+	assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31",
+		req.Header.Get("Authorization"))
+}
+
+func TestGCP(t *testing.T) {
+	key := "projects/p/locations/global/keyRings/r/cryptoKeys/passwall"
+	server := kmsServer(t, func(r *http.Request, in map[string]string) map[string]interface{} {
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/v1/" + key + ":encrypt":
+			return map[string]interface{}{"ciphertext": reverse(in["plaintext"])}
+		case "/v1/" + key + ":decrypt":
+			return map[string]interface{}{"plaintext": reverse(in["ciphertext"])}
+		}
+		return nil
+	})
+
+	provider, err := New(Config{Provider: ProviderGCP, KeyID: key, Endpoint: server.URL, Token: "token"})
+	require.NoError(t, err)
+	assertRoundTrip(t, provider)
+}
+
+func TestVault(t *testing.T) {
+	server := kmsServer(t, func(r *http.Request, in map[string]string) map[string]interface{} {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			return nil
+		}
+		switch r.URL.Path {
+		case "/v1/keys/encrypt/passwall":
+			return map[string]interface{}{"data": map[string]string{"ciphertext": "vault:v1:" + reverse(in["plaintext"])}}
+		case "/v1/keys/decrypt/passwall":
+			return map[string]interface{}{"data": map[string]string{"plaintext": reverse(strings.TrimPrefix(in["ciphertext"], "vault:v1:"))}}
+		}
+		return nil
+	})
+
+	provider, err := New(Config{Provider: ProviderVault, KeyID: "passwall", Endpoint: server.URL, Token: "token", Mount: "keys"})
+	require.NoError(t, err)
+	assertRoundTrip(t, provider)
+
+	provider, _ = New(Config{Provider: ProviderVault, KeyID: "passwall", Endpoint: server.URL, Token: "wrong", Mount: "keys"})
+	_, err = provider.Wrap(context.Background(), []byte("server passphrase"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,87 @@
+package crypto
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// gcpMetadataToken is where instances of GCP get the access token of their service account
+const gcpMetadataToken = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// gcpKMS wraps with the encrypt and decrypt methods of Cloud KMS
+type gcpKMS struct {
+	keyID, endpoint, token string
+	metadataURL            string
+}
+
+func newGCP(cfg Config) *gcpKMS {
+	k := &gcpKMS{keyID: cfg.KeyID, endpoint: cfg.Endpoint, token: cfg.Token, metadataURL: gcpMetadataToken}
+	if k.endpoint == "" {
+		k.endpoint = "https://cloudkms.googleapis.com"
+	}
+	if k.token == "" {
+		k.token = os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN")
+	}
+	return k
+}
+
+func (k *gcpKMS) Wrap(ctx context.Context, plaintext []byte) (string, error) {
+	var out struct {
+		Ciphertext string `json:"ciphertext"`
+	}
+	in := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}
+	if err := k.call(ctx, "encrypt", in, &out); err != nil {
+		return "", err
+	}
+	return out.Ciphertext, nil
+}
+
+func (k *gcpKMS) Unwrap(ctx context.Context, wrapped string) ([]byte, error) {
+	var out struct {
+		Plaintext string `json:"plaintext"`
+	}
+	if err := k.call(ctx, "decrypt", map[string]string{"ciphertext": wrapped}, &out); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Plaintext)
+}
+
+func (k *gcpKMS) call(ctx context.Context, method string, in, out interface{}) error {
+	token, err := k.accessToken(ctx)
+	if err != nil {
+		return err
+	}
+	url := strings.TrimSuffix(k.endpoint, "/") + "/v1/" + k.keyID + ":" + method
+	return postJSON(ctx, url, in, out, func(req *http.Request, _ []byte) error {
+		req.Header.Set("Authorization", "Bearer "+token)
+		return nil
+	})
+}
+
+// accessToken returns the configured token, or the one of the service account of the instance
+func (k *gcpKMS) accessToken(ctx context.Context) (string, error) {
+	if k.token != "" {
+		return k.token, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, k.metadataURL, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	resp, err := httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", errCredentials
+	}
+	defer resp.Body.Close()
+	var token struct {
+		AccessToken string `json:"access_token"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&token) != nil || token.AccessToken == "" {
+		return "", errCredentials
+	}
+	return token.AccessToken, nil
+}
@@ -0,0 +1,65 @@
+package crypto
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// vaultTransit wraps with the transit secrets engine of HashiCorp Vault
+type vaultTransit struct {
+	keyID, address, token, mount string
+}
+
+func newVault(cfg Config) (*vaultTransit, error) {
+	k := &vaultTransit{keyID: cfg.KeyID, address: cfg.Endpoint, token: cfg.Token, mount: cfg.Mount}
+	if k.address == "" {
+		k.address = os.Getenv("VAULT_ADDR")
+	}
+	if k.token == "" {
+		k.token = os.Getenv("VAULT_TOKEN")
+	}
+	if k.mount == "" {
+		k.mount = "transit"
+	}
+	if k.address == "" || k.token == "" {
+		return nil, errCredentials
+	}
+	return k, nil
+}
+
+func (k *vaultTransit) Wrap(ctx context.Context, plaintext []byte) (string, error) {
+	var out struct {
+		Data struct {
+			Ciphertext string `json:"ciphertext"`
+		} `json:"data"`
+	}
+	in := map[string]string{"plaintext": base64.StdEncoding.EncodeToString(plaintext)}
+	if err := k.call(ctx, "encrypt", in, &out); err != nil {
+		return "", err
+	}
+	return out.Data.Ciphertext, nil
+}
+
+func (k *vaultTransit) Unwrap(ctx context.Context, wrapped string) ([]byte, error) {
+	var out struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := k.call(ctx, "decrypt", map[string]string{"ciphertext": wrapped}, &out); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(out.Data.Plaintext)
+}
+
+func (k *vaultTransit) call(ctx context.Context, operation string, in, out interface{}) error {
+	endpoint := strings.TrimSuffix(k.address, "/") + "/v1/" + k.mount + "/" + operation + "/" + url.PathEscape(k.keyID)
+	return postJSON(ctx, endpoint, in, out, func(req *http.Request, _ []byte) error {
+		req.Header.Set("X-Vault-Token", k.token)
+		return nil
+	})
+}
@@ -0,0 +1,126 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// SignupDisposableEmail is the error code of signups with a disposable email address
+const SignupDisposableEmail = "DISPOSABLE_EMAIL"
+
+// ErrDisposableEmail is returned when the server policy blocks the email domain of a signup
+var ErrDisposableEmail = errors.New("disposable email addresses can't sign up")
+
+// disposableDomains are well known throwaway email providers. Keep it sorted,
+// operators add their own in the file of server.disposableDomainsFile.
+var disposableDomains = []string{
+	"10minutemail.com",
+	"10minutemail.net",
+	"1secmail.com",
+	"1secmail.net",
+	"1secmail.org",
+	"burnermail.io",
+	"discard.email",
+	"dispostable.com",
+	"emailfake.com",
+	"emailondeck.com",
+	"fakeinbox.com",
+	"getairmail.com",
+	"getnada.com",
+	"grr.la",
+	"guerrillamail.biz",
+	"guerrillamail.com",
+	"guerrillamail.de",
+	"guerrillamail.net",
+	"guerrillamail.org",
+	"guerrillamailblock.com",
+	"inboxkitten.com",
+	"maildrop.cc",
+	"mailinator.com",
+	"mailinator.net",
+	"mailnesia.com",
+	"mintemail.com",
+	"moakt.com",
+	"mohmal.com",
+	"mytemp.email",
+	"nada.email",
+	"sharklasers.com",
+	"spam4.me",
+	"spamgourmet.com",
+	"temp-mail.org",
+	"tempinbox.com",
+	"tempmail.com",
+	"tempmail.net",
+	"tempmailo.com",
+	"tempr.email",
+	"throwawaymail.com",
+	"tmpmail.org",
+	"trashmail.com",
+	"trashmail.net",
+	"yopmail.com",
+	"yopmail.fr",
+	"yopmail.net",
+}
+
+// CheckSignupEmail returns ErrDisposableEmail when the server policy blocks disposable
+// emails and the domain of email, or a parent of it, is on the list
+func CheckSignupEmail(s storage.Store, email string) error {
+	policy, err := FindPolicy(s, ServerPolicyID)
+	if err != nil {
+		return err
+	}
+	if !policy.BlockDisposableEmails {
+		return nil
+	}
+
+	domain := strings.ToLower(email[strings.LastIndex(email, "@")+1:])
+	blocked := disposableDomainSet()
+	for domain != "" {
+		if blocked[domain] {
+			return ErrDisposableEmail
+		}
+		i := strings.Index(domain, ".")
+		if i < 0 {
+			break
+		}
+		domain = domain[i+1:]
+	}
+	return nil
+}
+
+// disposableDomainSet returns the built in domains with the ones of the operator.
+// The file has a domain per line, lines starting with # are comments.
+// It is read on every signup, so changes apply without a restart.
+func disposableDomainSet() map[string]bool {
+	set := make(map[string]bool, len(disposableDomains))
+	for _, domain := range disposableDomains {
+		set[domain] = true
+	}
+
+	path := viper.GetString("server.disposableDomainsFile")
+	if path == "" {
+		return set
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		log.WithError(err).Warn("disposable email domains couldn't be read")
+		return set
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.ToLower(strings.TrimSpace(scanner.Text()))
+		if line != "" && !strings.HasPrefix(line, "#") {
+			set[line] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSignupEmail(t *testing.T) {
+	mocks := storagetest.NewMocks()
+	mocks.Policies.On("FindByUserID", uint(ServerPolicyID)).Return(&model.Policy{BlockDisposableEmails: true}, nil)
+
+	file, err := ioutil.TempFile("", "disposable")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+	file.WriteString("# our own list\nthrowaway.example\n")
+	file.Close()
+
+	viper.Set("server.disposableDomainsFile", file.Name())
+	defer viper.Set("server.disposableDomainsFile", "")
+
+	assert.NoError(t, CheckSignupEmail(mocks.Store, "hello@passwall.io"))
+	assert.Equal(t, ErrDisposableEmail, CheckSignupEmail(mocks.Store, "someone@Mailinator.com"))
+	assert.Equal(t, ErrDisposableEmail, CheckSignupEmail(mocks.Store, "someone@eu.yopmail.com"))
+	assert.Equal(t, ErrDisposableEmail, CheckSignupEmail(mocks.Store, "someone@throwaway.example"))
+}
+
+func TestCheckSignupEmailAllowed(t *testing.T) {
+	mocks := storagetest.NewMocks()
+	mocks.Policies.On("FindByUserID", uint(ServerPolicyID)).Return(&model.Policy{}, nil)
+
+	assert.NoError(t, CheckSignupEmail(mocks.Store, "someone@mailinator.com"))
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app/passhash"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrMasterPassword is returned when the master password doesn't match
+	ErrMasterPassword = errors.New("master password is wrong")
+
+	errDuressPassword = errors.New("duress password should be different from the master password")
+)
+
+// DecoySchema returns the schema of the decoy vault of the user schema
+func DecoySchema(schema string) string {
+	return schema + "_decoy"
+}
+
+// Authenticate finds the user of the credentials. duress is true when the password
+// is the duress password of the user, the sign in is reported silently then.
+func Authenticate(s storage.Store, email, password, source string) (user *model.User, duress bool, err error) {
+	defer tracing.Start("app.Authenticate").End()
+
+	user, err = s.Users().FindByCredentials(email, password)
+	if err == nil {
+		upgradePasswordHash(s, user, &user.MasterPassword, password)
+		return user, false, nil
+	}
+
+	user, findErr := s.Users().FindByEmail(email)
+	if findErr != nil || user.DuressPassword == "" {
+		return nil, false, err
+	}
+	if passhash.Verify(user.DuressPassword, password) != nil {
+		return nil, false, err
+	}
+
+	upgradePasswordHash(s, user, &user.DuressPassword, password)
+	alertDuress(user, source)
+	return user, true, nil
+}
+
+// SetDuressPassword sets the duress password of the user and creates the empty decoy vault
+// which it opens. The master password confirms the change.
+func SetDuressPassword(s storage.Store, user *model.User, dto *model.DuressDTO) (*model.User, error) {
+	if !checkMasterPassword(user, dto.MasterPassword) {
+		return nil, ErrMasterPassword
+	}
+	if dto.MasterPassword == dto.DuressPassword {
+		return nil, errDuressPassword
+	}
+
+	if user.DuressPassword == "" {
+		schema := DecoySchema(user.Schema)
+		if err := s.Users().CreateSchema(schema); err != nil {
+			return nil, err
+		}
+		MigrateUserTables(s, schema)
+	}
+
+	hash, err := NewPasswordHash(dto.DuressPassword)
+	if err != nil {
+		return nil, err
+	}
+	user.DuressPassword = hash
+	return s.Users().Save(user)
+}
+
+// RemoveDuressPassword removes the duress password and deletes the decoy vault
+func RemoveDuressPassword(s storage.Store, user *model.User, masterPassword string) (*model.User, error) {
+	if !checkMasterPassword(user, masterPassword) {
+		return nil, ErrMasterPassword
+	}
+	if user.DuressPassword == "" {
+		return user, nil
+	}
+
+	deleteVaultAttachments(DecoySchema(user.Schema))
+	if err := s.Users().DropSchema(DecoySchema(user.Schema)); err != nil {
+		return nil, err
+	}
+
+	user.DuressPassword = ""
+	return s.Users().Save(user)
+}
+
+func checkMasterPassword(user *model.User, masterPassword string) bool {
+	return passhash.Verify(user.MasterPassword, masterPassword) == nil
+}
+
+// alertDuress reports the duress sign in to the admin channels only,
+// the user may be watched while signing in
+func alertDuress(user *model.User, source string) {
+	log.WithFields(log.Fields{
+		"event":   "duress_signin",
+		"user_id": user.ID,
+		"source":  source,
+	}).Error("user signed in with the duress password")
+
+	SendAlert(&Alert{
+		Event:   "duress_signin",
+		Subject: "Passwall Duress Alert",
+		Message: "A user signed in with the duress password and may be forced to unlock the vault. The decoy vault is open.",
+		Time:    time.Now(),
+		Fields: map[string]string{
+			"User ID": strconv.FormatUint(uint64(user.ID), 10),
+			"User":    user.Email,
+			"Source":  source,
+		},
+	})
+}
@@ -0,0 +1,59 @@
+package app
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthenticateDuress(t *testing.T) {
+	defer func(notifiers []Notifier) { Notifiers = notifiers }(Notifiers)
+	Notifiers = nil
+
+	user := &model.User{ID: 1, Email: "test@passwall.io", DuressPassword: NewBcrypt([]byte("duress-password"))}
+	errCredentials := errors.New("record not found")
+
+	mocks := storagetest.NewMocks()
+	mocks.Users.On("FindByCredentials", "test@passwall.io", "duress-password").Return(nil, errCredentials)
+	mocks.Users.On("FindByCredentials", "test@passwall.io", "guess").Return(nil, errCredentials)
+	mocks.Users.On("FindByEmail", "test@passwall.io").Return(user, nil)
+	mocks.Users.On("Save", user).Return(user, nil).Once()
+
+	found, duress, err := Authenticate(mocks.Store, "test@passwall.io", "duress-password", "203.0.113.9")
+	assert.NoError(t, err)
+	assert.True(t, duress)
+	assert.Equal(t, user, found)
+	// The bcrypt hash of older versions is replaced
+	assert.True(t, strings.HasPrefix(user.DuressPassword, "$argon2id$"))
+
+	_, duress, err = Authenticate(mocks.Store, "test@passwall.io", "guess", "203.0.113.9")
+	assert.Equal(t, errCredentials, err)
+	assert.False(t, duress)
+	mocks.Users.AssertExpectations(t)
+}
+
+func TestDuressSessionToken(t *testing.T) {
+	viper.Set("server.secret", "duress-test-secret")
+	viper.Set("server.accessTokenExpireDuration", "30m")
+	viper.Set("server.refreshTokenExpireDuration", "15d")
+	viper.Set("server.generatedPasswordLength", 16)
+
+	start := time.Now().Add(-time.Hour).Truncate(time.Second)
+	tokens, err := CreateSessionToken(&model.User{ID: 1, Role: "Admin"}, &Session{Start: start, Duress: true})
+	assert.NoError(t, err)
+
+	token, err := TokenValid(tokens.AccessToken)
+	assert.NoError(t, err)
+	claims := token.Claims.(jwt.MapClaims)
+
+	// Duress sessions never have admin rights
+	assert.Equal(t, false, claims["authorized"])
+	assert.Equal(t, &Session{Start: start, Duress: true}, SessionOf(claims))
+}
@@ -2,35 +2,56 @@ package app
 
 import (
 	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
 	"github.com/passwall/passwall-server/model"
 )
 
 // CreateEmail creates a new bank account and saves it to the store
 func CreateEmail(s storage.Store, dto *model.EmailDTO, schema string) (*model.Email, error) {
+	defer tracing.Start("app.CreateEmail").End()
+
 	rawModel := model.ToEmail(dto)
-	encModel := EncryptModel(rawModel)
 
-	createdEmail, err := s.Emails().Save(encModel.(*model.Email), schema)
+	createdEmail, err := s.Emails().Save(rawModel, schema)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := SetItemTags(s, createdEmail, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return createdEmail, nil
 }
 
 // UpdateEmail updates the account with the dto and applies the changes in the store
 func UpdateEmail(s storage.Store, email *model.Email, dto *model.EmailDTO, schema string) (*model.Email, error) {
+	defer tracing.Start("app.UpdateEmail").End()
+
+	if err := saveItemVersion(s, email, schema); err != nil {
+		return nil, err
+	}
+
 	rawModel := model.ToEmail(dto)
-	encModel := EncryptModel(rawModel).(*model.Email)
 
-	email.Title = encModel.Title
-	email.Email = encModel.Email
-	email.Password = encModel.Password
+	email.Title = rawModel.Title
+	email.Email = rawModel.Email
+	email.Password = rawModel.Password
+	email.Pinned = rawModel.Pinned
+	email.IsFavorite = rawModel.IsFavorite
+	email.SortOrder = rawModel.SortOrder
+	email.FolderID = rawModel.FolderID
+	email.Reprompt = rawModel.Reprompt
+	email.Canary = rawModel.Canary
 
 	updatedEmail, err := s.Emails().Save(email, schema)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := SetItemTags(s, updatedEmail, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return updatedEmail, nil
 }
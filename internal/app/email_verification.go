@@ -0,0 +1,113 @@
+package app
+
+import (
+	"crypto/subtle"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/i18n"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// SigninEmailNotVerified is the error code of sign ins of unverified accounts
+const SigninEmailNotVerified = "EMAIL_NOT_VERIFIED"
+
+const verificationLinkExpiry = 24 * time.Hour
+
+// ErrVerificationToken is returned for verification links which are expired, changed
+// or replaced by a newer one
+var ErrVerificationToken = errors.New("Verification link is expired or not valid")
+
+// EmailUnverified is true when the user signed up and hasn't opened the verification link.
+// Accounts which admins created don't wait for one.
+func EmailUnverified(user *model.User) bool {
+	return user.ConfirmationCode != "" && user.EmailVerifiedAt.IsZero()
+}
+
+// EmailVerificationRequired is true when server.requireEmailVerification blocks the sign ins of the user
+func EmailVerificationRequired(user *model.User) bool {
+	return viper.GetBool("server.requireEmailVerification") && EmailUnverified(user)
+}
+
+// SendVerificationEmail sends a new verification link to the user in its language,
+// the links it sent before stop working
+func SendVerificationEmail(s storage.Store, user *model.User) error {
+	user.ConfirmationCode = RandomMD5Hash()
+	if _, err := s.Users().Save(user); err != nil {
+		return err
+	}
+
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"code":    user.ConfirmationCode,
+		"purpose": "email_verification",
+		"exp":     time.Now().Add(verificationLinkExpiry).Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(viper.GetString("server.secret")))
+	if err != nil {
+		return err
+	}
+
+	body, err := i18n.Render(user.Locale, "email.confirmation.body", map[string]string{
+		"Link": viper.GetString("server.domain") + "/api/auth/verify?token=" + token,
+	})
+	if err != nil {
+		return err
+	}
+	SendMail(user.Name, user.Email, i18n.T(user.Locale, "email.confirmation.subject"), body)
+	return nil
+}
+
+// ResendVerificationEmail sends a new verification link to the unverified account of the
+// email. Each address can ask budget.verification times, unknown and verified ones too,
+// so the answers don't tell which accounts exist. The status tells when an address which
+// spent its budget can ask again.
+func ResendVerificationEmail(s storage.Store, email string, now time.Time) (*BudgetStatus, error) {
+	status := spendBudget("email:"+strings.ToLower(strings.TrimSpace(email)), BudgetVerification, now)
+	if !status.Allowed {
+		return status, nil
+	}
+
+	user, err := s.Users().FindByEmail(email)
+	if err != nil || !EmailUnverified(user) {
+		return status, nil
+	}
+	return status, SendVerificationEmail(s, user)
+}
+
+// VerifyEmail verifies the account of the verification link
+func VerifyEmail(s storage.Store, link string) (*model.User, error) {
+	token, err := verifyToken(link)
+	if err != nil || !token.Valid {
+		return nil, ErrVerificationToken
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if purpose, _ := claims["purpose"].(string); purpose != "email_verification" {
+		return nil, ErrVerificationToken
+	}
+	userID, _ := claims["user_id"].(float64)
+	code, _ := claims["code"].(string)
+
+	user, err := s.Users().FindByID(uint(userID))
+	if err != nil || !EmailUnverified(user) {
+		return nil, ErrVerificationToken
+	}
+	if subtle.ConstantTimeCompare([]byte(code), []byte(user.ConfirmationCode)) != 1 {
+		return nil, ErrVerificationToken
+	}
+
+	user.EmailVerifiedAt = time.Now()
+	if _, err := s.Users().Save(user); err != nil {
+		return nil, err
+	}
+	log.WithFields(log.Fields{
+		"event":   "email_verified",
+		"user_id": user.ID,
+	}).Info("email address of the user is verified")
+	return user, nil
+}
@@ -3,8 +3,10 @@ package app
 import (
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
@@ -13,17 +15,36 @@ import (
 	"io/ioutil"
 	mathRand "math/rand"
 	"os"
-	"reflect"
+	"strings"
 	"time"
 
 	"github.com/Luzifer/go-openssl/v4"
+	"github.com/passwall/passwall-server/internal/storage/fieldcipher"
 	"github.com/spf13/viper"
 	"golang.org/x/crypto/bcrypt"
 )
 
+// Ciphers of encrypted fields, server.cipher is used for new values. Both are AES-256-GCM,
+// v1 keys are the md5 hex of the passphrase and v2 keys its sha256. v1 values are base64,
+// v2 values have the v2 prefix which can't be in base64.
+const (
+	CipherV1 = "v1"
+	CipherV2 = "v2"
+
+	cipherV2Prefix = "v2:"
+
+	// blindIndexContext keeps the key of the blind indexes apart from the keys of the fields
+	blindIndexContext = "passwall blind index"
+)
+
 var (
 	minSecureKeyLength = 8
 	errShortSecureKey  = errors.New("length of secure key does not meet with minimum requirements")
+	errCipher          = errors.New("server.cipher should be v1 or v2")
+	errFieldDecrypt    = errors.New("field can't be decrypted with the server passphrase or the previous one")
+
+	// ErrZeroKnowledge is returned for features which read the vault in zero-knowledge mode
+	ErrZeroKnowledge = errors.New("the server can't read the vault in zero-knowledge mode")
 )
 
 // FindIndex ...
@@ -145,45 +166,152 @@ func DecryptFile(filename string, passphrase string) []byte {
 	return Decrypt(string(data[:]), passphrase)
 }
 
-// EncryptModel encrypts struct pointer according to struct tags
-func EncryptModel(rawModel interface{}) interface{} {
-	num := reflect.ValueOf(rawModel).Elem().NumField()
+func init() {
+	fieldcipher.Use(storeCipher{})
+}
 
-	var tagVal string
+// storeCipher encrypts the tagged fields of the models in the storage layer with the
+// server passphrase. In zero-knowledge mode the fields of the vault are kept as the
+// client encrypted them, secrets of the server like TOTP seeds are still encrypted.
+type storeCipher struct{}
 
-	for i := 0; i < num; i++ {
-		tagVal = reflect.TypeOf(rawModel).Elem().Field(i).Tag.Get("encrypt")
-		value := reflect.ValueOf(rawModel).Elem().Field(i).String()
+func (storeCipher) EncryptField(value string, vault bool) string {
+	if vault && ZeroKnowledge() {
+		return value
+	}
+	return encryptField(value)
+}
 
-		if tagVal == "true" {
-			value = base64.StdEncoding.EncodeToString(Encrypt(value, viper.GetString("server.passphrase")))
-			reflect.ValueOf(rawModel).Elem().Field(i).SetString(value)
-		}
+func (storeCipher) DecryptField(value string, vault bool) (string, error) {
+	if vault && ZeroKnowledge() {
+		return value, nil
 	}
+	plain, _, err := decryptField(value)
+	return plain, err
+}
+
+// BlindIndex is the first 64 bits of the HMAC-SHA256 of the term with a key derived from
+// the server passphrase. Terms of the vault aren't indexed in zero-knowledge mode.
+func (storeCipher) BlindIndex(term string) string {
+	if ZeroKnowledge() {
+		return ""
+	}
+	key := hmac.New(sha256.New, []byte(viper.GetString("server.passphrase")))
+	key.Write([]byte(blindIndexContext))
+	mac := hmac.New(sha256.New, key.Sum(nil))
+	mac.Write([]byte(term))
+	return hex.EncodeToString(mac.Sum(nil)[:8])
+}
 
+// EncryptModel encrypts the tagged fields of a struct pointer which isn't saved through
+// the store, models of the store are encrypted by it
+func EncryptModel(rawModel interface{}) interface{} {
+	fieldcipher.EncryptFields(rawModel)
 	return rawModel
 }
 
-// DecryptModel decrypts struct pointer according to struct tags
+// DecryptModel decrypts the tagged fields of a struct pointer which isn't read through
+// the store, models of the store are decrypted by it
 func DecryptModel(rawModel interface{}) (interface{}, error) {
-	var err error
-	var valueByte []byte
-	num := reflect.ValueOf(rawModel).Elem().NumField()
+	err := fieldcipher.DecryptFields(rawModel)
+	return rawModel, err
+}
 
-	var tagVal string
+// ZeroKnowledge is true when server.zeroKnowledge keeps the server from reading the vaults.
+// Clients encrypt the items themselves and the server stores them as they are.
+func ZeroKnowledge() bool {
+	return viper.GetBool("server.zeroKnowledge")
+}
+
+// CheckCipher returns an error when server.cipher is unknown
+func CheckCipher() error {
+	switch viper.GetString("server.cipher") {
+	case "", CipherV1, CipherV2:
+		return nil
+	}
+	return errCipher
+}
 
-	for i := 0; i < num; i++ {
-		tagVal = reflect.TypeOf(rawModel).Elem().Field(i).Tag.Get("encrypt")
-		value := reflect.ValueOf(rawModel).Elem().Field(i).String()
+// encryptField encrypts the value with the server passphrase and server.cipher
+func encryptField(value string) string {
+	passphrase := viper.GetString("server.passphrase")
+	if viper.GetString("server.cipher") == CipherV2 {
+		return cipherV2Prefix + base64.StdEncoding.EncodeToString(sealV2(value, passphrase))
+	}
+	return base64.StdEncoding.EncodeToString(Encrypt(value, passphrase))
+}
+
+// decryptField decrypts the value with the server passphrase, or with server.previousPassphrase
+// while a key rotation runs. current is false when the value has to be re-encrypted because
+// of the previous passphrase or an older cipher. Empty values were never encrypted.
+func decryptField(value string) (plain string, current bool, err error) {
+	if value == "" {
+		return "", true, nil
+	}
+
+	cipherName, open := CipherV1, openV1
+	if strings.HasPrefix(value, cipherV2Prefix) {
+		cipherName, open = CipherV2, openV2
+		value = value[len(cipherV2Prefix):]
+	}
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", false, err
+	}
 
-		if tagVal == "true" {
-			valueByte, err = base64.StdEncoding.DecodeString(value)
-			value = string(Decrypt(string(valueByte[:]), viper.GetString("server.passphrase")))
-			reflect.ValueOf(rawModel).Elem().Field(i).SetString(value)
+	wanted := viper.GetString("server.cipher")
+	if wanted == "" {
+		wanted = CipherV1
+	}
+	if plain, ok := open(data, viper.GetString("server.passphrase")); ok {
+		return plain, cipherName == wanted, nil
+	}
+	if previous := viper.GetString("server.previousPassphrase"); previous != "" {
+		if plain, ok := open(data, previous); ok {
+			return plain, false, nil
 		}
 	}
+	return "", false, errFieldDecrypt
+}
 
-	return rawModel, err
+// openV1 is Decrypt without panics for wrong keys
+func openV1(data []byte, passphrase string) (string, bool) {
+	return openGCM(data, []byte(CreateHash(passphrase)))
+}
+
+func sealV2(value, passphrase string) []byte {
+	key := sha256.Sum256([]byte(passphrase))
+	block, _ := aes.NewCipher(key[:])
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err.Error())
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		panic(err.Error())
+	}
+	return gcm.Seal(nonce, nonce, []byte(value), nil)
+}
+
+func openV2(data []byte, passphrase string) (string, bool) {
+	key := sha256.Sum256([]byte(passphrase))
+	return openGCM(data, key[:])
+}
+
+func openGCM(data, key []byte) (string, bool) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", false
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil || len(data) < gcm.NonceSize() {
+		return "", false
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", false
+	}
+	return string(plain), true
 }
 
 // DecryptJSON ...
@@ -1,11 +1,13 @@
 package app
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/go-test/deep"
 	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -83,6 +85,21 @@ func TestEncryptModel(t *testing.T) {
 	assert.Nil(t, deep.Equal(login, decLogin))
 }
 
+func TestZeroKnowledgeModel(t *testing.T) {
+	viper.Set("server.zeroKnowledge", true)
+	defer viper.Set("server.zeroKnowledge", false)
+
+	// The blobs of the client are stored and returned as they are
+	login := &model.Login{ID: 1, Title: "Baslik", Username: "blob-of-the-username", Password: "blob-of-the-password"}
+	EncryptModel(login)
+	assert.Equal(t, "blob-of-the-password", login.Password)
+	_, err := DecryptModel(login)
+	assert.NoError(t, err)
+	assert.Equal(t, "blob-of-the-username", login.Username)
+	assert.Nil(t, tablesOf("user1"))
+	assert.Equal(t, ErrZeroKnowledge, ValidateRotation(&model.LoginDTO{RotationProvider: "github"}))
+}
+
 func TestDecryptJSON(t *testing.T) {
 
 	// Define tests
@@ -140,3 +157,40 @@ func TestEncryptJSON(t *testing.T) {
 		})
 	}
 }
+
+func TestDecryptField(t *testing.T) {
+	defer viper.Set("server.passphrase", viper.GetString("server.passphrase"))
+	defer viper.Set("server.previousPassphrase", "")
+	defer viper.Set("server.cipher", "")
+
+	viper.Set("server.passphrase", "old-passphrase")
+	viper.Set("server.cipher", CipherV1)
+	v1 := encryptField("secret")
+
+	viper.Set("server.cipher", CipherV2)
+	v2 := encryptField("secret")
+	assert.True(t, strings.HasPrefix(v2, "v2:"))
+
+	// v1 values are readable and have to be upgraded
+	plain, current, err := decryptField(v1)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", plain)
+	assert.False(t, current)
+	_, current, _ = decryptField(v2)
+	assert.True(t, current)
+
+	// After a key rotation the old key only decrypts with the previous passphrase
+	viper.Set("server.passphrase", "new-passphrase")
+	_, _, err = decryptField(v2)
+	assert.Equal(t, errFieldDecrypt, err)
+	viper.Set("server.previousPassphrase", "old-passphrase")
+	plain, current, err = decryptField(v2)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", plain)
+	assert.False(t, current)
+
+	plain, current, err = decryptField("")
+	assert.NoError(t, err)
+	assert.Empty(t, plain)
+	assert.True(t, current)
+}
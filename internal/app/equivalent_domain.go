@@ -0,0 +1,137 @@
+package app
+
+import (
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	"golang.org/x/net/publicsuffix"
+)
+
+// DefaultEquivalentDomains are the built-in domain sets which belong to the same sites
+var DefaultEquivalentDomains = [][]string{
+	{"amazon.com", "amazon.ca", "amazon.co.uk", "amazon.com.au", "amazon.com.br", "amazon.com.mx", "amazon.com.tr", "amazon.de", "amazon.es", "amazon.fr", "amazon.in", "amazon.it", "amazon.nl", "amazon.co.jp"},
+	{"apple.com", "icloud.com"},
+	{"google.com", "gmail.com", "youtube.com", "google.com.tr", "google.co.uk", "google.de"},
+	{"microsoft.com", "live.com", "outlook.com", "hotmail.com", "office.com", "microsoftonline.com", "xbox.com", "skype.com"},
+	{"ebay.com", "ebay.ca", "ebay.co.uk", "ebay.com.au", "ebay.de", "ebay.fr", "ebay.it", "ebay.es"},
+	{"paypal.com", "paypal.me", "paypal-community.com"},
+	{"facebook.com", "messenger.com", "instagram.com"},
+	{"yahoo.com", "flickr.com", "tumblr.com"},
+	{"steampowered.com", "steamcommunity.com"},
+	{"atlassian.com", "atlassian.net", "bitbucket.org", "trello.com"},
+	{"github.com", "githubusercontent.com"},
+	{"sony.com", "playstation.com", "sonyentertainmentnetwork.com"},
+}
+
+// EquivalentDomains returns all domain sets for the user schema.
+// Built-in sets come first, then server wide sets and user sets.
+func EquivalentDomains(s storage.Store, schema string) ([][]string, error) {
+	sets := [][]string{}
+	sets = append(sets, DefaultEquivalentDomains...)
+
+	for _, sch := range []string{"public", schema} {
+		equivalentDomains, err := s.EquivalentDomains().All(sch)
+		if err != nil {
+			return nil, err
+		}
+		for i := range equivalentDomains {
+			sets = append(sets, equivalentDomains[i].DomainList())
+		}
+	}
+
+	return sets, nil
+}
+
+// SaveEquivalentDomain normalizes the domains of the dto and saves the set to the schema
+func SaveEquivalentDomain(s storage.Store, equivalentDomain *model.EquivalentDomain, dto *model.EquivalentDomainDTO, schema string) (*model.EquivalentDomain, error) {
+	domains := []string{}
+	for _, domain := range dto.Domains {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+		if FindIndex(domains, domain) < 0 {
+			domains = append(domains, domain)
+		}
+	}
+
+	equivalentDomain.Domains = strings.Join(domains, ",")
+
+	return s.EquivalentDomains().Save(equivalentDomain, schema)
+}
+
+// FindAutofillLogins finds the logins which belong to the site of the url
+// or to one of its equivalent domains
+func FindAutofillLogins(s storage.Store, rawURL, schema string) ([]model.Login, error) {
+	sets, err := EquivalentDomains(s, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	domains := MatchingDomains(BaseDomain(rawURL), sets)
+
+	logins, err := s.Logins().All(schema)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []model.Login{}
+	for i := range logins {
+		if FindIndex(domains, BaseDomain(logins[i].URL)) >= 0 {
+			matches = append(matches, logins[i])
+		}
+	}
+
+	return matches, nil
+}
+
+// MatchingDomains returns the domain with all domains sharing a set with it
+func MatchingDomains(domain string, sets [][]string) []string {
+	domains := []string{domain}
+	if domain == "" {
+		return domains
+	}
+
+	for _, set := range sets {
+		if FindIndex(set, domain) < 0 {
+			continue
+		}
+		for _, d := range set {
+			if FindIndex(domains, d) < 0 {
+				domains = append(domains, d)
+			}
+		}
+	}
+
+	return domains
+}
+
+// BaseDomain returns the registrable domain of the url
+// e.g. https://www.amazon.co.uk/login -> amazon.co.uk
+func BaseDomain(rawURL string) string {
+	rawURL = strings.ToLower(strings.TrimSpace(rawURL))
+	if rawURL == "" {
+		return ""
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	host := u.Hostname()
+	if net.ParseIP(host) != nil {
+		return host
+	}
+
+	domain, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil {
+		// Hosts like localhost
+		return host
+	}
+
+	return domain
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBaseDomain(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{name: "Empty url", url: "", expected: ""},
+		{name: "Url without scheme", url: "yakuter.com", expected: "yakuter.com"},
+		{name: "Url with subdomain and path", url: "https://www.amazon.de/login?x=1", expected: "amazon.de"},
+		{name: "Multi part public suffix", url: "https://signin.amazon.co.uk", expected: "amazon.co.uk"},
+		{name: "Url with port", url: "http://vault.passwall.io:3625", expected: "passwall.io"},
+		{name: "IP address", url: "http://192.168.1.1/admin", expected: "192.168.1.1"},
+		{name: "Localhost", url: "localhost:3625", expected: "localhost"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, BaseDomain(tt.url))
+		})
+	}
+}
+
+func TestMatchingDomains(t *testing.T) {
+	sets := [][]string{
+		{"amazon.com", "amazon.de"},
+		{"amazon.de", "amazon.com.tr"},
+		{"apple.com", "icloud.com"},
+	}
+
+	tests := []struct {
+		name     string
+		domain   string
+		expected []string
+	}{
+		{name: "Domain without set", domain: "passwall.io", expected: []string{"passwall.io"}},
+		{name: "Domain in one set", domain: "icloud.com", expected: []string{"icloud.com", "apple.com"}},
+		{name: "Domain in many sets", domain: "amazon.de", expected: []string{"amazon.de", "amazon.com", "amazon.com.tr"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, MatchingDomains(tt.domain, sets))
+		})
+	}
+}
@@ -0,0 +1,360 @@
+package app
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/blob"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/scrypt"
+)
+
+// exportQueueSize is the count of exports which can wait for a worker
+const exportQueueSize = 100
+
+// exportCleanupPeriod is how often expired archives are deleted
+const exportCleanupPeriod = time.Hour
+
+// exportVersion is the version of the archive content, version 2 has folders and tags
+const exportVersion = 2
+
+var (
+	// exportMagic starts every archive, the number is the version of the format
+	exportMagic = []byte("PWEXPORT1")
+
+	// ErrExportQueueFull is returned when too many exports are waiting
+	ErrExportQueueFull = errors.New("too many exports are running, try again later")
+	// ErrExportLink is returned for download links which are changed or expired
+	ErrExportLink = errors.New("export link is not valid or expired")
+
+	errExportArchive = errors.New("export archive is not valid or the passphrase is wrong")
+	errExportRestart = errors.New("the server restarted before the export finished, start a new export")
+)
+
+// exportQueue holds the jobs for the workers. The passphrase of an archive is never
+// saved, it only lives here until the worker encrypts the archive with it.
+var exportQueue = make(chan exportTask, exportQueueSize)
+
+type exportTask struct {
+	uuid       string
+	passphrase string
+}
+
+// CreateExportJob queues an export of the vault in schema, the archive is encrypted with the passphrase
+func CreateExportJob(s storage.Store, userID uint, schema string, dto *model.ExportRequestDTO) (*model.ExportJob, error) {
+	job, err := s.ExportJobs().Save(&model.ExportJob{
+		UUID:   uuid.NewV4(),
+		UserID: userID,
+		Schema: schema,
+		Status: model.ExportQueued,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case exportQueue <- exportTask{uuid: job.UUID.String(), passphrase: dto.Passphrase}:
+		return job, nil
+	default:
+		failExportJob(s, job, ErrExportQueueFull)
+		return nil, ErrExportQueueFull
+	}
+}
+
+// StartExportWorkers starts export.workers workers and deletes expired archives.
+// Jobs which were unfinished when the server stopped failed, their passphrases are gone.
+func StartExportWorkers(s storage.Store) error {
+	if _, err := parsePeriod(viper.GetString("export.retention")); err != nil {
+		return fmt.Errorf("export.retention: %w", err)
+	}
+	if _, err := parsePeriod(viper.GetString("export.urlExpiry")); err != nil {
+		return fmt.Errorf("export.urlExpiry: %w", err)
+	}
+
+	blobs, err := blob.FromConfig()
+	if err != nil {
+		return err
+	}
+
+	jobs, err := s.ExportJobs().FindUnfinished()
+	if err != nil {
+		return err
+	}
+	for i := range jobs {
+		failExportJob(s, &jobs[i], errExportRestart)
+	}
+
+	workers := viper.GetInt("export.workers")
+	if workers < 1 {
+		workers = 1
+	}
+	// Queued exports fail at the next start, like the ones which were running
+	for i := 0; i < workers; i++ {
+		goBackground(func() {
+			for {
+				select {
+				case <-background.stopping:
+					return
+				case task := <-exportQueue:
+					RunExportJob(s, blobs, task.uuid, task.passphrase)
+				}
+			}
+		})
+	}
+
+	every(exportCleanupPeriod, func(now time.Time) {
+		DeleteExpiredExports(s, blobs, now)
+	})
+	return nil
+}
+
+// RunExportJob builds the archive of the job and puts it to the blob store.
+// Progress is saved after each item type, so clients can poll it.
+func RunExportJob(s storage.Store, blobs blob.Store, id, passphrase string) {
+	defer tracing.Start("app.RunExportJob").End()
+
+	job, err := s.ExportJobs().FindByUUID(id)
+	if err != nil {
+		log.WithError(err).WithField("job_id", id).Error("export job couldn't be found")
+		return
+	}
+
+	job.Status = model.ExportRunning
+	if job, err = s.ExportJobs().Save(job); err != nil {
+		log.WithError(err).WithField("job_id", id).Error("export job couldn't be saved")
+		return
+	}
+
+	archive := &model.ExportArchive{Version: exportVersion, CreatedAt: time.Now().UTC(), Items: map[string]interface{}{}}
+	folders, err := s.Folders().All(job.Schema)
+	if err != nil {
+		failExportJob(s, job, err)
+		return
+	}
+	archive.Folders = model.ToFolderDTOs(folders)
+	tags, err := s.Tags().All(job.Schema)
+	if err != nil {
+		failExportJob(s, job, err)
+		return
+	}
+	archive.Tags = model.ToTagDTOs(tags)
+
+	for i, itemType := range ItemTypes {
+		dtos, err := exportItems(s, job, itemType)
+		if err != nil {
+			failExportJob(s, job, err)
+			return
+		}
+		archive.Items[itemType] = dtos
+		job.Items += len(dtos)
+		if itemType == LoginItem {
+			if archive.PasswordHistory, err = passwordHistoryDTOs(s, dtos, job.Schema); err != nil {
+				failExportJob(s, job, err)
+				return
+			}
+		}
+
+		// The last percents are for encrypting and storing the archive
+		job.Progress = (i + 1) * 90 / len(ItemTypes)
+		if job, err = s.ExportJobs().Save(job); err != nil {
+			log.WithError(err).WithField("job_id", id).Error("export job couldn't be saved")
+			return
+		}
+	}
+
+	data, err := json.Marshal(archive)
+	if err != nil {
+		failExportJob(s, job, err)
+		return
+	}
+	sealed, err := SealExportArchive(data, passphrase)
+	if err != nil {
+		failExportJob(s, job, err)
+		return
+	}
+
+	job.BlobKey = "exports/" + id + ".pwx"
+	if err := blobs.Put(job.BlobKey, bytes.NewReader(sealed)); err != nil {
+		failExportJob(s, job, err)
+		return
+	}
+
+	retention, _ := parsePeriod(viper.GetString("export.retention"))
+	expires := time.Now().Add(retention)
+	job.ExpiresAt = &expires
+	job.Status = model.ExportDone
+	job.Progress = 100
+	if _, err := s.ExportJobs().Save(job); err != nil {
+		log.WithError(err).WithField("job_id", id).Error("export job couldn't be saved")
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"event":   "export",
+		"user_id": job.UserID,
+		"job":     id,
+		"items":   job.Items,
+	}).Info("vault is exported")
+}
+
+// exportItems returns the items of the type as DTOs with their tags
+func exportItems(s storage.Store, job *model.ExportJob, itemType string) ([]interface{}, error) {
+	items, err := AllItems(s, itemType, job.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if err := LoadItemTags(s, items, job.Schema); err != nil {
+		return nil, err
+	}
+	TripCanaries(s, job.UserID, CanaryRead, "export", items)
+	return itemDTOs(items), nil
+}
+
+// itemDTOs returns the items of a slice as DTOs
+func itemDTOs(items interface{}) []interface{} {
+	v := reflect.ValueOf(items)
+	dtos := make([]interface{}, 0, v.Len())
+	for i := 0; i < v.Len(); i++ {
+		dtos = append(dtos, ToItemDTO(v.Index(i).Addr().Interface()))
+	}
+	return dtos
+}
+
+// passwordHistoryDTOs returns the previous passwords of the login DTOs
+func passwordHistoryDTOs(s storage.Store, logins []interface{}, schema string) ([]*model.PasswordHistoryDTO, error) {
+	dtos := []*model.PasswordHistoryDTO{}
+	for _, login := range logins {
+		histories, err := s.PasswordHistories().FindByLoginID(login.(*model.LoginDTO).ID, schema)
+		if err != nil {
+			return nil, err
+		}
+		dtos = append(dtos, model.ToPasswordHistoryDTOs(histories)...)
+	}
+	return dtos, nil
+}
+
+func failExportJob(s storage.Store, job *model.ExportJob, cause error) {
+	job.Status = model.ExportFailed
+	job.Error = cause.Error()
+	if _, err := s.ExportJobs().Save(job); err != nil {
+		log.WithError(err).WithField("job_id", job.UUID).Error("export job couldn't be saved")
+	}
+}
+
+// DeleteExpiredExports deletes the archives and jobs which expired before now
+func DeleteExpiredExports(s storage.Store, blobs blob.Store, now time.Time) {
+	jobs, err := s.ExportJobs().FindExpired(now)
+	if err != nil {
+		log.WithError(err).Error("expired exports couldn't be found")
+		return
+	}
+	for i := range jobs {
+		if jobs[i].BlobKey != "" {
+			if err := blobs.Delete(jobs[i].BlobKey); err != nil {
+				log.WithError(err).WithField("blob", jobs[i].BlobKey).Error("export archive couldn't be deleted")
+				continue
+			}
+		}
+		if err := s.ExportJobs().Delete(jobs[i].ID); err != nil {
+			log.WithError(err).WithField("job_id", jobs[i].UUID).Error("export job couldn't be deleted")
+		}
+	}
+}
+
+// SealExportArchive encrypts the archive with AES-256-GCM and a scrypt key of the passphrase.
+// The format is the magic, a 16 byte salt, a 12 byte nonce and the ciphertext.
+func SealExportArchive(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := exportCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	out := append(append(append([]byte{}, exportMagic...), salt...), nonce...)
+	return gcm.Seal(out, nonce, data, exportMagic), nil
+}
+
+// OpenExportArchive decrypts an archive of SealExportArchive
+func OpenExportArchive(sealed []byte, passphrase string) ([]byte, error) {
+	header := len(exportMagic) + 16 + 12
+	if len(sealed) < header || !bytes.Equal(sealed[:len(exportMagic)], exportMagic) {
+		return nil, errExportArchive
+	}
+	salt := sealed[len(exportMagic) : len(exportMagic)+16]
+	gcm, err := exportCipher(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	data, err := gcm.Open(nil, sealed[len(exportMagic)+16:header], sealed[header:], exportMagic)
+	if err != nil {
+		return nil, errExportArchive
+	}
+	return data, nil
+}
+
+func exportCipher(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// ExportDownloadURL returns the signed path which downloads the archive of the job
+// until export.urlExpiry from now. It works without a session, e.g. in a browser.
+func ExportDownloadURL(job *model.ExportJob, now time.Time) string {
+	expiry, err := parsePeriod(viper.GetString("export.urlExpiry"))
+	if err != nil {
+		expiry = 15 * time.Minute
+	}
+	expires := strconv.FormatInt(now.Add(expiry).Unix(), 10)
+	return fmt.Sprintf("/export/download/%s?expires=%s&signature=%s", job.UUID, expires, exportSignature(job.UUID.String(), expires))
+}
+
+// VerifyExportDownload checks the signature and the expiry of a download link
+func VerifyExportDownload(id, expires, signature string, now time.Time) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || now.Unix() > unix {
+		return ErrExportLink
+	}
+	if !hmac.Equal([]byte(signature), []byte(exportSignature(id, expires))) {
+		return ErrExportLink
+	}
+	return nil
+}
+
+// exportSignature signs the link with a key derived from server.secret
+func exportSignature(id, expires string) string {
+	key := sha256.Sum256([]byte("export-download:" + viper.GetString("server.secret")))
+	mac := hmac.New(sha256.New, key[:])
+	io.WriteString(mac, id+"\n"+expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
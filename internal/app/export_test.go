@@ -0,0 +1,52 @@
+package app
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/model"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportArchive(t *testing.T) {
+	sealed, err := SealExportArchive([]byte(`{"version":1}`), "export-passphrase")
+	assert.NoError(t, err)
+
+	data, err := OpenExportArchive(sealed, "export-passphrase")
+	assert.NoError(t, err)
+	assert.Equal(t, `{"version":1}`, string(data))
+
+	_, err = OpenExportArchive(sealed, "wrong-passphrase")
+	assert.Equal(t, errExportArchive, err)
+
+	sealed[len(sealed)-1] ^= 1
+	_, err = OpenExportArchive(sealed, "export-passphrase")
+	assert.Equal(t, errExportArchive, err)
+
+	_, err = OpenExportArchive([]byte("PWEXPORT1"), "export-passphrase")
+	assert.Equal(t, errExportArchive, err)
+}
+
+func TestExportDownloadURL(t *testing.T) {
+	viper.Set("server.secret", "secret")
+	viper.Set("export.urlExpiry", "15m")
+
+	now := time.Now()
+	job := &model.ExportJob{UUID: uuid.NewV4()}
+	link := ExportDownloadURL(job, now)
+
+	u, err := url.Parse(link)
+	assert.NoError(t, err)
+	id := strings.TrimPrefix(u.Path, "/export/download/")
+	expires, signature := u.Query().Get("expires"), u.Query().Get("signature")
+	assert.Equal(t, job.UUID.String(), id)
+
+	assert.NoError(t, VerifyExportDownload(id, expires, signature, now))
+	assert.Equal(t, ErrExportLink, VerifyExportDownload(id, expires, signature, now.Add(16*time.Minute)))
+	assert.Equal(t, ErrExportLink, VerifyExportDownload(uuid.NewV4().String(), expires, signature, now))
+	assert.Equal(t, ErrExportLink, VerifyExportDownload(id, expires+"0", signature, now))
+}
@@ -0,0 +1,15 @@
+package app
+
+import (
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+)
+
+// Favorites returns pointers to the favorite items of all types with their tags, by type
+// and pinned and last updated first within a type
+func Favorites(s storage.Store, schema string) ([]interface{}, error) {
+	defer tracing.Start("app.Favorites").End()
+
+	argsStr := map[string]string{"is_favorite": "true", "order": "updated_at desc"}
+	return findItemsOfAllTypes(s, argsStr, schema)
+}
@@ -0,0 +1,67 @@
+package app
+
+import (
+	"reflect"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// CreateFolder creates a new folder and saves it to the store
+func CreateFolder(s storage.Store, dto *model.FolderDTO, schema string) (*model.Folder, error) {
+	defer tracing.Start("app.CreateFolder").End()
+
+	return s.Folders().Save(model.ToFolder(dto), schema)
+}
+
+// UpdateFolder renames the folder
+func UpdateFolder(s storage.Store, folder *model.Folder, dto *model.FolderDTO, schema string) (*model.Folder, error) {
+	defer tracing.Start("app.UpdateFolder").End()
+
+	folder.Name = dto.Name
+	return s.Folders().Save(folder, schema)
+}
+
+// DeleteFolder deletes the folder, its items stay in the vault without a folder
+func DeleteFolder(s storage.Store, folder *model.Folder, schema string) error {
+	defer tracing.Start("app.DeleteFolder").End()
+
+	for _, itemType := range ItemTypes {
+		if err := s.Folders().Unfile(folder.ID, itemTable(itemType, schema)); err != nil {
+			return err
+		}
+	}
+	return s.Folders().Delete(folder.ID, schema)
+}
+
+// MoveItems moves the items of the dto to its folder in a single transaction, none of them
+// is moved when the folder or one of the items isn't found. It returns the moved items.
+func MoveItems(s storage.Store, dto *model.ItemMoveDTO, schema string) ([]interface{}, error) {
+	defer tracing.Start("app.MoveItems").End()
+
+	items := []interface{}{}
+	err := s.Transaction(func(tx storage.Store) error {
+		if dto.FolderID != 0 {
+			if _, err := tx.Folders().FindByID(dto.FolderID, schema); err != nil {
+				return err
+			}
+		}
+		for _, ref := range dto.Items {
+			item, err := FindItem(tx, ref.Type, ref.ID, schema)
+			if err != nil {
+				return err
+			}
+			reflect.ValueOf(item).Elem().FieldByName("FolderID").SetUint(uint64(dto.FolderID))
+			if _, err := SaveItem(tx, item, schema); err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,82 @@
+package app
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/spf13/viper"
+)
+
+// Statuses of the readiness checks
+const (
+	HealthOK   = "ok"
+	HealthFail = "fail"
+)
+
+// EmailAddress is the host and port of the mail API, the readiness check dials it
+var EmailAddress = "api.sendgrid.com:443"
+
+const healthDialTimeout = 3 * time.Second
+
+var errPendingMigrations = errors.New("migrations are pending, the primary runs them at startup or with passwall-server migrate up")
+
+// ReadOnly is true on standby servers which serve the reads from a replica. Reads don't
+// write there, session activity and audit events of their requests are skipped.
+func ReadOnly() bool {
+	return viper.GetBool("server.readOnly")
+}
+
+// DependencyHealth is the result of the check of a dependency
+type DependencyHealth struct {
+	Status    string   `json:"status"`
+	Error     string   `json:"error,omitempty"`
+	Pending   []string `json:"pending,omitempty"` // migrations, tables and columns of the system schema
+	LatencyMS float64  `json:"latency_ms"`
+}
+
+// Readiness is ok when all dependencies are
+type Readiness struct {
+	Status string                       `json:"status"`
+	Checks map[string]*DependencyHealth `json:"checks"`
+}
+
+// CheckReadiness pings the database and checks it has no pending migrations. The mail
+// API is dialed too with health.checkEmail, servers which can't send emails aren't ready then.
+func CheckReadiness(s storage.Store) *Readiness {
+	readiness := &Readiness{Status: HealthOK, Checks: map[string]*DependencyHealth{}}
+	check := func(name string, f func(*DependencyHealth) error) {
+		start := time.Now()
+		health := &DependencyHealth{Status: HealthOK}
+		if err := f(health); err != nil {
+			health.Status, health.Error = HealthFail, err.Error()
+			readiness.Status = HealthFail
+		}
+		health.LatencyMS = float64(time.Since(start)) / float64(time.Millisecond)
+		readiness.Checks[name] = health
+	}
+
+	check("database", func(*DependencyHealth) error {
+		return s.Ping()
+	})
+	if readiness.Status == HealthOK {
+		check("migrations", func(health *DependencyHealth) error {
+			health.Pending = append(schemaMigrationStatus(s, SystemSchema).Pending, s.PendingMigrations()...)
+			if len(health.Pending) > 0 {
+				return errPendingMigrations
+			}
+			return nil
+		})
+	}
+	if viper.GetBool("health.checkEmail") {
+		check("email", func(*DependencyHealth) error {
+			conn, err := net.DialTimeout("tcp", EmailAddress, healthDialTimeout)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		})
+	}
+	return readiness
+}
@@ -0,0 +1,83 @@
+package app
+
+import (
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckReadiness(t *testing.T) {
+	s, err := storage.NewMemory()
+	require.NoError(t, err)
+	defer s.Close()
+
+	// A new database misses all system tables
+	readiness := CheckReadiness(s)
+	assert.Equal(t, HealthFail, readiness.Status)
+	assert.Equal(t, HealthOK, readiness.Checks["database"].Status)
+	assert.Equal(t, errPendingMigrations.Error(), readiness.Checks["migrations"].Error)
+	assert.Contains(t, readiness.Checks["migrations"].Pending, "users")
+
+	MigrateSystemTables(s)
+	readiness = CheckReadiness(s)
+	assert.Equal(t, HealthOK, readiness.Status)
+	assert.Empty(t, readiness.Checks["migrations"].Pending)
+	assert.NotContains(t, readiness.Checks, "email")
+
+	// The mail API is only checked when it's turned on
+	viper.Set("health.checkEmail", true)
+	defer viper.Set("health.checkEmail", false)
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func(address string) { EmailAddress = address }(EmailAddress)
+	EmailAddress = ln.Addr().String()
+	assert.Equal(t, HealthOK, CheckReadiness(s).Checks["email"].Status)
+	ln.Close()
+	readiness = CheckReadiness(s)
+	assert.Equal(t, HealthFail, readiness.Status)
+	assert.Equal(t, HealthFail, readiness.Checks["email"].Status)
+}
+
+func TestCheckReadinessDatabaseDown(t *testing.T) {
+	mocks := storagetest.NewMocks()
+	mocks.Store.ExpectedCalls = nil
+	mocks.Store.On("Ping").Return(errors.New("connection refused"))
+
+	// Migrations aren't checked without a database
+	readiness := CheckReadiness(mocks.Store)
+	assert.Equal(t, HealthFail, readiness.Status)
+	assert.Equal(t, "connection refused", readiness.Checks["database"].Error)
+	assert.NotContains(t, readiness.Checks, "migrations")
+	mocks.Store.AssertNotCalled(t, "PendingMigrations")
+}
+
+func TestReadOnlySkipsWrites(t *testing.T) {
+	mocks := storagetest.NewMocks()
+	token := &model.Token{UserID: 1, Family: "family"}
+	event := &model.AuditEvent{Actor: "user:1", Action: AuditRead, ItemType: "logins", ItemID: 3}
+
+	viper.Set("server.readOnly", true)
+	defer viper.Set("server.readOnly", false)
+	TouchSessionAt(mocks.Store, token, time.Now())
+	RecordAuditEvent(mocks.Store, event, "user1")
+	mocks.Tokens.AssertNotCalled(t, "TouchByFamily", mock.Anything, mock.Anything)
+	mocks.AuditEvents.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+
+	// The primary saves them and its errors are logged
+	viper.Set("server.readOnly", false)
+	mocks.Tokens.On("TouchByFamily", "family", mock.Anything).Return(errors.New("connection refused")).Once()
+	mocks.AuditEvents.On("Create", event, "user1").Return(event, nil).Once()
+	TouchSessionAt(mocks.Store, token, time.Now())
+	RecordAuditEvent(mocks.Store, event, "user1")
+	mocks.Tokens.AssertExpectations(t)
+	mocks.AuditEvents.AssertExpectations(t)
+}
@@ -1,5 +1,174 @@
 package app
 
+import (
+	"encoding/csv"
+	"errors"
+	"reflect"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+var (
+	// ErrImportFile is the error of a file which isn't an export of the password manager
+	ErrImportFile = errors.New("Import file isn't a valid export of the password manager")
+	// ErrImportEncrypted is the error of an export which is encrypted by the password manager
+	ErrImportEncrypted = errors.New("Encrypted exports can't be imported, export the vault unencrypted")
+	// ErrImportPassword is the error of a wrong password of an encrypted file
+	ErrImportPassword = errors.New("Import file can't be opened with the password")
+	// ErrImportUnsupported is the error of a file of a format version or cipher which isn't supported
+	ErrImportUnsupported = errors.New("Import file isn't supported, export the vault unencrypted")
+	// ErrImportMapping is the error of a column mapping of the generic CSV import which doesn't
+	// name columns of the CSV
+	ErrImportMapping = errors.New("Import mapping has to name columns of the CSV")
+)
+
+// importEntry is an entry of an export of another password manager. It becomes the item
+// of the DTO pointer in the folder, or it is skipped with the reason.
+type importEntry struct {
+	title  string
+	folder string
+	dto    interface{}
+	skip   string
+}
+
+// importField is a named value of an entry which has no field in the item
+type importField struct {
+	name  string
+	value string
+}
+
+// importEntries creates the items of the entries and the folders they are in, folders of
+// the vault with the same name are reused. Entries which aren't valid items are skipped,
+// the others are created in a single transaction so none of them is created when the
+// store fails. A dry run only reports the items and folders it would create.
+func importEntries(s storage.Store, entries []importEntry, dryRun bool, schema string) (*model.ImportReportDTO, error) {
+	defer tracing.Start("app.importEntries").End()
+
+	report := &model.ImportReportDTO{DryRun: dryRun, Imported: map[string]int{}, Skipped: []model.ImportSkippedDTO{}}
+	err := s.Transaction(func(tx storage.Store) error {
+		folders, err := tx.Folders().All(schema)
+		if err != nil {
+			return err
+		}
+		folderIDs := map[string]uint{}
+		for i := range folders {
+			folderIDs[folders[i].Name] = folders[i].ID
+		}
+
+		for i, entry := range entries {
+			if entry.skip == "" {
+				if err := ValidateItemDTO(entry.dto); err != nil {
+					entry.skip = err.Error()
+				}
+			}
+			if entry.skip != "" {
+				report.Skipped = append(report.Skipped, model.ImportSkippedDTO{Entry: i + 1, Title: entry.title, Reason: entry.skip})
+				continue
+			}
+
+			folder := strings.TrimSpace(entry.folder)
+			if dryRun {
+				if _, ok := folderIDs[folder]; !ok && folder != "" {
+					folderIDs[folder] = 0
+					report.Folders++
+				}
+				itemType := importItemType(entry.dto)
+				report.Imported[itemType]++
+				report.Items = append(report.Items, model.ImportItemDTO{Entry: i + 1, Type: itemType, Folder: folder, Item: entry.dto})
+				continue
+			}
+			if _, ok := folderIDs[folder]; !ok && folder != "" {
+				created, err := tx.Folders().Save(&model.Folder{Name: folder}, schema)
+				if err != nil {
+					return err
+				}
+				folderIDs[folder] = created.ID
+				report.Folders++
+			}
+			reflect.ValueOf(entry.dto).Elem().FieldByName("FolderID").SetUint(uint64(folderIDs[folder]))
+
+			item, err := createItem(tx, entry.dto, schema)
+			if err != nil {
+				return err
+			}
+			report.Imported[ItemTypeOf(item)]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// importItemType returns the item type of the DTO pointer like "logins"
+func importItemType(dto interface{}) string {
+	switch dto.(type) {
+	case *model.LoginDTO:
+		return LoginItem
+	case *model.CreditCardDTO:
+		return CreditCardItem
+	case *model.BankAccountDTO:
+		return BankAccountItem
+	case *model.NoteDTO:
+		return NoteItem
+	case *model.EmailDTO:
+		return EmailItem
+	case *model.ServerDTO:
+		return ServerItem
+	}
+	return ""
+}
+
+// importExtra joins the notes of an entry with its other fields, like custom fields, as
+// "name: value" lines for the extra or note of the item
+func importExtra(notes string, fields []importField) string {
+	lines := []string{}
+	if notes = strings.TrimSpace(notes); notes != "" {
+		lines = append(lines, notes)
+	}
+	for _, field := range fields {
+		if strings.TrimSpace(field.value) == "" {
+			continue
+		}
+		lines = append(lines, field.name+": "+field.value)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// readImportCSV reads the rows of a CSV export as maps of the lower case names of the
+// columns to their values. The export has to have the columns.
+func readImportCSV(content string, columns ...string) ([]map[string]string, error) {
+	records, err := csv.NewReader(strings.NewReader(strings.TrimPrefix(content, "\ufeff"))).ReadAll()
+	if err != nil || len(records) == 0 {
+		return nil, ErrImportFile
+	}
+
+	header := make([]string, len(records[0]))
+	names := map[string]bool{}
+	for i, name := range records[0] {
+		header[i] = strings.ToLower(strings.TrimSpace(name))
+		names[header[i]] = true
+	}
+	for _, column := range columns {
+		if !names[column] {
+			return nil, ErrImportFile
+		}
+	}
+
+	rows := make([]map[string]string, len(records)-1)
+	for i, record := range records[1:] {
+		rows[i] = map[string]string{}
+		for j, value := range record {
+			rows[i][header[j]] = value
+		}
+	}
+	return rows, nil
+}
+
 // InsertValues ...
 /* func InsertValues(s storage.Store, url, username, password string, file *os.File) error {
 	var urlIndex, usernameIndex, passwordIndex int
@@ -39,7 +208,7 @@ package app
 	}
 
 	if err := scanner.Err(); err != nil {
-		log.Println(err)
+		log.Error(err)
 		return err
 	}
 
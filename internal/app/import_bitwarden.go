@@ -0,0 +1,201 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Types of the items of Bitwarden exports
+const (
+	bitwardenLogin      = 1
+	bitwardenSecureNote = 2
+	bitwardenCard       = 3
+	bitwardenIdentity   = 4
+	bitwardenSSHKey     = 5
+
+	// bitwardenLinkedField is a custom field which only points to another field
+	bitwardenLinkedField = 3
+)
+
+// bitwardenExport is the JSON export of a Bitwarden vault
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	FolderID string `json:"folderId"`
+	Type     int    `json:"type"`
+	Reprompt int    `json:"reprompt"`
+	Name     string `json:"name"`
+	Notes    string `json:"notes"`
+	Favorite bool   `json:"favorite"`
+	Fields   []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Type  int    `json:"type"`
+	} `json:"fields"`
+	Login struct {
+		URIs []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+	} `json:"login"`
+	Card struct {
+		CardholderName string `json:"cardholderName"`
+		Number         string `json:"number"`
+		ExpMonth       string `json:"expMonth"`
+		ExpYear        string `json:"expYear"`
+		Code           string `json:"code"`
+	} `json:"card"`
+}
+
+// ImportBitwarden imports the unencrypted JSON or CSV export of a Bitwarden vault. Logins,
+// secure notes and cards become logins, notes and credit cards in the folders of the
+// export, other items are skipped. Custom fields and TOTP secrets are kept in the extra of
+// logins and the text of notes.
+func ImportBitwarden(s storage.Store, dto *model.ImportFileDTO, schema string) (*model.ImportReportDTO, error) {
+	defer tracing.Start("app.ImportBitwarden").End()
+
+	var entries []importEntry
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(dto.Content), "{") {
+		entries, err = bitwardenJSONEntries(dto.Content)
+	} else {
+		entries, err = bitwardenCSVEntries(dto.Content)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return importEntries(s, entries, dto.DryRun, schema)
+}
+
+func bitwardenJSONEntries(content string) ([]importEntry, error) {
+	var export bitwardenExport
+	if err := json.Unmarshal([]byte(content), &export); err != nil {
+		return nil, ErrImportFile
+	}
+	if export.Encrypted {
+		return nil, ErrImportEncrypted
+	}
+
+	folders := map[string]string{}
+	for _, folder := range export.Folders {
+		folders[folder.ID] = folder.Name
+	}
+
+	entries := make([]importEntry, len(export.Items))
+	for i, item := range export.Items {
+		fields := []importField{}
+		for _, field := range item.Fields {
+			if field.Type != bitwardenLinkedField {
+				fields = append(fields, importField{name: field.Name, value: field.Value})
+			}
+		}
+
+		entry := importEntry{title: item.Name, folder: folders[item.FolderID]}
+		switch item.Type {
+		case bitwardenLogin:
+			url := ""
+			if len(item.Login.URIs) > 0 {
+				url = item.Login.URIs[0].URI
+			}
+			fields = append(fields, importField{name: "TOTP", value: item.Login.TOTP})
+			entry.dto = &model.LoginDTO{
+				Title:      item.Name,
+				URL:        url,
+				Username:   item.Login.Username,
+				Password:   item.Login.Password,
+				Extra:      importExtra(item.Notes, fields),
+				IsFavorite: item.Favorite,
+				Reprompt:   item.Reprompt != 0,
+			}
+		case bitwardenSecureNote:
+			entry.dto = &model.NoteDTO{
+				Title:      item.Name,
+				Note:       importExtra(item.Notes, fields),
+				IsFavorite: item.Favorite,
+				Reprompt:   item.Reprompt != 0,
+			}
+		case bitwardenCard:
+			entry.dto = &model.CreditCardDTO{
+				CardName:           item.Name,
+				CardholderName:     item.Card.CardholderName,
+				Number:             item.Card.Number,
+				VerificationNumber: item.Card.Code,
+				ExpiryDate:         bitwardenExpiry(item.Card.ExpMonth, item.Card.ExpYear),
+				IsFavorite:         item.Favorite,
+				Reprompt:           item.Reprompt != 0,
+			}
+		case bitwardenIdentity:
+			entry.skip = "identity items can't be imported"
+		case bitwardenSSHKey:
+			entry.skip = "SSH key items can't be imported"
+		default:
+			entry.skip = fmt.Sprintf("items of type %d can't be imported", item.Type)
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// bitwardenCSVEntries reads the CSV export, which only has logins and secure notes
+func bitwardenCSVEntries(content string) ([]importEntry, error) {
+	rows, err := readImportCSV(content, "type", "name")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]importEntry, len(rows))
+	for i, row := range rows {
+		fields := []importField{{name: "TOTP", value: row["login_totp"]}}
+		notes := row["notes"] + "\n" + row["fields"]
+
+		entry := importEntry{title: row["name"], folder: row["folder"]}
+		switch row["type"] {
+		case "login":
+			entry.dto = &model.LoginDTO{
+				Title:      row["name"],
+				URL:        row["login_uri"],
+				Username:   row["login_username"],
+				Password:   row["login_password"],
+				Extra:      importExtra(notes, fields),
+				IsFavorite: row["favorite"] == "1",
+				Reprompt:   row["reprompt"] == "1",
+			}
+		case "note":
+			entry.dto = &model.NoteDTO{
+				Title:      row["name"],
+				Note:       importExtra(notes, nil),
+				IsFavorite: row["favorite"] == "1",
+				Reprompt:   row["reprompt"] == "1",
+			}
+		default:
+			entry.skip = fmt.Sprintf("items of type %q can't be imported", row["type"])
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// bitwardenExpiry returns the MM/YYYY expiry date of a card, empty without month or year
+func bitwardenExpiry(month, year string) string {
+	if month == "" || year == "" {
+		return ""
+	}
+	if len(month) == 1 {
+		month = "0" + month
+	}
+	return month + "/" + year
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// browserInternal is the scheme of the logins browsers keep for themselves, like the
+// Firefox account of Firefox exports
+const browserInternal = "chrome://"
+
+// ImportBrowser imports the CSV export of the passwords of a browser, the exports of
+// Chrome, Edge, Firefox and Safari are told apart by their columns. The entries become
+// logins, the ones without a name are titled by the host of their URL.
+func ImportBrowser(s storage.Store, dto *model.ImportFileDTO, schema string) (*model.ImportReportDTO, error) {
+	defer tracing.Start("app.ImportBrowser").End()
+
+	entries, err := browserEntries(dto.Content)
+	if err != nil {
+		return nil, err
+	}
+	return importEntries(s, entries, dto.DryRun, schema)
+}
+
+func browserEntries(content string) ([]importEntry, error) {
+	rows, err := readImportCSV(content, "url", "username", "password")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]importEntry, len(rows))
+	for i, row := range rows {
+		// Chrome and Edge have a name, Safari a title and Firefox neither
+		title := row["name"]
+		if title == "" {
+			title = row["title"]
+		}
+		if title == "" {
+			title = browserTitle(row["url"])
+		}
+		entries[i] = importEntry{title: title}
+		if strings.HasPrefix(row["url"], browserInternal) {
+			entries[i].skip = "logins of the browser itself aren't imported"
+			continue
+		}
+
+		notes := row["note"]
+		if notes == "" {
+			notes = row["notes"]
+		}
+		entries[i].dto = &model.LoginDTO{
+			Title:    title,
+			URL:      row["url"],
+			Username: row["username"],
+			Password: row["password"],
+			Extra:    importExtra(notes, []importField{{name: "TOTP", value: row["otpauth"]}}),
+		}
+	}
+	return entries, nil
+}
+
+// browserTitle returns the host of the URL without "www.", or the URL if it has no host
+func browserTitle(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" {
+		return rawURL
+	}
+	return strings.TrimPrefix(u.Hostname(), "www.")
+}
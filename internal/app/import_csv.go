@@ -0,0 +1,84 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// ImportCSV imports a CSV of any layout with the mapping of its columns to the fields of
+// the items. Rows become logins, or notes when the type column of the mapping says so,
+// and logins without a title are titled by the host of their URL.
+func ImportCSV(s storage.Store, dto *model.ImportFileDTO, schema string) (*model.ImportReportDTO, error) {
+	defer tracing.Start("app.ImportCSV").End()
+
+	entries, err := csvEntries(dto.Content, dto.Mapping)
+	if err != nil {
+		return nil, err
+	}
+	return importEntries(s, entries, dto.DryRun, schema)
+}
+
+func csvEntries(content string, mapping *model.ImportMappingDTO) ([]importEntry, error) {
+	if mapping == nil {
+		return nil, ErrImportMapping
+	}
+	m := *mapping
+	columns := []string{}
+	for _, column := range []*string{&m.Title, &m.URL, &m.Username, &m.Password, &m.Notes, &m.Type, &m.Folder} {
+		if *column = strings.ToLower(strings.TrimSpace(*column)); *column != "" {
+			columns = append(columns, *column)
+		}
+	}
+	if len(columns) == 0 {
+		return nil, ErrImportMapping
+	}
+
+	rows, err := readImportCSV(content)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) > 0 {
+		for _, column := range columns {
+			if _, ok := rows[0][column]; !ok {
+				return nil, fmt.Errorf("%w, the CSV has no column %q", ErrImportMapping, column)
+			}
+		}
+	}
+
+	entries := make([]importEntry, len(rows))
+	for i, row := range rows {
+		// Fields without a column are empty
+		value := func(column string) string {
+			if column == "" {
+				return ""
+			}
+			return row[column]
+		}
+
+		title := value(m.Title)
+		entries[i] = importEntry{title: title, folder: value(m.Folder)}
+		switch itemType := strings.ToLower(strings.TrimSpace(value(m.Type))); itemType {
+		case "", "login", "logins", "password":
+			if title == "" {
+				title = browserTitle(value(m.URL))
+			}
+			entries[i].title = title
+			entries[i].dto = &model.LoginDTO{
+				Title:    title,
+				URL:      value(m.URL),
+				Username: value(m.Username),
+				Password: value(m.Password),
+				Extra:    value(m.Notes),
+			}
+		case "note", "notes", "secure note":
+			entries[i].dto = &model.NoteDTO{Title: title, Note: value(m.Notes)}
+		default:
+			entries[i].skip = fmt.Sprintf("rows of type %q can't be imported", itemType)
+		}
+	}
+	return entries, nil
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/app/keepass"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// ImportKeePass imports a KeePass database, the base64 encoded KDBX file opened with the
+// password or its XML export. Groups become folders like "Work/Dev" and entries become
+// logins with their notes and custom strings in the extra.
+func ImportKeePass(s storage.Store, dto *model.ImportFileDTO, schema string) (*model.ImportReportDTO, error) {
+	defer tracing.Start("app.ImportKeePass").End()
+
+	var entries []keepass.Entry
+	var err error
+	if strings.HasPrefix(strings.TrimSpace(dto.Content), "<") {
+		entries, err = keepass.ReadXML([]byte(dto.Content))
+	} else {
+		var file []byte
+		if file, err = base64.StdEncoding.DecodeString(strings.TrimSpace(dto.Content)); err != nil {
+			return nil, ErrImportFile
+		}
+		entries, err = keepass.Read(file, dto.Password)
+	}
+	switch {
+	case errors.Is(err, keepass.ErrCredentials):
+		return nil, ErrImportPassword
+	case errors.Is(err, keepass.ErrUnsupported):
+		return nil, ErrImportUnsupported
+	case err != nil:
+		return nil, ErrImportFile
+	}
+	return importEntries(s, keePassEntries(entries), dto.DryRun, schema)
+}
+
+func keePassEntries(entries []keepass.Entry) []importEntry {
+	imported := make([]importEntry, len(entries))
+	for i, entry := range entries {
+		imported[i] = importEntry{title: entry.Title, folder: entry.Group}
+		if entry.Recycled {
+			imported[i].skip = "entries in the recycle bin aren't imported"
+			continue
+		}
+
+		fields := make([]importField, len(entry.Fields))
+		for j, field := range entry.Fields {
+			fields[j] = importField{name: field.Key, value: field.Value}
+		}
+		imported[i].dto = &model.LoginDTO{
+			Title:            entry.Title,
+			URL:              entry.URL,
+			Username:         entry.UserName,
+			Password:         entry.Password,
+			Extra:            importExtra(entry.Notes, fields),
+			AutoTypeSequence: entry.AutoTypeSequence,
+			AutoTypeWindow:   entry.AutoTypeWindow,
+		}
+	}
+	return imported
+}
@@ -0,0 +1,132 @@
+package app
+
+import (
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	// lastPassNoteURL is the url of the secure notes of LastPass exports
+	lastPassNoteURL = "http://sn"
+	// lastPassNoGroup is the grouping of entries without a folder
+	lastPassNoGroup = "(none)"
+)
+
+// ImportLastPass imports the CSV export of a LastPass vault. Sites become logins and
+// secure notes become notes, the ones of the credit card and bank account note types
+// become credit cards and bank accounts. Groupings like `Work\Dev` become folders like
+// "Work/Dev".
+func ImportLastPass(s storage.Store, dto *model.ImportFileDTO, schema string) (*model.ImportReportDTO, error) {
+	defer tracing.Start("app.ImportLastPass").End()
+
+	entries, err := lastPassEntries(dto.Content)
+	if err != nil {
+		return nil, err
+	}
+	return importEntries(s, entries, dto.DryRun, schema)
+}
+
+func lastPassEntries(content string) ([]importEntry, error) {
+	rows, err := readImportCSV(content, "url", "username", "password", "extra", "name", "grouping")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]importEntry, len(rows))
+	for i, row := range rows {
+		entry := importEntry{title: row["name"], folder: lastPassFolder(row["grouping"])}
+		favorite := row["fav"] == "1"
+		if row["url"] != lastPassNoteURL {
+			entry.dto = &model.LoginDTO{
+				Title:      row["name"],
+				URL:        row["url"],
+				Username:   row["username"],
+				Password:   row["password"],
+				Extra:      importExtra(row["extra"], []importField{{name: "TOTP", value: row["totp"]}}),
+				IsFavorite: favorite,
+			}
+			entries[i] = entry
+			continue
+		}
+
+		note := lastPassNoteFields(row["extra"])
+		switch note["NoteType"] {
+		case "Credit Card":
+			entry.dto = &model.CreditCardDTO{
+				CardName:           row["name"],
+				CardholderName:     note["Name on Card"],
+				Type:               note["Type"],
+				Number:             note["Number"],
+				VerificationNumber: note["Security Code"],
+				ExpiryDate:         lastPassExpiry(note["Expiration Date"]),
+				IsFavorite:         favorite,
+			}
+		case "Bank Account":
+			bankName, bankCode := note["Bank Name"], note["SWIFT Code"]
+			if bankName == "" {
+				bankName = row["name"]
+			}
+			if bankCode == "" {
+				bankCode = note["Routing Number"]
+			}
+			entry.dto = &model.BankAccountDTO{
+				BankName:      bankName,
+				BankCode:      bankCode,
+				AccountName:   row["name"],
+				AccountNumber: note["Account Number"],
+				IBAN:          note["IBAN Number"],
+				Password:      note["Pin"],
+				IsFavorite:    favorite,
+			}
+		default:
+			entry.dto = &model.NoteDTO{
+				Title:      row["name"],
+				Note:       row["extra"],
+				IsFavorite: favorite,
+			}
+		}
+		entries[i] = entry
+	}
+	return entries, nil
+}
+
+// lastPassFolder returns the folder name of a grouping, empty for entries without one
+func lastPassFolder(grouping string) string {
+	if grouping == lastPassNoGroup {
+		return ""
+	}
+	return strings.Replace(grouping, `\`, "/", -1)
+}
+
+// lastPassNoteFields returns the "Name:Value" lines of a typed secure note, the last
+// field "Notes" has the rest of the note
+func lastPassNoteFields(extra string) map[string]string {
+	fields := map[string]string{}
+	lines := strings.Split(strings.Replace(extra, "\r\n", "\n", -1), "\n")
+	for i, line := range lines {
+		field := strings.SplitN(line, ":", 2)
+		if len(field) != 2 {
+			continue
+		}
+		if field[0] == "Notes" {
+			fields["Notes"] = strings.Join(append([]string{field[1]}, lines[i+1:]...), "\n")
+			break
+		}
+		fields[field[0]] = strings.TrimSpace(field[1])
+	}
+	return fields
+}
+
+// lastPassExpiry returns the MM/YYYY expiry date of a card expiring like "January,2025",
+// other dates are kept for the validation to report them
+func lastPassExpiry(date string) string {
+	expiry, err := time.Parse("January,2006", date)
+	if err != nil {
+		return strings.Trim(date, ",")
+	}
+	return expiry.Format("01/2006")
+}
@@ -0,0 +1,317 @@
+package app
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Categories of the items of 1Password exports which have a close item type
+const (
+	onePasswordLogin       = "001"
+	onePasswordCreditCard  = "002"
+	onePasswordSecureNote  = "003"
+	onePasswordPassword    = "005"
+	onePasswordDocument    = "006"
+	onePasswordBankAccount = "101"
+	onePasswordServer      = "110"
+	onePasswordEmail       = "111"
+)
+
+const (
+	// onePasswordData is the file of the items in a 1PUX archive
+	onePasswordData = "export.data"
+	// onePasswordMaxData is the largest export.data which is read
+	onePasswordMaxData = 64 << 20
+	// onePasswordTrashed is the state of the items in the trash of 1Password
+	onePasswordTrashed = "trashed"
+)
+
+// zipMagic starts every zip archive, like 1PUX exports
+var zipMagic = []byte("PK\x03\x04")
+
+// onePasswordExport is the export.data of a 1PUX archive, accounts have vaults of items
+type onePasswordExport struct {
+	Accounts []struct {
+		Vaults []struct {
+			Attrs struct {
+				Name string `json:"name"`
+			} `json:"attrs"`
+			Items []onePasswordItem `json:"items"`
+		} `json:"vaults"`
+	} `json:"accounts"`
+}
+
+type onePasswordItem struct {
+	FavIndex     int    `json:"favIndex"`
+	State        string `json:"state"`
+	CategoryUUID string `json:"categoryUuid"`
+	Overview     struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+	} `json:"overview"`
+	Details struct {
+		LoginFields []struct {
+			Value       string `json:"value"`
+			Designation string `json:"designation"`
+		} `json:"loginFields"`
+		NotesPlain string `json:"notesPlain"`
+		Password   string `json:"password"`
+		Sections   []struct {
+			Fields []struct {
+				Title string                     `json:"title"`
+				ID    string                     `json:"id"`
+				Value map[string]json.RawMessage `json:"value"`
+			} `json:"fields"`
+		} `json:"sections"`
+	} `json:"details"`
+}
+
+// ImportOnePassword imports the 1PUX or CSV export of 1Password, 1PUX archives are base64
+// encoded. The vaults of the export become folders. Logins and passwords become logins,
+// credit cards, secure notes, bank accounts, servers and email accounts become items of
+// their type and the items of other categories become notes with their fields. Fields
+// without a field in the item are kept as "name: value" lines in its extra or note.
+func ImportOnePassword(s storage.Store, dto *model.ImportFileDTO, schema string) (*model.ImportReportDTO, error) {
+	defer tracing.Start("app.ImportOnePassword").End()
+
+	var entries []importEntry
+	archive, err := base64.StdEncoding.DecodeString(strings.TrimSpace(dto.Content))
+	if err == nil && bytes.HasPrefix(archive, zipMagic) {
+		entries, err = onePasswordArchiveEntries(archive)
+	} else {
+		entries, err = onePasswordCSVEntries(dto.Content)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return importEntries(s, entries, dto.DryRun, schema)
+}
+
+func onePasswordArchiveEntries(archive []byte) ([]importEntry, error) {
+	files, err := zip.NewReader(bytes.NewReader(archive), int64(len(archive)))
+	if err != nil {
+		return nil, ErrImportFile
+	}
+
+	var export onePasswordExport
+	found := false
+	for _, file := range files.File {
+		if file.Name != onePasswordData {
+			continue
+		}
+		data, err := file.Open()
+		if err != nil {
+			return nil, ErrImportFile
+		}
+		err = json.NewDecoder(io.LimitReader(data, onePasswordMaxData)).Decode(&export)
+		data.Close()
+		if err != nil {
+			return nil, ErrImportFile
+		}
+		found = true
+	}
+	if !found {
+		return nil, ErrImportFile
+	}
+
+	entries := []importEntry{}
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			for _, item := range vault.Items {
+				entries = append(entries, onePasswordEntry(item, vault.Attrs.Name))
+			}
+		}
+	}
+	return entries, nil
+}
+
+// onePasswordEntry maps the item to the closest item type
+func onePasswordEntry(item onePasswordItem, vault string) importEntry {
+	title := item.Overview.Title
+	entry := importEntry{title: title, folder: vault}
+	if item.State == onePasswordTrashed {
+		entry.skip = "items in the trash aren't imported"
+		return entry
+	}
+
+	fields := newOnePasswordFields(item)
+	favorite := item.FavIndex > 0
+	notes := item.Details.NotesPlain
+	switch item.CategoryUUID {
+	case onePasswordLogin, onePasswordPassword:
+		login := &model.LoginDTO{Title: title, URL: item.Overview.URL, Password: item.Details.Password, IsFavorite: favorite}
+		for _, field := range item.Details.LoginFields {
+			switch field.Designation {
+			case "username":
+				login.Username = field.Value
+			case "password":
+				login.Password = field.Value
+			}
+		}
+		login.Extra = importExtra(notes, fields.rest())
+		entry.dto = login
+	case onePasswordCreditCard:
+		entry.dto = &model.CreditCardDTO{
+			CardName:           title,
+			CardholderName:     fields.take("cardholder"),
+			Type:               fields.take("type"),
+			Number:             fields.take("ccnum"),
+			VerificationNumber: fields.take("cvv"),
+			ExpiryDate:         fields.take("expiry"),
+			IsFavorite:         favorite,
+		}
+	case onePasswordSecureNote:
+		entry.dto = &model.NoteDTO{Title: title, Note: importExtra(notes, fields.rest()), IsFavorite: favorite}
+	case onePasswordBankAccount:
+		bankName, bankCode := fields.take("bankName"), fields.take("swift")
+		if bankName == "" {
+			bankName = title
+		}
+		if bankCode == "" {
+			bankCode = fields.take("routingNo")
+		}
+		entry.dto = &model.BankAccountDTO{
+			BankName:      bankName,
+			BankCode:      bankCode,
+			AccountName:   title,
+			AccountNumber: fields.take("accountNo"),
+			IBAN:          fields.take("iban"),
+			Password:      fields.take("telephonePin"),
+			IsFavorite:    favorite,
+		}
+	case onePasswordServer:
+		server := &model.ServerDTO{
+			Title:         title,
+			URL:           fields.take("url"),
+			Username:      fields.take("username"),
+			Password:      fields.take("password"),
+			AdminUsername: fields.take("admin_console_username"),
+			AdminPassword: fields.take("admin_console_password"),
+			IsFavorite:    favorite,
+		}
+		server.Extra = importExtra(notes, fields.rest())
+		entry.dto = server
+	case onePasswordEmail:
+		entry.dto = &model.EmailDTO{
+			Title:      title,
+			Email:      fields.take("pop_username"),
+			Password:   fields.take("pop_password"),
+			IsFavorite: favorite,
+		}
+	case onePasswordDocument:
+		entry.skip = "documents can't be imported"
+	default:
+		entry.dto = &model.NoteDTO{Title: title, Note: importExtra(notes, fields.rest()), IsFavorite: favorite}
+	}
+	return entry
+}
+
+// onePasswordFields are the fields of the sections of an item, the ones which are taken
+// for fields of the item aren't in the rest
+type onePasswordFields struct {
+	ids    []string
+	fields []importField
+	taken  map[string]bool
+}
+
+func newOnePasswordFields(item onePasswordItem) *onePasswordFields {
+	f := &onePasswordFields{taken: map[string]bool{}}
+	for _, section := range item.Details.Sections {
+		for _, field := range section.Fields {
+			f.ids = append(f.ids, field.ID)
+			f.fields = append(f.fields, importField{name: field.Title, value: onePasswordValue(field.Value)})
+		}
+	}
+	return f
+}
+
+// take returns the value of the first field with the id
+func (f *onePasswordFields) take(id string) string {
+	for i := range f.ids {
+		if f.ids[i] == id {
+			f.taken[id] = true
+			return f.fields[i].value
+		}
+	}
+	return ""
+}
+
+// rest returns the fields which weren't taken
+func (f *onePasswordFields) rest() []importField {
+	rest := []importField{}
+	for i := range f.ids {
+		if !f.taken[f.ids[i]] {
+			rest = append(rest, f.fields[i])
+		}
+	}
+	return rest
+}
+
+// onePasswordValue returns the text of a field value, which is keyed by its kind like
+// {"concealed": "..."}. Expiry dates like 203001 are MM/YYYY and dates are YYYY-MM-DD.
+func onePasswordValue(value map[string]json.RawMessage) string {
+	for kind, raw := range value {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err != nil {
+			continue
+		}
+		switch v := v.(type) {
+		case string:
+			return v
+		case bool:
+			return strconv.FormatBool(v)
+		case float64:
+			switch kind {
+			case "monthYear":
+				return fmt.Sprintf("%02d/%d", int(v)%100, int(v)/100)
+			case "date":
+				return time.Unix(int64(v), 0).UTC().Format("2006-01-02")
+			}
+			return strconv.FormatFloat(v, 'f', -1, 64)
+		case map[string]interface{}:
+			// Emails and SSH keys are objects
+			for _, key := range []string{"email_address", "privateKey"} {
+				if s, ok := v[key].(string); ok {
+					return s
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// onePasswordCSVEntries reads the CSV export, which only has logins and passwords
+func onePasswordCSVEntries(content string) ([]importEntry, error) {
+	rows, err := readImportCSV(content, "title", "password")
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]importEntry, len(rows))
+	for i, row := range rows {
+		url := row["url"]
+		if url == "" {
+			url = row["website"]
+		}
+		entries[i] = importEntry{title: row["title"], dto: &model.LoginDTO{
+			Title:      row["title"],
+			URL:        url,
+			Username:   row["username"],
+			Password:   row["password"],
+			Extra:      importExtra(row["notes"], []importField{{name: "TOTP", value: row["otpauth"]}}),
+			IsFavorite: row["favorite"] == "true" || row["favorite"] == "1",
+		}}
+	}
+	return entries, nil
+}
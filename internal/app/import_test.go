@@ -0,0 +1,120 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadImportCSV(t *testing.T) {
+	rows, err := readImportCSV("\ufeffName, URL\nGitHub,https://github.com\n", "name", "url")
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]string{{"name": "GitHub", "url": "https://github.com"}}, rows)
+
+	_, err = readImportCSV("name\nGitHub\n", "name", "url")
+	assert.Equal(t, ErrImportFile, err)
+	_, err = readImportCSV("name,url\nGitHub\n", "name")
+	assert.Equal(t, ErrImportFile, err)
+}
+
+func TestBitwardenJSONEntries(t *testing.T) {
+	entries, err := bitwardenJSONEntries(`{"items": [{"type": 1, "name": "GitHub", "fields": [
+		{"name": "Recovery", "value": "abc", "type": 0},
+		{"name": "Link", "value": null, "linkedId": 100, "type": 3}
+	], "login": {"username": "octocat"}}]}`)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		assert.Equal(t, &model.LoginDTO{Title: "GitHub", Username: "octocat", Extra: "Recovery: abc"}, entries[0].dto)
+	}
+
+	assert.Equal(t, "", bitwardenExpiry("", "2030"))
+	assert.Equal(t, "12/2030", bitwardenExpiry("12", "2030"))
+}
+
+func TestLastPassNoteFields(t *testing.T) {
+	fields := lastPassNoteFields("NoteType:Credit Card\nName on Card:Octo Cat\nNumber:4111111111111111\nNotes:first\nsecond: line")
+	assert.Equal(t, map[string]string{
+		"NoteType":     "Credit Card",
+		"Name on Card": "Octo Cat",
+		"Number":       "4111111111111111",
+		"Notes":        "first\nsecond: line",
+	}, fields)
+
+	assert.Equal(t, "01/2025", lastPassExpiry("January,2025"))
+	assert.Equal(t, "", lastPassExpiry(","))
+	assert.Equal(t, "Soon", lastPassExpiry("Soon"))
+	assert.Equal(t, "Work/Dev", lastPassFolder(`Work\Dev`))
+	assert.Equal(t, "", lastPassFolder("(none)"))
+}
+
+func TestOnePasswordValue(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{value: `{"concealed": "secret"}`, expected: "secret"},
+		{value: `{"monthYear": 203001}`, expected: "01/2030"},
+		{value: `{"date": 1577836800}`, expected: "2020-01-01"},
+		{value: `{"email": {"email_address": "octocat@example.com", "provider": null}}`, expected: "octocat@example.com"},
+		{value: `{"menu": true}`, expected: "true"},
+		{value: `{"address": {"city": "Berlin"}}`, expected: ""},
+	}
+	for _, tt := range tests {
+		var value map[string]json.RawMessage
+		assert.NoError(t, json.Unmarshal([]byte(tt.value), &value))
+		assert.Equal(t, tt.expected, onePasswordValue(value), tt.value)
+	}
+}
+
+func TestBrowserEntries(t *testing.T) {
+	firefox := `"url","username","password","httpRealm","formActionOrigin","guid","timeCreated","timeLastUsed","timePasswordChanged"
+"https://www.github.com","octocat","secret",,"https://github.com","{1}","1","1","1"
+"chrome://FirefoxAccounts","{uid}","{keys}","Firefox Accounts credentials",,"{2}","1","1","1"
+`
+	entries, err := browserEntries(firefox)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 2) {
+		assert.Equal(t, &model.LoginDTO{Title: "github.com", URL: "https://www.github.com", Username: "octocat", Password: "secret"}, entries[0].dto)
+		assert.NotEmpty(t, entries[1].skip)
+	}
+
+	safari := "Title,URL,Username,Password,Notes,OTPAuth\nGitHub,https://github.com/,octocat,secret,recovery codes,otpauth://totp/GitHub\n"
+	entries, err = browserEntries(safari)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 1) {
+		login := entries[0].dto.(*model.LoginDTO)
+		assert.Equal(t, "GitHub", login.Title)
+		assert.Equal(t, "recovery codes\nTOTP: otpauth://totp/GitHub", login.Extra)
+	}
+
+	assert.Equal(t, "10.0.0.1", browserTitle("http://10.0.0.1:8080/login"))
+	assert.Equal(t, "no url", browserTitle("no url"))
+}
+
+func TestCSVEntries(t *testing.T) {
+	content := "Site,Login,Secret,Comment,Kind,Group\n" +
+		"https://www.github.com,octocat,secret,recovery codes,,Work\n" +
+		",,,door code 0000,Note,\n" +
+		",,,,Card,\n"
+	mapping := &model.ImportMappingDTO{URL: "site", Username: "Login", Password: "secret", Notes: "comment", Type: "kind", Folder: "group"}
+	entries, err := csvEntries(content, mapping)
+	assert.NoError(t, err)
+	if assert.Len(t, entries, 3) {
+		assert.Equal(t, importEntry{title: "github.com", folder: "Work", dto: &model.LoginDTO{
+			Title: "github.com", URL: "https://www.github.com", Username: "octocat", Password: "secret", Extra: "recovery codes",
+		}}, entries[0])
+		assert.Equal(t, &model.NoteDTO{Note: "door code 0000"}, entries[1].dto)
+		assert.Equal(t, `rows of type "card" can't be imported`, entries[2].skip)
+	}
+	assert.Equal(t, "Login", mapping.Username)
+
+	_, err = csvEntries(content, &model.ImportMappingDTO{URL: "address"})
+	assert.True(t, errors.Is(err, ErrImportMapping))
+	_, err = csvEntries(content, &model.ImportMappingDTO{})
+	assert.Equal(t, ErrImportMapping, err)
+	_, err = csvEntries(content, nil)
+	assert.Equal(t, ErrImportMapping, err)
+}
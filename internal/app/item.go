@@ -0,0 +1,312 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/storage/revision"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Item types as they are used in the api paths
+const (
+	LoginItem       = "logins"
+	CreditCardItem  = "credit-cards"
+	BankAccountItem = "bank-accounts"
+	NoteItem        = "notes"
+	EmailItem       = "emails"
+	ServerItem      = "servers"
+)
+
+var (
+	// ItemTypes lists all item types of a user vault
+	ItemTypes = []string{LoginItem, CreditCardItem, BankAccountItem, NoteItem, EmailItem, ServerItem}
+
+	// ErrItemChanged is returned by updates of items which another update changed since
+	// they were read
+	ErrItemChanged = revision.ErrChanged
+
+	errUnknownItemType = errors.New("unknown item type")
+	copySuffix         = " (copy)"
+)
+
+// FindItem finds the item with the given type and id
+func FindItem(s storage.Store, itemType string, id uint, schema string) (interface{}, error) {
+	defer tracing.Start("app.FindItem").End()
+
+	switch itemType {
+	case LoginItem:
+		return s.Logins().FindByID(id, schema)
+	case CreditCardItem:
+		return s.CreditCards().FindByID(id, schema)
+	case BankAccountItem:
+		return s.BankAccounts().FindByID(id, schema)
+	case NoteItem:
+		return s.Notes().FindByID(id, schema)
+	case EmailItem:
+		return s.Emails().FindByID(id, schema)
+	case ServerItem:
+		return s.Servers().FindByID(id, schema)
+	}
+	return nil, errUnknownItemType
+}
+
+// AllItems returns all items of the type as a slice like []model.Login
+func AllItems(s storage.Store, itemType string, schema string) (interface{}, error) {
+	switch itemType {
+	case LoginItem:
+		return s.Logins().All(schema)
+	case CreditCardItem:
+		return s.CreditCards().All(schema)
+	case BankAccountItem:
+		return s.BankAccounts().All(schema)
+	case NoteItem:
+		return s.Notes().All(schema)
+	case EmailItem:
+		return s.Emails().All(schema)
+	case ServerItem:
+		return s.Servers().All(schema)
+	}
+	return nil, errUnknownItemType
+}
+
+// findItems returns the items of the type matching the arguments as a slice like []model.Login
+func findItems(s storage.Store, itemType string, argsStr map[string]string, argsInt map[string]int, schema string) (interface{}, error) {
+	switch itemType {
+	case LoginItem:
+		return s.Logins().FindAll(argsStr, argsInt, schema)
+	case CreditCardItem:
+		return s.CreditCards().FindAll(argsStr, argsInt, schema)
+	case BankAccountItem:
+		return s.BankAccounts().FindAll(argsStr, argsInt, schema)
+	case NoteItem:
+		return s.Notes().FindAll(argsStr, argsInt, schema)
+	case EmailItem:
+		return s.Emails().FindAll(argsStr, argsInt, schema)
+	case ServerItem:
+		return s.Servers().FindAll(argsStr, argsInt, schema)
+	}
+	return nil, errUnknownItemType
+}
+
+// findItemsOfAllTypes returns pointers to the items of all types matching the arguments
+// with their tags, by type
+func findItemsOfAllTypes(s storage.Store, argsStr map[string]string, schema string) ([]interface{}, error) {
+	argsInt := map[string]int{"limit": -1, "offset": -1}
+
+	all := []interface{}{}
+	for _, itemType := range ItemTypes {
+		items, err := findItems(s, itemType, argsStr, argsInt, schema)
+		if err != nil {
+			return nil, err
+		}
+		if err := LoadItemTags(s, items, schema); err != nil {
+			return nil, err
+		}
+
+		v := reflect.ValueOf(items)
+		for i := 0; i < v.Len(); i++ {
+			all = append(all, v.Index(i).Addr().Interface())
+		}
+	}
+	return all, nil
+}
+
+// itemPathOf returns the path of the item pointer like "logins/3"
+func itemPathOf(item interface{}) string {
+	return fmt.Sprintf("%s/%d", ItemTypeOf(item), reflect.ValueOf(item).Elem().FieldByName("ID").Uint())
+}
+
+// ItemTypeOf returns the item type of the item pointer like "logins"
+func ItemTypeOf(item interface{}) string {
+	switch item.(type) {
+	case *model.Login:
+		return LoginItem
+	case *model.CreditCard:
+		return CreditCardItem
+	case *model.BankAccount:
+		return BankAccountItem
+	case *model.Note:
+		return NoteItem
+	case *model.Email:
+		return EmailItem
+	case *model.Server:
+		return ServerItem
+	}
+	return ""
+}
+
+// SaveItem saves the item pointer to the repository of its type
+func SaveItem(s storage.Store, item interface{}, schema string) (interface{}, error) {
+	switch v := item.(type) {
+	case *model.Login:
+		return s.Logins().Save(v, schema)
+	case *model.CreditCard:
+		return s.CreditCards().Save(v, schema)
+	case *model.BankAccount:
+		return s.BankAccounts().Save(v, schema)
+	case *model.Note:
+		return s.Notes().Save(v, schema)
+	case *model.Email:
+		return s.Emails().Save(v, schema)
+	case *model.Server:
+		return s.Servers().Save(v, schema)
+	}
+	return nil, errUnknownItemType
+}
+
+// ToItemDTO converts the item pointer to the DTO of its type
+func ToItemDTO(item interface{}) interface{} {
+	switch v := item.(type) {
+	case *model.Login:
+		return model.ToLoginDTO(v)
+	case *model.CreditCard:
+		return model.ToCreditCardDTO(v)
+	case *model.BankAccount:
+		return model.ToBankAccountDTO(v)
+	case *model.Note:
+		return model.ToNoteDTO(v)
+	case *model.Email:
+		return model.ToEmailDTO(v)
+	case *model.Server:
+		return model.ToServerDTO(v)
+	}
+	return nil
+}
+
+// ItemETag returns the entity tag of the item pointer, its quoted revision
+func ItemETag(item interface{}) string {
+	return fmt.Sprintf(`"%d"`, reflect.ValueOf(item).Elem().FieldByName("Revision").Uint())
+}
+
+// ValidateItemDTO checks the DTO pointer as the create and update endpoints of its type do
+func ValidateItemDTO(dto interface{}) error {
+	switch v := dto.(type) {
+	case *model.LoginDTO:
+		return ValidateRotation(v)
+	case *model.CreditCardDTO:
+		if errs := ValidateCreditCard(v); len(errs) > 0 {
+			return errors.New(strings.Join(errs, ", "))
+		}
+	}
+	return nil
+}
+
+// UpdateItem updates the item pointer with the DTO of its type, with the update of the type
+func UpdateItem(s storage.Store, item interface{}, dto interface{}, schema string) (interface{}, error) {
+	switch v := item.(type) {
+	case *model.Login:
+		if d, ok := dto.(*model.LoginDTO); ok {
+			return UpdateLogin(s, v, d, schema)
+		}
+	case *model.CreditCard:
+		if d, ok := dto.(*model.CreditCardDTO); ok {
+			return UpdateCreditCard(s, v, d, schema)
+		}
+	case *model.BankAccount:
+		if d, ok := dto.(*model.BankAccountDTO); ok {
+			return UpdateBankAccount(s, v, d, schema)
+		}
+	case *model.Note:
+		if d, ok := dto.(*model.NoteDTO); ok {
+			return UpdateNote(s, v, d, schema)
+		}
+	case *model.Email:
+		if d, ok := dto.(*model.EmailDTO); ok {
+			return UpdateEmail(s, v, d, schema)
+		}
+	case *model.Server:
+		if d, ok := dto.(*model.ServerDTO); ok {
+			return UpdateServer(s, v, d, schema)
+		}
+	}
+	return nil, errUnknownItemType
+}
+
+// CloneItem copies the item as a new item with a "(copy)" suffix in its title, into the
+// folder of folderID of the vault when it isn't nil. Encrypted fields are copied as they
+// are, so there is no need to decrypt them.
+func CloneItem(s storage.Store, itemType string, id uint, folderID *uint, schema string) (interface{}, error) {
+	defer tracing.Start("app.CloneItem").End()
+
+	item, err := FindItem(s, itemType, id, schema)
+	if err != nil {
+		return nil, err
+	}
+	if folderID != nil {
+		if *folderID != 0 {
+			if _, err := s.Folders().FindByID(*folderID, schema); err != nil {
+				return nil, err
+			}
+		}
+		reflect.ValueOf(item).Elem().FieldByName("FolderID").SetUint(uint64(*folderID))
+	}
+	if err := LoadItemTags(s, item, schema); err != nil {
+		return nil, err
+	}
+	tags := reflect.ValueOf(item).Elem().FieldByName("Tags").Interface().([]uint)
+
+	resetItem(item)
+
+	title := titleField(item)
+	if title.IsValid() {
+		title.SetString(title.String() + copySuffix)
+	}
+
+	clone, err := SaveItem(s, item, schema)
+	if err != nil {
+		return nil, err
+	}
+	if err := SetItemTags(s, clone, tags, schema); err != nil {
+		return nil, err
+	}
+	return clone, nil
+}
+
+// resetItem clears the identity and timestamps of the item pointer
+// so the repository stores it as a new record
+func resetItem(item interface{}) {
+	v := reflect.ValueOf(item).Elem()
+	v.FieldByName("ID").SetUint(0)
+	v.FieldByName("CreatedAt").Set(reflect.ValueOf(time.Time{}))
+	v.FieldByName("UpdatedAt").Set(reflect.ValueOf(time.Time{}))
+	v.FieldByName("DeletedAt").Set(reflect.Zero(v.FieldByName("DeletedAt").Type()))
+}
+
+// titleField returns the field tagged with json:"title"
+// e.g. Title of logins, CardName of credit cards
+func titleField(item interface{}) reflect.Value {
+	v := reflect.ValueOf(item).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Tag.Get("json") == "title" {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// SetItemOrders updates the pin state and manual position of the items
+func SetItemOrders(s storage.Store, itemType string, orders []model.ItemOrderDTO, schema string) error {
+	defer tracing.Start("app.SetItemOrders").End()
+
+	for i := range orders {
+		item, err := FindItem(s, itemType, orders[i].ID, schema)
+		if err != nil {
+			return err
+		}
+
+		v := reflect.ValueOf(item).Elem()
+		v.FieldByName("Pinned").SetBool(orders[i].Pinned)
+		v.FieldByName("SortOrder").SetInt(int64(orders[i].SortOrder))
+
+		if _, err := SaveItem(s, item, schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package app
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"reflect"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// ItemExportCSV is the format of the item export
+const ItemExportCSV = "csv"
+
+var errItemExportFormat = errors.New("format should be csv")
+
+// itemExportColumn is a column of the CSV of an item type with its value of an item DTO
+type itemExportColumn struct {
+	name  string
+	value func(dto interface{}) string
+}
+
+// itemExportColumns are the columns of each item type. Logins have the columns of Chrome,
+// which password managers and browsers import, the other types their fields.
+var itemExportColumns = map[string][]itemExportColumn{
+	LoginItem: {
+		{"name", func(dto interface{}) string { return dto.(*model.LoginDTO).Title }},
+		{"url", func(dto interface{}) string { return dto.(*model.LoginDTO).URL }},
+		{"username", func(dto interface{}) string { return dto.(*model.LoginDTO).Username }},
+		{"password", func(dto interface{}) string { return dto.(*model.LoginDTO).Password }},
+		{"note", func(dto interface{}) string { return dto.(*model.LoginDTO).Extra }},
+	},
+	CreditCardItem: {
+		{"title", func(dto interface{}) string { return dto.(*model.CreditCardDTO).CardName }},
+		{"cardholder_name", func(dto interface{}) string { return dto.(*model.CreditCardDTO).CardholderName }},
+		{"type", func(dto interface{}) string { return dto.(*model.CreditCardDTO).Type }},
+		{"number", func(dto interface{}) string { return dto.(*model.CreditCardDTO).Number }},
+		{"verification_number", func(dto interface{}) string { return dto.(*model.CreditCardDTO).VerificationNumber }},
+		{"expiry_date", func(dto interface{}) string { return dto.(*model.CreditCardDTO).ExpiryDate }},
+	},
+	BankAccountItem: {
+		{"title", func(dto interface{}) string { return dto.(*model.BankAccountDTO).BankName }},
+		{"bank_code", func(dto interface{}) string { return dto.(*model.BankAccountDTO).BankCode }},
+		{"account_name", func(dto interface{}) string { return dto.(*model.BankAccountDTO).AccountName }},
+		{"account_number", func(dto interface{}) string { return dto.(*model.BankAccountDTO).AccountNumber }},
+		{"iban", func(dto interface{}) string { return dto.(*model.BankAccountDTO).IBAN }},
+		{"currency", func(dto interface{}) string { return dto.(*model.BankAccountDTO).Currency }},
+		{"password", func(dto interface{}) string { return dto.(*model.BankAccountDTO).Password }},
+	},
+	NoteItem: {
+		{"title", func(dto interface{}) string { return dto.(*model.NoteDTO).Title }},
+		{"note", func(dto interface{}) string { return dto.(*model.NoteDTO).Note }},
+	},
+	EmailItem: {
+		{"title", func(dto interface{}) string { return dto.(*model.EmailDTO).Title }},
+		{"email", func(dto interface{}) string { return dto.(*model.EmailDTO).Email }},
+		{"password", func(dto interface{}) string { return dto.(*model.EmailDTO).Password }},
+	},
+	ServerItem: {
+		{"title", func(dto interface{}) string { return dto.(*model.ServerDTO).Title }},
+		{"ip", func(dto interface{}) string { return dto.(*model.ServerDTO).IP }},
+		{"url", func(dto interface{}) string { return dto.(*model.ServerDTO).URL }},
+		{"username", func(dto interface{}) string { return dto.(*model.ServerDTO).Username }},
+		{"password", func(dto interface{}) string { return dto.(*model.ServerDTO).Password }},
+		{"hosting_username", func(dto interface{}) string { return dto.(*model.ServerDTO).HostingUsername }},
+		{"hosting_password", func(dto interface{}) string { return dto.(*model.ServerDTO).HostingPassword }},
+		{"admin_username", func(dto interface{}) string { return dto.(*model.ServerDTO).AdminUsername }},
+		{"admin_password", func(dto interface{}) string { return dto.(*model.ServerDTO).AdminPassword }},
+		{"extra", func(dto interface{}) string { return dto.(*model.ServerDTO).Extra }},
+	},
+}
+
+// CheckItemExportFormat checks the format before the export starts writing
+func CheckItemExportFormat(format string) error {
+	if format != ItemExportCSV {
+		return errItemExportFormat
+	}
+	return nil
+}
+
+// ExportItemsCSV writes the decrypted items of the type as CSV to w
+func ExportItemsCSV(s storage.Store, w io.Writer, itemType string, userID uint, schema string) error {
+	defer tracing.Start("app.ExportItemsCSV").End()
+
+	columns, ok := itemExportColumns[itemType]
+	if !ok {
+		return errUnknownItemType
+	}
+	items, err := AllItems(s, itemType, schema)
+	if err != nil {
+		return err
+	}
+	TripCanaries(s, userID, CanaryRead, "export", items)
+
+	rows := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.name
+	}
+	if err := rows.Write(header); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(items)
+	for i := 0; i < v.Len(); i++ {
+		dto := ToItemDTO(v.Index(i).Addr().Interface())
+		record := make([]string, len(columns))
+		for j, column := range columns {
+			record[j] = column.value(dto)
+		}
+		if err := rows.Write(record); err != nil {
+			return err
+		}
+	}
+	rows.Flush()
+	if err := rows.Error(); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"event":     "export",
+		"user_id":   userID,
+		"item_type": itemType,
+		"items":     v.Len(),
+	}).Info("items are exported as csv")
+	return nil
+}
@@ -0,0 +1,55 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCloneItem(t *testing.T) {
+	mocks := storagetest.NewMocks()
+	mocks.Notes.On("FindByID", uint(3), "user1").Return(&model.Note{ID: 3, Title: "Wifi", Note: "enc"}, nil)
+	mocks.Notes.On("Save", mock.MatchedBy(func(n *model.Note) bool {
+		return n.ID == 0 && n.Title == "Wifi (copy)" && n.Note == "enc"
+	}), "user1").Return(&model.Note{ID: 4, Title: "Wifi (copy)", Note: "enc"}, nil)
+	mocks.Tags.On("FindItemTags", NoteItem, []uint{3}, "user1").Return([]model.ItemTag{{ItemType: NoteItem, ItemID: 3, TagID: 7}}, nil)
+	mocks.Tags.On("FindByID", uint(7), "user1").Return(&model.Tag{ID: 7}, nil)
+	mocks.Tags.On("SetItemTags", NoteItem, uint(4), []uint{7}, "user1").Return(nil)
+
+	cloned, err := CloneItem(mocks.Store, NoteItem, 3, nil, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, uint(4), cloned.(*model.Note).ID)
+	assert.Equal(t, []uint{7}, cloned.(*model.Note).Tags)
+	mocks.AssertExpectations(t)
+}
+
+func TestCloneItemToMissingFolder(t *testing.T) {
+	errNotFound := errors.New("record not found")
+	mocks := storagetest.NewMocks()
+	mocks.Notes.On("FindByID", uint(3), "user1").Return(&model.Note{ID: 3, Title: "Wifi"}, nil)
+	mocks.Folders.On("FindByID", uint(9), "user1").Return(nil, errNotFound)
+
+	folderID := uint(9)
+	_, err := CloneItem(mocks.Store, NoteItem, 3, &folderID, "user1")
+	assert.Equal(t, errNotFound, err)
+	mocks.AssertExpectations(t)
+}
+
+func TestSetItemOrdersNotFound(t *testing.T) {
+	errNotFound := errors.New("record not found")
+	mocks := storagetest.NewMocks()
+	mocks.Logins.On("FindByID", uint(1), "user1").Return(&model.Login{ID: 1}, nil)
+	mocks.Logins.On("Save", mock.AnythingOfType("*model.Login"), "user1").Return(&model.Login{ID: 1}, nil)
+	mocks.Logins.On("FindByID", uint(2), "user1").Return(nil, errNotFound)
+
+	err := SetItemOrders(mocks.Store, LoginItem, []model.ItemOrderDTO{
+		{ID: 1, Pinned: true, SortOrder: 2},
+		{ID: 2, SortOrder: 1},
+	}, "user1")
+	assert.Equal(t, errNotFound, err)
+	mocks.AssertExpectations(t)
+}
@@ -0,0 +1,165 @@
+package app
+
+import (
+	"encoding/json"
+	"reflect"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// saveItemVersion keeps the item pointer as it is before an update and drops the versions
+// of the item beyond server.itemVersions. Nothing is kept when it is 0.
+func saveItemVersion(s storage.Store, item interface{}, schema string) error {
+	keep := viper.GetInt("server.itemVersions")
+	if keep <= 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+	itemType := ItemTypeOf(item)
+	itemID := uint(reflect.ValueOf(item).Elem().FieldByName("ID").Uint())
+	version := &model.ItemVersion{
+		ItemType: itemType,
+		ItemID:   itemID,
+		Data:     string(data),
+	}
+	if err := s.ItemVersions().Create(version, schema); err != nil {
+		return err
+	}
+	return s.ItemVersions().DeleteOlder(itemType, itemID, keep, schema)
+}
+
+// itemOfVersion decodes the item pointer of the version
+func itemOfVersion(version *model.ItemVersion) (interface{}, error) {
+	items, err := newItems(version.ItemType)
+	if err != nil {
+		return nil, err
+	}
+	item := reflect.New(reflect.TypeOf(items).Elem().Elem()).Interface()
+	if err := json.Unmarshal([]byte(version.Data), item); err != nil {
+		return nil, err
+	}
+	return item, nil
+}
+
+// ItemConflict returns the conflict of the update DTO pointer with the item pointer, nil
+// when the DTO has no revision or the one of the item. Updates without a revision win.
+func ItemConflict(s storage.Store, item interface{}, dto interface{}, schema string) (*model.ConflictDTO, error) {
+	base := uint(reflect.ValueOf(dto).Elem().FieldByName("Revision").Uint())
+	current := reflect.ValueOf(item).Elem()
+	if base == 0 || base == uint(current.FieldByName("Revision").Uint()) {
+		return nil, nil
+	}
+
+	itemType := ItemTypeOf(item)
+	itemID := uint(current.FieldByName("ID").Uint())
+	conflict := &model.ConflictDTO{
+		Type:         itemType,
+		ID:           itemID,
+		BaseRevision: base,
+		Current:      ToItemDTO(item),
+		Proposed:     dto,
+	}
+
+	// Each version is the item before an update, the one of the base is its state then
+	versions, err := s.ItemVersions().FindByItem(itemType, itemID, schema)
+	if err != nil {
+		return nil, err
+	}
+	for i := range versions {
+		version, err := itemOfVersion(&versions[i])
+		if err != nil {
+			return nil, err
+		}
+		if uint(reflect.ValueOf(version).Elem().FieldByName("Revision").Uint()) == base {
+			conflict.Base = ToItemDTO(version)
+			break
+		}
+	}
+	return conflict, nil
+}
+
+// FindItemVersions returns the versions of the item, newest first
+func FindItemVersions(s storage.Store, itemType string, id uint, schema string) ([]model.ItemVersionDTO, error) {
+	defer tracing.Start("app.FindItemVersions").End()
+
+	versions, err := s.ItemVersions().FindByItem(itemType, id, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	dtos := []model.ItemVersionDTO{}
+	for i := range versions {
+		item, err := itemOfVersion(&versions[i])
+		if err != nil {
+			return nil, err
+		}
+		dtos = append(dtos, model.ItemVersionDTO{
+			ID:        versions[i].ID,
+			ItemType:  versions[i].ItemType,
+			ItemID:    versions[i].ItemID,
+			CreatedAt: versions[i].CreatedAt,
+			Item:      ToItemDTO(item),
+		})
+	}
+	return dtos, nil
+}
+
+// RestoreItemVersion sets the fields of the item back to the version. The item before the
+// restore is kept as a version too, so the restore can be undone.
+func RestoreItemVersion(s storage.Store, item interface{}, versionID uint, schema string) (interface{}, error) {
+	defer tracing.Start("app.RestoreItemVersion").End()
+
+	itemType := ItemTypeOf(item)
+	current := reflect.ValueOf(item).Elem()
+	itemID := uint(current.FieldByName("ID").Uint())
+
+	version, err := s.ItemVersions().FindByID(versionID, schema)
+	if err != nil {
+		return nil, err
+	}
+	if version.ItemType != itemType || version.ItemID != itemID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	restored, err := itemOfVersion(version)
+	if err != nil {
+		return nil, err
+	}
+
+	if login, ok := item.(*model.Login); ok {
+		if err := savePasswordHistory(s, login, restored.(*model.Login).Password, schema); err != nil {
+			return nil, err
+		}
+	}
+	if err := saveItemVersion(s, item, schema); err != nil {
+		return nil, err
+	}
+
+	// The identity of the item stays, only its fields go back
+	v := reflect.ValueOf(restored).Elem()
+	for _, field := range []string{"ID", "CreatedAt", "DeletedAt", "Revision"} {
+		v.FieldByName(field).Set(current.FieldByName(field))
+	}
+
+	updated, err := SaveItem(s, restored, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"event":     "item_version_restored",
+		"schema":    schema,
+		"item_type": itemType,
+		"item_id":   itemID,
+		"version":   versionID,
+	}).Info("item restored to a previous version")
+	return updated, nil
+}
@@ -0,0 +1,573 @@
+package keepass
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"math"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/salsa20/salsa"
+	"golang.org/x/crypto/twofish"
+)
+
+// Signatures which start KDBX files
+const (
+	signature1 = 0x9AA2D903
+	signature2 = 0xB54BFB67
+)
+
+// Fields of the outer header, the ones of KDBX 3 and 4
+const (
+	fieldEnd             = 0
+	fieldCipherID        = 2
+	fieldCompression     = 3
+	fieldMasterSeed      = 4
+	fieldTransformSeed   = 5  // KDBX 3
+	fieldTransformRounds = 6  // KDBX 3
+	fieldEncryptionIV    = 7  // KDBX 3
+	fieldStreamKey       = 8  // KDBX 3
+	fieldStreamStart     = 9  // KDBX 3
+	fieldStreamID        = 10 // KDBX 3
+	fieldKDFParameters   = 11 // KDBX 4
+)
+
+// Fields of the inner header of KDBX 4
+const (
+	innerFieldEnd       = 0
+	innerFieldStreamID  = 1
+	innerFieldStreamKey = 2
+)
+
+// Ciphers of protected values
+const (
+	streamNone     = 0
+	streamSalsa20  = 2
+	streamChaCha20 = 3
+)
+
+// UUIDs of the ciphers and key derivations
+const (
+	cipherAES      = "31c1f2e6bf714350be5805216afc5aff"
+	cipherTwofish  = "ad68f29f576f4bb9a36ad47af965346c"
+	cipherChaCha20 = "d6038a2b8b6f4cb5a524339a31dbb59a"
+	kdfAES         = "c9d9f39a628a4460bf740d08c18a4fea"
+	kdfAESKDBX3    = "7c02bb8279a74ac0927d114a00648238"
+	kdfArgon2d     = "ef636ddf8c29444b91f7a9a403e30a0c"
+	kdfArgon2id    = "9e298b1956db4773b23dfc3ec6f0a1e6"
+)
+
+// Limits of the key derivation and the XML, databases of other parameters aren't read so
+// imports don't exhaust the server
+const (
+	maxRounds      = 100000000 // AES-KDF
+	maxMemory      = 256 << 20 // bytes of Argon2
+	maxIterations  = 100       // of Argon2
+	maxParallelism = 64        // of Argon2
+	maxXML         = 256 << 20
+)
+
+// salsaNonce is the nonce of the Salsa20 stream of protected values
+var salsaNonce = []byte{0xE8, 0x30, 0x09, 0x4B, 0x97, 0x20, 0x5D, 0x2A}
+
+// header is the outer header of a database
+type header struct {
+	major      uint16
+	cipherID   string
+	compressed bool
+	masterSeed []byte
+	iv         []byte
+
+	// KDBX 3
+	transformSeed   []byte
+	transformRounds uint64
+	streamKey       []byte
+	streamStart     []byte
+	streamID        uint32
+
+	// KDBX 4
+	kdf map[string]interface{}
+}
+
+// decrypt returns the XML of a database and the stream of its protected values
+func decrypt(data []byte, password string) ([]byte, cipher.Stream, error) {
+	h, headerLen, err := readHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	passwordHash := sha256.Sum256([]byte(password))
+	compositeKey := sha256.Sum256(passwordHash[:])
+	transformedKey, err := transformKey(h, compositeKey[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	masterKey := sha256.Sum256(append(append([]byte{}, h.masterSeed...), transformedKey...))
+
+	if h.major == 3 {
+		return decryptKDBX3(h, data[headerLen:], masterKey[:])
+	}
+	hmacKey := sha512.Sum512(append(append(append([]byte{}, h.masterSeed...), transformedKey...), 1))
+	return decryptKDBX4(h, data[:headerLen], data[headerLen:], masterKey[:], hmacKey[:])
+}
+
+func decryptKDBX3(h *header, data, masterKey []byte) ([]byte, cipher.Stream, error) {
+	payload, err := decryptPayload(h.cipherID, masterKey, h.iv, data)
+	if err == ErrUnsupported {
+		return nil, nil, err
+	}
+	// A wrong key garbles the padding too
+	if err != nil || len(payload) < len(h.streamStart) || !bytes.Equal(payload[:len(h.streamStart)], h.streamStart) {
+		return nil, nil, ErrCredentials
+	}
+
+	content, err := readHashedBlocks(payload[len(h.streamStart):])
+	if err != nil {
+		return nil, nil, err
+	}
+	if content, err = decompress(h, content); err != nil {
+		return nil, nil, err
+	}
+	stream, err := innerStream(h.streamID, h.streamKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return content, stream, nil
+}
+
+func decryptKDBX4(h *header, headerData, data, masterKey, hmacKey []byte) ([]byte, cipher.Stream, error) {
+	if len(data) < 2*sha256.Size {
+		return nil, nil, ErrInvalid
+	}
+	sum := sha256.Sum256(headerData)
+	if !bytes.Equal(sum[:], data[:sha256.Size]) {
+		return nil, nil, ErrInvalid
+	}
+	mac := blockHMAC(hmacKey, math.MaxUint64)
+	mac.Write(headerData)
+	if !hmac.Equal(mac.Sum(nil), data[sha256.Size:2*sha256.Size]) {
+		return nil, nil, ErrCredentials
+	}
+
+	encrypted, err := readHMACBlocks(data[2*sha256.Size:], hmacKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	payload, err := decryptPayload(h.cipherID, masterKey, h.iv, encrypted)
+	if err != nil {
+		return nil, nil, err
+	}
+	if payload, err = decompress(h, payload); err != nil {
+		return nil, nil, err
+	}
+
+	// The inner header has the cipher of the protected values
+	streamID, streamKey := uint32(0), []byte(nil)
+	for {
+		if len(payload) < 5 {
+			return nil, nil, ErrInvalid
+		}
+		id, size := payload[0], binary.LittleEndian.Uint32(payload[1:5])
+		if uint64(size) > uint64(len(payload)-5) {
+			return nil, nil, ErrInvalid
+		}
+		value := payload[5 : 5+size]
+		payload = payload[5+size:]
+		if id == innerFieldEnd {
+			break
+		}
+		switch id {
+		case innerFieldStreamID:
+			if len(value) != 4 {
+				return nil, nil, ErrInvalid
+			}
+			streamID = binary.LittleEndian.Uint32(value)
+		case innerFieldStreamKey:
+			streamKey = value
+		}
+	}
+	stream, err := innerStream(streamID, streamKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	return payload, stream, nil
+}
+
+// readHeader reads the outer header and returns it with its length
+func readHeader(data []byte) (*header, int, error) {
+	if len(data) < 12 || binary.LittleEndian.Uint32(data[0:4]) != signature1 || binary.LittleEndian.Uint32(data[4:8]) != signature2 {
+		return nil, 0, ErrInvalid
+	}
+	h := &header{major: binary.LittleEndian.Uint16(data[10:12])}
+	if h.major != 3 && h.major != 4 {
+		return nil, 0, ErrUnsupported
+	}
+
+	offset := 12
+	for {
+		sizeLen := 2
+		if h.major == 4 {
+			sizeLen = 4
+		}
+		if len(data) < offset+1+sizeLen {
+			return nil, 0, ErrInvalid
+		}
+		id := data[offset]
+		size := uint64(binary.LittleEndian.Uint16(data[offset+1:]))
+		if h.major == 4 {
+			size = uint64(binary.LittleEndian.Uint32(data[offset+1:]))
+		}
+		offset += 1 + sizeLen
+		if size > uint64(len(data)-offset) {
+			return nil, 0, ErrInvalid
+		}
+		value := data[offset : offset+int(size)]
+		offset += int(size)
+
+		switch id {
+		case fieldEnd:
+			return h, offset, h.validate()
+		case fieldCipherID:
+			h.cipherID = hex.EncodeToString(value)
+		case fieldCompression:
+			h.compressed = len(value) == 4 && binary.LittleEndian.Uint32(value) == 1
+		case fieldMasterSeed:
+			h.masterSeed = value
+		case fieldTransformSeed:
+			h.transformSeed = value
+		case fieldTransformRounds:
+			if len(value) != 8 {
+				return nil, 0, ErrInvalid
+			}
+			h.transformRounds = binary.LittleEndian.Uint64(value)
+		case fieldEncryptionIV:
+			h.iv = value
+		case fieldStreamKey:
+			h.streamKey = value
+		case fieldStreamStart:
+			h.streamStart = value
+		case fieldStreamID:
+			if len(value) != 4 {
+				return nil, 0, ErrInvalid
+			}
+			h.streamID = binary.LittleEndian.Uint32(value)
+		case fieldKDFParameters:
+			kdf, err := readVariantDictionary(value)
+			if err != nil {
+				return nil, 0, err
+			}
+			h.kdf = kdf
+		}
+	}
+}
+
+func (h *header) validate() error {
+	if len(h.masterSeed) == 0 || h.cipherID == "" {
+		return ErrInvalid
+	}
+	if h.major == 3 && (len(h.transformSeed) != 32 || len(h.streamStart) == 0) {
+		return ErrInvalid
+	}
+	if h.major == 4 && h.kdf == nil {
+		return ErrInvalid
+	}
+	return nil
+}
+
+// transformKey derives the key of the database from the composite key of its credentials
+func transformKey(h *header, compositeKey []byte) ([]byte, error) {
+	if h.major == 3 {
+		return aesKDF(compositeKey, h.transformSeed, h.transformRounds)
+	}
+
+	uuid, _ := h.kdf["$UUID"].([]byte)
+	salt, _ := h.kdf["S"].([]byte)
+	switch hex.EncodeToString(uuid) {
+	case kdfAES, kdfAESKDBX3:
+		rounds, _ := h.kdf["R"].(uint64)
+		return aesKDF(compositeKey, salt, rounds)
+	case kdfArgon2d, kdfArgon2id:
+		iterations, _ := h.kdf["I"].(uint64)
+		memory, _ := h.kdf["M"].(uint64)
+		parallelism, _ := h.kdf["P"].(uint32)
+		version, _ := h.kdf["V"].(uint32)
+		secret, _ := h.kdf["K"].([]byte)
+		data, _ := h.kdf["A"].([]byte)
+		if version != argon2Version {
+			return nil, ErrUnsupported
+		}
+		if iterations < 1 || iterations > maxIterations || memory > maxMemory || parallelism < 1 || parallelism > maxParallelism {
+			return nil, fmt.Errorf("%w: the key derivation is too expensive", ErrUnsupported)
+		}
+		if hex.EncodeToString(uuid) == kdfArgon2d {
+			return argon2dKey(compositeKey, salt, secret, data, uint32(iterations), uint32(memory/1024), uint8(parallelism), 32), nil
+		}
+		if len(secret) > 0 || len(data) > 0 {
+			return nil, ErrUnsupported
+		}
+		return argon2.IDKey(compositeKey, salt, uint32(iterations), uint32(memory/1024), uint8(parallelism), 32), nil
+	}
+	return nil, ErrUnsupported
+}
+
+// aesKDF encrypts the key with the seed for the rounds
+func aesKDF(key, seed []byte, rounds uint64) ([]byte, error) {
+	if rounds > maxRounds {
+		return nil, fmt.Errorf("%w: the key derivation is too expensive", ErrUnsupported)
+	}
+	block, err := aes.NewCipher(seed)
+	if err != nil || len(key) != 32 {
+		return nil, ErrInvalid
+	}
+	transformed := append([]byte{}, key...)
+	for i := uint64(0); i < rounds; i++ {
+		block.Encrypt(transformed[:16], transformed[:16])
+		block.Encrypt(transformed[16:], transformed[16:])
+	}
+	sum := sha256.Sum256(transformed)
+	return sum[:], nil
+}
+
+// decryptPayload decrypts the payload with the cipher of the header
+func decryptPayload(cipherID string, key, iv, data []byte) ([]byte, error) {
+	var block cipher.Block
+	var err error
+	switch cipherID {
+	case cipherAES:
+		block, err = aes.NewCipher(key)
+	case cipherTwofish:
+		block, err = twofish.NewCipher(key)
+	case cipherChaCha20:
+		stream, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		payload := make([]byte, len(data))
+		stream.XORKeyStream(payload, data)
+		return payload, nil
+	default:
+		return nil, ErrUnsupported
+	}
+	if err != nil {
+		return nil, ErrInvalid
+	}
+
+	size := block.BlockSize()
+	if len(iv) != size || len(data) == 0 || len(data)%size != 0 {
+		return nil, ErrInvalid
+	}
+	payload := make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(payload, data)
+
+	padding := int(payload[len(payload)-1])
+	if padding == 0 || padding > size {
+		return nil, ErrInvalid
+	}
+	for _, b := range payload[len(payload)-padding:] {
+		if int(b) != padding {
+			return nil, ErrInvalid
+		}
+	}
+	return payload[:len(payload)-padding], nil
+}
+
+// readHashedBlocks reads the blocks of KDBX 3, each has the SHA-256 of its data
+func readHashedBlocks(data []byte) ([]byte, error) {
+	var content bytes.Buffer
+	for {
+		if len(data) < 40 {
+			return nil, ErrInvalid
+		}
+		sum, size := data[4:36], binary.LittleEndian.Uint32(data[36:40])
+		data = data[40:]
+		if size == 0 {
+			return content.Bytes(), nil
+		}
+		if uint64(size) > uint64(len(data)) {
+			return nil, ErrInvalid
+		}
+		if blockSum := sha256.Sum256(data[:size]); !bytes.Equal(blockSum[:], sum) {
+			return nil, ErrInvalid
+		}
+		content.Write(data[:size])
+		data = data[size:]
+	}
+}
+
+// readHMACBlocks reads the blocks of KDBX 4, each has an HMAC of its index, size and data
+func readHMACBlocks(data []byte, hmacKey []byte) ([]byte, error) {
+	var content bytes.Buffer
+	for index := uint64(0); ; index++ {
+		if len(data) < sha256.Size+4 {
+			return nil, ErrInvalid
+		}
+		sum, sizeData := data[:sha256.Size], data[sha256.Size:sha256.Size+4]
+		size := binary.LittleEndian.Uint32(sizeData)
+		data = data[sha256.Size+4:]
+		if uint64(size) > uint64(len(data)) {
+			return nil, ErrInvalid
+		}
+
+		var indexData [8]byte
+		binary.LittleEndian.PutUint64(indexData[:], index)
+		mac := blockHMAC(hmacKey, index)
+		mac.Write(indexData[:])
+		mac.Write(sizeData)
+		mac.Write(data[:size])
+		if !hmac.Equal(mac.Sum(nil), sum) {
+			return nil, ErrInvalid
+		}
+		if size == 0 {
+			return content.Bytes(), nil
+		}
+		content.Write(data[:size])
+		data = data[size:]
+	}
+}
+
+// blockHMAC returns the HMAC of the block of the index, the header is the block math.MaxUint64
+func blockHMAC(hmacKey []byte, index uint64) hash.Hash {
+	var indexData [8]byte
+	binary.LittleEndian.PutUint64(indexData[:], index)
+	key := sha512.Sum512(append(indexData[:], hmacKey...))
+	return hmac.New(sha256.New, key[:])
+}
+
+func decompress(h *header, data []byte) ([]byte, error) {
+	if !h.compressed {
+		return data, nil
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	content, err := ioutil.ReadAll(io.LimitReader(r, maxXML))
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	return content, nil
+}
+
+// readVariantDictionary reads the typed values of the key derivation parameters
+func readVariantDictionary(data []byte) (map[string]interface{}, error) {
+	if len(data) < 2 || binary.LittleEndian.Uint16(data)&0xFF00 != 0x0100 {
+		return nil, ErrUnsupported
+	}
+	data = data[2:]
+
+	dictionary := map[string]interface{}{}
+	for {
+		if len(data) < 1 {
+			return nil, ErrInvalid
+		}
+		kind := data[0]
+		if kind == 0 {
+			return dictionary, nil
+		}
+		key, rest, err := readSized(data[1:])
+		if err != nil {
+			return nil, err
+		}
+		value, rest, err := readSized(rest)
+		if err != nil {
+			return nil, err
+		}
+		data = rest
+
+		switch {
+		case kind == 0x04 && len(value) == 4:
+			dictionary[string(key)] = binary.LittleEndian.Uint32(value)
+		case kind == 0x05 && len(value) == 8:
+			dictionary[string(key)] = binary.LittleEndian.Uint64(value)
+		case kind == 0x08 && len(value) == 1:
+			dictionary[string(key)] = value[0] != 0
+		case kind == 0x0C && len(value) == 4:
+			dictionary[string(key)] = int32(binary.LittleEndian.Uint32(value))
+		case kind == 0x0D && len(value) == 8:
+			dictionary[string(key)] = int64(binary.LittleEndian.Uint64(value))
+		case kind == 0x18:
+			dictionary[string(key)] = string(value)
+		case kind == 0x42:
+			dictionary[string(key)] = value
+		default:
+			return nil, ErrInvalid
+		}
+	}
+}
+
+// readSized reads a value after its int32 size
+func readSized(data []byte) ([]byte, []byte, error) {
+	if len(data) < 4 {
+		return nil, nil, ErrInvalid
+	}
+	size := binary.LittleEndian.Uint32(data)
+	if uint64(size) > uint64(len(data)-4) {
+		return nil, nil, ErrInvalid
+	}
+	return data[4 : 4+size], data[4+size:], nil
+}
+
+// innerStream returns the cipher of the protected values
+func innerStream(id uint32, key []byte) (cipher.Stream, error) {
+	switch id {
+	case streamNone:
+		return nullStream{}, nil
+	case streamSalsa20:
+		return newSalsaStream(sha256.Sum256(key), salsaNonce), nil
+	case streamChaCha20:
+		sum := sha512.Sum512(key)
+		stream, err := chacha20.NewUnauthenticatedCipher(sum[:32], sum[32:44])
+		if err != nil {
+			return nil, ErrInvalid
+		}
+		return stream, nil
+	}
+	return nil, ErrUnsupported
+}
+
+// nullStream keeps protected values as they are, they are still base64 encoded
+type nullStream struct{}
+
+func (nullStream) XORKeyStream(dst, src []byte) {
+	copy(dst, src)
+}
+
+// salsaStream is the Salsa20 key stream across the protected values
+type salsaStream struct {
+	key     [32]byte
+	counter [16]byte
+	index   uint64
+	block   [64]byte
+	used    int
+}
+
+func newSalsaStream(key [32]byte, nonce []byte) *salsaStream {
+	s := &salsaStream{key: key, used: 64}
+	copy(s.counter[:8], nonce)
+	return s
+}
+
+func (s *salsaStream) XORKeyStream(dst, src []byte) {
+	for i := range src {
+		if s.used == len(s.block) {
+			var zero [64]byte
+			binary.LittleEndian.PutUint64(s.counter[8:], s.index)
+			salsa.XORKeyStream(s.block[:], zero[:], &s.counter, &s.key)
+			s.index++
+			s.used = 0
+		}
+		dst[i] = src[i] ^ s.block[s.used]
+		s.used++
+	}
+}
@@ -0,0 +1,221 @@
+// Package keepass reads the entries of KeePass databases, KDBX 3.1 and 4 files protected by
+// a password, and of their XML exports. Databases with a key file or a key provider can't
+// be opened, export them as XML then.
+package keepass
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+var (
+	// ErrInvalid is the error of a file which isn't a KeePass database
+	ErrInvalid = errors.New("keepass: file is not a valid KeePass database")
+	// ErrCredentials is the error of a wrong password, or of a database which needs a key file
+	ErrCredentials = errors.New("keepass: password is wrong")
+	// ErrUnsupported is the error of a database of another version, cipher or key derivation
+	ErrUnsupported = errors.New("keepass: database format is not supported")
+)
+
+// Entry is an entry of a database, entries of the history aren't read
+type Entry struct {
+	Group            string // path of the groups below the root group, like "Work/Dev"
+	Recycled         bool   // in the recycle bin
+	Title            string
+	UserName         string
+	Password         string
+	URL              string
+	Notes            string
+	Fields           []Field // custom strings in the order of the database
+	AutoTypeSequence string
+	AutoTypeWindow   string
+}
+
+// Field is a custom string of an entry
+type Field struct {
+	Key   string
+	Value string
+}
+
+// Read returns the entries of the KDBX database protected by the password
+func Read(data []byte, password string) ([]Entry, error) {
+	content, stream, err := decrypt(data, password)
+	if err != nil {
+		return nil, err
+	}
+	return parse(bytes.NewReader(content), stream)
+}
+
+// ReadXML returns the entries of the XML export of a database
+func ReadXML(data []byte) ([]Entry, error) {
+	return parse(bytes.NewReader(data), nil)
+}
+
+func (e *Entry) set(key, value string) {
+	switch key {
+	case "Title":
+		e.Title = value
+	case "UserName":
+		e.UserName = value
+	case "Password":
+		e.Password = value
+	case "URL":
+		e.URL = value
+	case "Notes":
+		e.Notes = value
+	default:
+		e.Fields = append(e.Fields, Field{Key: key, Value: value})
+	}
+}
+
+// group is a group the parser is in
+type group struct {
+	name     string
+	recycled bool
+}
+
+// parse reads the entries of the XML in the order of the file. Protected values are
+// decrypted with the stream in the order they appear, history entries too, which is why
+// the XML is read token by token. Exports have no stream, their values aren't protected.
+func parse(r io.Reader, stream cipher.Stream) ([]Entry, error) {
+	decoder := xml.NewDecoder(r)
+	var (
+		path       []string
+		text       []byte
+		protected  bool
+		groups     []group
+		recycleBin string
+		history    int
+		entry      *Entry
+		key        string
+		sequence   string // of the first auto-type association
+		entries    = []Entry{}
+	)
+
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, ErrInvalid
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(path) == 0 && t.Name.Local != "KeePassFile" {
+				return nil, ErrInvalid
+			}
+			path = append(path, t.Name.Local)
+			text = text[:0]
+			protected = false
+			for _, attr := range t.Attr {
+				if attr.Name.Local == "Protected" && strings.EqualFold(attr.Value, "true") {
+					protected = true
+				}
+			}
+
+			switch t.Name.Local {
+			case "Group":
+				g := group{}
+				if len(groups) > 0 {
+					g.recycled = groups[len(groups)-1].recycled
+				}
+				groups = append(groups, g)
+			case "History":
+				history++
+			case "Entry":
+				if history == 0 {
+					entry = &Entry{Group: groupPath(groups)}
+					if len(groups) > 0 {
+						entry.Recycled = groups[len(groups)-1].recycled
+					}
+					sequence = ""
+				}
+			}
+		case xml.CharData:
+			text = append(text, t...)
+		case xml.EndElement:
+			if len(path) == 0 {
+				return nil, ErrInvalid
+			}
+			value := string(text)
+			if protected && stream != nil {
+				if value, err = unprotect(value, stream); err != nil {
+					return nil, ErrInvalid
+				}
+			}
+			protected = false
+			text = text[:0]
+			parent := ""
+			if len(path) > 1 {
+				parent = path[len(path)-2]
+			}
+			path = path[:len(path)-1]
+			current := entry != nil && history == 0
+
+			switch {
+			case t.Name.Local == "RecycleBinUUID" && parent == "Meta":
+				recycleBin = value
+			case t.Name.Local == "Name" && parent == "Group" && len(groups) > 0:
+				groups[len(groups)-1].name = value
+			case t.Name.Local == "UUID" && parent == "Group" && len(groups) > 0:
+				if recycleBin != "" && value == recycleBin {
+					groups[len(groups)-1].recycled = true
+				}
+			case t.Name.Local == "Key" && parent == "String":
+				key = value
+			case t.Name.Local == "Value" && parent == "String" && current:
+				entry.set(key, value)
+			case t.Name.Local == "DefaultSequence" && parent == "AutoType" && current:
+				entry.AutoTypeSequence = value
+			case t.Name.Local == "Window" && parent == "Association" && current:
+				if entry.AutoTypeWindow == "" {
+					entry.AutoTypeWindow = value
+				}
+			case t.Name.Local == "KeystrokeSequence" && parent == "Association" && current:
+				if sequence == "" {
+					sequence = value
+				}
+			case t.Name.Local == "Entry" && current:
+				if entry.AutoTypeSequence == "" {
+					entry.AutoTypeSequence = sequence
+				}
+				entries = append(entries, *entry)
+				entry = nil
+			case t.Name.Local == "History":
+				history--
+			case t.Name.Local == "Group" && len(groups) > 0:
+				groups = groups[:len(groups)-1]
+			}
+		}
+	}
+	if len(path) != 0 {
+		return nil, ErrInvalid
+	}
+	return entries, nil
+}
+
+// groupPath returns the names of the groups below the root group joined by "/"
+func groupPath(groups []group) string {
+	names := []string{}
+	for i := 1; i < len(groups); i++ {
+		names = append(names, groups[i].name)
+	}
+	return strings.Join(names, "/")
+}
+
+// unprotect decrypts the base64 value with the stream
+func unprotect(value string, stream cipher.Stream) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", err
+	}
+	stream.XORKeyStream(data, data)
+	return string(data), nil
+}
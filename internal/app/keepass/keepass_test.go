@@ -0,0 +1,355 @@
+package keepass
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"math"
+	"testing"
+	"text/template"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/chacha20"
+)
+
+func TestArgon2d(t *testing.T) {
+	fill := func(b byte, n int) []byte { return bytes.Repeat([]byte{b}, n) }
+
+	// The test vector of the Argon2 specification
+	key := argon2dKey(fill(1, 32), fill(2, 16), fill(3, 8), fill(4, 12), 3, 32, 4, 32)
+	assert.Equal(t, "512b391b6f1162975371d30919734294f868e3be3984f3c1a13a4db9fabe4acb", hex.EncodeToString(key))
+
+	tests := []struct {
+		time, memory uint32
+		threads      uint8
+		want         string
+	}{
+		{1, 64, 1, "8727405fd07c32c78d64f547f24150d3f2e703a89f981a19"},
+		{2, 64, 1, "3be9ec79a69b75d3752acb59a1fbb8b295a46529c48fbb75"},
+		{4, 4096, 4, "935598181aa8dc2b720914aa6435ac8d3e3a4210c5b0fb2d"},
+	}
+	for _, tt := range tests {
+		key := argon2dKey([]byte("password"), []byte("somesalt"), nil, nil, tt.time, tt.memory, tt.threads, 24)
+		assert.Equal(t, tt.want, hex.EncodeToString(key))
+	}
+}
+
+// testXML is a database with a protected password in the history, which has to be xored
+// with the stream too
+const testXML = `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Meta>
+		<RecycleBinUUID>cmVjeWNsZWQ=</RecycleBinUUID>
+	</Meta>
+	<Root>
+		<Group>
+			<UUID>cm9vdA==</UUID>
+			<Name>Database</Name>
+			<Entry>
+				<String><Key>Title</Key><Value>Mail</Value></String>
+				<String><Key>UserName</Key><Value>jane</Value></String>
+				<String><Key>Password</Key><Value Protected="True">{{protect "mail secret"}}</Value></String>
+				<String><Key>URL</Key><Value>https://mail.example.com</Value></String>
+				<AutoType>
+					<Association><Window>Mail*</Window><KeystrokeSequence>{PASSWORD}{ENTER}</KeystrokeSequence></Association>
+				</AutoType>
+				<History>
+					<Entry>
+						<String><Key>Title</Key><Value>Old mail</Value></String>
+						<String><Key>Password</Key><Value Protected="True">{{protect "old secret"}}</Value></String>
+					</Entry>
+				</History>
+			</Entry>
+			<Group>
+				<UUID>d29yaw==</UUID>
+				<Name>Work</Name>
+				<Group>
+					<UUID>ZGV2</UUID>
+					<Name>Dev</Name>
+					<Entry>
+						<String><Key>Title</Key><Value>Git</Value></String>
+						<String><Key>Notes</Key><Value>Deploy key</Value></String>
+						<String><Key>Password</Key><Value Protected="True">{{protect "git secret"}}</Value></String>
+						<String><Key>Recovery</Key><Value Protected="True">{{protect "1234 5678"}}</Value></String>
+						<AutoType><DefaultSequence>{USERNAME}{TAB}{PASSWORD}</DefaultSequence></AutoType>
+					</Entry>
+				</Group>
+			</Group>
+			<Group>
+				<UUID>cmVjeWNsZWQ=</UUID>
+				<Name>Recycle Bin</Name>
+				<Entry>
+					<String><Key>Title</Key><Value>Deleted</Value></String>
+				</Entry>
+			</Group>
+		</Group>
+	</Root>
+</KeePassFile>`
+
+var testEntries = []Entry{
+	{Title: "Mail", UserName: "jane", Password: "mail secret", URL: "https://mail.example.com", AutoTypeSequence: "{PASSWORD}{ENTER}", AutoTypeWindow: "Mail*"},
+	{Group: "Work/Dev", Title: "Git", Notes: "Deploy key", Password: "git secret", Fields: []Field{{Key: "Recovery", Value: "1234 5678"}}, AutoTypeSequence: "{USERNAME}{TAB}{PASSWORD}"},
+	{Group: "Recycle Bin", Recycled: true, Title: "Deleted"},
+}
+
+// testDatabase is a database to write, the files of KeePass and KeePassXC are written the same way
+type testDatabase struct {
+	major    uint16
+	cipherID string
+	kdf      map[string]interface{} // of KDBX 4
+	rounds   uint64                 // of KDBX 3
+	streamID uint32
+}
+
+// protectedXML returns the test XML with the values protected by the stream
+func protectedXML(t *testing.T, stream cipher.Stream) []byte {
+	var xml bytes.Buffer
+	tmpl := template.Must(template.New("xml").Funcs(template.FuncMap{
+		"protect": func(value string) string {
+			data := []byte(value)
+			if stream != nil {
+				stream.XORKeyStream(data, data)
+				return base64.StdEncoding.EncodeToString(data)
+			}
+			return value
+		},
+	}).Parse(testXML))
+	assert.NoError(t, tmpl.Execute(&xml, nil))
+	return xml.Bytes()
+}
+
+func (d testDatabase) write(t *testing.T, password string) []byte {
+	masterSeed, iv, streamKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16), bytes.Repeat([]byte{3}, 32)
+	if d.cipherID == cipherChaCha20 {
+		iv = iv[:12]
+	}
+	h := &header{major: d.major, masterSeed: masterSeed, kdf: d.kdf, transformSeed: bytes.Repeat([]byte{4}, 32), transformRounds: d.rounds}
+	passwordHash := sha256.Sum256([]byte(password))
+	compositeKey := sha256.Sum256(passwordHash[:])
+	transformedKey, err := transformKey(h, compositeKey[:])
+	assert.NoError(t, err)
+	masterKey := sha256.Sum256(append(append([]byte{}, masterSeed...), transformedKey...))
+
+	var file bytes.Buffer
+	field := func(id byte, value []byte) {
+		file.WriteByte(id)
+		if d.major == 3 {
+			binary.Write(&file, binary.LittleEndian, uint16(len(value)))
+		} else {
+			binary.Write(&file, binary.LittleEndian, uint32(len(value)))
+		}
+		file.Write(value)
+	}
+	binary.Write(&file, binary.LittleEndian, []uint32{signature1, signature2})
+	binary.Write(&file, binary.LittleEndian, []uint16{1, d.major})
+	cipherID, _ := hex.DecodeString(d.cipherID)
+	field(fieldCipherID, cipherID)
+	field(fieldCompression, uint32Bytes(1))
+	field(fieldMasterSeed, masterSeed)
+	field(fieldEncryptionIV, iv)
+
+	if d.major == 3 {
+		streamStart := bytes.Repeat([]byte{5}, 32)
+		field(fieldTransformSeed, h.transformSeed)
+		rounds := make([]byte, 8)
+		binary.LittleEndian.PutUint64(rounds, d.rounds)
+		field(fieldTransformRounds, rounds)
+		field(fieldStreamKey, streamKey)
+		field(fieldStreamStart, streamStart)
+		field(fieldStreamID, uint32Bytes(d.streamID))
+		field(fieldEnd, []byte("\r\n\r\n"))
+
+		// One hashed block and the final one
+		content := gzipped(t, protectedXML(t, newTestStream(t, d.streamID, streamKey)))
+		var blocks bytes.Buffer
+		sum := sha256.Sum256(content)
+		binary.Write(&blocks, binary.LittleEndian, uint32(0))
+		blocks.Write(sum[:])
+		binary.Write(&blocks, binary.LittleEndian, uint32(len(content)))
+		blocks.Write(content)
+		binary.Write(&blocks, binary.LittleEndian, uint32(1))
+		blocks.Write(make([]byte, 32))
+		binary.Write(&blocks, binary.LittleEndian, uint32(0))
+		file.Write(encryptPayload(t, d.cipherID, masterKey[:], iv, append(streamStart, blocks.Bytes()...)))
+		return file.Bytes()
+	}
+
+	field(fieldKDFParameters, variantDictionary(d.kdf))
+	field(fieldEnd, []byte("\r\n\r\n"))
+	headerData := append([]byte{}, file.Bytes()...)
+	hmacKey := sha512.Sum512(append(append(append([]byte{}, masterSeed...), transformedKey...), 1))
+	sum := sha256.Sum256(headerData)
+	file.Write(sum[:])
+	mac := blockHMAC(hmacKey[:], math.MaxUint64)
+	mac.Write(headerData)
+	file.Write(mac.Sum(nil))
+
+	var inner bytes.Buffer
+	for _, f := range []struct {
+		id    byte
+		value []byte
+	}{{innerFieldStreamID, uint32Bytes(d.streamID)}, {innerFieldStreamKey, streamKey}, {innerFieldEnd, nil}} {
+		inner.WriteByte(f.id)
+		binary.Write(&inner, binary.LittleEndian, uint32(len(f.value)))
+		inner.Write(f.value)
+	}
+	inner.Write(protectedXML(t, newTestStream(t, d.streamID, streamKey)))
+	encrypted := encryptPayload(t, d.cipherID, masterKey[:], iv, gzipped(t, inner.Bytes()))
+
+	// One HMAC block and the final one
+	for index, block := range [][]byte{encrypted, nil} {
+		size := uint32Bytes(uint32(len(block)))
+		indexData := make([]byte, 8)
+		binary.LittleEndian.PutUint64(indexData, uint64(index))
+		mac := blockHMAC(hmacKey[:], uint64(index))
+		mac.Write(indexData)
+		mac.Write(size)
+		mac.Write(block)
+		file.Write(mac.Sum(nil))
+		file.Write(size)
+		file.Write(block)
+	}
+	return file.Bytes()
+}
+
+// newTestStream returns the stream of protected values, nil when they're not protected
+func newTestStream(t *testing.T, id uint32, key []byte) cipher.Stream {
+	if id == streamNone {
+		return nil
+	}
+	stream, err := innerStream(id, key)
+	assert.NoError(t, err)
+	return stream
+}
+
+func encryptPayload(t *testing.T, cipherID string, key, iv, data []byte) []byte {
+	if cipherID == cipherChaCha20 {
+		stream, err := chacha20.NewUnauthenticatedCipher(key, iv)
+		assert.NoError(t, err)
+		encrypted := make([]byte, len(data))
+		stream.XORKeyStream(encrypted, data)
+		return encrypted
+	}
+	block, err := aes.NewCipher(key)
+	assert.NoError(t, err)
+	padding := aes.BlockSize - len(data)%aes.BlockSize
+	data = append(append([]byte{}, data...), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	encrypted := make([]byte, len(data))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, data)
+	return encrypted
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	_, err := w.Write(data)
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	return b.Bytes()
+}
+
+func uint32Bytes(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+	return b
+}
+
+func variantDictionary(values map[string]interface{}) []byte {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, uint16(0x0100))
+	for key, value := range values {
+		var kind byte
+		var data []byte
+		switch v := value.(type) {
+		case uint32:
+			kind, data = 0x04, uint32Bytes(v)
+		case uint64:
+			kind, data = 0x05, make([]byte, 8)
+			binary.LittleEndian.PutUint64(data, v)
+		case []byte:
+			kind, data = 0x42, v
+		}
+		b.WriteByte(kind)
+		binary.Write(&b, binary.LittleEndian, uint32(len(key)))
+		b.WriteString(key)
+		binary.Write(&b, binary.LittleEndian, uint32(len(data)))
+		b.Write(data)
+	}
+	b.WriteByte(0)
+	return b.Bytes()
+}
+
+func kdfUUID(uuid string) []byte {
+	b, _ := hex.DecodeString(uuid)
+	return b
+}
+
+func TestRead(t *testing.T) {
+	salt := bytes.Repeat([]byte{6}, 32)
+	tests := []struct {
+		name string
+		db   testDatabase
+	}{
+		{"KDBX 3.1 AES Salsa20", testDatabase{major: 3, cipherID: cipherAES, rounds: 1000, streamID: streamSalsa20}},
+		{"KDBX 4 Argon2d ChaCha20", testDatabase{major: 4, cipherID: cipherChaCha20, streamID: streamChaCha20, kdf: map[string]interface{}{
+			"$UUID": kdfUUID(kdfArgon2d), "S": salt, "I": uint64(2), "M": uint64(64 << 10), "P": uint32(2), "V": uint32(argon2Version),
+		}}},
+		{"KDBX 4 Argon2id AES", testDatabase{major: 4, cipherID: cipherAES, streamID: streamChaCha20, kdf: map[string]interface{}{
+			"$UUID": kdfUUID(kdfArgon2id), "S": salt, "I": uint64(2), "M": uint64(64 << 10), "P": uint32(1), "V": uint32(argon2Version),
+		}}},
+		{"KDBX 4 AES-KDF Salsa20", testDatabase{major: 4, cipherID: cipherAES, streamID: streamSalsa20, kdf: map[string]interface{}{
+			"$UUID": kdfUUID(kdfAES), "S": salt, "R": uint64(1000),
+		}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := tt.db.write(t, "correct horse")
+
+			entries, err := Read(file, "correct horse")
+			assert.NoError(t, err)
+			assert.Equal(t, testEntries, entries)
+
+			_, err = Read(file, "wrong horse")
+			assert.Equal(t, ErrCredentials, err)
+		})
+	}
+}
+
+func TestReadInvalid(t *testing.T) {
+	db := testDatabase{major: 4, cipherID: cipherAES, streamID: streamChaCha20, kdf: map[string]interface{}{
+		"$UUID": kdfUUID(kdfAES), "S": bytes.Repeat([]byte{6}, 32), "R": uint64(1000),
+	}}
+	file := db.write(t, "correct horse")
+
+	for _, kdf := range []map[string]interface{}{
+		{"$UUID": kdfUUID(kdfAES), "R": uint64(maxRounds + 1)},
+		{"$UUID": kdfUUID(kdfArgon2d), "I": uint64(2), "M": uint64(maxMemory + 1024), "P": uint32(1), "V": uint32(argon2Version)},
+		{"$UUID": kdfUUID(kdfArgon2id), "I": uint64(maxIterations + 1), "M": uint64(64 << 10), "P": uint32(1), "V": uint32(argon2Version)},
+	} {
+		_, err := transformKey(&header{major: 4, kdf: kdf}, make([]byte, 32))
+		assert.True(t, errors.Is(err, ErrUnsupported))
+	}
+
+	_, err := Read([]byte("not a database"), "correct horse")
+	assert.Equal(t, ErrInvalid, err)
+	_, err = Read(file[:len(file)-40], "correct horse")
+	assert.Error(t, err)
+}
+
+func TestReadXML(t *testing.T) {
+	entries, err := ReadXML(protectedXML(t, nil))
+	assert.NoError(t, err)
+	assert.Equal(t, testEntries, entries)
+
+	_, err = ReadXML([]byte(`<Database></Database>`))
+	assert.Equal(t, ErrInvalid, err)
+	_, err = ReadXML([]byte(`<KeePassFile><Root>`))
+	assert.Equal(t, ErrInvalid, err)
+}
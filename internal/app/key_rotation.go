@@ -0,0 +1,105 @@
+package app
+
+import (
+	"errors"
+	"os"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// minPassphraseLength is the length of the shortest server passphrase a rotation accepts
+const minPassphraseLength = 16
+
+var (
+	// ErrPassphraseWeak is returned for new passphrases which are too short or the current one
+	ErrPassphraseWeak = errors.New("new passphrase should be at least 16 characters and differ from the current one")
+	// ErrKeyRotationRunning is returned while the vaults still have values of the previous passphrase
+	ErrKeyRotationRunning = errors.New("previous key rotation isn't finished")
+	// ErrPassphraseFromEnv is returned when PW_SERVER_PASSPHRASE would bring the old passphrase back after a restart
+	ErrPassphraseFromEnv = errors.New("passphrase is set in PW_SERVER_PASSPHRASE, rotate it in the environment and restart")
+	// ErrServerKeySplit is returned when the passphrase is only in the key shares of the operators
+	ErrServerKeySplit = errors.New("server key is split, rotate it by splitting a new passphrase")
+)
+
+// RotateServerKey makes passphrase the server passphrase and queues the job which re-encrypts
+// the vaults with it. The current one is server.previousPassphrase until FinishKeyRotation,
+// both are written to the configuration file so a restart resumes the job.
+func RotateServerKey(s storage.Store, passphrase string) (*model.ReencryptionJob, error) {
+	current := viper.GetString("server.passphrase")
+	if len(passphrase) < minPassphraseLength || passphrase == current {
+		return nil, ErrPassphraseWeak
+	}
+	if os.Getenv("PW_SERVER_PASSPHRASE") != "" {
+		return nil, ErrPassphraseFromEnv
+	}
+	if viper.GetInt("server.keyThreshold") > 0 {
+		return nil, ErrServerKeySplit
+	}
+	if viper.GetString("server.previousPassphrase") != "" {
+		return nil, ErrKeyRotationRunning
+	}
+
+	viper.Set("server.previousPassphrase", current)
+	viper.Set("server.passphrase", passphrase)
+	if err := writeServerKeys(); err != nil {
+		viper.Set("server.previousPassphrase", "")
+		viper.Set("server.passphrase", current)
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"event": "server_key_rotated",
+	}).Warn("server passphrase is rotated")
+	return QueueReencryption(s, model.ReencryptKeyRotation, 0)
+}
+
+// FinishKeyRotation drops the previous passphrase once no job has to read its values.
+// It refuses while a job is unfinished or the last rotation failed.
+func FinishKeyRotation(s storage.Store) error {
+	if viper.GetString("server.previousPassphrase") == "" {
+		return nil
+	}
+	if jobs, err := s.Reencryption().FindUnfinished(); err != nil || len(jobs) > 0 {
+		if err != nil {
+			return err
+		}
+		return ErrKeyRotationRunning
+	}
+	jobs, err := s.Reencryption().FindRecent(reencryptionRecent)
+	if err != nil {
+		return err
+	}
+	for _, job := range jobs {
+		if job.Reason != model.ReencryptKeyRotation || job.UserID != 0 {
+			continue
+		}
+		if job.Status != model.ReencryptionDone {
+			return ErrKeyRotationRunning
+		}
+		break
+	}
+
+	viper.Set("server.previousPassphrase", "")
+	if err := writeServerKeys(); err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"event": "server_key_rotation_finished",
+	}).Info("previous server passphrase is dropped")
+	return nil
+}
+
+// writeServerKeys saves the passphrases to the configuration file, wrapped when a kms is
+// configured. Servers without one like the test server keep them in memory.
+func writeServerKeys() error {
+	if KMSEnabled() {
+		return WrapServerKey()
+	}
+	if viper.ConfigFileUsed() == "" {
+		return nil
+	}
+	return viper.WriteConfig()
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/shamir"
+)
+
+// keySharePrefix versions the encoding of server key shares
+const keySharePrefix = "pwks1-"
+
+var (
+	errKeyShare    = errors.New("key share is not valid")
+	errKeyRecovery = errors.New("key shares don't recover the server key, there are too few or they belong to different keys")
+)
+
+// SplitServerKey splits the server passphrase into n shares for the operators,
+// any k of them recover it. A checksum in the shares tells if a recovery worked.
+func SplitServerKey(passphrase string, n, k int) ([]string, error) {
+	sum := sha256.Sum256([]byte(passphrase))
+	secret := append([]byte(passphrase), sum[:4]...)
+
+	shares, err := shamir.Split(secret, n, k)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := make([]string, len(shares))
+	for i, share := range shares {
+		encoded[i] = keySharePrefix + base64.RawURLEncoding.EncodeToString(share)
+	}
+	return encoded, nil
+}
+
+// RecoverServerKey combines the key shares to the server passphrase
+func RecoverServerKey(encoded []string) (string, error) {
+	shares := make([][]byte, len(encoded))
+	for i, share := range encoded {
+		share = strings.TrimSpace(share)
+		if !strings.HasPrefix(share, keySharePrefix) {
+			return "", errKeyShare
+		}
+		decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(share, keySharePrefix))
+		if err != nil {
+			return "", errKeyShare
+		}
+		shares[i] = decoded
+	}
+
+	secret, err := shamir.Combine(shares)
+	if err != nil {
+		return "", err
+	}
+	if len(secret) < 5 {
+		return "", errKeyRecovery
+	}
+
+	passphrase, checksum := secret[:len(secret)-4], secret[len(secret)-4:]
+	sum := sha256.Sum256(passphrase)
+	if !bytes.Equal(sum[:4], checksum) {
+		return "", errKeyRecovery
+	}
+	return string(passphrase), nil
+}
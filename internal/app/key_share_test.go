@@ -0,0 +1,27 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerKeyShares(t *testing.T) {
+	shares, err := SplitServerKey("passphrase-for-encrypting-passwords", 5, 3)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 5)
+
+	passphrase, err := RecoverServerKey([]string{shares[4], " " + shares[1] + "\n", shares[2]})
+	assert.NoError(t, err)
+	assert.Equal(t, "passphrase-for-encrypting-passwords", passphrase)
+
+	_, err = RecoverServerKey(shares[:2])
+	assert.Equal(t, errKeyRecovery, err)
+
+	other, _ := SplitServerKey("another-server-passphrase-of-same-size", 3, 2)
+	_, err = RecoverServerKey([]string{shares[0], other[1]})
+	assert.Error(t, err)
+
+	_, err = RecoverServerKey([]string{"not-a-share", shares[1]})
+	assert.Equal(t, errKeyShare, err)
+}
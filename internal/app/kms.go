@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app/crypto"
+	"github.com/spf13/viper"
+)
+
+const kmsTimeout = 30 * time.Second
+
+var (
+	// ErrServerKeyNotWrapped is returned when kms.provider is set and kms.wrappedKey isn't
+	ErrServerKeyNotWrapped = errors.New("server passphrase isn't wrapped, run passwall-server key wrap")
+	// ErrKMSWithKeyShares is returned when the passphrase would be both split and wrapped
+	ErrKMSWithKeyShares = errors.New("server key can't be split and wrapped by a kms at the same time")
+)
+
+// KMSEnabled is true when kms.provider wraps the server passphrase
+func KMSEnabled() bool {
+	return viper.GetString("kms.provider") != ""
+}
+
+// keyProvider returns the key provider of the kms configuration
+func keyProvider() (crypto.KeyProvider, error) {
+	return crypto.New(crypto.Config{
+		Provider: viper.GetString("kms.provider"),
+		KeyID:    viper.GetString("kms.keyID"),
+		Region:   viper.GetString("kms.region"),
+		Endpoint: viper.GetString("kms.endpoint"),
+		Token:    viper.GetString("kms.token"),
+		Mount:    viper.GetString("kms.mount"),
+	})
+}
+
+// UnwrapServerKey asks the kms for the passphrases in kms.wrappedKey and kms.wrappedPreviousKey
+// and keeps them in memory as server.passphrase and server.previousPassphrase
+func UnwrapServerKey() error {
+	wrapped := viper.GetString("kms.wrappedKey")
+	if wrapped == "" {
+		return ErrServerKeyNotWrapped
+	}
+	provider, err := keyProvider()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kmsTimeout)
+	defer cancel()
+
+	passphrase, err := provider.Unwrap(ctx, wrapped)
+	if err != nil {
+		return err
+	}
+	previous := []byte{}
+	if wrappedPrevious := viper.GetString("kms.wrappedPreviousKey"); wrappedPrevious != "" {
+		if previous, err = provider.Unwrap(ctx, wrappedPrevious); err != nil {
+			return err
+		}
+	}
+
+	viper.Set("server.passphrase", string(passphrase))
+	viper.Set("server.previousPassphrase", string(previous))
+	return nil
+}
+
+// WrapServerKey wraps the passphrases in memory with the kms into kms.wrappedKey and
+// kms.wrappedPreviousKey, then writes the configuration file without their plaintext
+func WrapServerKey() error {
+	if viper.GetInt("server.keyThreshold") > 0 {
+		return ErrKMSWithKeyShares
+	}
+	provider, err := keyProvider()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kmsTimeout)
+	defer cancel()
+
+	wrapped, err := provider.Wrap(ctx, []byte(viper.GetString("server.passphrase")))
+	if err != nil {
+		return err
+	}
+	wrappedPrevious := ""
+	if previous := viper.GetString("server.previousPassphrase"); previous != "" {
+		if wrappedPrevious, err = provider.Wrap(ctx, []byte(previous)); err != nil {
+			return err
+		}
+	}
+
+	viper.Set("kms.wrappedKey", wrapped)
+	viper.Set("kms.wrappedPreviousKey", wrappedPrevious)
+	if viper.ConfigFileUsed() == "" {
+		return nil
+	}
+
+	// A copy of the settings is written, the server keeps reading the plaintext from viper
+	v := viper.New()
+	if err := v.MergeConfigMap(viper.AllSettings()); err != nil {
+		return err
+	}
+	v.Set("server.passphrase", "")
+	v.Set("server.previousPassphrase", "")
+	return v.WriteConfigAs(viper.ConfigFileUsed())
+}
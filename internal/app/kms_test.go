@@ -0,0 +1,63 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrapServerKey(t *testing.T) {
+	// The transit engine of this Vault only prefixes the plaintext
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in map[string]string
+		json.NewDecoder(r.Body).Decode(&in)
+		data := map[string]string{}
+		switch r.URL.Path {
+		case "/v1/transit/encrypt/passwall":
+			data["ciphertext"] = "vault:v1:" + in["plaintext"]
+		case "/v1/transit/decrypt/passwall":
+			data["plaintext"] = strings.TrimPrefix(in["ciphertext"], "vault:v1:")
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"data": data})
+	}))
+	defer vault.Close()
+
+	for key, value := range map[string]string{
+		"kms.provider": "vault",
+		"kms.keyID":    "passwall",
+		"kms.endpoint": vault.URL,
+		"kms.token":    "token",
+	} {
+		viper.Set(key, value)
+		defer viper.Set(key, "")
+	}
+	defer viper.Set("kms.wrappedKey", "")
+	defer viper.Set("kms.wrappedPreviousKey", "")
+	defer viper.Set("server.passphrase", viper.GetString("server.passphrase"))
+	defer viper.Set("server.previousPassphrase", "")
+
+	viper.Set("kms.wrappedKey", "")
+	assert.Equal(t, ErrServerKeyNotWrapped, UnwrapServerKey())
+
+	viper.Set("server.passphrase", "new-passphrase")
+	viper.Set("server.previousPassphrase", "old-passphrase")
+	require.NoError(t, WrapServerKey())
+	assert.True(t, strings.HasPrefix(viper.GetString("kms.wrappedKey"), "vault:v1:"))
+	assert.NotEmpty(t, viper.GetString("kms.wrappedPreviousKey"))
+
+	viper.Set("server.passphrase", "")
+	viper.Set("server.previousPassphrase", "")
+	require.NoError(t, UnwrapServerKey())
+	assert.Equal(t, "new-passphrase", viper.GetString("server.passphrase"))
+	assert.Equal(t, "old-passphrase", viper.GetString("server.previousPassphrase"))
+
+	viper.Set("server.keyThreshold", 3)
+	defer viper.Set("server.keyThreshold", 0)
+	assert.Equal(t, ErrKMSWithKeyShares, WrapServerKey())
+}
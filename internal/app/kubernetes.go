@@ -0,0 +1,129 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Labels and annotations of the rendered Kubernetes Secrets
+const (
+	K8sManagedByLabel   = "app.kubernetes.io/managed-by"
+	K8sManagedByValue   = "passwall"
+	K8sAccountLabel     = "passwall.io/machine-account"
+	K8sItemAnnotation   = "passwall.io/item"
+	K8sUpdateAnnotation = "passwall.io/updated-at"
+
+	k8sMaxNameLength = 253
+)
+
+var (
+	errK8sNamespace = errors.New("namespace should be a valid kubernetes namespace name")
+
+	k8sNameChars     = regexp.MustCompile(`[^a-z0-9]+`)
+	k8sNamespaceName = regexp.MustCompile(`^[a-z0-9]([-a-z0-9]{0,61}[a-z0-9])?$`)
+)
+
+// RenderK8sSecrets renders the items of the machine account as Kubernetes Secrets in the namespace.
+// The list always has all items, the listed ones and the ones in the folders of the account,
+// so an agent applies it and prunes the secrets with the account label which are not in
+// the list. The items of the list are returned with it, TripMachineCanaries trips them once
+// the list is sent.
+func RenderK8sSecrets(s storage.Store, account *model.MachineAccount, namespace string) (*model.K8sSecretListDTO, []interface{}, error) {
+	defer tracing.Start("app.RenderK8sSecrets").End()
+
+	if namespace != "" && !k8sNamespaceName.MatchString(namespace) {
+		return nil, nil, errK8sNamespace
+	}
+
+	user, err := s.Users().FindByID(account.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	version := sha256.New()
+	fmt.Fprintf(version, "%s|%s\n", namespace, account.Items)
+
+	items, err := machineItems(s, account, user.Schema)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	list := &model.K8sSecretListDTO{Secrets: []*model.K8sSecretDTO{}}
+	names := map[string]bool{}
+	for _, item := range items {
+		path := itemPathOf(item)
+		itemType, id := ItemTypeOf(item), uint(reflect.ValueOf(item).Elem().FieldByName("ID").Uint())
+		updatedAt := reflect.ValueOf(item).Elem().FieldByName("UpdatedAt").Interface().(time.Time)
+		fmt.Fprintf(version, "%s|%d\n", path, updatedAt.UnixNano())
+
+		name := k8sName(titleField(item).String(), itemType, id)
+		if names[name] {
+			name = k8sName(fmt.Sprintf("%s-%d", name, id), itemType, id)
+		}
+		names[name] = true
+
+		data := map[string][]byte{}
+		for field, value := range secretFields(item) {
+			data[field] = []byte(value)
+		}
+
+		list.Secrets = append(list.Secrets, &model.K8sSecretDTO{
+			APIVersion: "v1",
+			Kind:       "Secret",
+			Metadata: model.K8sObjectMetaDTO{
+				Name:      name,
+				Namespace: namespace,
+				Labels: map[string]string{
+					K8sManagedByLabel: K8sManagedByValue,
+					K8sAccountLabel:   account.UUID.String(),
+				},
+				Annotations: map[string]string{
+					K8sItemAnnotation:   path,
+					K8sUpdateAnnotation: updatedAt.UTC().Format(time.RFC3339),
+				},
+			},
+			Type: "Opaque",
+			Data: data,
+		})
+	}
+
+	list.Version = hex.EncodeToString(version.Sum(nil)[:16])
+	return list, items, nil
+}
+
+// MachineVaultRevision returns the value of the sync counter of the vault of the machine
+// account. It changes with every change of an item, so waiting requests render again then.
+func MachineVaultRevision(s storage.Store, account *model.MachineAccount) (int64, error) {
+	user, err := s.Users().FindByID(account.UserID)
+	if err != nil {
+		return 0, err
+	}
+	counter, err := s.SyncCounters().Find(user.Schema)
+	if err != nil {
+		return 0, err
+	}
+	return counter.Value, nil
+}
+
+// k8sName converts titles to DNS subdomain names like "prod-db",
+// items without a usable title are named like "passwall-logins-3"
+func k8sName(title, itemType string, id uint) string {
+	name := strings.Trim(k8sNameChars.ReplaceAllString(strings.ToLower(title), "-"), "-")
+	if name == "" {
+		name = fmt.Sprintf("passwall-%s-%d", itemType, id)
+	}
+	if len(name) > k8sMaxNameLength {
+		name = strings.TrimRight(name[:k8sMaxNameLength], "-")
+	}
+	return name
+}
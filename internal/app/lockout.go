@@ -0,0 +1,204 @@
+package app
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Defaults of the lockout of failed sign ins, the server configuration overrides them
+const (
+	signinMaxFailures   = 5
+	signinIPMaxFailures = 20
+	signinLockDuration  = "15m"
+	signinDelay         = "1s"
+)
+
+// signinsInFlight are the accounts with a sign in being checked. Another sign in of the
+// account waits for it, so parallel guesses can't pass before the failures are counted.
+var signinsInFlight = struct {
+	sync.Mutex
+	accounts map[string]bool
+}{accounts: map[string]bool{}}
+
+// SigninThrottle is returned when a sign in isn't checked
+type SigninThrottle struct {
+	Locked     bool          // the account is locked, otherwise it or the address has to wait
+	RetryAfter time.Duration // until the next sign in is checked
+}
+
+func (t *SigninThrottle) Error() string {
+	if t.Locked {
+		return "account is locked"
+	}
+	return "too many failed sign ins"
+}
+
+// SigninAttempt is a sign in which passed the lockout, it ends with Fail, Succeed or
+// Continue
+type SigninAttempt struct {
+	s       storage.Store
+	account string
+	address string
+}
+
+// StartSignin checks the lockout of the account and the address before the password is.
+// A typo is free, each further failure of an account doubles the wait before its next sign
+// in, starting at server.signinDelay. server.signinMaxFailures failures in a row lock the account and
+// server.signinIPMaxFailures ones lock the address for server.signinLockDuration.
+func StartSignin(s storage.Store, email, ip string, now time.Time) (*SigninAttempt, *SigninThrottle) {
+	attempt := &SigninAttempt{s: s, account: "email:" + strings.ToLower(strings.TrimSpace(email))}
+	if ip != "" {
+		attempt.address = "ip:" + ip
+	}
+	lock, delay := signinDurations()
+
+	if wait, locked := signinWait(s, attempt.account, now, lock, delay); wait > 0 {
+		return nil, &SigninThrottle{Locked: locked, RetryAfter: wait}
+	}
+	if attempt.address != "" {
+		if wait, _ := signinWait(s, attempt.address, now, lock, 0); wait > 0 {
+			return nil, &SigninThrottle{RetryAfter: wait}
+		}
+	}
+
+	signinsInFlight.Lock()
+	defer signinsInFlight.Unlock()
+	if signinsInFlight.accounts[attempt.account] {
+		return nil, &SigninThrottle{RetryAfter: time.Second}
+	}
+	signinsInFlight.accounts[attempt.account] = true
+	return attempt, nil
+}
+
+// Fail counts the failure for the account and the address
+func (a *SigninAttempt) Fail(now time.Time) {
+	defer a.end()
+	lock, _ := signinDurations()
+
+	if failure, locked := countSigninFailure(a.s, a.account, signinLimit("server.signinMaxFailures", signinMaxFailures), now, lock); locked {
+		log.WithFields(log.Fields{
+			"event":    "account_locked",
+			"account":  strings.TrimPrefix(a.account, "email:"),
+			"ip":       strings.TrimPrefix(a.address, "ip:"),
+			"failures": failure.Failures,
+		}).Warn("account is locked after failed sign ins")
+	}
+	if a.address == "" {
+		return
+	}
+	if failure, locked := countSigninFailure(a.s, a.address, signinLimit("server.signinIPMaxFailures", signinIPMaxFailures), now, lock); locked {
+		log.WithFields(log.Fields{
+			"event":    "address_locked",
+			"ip":       strings.TrimPrefix(a.address, "ip:"),
+			"failures": failure.Failures,
+		}).Warn("address is locked after failed sign ins")
+	}
+}
+
+// Succeed clears the failures of the account. The ones of the address stay, a guesser
+// with an account of its own can't clear them.
+func (a *SigninAttempt) Succeed() {
+	defer a.end()
+	if err := a.s.SigninFailures().DeleteByKey(a.account); err != nil {
+		log.WithError(err).Error("failed sign ins couldn't be cleared")
+	}
+}
+
+// Continue ends an attempt whose master password was right but which waits for the second
+// factor. The failures of the account stay until the second factor succeeds, so a stolen
+// password can't clear the failures of the codes.
+func (a *SigninAttempt) Continue() {
+	a.end()
+}
+
+func (a *SigninAttempt) end() {
+	signinsInFlight.Lock()
+	delete(signinsInFlight.accounts, a.account)
+	signinsInFlight.Unlock()
+}
+
+// PurgeSigninFailures deletes the failures which are too old to count and whose locks ended
+func PurgeSigninFailures(s storage.Store, now time.Time) (int, error) {
+	lock, _ := signinDurations()
+	return s.SigninFailures().DeleteBefore(now.Add(-lock))
+}
+
+// signinWait returns how long the key has to wait before its next sign in, locked is true
+// when it's locked. A zero delay only waits for locks.
+func signinWait(s storage.Store, key string, now time.Time, lock, delay time.Duration) (wait time.Duration, locked bool) {
+	failure, err := s.SigninFailures().FindByKey(key)
+	if err != nil {
+		return 0, false
+	}
+	if failure.LockedUntil != nil && now.Before(*failure.LockedUntil) {
+		return failure.LockedUntil.Sub(now), true
+	}
+	if delay <= 0 || failure.Failures < 2 || failure.LockedUntil != nil {
+		return 0, false
+	}
+
+	for i := 2; i < failure.Failures && delay < lock; i++ {
+		delay *= 2
+	}
+	if delay > lock {
+		delay = lock
+	}
+	if until := failure.LastFailureAt.Add(delay); now.Before(until) {
+		return until.Sub(now), false
+	}
+	return 0, false
+}
+
+// countSigninFailure adds a failure to the key and locks it at the limit, 0 never locks.
+// Failures older than the lock duration and ended locks start over.
+func countSigninFailure(s storage.Store, key string, limit int, now time.Time, lock time.Duration) (*model.SigninFailure, bool) {
+	failure, err := s.SigninFailures().FindByKey(key)
+	if err != nil {
+		failure = &model.SigninFailure{Key: key}
+	}
+	if now.Sub(failure.LastFailureAt) >= lock || (failure.LockedUntil != nil && !now.Before(*failure.LockedUntil)) {
+		failure.Failures, failure.LockedUntil = 0, nil
+	}
+
+	failure.Failures++
+	failure.LastFailureAt = now
+	locked := limit > 0 && failure.Failures >= limit
+	if locked {
+		until := now.Add(lock)
+		failure.LockedUntil = &until
+	}
+	if _, err := s.SigninFailures().Save(failure); err != nil {
+		log.WithError(err).Error("failed sign in couldn't be counted")
+	}
+	return failure, locked
+}
+
+// signinDurations returns the lock duration and the first delay of the configuration
+func signinDurations() (lock, delay time.Duration) {
+	lock, err := parsePeriod(viper.GetString("server.signinLockDuration"))
+	if err != nil {
+		lock, _ = parsePeriod(signinLockDuration)
+	}
+	period := viper.GetString("server.signinDelay")
+	if period == "" {
+		period = signinDelay
+	}
+	delay, err = time.ParseDuration(period)
+	if err != nil {
+		delay = 0
+	}
+	return lock, delay
+}
+
+func signinLimit(key string, fallback int) int {
+	if !viper.IsSet(key) {
+		return fallback
+	}
+	return viper.GetInt(key)
+}
@@ -0,0 +1,84 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSigninWait(t *testing.T) {
+	now := time.Now()
+	until := now.Add(10 * time.Minute)
+	ended := now.Add(-time.Minute)
+
+	mocks := storagetest.NewMocks()
+	mocks.SigninFailures.On("FindByKey", "email:one").Return(&model.SigninFailure{Failures: 1, LastFailureAt: now}, nil)
+	mocks.SigninFailures.On("FindByKey", "email:two").Return(&model.SigninFailure{Failures: 2, LastFailureAt: now}, nil)
+	mocks.SigninFailures.On("FindByKey", "email:four").Return(&model.SigninFailure{Failures: 4, LastFailureAt: now.Add(-time.Second)}, nil)
+	mocks.SigninFailures.On("FindByKey", "email:many").Return(&model.SigninFailure{Failures: 40, LastFailureAt: now}, nil)
+	mocks.SigninFailures.On("FindByKey", "email:locked").Return(&model.SigninFailure{Failures: 5, LastFailureAt: now, LockedUntil: &until}, nil)
+	mocks.SigninFailures.On("FindByKey", "email:unlocked").Return(&model.SigninFailure{Failures: 5, LastFailureAt: ended.Add(-15 * time.Minute), LockedUntil: &ended}, nil)
+	mocks.SigninFailures.On("FindByKey", mock.Anything).Return(nil, errors.New("record not found"))
+
+	// A typo doesn't wait, the wait doubles with each further failure up to the lock duration
+	wait, locked := signinWait(mocks.Store, "email:one", now, 15*time.Minute, time.Second)
+	assert.Zero(t, wait)
+	wait, locked = signinWait(mocks.Store, "email:two", now, 15*time.Minute, time.Second)
+	assert.Equal(t, time.Second, wait)
+	assert.False(t, locked)
+	wait, _ = signinWait(mocks.Store, "email:four", now, 15*time.Minute, time.Second)
+	assert.Equal(t, 3*time.Second, wait)
+	wait, _ = signinWait(mocks.Store, "email:many", now, 15*time.Minute, time.Second)
+	assert.Equal(t, 15*time.Minute, wait)
+
+	// Locked keys wait for the lock, ended locks and unknown keys don't wait
+	wait, locked = signinWait(mocks.Store, "email:locked", now, 15*time.Minute, time.Second)
+	assert.Equal(t, 10*time.Minute, wait)
+	assert.True(t, locked)
+	wait, locked = signinWait(mocks.Store, "email:unlocked", now, 15*time.Minute, time.Second)
+	assert.Zero(t, wait)
+	assert.False(t, locked)
+	wait, _ = signinWait(mocks.Store, "email:new", now, 15*time.Minute, time.Second)
+	assert.Zero(t, wait)
+
+	// Addresses only wait for locks
+	wait, _ = signinWait(mocks.Store, "email:two", now, 15*time.Minute, 0)
+	assert.Zero(t, wait)
+}
+
+func TestCountSigninFailure(t *testing.T) {
+	now := time.Now()
+	ended := now.Add(-time.Minute)
+
+	mocks := storagetest.NewMocks()
+	mocks.SigninFailures.On("FindByKey", "email:four").Return(&model.SigninFailure{Key: "email:four", Failures: 4, LastFailureAt: now.Add(-time.Minute)}, nil)
+	mocks.SigninFailures.On("FindByKey", "email:old").Return(&model.SigninFailure{Key: "email:old", Failures: 4, LastFailureAt: now.Add(-time.Hour)}, nil)
+	mocks.SigninFailures.On("FindByKey", "email:unlocked").Return(&model.SigninFailure{Key: "email:unlocked", Failures: 5, LastFailureAt: now.Add(-5 * time.Minute), LockedUntil: &ended}, nil)
+	mocks.SigninFailures.On("FindByKey", mock.Anything).Return(nil, errors.New("record not found"))
+	mocks.SigninFailures.On("Save", mock.Anything).Return(nil, nil)
+
+	// The limit locks the key for the lock duration
+	failure, locked := countSigninFailure(mocks.Store, "email:four", 5, now, 15*time.Minute)
+	assert.True(t, locked)
+	assert.Equal(t, 5, failure.Failures)
+	assert.Equal(t, now.Add(15*time.Minute), *failure.LockedUntil)
+
+	// Old failures and ended locks start over, new keys start counting
+	for _, key := range []string{"email:old", "email:unlocked", "email:new"} {
+		failure, locked = countSigninFailure(mocks.Store, key, 5, now, 15*time.Minute)
+		assert.False(t, locked, key)
+		assert.Equal(t, 1, failure.Failures, key)
+		assert.Nil(t, failure.LockedUntil, key)
+		assert.Equal(t, key, failure.Key)
+	}
+
+	// A limit of 0 never locks
+	_, locked = countSigninFailure(mocks.Store, "email:four", 0, now, 15*time.Minute)
+	assert.False(t, locked)
+	mocks.SigninFailures.AssertNumberOfCalls(t, "Save", 5)
+}
@@ -2,19 +2,25 @@ package app
 
 import (
 	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
 	"github.com/passwall/passwall-server/model"
 )
 
 // CreateLogin creates a login and saves it to the store
 func CreateLogin(s storage.Store, dto *model.LoginDTO, schema string) (*model.Login, error) {
+	defer tracing.Start("app.CreateLogin").End()
+
 	rawLogin := model.ToLogin(dto)
-	encLogin := EncryptModel(rawLogin)
 
-	createdLogin, err := s.Logins().Save(encLogin.(*model.Login), schema)
+	createdLogin, err := s.Logins().Save(rawLogin, schema)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := SetItemTags(s, createdLogin, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return createdLogin, nil
 }
 
@@ -22,9 +28,8 @@ func CreateLogin(s storage.Store, dto *model.LoginDTO, schema string) (*model.Lo
 func CreateLogins(s storage.Store, dtos []model.LoginDTO, schema string) error {
 	for i := range dtos {
 		rawLogin := model.ToLogin(&dtos[i])
-		encLogin := EncryptModel(rawLogin)
 
-		_, err := s.Logins().Save(encLogin.(*model.Login), schema)
+		_, err := s.Logins().Save(rawLogin, schema)
 		if err != nil {
 			return err
 		}
@@ -35,19 +40,63 @@ func CreateLogins(s storage.Store, dtos []model.LoginDTO, schema string) error {
 
 // UpdateLogin updates the login with the dto and applies the changes in the store
 func UpdateLogin(s storage.Store, login *model.Login, dto *model.LoginDTO, schema string) (*model.Login, error) {
+	defer tracing.Start("app.UpdateLogin").End()
+
+	if err := saveItemVersion(s, login, schema); err != nil {
+		return nil, err
+	}
+
+	// Keep the previous password if it is changed
+	if err := savePasswordHistory(s, login, dto.Password, schema); err != nil {
+		return nil, err
+	}
+
 	rawModel := model.ToLogin(dto)
-	encModel := EncryptModel(rawModel).(*model.Login)
 
-	login.Title = encModel.Title
-	login.URL = encModel.URL
-	login.Username = encModel.Username
-	login.Password = encModel.Password
-	login.Extra = encModel.Extra
+	login.Title = rawModel.Title
+	login.URL = rawModel.URL
+	login.Username = rawModel.Username
+	login.Password = rawModel.Password
+	login.Extra = rawModel.Extra
+	login.AutoTypeSequence = rawModel.AutoTypeSequence
+	login.AutoTypeWindow = rawModel.AutoTypeWindow
+	login.Pinned = rawModel.Pinned
+	login.IsFavorite = rawModel.IsFavorite
+	login.SortOrder = rawModel.SortOrder
+	login.FolderID = rawModel.FolderID
+	login.Reprompt = rawModel.Reprompt
+	login.Canary = rawModel.Canary
+	login.RotationProvider = rawModel.RotationProvider
+	login.RotationPeriod = rawModel.RotationPeriod
 
 	updatedLogin, err := s.Logins().Save(login, schema)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := SetItemTags(s, updatedLogin, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return updatedLogin, nil
 }
+
+// FindPasswordHistory returns the previous passwords of the login
+func FindPasswordHistory(s storage.Store, loginID uint, schema string) ([]model.PasswordHistory, error) {
+	return s.PasswordHistories().FindByLoginID(loginID, schema)
+}
+
+// savePasswordHistory stores the current password of the login
+// when it differs from the new password
+func savePasswordHistory(s storage.Store, login *model.Login, newPassword, schema string) error {
+	if login.Password == "" || login.Password == newPassword {
+		return nil
+	}
+
+	history := &model.PasswordHistory{
+		LoginID:  login.ID,
+		Password: login.Password,
+	}
+	_, err := s.PasswordHistories().Save(history, schema)
+	return err
+}
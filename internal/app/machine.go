@@ -0,0 +1,366 @@
+package app
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Formats of the injected secrets
+const (
+	InjectDotenv = "dotenv"
+	InjectJSON   = "json"
+
+	machineTokenType   = "machine"
+	machineSecretBytes = 32
+
+	defaultMachineTokenDuration = 15 * time.Minute
+)
+
+var (
+	// ErrMachineCredentials is returned for a wrong client id or secret
+	ErrMachineCredentials = errors.New("client id or secret is wrong")
+	// ErrItemNotAllowed is returned when a machine account reads an item out of its scope
+	ErrItemNotAllowed = errors.New("machine account is not allowed to read the item")
+
+	errItemPath     = errors.New("items should be like logins/3")
+	errMachineScope = errors.New("items should be like logins/3 or folders/7")
+	errInjectFormat = errors.New("format should be dotenv or json")
+
+	envNameChars = regexp.MustCompile(`[^A-Z0-9]+`)
+)
+
+// CreateMachineAccount creates a machine account of the user which can only read the items in dto.
+// The returned secret isn't stored, it can't be shown again.
+func CreateMachineAccount(s storage.Store, user *model.User, dto *model.MachineAccountDTO) (*model.MachineAccount, string, error) {
+	if err := checkMachineItems(s, dto.Items, user.Schema); err != nil {
+		return nil, "", err
+	}
+
+	secret, err := GenerateSecureKey(machineSecretBytes)
+	if err != nil {
+		return nil, "", err
+	}
+
+	account, err := s.MachineAccounts().Save(&model.MachineAccount{
+		UUID:   uuid.NewV4(),
+		UserID: user.ID,
+		Name:   dto.Name,
+		Secret: NewBcrypt([]byte(secret)),
+		Items:  strings.Join(dto.Items, ","),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	return account, secret, nil
+}
+
+// UpdateMachineAccount changes the name and the items of the machine account
+func UpdateMachineAccount(s storage.Store, account *model.MachineAccount, dto *model.MachineAccountDTO, schema string) (*model.MachineAccount, error) {
+	if err := checkMachineItems(s, dto.Items, schema); err != nil {
+		return nil, err
+	}
+
+	account.Name = dto.Name
+	account.Items = strings.Join(dto.Items, ",")
+	return s.MachineAccounts().Save(account)
+}
+
+// CreateMachineToken checks the credentials of the machine account and
+// returns a short-lived access token for the inject endpoint
+func CreateMachineToken(s storage.Store, clientID, secret string) (*model.MachineTokenDTO, error) {
+	account, err := s.MachineAccounts().FindByUUID(clientID)
+	if err != nil {
+		return nil, ErrMachineCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(account.Secret), []byte(secret)) != nil {
+		return nil, ErrMachineCredentials
+	}
+
+	duration := defaultMachineTokenDuration
+	if d := viper.GetString("server.machineTokenExpireDuration"); d != "" {
+		duration = resolveTokenExpireDuration(d)
+	}
+	claims := jwt.MapClaims{
+		"typ":                machineTokenType,
+		"machine_account_id": account.ID,
+		"exp":                time.Now().Add(duration).Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(viper.GetString("server.secret")))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	account.LastUsedAt = &now
+	if _, err := s.MachineAccounts().Save(account); err != nil {
+		return nil, err
+	}
+
+	return &model.MachineTokenDTO{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(duration.Seconds()),
+	}, nil
+}
+
+// FindMachineAccountByToken returns the machine account of a valid access token.
+// Access tokens of users are not accepted.
+func FindMachineAccountByToken(s storage.Store, tokenStr string) (*model.MachineAccount, error) {
+	token, err := verifyToken(tokenStr)
+	if err != nil {
+		return nil, ErrExpiredToken
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok || !token.Valid {
+		return nil, ErrUnauthorized
+	}
+
+	typ, _ := claims["typ"].(string)
+	id, ok := claims["machine_account_id"].(float64)
+	if subtle.ConstantTimeCompare([]byte(typ), []byte(machineTokenType)) != 1 || !ok {
+		return nil, ErrUnauthorized
+	}
+
+	// Deleted machine accounts can't use their tokens anymore
+	account, err := s.MachineAccounts().FindByID(uint(id))
+	if err != nil {
+		return nil, ErrUnauthorized
+	}
+	return account, nil
+}
+
+// IsMachineToken reports whether the claims are of an access token of a machine account
+func IsMachineToken(claims jwt.MapClaims) bool {
+	typ, _ := claims["typ"].(string)
+	return typ == machineTokenType
+}
+
+// InjectSecrets returns the decrypted items as environment variables or JSON, with the
+// items it read. Items are paths like "logins/3" which the machine account can read,
+// none are all items of the account.
+func InjectSecrets(s storage.Store, account *model.MachineAccount, items []string, format string) ([]byte, []interface{}, error) {
+	defer tracing.Start("app.InjectSecrets").End()
+
+	if format != InjectDotenv && format != InjectJSON {
+		return nil, nil, errInjectFormat
+	}
+
+	user, err := s.Users().FindByID(account.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var read []interface{}
+	if len(items) == 0 {
+		if read, err = machineItems(s, account, user.Schema); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, path := range items {
+		item, err := findMachineItem(s, account, path, user.Schema)
+		if err != nil {
+			return nil, nil, err
+		}
+		read = append(read, item)
+	}
+	TripMachineCanaries(s, account, read)
+
+	secrets := map[string]interface{}{}
+	var env bytes.Buffer
+	for _, item := range read {
+		if format == InjectJSON {
+			secrets[itemPathOf(item)] = ToItemDTO(item)
+			continue
+		}
+		writeDotenv(&env, envName(titleField(item).String()), item)
+	}
+
+	if format == InjectJSON {
+		b, err := json.MarshalIndent(secrets, "", "  ")
+		return b, read, err
+	}
+	return env.Bytes(), read, nil
+}
+
+// findMachineItem returns the item of the path if the machine account can read it, by its
+// path or its folder
+func findMachineItem(s storage.Store, account *model.MachineAccount, path, schema string) (interface{}, error) {
+	itemType, id, err := parseItemPath(path)
+	if err != nil {
+		if !account.CanRead(path, 0) {
+			return nil, ErrItemNotAllowed
+		}
+		return nil, err
+	}
+
+	item, err := FindItem(s, itemType, id, schema)
+	if err != nil {
+		if !account.CanRead(path, 0) {
+			return nil, ErrItemNotAllowed
+		}
+		return nil, err
+	}
+	if !account.CanRead(path, uint(reflect.ValueOf(item).Elem().FieldByName("FolderID").Uint())) {
+		return nil, ErrItemNotAllowed
+	}
+	return item, nil
+}
+
+// machineItems returns the items the machine account can read, the listed ones and the
+// ones in its folders now. Deleted items are left out.
+func machineItems(s storage.Store, account *model.MachineAccount, schema string) ([]interface{}, error) {
+	items := []interface{}{}
+	seen := map[string]bool{}
+	add := func(item interface{}) {
+		if path := itemPathOf(item); !seen[path] {
+			seen[path] = true
+			items = append(items, item)
+		}
+	}
+
+	for _, path := range account.ItemList() {
+		if folderID, ok := parseFolderScope(path); ok {
+			inFolder, err := findItemsOfAllTypes(s, map[string]string{"folder_id": strconv.FormatUint(uint64(folderID), 10)}, schema)
+			if err != nil {
+				return nil, err
+			}
+			for _, item := range inFolder {
+				add(item)
+			}
+			continue
+		}
+
+		itemType, id, err := parseItemPath(path)
+		if err != nil {
+			return nil, err
+		}
+		item, err := FindItem(s, itemType, id, schema)
+		if gorm.IsRecordNotFoundError(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		add(item)
+	}
+	return items, nil
+}
+
+// TripMachineCanaries trips the canaries in the items the machine account reads
+func TripMachineCanaries(s storage.Store, account *model.MachineAccount, items interface{}) {
+	TripCanaries(s, account.UserID, CanaryRead, machineSource(account), items)
+}
+
+// machineSource names the machine account in canary alerts
+func machineSource(account *model.MachineAccount) string {
+	return fmt.Sprintf("machine account %s (%s)", account.Name, account.UUID)
+}
+
+// checkMachineItems checks the item paths and folder scopes exist in the schema
+func checkMachineItems(s storage.Store, items []string, schema string) error {
+	for _, path := range items {
+		if folderID, ok := parseFolderScope(path); ok {
+			if _, err := s.Folders().FindByID(folderID, schema); err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			continue
+		}
+
+		itemType, id, err := parseItemPath(path)
+		if err != nil {
+			return errMachineScope
+		}
+		if _, err := FindItem(s, itemType, id, schema); err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
+
+// parseFolderScope returns the folder id of scopes like "folders/7"
+func parseFolderScope(path string) (uint, bool) {
+	if !strings.HasPrefix(path, model.MachineFolderScope+"/") {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(path, model.MachineFolderScope+"/"), 10, 64)
+	if err != nil || id == 0 {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseItemPath splits item paths like "logins/3" into the item type and id
+func parseItemPath(path string) (string, uint, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 || FindIndex(ItemTypes, parts[0]) < 0 {
+		return "", 0, errItemPath
+	}
+
+	id, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		return "", 0, errItemPath
+	}
+	return parts[0], uint(id), nil
+}
+
+// writeDotenv writes the secret fields of the decrypted item as PREFIX_FIELD="value" lines
+func writeDotenv(buf *bytes.Buffer, prefix string, item interface{}) {
+	fields := secretFields(item)
+
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(buf, "%s_%s=%s\n", prefix, envName(name), strconv.Quote(fields[name]))
+	}
+}
+
+// secretFields returns the url and the encrypted fields of the decrypted item
+// by their json names, empty fields are skipped
+func secretFields(item interface{}) map[string]string {
+	v := reflect.ValueOf(item).Elem()
+
+	fields := map[string]string{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		name := field.Tag.Get("json")
+		if field.Tag.Get("encrypt") != "true" && name != "url" {
+			continue
+		}
+		if v.Field(i).String() != "" {
+			fields[name] = v.Field(i).String()
+		}
+	}
+	return fields
+}
+
+// envName converts titles like "Prod DB" to environment variable names like PROD_DB
+func envName(title string) string {
+	name := strings.Trim(envNameChars.ReplaceAllString(strings.ToUpper(title), "_"), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "ITEM_" + name
+	}
+	return name
+}
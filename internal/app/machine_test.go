@@ -0,0 +1,37 @@
+package app
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteDotenv(t *testing.T) {
+	var buf bytes.Buffer
+	writeDotenv(&buf, envName("Prod DB (eu-1)"), &model.Login{
+		Title:    "Prod DB (eu-1)",
+		URL:      "postgres://db.example.com/app",
+		Username: "app",
+		Password: `p"ss`,
+	})
+	assert.Equal(t, `PROD_DB_EU_1_PASSWORD="p\"ss"
+PROD_DB_EU_1_URL="postgres://db.example.com/app"
+PROD_DB_EU_1_USERNAME="app"
+`, buf.String())
+
+	assert.Equal(t, "ITEM_1PASSWORD", envName("1Password"))
+}
+
+func TestParseItemPath(t *testing.T) {
+	itemType, id, err := parseItemPath("notes/5")
+	assert.NoError(t, err)
+	assert.Equal(t, NoteItem, itemType)
+	assert.Equal(t, uint(5), id)
+
+	for _, path := range []string{"notes", "folders/1", "notes/x", "notes/1/2"} {
+		_, _, err := parseItemPath(path)
+		assert.Equal(t, errItemPath, err, path)
+	}
+}
@@ -1,44 +1,290 @@
 package app
 
 import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
 )
 
-// MigrateSystemTables runs auto migration for the system models (Token and User),
-// will only add missing fields won't delete/change current data in the store.
-func MigrateSystemTables(s storage.Store) {
-	if err := s.Tokens().Migrate(); err != nil {
-		log.Error(err)
+// SystemSchema keeps the system tables and their schema_migrations
+const SystemSchema = "public"
+
+// ErrIrreversible is returned when a migration which has no Down would be reverted
+var ErrIrreversible = errors.New("migration can't be reverted")
+
+// Migration is a versioned change of a schema. The versions applied to a schema are kept
+// in its schema_migrations table. Up and Down run in a transaction with the record of the
+// version, Down reverts Up and is nil for migrations which can't be reverted.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(s storage.Store, schema string) error
+	Down    func(s storage.Store, schema string) error
+}
+
+// ID is the version and name of the migration, like 1_baseline
+func (m Migration) ID() string {
+	return strconv.Itoa(m.Version) + "_" + m.Name
+}
+
+// SystemMigrations change the system tables. New columns and tables come with a
+// migration of their own, readiness reports the columns of the models which are missing.
+var SystemMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up:      ddl(createTables(systemBaseline...), upgradeTables(systemBaseline...), systemBaselineIndexes),
+		Down:    ddl(dropTables(systemBaseline...)),
+	},
+	{
+		Version: 2,
+		Name:    "attachment_limits",
+		Up:      ddl(addColumns([]string{"policies"}, "attachment_max_size bigint", "attachment_quota bigint")),
+		Down:    ddl(dropColumns([]string{"policies"}, "attachment_quota", "attachment_max_size")),
+	},
+}
+
+// UserMigrations change the tables of every user schema and its decoy schema
+var UserMigrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up:      ddl(createTables(userBaseline...), upgradeTables(userBaseline...)),
+		Down:    ddl(dropTables(userBaseline...)),
+	},
+	{
+		Version: 2,
+		Name:    "item_versions",
+		Up:      ddl(createTables(itemVersions)),
+		Down:    ddl(dropTables(itemVersions)),
+	},
+	{
+		Version: 3,
+		Name:    "folders",
+		Up:      ddl(createTables(folders), addColumns(itemTables, "folder_id integer")),
+		Down:    ddl(dropColumns(itemTables, "folder_id"), dropTables(folders)),
+	},
+	{
+		Version: 4,
+		Name:    "tags",
+		Up:      ddl(createTables(tags, itemTags)),
+		Down:    ddl(dropTables(tags, itemTags)),
+	},
+	{
+		Version: 5,
+		Name:    "favorites",
+		Up:      ddl(addColumns(itemTables, "is_favorite boolean")),
+		Down:    ddl(dropColumns(itemTables, "is_favorite")),
+	},
+	{
+		Version: 6,
+		Name:    "blind_indexes",
+		Up: ddl([]string{
+			"ALTER TABLE {schema}.logins ADD COLUMN IF NOT EXISTS username_index text",
+			"ALTER TABLE {schema}.emails ADD COLUMN IF NOT EXISTS email_index text",
+			"ALTER TABLE {schema}.notes ADD COLUMN IF NOT EXISTS note_index text",
+			"ALTER TABLE {schema}.servers ADD COLUMN IF NOT EXISTS ip_index text",
+		}),
+		Down: ddl([]string{
+			"ALTER TABLE {schema}.servers DROP COLUMN IF EXISTS ip_index",
+			"ALTER TABLE {schema}.notes DROP COLUMN IF EXISTS note_index",
+			"ALTER TABLE {schema}.emails DROP COLUMN IF EXISTS email_index",
+			"ALTER TABLE {schema}.logins DROP COLUMN IF EXISTS username_index",
+		}),
+	},
+	{
+		Version: 7,
+		Name:    "revisions",
+		Up:      ddl(addColumns(itemTables, "revision integer NOT NULL DEFAULT 0")),
+		Down:    ddl(dropColumns(itemTables, "revision")),
+	},
+	{
+		Version: 8,
+		Name:    "sync_revisions",
+		Up:      ddl(createTables(syncCounters), addColumns(itemTables, "sync_revision bigint NOT NULL DEFAULT 0")),
+		Down:    ddl(dropColumns(itemTables, "sync_revision"), dropTables(syncCounters)),
+	},
+	{
+		Version: 9,
+		Name:    "attachments",
+		Up:      ddl(createTables(attachments)),
+		Down:    ddl(dropTables(attachments)),
+	},
+}
+
+// migrationsOf returns the migrations of the system schema or of a user schema
+func migrationsOf(schema string) []Migration {
+	if schema == SystemSchema {
+		return SystemMigrations
 	}
-	if err := s.Users().Migrate(); err != nil {
-		log.Error(err)
+	return UserMigrations
+}
+
+// appliedMigrations returns the versions applied to the schema
+func appliedMigrations(s storage.Store, schema string) (map[int]bool, error) {
+	migrations, err := s.SchemaMigrations().FindAll(schema)
+	if err != nil {
+		return nil, err
 	}
-	if err := s.Subscriptions().Migrate(); err != nil {
-		log.Error(err)
+	applied := map[int]bool{}
+	for _, m := range migrations {
+		applied[m.Version] = true
 	}
+	return applied, nil
 }
 
-// MigrateUserTables runs auto migration for user models in user schema,
-// will only add missing fields won't delete/change current data in the store.
-func MigrateUserTables(s storage.Store, schema string) {
-	if err := s.Logins().Migrate(schema); err != nil {
-		log.Error(err)
+// MigrateUp applies the migrations the schema hasn't had yet in the order of their versions
+func MigrateUp(s storage.Store, schema string) error {
+	if err := s.SchemaMigrations().Migrate(schema); err != nil {
+		return err
 	}
-	if err := s.CreditCards().Migrate(schema); err != nil {
-		log.Error(err)
+	applied, err := appliedMigrations(s, schema)
+	if err != nil {
+		return err
 	}
-	if err := s.BankAccounts().Migrate(schema); err != nil {
-		log.Error(err)
+	for _, m := range migrationsOf(schema) {
+		if applied[m.Version] {
+			continue
+		}
+		err := s.Transaction(func(tx storage.Store) error {
+			if err := m.Up(tx, schema); err != nil {
+				return fmt.Errorf("migration %s of %s: %w", m.ID(), schema, err)
+			}
+			return tx.SchemaMigrations().Create(&model.SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}, schema)
+		})
+		if err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"event":     "migration_applied",
+			"schema":    schema,
+			"migration": m.ID(),
+		}).Info("migration is applied")
 	}
-	if err := s.Notes().Migrate(schema); err != nil {
-		log.Error(err)
+	return nil
+}
+
+// MigrateDown reverts the migrations of the schema after the version, the newest first
+func MigrateDown(s storage.Store, schema string, version int) error {
+	if err := s.SchemaMigrations().Migrate(schema); err != nil {
+		return err
 	}
-	if err := s.Emails().Migrate(schema); err != nil {
-		log.Error(err)
+	applied, err := appliedMigrations(s, schema)
+	if err != nil {
+		return err
+	}
+	migrations := migrationsOf(schema)
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if m.Version <= version || !applied[m.Version] {
+			continue
+		}
+		if m.Down == nil {
+			return fmt.Errorf("%s of %s: %w", m.ID(), schema, ErrIrreversible)
+		}
+		err := s.Transaction(func(tx storage.Store) error {
+			if err := m.Down(tx, schema); err != nil {
+				return fmt.Errorf("migration %s of %s: %w", m.ID(), schema, err)
+			}
+			return tx.SchemaMigrations().Delete(m.Version, schema)
+		})
+		if err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"event":     "migration_reverted",
+			"schema":    schema,
+			"migration": m.ID(),
+		}).Warn("migration is reverted")
 	}
-	if err := s.Servers().Migrate(schema); err != nil {
+	return nil
+}
+
+// MigrationStatus returns the applied version and the pending migrations of the system
+// schema and of every user schema
+func MigrationStatus(s storage.Store) ([]model.MigrationStatusDTO, error) {
+	schemas, err := userSchemas(s)
+	if err != nil {
+		return nil, err
+	}
+	schemas = append([]string{SystemSchema}, schemas...)
+
+	statuses := make([]model.MigrationStatusDTO, 0, len(schemas))
+	for _, schema := range schemas {
+		statuses = append(statuses, schemaMigrationStatus(s, schema))
+	}
+	return statuses, nil
+}
+
+// schemaMigrationStatus returns the status of the schema, all migrations are pending
+// before its schema_migrations table exists. It doesn't write, read-only servers report it too.
+func schemaMigrationStatus(s storage.Store, schema string) model.MigrationStatusDTO {
+	applied, _ := appliedMigrations(s, schema)
+	status := model.MigrationStatusDTO{Schema: schema, Pending: []string{}}
+	for _, m := range migrationsOf(schema) {
+		status.Latest = m.Version
+		if applied[m.Version] {
+			status.Version = m.Version
+		} else {
+			status.Pending = append(status.Pending, m.ID())
+		}
+	}
+	return status
+}
+
+// MigrateSystemTables applies the migrations of the system tables, it only adds tables
+// and columns and doesn't delete or change the data in the store.
+func MigrateSystemTables(s storage.Store) {
+	if err := MigrateUp(s, SystemSchema); err != nil {
+		log.WithError(err).Error("system tables couldn't be migrated")
+	}
+}
+
+// MigrateUserTables applies the migrations of the user tables in the user schema,
+// it only adds tables and columns and doesn't delete or change the data in the store.
+func MigrateUserTables(s storage.Store, schema string) {
+	if err := MigrateUp(s, schema); err != nil {
+		log.WithError(err).WithField("schema", schema).Error("user tables couldn't be migrated")
+	}
+}
+
+// MigrateAllUserTables runs MigrateUserTables for the schema and the decoy schema of
+// every user, so tables added in new versions are created for existing users too.
+func MigrateAllUserTables(s storage.Store) {
+	schemas, err := userSchemas(s)
+	if err != nil {
 		log.Error(err)
+		return
+	}
+
+	for _, schema := range schemas {
+		MigrateUserTables(s, schema)
+	}
+}
+
+// userSchemas returns the schemas and decoy schemas of the users in alphabetical order
+func userSchemas(s storage.Store) ([]string, error) {
+	users, err := s.Users().All()
+	if err != nil {
+		return nil, err
+	}
+
+	var schemas []string
+	for i := range users {
+		if users[i].Schema != "" {
+			schemas = append(schemas, users[i].Schema)
+		}
+		if users[i].Schema != "" && users[i].DuressPassword != "" {
+			schemas = append(schemas, DecoySchema(users[i].Schema))
+		}
 	}
+	sort.Strings(schemas)
+	return schemas, nil
 }
@@ -0,0 +1,486 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/storage"
+)
+
+// The DDL of the migrations is written for PostgreSQL and pinned to the version which
+// introduced it, later changes of the models come with a migration of their own.
+// "{schema}" is replaced by the schema which is migrated, system tables without it are
+// in the default schema.
+
+// table is the definition of a table at the version of a migration
+type table struct {
+	name    string
+	columns []string
+}
+
+// itemTables are the tables of the six item types of a vault
+var itemTables = []string{
+	"{schema}.logins",
+	"{schema}.credit_cards",
+	"{schema}.bank_accounts",
+	"{schema}.notes",
+	"{schema}.emails",
+	"{schema}.servers",
+}
+
+// systemBaseline are the system tables of the versions before the migrations
+var systemBaseline = []table{
+	{"tokens", []string{
+		"id serial PRIMARY KEY",
+		"user_id integer",
+		"uuid varchar(100)",
+		"token text",
+		"transmission_key text",
+		"expiry_time timestamp with time zone",
+		"last_used_at timestamp with time zone",
+		"family varchar(100)",
+		"refresh boolean",
+		"rotated_at timestamp with time zone",
+		"ip varchar(45)",
+		"user_agent text",
+		"started_at timestamp with time zone",
+		"duress boolean",
+	}},
+	{"users", []string{
+		"id serial PRIMARY KEY",
+		"uuid varchar(100)",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"name text",
+		"email text",
+		"master_password text",
+		"duress_password text",
+		"secret text",
+		"schema text",
+		"role text",
+		"confirmation_code text",
+		"email_verified_at timestamp with time zone",
+		"locale text",
+		"two_factor_enabled boolean",
+		"totp_secret text",
+		"totp_last_step bigint",
+		"kdf_type text",
+		"kdf_iterations integer",
+		"kdf_memory integer",
+		"kdf_parallelism integer",
+	}},
+	{"subscriptions", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"cancelled_at timestamp with time zone",
+		"subscription_id integer",
+		"plan_id integer",
+		"user_id integer",
+		"email text",
+		"status text",
+		"next_bill_date timestamp with time zone",
+		"update_url text",
+		"cancel_url text",
+	}},
+	{"machine_accounts", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"uuid varchar(100)",
+		"user_id integer",
+		"name text",
+		"secret text",
+		"items text",
+		"last_used_at timestamp with time zone",
+	}},
+	{"policies", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"user_id integer",
+		"session_idle_timeout text",
+		"session_absolute_timeout text",
+		"blocked_countries text",
+		"office_c_id_rs text",
+		"require_two_factor_outside_office boolean",
+		"access_hours text",
+		"access_days text",
+		"time_zone text",
+		"require_security_key boolean",
+		"security_key_grace_period text",
+		"security_key_required_at timestamp with time zone",
+		"security_key_exempt_until timestamp with time zone",
+		"security_key_exempt_reason text",
+		"block_disposable_emails boolean",
+		"trash_retention text",
+		"audit_retention text",
+		"tombstone_retention text",
+		"session_retention text",
+	}},
+	{"sso_identities", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"user_id integer",
+		"provider text",
+		"subject text",
+	}},
+	{"personal_access_tokens", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"user_id integer",
+		"name text",
+		"prefix text",
+		"hash text",
+		"transmission_key text",
+		"expires_at timestamp with time zone",
+		"last_used_at timestamp with time zone",
+	}},
+	{"trusted_devices", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"user_id integer",
+		"name text",
+		"fingerprint text",
+		"hash text",
+		"security_key boolean",
+		"duress boolean",
+		"expires_at timestamp with time zone",
+		"last_used_at timestamp with time zone",
+	}},
+	{"signin_failures", []string{
+		"id serial PRIMARY KEY",
+		"key varchar(320)",
+		"failures integer",
+		"last_failure_at timestamp with time zone",
+		"locked_until timestamp with time zone",
+	}},
+	{"audit_logs", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"level text",
+		"event text",
+		"message text",
+		"fields text",
+		"prev_hash text",
+		"hash text",
+	}},
+	{"audit_checkpoints", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"audit_log_id integer",
+		"hash text",
+		"anchor boolean",
+		"signature text",
+	}},
+	{"export_jobs", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"uuid varchar(100)",
+		"user_id integer",
+		"schema text",
+		"status text",
+		"progress integer",
+		"items integer",
+		"error text",
+		"blob_key text",
+		"expires_at timestamp with time zone",
+	}},
+	{"reencryption_jobs", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"reason text",
+		"user_id integer",
+		"status text",
+		"schema text",
+		`"table" text`,
+		"last_id integer",
+		"total integer",
+		"done integer",
+		"reencrypted integer",
+		"error text",
+		"started_at timestamp with time zone",
+		"finished_at timestamp with time zone",
+	}},
+	equivalentDomains,
+}
+
+// systemBaselineIndexes are the indexes of the system baseline
+var systemBaselineIndexes = []string{
+	"CREATE INDEX IF NOT EXISTS idx_export_jobs_user_id ON export_jobs (user_id)",
+	"CREATE INDEX IF NOT EXISTS idx_personal_access_tokens_user_id ON personal_access_tokens (user_id)",
+	"CREATE INDEX IF NOT EXISTS idx_reencryption_jobs_status ON reencryption_jobs (status)",
+	"CREATE INDEX IF NOT EXISTS idx_reencryption_jobs_user_id ON reencryption_jobs (user_id)",
+	"CREATE INDEX IF NOT EXISTS idx_signin_failures_last_failure_at ON signin_failures (last_failure_at)",
+	"CREATE INDEX IF NOT EXISTS idx_sso_identities_user_id ON sso_identities (user_id)",
+	"CREATE INDEX IF NOT EXISTS idx_trusted_devices_user_id ON trusted_devices (user_id)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS uix_export_jobs_uuid ON export_jobs (uuid)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS uix_personal_access_tokens_hash ON personal_access_tokens (hash)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS uix_policies_user_id ON policies (user_id)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS uix_signin_failures_key ON signin_failures (key)",
+	"CREATE UNIQUE INDEX IF NOT EXISTS uix_trusted_devices_hash ON trusted_devices (hash)",
+}
+
+// equivalentDomains is in the system schema and in every user schema
+var equivalentDomains = table{"{schema}.equivalent_domains", []string{
+	"id serial PRIMARY KEY",
+	"created_at timestamp with time zone",
+	"updated_at timestamp with time zone",
+	"deleted_at timestamp with time zone",
+	"domains text",
+}}
+
+// userBaseline are the tables of a user schema of the versions before the migrations
+var userBaseline = []table{
+	{"{schema}.logins", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"title text",
+		"url text",
+		"username text",
+		"password text",
+		"extra text",
+		"auto_type_sequence text",
+		"auto_type_window text",
+		"pinned boolean",
+		"sort_order integer",
+		"reprompt boolean",
+		"canary boolean",
+		"rotation_provider text",
+		"rotation_period text",
+		"rotated_at timestamp with time zone",
+	}},
+	{"{schema}.password_histories", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"login_id integer",
+		"password text",
+	}},
+	{"{schema}.credit_cards", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"card_name text",
+		"cardholder_name text",
+		"type text",
+		"number text",
+		"verification_number text",
+		"expiry_date text",
+		"brand text",
+		"pinned boolean",
+		"sort_order integer",
+		"reprompt boolean",
+		"canary boolean",
+	}},
+	{"{schema}.bank_accounts", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"bank_name text",
+		"bank_code text",
+		"account_name text",
+		"account_number text",
+		"iban text",
+		"currency text",
+		"password text",
+		"pinned boolean",
+		"sort_order integer",
+		"reprompt boolean",
+		"canary boolean",
+	}},
+	{"{schema}.notes", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"title text",
+		"note text",
+		"pinned boolean",
+		"sort_order integer",
+		"reprompt boolean",
+		"canary boolean",
+	}},
+	{"{schema}.emails", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"title text",
+		"email text",
+		"password text",
+		"pinned boolean",
+		"sort_order integer",
+		"reprompt boolean",
+		"canary boolean",
+	}},
+	{"{schema}.servers", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"title text",
+		"ip text",
+		"username text",
+		"password text",
+		"url text",
+		"hosting_username text",
+		"hosting_password text",
+		"admin_username text",
+		"admin_password text",
+		"extra text",
+		"pinned boolean",
+		"sort_order integer",
+		"reprompt boolean",
+		"canary boolean",
+	}},
+	equivalentDomains,
+	{"{schema}.webauthn_credentials", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"name text",
+		"credential_id text",
+		"public_key text",
+		"aa_guid text",
+		"sign_count bigint",
+		"last_used_at timestamp with time zone",
+	}},
+	{"{schema}.audit_logs", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"actor text",
+		"action text",
+		"item_type text",
+		"item_id integer",
+		"ip text",
+		"user_agent text",
+		"result text",
+	}},
+}
+
+var (
+	itemVersions = table{"{schema}.item_versions", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"item_type text",
+		"item_id integer",
+		"data text",
+	}}
+	folders = table{"{schema}.folders", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"name text",
+	}}
+	tags = table{"{schema}.tags", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"updated_at timestamp with time zone",
+		"deleted_at timestamp with time zone",
+		"name text",
+	}}
+	itemTags = table{"{schema}.item_tags", []string{
+		"item_type text",
+		"item_id integer",
+		"tag_id integer",
+		"PRIMARY KEY (item_type, item_id, tag_id)",
+	}}
+	syncCounters = table{"{schema}.sync_counters", []string{
+		"id serial PRIMARY KEY",
+		"epoch text",
+		"value bigint NOT NULL DEFAULT 0",
+		"purged bigint NOT NULL DEFAULT 0",
+	}}
+	attachments = table{"{schema}.attachments", []string{
+		"id serial PRIMARY KEY",
+		"created_at timestamp with time zone",
+		"item_type text",
+		"item_id integer",
+		"name text",
+		"content_type text",
+		"size bigint",
+		"sha256 text",
+		"blob_key text",
+		"key text",
+	}}
+)
+
+// ddl returns a step of a migration which runs the statements in the schema
+func ddl(parts ...[]string) func(s storage.Store, schema string) error {
+	return func(s storage.Store, schema string) error {
+		for _, part := range parts {
+			for _, statement := range part {
+				if err := s.SchemaMigrations().Exec(strings.ReplaceAll(statement, "{schema}", schema)); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+}
+
+// createTables returns the statements which create the tables
+func createTables(tables ...table) []string {
+	statements := make([]string, 0, len(tables))
+	for _, t := range tables {
+		statements = append(statements, "CREATE TABLE IF NOT EXISTS "+t.name+" ("+strings.Join(t.columns, ", ")+")")
+	}
+	return statements
+}
+
+// upgradeTables returns the statements which add the missing columns to the tables,
+// the baselines use them for tables which versions before the migrations created
+func upgradeTables(tables ...table) []string {
+	var statements []string
+	for _, t := range tables {
+		for _, column := range t.columns {
+			if strings.Contains(column, "PRIMARY KEY") {
+				continue
+			}
+			statements = append(statements, "ALTER TABLE "+t.name+" ADD COLUMN IF NOT EXISTS "+column)
+		}
+	}
+	return statements
+}
+
+// dropTables returns the statements which drop the tables in reverse order
+func dropTables(tables ...table) []string {
+	statements := make([]string, 0, len(tables))
+	for i := len(tables) - 1; i >= 0; i-- {
+		statements = append(statements, "DROP TABLE IF EXISTS "+tables[i].name)
+	}
+	return statements
+}
+
+// addColumns returns the statements which add the columns to each of the tables
+func addColumns(tables []string, columns ...string) []string {
+	var statements []string
+	for _, name := range tables {
+		for _, column := range columns {
+			statements = append(statements, "ALTER TABLE "+name+" ADD COLUMN IF NOT EXISTS "+column)
+		}
+	}
+	return statements
+}
+
+// dropColumns returns the statements which drop the columns from each of the tables
+func dropColumns(tables []string, columns ...string) []string {
+	var statements []string
+	for _, name := range tables {
+		for _, column := range columns {
+			statements = append(statements, "ALTER TABLE "+name+" DROP COLUMN IF EXISTS "+column)
+		}
+	}
+	return statements
+}
@@ -0,0 +1,127 @@
+package app
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrations(t *testing.T) {
+	s, err := storage.NewMemory()
+	require.NoError(t, err)
+	defer s.Close()
+	MigrateSystemTables(s)
+	user, err := SetupUser(s, &model.UserDTO{Name: "Test", Email: "test@passwall.io", MasterPassword: "master-password"})
+	require.NoError(t, err)
+
+	system, latest := SystemMigrations[len(SystemMigrations)-1].Version, UserMigrations[len(UserMigrations)-1].Version
+	statuses, err := MigrationStatus(s)
+	require.NoError(t, err)
+	assert.Equal(t, []model.MigrationStatusDTO{
+		{Schema: SystemSchema, Version: system, Latest: system, Pending: []string{}},
+		{Schema: user.Schema, Version: latest, Latest: latest, Pending: []string{}},
+	}, statuses)
+
+	// A new version of the server adds a reversible migration
+	defer func(migrations []Migration) { UserMigrations = migrations }(UserMigrations)
+	var ups, downs int
+	UserMigrations = append(UserMigrations, Migration{
+		Version: latest + 1,
+		Name:    "add_tags",
+		Up:      func(storage.Store, string) error { ups++; return nil },
+		Down:    func(storage.Store, string) error { downs++; return nil },
+	})
+	MigrateAllUserTables(s)
+	MigrateAllUserTables(s)
+	assert.Equal(t, 1, ups)
+	statuses, _ = MigrationStatus(s)
+	assert.Equal(t, latest+1, statuses[1].Version)
+
+	require.NoError(t, MigrateDown(s, user.Schema, latest))
+	assert.Equal(t, 1, downs)
+	statuses, _ = MigrationStatus(s)
+	assert.Equal(t, []string{UserMigrations[len(UserMigrations)-1].ID()}, statuses[1].Pending)
+
+	// Migrations without a Down can't be reverted
+	UserMigrations[len(UserMigrations)-1].Down = nil
+	require.NoError(t, MigrateUp(s, user.Schema))
+	err = MigrateDown(s, user.Schema, 0)
+	assert.True(t, errors.Is(err, ErrIrreversible))
+	assert.Equal(t, 1, downs)
+	statuses, _ = MigrationStatus(s)
+	assert.Equal(t, latest+1, statuses[1].Version)
+	require.NoError(t, s.SchemaMigrations().Delete(latest+1, user.Schema))
+
+	// Failed migrations aren't recorded and run again
+	UserMigrations[len(UserMigrations)-1].Up = func(storage.Store, string) error { return errors.New("disk full") }
+	assert.EqualError(t, MigrateUp(s, user.Schema), "migration "+UserMigrations[len(UserMigrations)-1].ID()+" of "+user.Schema+": disk full")
+	statuses, _ = MigrationStatus(s)
+	assert.Equal(t, latest, statuses[1].Version)
+}
+
+func TestMigrationsCreateModelColumns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passwall-migrations")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	db, err := storage.DBConn(&config.DatabaseConfiguration{Driver: "sqlite", Path: filepath.Join(dir, "passwall.db")})
+	require.NoError(t, err)
+	defer db.Close()
+	s := storage.New(db)
+	require.NoError(t, s.Users().CreateSchema("user1"))
+
+	userTables := map[string]interface{}{
+		"logins":               &model.Login{},
+		"password_histories":   &model.PasswordHistory{},
+		"credit_cards":         &model.CreditCard{},
+		"bank_accounts":        &model.BankAccount{},
+		"notes":                &model.Note{},
+		"emails":               &model.Email{},
+		"servers":              &model.Server{},
+		"equivalent_domains":   &model.EquivalentDomain{},
+		"webauthn_credentials": &model.WebAuthnCredential{},
+		"audit_logs":           &model.AuditEvent{},
+		"item_versions":        &model.ItemVersion{},
+		"folders":              &model.Folder{},
+		"tags":                 &model.Tag{},
+		"item_tags":            &model.ItemTag{},
+		"sync_counters":        &model.SyncCounter{},
+		"attachments":          &model.Attachment{},
+	}
+	// Every migration is applied, reverted and applied again
+	for i := 0; i < 2; i++ {
+		require.NoError(t, MigrateUp(s, SystemSchema))
+		require.NoError(t, MigrateUp(s, "user1"))
+		assert.Empty(t, s.PendingMigrations())
+		for table, m := range userTables {
+			assert.Empty(t, missingColumns(db, "user1."+table, m), table)
+		}
+
+		require.NoError(t, MigrateDown(s, "user1", 0))
+		require.NoError(t, MigrateDown(s, SystemSchema, 0))
+		for table := range userTables {
+			assert.False(t, db.Dialect().HasTable("user1."+table), table)
+		}
+		assert.False(t, db.Dialect().HasTable("users"))
+		assert.NotEmpty(t, s.PendingMigrations())
+	}
+}
+
+// missingColumns returns the columns of the model which the table hasn't got
+func missingColumns(db *gorm.DB, table string, m interface{}) []string {
+	var missing []string
+	for _, field := range db.NewScope(m).GetModelStruct().StructFields {
+		if field.IsNormal && !field.IsIgnored && !db.Dialect().HasColumn(table, field.DBName) {
+			missing = append(missing, field.DBName)
+		}
+	}
+	return missing
+}
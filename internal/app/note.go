@@ -2,34 +2,55 @@ package app
 
 import (
 	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
 	"github.com/passwall/passwall-server/model"
 )
 
 // CreateNote creates a new note and saves it to the store
 func CreateNote(s storage.Store, dto *model.NoteDTO, schema string) (*model.Note, error) {
+	defer tracing.Start("app.CreateNote").End()
+
 	rawModel := model.ToNote(dto)
-	encModel := EncryptModel(rawModel)
 
-	createdNote, err := s.Notes().Save(encModel.(*model.Note), schema)
+	createdNote, err := s.Notes().Save(rawModel, schema)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := SetItemTags(s, createdNote, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return createdNote, nil
 }
 
 // UpdateNote updates the note with the dto and applies the changes in the store
 func UpdateNote(s storage.Store, note *model.Note, dto *model.NoteDTO, schema string) (*model.Note, error) {
+	defer tracing.Start("app.UpdateNote").End()
+
+	if err := saveItemVersion(s, note, schema); err != nil {
+		return nil, err
+	}
+
 	rawModel := model.ToNote(dto)
-	encModel := EncryptModel(rawModel).(*model.Note)
 
-	note.Title = encModel.Title
-	note.Note = encModel.Note
+	note.Title = rawModel.Title
+	note.Note = rawModel.Note
+	note.Pinned = rawModel.Pinned
+	note.IsFavorite = rawModel.IsFavorite
+	note.SortOrder = rawModel.SortOrder
+	note.FolderID = rawModel.FolderID
+	note.Reprompt = rawModel.Reprompt
+	note.Canary = rawModel.Canary
 
 	updatedNote, err := s.Notes().Save(note, schema)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := SetItemTags(s, updatedNote, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return updatedNote, nil
 }
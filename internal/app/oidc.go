@@ -0,0 +1,369 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+)
+
+const (
+	oidcCodeDuration  = time.Minute
+	oidcTokenDuration = time.Hour
+	oidcKeyBits       = 2048
+)
+
+var (
+	oidcCodes = struct {
+		sync.Mutex
+		m map[string]*oidcCode
+	}{m: map[string]*oidcCode{}}
+
+	oidcKeys = struct {
+		sync.Mutex
+		path string
+		key  *rsa.PrivateKey
+	}{}
+)
+
+// oidcCode is an authorization code waiting to be exchanged for tokens
+type oidcCode struct {
+	request *model.OIDCAuthorizeDTO
+	userID  uint
+	expires time.Time
+}
+
+// OIDCIssuer returns the issuer url of the OpenID Connect provider
+func OIDCIssuer() string {
+	issuer := viper.GetString("oidc.issuer")
+	if issuer == "" {
+		issuer = viper.GetString("server.domain")
+	}
+	return strings.TrimRight(issuer, "/")
+}
+
+// OIDCDiscovery returns the provider metadata
+func OIDCDiscovery() *model.OIDCDiscoveryDTO {
+	issuer := OIDCIssuer()
+	return &model.OIDCDiscoveryDTO{
+		Issuer:                            issuer,
+		AuthorizationEndpoint:             issuer + "/oauth/authorize",
+		TokenEndpoint:                     issuer + "/oauth/token",
+		UserinfoEndpoint:                  issuer + "/oauth/userinfo",
+		JWKSURI:                           issuer + "/oauth/jwks",
+		ResponseTypesSupported:            []string{"code"},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  []string{"RS256"},
+		ScopesSupported:                   []string{"openid", "email", "profile"},
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		ClaimsSupported:                   []string{"sub", "iss", "aud", "exp", "iat", "nonce", "email", "email_verified", "name"},
+		CodeChallengeMethodsSupported:     []string{"S256", "plain"},
+		GrantTypesSupported:               []string{"authorization_code"},
+	}
+}
+
+// FindOIDCClient finds the configured client with the id
+func FindOIDCClient(id string) (*config.OIDCClient, error) {
+	var clients []config.OIDCClient
+	if err := viper.UnmarshalKey("oidc.clients", &clients); err != nil {
+		return nil, err
+	}
+	for i := range clients {
+		if clients[i].ID == id && id != "" {
+			return &clients[i], nil
+		}
+	}
+	return nil, &model.OIDCError{Code: "invalid_client", Description: "unknown client"}
+}
+
+// ValidateOIDCAuthorize checks the authorization request before the user signs in.
+// Until the redirect uri is known to be valid errors can't be sent to the client.
+func ValidateOIDCAuthorize(req *model.OIDCAuthorizeDTO) (*config.OIDCClient, error) {
+	client, err := FindOIDCClient(req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+
+	if FindIndex(client.RedirectURIs, req.RedirectURI) < 0 {
+		return nil, &model.OIDCError{Code: "invalid_request", Description: "redirect_uri is not registered"}
+	}
+
+	if req.ResponseType != "code" {
+		return client, &model.OIDCError{Code: "unsupported_response_type", Description: "only code flow is supported"}
+	}
+	if FindIndex(strings.Fields(req.Scope), "openid") < 0 {
+		return client, &model.OIDCError{Code: "invalid_scope", Description: "openid scope is required"}
+	}
+	if client.Secret == "" && req.CodeChallenge == "" {
+		return client, &model.OIDCError{Code: "invalid_request", Description: "public clients have to use PKCE"}
+	}
+	switch req.CodeChallengeMethod {
+	case "", "plain", "S256":
+	default:
+		return client, &model.OIDCError{Code: "invalid_request", Description: "unsupported code_challenge_method"}
+	}
+
+	return client, nil
+}
+
+// CreateOIDCCode creates an authorization code of the signed in user
+func CreateOIDCCode(req *model.OIDCAuthorizeDTO, user *model.User) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := hex.EncodeToString(b)
+
+	oidcCodes.Lock()
+	defer oidcCodes.Unlock()
+
+	// Drop expired codes which were never exchanged
+	now := time.Now()
+	for k, c := range oidcCodes.m {
+		if now.After(c.expires) {
+			delete(oidcCodes.m, k)
+		}
+	}
+
+	oidcCodes.m[code] = &oidcCode{request: req, userID: user.ID, expires: now.Add(oidcCodeDuration)}
+	return code, nil
+}
+
+// AuthenticateOIDCClient checks the client credentials of the token request
+func AuthenticateOIDCClient(clientID, secret string) (*config.OIDCClient, error) {
+	client, err := FindOIDCClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if subtle.ConstantTimeCompare([]byte(client.Secret), []byte(secret)) != 1 {
+		return nil, &model.OIDCError{Code: "invalid_client", Description: "client authentication failed"}
+	}
+	return client, nil
+}
+
+// ExchangeOIDCCode redeems the authorization code for an id token and an access token.
+// A code can be used only once.
+func ExchangeOIDCCode(s storage.Store, client *config.OIDCClient, code, redirectURI, verifier string) (*model.OIDCTokenDTO, error) {
+	errGrant := &model.OIDCError{Code: "invalid_grant", Description: "code is invalid or expired"}
+
+	oidcCodes.Lock()
+	c, ok := oidcCodes.m[code]
+	delete(oidcCodes.m, code)
+	oidcCodes.Unlock()
+
+	if !ok || time.Now().After(c.expires) || c.request.ClientID != client.ID || c.request.RedirectURI != redirectURI {
+		return nil, errGrant
+	}
+	if !verifyCodeChallenge(c.request.CodeChallenge, c.request.CodeChallengeMethod, verifier) {
+		return nil, &model.OIDCError{Code: "invalid_grant", Description: "code_verifier does not match"}
+	}
+
+	user, err := s.Users().FindByID(c.userID)
+	if err != nil {
+		return nil, errGrant
+	}
+
+	key, kid, err := oidcKey()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	scopes := strings.Fields(c.request.Scope)
+
+	idClaims := jwt.MapClaims{
+		"iss": OIDCIssuer(),
+		"sub": user.UUID.String(),
+		"aud": client.ID,
+		"iat": now.Unix(),
+		"exp": now.Add(oidcTokenDuration).Unix(),
+	}
+	if c.request.Nonce != "" {
+		idClaims["nonce"] = c.request.Nonce
+	}
+	for k, v := range oidcUserClaims(user, scopes) {
+		idClaims[k] = v
+	}
+
+	idToken, err := signOIDCToken(idClaims, key, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := signOIDCToken(jwt.MapClaims{
+		"iss":       OIDCIssuer(),
+		"sub":       user.UUID.String(),
+		"aud":       OIDCIssuer() + "/oauth/userinfo",
+		"client_id": client.ID,
+		"user_id":   user.ID,
+		"scope":     c.request.Scope,
+		"iat":       now.Unix(),
+		"exp":       now.Add(oidcTokenDuration).Unix(),
+	}, key, kid)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.OIDCTokenDTO{
+		AccessToken: accessToken,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(oidcTokenDuration.Seconds()),
+		IDToken:     idToken,
+		Scope:       c.request.Scope,
+	}, nil
+}
+
+// OIDCUserInfo returns the claims of the user the access token was issued for
+func OIDCUserInfo(s storage.Store, accessToken string) (*model.OIDCUserInfoDTO, error) {
+	errToken := &model.OIDCError{Code: "invalid_token", Description: "access token is invalid or expired"}
+
+	key, _, err := oidcKey()
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := jwt.Parse(accessToken, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, errToken
+		}
+		return &key.PublicKey, nil
+	})
+	if err != nil || !token.Valid {
+		return nil, errToken
+	}
+
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if !claims.VerifyAudience(OIDCIssuer()+"/oauth/userinfo", true) {
+		return nil, errToken
+	}
+	userID, _ := claims["user_id"].(float64)
+	scope, _ := claims["scope"].(string)
+
+	user, err := s.Users().FindByID(uint(userID))
+	if err != nil {
+		return nil, errToken
+	}
+
+	info := &model.OIDCUserInfoDTO{Subject: user.UUID.String()}
+	userClaims := oidcUserClaims(user, strings.Fields(scope))
+	if name, ok := userClaims["name"].(string); ok {
+		info.Name = name
+	}
+	if email, ok := userClaims["email"].(string); ok {
+		verified := userClaims["email_verified"].(bool)
+		info.Email = email
+		info.EmailVerified = &verified
+	}
+	return info, nil
+}
+
+// OIDCJWKS returns the public key which verifies the tokens
+func OIDCJWKS() (*model.JWKSDTO, error) {
+	key, kid, err := oidcKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.JWKSDTO{Keys: []model.JWKDTO{{
+		KeyType:   "RSA",
+		Use:       "sig",
+		Algorithm: "RS256",
+		KeyID:     kid,
+		Modulus:   base64.RawURLEncoding.EncodeToString(key.PublicKey.N.Bytes()),
+		Exponent:  base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.PublicKey.E)).Bytes()),
+	}}}, nil
+}
+
+// oidcUserClaims returns the claims of the user which the scopes allow
+func oidcUserClaims(user *model.User, scopes []string) map[string]interface{} {
+	claims := map[string]interface{}{}
+	if FindIndex(scopes, "email") >= 0 {
+		claims["email"] = user.Email
+		claims["email_verified"] = !user.EmailVerifiedAt.IsZero()
+	}
+	if FindIndex(scopes, "profile") >= 0 {
+		claims["name"] = user.Name
+	}
+	return claims
+}
+
+func verifyCodeChallenge(challenge, method, verifier string) bool {
+	if challenge == "" {
+		return true
+	}
+	if method == "S256" {
+		sum := sha256.Sum256([]byte(verifier))
+		verifier = base64.RawURLEncoding.EncodeToString(sum[:])
+	}
+	return subtle.ConstantTimeCompare([]byte(challenge), []byte(verifier)) == 1
+}
+
+func signOIDCToken(claims jwt.MapClaims, key *rsa.PrivateKey, kid string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = kid
+	return token.SignedString(key)
+}
+
+// oidcKey loads the signing key from oidc.keyFile and creates the file if it doesn't exist
+func oidcKey() (*rsa.PrivateKey, string, error) {
+	path := viper.GetString("oidc.keyFile")
+
+	oidcKeys.Lock()
+	defer oidcKeys.Unlock()
+
+	if oidcKeys.key == nil || oidcKeys.path != path {
+		key, err := loadOrCreateRSAKey(path)
+		if err != nil {
+			return nil, "", err
+		}
+		oidcKeys.path, oidcKeys.key = path, key
+	}
+
+	sum := sha256.Sum256(x509.MarshalPKCS1PublicKey(&oidcKeys.key.PublicKey))
+	return oidcKeys.key, hex.EncodeToString(sum[:8]), nil
+}
+
+func loadOrCreateRSAKey(path string) (*rsa.PrivateKey, error) {
+	data, err := ioutil.ReadFile(path)
+	if err == nil {
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, &model.OIDCError{Code: "server_error", Description: "oidc key file is not a PEM file"}
+		}
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, oidcKeyBits)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	block := &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(block), 0600); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupOIDC(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "oidc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set("oidc.issuer", "https://vault.example.com")
+	viper.Set("oidc.keyFile", filepath.Join(dir, "oidc.pem"))
+	viper.Set("oidc.clients", []map[string]interface{}{
+		{"id": "wiki", "secret": "s3cret", "redirectURIs": []string{"https://wiki.example.com/callback"}},
+		{"id": "cli", "redirectURIs": []string{"http://localhost:8085/"}},
+	})
+
+	return func() {
+		viper.Set("oidc.clients", nil)
+		os.RemoveAll(dir)
+	}
+}
+
+func TestValidateOIDCAuthorize(t *testing.T) {
+	defer setupOIDC(t)()
+
+	req := &model.OIDCAuthorizeDTO{
+		ResponseType: "code",
+		ClientID:     "wiki",
+		RedirectURI:  "https://evil.example.com/callback",
+		Scope:        "openid email",
+	}
+	client, err := ValidateOIDCAuthorize(req)
+	assert.Nil(t, client)
+	assert.Error(t, err)
+
+	req.RedirectURI = "https://wiki.example.com/callback"
+	client, err = ValidateOIDCAuthorize(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "wiki", client.ID)
+
+	req.ClientID, req.RedirectURI = "cli", "http://localhost:8085/"
+	_, err = ValidateOIDCAuthorize(req)
+	assert.Equal(t, "invalid_request", err.(*model.OIDCError).Code)
+}
+
+func TestExchangeOIDCCode(t *testing.T) {
+	defer setupOIDC(t)()
+
+	user := &model.User{ID: 7, UUID: uuid.NewV4(), Name: "Jane", Email: "jane@example.com"}
+	mocks := storagetest.NewMocks()
+	mocks.Users.On("FindByID", uint(7)).Return(user, nil)
+
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	sum := sha256.Sum256([]byte(verifier))
+	req := &model.OIDCAuthorizeDTO{
+		ResponseType:        "code",
+		ClientID:            "cli",
+		RedirectURI:         "http://localhost:8085/",
+		Scope:               "openid email",
+		Nonce:               "n-0S6",
+		CodeChallenge:       base64.RawURLEncoding.EncodeToString(sum[:]),
+		CodeChallengeMethod: "S256",
+	}
+	client, err := ValidateOIDCAuthorize(req)
+	assert.NoError(t, err)
+
+	code, err := CreateOIDCCode(req, user)
+	assert.NoError(t, err)
+
+	_, err = ExchangeOIDCCode(mocks.Store, client, code, req.RedirectURI, "wrong")
+	assert.Equal(t, "invalid_grant", err.(*model.OIDCError).Code)
+
+	// The failed exchange used up the code
+	code, _ = CreateOIDCCode(req, user)
+	token, err := ExchangeOIDCCode(mocks.Store, client, code, req.RedirectURI, verifier)
+	assert.NoError(t, err)
+
+	_, err = ExchangeOIDCCode(mocks.Store, client, code, req.RedirectURI, verifier)
+	assert.Error(t, err)
+
+	key, _, err := oidcKey()
+	assert.NoError(t, err)
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(token.IDToken, claims, func(*jwt.Token) (interface{}, error) {
+		return &key.PublicKey, nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "n-0S6", claims["nonce"])
+	assert.Equal(t, "jane@example.com", claims["email"])
+	assert.Equal(t, user.UUID.String(), claims["sub"])
+
+	info, err := OIDCUserInfo(mocks.Store, token.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "jane@example.com", info.Email)
+}
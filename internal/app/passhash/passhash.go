@@ -0,0 +1,102 @@
+// Package passhash hashes master passwords with Argon2id. Hashes are in the PHC string
+// format, like $argon2id$v=19$m=65536,t=3,p=2$salt$hash, so each one keeps the parameters
+// it was made with. bcrypt hashes of older versions are still verified.
+package passhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	saltLength = 16
+	keyLength  = 32
+	prefix     = "$argon2id$"
+)
+
+// ErrMismatch is returned when the password doesn't match the hash
+var ErrMismatch = errors.New("passhash: password doesn't match")
+
+var errFormat = errors.New("passhash: hash is not valid")
+
+// Params are the costs of Argon2id
+type Params struct {
+	Memory      uint32 // KiB
+	Iterations  uint32
+	Parallelism uint8
+}
+
+// DefaultParams are the costs of the second recommended option of RFC 9106, with 64 MiB of memory
+var DefaultParams = Params{Memory: 64 * 1024, Iterations: 3, Parallelism: 4}
+
+// Hash returns the Argon2id hash of the password with a random salt
+func Hash(password string, p Params) (string, error) {
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, keyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", prefix, argon2.Version, p.Memory, p.Iterations, p.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify checks the password against an Argon2id or bcrypt hash
+func Verify(hash, password string) error {
+	if !strings.HasPrefix(hash, prefix) {
+		if bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) != nil {
+			return ErrMismatch
+		}
+		return nil
+	}
+
+	p, salt, key, err := decode(hash)
+	if err != nil {
+		return err
+	}
+	other := argon2.IDKey([]byte(password), salt, p.Iterations, p.Memory, p.Parallelism, uint32(len(key)))
+	if subtle.ConstantTimeCompare(key, other) != 1 {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// NeedsRehash is true for bcrypt hashes and for Argon2id hashes of other parameters
+func NeedsRehash(hash string, p Params) bool {
+	params, _, _, err := decode(hash)
+	return err != nil || *params != p
+}
+
+// decode reads the parameters, salt and key of an Argon2id hash
+func decode(hash string) (*Params, []byte, []byte, error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[1] != "argon2id" {
+		return nil, nil, nil, errFormat
+	}
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return nil, nil, nil, errFormat
+	}
+	p := new(Params)
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &p.Memory, &p.Iterations, &p.Parallelism); err != nil {
+		return nil, nil, nil, errFormat
+	}
+	if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 {
+		return nil, nil, nil, errFormat
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return nil, nil, nil, errFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return nil, nil, nil, errFormat
+	}
+	return p, salt, key, nil
+}
@@ -0,0 +1,47 @@
+package passhash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var testParams = Params{Memory: 1024, Iterations: 1, Parallelism: 1}
+
+func TestHashAndVerify(t *testing.T) {
+	hash, err := Hash("master-password", testParams)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.NoError(t, Verify(hash, "master-password"))
+	assert.Equal(t, ErrMismatch, Verify(hash, "guess"))
+
+	// Each hash has its own salt
+	other, err := Hash("master-password", testParams)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other)
+}
+
+func TestVerifyBcrypt(t *testing.T) {
+	legacy, err := bcrypt.GenerateFromPassword([]byte("master-password"), bcrypt.MinCost)
+	assert.NoError(t, err)
+	assert.NoError(t, Verify(string(legacy), "master-password"))
+	assert.Equal(t, ErrMismatch, Verify(string(legacy), "guess"))
+}
+
+func TestVerifyMalformed(t *testing.T) {
+	for _, hash := range []string{"", "$argon2id$v=19$m=1024,t=1,p=1$", "$argon2id$v=18$m=1024,t=1,p=1$c2FsdA$a2V5", "$argon2id$v=19$m=x$c2FsdA$a2V5"} {
+		assert.Error(t, Verify(hash, "master-password"), hash)
+	}
+}
+
+func TestNeedsRehash(t *testing.T) {
+	hash, err := Hash("master-password", testParams)
+	assert.NoError(t, err)
+	assert.False(t, NeedsRehash(hash, testParams))
+	assert.True(t, NeedsRehash(hash, Params{Memory: 2048, Iterations: 1, Parallelism: 1}))
+
+	legacy, _ := bcrypt.GenerateFromPassword([]byte("master-password"), bcrypt.MinCost)
+	assert.True(t, NeedsRehash(string(legacy), testParams))
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"github.com/passwall/passwall-server/internal/app/passhash"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// NewPasswordHash hashes a master or duress password with Argon2id and the parameters of passwordHash
+func NewPasswordHash(password string) (string, error) {
+	return passhash.Hash(password, passwordHashParams())
+}
+
+// passwordHashParams returns the configured Argon2id parameters, unset ones are the defaults
+func passwordHashParams() passhash.Params {
+	p := passhash.DefaultParams
+	if memory := viper.GetInt("passwordHash.memory"); memory > 0 {
+		p.Memory = uint32(memory)
+	}
+	if iterations := viper.GetInt("passwordHash.iterations"); iterations > 0 {
+		p.Iterations = uint32(iterations)
+	}
+	if parallelism := viper.GetInt("passwordHash.parallelism"); parallelism > 0 && parallelism < 256 {
+		p.Parallelism = uint8(parallelism)
+	}
+	return p
+}
+
+// upgradePasswordHash rehashes the password of a sign in when its hash is a bcrypt one of
+// older versions or has other parameters. The password is only known while signing in.
+func upgradePasswordHash(s storage.Store, user *model.User, hash *string, password string) {
+	params := passwordHashParams()
+	if !passhash.NeedsRehash(*hash, params) {
+		return
+	}
+	upgraded, err := passhash.Hash(password, params)
+	if err != nil {
+		log.WithError(err).WithField("user_id", user.ID).Error("password hash couldn't be upgraded")
+		return
+	}
+	*hash = upgraded
+	if _, err := s.Users().Save(user); err != nil {
+		log.WithError(err).WithField("user_id", user.ID).Error("password hash couldn't be upgraded")
+	}
+}
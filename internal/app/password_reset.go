@@ -0,0 +1,173 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/i18n"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Policies of server.passwordReset for the vault of a forgotten master password
+const (
+	// PasswordResetKeep keeps the vault, the server passphrase encrypts it so it stays readable
+	PasswordResetKeep = "keep"
+	// PasswordResetWipe empties the vault, for clients which encrypt it with the master password
+	PasswordResetWipe = "wipe"
+	// PasswordResetOff turns the reset by email off, admins still reset master passwords
+	PasswordResetOff = "off"
+)
+
+// ResetVaultWipeRequired is the error code of resets which don't confirm the vault is wiped
+const ResetVaultWipeRequired = "VAULT_WIPE_REQUIRED"
+
+const passwordResetExpiry = 30 * time.Minute
+
+var (
+	// ErrPasswordResetOff is returned when server.passwordReset turns the reset by email off
+	ErrPasswordResetOff = errors.New("Password reset is turned off, ask an admin")
+	// ErrPasswordResetToken is returned for reset links which are expired, changed or used
+	ErrPasswordResetToken = errors.New("Password reset link is expired or not valid")
+	// ErrVaultWipeRequired is returned when the reset wipes the vault and the request doesn't confirm it
+	ErrVaultWipeRequired = errors.New("The reset wipes the vault, confirm it with wipe_vault")
+)
+
+// PasswordResetPolicy returns the policy of server.passwordReset, the vault is kept by default
+func PasswordResetPolicy() string {
+	switch policy := strings.ToLower(viper.GetString("server.passwordReset")); policy {
+	case PasswordResetWipe, PasswordResetOff:
+		return policy
+	}
+	return PasswordResetKeep
+}
+
+// RecoverPassword emails a reset link to the account of the email. Like verification
+// emails, each address can ask budget.verification times and the answer is the same for
+// unknown ones. The link stops working once the master password changes.
+func RecoverPassword(s storage.Store, email string, now time.Time) (*BudgetStatus, error) {
+	if PasswordResetPolicy() == PasswordResetOff {
+		return nil, ErrPasswordResetOff
+	}
+	status := spendBudget("email:"+strings.ToLower(strings.TrimSpace(email)), BudgetVerification, now)
+	if !status.Allowed {
+		return status, nil
+	}
+	user, err := s.Users().FindByEmail(email)
+	if err != nil {
+		return status, nil
+	}
+
+	claims := jwt.MapClaims{
+		"user_id":  user.ID,
+		"password": passwordFingerprint(user),
+		"purpose":  "password_reset",
+		"exp":      now.Add(passwordResetExpiry).Unix(),
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(viper.GetString("server.secret")))
+	if err != nil {
+		return status, err
+	}
+
+	page := viper.GetString("server.passwordResetURL")
+	if page == "" {
+		page = viper.GetString("server.domain") + "/reset-password"
+	}
+	body, err := i18n.Render(user.Locale, "email.password_reset.body", map[string]string{
+		"Link": page + "?token=" + token,
+	})
+	if err != nil {
+		return status, err
+	}
+	SendMail(user.Name, user.Email, i18n.T(user.Locale, "email.password_reset.subject"), body)
+
+	log.WithFields(log.Fields{
+		"event":   "password_reset_requested",
+		"user_id": user.ID,
+	}).Info("password reset link is sent")
+	return status, nil
+}
+
+// ResetPassword sets the master password of the reset link and ends the sessions of the
+// user. The wipe policy empties the vault and the decoy vault, the request has to confirm it.
+func ResetPassword(s storage.Store, dto *model.PasswordResetDTO) (*model.User, error) {
+	policy := PasswordResetPolicy()
+	if policy == PasswordResetOff {
+		return nil, ErrPasswordResetOff
+	}
+
+	token, err := verifyToken(dto.Token)
+	if err != nil || !token.Valid {
+		return nil, ErrPasswordResetToken
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if purpose, _ := claims["purpose"].(string); purpose != "password_reset" {
+		return nil, ErrPasswordResetToken
+	}
+	userID, _ := claims["user_id"].(float64)
+	user, err := s.Users().FindByID(uint(userID))
+	if err != nil {
+		return nil, ErrPasswordResetToken
+	}
+	if fingerprint, _ := claims["password"].(string); fingerprint != passwordFingerprint(user) {
+		return nil, ErrPasswordResetToken
+	}
+	if policy == PasswordResetWipe && !dto.WipeVault {
+		return nil, ErrVaultWipeRequired
+	}
+
+	// The vault is wiped once the new master password is saved, never for a failed reset
+	updatedUser, err := ResetMasterPassword(s, user, dto.MasterPassword)
+	if err != nil {
+		return nil, err
+	}
+	if policy == PasswordResetWipe {
+		if err := wipeVault(s, updatedUser); err != nil {
+			return nil, err
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"event":   "master_password_reset",
+		"user_id": user.ID,
+		"wiped":   policy == PasswordResetWipe,
+	}).Warn("master password is reset by email")
+	SendMail(user.Name, user.Email, i18n.T(user.Locale, "email.password_changed.subject"),
+		i18n.T(user.Locale, "email.password_changed.body"))
+	return updatedUser, nil
+}
+
+// wipeVault replaces the vault of the user with an empty one and removes the decoy vault
+func wipeVault(s storage.Store, user *model.User) error {
+	if user.DuressPassword != "" {
+		deleteVaultAttachments(DecoySchema(user.Schema))
+		if err := s.Users().DropSchema(DecoySchema(user.Schema)); err != nil {
+			return err
+		}
+		user.DuressPassword = ""
+		if _, err := s.Users().Save(user); err != nil {
+			return err
+		}
+	}
+	deleteVaultAttachments(user.Schema)
+	if err := s.Users().DropSchema(user.Schema); err != nil {
+		return err
+	}
+	if err := s.Users().CreateSchema(user.Schema); err != nil {
+		return err
+	}
+	MigrateUserTables(s, user.Schema)
+	return nil
+}
+
+// passwordFingerprint identifies the master password hash without revealing it
+func passwordFingerprint(user *model.User) string {
+	sum := sha256.Sum256([]byte(user.MasterPassword))
+	return hex.EncodeToString(sum[:8])
+}
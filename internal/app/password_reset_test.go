@@ -0,0 +1,40 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestResetPasswordWipeFails(t *testing.T) {
+	viper.Set("server.secret", "reset-test-secret")
+	viper.Set("server.passwordReset", PasswordResetWipe)
+	defer viper.Set("server.passwordReset", "")
+
+	user := &model.User{ID: 1, Email: "test@passwall.io", MasterPassword: "hash", Schema: "user1"}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id":  user.ID,
+		"password": passwordFingerprint(user),
+		"purpose":  "password_reset",
+		"exp":      time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("reset-test-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The vault isn't wiped when the new master password can't be saved
+	errSave := errors.New("connection refused")
+	mocks := storagetest.NewMocks()
+	mocks.Users.On("FindByID", uint(1)).Return(user, nil)
+	mocks.Users.On("Save", user).Return(nil, errSave)
+
+	_, err = ResetPassword(mocks.Store, &model.PasswordResetDTO{Token: token, MasterPassword: "new-password", WipeVault: true})
+	assert.Equal(t, errSave, err)
+	mocks.Users.AssertNotCalled(t, "DropSchema", "user1")
+}
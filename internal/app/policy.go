@@ -0,0 +1,153 @@
+package app
+
+import (
+	"errors"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// ServerPolicyID is the user id of the server wide policy
+const ServerPolicyID = 0
+
+// sessionTouchInterval limits the writes of the session activity to one per interval
+const sessionTouchInterval = 30 * time.Second
+
+var (
+	// ErrSessionIdle is returned when a session wasn't used for longer than the idle timeout
+	ErrSessionIdle = errors.New("session is locked after inactivity")
+	// ErrSessionExpired is returned when a session is older than the absolute timeout
+	ErrSessionExpired = errors.New("session is expired, sign in again")
+)
+
+// FindPolicy returns the policy of the user, ServerPolicyID is the server policy.
+// The server policy defaults to the configuration until it is saved.
+func FindPolicy(s storage.Store, userID uint) (*model.Policy, error) {
+	policy, err := s.Policies().FindByUserID(userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		policy = &model.Policy{UserID: userID}
+		if userID == ServerPolicyID {
+			policy.SessionIdleTimeout = viper.GetString("server.sessionIdleTimeout")
+			policy.SessionAbsoluteTimeout = viper.GetString("server.sessionAbsoluteTimeout")
+		}
+		return policy, nil
+	}
+	return policy, err
+}
+
+// ValidatePolicy checks the periods and the access rules of the policy
+func ValidatePolicy(dto *model.PolicyDTO) error {
+	for _, period := range []string{
+		dto.SessionIdleTimeout, dto.SessionAbsoluteTimeout, dto.SecurityKeyGracePeriod,
+		dto.TrashRetention, dto.AuditRetention, dto.TombstoneRetention, dto.SessionRetention,
+	} {
+		if period == "" {
+			continue
+		}
+		if _, err := parsePeriod(period); err != nil {
+			return err
+		}
+	}
+	return validateAccessRules(dto)
+}
+
+// SavePolicy replaces the rules of the policy of the user
+func SavePolicy(s storage.Store, userID uint, dto *model.PolicyDTO) (*model.Policy, error) {
+	if err := ValidatePolicy(dto); err != nil {
+		return nil, err
+	}
+
+	policy, err := FindPolicy(s, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.Policies().Save(model.ToPolicy(dto, policy))
+}
+
+// EffectivePolicy merges the session timeouts and security key requirements of the server and
+// user policies, the stricter one wins. Access rules aren't merged, CheckAccess evaluates each
+// policy on its own.
+func EffectivePolicy(s storage.Store, userID uint) (*model.PolicyDTO, error) {
+	server, err := FindPolicy(s, ServerPolicyID)
+	if err != nil {
+		return nil, err
+	}
+	user, err := FindPolicy(s, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := earlierTime(securityKeyDeadline(server), securityKeyDeadline(user))
+	return &model.PolicyDTO{
+		SessionIdleTimeout:     shorterPeriod(server.SessionIdleTimeout, user.SessionIdleTimeout),
+		SessionAbsoluteTimeout: shorterPeriod(server.SessionAbsoluteTimeout, user.SessionAbsoluteTimeout),
+		RequireSecurityKey:     deadline != nil,
+		SecurityKeyDeadline:    deadline,
+		SecurityKeyExemptUntil: user.SecurityKeyExemptUntil,
+	}, nil
+}
+
+// CheckSession returns ErrSessionIdle or ErrSessionExpired when the session of the token
+// is over according to the effective policy of the user. Tokens issued before these
+// fields existed have zero times and only their expiry applies.
+func CheckSession(s storage.Store, userID uint, lastUsedAt, startedAt time.Time) error {
+	policy, err := EffectivePolicy(s, userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if idle, err := parsePeriod(policy.SessionIdleTimeout); err == nil && !lastUsedAt.IsZero() && now.Sub(lastUsedAt) > idle {
+		return ErrSessionIdle
+	}
+	if absolute, err := parsePeriod(policy.SessionAbsoluteTimeout); err == nil && !startedAt.IsZero() && now.Sub(startedAt) > absolute {
+		return ErrSessionExpired
+	}
+	return nil
+}
+
+// TouchSession marks the session of the token as active
+func TouchSession(s storage.Store, token *model.Token) {
+	if time.Since(token.LastUsedAt) < sessionTouchInterval {
+		return
+	}
+	TouchSessionAt(s, token, time.Now())
+}
+
+// TouchSessionAt sets the last activity time of the session of the token. Read-only
+// servers can't write it, the idle timeout counts from the last request to the primary.
+func TouchSessionAt(s storage.Store, token *model.Token, lastUsedAt time.Time) {
+	if ReadOnly() {
+		return
+	}
+	var err error
+	if token.Family == "" {
+		err = s.Tokens().Touch(token.UserID, lastUsedAt)
+	} else {
+		err = s.Tokens().TouchByFamily(token.Family, lastUsedAt)
+	}
+	if err != nil {
+		log.WithError(err).WithField("user_id", token.UserID).Error("session activity couldn't be saved")
+	}
+}
+
+// shorterPeriod returns the shorter of the periods, empty periods are unlimited
+func shorterPeriod(a, b string) string {
+	da, errA := parsePeriod(a)
+	db, errB := parsePeriod(b)
+	switch {
+	case errA != nil:
+		if errB != nil {
+			return ""
+		}
+		return b
+	case errB != nil || da <= db:
+		return a
+	}
+	return b
+}
@@ -0,0 +1,52 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShorterPeriod(t *testing.T) {
+	assert.Equal(t, "", shorterPeriod("", ""))
+	assert.Equal(t, "15m", shorterPeriod("", "15m"))
+	assert.Equal(t, "15m", shorterPeriod("15m", ""))
+	assert.Equal(t, "15m", shorterPeriod("1h", "15m"))
+	assert.Equal(t, "2h", shorterPeriod("2h", "1d"))
+}
+
+func TestCheckSession(t *testing.T) {
+	viper.Set("server.sessionIdleTimeout", "")
+	viper.Set("server.sessionAbsoluteTimeout", "12h")
+	defer viper.Set("server.sessionAbsoluteTimeout", "")
+
+	mocks := storagetest.NewMocks()
+	store := mocks.Store
+	mocks.Policies.On("FindByUserID", uint(ServerPolicyID)).Return(nil, gorm.ErrRecordNotFound)
+	mocks.Policies.On("FindByUserID", uint(1)).Return(&model.Policy{UserID: 1, SessionIdleTimeout: "15m"}, nil)
+
+	now := time.Now()
+	assert.NoError(t, CheckSession(store, 1, now, now))
+	assert.NoError(t, CheckSession(store, 1, time.Time{}, time.Time{}))
+	assert.Equal(t, ErrSessionIdle, CheckSession(store, 1, now.Add(-time.Hour), now))
+	assert.Equal(t, ErrSessionExpired, CheckSession(store, 1, now, now.Add(-13*time.Hour)))
+}
+
+func TestSavePolicy(t *testing.T) {
+	mocks := storagetest.NewMocks()
+	store := mocks.Store
+	mocks.Policies.On("FindByUserID", uint(1)).Return(nil, gorm.ErrRecordNotFound)
+	mocks.Policies.On("Save", &model.Policy{UserID: 1, SessionIdleTimeout: "30m"}).Return(&model.Policy{ID: 1, UserID: 1, SessionIdleTimeout: "30m"}, nil)
+
+	_, err := SavePolicy(store, 1, &model.PolicyDTO{SessionAbsoluteTimeout: "soon"})
+	assert.Equal(t, errPeriod, err)
+
+	policy, err := SavePolicy(store, 1, &model.PolicyDTO{SessionIdleTimeout: "30m"})
+	assert.NoError(t, err)
+	assert.Equal(t, "30m", policy.SessionIdleTimeout)
+	mocks.AssertExpectations(t)
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+)
+
+// Key derivation functions clients derive the keys of the vault with
+const (
+	KdfPBKDF2   = "pbkdf2-sha256"
+	KdfArgon2id = "argon2id"
+)
+
+// Prelogin returns the key derivation function of the account and its parameters.
+// Accounts without their own use the ones of the kdf configuration, unknown emails
+// get them too so the answer doesn't tell which accounts exist.
+func Prelogin(s storage.Store, email string) *model.PreloginResponse {
+	kdf := configuredKdf()
+	user, err := s.Users().FindByEmail(email)
+	if err != nil || user.KdfType == "" {
+		return kdf
+	}
+
+	// The parameters the user doesn't have come from the server if it uses the same function
+	if user.KdfType != kdf.Kdf {
+		kdf = kdfDefaults(user.KdfType)
+	}
+	if user.KdfIterations > 0 {
+		kdf.Iterations = user.KdfIterations
+	}
+	if user.KdfMemory > 0 {
+		kdf.Memory = user.KdfMemory
+	}
+	if user.KdfParallelism > 0 {
+		kdf.Parallelism = user.KdfParallelism
+	}
+	return kdf
+}
+
+// configuredKdf returns the kdf configuration, unset values are the defaults of its function
+func configuredKdf() *model.PreloginResponse {
+	kdf := kdfDefaults(strings.ToLower(viper.GetString("kdf.type")))
+	if iterations := viper.GetInt("kdf.iterations"); iterations > 0 {
+		kdf.Iterations = iterations
+	}
+	if kdf.Kdf == KdfArgon2id {
+		if memory := viper.GetInt("kdf.memory"); memory > 0 {
+			kdf.Memory = memory
+		}
+		if parallelism := viper.GetInt("kdf.parallelism"); parallelism > 0 {
+			kdf.Parallelism = parallelism
+		}
+	}
+	return kdf
+}
+
+// kdfDefaults returns the default parameters of the key derivation function, PBKDF2 for unknown ones
+func kdfDefaults(function string) *model.PreloginResponse {
+	if function == KdfArgon2id {
+		return &model.PreloginResponse{Kdf: KdfArgon2id, Iterations: 3, Memory: 65536, Parallelism: 4}
+	}
+	return &model.PreloginResponse{Kdf: KdfPBKDF2, Iterations: 600000}
+}
@@ -0,0 +1,37 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrelogin(t *testing.T) {
+	mocks := storagetest.NewMocks()
+	mocks.Users.On("FindByEmail", "nobody@passwall.io").Return(nil, errors.New("record not found"))
+	mocks.Users.On("FindByEmail", "test@passwall.io").Return(&model.User{ID: 1}, nil)
+	mocks.Users.On("FindByEmail", "tuned@passwall.io").Return(&model.User{ID: 2, KdfType: KdfArgon2id, KdfMemory: 262144}, nil)
+
+	// Unknown accounts get the same answer as the ones without their own
+	pbkdf2 := &model.PreloginResponse{Kdf: KdfPBKDF2, Iterations: 600000}
+	assert.Equal(t, pbkdf2, Prelogin(mocks.Store, "nobody@passwall.io"))
+	assert.Equal(t, pbkdf2, Prelogin(mocks.Store, "test@passwall.io"))
+
+	// Parameters the user doesn't have are the defaults of its function
+	assert.Equal(t, &model.PreloginResponse{Kdf: KdfArgon2id, Iterations: 3, Memory: 262144, Parallelism: 4},
+		Prelogin(mocks.Store, "tuned@passwall.io"))
+
+	// or the ones of the server when it uses the same
+	viper.Set("kdf.type", KdfArgon2id)
+	viper.Set("kdf.parallelism", 2)
+	defer viper.Set("kdf.type", "")
+	defer viper.Set("kdf.parallelism", 0)
+	assert.Equal(t, &model.PreloginResponse{Kdf: KdfArgon2id, Iterations: 3, Memory: 65536, Parallelism: 2},
+		Prelogin(mocks.Store, "nobody@passwall.io"))
+	assert.Equal(t, &model.PreloginResponse{Kdf: KdfArgon2id, Iterations: 3, Memory: 262144, Parallelism: 2},
+		Prelogin(mocks.Store, "tuned@passwall.io"))
+}
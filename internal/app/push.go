@@ -0,0 +1,77 @@
+package app
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// changeBuffer is the number of changes waiting for a connection, one which falls
+// further behind is closed and its client syncs when it reconnects
+const changeBuffer = 32
+
+// subscribers are the change channels of the connected sessions by the schema of their
+// vault. They're kept in the memory of the server, each instance pushes its own changes.
+var subscribers = struct {
+	sync.Mutex
+	bySchema map[string]map[chan model.ChangeDTO]bool
+}{bySchema: map[string]map[chan model.ChangeDTO]bool{}}
+
+// SubscribeChanges returns the changes of the items of the vault from now on. The channel
+// is closed when the subscriber falls behind, unsubscribe ends the subscription.
+func SubscribeChanges(schema string) (<-chan model.ChangeDTO, func()) {
+	changes := make(chan model.ChangeDTO, changeBuffer)
+
+	subscribers.Lock()
+	if subscribers.bySchema[schema] == nil {
+		subscribers.bySchema[schema] = map[chan model.ChangeDTO]bool{}
+	}
+	subscribers.bySchema[schema][changes] = true
+	subscribers.Unlock()
+
+	unsubscribe := func() {
+		subscribers.Lock()
+		defer subscribers.Unlock()
+		removeSubscriber(schema, changes)
+	}
+	return changes, unsubscribe
+}
+
+// PublishChange sends the change to the subscribers of the vault, it never blocks
+func PublishChange(schema string, change model.ChangeDTO) {
+	subscribers.Lock()
+	defer subscribers.Unlock()
+
+	for changes := range subscribers.bySchema[schema] {
+		select {
+		case changes <- change:
+		default:
+			removeSubscriber(schema, changes)
+		}
+	}
+}
+
+// PublishItemChanges sends a change of each item pointer. The requests of a single type
+// are published by the audit middleware, this is for the ones of many types.
+func PublishItemChanges(schema string, items []interface{}, operation string) {
+	for _, item := range items {
+		PublishChange(schema, model.ChangeDTO{
+			Type:      ItemTypeOf(item),
+			ID:        uint(reflect.ValueOf(item).Elem().FieldByName("ID").Uint()),
+			Operation: operation,
+		})
+	}
+}
+
+// removeSubscriber closes the channel once, the lock of the subscribers is held
+func removeSubscriber(schema string, changes chan model.ChangeDTO) {
+	if !subscribers.bySchema[schema][changes] {
+		return
+	}
+	delete(subscribers.bySchema[schema], changes)
+	if len(subscribers.bySchema[schema]) == 0 {
+		delete(subscribers.bySchema, schema)
+	}
+	close(changes)
+}
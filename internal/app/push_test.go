@@ -0,0 +1,38 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPublishChange(t *testing.T) {
+	changes, unsubscribe := SubscribeChanges("user1")
+	defer unsubscribe()
+	decoy, unsubscribeDecoy := SubscribeChanges(DecoySchema("user1"))
+	defer unsubscribeDecoy()
+
+	change := model.ChangeDTO{Type: LoginItem, ID: 3, Operation: AuditUpdate}
+	PublishChange("user1", change)
+	assert.Equal(t, change, <-changes)
+
+	// Other vaults, the decoy one too, don't see the change
+	select {
+	case <-decoy:
+		t.Fatal("change of another vault is pushed")
+	default:
+	}
+
+	// A subscriber which falls behind is closed
+	for i := 0; i <= changeBuffer; i++ {
+		PublishChange("user1", change)
+	}
+	for range changes {
+	}
+	unsubscribe()
+
+	PublishChange("user1", change)
+	_, ok := <-changes
+	assert.False(t, ok)
+}
@@ -0,0 +1,72 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app/passhash"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+)
+
+// ReauthHeader is the header of the token of a re-authentication
+const ReauthHeader = "X-Reauth-Token"
+
+// reauthExpiry is how long a re-authentication lasts
+const reauthExpiry = 5 * time.Minute
+
+// ErrReauthRequired is returned for sensitive requests without a valid re-authentication
+var ErrReauthRequired = errors.New("Re-authenticate with the master password at /api/auth/reauth first")
+
+// Reauthenticate checks the master password of the user and returns the token which
+// proves it for the session until it expires. Duress sessions confirm the duress
+// password, so the decoy vault can't be told apart.
+func Reauthenticate(user *model.User, password string, duress bool, session string, now time.Time) (*model.ReauthTokenDTO, error) {
+	hash := user.MasterPassword
+	if duress {
+		hash = user.DuressPassword
+	}
+	if hash == "" || passhash.Verify(hash, password) != nil {
+		return nil, ErrMasterPassword
+	}
+
+	expiresAt := now.Add(reauthExpiry)
+	expires := strconv.FormatInt(expiresAt.Unix(), 10)
+	return &model.ReauthTokenDTO{
+		Token:     expires + "." + reauthSignature(user.ID, session, expires),
+		ExpiresAt: expiresAt.UTC(),
+	}, nil
+}
+
+// VerifyReauth checks that the token is a re-authentication of the user in the session
+// which didn't expire
+func VerifyReauth(token string, userID uint, session string, now time.Time) error {
+	parts := strings.SplitN(token, ".", 2)
+	if len(parts) != 2 {
+		return ErrReauthRequired
+	}
+	unix, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil || now.Unix() > unix {
+		return ErrReauthRequired
+	}
+	if !hmac.Equal([]byte(parts[1]), []byte(reauthSignature(userID, session, parts[0]))) {
+		return ErrReauthRequired
+	}
+	return nil
+}
+
+// reauthSignature signs the token with a key derived from server.secret, tokens of other
+// sessions or users don't verify
+func reauthSignature(userID uint, session, expires string) string {
+	key := sha256.Sum256([]byte("reauth:" + viper.GetString("server.secret")))
+	mac := hmac.New(sha256.New, key[:])
+	io.WriteString(mac, fmt.Sprintf("%d\n%s\n%s", userID, session, expires))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,35 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReauthenticate(t *testing.T) {
+	viper.Set("server.secret", "reauth-test-secret")
+
+	user := &model.User{ID: 1, MasterPassword: NewBcrypt([]byte("master-password")), DuressPassword: NewBcrypt([]byte("duress-password"))}
+	now := time.Now()
+
+	_, err := Reauthenticate(user, "guess", false, "family", now)
+	assert.Equal(t, ErrMasterPassword, err)
+	_, err = Reauthenticate(user, "master-password", true, "family", now)
+	assert.Equal(t, ErrMasterPassword, err)
+	_, err = Reauthenticate(user, "duress-password", true, "family", now)
+	assert.NoError(t, err)
+
+	token, err := Reauthenticate(user, "master-password", false, "family", now)
+	assert.NoError(t, err)
+	assert.Equal(t, now.Add(reauthExpiry).Unix(), token.ExpiresAt.Unix())
+
+	assert.NoError(t, VerifyReauth(token.Token, 1, "family", now))
+	assert.Equal(t, ErrReauthRequired, VerifyReauth(token.Token, 1, "family", now.Add(reauthExpiry+time.Second)))
+	assert.Equal(t, ErrReauthRequired, VerifyReauth(token.Token, 1, "other-family", now))
+	assert.Equal(t, ErrReauthRequired, VerifyReauth(token.Token, 2, "family", now))
+	assert.Equal(t, ErrReauthRequired, VerifyReauth("", 1, "family", now))
+	assert.Equal(t, ErrReauthRequired, VerifyReauth("9"+token.Token, 1, "family", now))
+}
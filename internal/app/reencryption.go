@@ -0,0 +1,418 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/storage/fieldcipher"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// reencryptionPoll is how often the worker looks for jobs queued by other processes like the CLI
+const reencryptionPoll = 30 * time.Second
+
+// reencryptionRecent is the count of jobs in the status
+const reencryptionRecent = 10
+
+var errBatchSize = errors.New("reencryption.batchSize should be a positive number")
+
+// reencryptionTable is a table with encrypted fields and the model of its rows
+type reencryptionTable struct {
+	name string
+	rows func() interface{}
+}
+
+// reencryptionTables are the tables of a vault
+var reencryptionTables = []reencryptionTable{
+	{"logins", func() interface{} { return &[]model.Login{} }},
+	{"credit_cards", func() interface{} { return &[]model.CreditCard{} }},
+	{"bank_accounts", func() interface{} { return &[]model.BankAccount{} }},
+	{"notes", func() interface{} { return &[]model.Note{} }},
+	{"emails", func() interface{} { return &[]model.Email{} }},
+	{"servers", func() interface{} { return &[]model.Server{} }},
+	{"password_histories", func() interface{} { return &[]model.PasswordHistory{} }},
+	{"item_versions", func() interface{} { return &[]model.ItemVersion{} }},
+	{"attachments", func() interface{} { return &[]model.Attachment{} }},
+	{"folders", func() interface{} { return &[]model.Folder{} }},
+	{"tags", func() interface{} { return &[]model.Tag{} }},
+}
+
+// reencryptionSystemTables are walked first by jobs of all vaults, their schema is ""
+var reencryptionSystemTables = []reencryptionTable{
+	{"users", func() interface{} { return &[]model.User{} }},
+}
+
+// reencryptionWake starts the worker right away for jobs queued by this process
+var reencryptionWake = make(chan struct{}, 1)
+
+// reencryptionRun is where the job of this process resumed, the ETA is estimated from it
+var reencryptionRun = struct {
+	sync.Mutex
+	jobID uint
+	since time.Time
+	done  int
+}{}
+
+// QueueReencryption queues a job which re-encrypts the vault of the user, or all vaults
+// and the TOTP secrets of the users when userID is 0. The worker of the primary runs the jobs one after the other.
+func QueueReencryption(s storage.Store, reason string, userID uint) (*model.ReencryptionJob, error) {
+	job, err := s.Reencryption().Save(&model.ReencryptionJob{
+		Reason: reason,
+		UserID: userID,
+		Status: model.ReencryptionQueued,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"event":   "reencryption_queued",
+		"job_id":  job.ID,
+		"reason":  reason,
+		"user_id": userID,
+	}).Info("re-encryption is queued")
+
+	select {
+	case reencryptionWake <- struct{}{}:
+	default:
+	}
+	return job, nil
+}
+
+// StartReencryptionWorker runs the queued jobs in the background. A job which was running
+// when the server stopped resumes from its cursor. Batches of reencryption.batchSize rows
+// are written in a transaction with a pause of reencryption.batchPause between them.
+func StartReencryptionWorker(s storage.Store) error {
+	if err := CheckCipher(); err != nil {
+		return err
+	}
+	batchSize := viper.GetInt("reencryption.batchSize")
+	if batchSize <= 0 {
+		return errBatchSize
+	}
+	pause, err := time.ParseDuration(viper.GetString("reencryption.batchPause"))
+	if err != nil {
+		return fmt.Errorf("reencryption.batchPause: %w", err)
+	}
+
+	goBackground(func() {
+		for {
+			ran, err := RunNextReencryption(s, batchSize, pause)
+			if err == errShuttingDown {
+				return
+			}
+			if err != nil {
+				log.WithError(err).Error("re-encryption failed")
+			}
+			if ran {
+				continue
+			}
+			select {
+			case <-background.stopping:
+				return
+			case <-reencryptionWake:
+			case <-time.After(reencryptionPoll):
+			}
+		}
+	})
+	return nil
+}
+
+// RunNextReencryption runs the oldest unfinished job, it reports whether there was one
+func RunNextReencryption(s storage.Store, batchSize int, pause time.Duration) (bool, error) {
+	jobs, err := s.Reencryption().FindUnfinished()
+	if err != nil || len(jobs) == 0 {
+		return false, err
+	}
+	return true, RunReencryption(s, &jobs[0], batchSize, pause)
+}
+
+// RunReencryption walks the rows of the vaults of the job and writes the fields which aren't
+// encrypted with the current passphrase and cipher again. Checked rows are skipped when the
+// job resumes, rows written twice after a crash are left as they are.
+func RunReencryption(s storage.Store, job *model.ReencryptionJob, batchSize int, pause time.Duration) error {
+	schemas, err := reencryptionSchemas(s, job)
+	if err != nil {
+		return failReencryption(s, job, err)
+	}
+
+	if job.Status == model.ReencryptionQueued {
+		if job.Total, err = countReencryptionRows(s, schemas); err != nil {
+			return failReencryption(s, job, err)
+		}
+		now := time.Now()
+		job.Status = model.ReencryptionRunning
+		job.StartedAt = &now
+		if job, err = s.Reencryption().Save(job); err != nil {
+			return err
+		}
+		log.WithFields(log.Fields{
+			"event":   "reencryption_started",
+			"job_id":  job.ID,
+			"reason":  job.Reason,
+			"user_id": job.UserID,
+			"rows":    job.Total,
+		}).Info("re-encryption is started")
+	}
+
+	reencryptionRun.Lock()
+	reencryptionRun.jobID, reencryptionRun.since, reencryptionRun.done = job.ID, time.Now(), job.Done
+	reencryptionRun.Unlock()
+
+	cursorSchema, cursorTable := job.Schema, reencryptionTableIndex(job.Schema, job.Table)
+	for _, schema := range schemas {
+		if schema < cursorSchema {
+			continue
+		}
+		for i, table := range tablesOf(schema) {
+			if schema == cursorSchema && i < cursorTable {
+				continue
+			}
+			if schema != job.Schema || table.name != job.Table {
+				job.Schema, job.Table, job.LastID = schema, table.name, 0
+			}
+			err := reencryptTable(s, job, table.rows, batchSize, pause)
+			if err == errShuttingDown {
+				return err
+			}
+			if err != nil {
+				return failReencryption(s, job, err)
+			}
+		}
+	}
+
+	now := time.Now()
+	job.Status = model.ReencryptionDone
+	job.FinishedAt = &now
+	if _, err := s.Reencryption().Save(job); err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"event":       "reencryption_finished",
+		"job_id":      job.ID,
+		"reason":      job.Reason,
+		"user_id":     job.UserID,
+		"rows":        job.Done,
+		"reencrypted": job.Reencrypted,
+	}).Info("re-encryption is finished")
+	return nil
+}
+
+// reencryptTable re-encrypts the table of the cursor of the job batch by batch
+func reencryptTable(s storage.Store, job *model.ReencryptionJob, newRows func() interface{}, batchSize int, pause time.Duration) error {
+	table := qualifiedTable(job.Schema, job.Table)
+	for {
+		rows := newRows()
+		if err := s.Reencryption().FindBatch(table, job.LastID, batchSize, rows); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		v := reflect.ValueOf(rows).Elem()
+		if v.Len() == 0 {
+			return nil
+		}
+
+		updates := map[uint]map[string]interface{}{}
+		for i := 0; i < v.Len(); i++ {
+			row := v.Index(i).Addr().Interface()
+			id := uint(v.Index(i).FieldByName("ID").Uint())
+			columns, err := reencryptRow(row)
+			if err != nil {
+				return fmt.Errorf("row %d of %s: %w", id, table, err)
+			}
+			if columns != nil {
+				updates[id] = columns
+			}
+			job.LastID = id
+		}
+		job.Done += v.Len()
+		job.Reencrypted += len(updates)
+
+		if err := s.Reencryption().SaveBatch(job, table, updates); err != nil {
+			return fmt.Errorf("%s: %w", table, err)
+		}
+		if v.Len() < batchSize {
+			return nil
+		}
+		// The job resumes from the saved cursor at the next start
+		if ShuttingDown() {
+			return errShuttingDown
+		}
+		time.Sleep(pause)
+	}
+}
+
+// reencryptRow returns the columns of the row which have to be encrypted again, or nil.
+// Blind indexes which don't match the plaintext, like the ones of a previous passphrase,
+// are written again too.
+func reencryptRow(row interface{}) (map[string]interface{}, error) {
+	v := reflect.ValueOf(row).Elem()
+	plain := reflect.New(v.Type()).Elem()
+	plain.Set(v)
+	var columns map[string]interface{}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Type().Field(i)
+		if tag := field.Tag.Get("encrypt"); tag != fieldcipher.TagVault && tag != fieldcipher.TagServer {
+			continue
+		}
+		value, current, err := decryptField(v.Field(i).String())
+		if err != nil {
+			return nil, err
+		}
+		plain.Field(i).SetString(value)
+		if current {
+			continue
+		}
+		if columns == nil {
+			columns = map[string]interface{}{}
+		}
+		columns[gorm.ToColumnName(field.Name)] = encryptField(value)
+	}
+
+	for name, index := range fieldcipher.Indexes(plain.Addr().Interface()) {
+		if index == v.FieldByName(name).String() {
+			continue
+		}
+		if columns == nil {
+			columns = map[string]interface{}{}
+		}
+		columns[gorm.ToColumnName(name)] = index
+	}
+	return columns, nil
+}
+
+// failReencryption saves the error of the job, a new job has to be queued after fixing it
+func failReencryption(s storage.Store, job *model.ReencryptionJob, err error) error {
+	now := time.Now()
+	job.Status = model.ReencryptionFailed
+	job.Error = err.Error()
+	job.FinishedAt = &now
+	if _, saveErr := s.Reencryption().Save(job); saveErr != nil {
+		return saveErr
+	}
+
+	log.WithFields(log.Fields{
+		"event":   "reencryption_failed",
+		"job_id":  job.ID,
+		"reason":  job.Reason,
+		"user_id": job.UserID,
+		"error":   err.Error(),
+	}).Error("re-encryption failed")
+	return err
+}
+
+// reencryptionSchemas returns the vaults of the job in the order they are walked
+func reencryptionSchemas(s storage.Store, job *model.ReencryptionJob) ([]string, error) {
+	var schemas []string
+	if job.UserID == 0 {
+		var err error
+		if schemas, err = vaultSchemas(s); err != nil {
+			return nil, err
+		}
+		schemas = append(schemas, "")
+	} else {
+		user, err := s.Users().FindByID(job.UserID)
+		if err != nil {
+			return nil, err
+		}
+		schemas = []string{user.Schema}
+		if user.DuressPassword != "" {
+			schemas = append(schemas, DecoySchema(user.Schema))
+		}
+	}
+	sort.Strings(schemas)
+	return schemas, nil
+}
+
+func countReencryptionRows(s storage.Store, schemas []string) (int, error) {
+	total := 0
+	for _, schema := range schemas {
+		for _, table := range tablesOf(schema) {
+			count, err := s.Reencryption().CountRows(qualifiedTable(schema, table.name))
+			if err != nil {
+				return 0, err
+			}
+			total += count
+		}
+	}
+	return total, nil
+}
+
+func reencryptionTableIndex(schema, name string) int {
+	for i, table := range tablesOf(schema) {
+		if table.name == name {
+			return i
+		}
+	}
+	return 0
+}
+
+// tablesOf returns the tables of the schema, the system ones for "". Vaults of the
+// zero-knowledge mode have nothing the server encrypted.
+func tablesOf(schema string) []reencryptionTable {
+	if schema == "" {
+		return reencryptionSystemTables
+	}
+	if ZeroKnowledge() {
+		return nil
+	}
+	return reencryptionTables
+}
+
+func qualifiedTable(schema, table string) string {
+	if schema == "" {
+		return table
+	}
+	return schema + "." + table
+}
+
+// ReencryptionStatus returns the progress of the recent jobs with the ETA of the running one
+func ReencryptionStatus(s storage.Store, now time.Time) (*model.ReencryptionStatusDTO, error) {
+	jobs, err := s.Reencryption().FindRecent(reencryptionRecent)
+	if err != nil {
+		return nil, err
+	}
+
+	status := &model.ReencryptionStatusDTO{Cipher: viper.GetString("server.cipher"), Jobs: []model.ReencryptionJobDTO{}}
+	if status.Cipher == "" {
+		status.Cipher = CipherV1
+	}
+	for i := range jobs {
+		dto := model.ToReencryptionJobDTO(&jobs[i])
+		if jobs[i].Status == model.ReencryptionRunning {
+			status.Running = true
+			dto.ETA = reencryptionETA(&jobs[i], now)
+		}
+		status.Jobs = append(status.Jobs, *dto)
+	}
+	return status, nil
+}
+
+// reencryptionETA estimates the end of the job from its rate since it resumed in this process,
+// or since it started when another process runs it
+func reencryptionETA(job *model.ReencryptionJob, now time.Time) *time.Time {
+	if job.StartedAt == nil || job.Done >= job.Total {
+		return nil
+	}
+	since, done := *job.StartedAt, 0
+
+	reencryptionRun.Lock()
+	if reencryptionRun.jobID == job.ID {
+		since, done = reencryptionRun.since, reencryptionRun.done
+	}
+	reencryptionRun.Unlock()
+
+	rate := float64(job.Done-done) / now.Sub(since).Seconds()
+	if rate <= 0 {
+		return nil
+	}
+	eta := now.Add(time.Duration(float64(job.Total-job.Done) / rate * float64(time.Second)))
+	return &eta
+}
@@ -0,0 +1,23 @@
+package app
+
+import (
+	"github.com/passwall/passwall-server/internal/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ReloadConfig applies the reloadable settings of the configuration file, the sessions
+// stay valid. It returns the keys of the changed settings.
+func ReloadConfig() ([]string, error) {
+	changed, err := config.Reload()
+	if err != nil {
+		log.WithError(err).Error("configuration couldn't be reloaded")
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"event": "config_reloaded",
+		"keys":  changed,
+	}).Info("configuration is reloaded")
+	return changed, nil
+}
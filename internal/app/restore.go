@@ -0,0 +1,418 @@
+package app
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// Modes of restores
+const (
+	// RestoreMerge keeps the vault and adds the backup to it
+	RestoreMerge = "merge"
+	// RestoreWipe empties the vault before the backup is restored
+	RestoreWipe = "wipe"
+)
+
+var (
+	// ErrRestoreSource is returned when a restore names neither a backup nor uploads a file,
+	// or both
+	ErrRestoreSource = errors.New("restore needs the name of a backup or the content of a backup file")
+	// ErrRestoreFile is returned for backup files which can't be opened
+	ErrRestoreFile = errors.New("backup file could not be opened, check the passphrase")
+)
+
+// BackupArchive is the content of a backup file or an export archive, the items are kept
+// as JSON of their type until they are restored
+type BackupArchive struct {
+	Version         int                         `json:"version"`
+	Folders         []*model.FolderDTO          `json:"folders"`
+	Tags            []*model.TagDTO             `json:"tags"`
+	Items           map[string]json.RawMessage  `json:"items"`
+	PasswordHistory []*model.PasswordHistoryDTO `json:"password_history"`
+}
+
+// OpenRestoreArchive opens the backup of the restore into the vault in schema. Backups of
+// the server are named and opened with the server passphrase, only the backups of the
+// vault itself can be restored. Uploaded files are base64 content and need their passphrase.
+func OpenRestoreArchive(dto *model.RestoreDTO, schema string) (*BackupArchive, error) {
+	var data []byte
+	passphrase := dto.Passphrase
+	switch {
+	case dto.Name != "" && dto.Content == "":
+		if backupSchema, _ := parseBackupName(dto.Name, time.Time{}); backupSchema != schema {
+			return nil, ErrBackupNotFound
+		}
+		var err error
+		if data, err = ReadBackup(dto.Name); err != nil {
+			return nil, err
+		}
+		passphrase = viper.GetString("server.passphrase")
+	case dto.Content != "" && dto.Name == "":
+		var err error
+		if data, err = base64.StdEncoding.DecodeString(strings.TrimSpace(dto.Content)); err != nil {
+			return nil, ErrRestoreFile
+		}
+	default:
+		return nil, ErrRestoreSource
+	}
+
+	archive, err := OpenBackupArchive(data, passphrase)
+	if err != nil {
+		return nil, ErrRestoreFile
+	}
+	return archive, nil
+}
+
+// OpenBackupArchive decrypts a backup file or an export archive with the passphrase.
+// Backups of older versions are lists of logins encrypted with the server passphrase,
+// their logins are the items of the archive.
+func OpenBackupArchive(data []byte, passphrase string) (*BackupArchive, error) {
+	archive := &BackupArchive{}
+	if bytes.HasPrefix(data, exportMagic) {
+		content, err := OpenExportArchive(data, passphrase)
+		if err != nil {
+			return nil, errBackupDecrypt
+		}
+		if err := json.Unmarshal(content, archive); err != nil {
+			return nil, err
+		}
+		return archive, nil
+	}
+
+	content, err := decryptBackup(data, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	var logins []json.RawMessage
+	if err := json.Unmarshal(content, &logins); err != nil {
+		return nil, errors.New("backup content is not a login list")
+	}
+	archive.Items = map[string]json.RawMessage{LoginItem: content}
+	return archive, nil
+}
+
+// decryptBackup is Decrypt without panics on a wrong passphrase or a broken file
+func decryptBackup(data []byte, passphrase string) (content []byte, err error) {
+	defer func() {
+		if recover() != nil {
+			content, err = nil, errBackupDecrypt
+		}
+	}()
+	return Decrypt(string(data), passphrase), nil
+}
+
+// items returns the JSON of the items of the type
+func (a *BackupArchive) items(itemType string) ([]json.RawMessage, error) {
+	rows := []json.RawMessage{}
+	if raw, ok := a.Items[itemType]; ok && string(raw) != "null" {
+		if err := json.Unmarshal(raw, &rows); err != nil {
+			return nil, err
+		}
+	}
+	return rows, nil
+}
+
+// RestoreBackup restores the folders, tags and items of the archive into the vault in
+// schema. Merging reuses the folders and tags of the vault with the same name and skips
+// the items which are in the vault already. Wiping moves the items of the vault to the
+// trash and deletes its folders and tags first. The password history of the restored
+// logins is restored with them. The restore is a single transaction, so
+// nothing is changed when it fails, and a dry run only reports what it would do.
+func RestoreBackup(s storage.Store, archive *BackupArchive, mode string, dryRun bool, schema string) (*model.RestoreReportDTO, error) {
+	defer tracing.Start("app.RestoreBackup").End()
+
+	if mode == "" {
+		mode = RestoreMerge
+	}
+	report := &model.RestoreReportDTO{
+		Mode:     mode,
+		DryRun:   dryRun,
+		Deleted:  model.RestoreCountDTO{Items: map[string]int{}},
+		Restored: model.RestoreCountDTO{Items: map[string]int{}},
+		Skipped:  []model.ImportSkippedDTO{},
+	}
+
+	err := s.Transaction(func(tx storage.Store) error {
+		r := &restore{tx: tx, schema: schema, dryRun: dryRun, report: report, existing: map[string]bool{}, loginIDs: map[uint]uint{}}
+		if mode == RestoreWipe {
+			if err := r.wipe(); err != nil {
+				return err
+			}
+		} else if err := r.loadVault(); err != nil {
+			return err
+		}
+
+		folderIDs, err := r.folders(archive.Folders)
+		if err != nil {
+			return err
+		}
+		tagIDs, err := r.tags(archive.Tags)
+		if err != nil {
+			return err
+		}
+
+		entry := 0
+		for _, itemType := range ItemTypes {
+			rows, err := archive.items(itemType)
+			if err != nil {
+				return err
+			}
+			for _, row := range rows {
+				entry++
+				var titled struct {
+					Title string `json:"title"`
+				}
+				json.Unmarshal(row, &titled)
+				dto, err := decodeItemDTO(itemType, row)
+				if err != nil {
+					report.Skipped = append(report.Skipped, model.ImportSkippedDTO{Entry: entry, Title: titled.Title, Reason: err.Error()})
+					continue
+				}
+				skipped := model.ImportSkippedDTO{Entry: entry, Title: titled.Title, Reason: "item is in the vault already"}
+				if err := r.item(itemType, dto, folderIDs, tagIDs, skipped); err != nil {
+					return err
+				}
+			}
+		}
+		return r.passwordHistory(archive.PasswordHistory)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		log.WithFields(log.Fields{
+			"event":    "restore",
+			"schema":   schema,
+			"mode":     mode,
+			"deleted":  report.Deleted.Items,
+			"restored": report.Restored.Items,
+		}).Warn("backup is restored")
+	}
+	return report, nil
+}
+
+// restore is a restore in a transaction, existing has the fingerprints of the items of
+// the vault when merging
+type restore struct {
+	tx     storage.Store
+	schema string
+	dryRun bool
+	report *model.RestoreReportDTO
+	// Ids of the folders and tags of the vault by their names
+	folderIDs map[string]uint
+	tagIDs    map[string]uint
+	existing  map[string]bool
+	// Ids of the restored logins by their ids in the archive
+	loginIDs map[uint]uint
+}
+
+// wipe moves the items of the vault to the trash and deletes its folders and tags
+func (r *restore) wipe() error {
+	r.folderIDs, r.tagIDs = map[string]uint{}, map[string]uint{}
+	for _, itemType := range ItemTypes {
+		items, err := AllItems(r.tx, itemType, r.schema)
+		if err != nil {
+			return err
+		}
+		v := reflect.ValueOf(items)
+		for i := 0; i < v.Len(); i++ {
+			if !r.dryRun {
+				if err := deleteItem(r.tx, v.Index(i).Addr().Interface(), r.schema); err != nil {
+					return err
+				}
+			}
+			r.report.Deleted.Items[itemType]++
+		}
+	}
+
+	folders, err := r.tx.Folders().All(r.schema)
+	if err != nil {
+		return err
+	}
+	for i := range folders {
+		if !r.dryRun {
+			if err := DeleteFolder(r.tx, &folders[i], r.schema); err != nil {
+				return err
+			}
+		}
+		r.report.Deleted.Folders++
+	}
+	tags, err := r.tx.Tags().All(r.schema)
+	if err != nil {
+		return err
+	}
+	for i := range tags {
+		if !r.dryRun {
+			if err := r.tx.Tags().Delete(tags[i].ID, r.schema); err != nil {
+				return err
+			}
+		}
+		r.report.Deleted.Tags++
+	}
+	return nil
+}
+
+// loadVault reads the folders, tags and item fingerprints of the vault for a merge
+func (r *restore) loadVault() error {
+	r.folderIDs, r.tagIDs = map[string]uint{}, map[string]uint{}
+	folders, err := r.tx.Folders().All(r.schema)
+	if err != nil {
+		return err
+	}
+	for i := range folders {
+		r.folderIDs[folders[i].Name] = folders[i].ID
+	}
+	tags, err := r.tx.Tags().All(r.schema)
+	if err != nil {
+		return err
+	}
+	for i := range tags {
+		r.tagIDs[tags[i].Name] = tags[i].ID
+	}
+
+	for _, itemType := range ItemTypes {
+		items, err := AllItems(r.tx, itemType, r.schema)
+		if err != nil {
+			return err
+		}
+		if err := LoadItemTags(r.tx, items, r.schema); err != nil {
+			return err
+		}
+		for _, dto := range itemDTOs(items) {
+			r.existing[itemType+":"+itemFingerprint(dto)] = true
+		}
+	}
+	return nil
+}
+
+// folders returns the ids of the vault for the folder ids of the archive, folders which
+// the vault doesn't have are created
+func (r *restore) folders(dtos []*model.FolderDTO) (map[uint]uint, error) {
+	ids := map[uint]uint{}
+	for _, dto := range dtos {
+		if dto == nil || dto.Name == "" {
+			continue
+		}
+		if id, ok := r.folderIDs[dto.Name]; ok {
+			ids[dto.ID] = id
+			continue
+		}
+		// New folders of a dry run get ids which the vault can't have
+		id := ^uint(0) - uint(len(r.folderIDs))
+		if !r.dryRun {
+			created, err := r.tx.Folders().Save(&model.Folder{Name: dto.Name}, r.schema)
+			if err != nil {
+				return nil, err
+			}
+			id = created.ID
+		}
+		r.folderIDs[dto.Name] = id
+		ids[dto.ID] = id
+		r.report.Restored.Folders++
+	}
+	return ids, nil
+}
+
+// tags returns the ids of the vault for the tag ids of the archive like folders does
+func (r *restore) tags(dtos []*model.TagDTO) (map[uint]uint, error) {
+	ids := map[uint]uint{}
+	for _, dto := range dtos {
+		if dto == nil || dto.Name == "" {
+			continue
+		}
+		if id, ok := r.tagIDs[dto.Name]; ok {
+			ids[dto.ID] = id
+			continue
+		}
+		id := ^uint(0) - uint(len(r.tagIDs))
+		if !r.dryRun {
+			created, err := CreateTag(r.tx, dto, r.schema)
+			if err != nil {
+				return nil, err
+			}
+			id = created.ID
+		}
+		r.tagIDs[dto.Name] = id
+		ids[dto.ID] = id
+		r.report.Restored.Tags++
+	}
+	return ids, nil
+}
+
+// item creates the item of the DTO pointer in the folder and with the tags of the vault,
+// unless the vault has the same item, then it is skipped
+func (r *restore) item(itemType string, dto interface{}, folderIDs, tagIDs map[uint]uint, skipped model.ImportSkippedDTO) error {
+	v := reflect.ValueOf(dto).Elem()
+	v.FieldByName("FolderID").SetUint(uint64(folderIDs[uint(v.FieldByName("FolderID").Uint())]))
+	tags := []uint{}
+	for _, id := range v.FieldByName("Tags").Interface().([]uint) {
+		if tagID, ok := tagIDs[id]; ok {
+			tags = append(tags, tagID)
+		}
+	}
+	v.FieldByName("Tags").Set(reflect.ValueOf(tags))
+
+	if r.existing[itemType+":"+itemFingerprint(dto)] {
+		r.report.Skipped = append(r.report.Skipped, skipped)
+		return nil
+	}
+	if !r.dryRun {
+		id := uint(v.FieldByName("ID").Uint())
+		created, err := createItem(r.tx, dto, r.schema)
+		if err != nil {
+			return err
+		}
+		if login, ok := created.(*model.Login); ok {
+			r.loginIDs[id] = login.ID
+		}
+	}
+	r.report.Restored.Items[itemType]++
+	return nil
+}
+
+// passwordHistory restores the previous passwords of the restored logins with the time
+// they were changed, the history of skipped logins is in the vault already
+func (r *restore) passwordHistory(dtos []*model.PasswordHistoryDTO) error {
+	for _, dto := range dtos {
+		if dto == nil {
+			continue
+		}
+		loginID, ok := r.loginIDs[dto.LoginID]
+		if !ok {
+			continue
+		}
+		history := &model.PasswordHistory{CreatedAt: dto.ChangedAt, LoginID: loginID, Password: dto.Password}
+		if _, err := r.tx.PasswordHistories().Save(history, r.schema); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// itemFingerprint returns the JSON of the DTO pointer without its id and revision, items
+// with the same fingerprint have the same fields, folder and tags
+func itemFingerprint(dto interface{}) string {
+	v := reflect.New(reflect.TypeOf(dto).Elem()).Elem()
+	v.Set(reflect.ValueOf(dto).Elem())
+	v.FieldByName("ID").SetUint(0)
+	v.FieldByName("Revision").SetUint(0)
+	tags := append([]uint{}, v.FieldByName("Tags").Interface().([]uint)...)
+	sort.Slice(tags, func(i, j int) bool { return tags[i] < tags[j] })
+	v.FieldByName("Tags").Set(reflect.ValueOf(tags))
+
+	data, _ := json.Marshal(v.Interface())
+	return string(data)
+}
@@ -0,0 +1,197 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+var (
+	// trashTables keep the items of a vault, deleted items stay in them until they are
+	// purged. They're in the order of ItemTypes.
+	trashTables = []string{"logins", "credit_cards", "bank_accounts", "notes", "emails", "servers"}
+	// tombstoneTables are the other tables of a vault with soft deleted rows
+	tombstoneTables = []string{"password_histories", "equivalent_domains"}
+	// systemTombstoneTables are the system tables with soft deleted rows
+	systemTombstoneTables = []string{"users", "machine_accounts", "subscriptions", "public.equivalent_domains"}
+)
+
+// PurgeExpiredData permanently deletes the data which is older than the retention periods of
+// the server policy: deleted items, audit entries, soft deleted records and expired sessions.
+// A dry run reports the rows without deleting them. Empty periods keep the data forever.
+func PurgeExpiredData(s storage.Store, now time.Time, dryRun bool) (*model.RetentionReportDTO, error) {
+	policy, err := FindPolicy(s, ServerPolicyID)
+	if err != nil {
+		return nil, err
+	}
+	schemas, err := vaultSchemas(s)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &model.RetentionReportDTO{
+		DryRun:     dryRun,
+		Trash:      retentionCount(policy.TrashRetention, now),
+		AuditLogs:  retentionCount(policy.AuditRetention, now),
+		Tombstones: retentionCount(policy.TombstoneRetention, now),
+		Sessions:   retentionCount(policy.SessionRetention, now),
+	}
+
+	tombstones := append([]string{}, systemTombstoneTables...)
+	for _, schema := range schemas {
+		for _, table := range tombstoneTables {
+			tombstones = append(tombstones, schema+"."+table)
+		}
+	}
+
+	if err := purgeTrash(s, &report.Trash, schemas, dryRun); err != nil {
+		return nil, fmt.Errorf("trash: %w", err)
+	}
+	if err := purgeRows(s, &report.Tombstones, tombstones, "deleted_at", dryRun); err != nil {
+		return nil, fmt.Errorf("tombstones: %w", err)
+	}
+	if err := purgeRows(s, &report.Sessions, []string{"tokens"}, "expiry_time", dryRun); err != nil {
+		return nil, fmt.Errorf("sessions: %w", err)
+	}
+	if report.AuditLogs.Before != nil {
+		if report.AuditLogs.Rows, err = purgeAuditLog(s, *report.AuditLogs.Before, dryRun); err != nil {
+			return nil, fmt.Errorf("audit logs: %w", err)
+		}
+	}
+
+	if !dryRun {
+		log.WithFields(log.Fields{
+			"event":      "retention_purge",
+			"trash":      report.Trash.Rows,
+			"audit_logs": report.AuditLogs.Rows,
+			"tombstones": report.Tombstones.Rows,
+			"sessions":   report.Sessions.Rows,
+		}).Info("expired data is purged")
+	}
+	return report, nil
+}
+
+// StartRetentionJob purges the expired data and the superseded refresh tokens every retention.period
+func StartRetentionJob(s storage.Store) error {
+	period, err := parsePeriod(viper.GetString("retention.period"))
+	if err != nil {
+		return fmt.Errorf("retention.period: %w", err)
+	}
+
+	every(period, func(now time.Time) {
+		if _, err := PurgeExpiredData(s, now, false); err != nil {
+			log.WithError(err).Error("expired data couldn't be purged")
+		}
+		if _, err := PurgeSupersededTokens(s, now); err != nil {
+			log.WithError(err).Error("superseded tokens couldn't be purged")
+		}
+		if _, err := PurgeSigninFailures(s, now); err != nil {
+			log.WithError(err).Error("failed sign ins couldn't be purged")
+		}
+	})
+	return nil
+}
+
+// retentionCount returns the count of the period with the time its data has to be newer than
+func retentionCount(retention string, now time.Time) model.RetentionCount {
+	count := model.RetentionCount{Retention: retention}
+	if period, err := parsePeriod(retention); err == nil {
+		before := now.Add(-period)
+		count.Before = &before
+	}
+	return count
+}
+
+// purgeRows deletes the rows of the tables whose column is before the time of the count
+func purgeRows(s storage.Store, count *model.RetentionCount, tables []string, column string, dryRun bool) error {
+	if count.Before == nil {
+		return nil
+	}
+	for _, table := range tables {
+		var rows int
+		var err error
+		if dryRun {
+			rows, err = s.Retention().CountBefore(table, column, *count.Before)
+		} else {
+			rows, err = s.Retention().DeleteBefore(table, column, *count.Before)
+		}
+		if err != nil {
+			return err
+		}
+		count.Rows += rows
+	}
+	return nil
+}
+
+// purgeTrash deletes the items of the vaults which were deleted before the time of the
+// count. Syncs since before the purge of a vault start over, they can't send the deletions.
+func purgeTrash(s storage.Store, count *model.RetentionCount, schemas []string, dryRun bool) error {
+	if count.Before == nil {
+		return nil
+	}
+	for _, schema := range schemas {
+		tables := []string{}
+		for _, table := range trashTables {
+			tables = append(tables, schema+"."+table)
+		}
+
+		expired := make([]int, len(tables))
+		if !dryRun {
+			total := 0
+			for i, table := range tables {
+				rows, err := s.Retention().CountBefore(table, "deleted_at", *count.Before)
+				if err != nil {
+					return err
+				}
+				expired[i] = rows
+				total += rows
+			}
+			if total == 0 {
+				continue
+			}
+			if err := s.SyncCounters().MarkPurged(schema); err != nil {
+				return err
+			}
+		}
+		if err := purgeRows(s, count, tables, "deleted_at", dryRun); err != nil {
+			return err
+		}
+		if !dryRun {
+			if err := purgeOrphanAttachments(s, schema); err != nil {
+				return err
+			}
+		}
+
+		// The connected sessions sync, their next sync starts over
+		for i, rows := range expired {
+			if rows > 0 {
+				PublishChange(schema, model.ChangeDTO{Type: ItemTypes[i], Operation: AuditDelete})
+			}
+		}
+	}
+	return nil
+}
+
+// vaultSchemas returns the schemas of all vaults, decoy vaults included
+func vaultSchemas(s storage.Store) ([]string, error) {
+	users, err := s.Users().All()
+	if err != nil {
+		return nil, err
+	}
+
+	schemas := []string{}
+	for i := range users {
+		if users[i].Schema == "" {
+			continue
+		}
+		schemas = append(schemas, users[i].Schema)
+		if users[i].DuressPassword != "" {
+			schemas = append(schemas, DecoySchema(users[i].Schema))
+		}
+	}
+	return schemas, nil
+}
@@ -0,0 +1,66 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPurgeExpiredDataDryRun(t *testing.T) {
+	now := time.Date(2020, 6, 30, 12, 0, 0, 0, time.UTC)
+	trashBefore := now.Add(-30 * 24 * time.Hour)
+	sessionsBefore := now.Add(-7 * 24 * time.Hour)
+
+	mocks := storagetest.NewMocks()
+	mocks.Policies.On("FindByUserID", uint(ServerPolicyID)).Return(&model.Policy{TrashRetention: "30d", SessionRetention: "7d"}, nil)
+	mocks.Users.On("All").Return([]model.User{
+		{ID: 1, Schema: "user1", DuressPassword: "hash"},
+		{ID: 2, Schema: "user2"},
+	}, nil)
+	mocks.Retention.On("CountBefore", "user1.logins", "deleted_at", trashBefore).Return(2, nil)
+	mocks.Retention.On("CountBefore", "user1_decoy.notes", "deleted_at", trashBefore).Return(1, nil)
+	mocks.Retention.On("CountBefore", "tokens", "expiry_time", sessionsBefore).Return(5, nil)
+	mocks.Retention.On("CountBefore", mock.Anything, "deleted_at", trashBefore).Return(0, nil)
+
+	report, err := PurgeExpiredData(mocks.Store, now, true)
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 3, report.Trash.Rows)
+	assert.Equal(t, &trashBefore, report.Trash.Before)
+	assert.Equal(t, 5, report.Sessions.Rows)
+
+	// Empty periods keep the data forever
+	assert.Nil(t, report.Tombstones.Before)
+	assert.Nil(t, report.AuditLogs.Before)
+	mocks.Retention.AssertNotCalled(t, "DeleteBefore", mock.Anything, mock.Anything, mock.Anything)
+	mocks.Retention.AssertNumberOfCalls(t, "CountBefore", 3*len(trashTables)+1)
+}
+
+func TestPurgeAuditLog(t *testing.T) {
+	entries := auditChain(4)
+	before := entries[2].CreatedAt
+
+	mocks := storagetest.NewMocks()
+	mocks.AuditLogs.On("LastBefore", before).Return(&entries[1], nil)
+	mocks.AuditLogs.On("CountUntil", uint(2)).Return(2, nil)
+	mocks.AuditLogs.On("CreateCheckpoint", mock.MatchedBy(func(anchor *model.AuditCheckpoint) bool {
+		return anchor.Anchor && anchor.AuditLogID == 2 && anchor.Hash == entries[1].Hash && anchor.Signature == auditSignature(anchor)
+	})).Return(&model.AuditCheckpoint{}, nil)
+	mocks.AuditLogs.On("DeleteUntil", uint(2)).Return(nil)
+
+	count, err := purgeAuditLog(mocks.Store, before, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+	mocks.AssertExpectations(t)
+
+	mocks = storagetest.NewMocks()
+	mocks.AuditLogs.On("LastBefore", before).Return(nil, gorm.ErrRecordNotFound)
+	count, err = purgeAuditLog(mocks.Store, before, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, count)
+}
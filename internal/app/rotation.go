@@ -0,0 +1,170 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/rotation"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const rotationTimeout = time.Minute
+
+var (
+	// ErrNoRotationProvider is returned when a login without a provider is rotated
+	ErrNoRotationProvider = errors.New("login has no rotation provider")
+)
+
+// ValidateRotation checks the rotation settings of the login
+func ValidateRotation(dto *model.LoginDTO) error {
+	if dto.RotationProvider == "" {
+		if dto.RotationPeriod != "" {
+			return ErrNoRotationProvider
+		}
+		return nil
+	}
+	if ZeroKnowledge() {
+		return ErrZeroKnowledge
+	}
+
+	if _, err := rotation.Find(dto.RotationProvider); err != nil {
+		return err
+	}
+
+	if dto.RotationPeriod != "" {
+		if _, err := parsePeriod(dto.RotationPeriod); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RotateLogin replaces the credential of the login with its rotation provider
+// and saves the new credential. The previous password is kept in the password history.
+func RotateLogin(s storage.Store, login *model.Login, schema string) (*model.Login, error) {
+	defer tracing.Start("app.RotateLogin").End()
+
+	if login.RotationProvider == "" {
+		return nil, ErrNoRotationProvider
+	}
+	if ZeroKnowledge() {
+		return nil, ErrZeroKnowledge
+	}
+
+	provider, err := rotation.Find(login.RotationProvider)
+	if err != nil {
+		return nil, err
+	}
+
+	current := *login
+
+	ctx, cancel := context.WithTimeout(context.Background(), rotationTimeout)
+	defer cancel()
+
+	rotated, err := provider.Rotate(ctx, &rotation.Credential{
+		URL:      current.URL,
+		Username: current.Username,
+		Password: current.Password,
+		Extra:    current.Extra,
+	})
+	var revokeErr *rotation.RevokeError
+	if errors.As(err, &revokeErr) && rotated != nil {
+		// The new credential works and the old one still does, it's saved so it isn't lost
+		log.WithError(err).WithFields(log.Fields{"login_id": login.ID, "schema": schema}).Warn("login is rotated but its old credential isn't revoked")
+	} else if err != nil {
+		return nil, err
+	}
+
+	if err := savePasswordHistory(s, login, rotated.Password, schema); err != nil {
+		return nil, err
+	}
+
+	current.URL = rotated.URL
+	current.Username = rotated.Username
+	current.Password = rotated.Password
+	current.Extra = rotated.Extra
+	now := time.Now()
+	current.RotatedAt = &now
+
+	updatedLogin, err := s.Logins().Save(&current, schema)
+	if err != nil {
+		// The old credential doesn't work anymore, so this has to be noticed
+		log.WithError(err).WithFields(log.Fields{"login_id": login.ID, "schema": schema}).Error("login is rotated but the new credential isn't saved")
+		return nil, err
+	}
+
+	return updatedLogin, nil
+}
+
+// RotateDueLogins rotates the logins of all users whose rotation period has passed
+func RotateDueLogins(s storage.Store) {
+	defer tracing.Start("app.RotateDueLogins").End()
+
+	users, err := s.Users().All()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+
+	for _, user := range users {
+		logins, err := s.Logins().All(user.Schema)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+
+		for i := range logins {
+			if !rotationDue(&logins[i], time.Now()) {
+				continue
+			}
+			if _, err := RotateLogin(s, &logins[i], user.Schema); err != nil {
+				log.WithError(err).WithFields(log.Fields{"login_id": logins[i].ID, "schema": user.Schema}).Error("login couldn't be rotated")
+				continue
+			}
+			// Rotations of requests are pushed by the audit middleware, the ones of the job here
+			PublishChange(user.Schema, model.ChangeDTO{Type: LoginItem, ID: logins[i].ID, Operation: AuditUpdate})
+		}
+	}
+}
+
+// StartRotationJob runs RotateDueLogins every rotation.period in the background,
+// it doesn't run in zero-knowledge mode
+func StartRotationJob(s storage.Store) error {
+	if ZeroKnowledge() {
+		return nil
+	}
+	period, err := parsePeriod(viper.GetString("rotation.period"))
+	if err != nil {
+		return fmt.Errorf("rotation.period: %w", err)
+	}
+
+	every(period, func(time.Time) {
+		RotateDueLogins(s)
+	})
+	return nil
+}
+
+// rotationDue reports whether the rotation period of the login has passed
+// since its last rotation, or since its creation if it was never rotated
+func rotationDue(login *model.Login, now time.Time) bool {
+	if login.RotationProvider == "" || login.RotationPeriod == "" {
+		return false
+	}
+
+	period, err := parsePeriod(login.RotationPeriod)
+	if err != nil {
+		return false
+	}
+
+	last := login.CreatedAt
+	if login.RotatedAt != nil {
+		last = *login.RotatedAt
+	}
+	return !now.Before(last.Add(period))
+}
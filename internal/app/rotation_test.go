@@ -0,0 +1,94 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/rotation"
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type fakeProvider struct{}
+
+func (fakeProvider) Rotate(ctx context.Context, c *rotation.Credential) (*rotation.Credential, error) {
+	rotated := *c
+	rotated.Password = c.Password + "-rotated"
+	return &rotated, nil
+}
+
+func TestRotateLogin(t *testing.T) {
+	rotation.Register("fake", fakeProvider{})
+
+	// The store encrypts the fields, the app only sees plaintext
+	login := &model.Login{ID: 5, Username: "deploy", Password: "hunter2", RotationProvider: "fake"}
+
+	mocks := storagetest.NewMocks()
+	mocks.PasswordHistories.On("Save", mock.MatchedBy(func(h *model.PasswordHistory) bool {
+		return h.LoginID == 5 && h.Password == "hunter2"
+	}), "user1").Return(&model.PasswordHistory{}, nil)
+	var rotated *model.Login
+	mocks.Logins.On("Save", mock.AnythingOfType("*model.Login"), "user1").Run(func(args mock.Arguments) {
+		rotated = args.Get(0).(*model.Login)
+	}).Return(&model.Login{ID: 5}, nil)
+
+	_, err := RotateLogin(mocks.Store, login, "user1")
+	assert.NoError(t, err)
+	assert.NotNil(t, rotated.RotatedAt)
+
+	assert.Equal(t, "deploy", rotated.Username)
+	assert.Equal(t, "hunter2-rotated", rotated.Password)
+	mocks.AssertExpectations(t)
+
+	_, err = RotateLogin(mocks.Store, &model.Login{}, "user1")
+	assert.Equal(t, ErrNoRotationProvider, err)
+}
+
+// revokeFailingProvider rotates the credential but can't revoke the old one
+type revokeFailingProvider struct{}
+
+func (revokeFailingProvider) Rotate(ctx context.Context, c *rotation.Credential) (*rotation.Credential, error) {
+	rotated := *c
+	rotated.Username = "AKIANEW"
+	rotated.Password = "new-secret"
+	return &rotated, &rotation.RevokeError{Err: errors.New("old key isn't deleted")}
+}
+
+func TestRotateLoginRevokeFails(t *testing.T) {
+	rotation.Register("revoke-failing", revokeFailingProvider{})
+
+	mocks := storagetest.NewMocks()
+	mocks.PasswordHistories.On("Save", mock.AnythingOfType("*model.PasswordHistory"), "user1").Return(&model.PasswordHistory{}, nil)
+	var rotated *model.Login
+	mocks.Logins.On("Save", mock.AnythingOfType("*model.Login"), "user1").Run(func(args mock.Arguments) {
+		rotated = args.Get(0).(*model.Login)
+	}).Return(&model.Login{ID: 5}, nil)
+
+	// The new credential is saved, it's the one which works
+	_, err := RotateLogin(mocks.Store, &model.Login{ID: 5, Username: "AKIAOLD", Password: "old-secret", RotationProvider: "revoke-failing"}, "user1")
+	assert.NoError(t, err)
+	assert.Equal(t, "AKIANEW", rotated.Username)
+	mocks.AssertExpectations(t)
+}
+
+func TestRotationDue(t *testing.T) {
+	now := time.Now()
+	rotatedAt := now.Add(-2 * time.Hour)
+
+	assert.False(t, rotationDue(&model.Login{RotationProvider: "fake"}, now))
+	assert.True(t, rotationDue(&model.Login{RotationProvider: "fake", RotationPeriod: "1d", CreatedAt: now.Add(-25 * time.Hour)}, now))
+	assert.False(t, rotationDue(&model.Login{RotationProvider: "fake", RotationPeriod: "1d", CreatedAt: now.Add(-25 * time.Hour), RotatedAt: &rotatedAt}, now))
+	assert.True(t, rotationDue(&model.Login{RotationProvider: "fake", RotationPeriod: "90m", RotatedAt: &rotatedAt}, now))
+}
+
+func TestValidateRotation(t *testing.T) {
+	assert.NoError(t, ValidateRotation(&model.LoginDTO{}))
+	assert.NoError(t, ValidateRotation(&model.LoginDTO{RotationProvider: "aws-iam", RotationPeriod: "30d"}))
+	assert.Equal(t, ErrNoRotationProvider, ValidateRotation(&model.LoginDTO{RotationPeriod: "30d"}))
+	assert.Equal(t, rotation.ErrUnknownProvider, ValidateRotation(&model.LoginDTO{RotationProvider: "ftp"}))
+	assert.Equal(t, errPeriod, ValidateRotation(&model.LoginDTO{RotationProvider: "postgres", RotationPeriod: "monthly"}))
+}
@@ -0,0 +1,270 @@
+package app
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app/saml"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const samlCodeDuration = time.Minute
+
+var (
+	// ErrSAMLResponse is returned for responses of the identity provider which don't verify
+	ErrSAMLResponse = errors.New("Identity provider couldn't sign in the user")
+
+	errSAMLUnknownProvider = errors.New("unknown SAML identity provider")
+
+	// samlRequests wait for the response of the identity provider, each can be answered once
+	samlRequests = struct {
+		sync.Mutex
+		m map[string]*samlRequest
+	}{m: map[string]*samlRequest{}}
+
+	// samlCodes are given to the client for the verified user, each can be used once
+	samlCodes = struct {
+		sync.Mutex
+		m map[string]*samlCode
+	}{m: map[string]*samlCode{}}
+)
+
+// samlRequest is an authentication request sent to the identity provider
+type samlRequest struct {
+	provider string
+	state    string
+	expires  time.Time
+}
+
+// samlCode finishes the sign in of the user the identity provider asserted
+type samlCode struct {
+	provider string
+	state    string
+	userID   uint
+	expires  time.Time
+}
+
+// BeginSAML returns the url of the identity provider with an authentication request,
+// the user is redirected to it. The state comes back as the relay state of the response.
+func BeginSAML(id string) (*model.SSOAuthorizationDTO, error) {
+	provider, sp, err := findSAMLProvider(id)
+	if err != nil {
+		return nil, err
+	}
+	state, err := ssoRandom()
+	if err != nil {
+		return nil, err
+	}
+	requestID, err := saml.NewID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	requestURL, err := sp.AuthnRequestURL(requestID, state, now)
+	if err != nil {
+		return nil, err
+	}
+
+	samlRequests.Lock()
+	defer samlRequests.Unlock()
+
+	// Drop requests the identity provider never answered
+	for k, request := range samlRequests.m {
+		if now.After(request.expires) {
+			delete(samlRequests.m, k)
+		}
+	}
+	samlRequests.m[requestID] = &samlRequest{provider: provider.ID, state: state, expires: now.Add(ssoStateDuration)}
+	return &model.SSOAuthorizationDTO{URL: requestURL, State: state}, nil
+}
+
+// SAMLMetadata returns the metadata of the server for the identity provider
+func SAMLMetadata(id string) ([]byte, error) {
+	_, sp, err := findSAMLProvider(id)
+	if err != nil {
+		return nil, err
+	}
+	return sp.Metadata(), nil
+}
+
+// ConsumeSAML verifies the response the identity provider posted and returns the redirect url of
+// the client with a code and the state. Only responses to requests of BeginSAML are accepted.
+func ConsumeSAML(s storage.Store, id, response, relayState string) (string, error) {
+	provider, sp, err := findSAMLProvider(id)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	assertion, err := sp.ParseResponse(response, now)
+	if err != nil {
+		log.WithError(err).WithField("provider", provider.ID).Warn("response of SAML identity provider couldn't be verified")
+		return "", ErrSAMLResponse
+	}
+
+	// Requests are answered once, so responses can't be replayed
+	samlRequests.Lock()
+	request, ok := samlRequests.m[assertion.InResponseTo]
+	delete(samlRequests.m, assertion.InResponseTo)
+	samlRequests.Unlock()
+	if !ok || request.provider != provider.ID || request.state != relayState || now.After(request.expires) {
+		return "", ErrSSOState
+	}
+
+	user, err := samlUser(s, provider, assertion)
+	if err != nil {
+		return "", err
+	}
+
+	code, err := ssoRandom()
+	if err != nil {
+		return "", err
+	}
+	samlCodes.Lock()
+	for k, c := range samlCodes.m {
+		if now.After(c.expires) {
+			delete(samlCodes.m, k)
+		}
+	}
+	samlCodes.m[code] = &samlCode{provider: provider.ID, state: request.state, userID: user.ID, expires: now.Add(samlCodeDuration)}
+	samlCodes.Unlock()
+
+	redirectURL := provider.RedirectURL
+	if strings.Contains(redirectURL, "?") {
+		redirectURL += "&"
+	} else {
+		redirectURL += "?"
+	}
+	return redirectURL + url.Values{"code": {code}, "state": {request.state}}.Encode(), nil
+}
+
+// FinishSAML returns the user of the code the client was redirected with
+func FinishSAML(s storage.Store, id string, dto *model.SSOCallbackDTO) (*model.User, error) {
+	provider, _, err := findSAMLProvider(id)
+	if err != nil {
+		return nil, err
+	}
+
+	samlCodes.Lock()
+	code, ok := samlCodes.m[dto.Code]
+	delete(samlCodes.m, dto.Code)
+	samlCodes.Unlock()
+	if !ok || code.provider != provider.ID || code.state != dto.State || time.Now().After(code.expires) {
+		return nil, ErrSSOState
+	}
+	return s.Users().FindByID(code.userID)
+}
+
+// samlUser returns the user linked to the name id of the assertion. Unlinked users are found
+// by the email attribute, and created when the provider is allowed to.
+func samlUser(s storage.Store, provider *config.SAMLProvider, assertion *saml.Assertion) (*model.User, error) {
+	identityProvider := "saml:" + provider.ID
+	if identity, err := s.SSOIdentities().FindBySubject(identityProvider, assertion.NameID); err == nil {
+		return s.Users().FindByID(identity.UserID)
+	}
+
+	emailAttributes := []string{"email", "mail", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress"}
+	if provider.EmailAttribute != "" {
+		emailAttributes = []string{provider.EmailAttribute}
+	}
+	email := strings.TrimSpace(assertion.Attribute(emailAttributes...))
+	if email == "" {
+		return nil, ErrSSOUser
+	}
+
+	// Corporate identity providers own the emails of their directory, so they're taken as verified
+	user, err := s.Users().FindByEmail(email)
+	if err != nil {
+		if !provider.CreateUsers {
+			return nil, ErrSSOUser
+		}
+		if user, err = createSAMLUser(s, provider, assertion, email); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := linkSSOIdentity(s, user, identityProvider, assertion.NameID); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// createSAMLUser sets up the user on its first sign in, the master password is random
+// until the user resets it
+func createSAMLUser(s storage.Store, provider *config.SAMLProvider, assertion *saml.Assertion, email string) (*model.User, error) {
+	nameAttributes := []string{"displayName", "name", "http://schemas.xmlsoap.org/ws/2005/05/identity/claims/name"}
+	if provider.NameAttribute != "" {
+		nameAttributes = []string{provider.NameAttribute}
+	}
+	masterPassword, err := ssoRandom()
+	if err != nil {
+		return nil, err
+	}
+	user, err := SetupUser(s, &model.UserDTO{
+		Name:            assertion.Attribute(nameAttributes...),
+		Email:           email,
+		MasterPassword:  masterPassword,
+		EmailVerifiedAt: time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.WithFields(log.Fields{
+		"event":    "sso_user_created",
+		"user_id":  user.ID,
+		"provider": "saml:" + provider.ID,
+	}).Info("user is created by the identity provider")
+	return user, nil
+}
+
+func samlProviders() ([]config.SAMLProvider, error) {
+	var providers []config.SAMLProvider
+	err := viper.UnmarshalKey("sso.saml", &providers)
+	return providers, err
+}
+
+// findSAMLProvider returns the configuration of the provider and the service provider of the server for it
+func findSAMLProvider(id string) (*config.SAMLProvider, *saml.ServiceProvider, error) {
+	providers, err := samlProviders()
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range providers {
+		if providers[i].ID != id || id == "" {
+			continue
+		}
+		provider := &providers[i]
+
+		certificate := []byte(provider.IdPCertificate)
+		if !strings.Contains(provider.IdPCertificate, "-----BEGIN") {
+			if certificate, err = ioutil.ReadFile(provider.IdPCertificate); err != nil {
+				return nil, nil, err
+			}
+		}
+		certificates, err := saml.ParseCertificates(certificate)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		base := strings.TrimSuffix(viper.GetString("server.domain"), "/") + "/auth/saml/" + url.PathEscape(provider.ID)
+		sp := &saml.ServiceProvider{
+			EntityID:        provider.EntityID,
+			ACSURL:          base + "/acs",
+			IdPEntityID:     provider.IdPEntityID,
+			IdPSSOURL:       provider.IdPSSOURL,
+			IdPCertificates: certificates,
+		}
+		if sp.EntityID == "" {
+			sp.EntityID = base + "/metadata"
+		}
+		return provider, sp, nil
+	}
+	return nil, nil, errSAMLUnknownProvider
+}
@@ -0,0 +1,143 @@
+package saml
+
+import (
+	"crypto"
+	"crypto/rsa"
+	_ "crypto/sha256" // linked for crypto.SHA256.New
+	_ "crypto/sha512" // linked for crypto.SHA512.New
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// Algorithms of XML signatures the service provider accepts, SHA-1 isn't one of them
+const (
+	algExcC14N             = "http://www.w3.org/2001/10/xml-exc-c14n#"
+	algExcC14NWithComments = "http://www.w3.org/2001/10/xml-exc-c14n#WithComments"
+	algEnveloped           = "http://www.w3.org/2000/09/xmldsig#enveloped-signature"
+	algRSASHA256           = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"
+	algRSASHA512           = "http://www.w3.org/2001/04/xmldsig-more#rsa-sha512"
+	algSHA256              = "http://www.w3.org/2001/04/xmlenc#sha256"
+	algSHA512              = "http://www.w3.org/2001/04/xmlenc#sha512"
+)
+
+var (
+	errNotSigned = errors.New("saml: element is not signed")
+	errSignature = errors.New("saml: signature is not valid")
+	errAlgorithm = errors.New("saml: signature algorithm is not supported")
+)
+
+// verifySignature checks the enveloped signature of the element with one of the certificates.
+// Only the element itself is signed then, so callers read the assertion from it and never
+// look it up again by its id.
+func verifySignature(n *node, certificates []*x509.Certificate) error {
+	signatures := n.elements(nsDSig, "Signature")
+	if len(signatures) == 0 {
+		return errNotSigned
+	}
+	if len(signatures) > 1 {
+		return errSignature
+	}
+	signature := signatures[0]
+
+	signedInfo := signature.element(nsDSig, "SignedInfo")
+	if signedInfo == nil {
+		return errSignature
+	}
+	c14n := signedInfo.element(nsDSig, "CanonicalizationMethod")
+	if c14n == nil || (c14n.attr("Algorithm") != algExcC14N && c14n.attr("Algorithm") != algExcC14NWithComments) {
+		return errAlgorithm
+	}
+
+	references := signedInfo.elements(nsDSig, "Reference")
+	if len(references) != 1 || n.attr("ID") == "" || references[0].attr("URI") != "#"+n.attr("ID") {
+		return errSignature
+	}
+	reference := references[0]
+
+	// The enveloped transform and exclusive canonicalization are the transforms of SAML
+	var inclusive []string
+	canonical := false
+	if transforms := reference.element(nsDSig, "Transforms"); transforms != nil {
+		for _, transform := range transforms.elements(nsDSig, "Transform") {
+			switch transform.attr("Algorithm") {
+			case algEnveloped:
+			case algExcC14N, algExcC14NWithComments:
+				canonical = true
+				inclusive = inclusivePrefixes(transform)
+			default:
+				return errAlgorithm
+			}
+		}
+	}
+	if !canonical {
+		return errAlgorithm
+	}
+
+	digestHash, err := hashOf(reference.element(nsDSig, "DigestMethod").attr("Algorithm"), algSHA256, algSHA512)
+	if err != nil {
+		return err
+	}
+	digestValue, err := base64.StdEncoding.DecodeString(stripSpaces(reference.element(nsDSig, "DigestValue").text()))
+	if err != nil {
+		return errSignature
+	}
+	h := digestHash.New()
+	h.Write(canonicalize(n, signature, inclusive))
+	if subtle.ConstantTimeCompare(h.Sum(nil), digestValue) != 1 {
+		return errSignature
+	}
+
+	signatureHash, err := hashOf(signedInfo.element(nsDSig, "SignatureMethod").attr("Algorithm"), algRSASHA256, algRSASHA512)
+	if err != nil {
+		return err
+	}
+	signatureValue, err := base64.StdEncoding.DecodeString(stripSpaces(signature.element(nsDSig, "SignatureValue").text()))
+	if err != nil {
+		return errSignature
+	}
+	h = signatureHash.New()
+	h.Write(canonicalize(signedInfo, nil, inclusivePrefixes(c14n)))
+	digest := h.Sum(nil)
+
+	for _, certificate := range certificates {
+		key, ok := certificate.PublicKey.(*rsa.PublicKey)
+		if ok && rsa.VerifyPKCS1v15(key, signatureHash, digest, signatureValue) == nil {
+			return nil
+		}
+	}
+	return errSignature
+}
+
+// hashOf returns the hash of the algorithm when it's the SHA-256 or SHA-512 one
+func hashOf(algorithm, sha256Algorithm, sha512Algorithm string) (crypto.Hash, error) {
+	switch algorithm {
+	case sha256Algorithm:
+		return crypto.SHA256, nil
+	case sha512Algorithm:
+		return crypto.SHA512, nil
+	}
+	return 0, errAlgorithm
+}
+
+// inclusivePrefixes returns the PrefixList of the InclusiveNamespaces of a canonicalization
+func inclusivePrefixes(method *node) []string {
+	for _, c := range method.children {
+		if e, ok := c.(*node); ok && e.name.Local == "InclusiveNamespaces" && e.lookup(e.name.Space) == algExcC14N {
+			return strings.Fields(e.attr("PrefixList"))
+		}
+	}
+	return nil
+}
+
+func stripSpaces(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '\t', '\n', '\r':
+			return -1
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,316 @@
+// Package saml is a SAML 2.0 service provider of the Web Browser SSO profile. Users are sent
+// to the identity provider with the HTTP-Redirect binding and come back with a response of
+// the HTTP-POST binding, which has to carry one signed and unencrypted assertion.
+package saml
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ClockSkew is tolerated between the clocks of the identity provider and the server
+const ClockSkew = 3 * time.Minute
+
+const (
+	statusSuccess = "urn:oasis:names:tc:SAML:2.0:status:Success"
+	bindingPOST   = "urn:oasis:names:tc:SAML:2.0:bindings:HTTP-POST"
+	methodBearer  = "urn:oasis:names:tc:SAML:2.0:cm:bearer"
+	nameIDFormat  = "urn:oasis:names:tc:SAML:1.1:nameid-format:unspecified"
+)
+
+var (
+	errResponse    = errors.New("saml: response is not valid")
+	errEncrypted   = errors.New("saml: encrypted assertions are not supported")
+	errIssuer      = errors.New("saml: issuer doesn't match")
+	errDestination = errors.New("saml: response is for another service provider")
+	errAudience    = errors.New("saml: assertion is for another service provider")
+	errExpired     = errors.New("saml: assertion is expired or not valid yet")
+	errCertificate = errors.New("saml: certificate of the identity provider is not valid")
+)
+
+// ServiceProvider is the server for one identity provider
+type ServiceProvider struct {
+	EntityID        string // identifies the server at the identity provider
+	ACSURL          string // assertion consumer service, where the responses are posted
+	IdPEntityID     string // issuer of the responses
+	IdPSSOURL       string // single sign-on service of the HTTP-Redirect binding
+	IdPCertificates []*x509.Certificate
+}
+
+// Assertion is the verified identity the identity provider asserted
+type Assertion struct {
+	ID           string
+	InResponseTo string // id of the authentication request, empty when the identity provider started
+	NameID       string
+	Attributes   map[string][]string
+	NotOnOrAfter time.Time // end of the subject confirmation or conditions
+}
+
+// Attribute returns the first value of the attribute with one of the names
+func (a *Assertion) Attribute(names ...string) string {
+	for _, name := range names {
+		if values := a.Attributes[name]; len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+// NewID returns a random id for a request, it can't start with a digit
+func NewID() (string, error) {
+	b := make([]byte, 20)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "_" + hex.EncodeToString(b), nil
+}
+
+// ParseCertificates reads PEM encoded certificates, or one base64 encoded DER
+// certificate like metadata documents have
+func ParseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certificates []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		certificate, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errCertificate
+		}
+		certificates = append(certificates, certificate)
+	}
+	if len(certificates) > 0 {
+		return certificates, nil
+	}
+
+	der, err := base64.StdEncoding.DecodeString(stripSpaces(string(data)))
+	if err != nil {
+		return nil, errCertificate
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errCertificate
+	}
+	return []*x509.Certificate{certificate}, nil
+}
+
+// AuthnRequestURL returns the url of the identity provider with an authentication request
+// of the id, the identity provider posts the response and the relay state to the ACS url
+func (sp *ServiceProvider) AuthnRequestURL(id, relayState string, now time.Time) (string, error) {
+	var request bytes.Buffer
+	request.WriteString(`<samlp:AuthnRequest xmlns:samlp="` + nsProtocol + `" xmlns:saml="` + nsAssertion + `"`)
+	writeAttr(&request, "ID", id)
+	writeAttr(&request, "Version", "2.0")
+	writeAttr(&request, "IssueInstant", now.UTC().Format(time.RFC3339))
+	writeAttr(&request, "Destination", sp.IdPSSOURL)
+	writeAttr(&request, "AssertionConsumerServiceURL", sp.ACSURL)
+	writeAttr(&request, "ProtocolBinding", bindingPOST)
+	request.WriteString(`><saml:Issuer>`)
+	xml.EscapeText(&request, []byte(sp.EntityID))
+	request.WriteString(`</saml:Issuer><samlp:NameIDPolicy Format="` + nameIDFormat + `" AllowCreate="true"/></samlp:AuthnRequest>`)
+
+	var deflated bytes.Buffer
+	w, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	w.Write(request.Bytes())
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+
+	query := url.Values{"SAMLRequest": {base64.StdEncoding.EncodeToString(deflated.Bytes())}}
+	if relayState != "" {
+		query.Set("RelayState", relayState)
+	}
+	separator := "?"
+	if strings.Contains(sp.IdPSSOURL, "?") {
+		separator = "&"
+	}
+	return sp.IdPSSOURL + separator + query.Encode(), nil
+}
+
+// Metadata returns the metadata document of the service provider for the identity provider
+func (sp *ServiceProvider) Metadata() []byte {
+	var metadata bytes.Buffer
+	metadata.WriteString(xml.Header)
+	metadata.WriteString(`<md:EntityDescriptor xmlns:md="` + nsMetadata + `"`)
+	writeAttr(&metadata, "entityID", sp.EntityID)
+	metadata.WriteString(`><md:SPSSODescriptor AuthnRequestsSigned="false" WantAssertionsSigned="true" protocolSupportEnumeration="` + nsProtocol + `">`)
+	metadata.WriteString(`<md:NameIDFormat>` + nameIDFormat + `</md:NameIDFormat>`)
+	metadata.WriteString(`<md:AssertionConsumerService Binding="` + bindingPOST + `"`)
+	writeAttr(&metadata, "Location", sp.ACSURL)
+	metadata.WriteString(` index="0" isDefault="true"/></md:SPSSODescriptor></md:EntityDescriptor>`)
+	metadata.WriteByte('\n')
+	return metadata.Bytes()
+}
+
+// ParseResponse verifies the base64 encoded response of the HTTP-POST binding and returns
+// its assertion. Either the response or the assertion has to be signed by the identity provider.
+func (sp *ServiceProvider) ParseResponse(encoded string, now time.Time) (*Assertion, error) {
+	data, err := base64.StdEncoding.DecodeString(stripSpaces(encoded))
+	if err != nil {
+		return nil, errResponse
+	}
+	response, err := parseXML(data)
+	if err != nil {
+		return nil, err
+	}
+	if !response.is(nsProtocol, "Response") || response.attr("Version") != "2.0" {
+		return nil, errResponse
+	}
+
+	status := response.element(nsProtocol, "Status")
+	if status == nil || status.element(nsProtocol, "StatusCode").attr("Value") != statusSuccess {
+		code := ""
+		if status != nil {
+			code = status.element(nsProtocol, "StatusCode").attr("Value")
+		}
+		return nil, fmt.Errorf("saml: identity provider responded with status %q", code)
+	}
+	if destination := response.attr("Destination"); destination != "" && destination != sp.ACSURL {
+		return nil, errDestination
+	}
+	if issuer := response.element(nsAssertion, "Issuer"); issuer != nil && issuer.text() != sp.IdPEntityID {
+		return nil, errIssuer
+	}
+
+	responseSigned := false
+	switch err := verifySignature(response, sp.IdPCertificates); err {
+	case nil:
+		responseSigned = true
+	case errNotSigned:
+	default:
+		return nil, err
+	}
+
+	if len(response.elements(nsAssertion, "EncryptedAssertion")) > 0 {
+		return nil, errEncrypted
+	}
+	assertions := response.elements(nsAssertion, "Assertion")
+	if len(assertions) != 1 {
+		return nil, errResponse
+	}
+	assertion := assertions[0]
+	if err := verifySignature(assertion, sp.IdPCertificates); err != nil && (err != errNotSigned || !responseSigned) {
+		return nil, err
+	}
+
+	// The id of the request is only known to be from the identity provider when the response is signed
+	inResponseTo := ""
+	if responseSigned {
+		inResponseTo = response.attr("InResponseTo")
+	}
+	return sp.readAssertion(assertion, inResponseTo, now)
+}
+
+// readAssertion checks the issuer, subject confirmation and conditions of the signed assertion
+func (sp *ServiceProvider) readAssertion(assertion *node, inResponseTo string, now time.Time) (*Assertion, error) {
+	if assertion.element(nsAssertion, "Issuer").text() != sp.IdPEntityID {
+		return nil, errIssuer
+	}
+	result := &Assertion{ID: assertion.attr("ID"), InResponseTo: inResponseTo, Attributes: map[string][]string{}}
+
+	subject := assertion.element(nsAssertion, "Subject")
+	if subject == nil {
+		return nil, errResponse
+	}
+	result.NameID = subject.element(nsAssertion, "NameID").text()
+	if result.ID == "" || result.NameID == "" {
+		return nil, errResponse
+	}
+
+	// One bearer confirmation has to be for the ACS url and not expired
+	confirmed := false
+	for _, confirmation := range subject.elements(nsAssertion, "SubjectConfirmation") {
+		data := confirmation.element(nsAssertion, "SubjectConfirmationData")
+		if confirmation.attr("Method") != methodBearer || data == nil || data.attr("Recipient") != sp.ACSURL {
+			continue
+		}
+		notOnOrAfter, err := parseTime(data.attr("NotOnOrAfter"))
+		if err != nil || !now.Before(notOnOrAfter.Add(ClockSkew)) {
+			continue
+		}
+		if id := data.attr("InResponseTo"); id != "" {
+			if inResponseTo != "" && id != inResponseTo {
+				continue
+			}
+			result.InResponseTo = id
+		}
+		result.NotOnOrAfter = notOnOrAfter
+		confirmed = true
+		break
+	}
+	if !confirmed {
+		return nil, errExpired
+	}
+
+	conditions := assertion.element(nsAssertion, "Conditions")
+	if conditions == nil {
+		return nil, errResponse
+	}
+	if notBefore := conditions.attr("NotBefore"); notBefore != "" {
+		t, err := parseTime(notBefore)
+		if err != nil || now.Add(ClockSkew).Before(t) {
+			return nil, errExpired
+		}
+	}
+	if notOnOrAfter := conditions.attr("NotOnOrAfter"); notOnOrAfter != "" {
+		t, err := parseTime(notOnOrAfter)
+		if err != nil || !now.Before(t.Add(ClockSkew)) {
+			return nil, errExpired
+		}
+		if t.Before(result.NotOnOrAfter) {
+			result.NotOnOrAfter = t
+		}
+	}
+	restrictions := conditions.elements(nsAssertion, "AudienceRestriction")
+	if len(restrictions) == 0 {
+		return nil, errAudience
+	}
+	for _, restriction := range restrictions {
+		found := false
+		for _, audience := range restriction.elements(nsAssertion, "Audience") {
+			found = found || audience.text() == sp.EntityID
+		}
+		if !found {
+			return nil, errAudience
+		}
+	}
+
+	for _, statement := range assertion.elements(nsAssertion, "AttributeStatement") {
+		for _, attribute := range statement.elements(nsAssertion, "Attribute") {
+			name := attribute.attr("Name")
+			for _, value := range attribute.elements(nsAssertion, "AttributeValue") {
+				result.Attributes[name] = append(result.Attributes[name], value.text())
+			}
+			if friendly := attribute.attr("FriendlyName"); friendly != "" && friendly != name {
+				result.Attributes[friendly] = result.Attributes[name]
+			}
+		}
+	}
+	return result, nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, value)
+}
+
+func writeAttr(buf *bytes.Buffer, name, value string) {
+	buf.WriteString(" " + name + `="`)
+	escapeAttr(buf, value)
+	buf.WriteByte('"')
+}
@@ -0,0 +1,148 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app/saml/samltest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalize(t *testing.T) {
+	// Unused namespaces are dropped, used ones are declared where they're first rendered,
+	// attributes are sorted and empty elements are expanded
+	doc := `<a:root xmlns:a="urn:a" xmlns:b="urn:b" xmlns:unused="urn:unused">` + "\n" +
+		`<b:child z="1" b:attr="&quot;x&quot;" a="2"/><a:child xmlns:a="urn:a">t &amp; &lt;u&gt;</a:child></a:root>`
+	root, err := parseXML([]byte(doc))
+	require.NoError(t, err)
+	want := `<a:root xmlns:a="urn:a">` + "\n" +
+		`<b:child xmlns:b="urn:b" a="2" z="1" b:attr="&quot;x&quot;"></b:child><a:child>t &amp; &lt;u&gt;</a:child></a:root>`
+	assert.Equal(t, want, string(canonicalize(root, nil, nil)))
+
+	// A subtree declares the namespaces of its ancestors it uses, inclusive prefixes are kept
+	child := root.children[1].(*node)
+	assert.Equal(t, `<b:child xmlns:b="urn:b" a="2" z="1" b:attr="&quot;x&quot;"></b:child>`, string(canonicalize(child, nil, nil)))
+	assert.Equal(t, `<b:child xmlns:a="urn:a" xmlns:b="urn:b" a="2" z="1" b:attr="&quot;x&quot;"></b:child>`, string(canonicalize(child, nil, []string{"a"})))
+
+	// Excluded elements are left out
+	assert.Equal(t, `<a:root xmlns:a="urn:a">`+"\n"+`<a:child>t &amp; &lt;u&gt;</a:child></a:root>`, string(canonicalize(root, child, nil)))
+
+	// Documents with a DTD or unbalanced elements are rejected
+	for _, bad := range []string{`<!DOCTYPE a [<!ENTITY x "y">]><a>&x;</a>`, `<a><b></a>`, `<a></a><b></b>`, ``} {
+		_, err := parseXML([]byte(bad))
+		assert.Equal(t, errXML, err, bad)
+	}
+}
+
+func TestParseResponse(t *testing.T) {
+	idp, err := samltest.New("https://idp.example.com")
+	require.NoError(t, err)
+	certificates, err := ParseCertificates(idp.CertificatePEM())
+	require.NoError(t, err)
+	sp := &ServiceProvider{
+		EntityID:        "https://passwall.example.com/auth/saml/corp/metadata",
+		ACSURL:          "https://passwall.example.com/auth/saml/corp/acs",
+		IdPEntityID:     idp.EntityID,
+		IdPSSOURL:       "https://idp.example.com/sso",
+		IdPCertificates: certificates,
+	}
+	now := time.Now()
+	respond := func(r *samltest.Response) []byte {
+		if r.ACSURL == "" {
+			r.ACSURL = sp.ACSURL
+		}
+		if r.Audience == "" {
+			r.Audience = sp.EntityID
+		}
+		r.NameID = "jane"
+		r.Attributes = map[string]string{"email": "jane@example.com"}
+		data, err := idp.Respond(r)
+		require.NoError(t, err)
+		return data
+	}
+	encode := base64.StdEncoding.EncodeToString
+
+	// The request carries its id and the relay state to the identity provider
+	requestURL, err := sp.AuthnRequestURL("_request", "state", now)
+	require.NoError(t, err)
+	id, relayState, err := idp.ParseRequest(requestURL)
+	require.NoError(t, err)
+	assert.Equal(t, "_request", id)
+	assert.Equal(t, "state", relayState)
+
+	// Either the assertion or the response can be signed
+	for _, signResponse := range []bool{false, true} {
+		assertion, err := sp.ParseResponse(encode(respond(&samltest.Response{InResponseTo: "_request", SignResponse: signResponse})), now)
+		require.NoError(t, err)
+		assert.Equal(t, "jane", assertion.NameID)
+		assert.Equal(t, "_request", assertion.InResponseTo)
+		assert.Equal(t, "jane@example.com", assertion.Attribute("mail", "email"))
+		assert.NotEmpty(t, assertion.ID)
+		assert.True(t, assertion.NotOnOrAfter.After(now))
+	}
+
+	// Responses the identity provider started have no request id
+	assertion, err := sp.ParseResponse(encode(respond(&samltest.Response{})), now)
+	require.NoError(t, err)
+	assert.Empty(t, assertion.InResponseTo)
+
+	// Changing a signed value breaks the digest
+	data := respond(&samltest.Response{})
+	_, err = sp.ParseResponse(encode(bytes.Replace(data, []byte(">jane<"), []byte(">john<"), 1)), now)
+	assert.Equal(t, errSignature, err)
+
+	// A forged assertion next to the signed one is rejected, and so is one that wraps it
+	signed := data[bytes.Index(data, []byte("<saml:Assertion")):bytes.Index(data, []byte("</samlp:Response>"))]
+	forged := bytes.Replace(bytes.Replace(signed, []byte(">jane<"), []byte(">john<"), 1), []byte(`ID="_assertion`), []byte(`ID="_forged`), 1)
+	_, err = sp.ParseResponse(encode(bytes.Replace(data, signed, append(append([]byte{}, forged...), signed...), 1)), now)
+	assert.Equal(t, errResponse, err)
+	unsigned := forged[:bytes.Index(forged, []byte("<ds:Signature"))]
+	unsigned = append(append([]byte{}, unsigned...), `<saml:Subject><saml:NameID>john</saml:NameID></saml:Subject></saml:Assertion>`...)
+	_, err = sp.ParseResponse(encode(bytes.Replace(data, signed, bytes.Replace(unsigned, []byte("</saml:Issuer>"), append([]byte("</saml:Issuer>"), signed...), 1), 1)), now)
+	assert.Error(t, err)
+
+	// Responses of another identity provider, for another service provider or for another time are rejected
+	other, err := samltest.New(idp.EntityID)
+	require.NoError(t, err)
+	otherData, err := other.Respond(&samltest.Response{ACSURL: sp.ACSURL, Audience: sp.EntityID, NameID: "jane"})
+	require.NoError(t, err)
+	_, err = sp.ParseResponse(encode(otherData), now)
+	assert.Equal(t, errSignature, err)
+	_, err = sp.ParseResponse(encode(respond(&samltest.Response{Audience: "https://other.example.com"})), now)
+	assert.Equal(t, errAudience, err)
+	_, err = sp.ParseResponse(encode(respond(&samltest.Response{ACSURL: "https://other.example.com/acs"})), now)
+	assert.Equal(t, errDestination, err)
+	_, err = sp.ParseResponse(encode(respond(&samltest.Response{Issued: now.Add(-time.Hour)})), now)
+	assert.Equal(t, errExpired, err)
+	_, err = sp.ParseResponse(encode(respond(&samltest.Response{Issued: now.Add(time.Hour)})), now)
+	assert.Equal(t, errExpired, err)
+
+	// Unsigned responses are rejected
+	start, end := bytes.Index(data, []byte("<ds:Signature")), bytes.Index(data, []byte("</ds:Signature>"))+len("</ds:Signature>")
+	_, err = sp.ParseResponse(encode(append(append([]byte{}, data[:start]...), data[end:]...)), now)
+	assert.Equal(t, errNotSigned, err)
+}
+
+func TestMetadata(t *testing.T) {
+	sp := &ServiceProvider{EntityID: "https://passwall.example.com/metadata?a=1&b=2", ACSURL: "https://passwall.example.com/acs"}
+	metadata, err := parseXML(sp.Metadata())
+	require.NoError(t, err)
+	assert.True(t, metadata.is(nsMetadata, "EntityDescriptor"))
+	assert.Equal(t, sp.EntityID, metadata.attr("entityID"))
+	acs := metadata.element(nsMetadata, "SPSSODescriptor").element(nsMetadata, "AssertionConsumerService")
+	assert.Equal(t, sp.ACSURL, acs.attr("Location"))
+	assert.Equal(t, bindingPOST, acs.attr("Binding"))
+
+	// Identity providers with a query in their url get the request appended
+	sp.IdPSSOURL = "https://idp.example.com/sso?tenant=1"
+	requestURL, err := sp.AuthnRequestURL("_request", "", time.Now())
+	require.NoError(t, err)
+	u, err := url.Parse(requestURL)
+	require.NoError(t, err)
+	assert.Equal(t, "1", u.Query().Get("tenant"))
+	assert.NotEmpty(t, u.Query().Get("SAMLRequest"))
+}
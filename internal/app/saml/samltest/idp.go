@@ -0,0 +1,230 @@
+// Package samltest is an identity provider which signs SAML responses like a corporate one
+// does, so tests can sign in without one. The signed elements are written in their exclusive
+// canonical form, so their digests are the ones of the bytes.
+package samltest
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"encoding/xml"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsDSig      = "http://www.w3.org/2000/09/xmldsig#"
+)
+
+// IdP signs responses with a self-signed certificate
+type IdP struct {
+	EntityID string
+
+	key         *rsa.PrivateKey
+	certificate []byte
+}
+
+// Response is what the identity provider asserts
+type Response struct {
+	ACSURL       string // recipient and destination
+	Audience     string // entity id of the service provider
+	InResponseTo string // id of the request, empty when the identity provider starts
+	NameID       string
+	Attributes   map[string]string
+	Issued       time.Time // the assertion is valid for five minutes after it
+	SignResponse bool      // sign the response instead of the assertion
+}
+
+// New returns an identity provider with a new key
+func New(entityID string) (*IdP, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: entityID},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	certificate, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return &IdP{EntityID: entityID, key: key, certificate: certificate}, nil
+}
+
+// CertificatePEM returns the signing certificate for the configuration of the service provider
+func (idp *IdP) CertificatePEM() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: idp.certificate})
+}
+
+// ParseRequest returns the id of the authentication request and the relay state of the url
+func (idp *IdP) ParseRequest(requestURL string) (string, string, error) {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", "", err
+	}
+	deflated, err := base64.StdEncoding.DecodeString(u.Query().Get("SAMLRequest"))
+	if err != nil {
+		return "", "", err
+	}
+	data, err := ioutil.ReadAll(flate.NewReader(bytes.NewReader(deflated)))
+	if err != nil {
+		return "", "", err
+	}
+	var request struct {
+		ID string `xml:"ID,attr"`
+	}
+	if err := xml.Unmarshal(data, &request); err != nil || request.ID == "" {
+		return "", "", errors.New("samltest: authentication request has no id")
+	}
+	return request.ID, u.Query().Get("RelayState"), nil
+}
+
+// Respond returns the XML of a signed response
+func (idp *IdP) Respond(r *Response) ([]byte, error) {
+	issued := r.Issued
+	if issued.IsZero() {
+		issued = time.Now()
+	}
+	instant := issued.UTC().Format(time.RFC3339)
+	expires := issued.Add(5 * time.Minute).UTC().Format(time.RFC3339)
+
+	// Attributes of canonical elements are sorted by name, none of them is qualified
+	var assertion bytes.Buffer
+	assertion.WriteString(`<saml:Assertion xmlns:saml="` + nsAssertion + `"`)
+	writeAttrs(&assertion, map[string]string{"ID": "_assertion" + randomID(), "IssueInstant": instant, "Version": "2.0"})
+	assertion.WriteString(`><saml:Issuer>` + escape(idp.EntityID) + `</saml:Issuer>`)
+	assertion.WriteString(`<saml:Subject><saml:NameID>` + escape(r.NameID) + `</saml:NameID>`)
+	assertion.WriteString(`<saml:SubjectConfirmation Method="urn:oasis:names:tc:SAML:2.0:cm:bearer"><saml:SubjectConfirmationData`)
+	data := map[string]string{"NotOnOrAfter": expires, "Recipient": r.ACSURL}
+	if r.InResponseTo != "" {
+		data["InResponseTo"] = r.InResponseTo
+	}
+	writeAttrs(&assertion, data)
+	assertion.WriteString(`></saml:SubjectConfirmationData></saml:SubjectConfirmation></saml:Subject><saml:Conditions`)
+	writeAttrs(&assertion, map[string]string{"NotBefore": instant, "NotOnOrAfter": expires})
+	assertion.WriteString(`><saml:AudienceRestriction><saml:Audience>` + escape(r.Audience) + `</saml:Audience></saml:AudienceRestriction></saml:Conditions>`)
+	assertion.WriteString(`<saml:AttributeStatement>`)
+	names := make([]string, 0, len(r.Attributes))
+	for name := range r.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		assertion.WriteString(`<saml:Attribute`)
+		writeAttrs(&assertion, map[string]string{"Name": name})
+		assertion.WriteString(`><saml:AttributeValue>` + escape(r.Attributes[name]) + `</saml:AttributeValue></saml:Attribute>`)
+	}
+	assertion.WriteString(`</saml:AttributeStatement></saml:Assertion>`)
+
+	if !r.SignResponse {
+		signed, err := idp.sign(assertion.Bytes(), `</saml:Issuer>`)
+		if err != nil {
+			return nil, err
+		}
+		assertion.Reset()
+		assertion.Write(signed)
+	}
+
+	var response bytes.Buffer
+	response.WriteString(`<samlp:Response xmlns:samlp="` + nsProtocol + `"`)
+	attrs := map[string]string{"Destination": r.ACSURL, "ID": "_response" + randomID(), "IssueInstant": instant, "Version": "2.0"}
+	if r.InResponseTo != "" {
+		attrs["InResponseTo"] = r.InResponseTo
+	}
+	writeAttrs(&response, attrs)
+	response.WriteString(`><saml:Issuer xmlns:saml="` + nsAssertion + `">` + escape(idp.EntityID) + `</saml:Issuer>`)
+	response.WriteString(`<samlp:Status><samlp:StatusCode Value="urn:oasis:names:tc:SAML:2.0:status:Success"></samlp:StatusCode></samlp:Status>`)
+	response.Write(assertion.Bytes())
+	response.WriteString(`</samlp:Response>`)
+
+	if r.SignResponse {
+		return idp.sign(response.Bytes(), `</saml:Issuer>`)
+	}
+	return response.Bytes(), nil
+}
+
+// sign inserts the enveloped signature of the canonical element after the first marker
+func (idp *IdP) sign(element []byte, after string) ([]byte, error) {
+	var root struct {
+		ID string `xml:"ID,attr"`
+	}
+	if err := xml.Unmarshal(element, &root); err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(element)
+
+	signedInfo := `<ds:SignedInfo xmlns:ds="` + nsDSig + `">` +
+		`<ds:CanonicalizationMethod Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"></ds:CanonicalizationMethod>` +
+		`<ds:SignatureMethod Algorithm="http://www.w3.org/2001/04/xmldsig-more#rsa-sha256"></ds:SignatureMethod>` +
+		`<ds:Reference URI="#` + root.ID + `"><ds:Transforms>` +
+		`<ds:Transform Algorithm="http://www.w3.org/2000/09/xmldsig#enveloped-signature"></ds:Transform>` +
+		`<ds:Transform Algorithm="http://www.w3.org/2001/10/xml-exc-c14n#"></ds:Transform></ds:Transforms>` +
+		`<ds:DigestMethod Algorithm="http://www.w3.org/2001/04/xmlenc#sha256"></ds:DigestMethod>` +
+		`<ds:DigestValue>` + base64.StdEncoding.EncodeToString(digest[:]) + `</ds:DigestValue></ds:Reference></ds:SignedInfo>`
+	hash := sha256.Sum256([]byte(signedInfo))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, idp.key, crypto.SHA256, hash[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature declares the namespace, the canonical SignedInfo renders it again
+	envelope := `<ds:Signature xmlns:ds="` + nsDSig + `">` +
+		strings.Replace(signedInfo, ` xmlns:ds="`+nsDSig+`"`, "", 1) +
+		`<ds:SignatureValue>` + base64.StdEncoding.EncodeToString(signature) + `</ds:SignatureValue>` +
+		`<ds:KeyInfo><ds:X509Data><ds:X509Certificate>` + base64.StdEncoding.EncodeToString(idp.certificate) +
+		`</ds:X509Certificate></ds:X509Data></ds:KeyInfo></ds:Signature>`
+
+	i := bytes.Index(element, []byte(after))
+	if i < 0 {
+		return nil, errors.New("samltest: no place for the signature")
+	}
+	i += len(after)
+	return append(append(append([]byte{}, element[:i]...), envelope...), element[i:]...), nil
+}
+
+func writeAttrs(buf *bytes.Buffer, attrs map[string]string) {
+	names := make([]string, 0, len(attrs))
+	for name := range attrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		buf.WriteString(" " + name + `="` + escapeAttr(attrs[name]) + `"`)
+	}
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escape(s string) string {
+	return textEscaper.Replace(s)
+}
+
+func escapeAttr(s string) string {
+	return attrEscaper.Replace(s)
+}
+
+func randomID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return strings.TrimRight(base64.RawURLEncoding.EncodeToString(b), "=")
+}
@@ -0,0 +1,260 @@
+package saml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Namespaces of the elements the service provider reads
+const (
+	nsXML       = "http://www.w3.org/XML/1998/namespace"
+	nsDSig      = "http://www.w3.org/2000/09/xmldsig#"
+	nsProtocol  = "urn:oasis:names:tc:SAML:2.0:protocol"
+	nsAssertion = "urn:oasis:names:tc:SAML:2.0:assertion"
+	nsMetadata  = "urn:oasis:names:tc:SAML:2.0:metadata"
+)
+
+var errXML = errors.New("saml: malformed XML")
+
+// node is an element of a parsed document. The prefixes and namespace declarations
+// are kept as written, the canonical form of signed elements is built from them.
+type node struct {
+	parent   *node
+	name     xml.Name      // Space is the prefix
+	attrs    []xml.Attr    // Space of the names is the prefix, xmlns declarations included
+	children []interface{} // *node or string
+}
+
+// parseXML reads the document into a tree. Documents with a DTD are rejected,
+// comments and processing instructions are dropped.
+func parseXML(data []byte) (*node, error) {
+	d := xml.NewDecoder(bytes.NewReader(data))
+	var root, current *node
+	for {
+		token, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errXML
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			n := &node{parent: current, name: t.Name, attrs: append([]xml.Attr{}, t.Attr...)}
+			if current == nil {
+				if root != nil {
+					return nil, errXML
+				}
+				root = n
+			} else {
+				current.children = append(current.children, n)
+			}
+			current = n
+		case xml.EndElement:
+			if current == nil || current.name != t.Name {
+				return nil, errXML
+			}
+			current = current.parent
+		case xml.CharData:
+			if current != nil {
+				current.children = append(current.children, string(t))
+			} else if len(bytes.TrimSpace(t)) != 0 {
+				return nil, errXML
+			}
+		case xml.Directive:
+			return nil, errXML
+		}
+	}
+	if root == nil || current != nil {
+		return nil, errXML
+	}
+	return root, nil
+}
+
+// lookup returns the namespace the prefix is bound to at the element
+func (n *node) lookup(prefix string) string {
+	if prefix == "xml" {
+		return nsXML
+	}
+	for e := n; e != nil; e = e.parent {
+		for _, a := range e.attrs {
+			if (prefix == "" && a.Name.Space == "" && a.Name.Local == "xmlns") ||
+				(prefix != "" && a.Name.Space == "xmlns" && a.Name.Local == prefix) {
+				return a.Value
+			}
+		}
+	}
+	return ""
+}
+
+// is tells if the element has the namespace and local name
+func (n *node) is(space, local string) bool {
+	return n.name.Local == local && n.lookup(n.name.Space) == space
+}
+
+// elements returns the child elements with the namespace and local name
+func (n *node) elements(space, local string) []*node {
+	var list []*node
+	for _, c := range n.children {
+		if e, ok := c.(*node); ok && e.is(space, local) {
+			list = append(list, e)
+		}
+	}
+	return list
+}
+
+// element returns the first child element with the namespace and local name
+func (n *node) element(space, local string) *node {
+	if list := n.elements(space, local); len(list) > 0 {
+		return list[0]
+	}
+	return nil
+}
+
+// attr returns the value of the unqualified attribute
+func (n *node) attr(local string) string {
+	if n == nil {
+		return ""
+	}
+	for _, a := range n.attrs {
+		if a.Name.Space == "" && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// text returns the character data of the element without its child elements
+func (n *node) text() string {
+	if n == nil {
+		return ""
+	}
+	var b strings.Builder
+	for _, c := range n.children {
+		if s, ok := c.(string); ok {
+			b.WriteString(s)
+		}
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// canonicalize returns the exclusive canonical form of the element without the excluded
+// descendant, see https://www.w3.org/TR/xml-exc-c14n/. The namespaces of the inclusive
+// prefixes are rendered like inclusive canonicalization does.
+func canonicalize(n *node, exclude *node, inclusive []string) []byte {
+	c := &canonicalizer{exclude: exclude, inclusive: inclusive}
+	c.element(n, map[string]string{})
+	return c.buf.Bytes()
+}
+
+type canonicalizer struct {
+	buf       bytes.Buffer
+	exclude   *node
+	inclusive []string
+}
+
+func (c *canonicalizer) element(n *node, rendered map[string]string) {
+	// Namespaces visibly utilized by the element and its attributes
+	used := map[string]bool{n.name.Space: true}
+	var attrs []xml.Attr
+	for _, a := range n.attrs {
+		if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+			continue
+		}
+		if a.Name.Space != "" {
+			used[a.Name.Space] = true
+		}
+		attrs = append(attrs, a)
+	}
+	for _, prefix := range c.inclusive {
+		if prefix == "#default" {
+			prefix = ""
+		}
+		used[prefix] = true
+	}
+
+	scope := make(map[string]string, len(rendered))
+	for prefix, uri := range rendered {
+		scope[prefix] = uri
+	}
+	var prefixes []string
+	for prefix := range used {
+		if prefix == "xml" {
+			continue
+		}
+		if uri := n.lookup(prefix); rendered[prefix] != uri {
+			prefixes = append(prefixes, prefix)
+			scope[prefix] = uri
+		}
+	}
+	sort.Strings(prefixes)
+
+	sort.Slice(attrs, func(i, j int) bool {
+		si, sj := n.lookup(attrs[i].Name.Space), n.lookup(attrs[j].Name.Space)
+		if attrs[i].Name.Space == "" {
+			si = ""
+		}
+		if attrs[j].Name.Space == "" {
+			sj = ""
+		}
+		if si != sj {
+			return si < sj
+		}
+		return attrs[i].Name.Local < attrs[j].Name.Local
+	})
+
+	c.buf.WriteByte('<')
+	c.buf.WriteString(qualifiedName(n.name))
+	for _, prefix := range prefixes {
+		if prefix == "" {
+			c.buf.WriteString(` xmlns="`)
+		} else {
+			c.buf.WriteString(` xmlns:` + prefix + `="`)
+		}
+		escapeAttr(&c.buf, scope[prefix])
+		c.buf.WriteByte('"')
+	}
+	for _, a := range attrs {
+		c.buf.WriteString(" " + qualifiedName(a.Name) + `="`)
+		escapeAttr(&c.buf, a.Value)
+		c.buf.WriteByte('"')
+	}
+	c.buf.WriteByte('>')
+
+	for _, child := range n.children {
+		switch child := child.(type) {
+		case *node:
+			if child != c.exclude {
+				c.element(child, scope)
+			}
+		case string:
+			escapeText(&c.buf, child)
+		}
+	}
+	c.buf.WriteString("</" + qualifiedName(n.name) + ">")
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+var (
+	textEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\r", "&#xD;")
+	attrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+func escapeText(buf *bytes.Buffer, s string) {
+	textEscaper.WriteString(buf, s)
+}
+
+func escapeAttr(buf *bytes.Buffer, s string) {
+	attrEscaper.WriteString(buf, s)
+}
@@ -0,0 +1,31 @@
+package app
+
+import (
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+)
+
+// Search returns pointers to a page of the items of all types matching the query, by type
+// and pinned and last updated first within a type, and the number of all matching items.
+// Offset and limit -1 don't paginate.
+func Search(s storage.Store, query string, offset, limit int, schema string) ([]interface{}, int, error) {
+	defer tracing.Start("app.Search").End()
+
+	argsStr := map[string]string{"search": query, "order": "updated_at desc"}
+	items, err := findItemsOfAllTypes(s, argsStr, schema)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(items)
+	if offset > 0 {
+		if offset > total {
+			offset = total
+		}
+		items = items[offset:]
+	}
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+	return items, total, nil
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"errors"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+)
+
+var errServerExemption = errors.New("only users can be exempted from the security key requirement")
+
+// securityKeyDeadline returns when the users of the policy have to sign in with a security key,
+// they can enroll one until then. It is nil when the policy doesn't require a key.
+func securityKeyDeadline(policy *model.Policy) *time.Time {
+	if !policy.RequireSecurityKey {
+		return nil
+	}
+	deadline := policy.SecurityKeyRequiredAt
+	if grace, err := parsePeriod(policy.SecurityKeyGracePeriod); err == nil {
+		deadline = deadline.Add(grace)
+	}
+	return &deadline
+}
+
+// checkSecurityKey denies sessions without a security key after the deadline of the policy,
+// a second factor like a TOTP code isn't enough. Exempted users pass until the exemption ends.
+func checkSecurityKey(policy *model.PolicyDTO, req *AccessRequest) *AccessDeniedError {
+	if policy.SecurityKeyDeadline == nil || req.SecurityKey || req.Time.Before(*policy.SecurityKeyDeadline) {
+		return nil
+	}
+	if policy.SecurityKeyExemptUntil != nil && req.Time.Before(*policy.SecurityKeyExemptUntil) {
+		return nil
+	}
+	return &AccessDeniedError{AccessSecurityKey, "A security key is required to sign in"}
+}
+
+// ExemptFromSecurityKey lets the user sign in without a security key for the period of the
+// exemption, e.g. after losing the key. admin tells who overrode the policy for the audit log.
+func ExemptFromSecurityKey(s storage.Store, userID uint, dto *model.SecurityKeyExemptionDTO, admin string) (*model.Policy, error) {
+	if userID == ServerPolicyID {
+		return nil, errServerExemption
+	}
+	period, err := parsePeriod(dto.Period)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := FindPolicy(s, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	until := time.Now().Add(period)
+	policy.SecurityKeyExemptUntil = &until
+	policy.SecurityKeyExemptReason = dto.Reason
+	if policy, err = s.Policies().Save(policy); err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"event":   "security_key_exemption",
+		"user_id": userID,
+		"admin":   admin,
+		"until":   until.Format(time.RFC3339),
+		"reason":  dto.Reason,
+	}).Warn("security key requirement is overridden")
+	return policy, nil
+}
+
+// RemoveSecurityKeyExemption ends the exemption of the user
+func RemoveSecurityKeyExemption(s storage.Store, userID uint, admin string) (*model.Policy, error) {
+	policy, err := FindPolicy(s, userID)
+	if err != nil {
+		return nil, err
+	}
+	if policy.SecurityKeyExemptUntil == nil {
+		return policy, nil
+	}
+
+	policy.SecurityKeyExemptUntil = nil
+	policy.SecurityKeyExemptReason = ""
+	if policy, err = s.Policies().Save(policy); err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"event":   "security_key_exemption_removed",
+		"user_id": userID,
+		"admin":   admin,
+	}).Warn("security key exemption is removed")
+	return policy, nil
+}
+
+// earlierTime returns the earlier of the times, nil is never
+func earlierTime(a, b *time.Time) *time.Time {
+	if a == nil || (b != nil && b.Before(*a)) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,38 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSecurityKeyDeadline(t *testing.T) {
+	since := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	assert.Nil(t, securityKeyDeadline(&model.Policy{SecurityKeyRequiredAt: since}))
+
+	deadline := securityKeyDeadline(&model.Policy{RequireSecurityKey: true, SecurityKeyRequiredAt: since, SecurityKeyGracePeriod: "14d"})
+	assert.Equal(t, since.Add(14*24*time.Hour), *deadline)
+	assert.Equal(t, since, *earlierTime(deadline, &since))
+	assert.Equal(t, deadline, earlierTime(deadline, nil))
+}
+
+func TestCheckSecurityKey(t *testing.T) {
+	deadline := time.Date(2020, 6, 15, 12, 0, 0, 0, time.UTC)
+	policy := &model.PolicyDTO{SecurityKeyDeadline: &deadline}
+
+	grace := &AccessRequest{Time: deadline.Add(-time.Hour)}
+	assert.Nil(t, checkSecurityKey(policy, grace))
+
+	totp := &AccessRequest{Time: deadline.Add(time.Hour), TwoFactor: true}
+	assert.Equal(t, AccessSecurityKey, checkSecurityKey(policy, totp).Code)
+
+	key := &AccessRequest{Time: deadline.Add(time.Hour), TwoFactor: true, SecurityKey: true}
+	assert.Nil(t, checkSecurityKey(policy, key))
+
+	exemptUntil := deadline.Add(2 * time.Hour)
+	policy.SecurityKeyExemptUntil = &exemptUntil
+	assert.Nil(t, checkSecurityKey(policy, totp))
+	assert.NotNil(t, checkSecurityKey(policy, &AccessRequest{Time: exemptUntil}))
+}
@@ -8,8 +8,15 @@ import (
 	"github.com/spf13/viper"
 )
 
+// MailSender delivers the emails of SendMail, tests replace it to read them
+var MailSender = sendgridMail
+
 // SendMail is an helper to send mail all over the project
 func SendMail(name, email string, subject, bodyHTML string) {
+	MailSender(name, email, subject, bodyHTML)
+}
+
+func sendgridMail(name, email string, subject, bodyHTML string) {
 	from := mail.NewEmail(viper.GetString("email.fromName"), viper.GetString("email.fromEmail"))
 	to := mail.NewEmail(name, email)
 	bodyText := ""
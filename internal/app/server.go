@@ -2,41 +2,62 @@ package app
 
 import (
 	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
 	"github.com/passwall/passwall-server/model"
 )
 
 // CreateServer creates a server and saves it to the store
 func CreateServer(s storage.Store, dto *model.ServerDTO, schema string) (*model.Server, error) {
+	defer tracing.Start("app.CreateServer").End()
+
 	rawModel := model.ToServer(dto)
-	encModel := EncryptModel(rawModel)
 
-	createdServer, err := s.Servers().Save(encModel.(*model.Server), schema)
+	createdServer, err := s.Servers().Save(rawModel, schema)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := SetItemTags(s, createdServer, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return createdServer, nil
 }
 
 // UpdateServer updates the server with the dto and applies the changes in the store
 func UpdateServer(s storage.Store, server *model.Server, dto *model.ServerDTO, schema string) (*model.Server, error) {
+	defer tracing.Start("app.UpdateServer").End()
+
+	if err := saveItemVersion(s, server, schema); err != nil {
+		return nil, err
+	}
+
 	rawModel := model.ToServer(dto)
-	encModel := EncryptModel(rawModel).(*model.Server)
-
-	server.Title = encModel.Title
-	server.IP = encModel.IP
-	server.Username = encModel.Username
-	server.Password = encModel.Password
-	server.URL = encModel.URL
-	server.HostingUsername = encModel.HostingUsername
-	server.HostingPassword = encModel.HostingPassword
-	server.AdminUsername = encModel.AdminUsername
-	server.AdminPassword = encModel.AdminPassword
-	server.Extra = encModel.Extra
+
+	server.Title = rawModel.Title
+	server.IP = rawModel.IP
+	server.Username = rawModel.Username
+	server.Password = rawModel.Password
+	server.URL = rawModel.URL
+	server.HostingUsername = rawModel.HostingUsername
+	server.HostingPassword = rawModel.HostingPassword
+	server.AdminUsername = rawModel.AdminUsername
+	server.AdminPassword = rawModel.AdminPassword
+	server.Extra = rawModel.Extra
+	server.Pinned = rawModel.Pinned
+	server.IsFavorite = rawModel.IsFavorite
+	server.SortOrder = rawModel.SortOrder
+	server.FolderID = rawModel.FolderID
+	server.Reprompt = rawModel.Reprompt
+	server.Canary = rawModel.Canary
 
 	updatedServer, err := s.Servers().Save(server, schema)
 	if err != nil {
 		return nil, err
 	}
+	if err := SetItemTags(s, updatedServer, dto.Tags, schema); err != nil {
+		return nil, err
+	}
+
 	return updatedServer, nil
 }
@@ -0,0 +1,149 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+)
+
+// ErrRefreshTokenReused is returned for refresh tokens which were already rotated. Someone
+// else holds a copy of the token, so the whole session is revoked.
+var ErrRefreshTokenReused = errors.New("Refresh token is already used, the session is revoked")
+
+// ErrSessionNotFound is returned for sessions which aren't one of the user
+var ErrSessionNotFound = errors.New("Session not found")
+
+// SaveSessionTokens saves the tokens of a session family with the device of the request,
+// an empty family starts a new one
+func SaveSessionTokens(s storage.Store, r *http.Request, userID uint, token *model.TokenDetailsDTO, session *Session, family string) {
+	if family == "" {
+		family = uuid.NewV4().String()
+	}
+	ip := ""
+	if clientIP := ClientIP(r); clientIP != nil {
+		ip = clientIP.String()
+	}
+	now := time.Now()
+	device := model.Token{
+		UserID:     int(userID),
+		LastUsedAt: now,
+		Family:     family,
+		IP:         ip,
+		UserAgent:  r.UserAgent(),
+		StartedAt:  session.Start,
+		Duress:     session.Duress,
+	}
+
+	access, refresh := device, device
+	access.UUID, access.Token, access.ExpiryTime = token.AtUUID, token.AccessToken, token.AtExpiresTime
+	access.TransmissionKey = token.TransmissionKey
+	refresh.UUID, refresh.Token, refresh.ExpiryTime = token.RtUUID, token.RefreshToken, token.RtExpiresTime
+	refresh.Refresh = true
+	s.Tokens().Save(&access)
+	s.Tokens().Save(&refresh)
+}
+
+// FindSessions returns the signed in devices of the user. The decoy vault only sees the
+// sessions of the duress password.
+func FindSessions(s storage.Store, userID uint, duress bool) ([]model.Token, error) {
+	tokens, err := s.Tokens().FindSessions(int(userID))
+	if err != nil {
+		return nil, err
+	}
+	sessions := []model.Token{}
+	for _, token := range tokens {
+		if token.Family != "" && (!duress || token.Duress) {
+			sessions = append(sessions, token)
+		}
+	}
+	return sessions, nil
+}
+
+// DeleteSession signs out a device of the user
+func DeleteSession(s storage.Store, userID uint, id string, duress bool) error {
+	sessions, err := FindSessions(s, userID, duress)
+	if err != nil {
+		return err
+	}
+	for i := range sessions {
+		if sessions[i].Family != id {
+			continue
+		}
+		RevokeSession(s, &sessions[i])
+		log.WithFields(log.Fields{
+			"event":   "session_revoked",
+			"user_id": userID,
+			"family":  id,
+		}).Info("session is revoked")
+		return nil
+	}
+	return ErrSessionNotFound
+}
+
+// RotateRefreshToken uses up the refresh token and ends the access tokens of its family,
+// the caller saves the new tokens to the family. A token which was rotated before revokes
+// the family instead.
+func RotateRefreshToken(s storage.Store, token *model.Token) error {
+	if token.RotatedAt != nil || !s.Tokens().Rotate(token.UUID.String(), time.Now()) {
+		RevokeSession(s, token)
+		log.WithFields(log.Fields{
+			"event":   "refresh_token_reused",
+			"user_id": token.UserID,
+			"family":  token.Family,
+		}).Warn("rotated refresh token is used again, the session is revoked")
+		return ErrRefreshTokenReused
+	}
+
+	// Tokens of sessions from before the families end with all tokens of the user
+	if token.Family == "" {
+		s.Tokens().Delete(token.UserID)
+		return nil
+	}
+	s.Tokens().DeleteAccessByFamily(token.Family)
+	return nil
+}
+
+// RevokeSessionToken ends the session of a valid access or refresh token.
+// It's false when the token doesn't belong to a session.
+func RevokeSessionToken(s storage.Store, tokenString string) bool {
+	token, err := TokenValid(tokenString)
+	if err != nil {
+		return false
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	id, _ := claims["uuid"].(string)
+	row, ok := s.Tokens().Any(id)
+	if !ok {
+		return false
+	}
+
+	RevokeSession(s, &row)
+	log.WithFields(log.Fields{
+		"event":   "session_revoked",
+		"user_id": row.UserID,
+		"family":  row.Family,
+	}).Info("session is revoked")
+	return true
+}
+
+// PurgeSupersededTokens deletes the rotated refresh tokens which expired. They're kept until
+// then to recognize their reuse, expired ones can't be used anyway.
+func PurgeSupersededTokens(s storage.Store, now time.Time) (int, error) {
+	return s.Tokens().DeleteSuperseded(now)
+}
+
+// RevokeSession deletes the tokens of the session of the token, sessions from before the
+// families end with all tokens of the user
+func RevokeSession(s storage.Store, token *model.Token) {
+	if token.Family == "" {
+		s.Tokens().Delete(token.UserID)
+		return
+	}
+	s.Tokens().DeleteByFamily(token.Family)
+}
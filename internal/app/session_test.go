@@ -0,0 +1,41 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	uuid "github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRotateRefreshToken(t *testing.T) {
+	rotatedAt := time.Now().Add(-time.Minute)
+	fresh := &model.Token{UserID: 1, UUID: uuid.NewV4(), Family: "family", Refresh: true}
+	raced := &model.Token{UserID: 1, UUID: uuid.NewV4(), Family: "raced", Refresh: true}
+	reused := &model.Token{UserID: 1, UUID: uuid.NewV4(), Family: "reused", Refresh: true, RotatedAt: &rotatedAt}
+	legacy := &model.Token{UserID: 2, UUID: uuid.NewV4()}
+
+	mocks := storagetest.NewMocks()
+	mocks.Tokens.On("Rotate", fresh.UUID.String(), mock.Anything).Return(true).Once()
+	mocks.Tokens.On("Rotate", raced.UUID.String(), mock.Anything).Return(false).Once()
+	mocks.Tokens.On("Rotate", legacy.UUID.String(), mock.Anything).Return(true).Once()
+	mocks.Tokens.On("DeleteAccessByFamily", "family").Once()
+	mocks.Tokens.On("DeleteByFamily", "raced").Once()
+	mocks.Tokens.On("DeleteByFamily", "reused").Once()
+	mocks.Tokens.On("Delete", 2).Once()
+
+	// The refresh token is used up and the access tokens of its family end
+	assert.NoError(t, RotateRefreshToken(mocks.Store, fresh))
+
+	// A token which was rotated before, or by a concurrent refresh, revokes its family
+	assert.Equal(t, ErrRefreshTokenReused, RotateRefreshToken(mocks.Store, reused))
+	assert.Equal(t, ErrRefreshTokenReused, RotateRefreshToken(mocks.Store, raced))
+	mocks.Tokens.AssertNotCalled(t, "Rotate", reused.UUID.String(), mock.Anything)
+
+	// Sessions without a family end with all tokens of the user
+	assert.NoError(t, RotateRefreshToken(mocks.Store, legacy))
+	mocks.AssertExpectations(t)
+}
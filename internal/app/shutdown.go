@@ -0,0 +1,89 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errShuttingDown stops long jobs between their batches, they resume at the next start
+var errShuttingDown = errors.New("server is shutting down")
+
+// background tracks the jobs of the server, Shutdown waits for them
+var background = struct {
+	sync.Mutex
+	running  int
+	idle     chan struct{} // closed while no job runs
+	once     sync.Once
+	stopping chan struct{}
+}{idle: closedChannel(), stopping: make(chan struct{})}
+
+func closedChannel() chan struct{} {
+	c := make(chan struct{})
+	close(c)
+	return c
+}
+
+// ShuttingDown is true once Shutdown is called
+func ShuttingDown() bool {
+	select {
+	case <-background.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+// Shutdown stops the background jobs and waits until their runs end and the alerts are
+// sent, at most until the context is done. No runs start anymore and re-encryptions stop
+// between their batches.
+func Shutdown(ctx context.Context) error {
+	background.once.Do(func() { close(background.stopping) })
+
+	background.Lock()
+	idle := background.idle
+	background.Unlock()
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// goBackground runs the job in a goroutine which Shutdown waits for
+func goBackground(job func()) {
+	background.Lock()
+	if background.running++; background.running == 1 {
+		background.idle = make(chan struct{})
+	}
+	background.Unlock()
+
+	go func() {
+		defer func() {
+			background.Lock()
+			if background.running--; background.running == 0 {
+				close(background.idle)
+			}
+			background.Unlock()
+		}()
+		job()
+	}()
+}
+
+// every runs the job every period until the shutdown
+func every(period time.Duration, job func(now time.Time)) {
+	goBackground(func() {
+		ticker := time.NewTicker(period)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-background.stopping:
+				return
+			case now := <-ticker.C:
+				job(now)
+			}
+		}
+	})
+}
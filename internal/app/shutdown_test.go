@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// resetBackground lets the next test start jobs again
+func resetBackground() {
+	background.Lock()
+	defer background.Unlock()
+	background.once = sync.Once{}
+	background.stopping = make(chan struct{})
+}
+
+func TestShutdown(t *testing.T) {
+	defer resetBackground()
+
+	runs := make(chan time.Time, 10)
+	every(time.Millisecond, func(now time.Time) { runs <- now })
+	<-runs
+
+	// A run which is in progress is waited for
+	running, release := make(chan struct{}), make(chan struct{})
+	goBackground(func() {
+		close(running)
+		<-release
+	})
+	<-running
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+	}()
+	assert.False(t, ShuttingDown())
+	assert.NoError(t, Shutdown(context.Background()))
+	assert.True(t, ShuttingDown())
+
+	// No runs start after the shutdown
+	for len(runs) > 0 {
+		<-runs
+	}
+	time.Sleep(5 * time.Millisecond)
+	assert.Empty(t, runs)
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	defer resetBackground()
+
+	release := make(chan struct{})
+	goBackground(func() { <-release })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, Shutdown(ctx))
+	close(release)
+	assert.NoError(t, Shutdown(context.Background()))
+}
@@ -0,0 +1,413 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const (
+	ssoStateDuration  = 10 * time.Minute
+	ssoMetadataMaxAge = time.Hour
+)
+
+var (
+	// ErrSSOState is returned for callbacks without a sign in the server started
+	ErrSSOState = errors.New("Single sign-on is expired, sign in again")
+	// ErrSSOUser is returned when no user has the verified email of the account
+	ErrSSOUser = errors.New("No user has the email of the single sign-on account")
+	// ErrSSOProvider is returned when the provider denies the sign in or its id token doesn't verify
+	ErrSSOProvider = errors.New("Single sign-on provider couldn't sign in the user")
+
+	errSSOUnknownProvider = errors.New("unknown single sign-on provider")
+
+	// ssoStates wait for the redirect of the provider, each can be used once
+	ssoStates = struct {
+		sync.Mutex
+		m map[string]*ssoState
+	}{m: map[string]*ssoState{}}
+
+	// ssoMetadata caches the discovery documents and signing keys of the providers
+	ssoMetadata = struct {
+		sync.Mutex
+		m map[string]*ssoProviderMetadata
+	}{m: map[string]*ssoProviderMetadata{}}
+
+	ssoHTTPClient = &http.Client{Transport: tracing.Transport(nil), Timeout: 10 * time.Second}
+)
+
+// ssoState is a sign in waiting for the code of the provider
+type ssoState struct {
+	provider string
+	nonce    string
+	verifier string
+	expires  time.Time
+}
+
+type ssoProviderMetadata struct {
+	discovery *model.OIDCDiscoveryDTO
+	keys      map[string]*rsa.PublicKey
+	fetched   time.Time
+}
+
+// SSOProviders returns the OpenID Connect and SAML providers users can sign in with
+func SSOProviders() ([]model.SSOProviderDTO, error) {
+	providers, err := ssoProviders()
+	if err != nil {
+		return nil, err
+	}
+	samlProviders, err := samlProviders()
+	if err != nil {
+		return nil, err
+	}
+	dtos := make([]model.SSOProviderDTO, 0, len(providers)+len(samlProviders))
+	for i := range providers {
+		dtos = append(dtos, model.SSOProviderDTO{ID: providers[i].ID, Name: ssoName(providers[i].ID, providers[i].Name), Protocol: "oidc"})
+	}
+	for i := range samlProviders {
+		dtos = append(dtos, model.SSOProviderDTO{ID: samlProviders[i].ID, Name: ssoName(samlProviders[i].ID, samlProviders[i].Name), Protocol: "saml"})
+	}
+	return dtos, nil
+}
+
+func ssoName(id, name string) string {
+	if name == "" {
+		return id
+	}
+	return name
+}
+
+// BeginSSO returns the authorization url of the provider, the user is redirected to it.
+// The code is bound to the sign in by the state, a nonce and a PKCE verifier.
+func BeginSSO(id string) (*model.SSOAuthorizationDTO, error) {
+	provider, err := findSSOProvider(id)
+	if err != nil {
+		return nil, err
+	}
+	metadata, err := ssoProviderMetadataOf(provider, false)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make([]string, 3)
+	for i := range values {
+		if values[i], err = ssoRandom(); err != nil {
+			return nil, err
+		}
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+	challenge := sha256.Sum256([]byte(verifier))
+
+	scopes := provider.Scopes
+	if len(scopes) == 0 {
+		scopes = []string{"openid", "email", "profile"}
+	}
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {provider.ClientID},
+		"redirect_uri":          {provider.RedirectURL},
+		"scope":                 {strings.Join(scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+
+	ssoStates.Lock()
+	defer ssoStates.Unlock()
+
+	// Drop sign ins which never came back from the provider
+	now := time.Now()
+	for k, st := range ssoStates.m {
+		if now.After(st.expires) {
+			delete(ssoStates.m, k)
+		}
+	}
+	ssoStates.m[state] = &ssoState{provider: provider.ID, nonce: nonce, verifier: verifier, expires: now.Add(ssoStateDuration)}
+
+	authorizationURL := metadata.discovery.AuthorizationEndpoint
+	if strings.Contains(authorizationURL, "?") {
+		authorizationURL += "&" + query.Encode()
+	} else {
+		authorizationURL += "?" + query.Encode()
+	}
+	return &model.SSOAuthorizationDTO{URL: authorizationURL, State: state}, nil
+}
+
+// FinishSSO exchanges the code of the provider and returns the user of the verified id token.
+// Subjects are linked to the user with the verified email of their first sign in.
+func FinishSSO(s storage.Store, id string, dto *model.SSOCallbackDTO) (*model.User, error) {
+	provider, err := findSSOProvider(id)
+	if err != nil {
+		return nil, err
+	}
+
+	ssoStates.Lock()
+	state, ok := ssoStates.m[dto.State]
+	delete(ssoStates.m, dto.State)
+	ssoStates.Unlock()
+	if !ok || state.provider != provider.ID || time.Now().After(state.expires) {
+		return nil, ErrSSOState
+	}
+
+	metadata, err := ssoProviderMetadataOf(provider, false)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := exchangeSSOCode(provider, metadata, dto.Code, state.verifier)
+	if err != nil {
+		log.WithError(err).WithField("provider", provider.ID).Warn("code of single sign-on provider couldn't be exchanged")
+		return nil, ErrSSOProvider
+	}
+	claims, err := verifySSOIDToken(provider, idToken, state.nonce)
+	if err != nil {
+		log.WithError(err).WithField("provider", provider.ID).Warn("id token of single sign-on provider couldn't be verified")
+		return nil, ErrSSOProvider
+	}
+	return ssoUser(s, provider, claims)
+}
+
+// ssoUser returns the user linked to the subject of the claims
+func ssoUser(s storage.Store, provider *config.SSOProvider, claims jwt.MapClaims) (*model.User, error) {
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return nil, ErrSSOProvider
+	}
+	if identity, err := s.SSOIdentities().FindBySubject(provider.ID, subject); err == nil {
+		return s.Users().FindByID(identity.UserID)
+	}
+
+	// Unverified emails could be set to the email of anyone
+	email, _ := claims["email"].(string)
+	verified, _ := claims["email_verified"].(bool)
+	if email == "" || !verified {
+		return nil, ErrSSOUser
+	}
+	user, err := s.Users().FindByEmail(email)
+	if err != nil {
+		return nil, ErrSSOUser
+	}
+
+	if err := linkSSOIdentity(s, user, provider.ID, subject); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// linkSSOIdentity signs the user in with the subject of the provider from now on
+func linkSSOIdentity(s storage.Store, user *model.User, provider, subject string) error {
+	if _, err := s.SSOIdentities().Save(&model.SSOIdentity{UserID: user.ID, Provider: provider, Subject: subject}); err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"event":    "sso_linked",
+		"user_id":  user.ID,
+		"provider": provider,
+		"subject":  subject,
+	}).Info("single sign-on account is linked")
+	return nil
+}
+
+// exchangeSSOCode redeems the code at the token endpoint and returns the id token
+func exchangeSSOCode(provider *config.SSOProvider, metadata *ssoProviderMetadata, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {provider.RedirectURL},
+		"code_verifier": {verifier},
+	}
+	if provider.ClientSecret == "" {
+		form.Set("client_id", provider.ClientID)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, metadata.discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if provider.ClientSecret != "" {
+		req.SetBasicAuth(url.QueryEscape(provider.ClientID), url.QueryEscape(provider.ClientSecret))
+	}
+
+	resp, err := ssoHTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var token struct {
+		model.OIDCTokenDTO
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("token response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint: %s %s %s", resp.Status, token.Error, token.ErrorDescription)
+	}
+	if token.IDToken == "" {
+		return "", errors.New("token response has no id_token")
+	}
+	return token.IDToken, nil
+}
+
+// verifySSOIDToken checks the signature, issuer, audience, expiry and nonce of the id token
+func verifySSOIDToken(provider *config.SSOProvider, raw, nonce string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+		if token.Method != jwt.SigningMethodRS256 {
+			return nil, fmt.Errorf("unexpected signing method %v", token.Header["alg"])
+		}
+		kid, _ := token.Header["kid"].(string)
+		return ssoSigningKey(provider, kid)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if iss, _ := claims["iss"].(string); strings.TrimRight(iss, "/") != strings.TrimRight(provider.Issuer, "/") {
+		return nil, fmt.Errorf("issuer %q doesn't match", iss)
+	}
+	if !claims.VerifyAudience(provider.ClientID, true) && !ssoAudienceContains(claims["aud"], provider.ClientID) {
+		return nil, errors.New("audience doesn't match")
+	}
+	if _, ok := claims["exp"]; !ok {
+		return nil, errors.New("id token doesn't expire")
+	}
+	if n, _ := claims["nonce"].(string); n != nonce {
+		return nil, errors.New("nonce doesn't match")
+	}
+	return claims, nil
+}
+
+func ssoAudienceContains(aud interface{}, clientID string) bool {
+	list, ok := aud.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, a := range list {
+		if a == clientID {
+			return true
+		}
+	}
+	return false
+}
+
+// ssoSigningKey finds the key of the kid, the keys are fetched again once for unknown
+// kids since providers rotate them
+func ssoSigningKey(provider *config.SSOProvider, kid string) (*rsa.PublicKey, error) {
+	for _, refresh := range []bool{false, true} {
+		metadata, err := ssoProviderMetadataOf(provider, refresh)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := metadata.keys[kid]; ok {
+			return key, nil
+		}
+		// Providers with a single key may leave the kid out
+		if kid == "" && len(metadata.keys) == 1 {
+			for _, key := range metadata.keys {
+				return key, nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+// ssoProviderMetadataOf returns the discovery document and keys of the provider,
+// refresh fetches them even when the cached ones aren't old
+func ssoProviderMetadataOf(provider *config.SSOProvider, refresh bool) (*ssoProviderMetadata, error) {
+	ssoMetadata.Lock()
+	defer ssoMetadata.Unlock()
+
+	cached, ok := ssoMetadata.m[provider.ID]
+	if ok && !refresh && time.Since(cached.fetched) < ssoMetadataMaxAge {
+		return cached, nil
+	}
+
+	discovery := new(model.OIDCDiscoveryDTO)
+	if err := ssoGetJSON(strings.TrimRight(provider.Issuer, "/")+"/.well-known/openid-configuration", discovery); err != nil {
+		return nil, err
+	}
+	if strings.TrimRight(discovery.Issuer, "/") != strings.TrimRight(provider.Issuer, "/") {
+		return nil, fmt.Errorf("issuer of the discovery document is %q", discovery.Issuer)
+	}
+	jwks := new(model.JWKSDTO)
+	if err := ssoGetJSON(discovery.JWKSURI, jwks); err != nil {
+		return nil, err
+	}
+
+	metadata := &ssoProviderMetadata{discovery: discovery, keys: map[string]*rsa.PublicKey{}, fetched: time.Now()}
+	for _, jwk := range jwks.Keys {
+		if jwk.KeyType != "RSA" || (jwk.Use != "" && jwk.Use != "sig") {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(jwk.Modulus)
+		e, errE := base64.RawURLEncoding.DecodeString(jwk.Exponent)
+		if errN != nil || errE != nil || len(e) == 0 || len(e) > 4 {
+			continue
+		}
+		metadata.keys[jwk.KeyID] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	ssoMetadata.m[provider.ID] = metadata
+	return metadata, nil
+}
+
+func ssoGetJSON(u string, v interface{}) error {
+	resp, err := ssoHTTPClient.Get(u)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func ssoProviders() ([]config.SSOProvider, error) {
+	var providers []config.SSOProvider
+	err := viper.UnmarshalKey("sso.providers", &providers)
+	return providers, err
+}
+
+func findSSOProvider(id string) (*config.SSOProvider, error) {
+	providers, err := ssoProviders()
+	if err != nil {
+		return nil, err
+	}
+	for i := range providers {
+		if providers[i].ID == id && id != "" {
+			return &providers[i], nil
+		}
+	}
+	return nil, errSSOUnknownProvider
+}
+
+func ssoRandom() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
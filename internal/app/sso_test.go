@@ -0,0 +1,44 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestSSOUser(t *testing.T) {
+	provider := &config.SSOProvider{ID: "okta"}
+	user := &model.User{ID: 3, Email: "test@passwall.io"}
+	notFound := errors.New("record not found")
+
+	mocks := storagetest.NewMocks()
+	mocks.SSOIdentities.On("FindBySubject", "okta", "linked").Return(&model.SSOIdentity{UserID: 3}, nil)
+	mocks.SSOIdentities.On("FindBySubject", "okta", mock.Anything).Return(nil, notFound)
+	mocks.SSOIdentities.On("Save", mock.Anything).Return(nil, nil)
+	mocks.Users.On("FindByID", uint(3)).Return(user, nil)
+	mocks.Users.On("FindByEmail", "test@passwall.io").Return(user, nil)
+	mocks.Users.On("FindByEmail", mock.Anything).Return(nil, notFound)
+
+	// Linked subjects don't need the email
+	found, err := ssoUser(mocks.Store, provider, jwt.MapClaims{"sub": "linked"})
+	assert.NoError(t, err)
+	assert.Equal(t, user, found)
+
+	_, err = ssoUser(mocks.Store, provider, jwt.MapClaims{"sub": "new", "email": "test@passwall.io"})
+	assert.Equal(t, ErrSSOUser, err)
+	_, err = ssoUser(mocks.Store, provider, jwt.MapClaims{"sub": "new", "email": "other@passwall.io", "email_verified": true})
+	assert.Equal(t, ErrSSOUser, err)
+	_, err = ssoUser(mocks.Store, provider, jwt.MapClaims{"email": "test@passwall.io", "email_verified": true})
+	assert.Equal(t, ErrSSOProvider, err)
+
+	found, err = ssoUser(mocks.Store, provider, jwt.MapClaims{"sub": "new", "email": "test@passwall.io", "email_verified": true})
+	assert.NoError(t, err)
+	assert.Equal(t, user, found)
+	mocks.SSOIdentities.AssertCalled(t, "Save", &model.SSOIdentity{UserID: 3, Provider: "okta", Subject: "new"})
+}
@@ -0,0 +1,115 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/storage/filter"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// ErrInvalidSyncToken is the error of a sync token which the server didn't make
+var ErrInvalidSyncToken = errors.New("Invalid sync token")
+
+// SyncChanges are the changes of a vault since a sync token
+type SyncChanges struct {
+	Changed []interface{} // item pointers
+	Deleted []model.ItemRefDTO
+	Token   string // asks the next sync for the changes since this one
+	Reset   bool   // the token was of another counter or before a purge, all items are sent
+	// Revision is the value of the sync counter in the token, for the clients which
+	// still sync with ?since=
+	Revision int64
+}
+
+// Sync returns the items of all types created, updated or restored since the sync token,
+// and the type and id of the items deleted since then. An empty token returns all items of
+// the vault. Tokens are the epoch of the sync counter of the vault with the revision they
+// were made at, when either changed on the server the sync starts over with Reset.
+func Sync(s storage.Store, token string, schema string) (*SyncChanges, error) {
+	defer tracing.Start("app.Sync").End()
+
+	since := int64(0)
+	epoch := ""
+	if token != "" {
+		var err error
+		if epoch, since, err = parseSyncToken(token); err != nil {
+			return nil, err
+		}
+	}
+
+	// The counter is read first, the changes after it are sent again by the next sync
+	counter, err := s.SyncCounters().Find(schema)
+	if err != nil {
+		return nil, err
+	}
+	changes := &SyncChanges{
+		Deleted:  []model.ItemRefDTO{},
+		Token:    fmt.Sprintf("%s.%d", counter.Epoch, counter.Value),
+		Revision: counter.Value,
+	}
+	if token != "" && (epoch != counter.Epoch || since > counter.Value || since < counter.Purged) {
+		changes.Reset = true
+		since = 0
+	}
+
+	argsStr := map[string]string{"order": "updated_at desc"}
+	if since > 0 {
+		argsStr[filter.SyncAfter] = strconv.FormatInt(since, 10)
+	}
+	if changes.Changed, err = findItemsOfAllTypes(s, argsStr, schema); err != nil {
+		return nil, err
+	}
+	if since == 0 {
+		return changes, nil
+	}
+
+	for _, itemType := range ItemTypes {
+		items, err := newItems(itemType)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Trash().FindDeleted(itemTable(itemType, schema), items); err != nil {
+			return nil, err
+		}
+
+		v := reflect.ValueOf(items).Elem()
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i)
+			if item.FieldByName("SyncRevision").Int() <= since {
+				continue
+			}
+			changes.Deleted = append(changes.Deleted, model.ItemRefDTO{Type: itemType, ID: uint(item.FieldByName("ID").Uint())})
+		}
+	}
+	return changes, nil
+}
+
+// SyncRevisionToken returns the token of a revision of the sync counter of the vault, the
+// one clients which still sync with ?since= send. Their revisions from before sync tokens
+// were times, which are ahead of the counter, so they start over once.
+func SyncRevisionToken(s storage.Store, revision int64, schema string) (string, error) {
+	counter, err := s.SyncCounters().Find(schema)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.%d", counter.Epoch, revision), nil
+}
+
+// parseSyncToken returns the epoch and revision of the token
+func parseSyncToken(token string) (string, int64, error) {
+	i := strings.LastIndex(token, ".")
+	if i <= 0 {
+		return "", 0, ErrInvalidSyncToken
+	}
+	revision, err := strconv.ParseInt(token[i+1:], 10, 64)
+	if err != nil || revision < 0 {
+		return "", 0, ErrInvalidSyncToken
+	}
+	return token[:i], revision, nil
+}
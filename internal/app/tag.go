@@ -0,0 +1,90 @@
+package app
+
+import (
+	"reflect"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+)
+
+// CreateTag creates a new tag and saves it to the store
+func CreateTag(s storage.Store, dto *model.TagDTO, schema string) (*model.Tag, error) {
+	defer tracing.Start("app.CreateTag").End()
+
+	return s.Tags().Save(model.ToTag(dto), schema)
+}
+
+// UpdateTag renames the tag
+func UpdateTag(s storage.Store, tag *model.Tag, dto *model.TagDTO, schema string) (*model.Tag, error) {
+	defer tracing.Start("app.UpdateTag").End()
+
+	tag.Name = dto.Name
+	return s.Tags().Save(tag, schema)
+}
+
+// SetItemTags replaces the tags of the item pointer with the tags of the ids which exist
+// and sets its Tags. Nil keeps the tags the item has, for clients which don't know them.
+func SetItemTags(s storage.Store, item interface{}, tagIDs []uint, schema string) error {
+	if tagIDs == nil {
+		return LoadItemTags(s, item, schema)
+	}
+
+	tags := []uint{}
+	seen := map[uint]bool{}
+	for _, id := range tagIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		if _, err := s.Tags().FindByID(id, schema); err == nil {
+			tags = append(tags, id)
+		}
+	}
+
+	v := reflect.ValueOf(item).Elem()
+	itemID := uint(v.FieldByName("ID").Uint())
+	if err := s.Tags().SetItemTags(ItemTypeOf(item), itemID, tags, schema); err != nil {
+		return err
+	}
+	v.FieldByName("Tags").Set(reflect.ValueOf(tags))
+	return nil
+}
+
+// LoadItemTags sets the Tags of the item pointer, or of the items of a slice of models
+func LoadItemTags(s storage.Store, items interface{}, schema string) error {
+	v := reflect.ValueOf(items)
+	if v.Kind() == reflect.Ptr && v.Elem().Kind() == reflect.Struct {
+		v = reflect.Append(reflect.MakeSlice(reflect.SliceOf(v.Type()), 0, 1), v)
+	} else {
+		v = reflect.Indirect(v)
+	}
+	if v.Len() == 0 {
+		return nil
+	}
+
+	elem := func(i int) reflect.Value {
+		return reflect.Indirect(v.Index(i))
+	}
+	itemType := ItemTypeOf(elem(0).Addr().Interface())
+	ids := make([]uint, v.Len())
+	for i := range ids {
+		ids[i] = uint(elem(i).FieldByName("ID").Uint())
+	}
+
+	itemTags, err := s.Tags().FindItemTags(itemType, ids, schema)
+	if err != nil {
+		return err
+	}
+	tags := map[uint][]uint{}
+	for _, itemTag := range itemTags {
+		tags[itemTag.ItemID] = append(tags[itemTag.ItemID], itemTag.TagID)
+	}
+	for i, id := range ids {
+		if tags[id] == nil {
+			tags[id] = []uint{}
+		}
+		elem(i).FieldByName("Tags").Set(reflect.ValueOf(tags[id]))
+	}
+	return nil
+}
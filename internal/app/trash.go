@@ -0,0 +1,118 @@
+package app
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// itemTable returns the table of the item type in the schema, e.g. user1.credit_cards
+func itemTable(itemType string, schema string) string {
+	return schema + "." + strings.Replace(itemType, "-", "_", -1)
+}
+
+// newItems returns a pointer to an empty slice of the models of the item type
+func newItems(itemType string) (interface{}, error) {
+	switch itemType {
+	case LoginItem:
+		return &[]model.Login{}, nil
+	case CreditCardItem:
+		return &[]model.CreditCard{}, nil
+	case BankAccountItem:
+		return &[]model.BankAccount{}, nil
+	case NoteItem:
+		return &[]model.Note{}, nil
+	case EmailItem:
+		return &[]model.Email{}, nil
+	case ServerItem:
+		return &[]model.Server{}, nil
+	}
+	return nil, errUnknownItemType
+}
+
+// Trash returns the deleted items of all types in the vault, the last deleted first. The
+// items are models, callers check the canaries before they turn them into DTOs.
+func Trash(s storage.Store, schema string) ([]model.TrashItemDTO, error) {
+	defer tracing.Start("app.Trash").End()
+
+	trash := []model.TrashItemDTO{}
+	for _, itemType := range ItemTypes {
+		items, err := newItems(itemType)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.Trash().FindDeleted(itemTable(itemType, schema), items); err != nil {
+			return nil, err
+		}
+
+		v := reflect.ValueOf(items).Elem()
+		for i := 0; i < v.Len(); i++ {
+			item := v.Index(i).Addr()
+			deletedAt := item.Elem().FieldByName("DeletedAt").Interface().(*time.Time)
+			trash = append(trash, model.TrashItemDTO{
+				Type:      itemType,
+				DeletedAt: *deletedAt,
+				Item:      item.Interface(),
+			})
+		}
+	}
+
+	sort.SliceStable(trash, func(i, j int) bool {
+		return trash[i].DeletedAt.After(trash[j].DeletedAt)
+	})
+	return trash, nil
+}
+
+// RestoreItem moves the deleted item back into the vault
+func RestoreItem(s storage.Store, itemType string, id uint, schema string) error {
+	defer tracing.Start("app.RestoreItem").End()
+
+	if err := s.Trash().Restore(itemTable(itemType, schema), id); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"event":     "item_restored",
+		"schema":    schema,
+		"item_type": itemType,
+		"item_id":   id,
+	}).Info("item restored from the trash")
+	return nil
+}
+
+// PurgeItem deletes the deleted item permanently, items which aren't in the trash are
+// kept
+func PurgeItem(s storage.Store, itemType string, id uint, schema string) error {
+	defer tracing.Start("app.PurgeItem").End()
+
+	// Syncs since before the purge can't send its deletion anymore, they start over
+	if err := s.SyncCounters().MarkPurged(schema); err != nil {
+		return err
+	}
+	if err := s.Trash().Purge(itemTable(itemType, schema), id); err != nil {
+		return err
+	}
+	if err := s.ItemVersions().DeleteByItem(itemType, id, schema); err != nil {
+		return err
+	}
+	if err := deleteItemAttachments(s, itemType, id, schema); err != nil {
+		return err
+	}
+	if err := s.Tags().SetItemTags(itemType, id, []uint{}, schema); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"event":     "item_purged",
+		"schema":    schema,
+		"item_type": itemType,
+		"item_id":   id,
+	}).Info("item purged from the trash")
+	return nil
+}
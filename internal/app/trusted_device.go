@@ -0,0 +1,111 @@
+package app
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+const deviceTokenBytes = 32
+
+// TrustDevice registers the device of a sign in which verified the second factor and
+// returns its device token. It's empty when server.trustedDeviceDuration turns trusted
+// devices off. The token isn't stored, only its hash is.
+func TrustDevice(s storage.Store, user *model.User, dto *model.TrustDeviceDTO, session *Session) (string, error) {
+	period := viper.GetString("server.trustedDeviceDuration")
+	if period == "" {
+		period = "30d"
+	}
+	duration, err := parsePeriod(period)
+	if err != nil || !dto.TrustDevice || !session.TwoFactor {
+		return "", nil
+	}
+
+	b := make([]byte, deviceTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(b)
+
+	device, err := s.TrustedDevices().Save(&model.TrustedDevice{
+		UserID:      user.ID,
+		Name:        dto.DeviceName,
+		Fingerprint: hashAccessToken(dto.DeviceFingerprint),
+		Hash:        hashAccessToken(raw),
+		SecurityKey: session.SecurityKey,
+		Duress:      session.Duress,
+		ExpiresAt:   time.Now().Add(duration),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	log.WithFields(log.Fields{
+		"event":     "trusted_device_registered",
+		"user_id":   user.ID,
+		"device_id": device.ID,
+		"name":      device.Name,
+	}).Info("device is trusted to skip the second factor")
+	return raw, nil
+}
+
+// FindTrustedDevice returns the trusted device of the sign in if its token and fingerprint
+// match and it isn't expired. Devices trusted with the duress password only skip for it.
+func FindTrustedDevice(s storage.Store, user *model.User, dto *model.DeviceSigninDTO, duress bool) (*model.TrustedDevice, bool) {
+	if dto.DeviceToken == "" || dto.DeviceFingerprint == "" {
+		return nil, false
+	}
+	device, err := s.TrustedDevices().FindByHash(hashAccessToken(dto.DeviceToken))
+	if err != nil || device.UserID != user.ID || device.Duress != duress {
+		return nil, false
+	}
+	if subtle.ConstantTimeCompare([]byte(device.Fingerprint), []byte(hashAccessToken(dto.DeviceFingerprint))) != 1 {
+		return nil, false
+	}
+	now := time.Now()
+	if !now.Before(device.ExpiresAt) {
+		return nil, false
+	}
+
+	device.LastUsedAt = &now
+	if _, err := s.TrustedDevices().Save(device); err != nil {
+		log.WithError(err).WithField("device_id", device.ID).Error("last use of trusted device couldn't be saved")
+	}
+	return device, true
+}
+
+// FindTrustedDevices returns the trusted devices of the user, the decoy vault only sees
+// the ones trusted with the duress password
+func FindTrustedDevices(s storage.Store, user *model.User, duress bool) ([]model.TrustedDevice, error) {
+	devices, err := s.TrustedDevices().FindAllByUserID(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	found := []model.TrustedDevice{}
+	for _, device := range devices {
+		if !duress || device.Duress {
+			found = append(found, device)
+		}
+	}
+	return found, nil
+}
+
+// RevokeTrustedDevice deletes the trusted device, its next sign in asks for the second factor again
+func RevokeTrustedDevice(s storage.Store, device *model.TrustedDevice) error {
+	if err := s.TrustedDevices().Delete(device.ID); err != nil {
+		return err
+	}
+	log.WithFields(log.Fields{
+		"event":     "trusted_device_revoked",
+		"user_id":   device.UserID,
+		"device_id": device.ID,
+		"name":      device.Name,
+	}).Info("trusted device is revoked")
+	return nil
+}
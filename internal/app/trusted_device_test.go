@@ -0,0 +1,55 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestFindTrustedDevice(t *testing.T) {
+	user := &model.User{ID: 1}
+	fingerprint := hashAccessToken("fingerprint")
+	trusted := &model.TrustedDevice{ID: 1, UserID: 1, Fingerprint: fingerprint, ExpiresAt: time.Now().Add(time.Hour)}
+	decoy := &model.TrustedDevice{ID: 2, UserID: 1, Fingerprint: fingerprint, ExpiresAt: time.Now().Add(time.Hour), Duress: true}
+	expired := &model.TrustedDevice{ID: 3, UserID: 1, Fingerprint: fingerprint, ExpiresAt: time.Now().Add(-time.Hour)}
+	other := &model.TrustedDevice{ID: 4, UserID: 2, Fingerprint: fingerprint, ExpiresAt: time.Now().Add(time.Hour)}
+
+	mocks := storagetest.NewMocks()
+	mocks.TrustedDevices.On("FindByHash", hashAccessToken("trusted")).Return(trusted, nil)
+	mocks.TrustedDevices.On("FindByHash", hashAccessToken("decoy")).Return(decoy, nil)
+	mocks.TrustedDevices.On("FindByHash", hashAccessToken("expired")).Return(expired, nil)
+	mocks.TrustedDevices.On("FindByHash", hashAccessToken("other")).Return(other, nil)
+	mocks.TrustedDevices.On("FindByHash", mock.Anything).Return(nil, errors.New("record not found"))
+	mocks.TrustedDevices.On("Save", trusted).Return(trusted, nil).Once()
+	mocks.TrustedDevices.On("Save", decoy).Return(decoy, nil).Once()
+
+	device, ok := FindTrustedDevice(mocks.Store, user, &model.DeviceSigninDTO{DeviceToken: "trusted", DeviceFingerprint: "fingerprint"}, false)
+	assert.True(t, ok)
+	assert.NotNil(t, device.LastUsedAt)
+
+	// The token only works with its fingerprint, for its user and until it expires
+	for _, dto := range []model.DeviceSigninDTO{
+		{DeviceToken: "trusted", DeviceFingerprint: "other"},
+		{DeviceToken: "trusted"},
+		{DeviceToken: "expired", DeviceFingerprint: "fingerprint"},
+		{DeviceToken: "other", DeviceFingerprint: "fingerprint"},
+		{DeviceToken: "unknown", DeviceFingerprint: "fingerprint"},
+	} {
+		_, ok := FindTrustedDevice(mocks.Store, user, &dto, false)
+		assert.False(t, ok, dto.DeviceToken)
+	}
+
+	// Devices trusted with the duress password only skip for it, and the other way around
+	_, ok = FindTrustedDevice(mocks.Store, user, &model.DeviceSigninDTO{DeviceToken: "decoy", DeviceFingerprint: "fingerprint"}, false)
+	assert.False(t, ok)
+	_, ok = FindTrustedDevice(mocks.Store, user, &model.DeviceSigninDTO{DeviceToken: "trusted", DeviceFingerprint: "fingerprint"}, true)
+	assert.False(t, ok)
+	_, ok = FindTrustedDevice(mocks.Store, user, &model.DeviceSigninDTO{DeviceToken: "decoy", DeviceFingerprint: "fingerprint"}, true)
+	assert.True(t, ok)
+	mocks.AssertExpectations(t)
+}
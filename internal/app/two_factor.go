@@ -0,0 +1,320 @@
+package app
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/passwall/passwall-server/model"
+	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// TOTP parameters of RFC 6238, the defaults of authenticator apps
+const (
+	totpPeriod = 30
+	totpDigits = 6
+	totpSkew   = 1 // steps accepted before and after the current one, for clock drift
+)
+
+// twoFactorChallengeExpiry limits the time between the master password and the code
+const twoFactorChallengeExpiry = 5 * time.Minute
+
+// twoFactorChallengeMaxFailures wrong codes end a challenge, the next guesses need the
+// master password again
+const twoFactorChallengeMaxFailures = 3
+
+var (
+	// ErrTOTPCode is returned for wrong codes and codes which were used before
+	ErrTOTPCode = errors.New("Two factor code is wrong")
+	// ErrTwoFactorChallenge is returned for changed or expired sign in challenges
+	ErrTwoFactorChallenge = errors.New("Two factor sign in is expired, sign in again")
+
+	errTOTPNotEnrolled = errors.New("TOTP is not enrolled, enroll it first")
+	errTOTPEnabled     = errors.New("two factor authentication is already enabled")
+	errTOTPDisabled    = errors.New("two factor authentication is not enabled")
+
+	totpEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+)
+
+// TOTPCode returns the code of the secret for the time
+func TOTPCode(secret string, t time.Time) (string, error) {
+	key, err := totpEncoding.DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return "", err
+	}
+	return totpCode(key, t.Unix()/totpPeriod), nil
+}
+
+// totpCode is the HOTP value of RFC 4226 for the time step
+func totpCode(key []byte, step int64) string {
+	counter := make([]byte, 8)
+	binary.BigEndian.PutUint64(counter, uint64(step))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, value%1000000)
+}
+
+// EnrollTOTP generates a new secret for the user. It is used only after EnableTOTP
+// confirms a code of it, enrolling again replaces a secret which isn't confirmed yet.
+func EnrollTOTP(s storage.Store, user *model.User) (*model.TOTPEnrollmentDTO, error) {
+	if user.TwoFactorEnabled {
+		return nil, errTOTPEnabled
+	}
+
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	secret := totpEncoding.EncodeToString(key)
+
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	if _, err := s.Users().Save(user); err != nil {
+		return nil, err
+	}
+	return &model.TOTPEnrollmentDTO{Secret: secret, URL: totpURL(user.Email, secret)}, nil
+}
+
+// totpURL is the key URI which authenticator apps read from a QR code
+func totpURL(account, secret string) string {
+	issuer := viper.GetString("server.twoFactorIssuer")
+	if issuer == "" {
+		issuer = "Passwall"
+	}
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(totpDigits))
+	query.Set("period", fmt.Sprint(totpPeriod))
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + issuer + ":" + account,
+		RawQuery: query.Encode(),
+	}
+	return u.String()
+}
+
+// EnableTOTP turns two factor authentication on after the code of the enrolled secret is verified
+func EnableTOTP(s storage.Store, user *model.User, code string) (*model.User, error) {
+	if user.TwoFactorEnabled {
+		return nil, errTOTPEnabled
+	}
+	if user.TOTPSecret == "" {
+		return nil, errTOTPNotEnrolled
+	}
+	if err := VerifyTOTP(s, user, code, time.Now()); err != nil {
+		return nil, err
+	}
+
+	user.TwoFactorEnabled = true
+	user, err := s.Users().Save(user)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"event":   "two_factor_enabled",
+		"user_id": user.ID,
+		"method":  model.TwoFactorTOTP,
+	}).Info("two factor authentication is enabled")
+	return user, nil
+}
+
+// DisableTOTP turns two factor authentication off, a current code confirms it
+func DisableTOTP(s storage.Store, user *model.User, code string) (*model.User, error) {
+	if !user.TwoFactorEnabled {
+		return nil, errTOTPDisabled
+	}
+	if err := VerifyTOTP(s, user, code, time.Now()); err != nil {
+		return nil, err
+	}
+	return clearTOTP(s, user, "")
+}
+
+// ResetTwoFactor removes the authenticator app, the security keys and the trusted devices of the
+// user, admin tells who did it for the audit log when it isn't the user, e.g. after losing the phone
+func ResetTwoFactor(s storage.Store, user *model.User, admin string) (*model.User, error) {
+	if err := s.TrustedDevices().DeleteByUserID(user.ID); err != nil {
+		return nil, err
+	}
+	credentials, err := s.WebAuthnCredentials().All(user.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) > 0 {
+		if err := s.WebAuthnCredentials().DeleteAll(user.Schema); err != nil {
+			return nil, err
+		}
+		fields := log.Fields{
+			"event":   "two_factor_disabled",
+			"user_id": user.ID,
+			"method":  model.TwoFactorSecurityKey,
+		}
+		if admin != "" {
+			fields["admin"] = admin
+		}
+		log.WithFields(fields).Warn("security keys are removed")
+	}
+	return clearTOTP(s, user, admin)
+}
+
+func clearTOTP(s storage.Store, user *model.User, admin string) (*model.User, error) {
+	user.TwoFactorEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+	user, err := s.Users().Save(user)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := log.Fields{
+		"event":   "two_factor_disabled",
+		"user_id": user.ID,
+		"method":  model.TwoFactorTOTP,
+	}
+	if admin != "" {
+		fields["admin"] = admin
+	}
+	log.WithFields(fields).Warn("two factor authentication is disabled")
+	return user, nil
+}
+
+// VerifyTOTP checks the code against the secret of the user. A code is accepted once,
+// so a code seen by someone else can't be used again in its time window.
+func VerifyTOTP(s storage.Store, user *model.User, code string, now time.Time) error {
+	defer tracing.Start("app.VerifyTOTP").End()
+
+	if user.TOTPSecret == "" {
+		return errTOTPNotEnrolled
+	}
+	key, err := totpEncoding.DecodeString(user.TOTPSecret)
+	if err != nil {
+		return err
+	}
+
+	current := now.Unix() / totpPeriod
+	for step := current - totpSkew; step <= current+totpSkew; step++ {
+		if step <= user.TOTPLastStep {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(totpCode(key, step)), []byte(code)) == 1 {
+			user.TOTPLastStep = step
+			_, err := s.Users().Save(user)
+			return err
+		}
+	}
+	return ErrTOTPCode
+}
+
+// TwoFactorMethods returns the second factors the user can sign in with
+func TwoFactorMethods(s storage.Store, user *model.User) ([]string, error) {
+	methods := []string{}
+	if user.TwoFactorEnabled {
+		methods = append(methods, model.TwoFactorTOTP)
+	}
+	credentials, err := s.WebAuthnCredentials().All(user.Schema)
+	if err != nil {
+		return nil, err
+	}
+	if len(credentials) > 0 {
+		methods = append(methods, model.TwoFactorSecurityKey)
+	}
+	return methods, nil
+}
+
+// CreateTwoFactorChallenge returns the token which continues the sign in of the user after the
+// master password. It carries whether the duress password was used and can't access the API.
+func CreateTwoFactorChallenge(user *model.User, duress bool) (string, error) {
+	claims := jwt.MapClaims{
+		"user_id": user.ID,
+		"purpose": "two_factor",
+		"jti":     uuid.NewV4().String(),
+		"exp":     time.Now().Add(twoFactorChallengeExpiry).Unix(),
+	}
+	if duress {
+		claims["duress"] = true
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString([]byte(viper.GetString("server.secret")))
+}
+
+// VerifyTwoFactorChallenge returns the user of the challenge and whether it was a duress sign in.
+// Challenges which FailTwoFactorChallenge ended are expired.
+func VerifyTwoFactorChallenge(s storage.Store, challenge string) (*model.User, bool, error) {
+	claims, ok := twoFactorClaims(challenge)
+	if !ok {
+		return nil, false, ErrTwoFactorChallenge
+	}
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		return nil, false, ErrTwoFactorChallenge
+	}
+	lock, _ := signinDurations()
+	if _, locked := signinWait(s, twoFactorChallengeKey(claims), time.Now(), lock, 0); locked {
+		return nil, false, ErrTwoFactorChallenge
+	}
+
+	user, err := s.Users().FindByID(uint(userID))
+	if err != nil {
+		return nil, false, ErrTwoFactorChallenge
+	}
+	duress, _ := claims["duress"].(bool)
+	return user, duress, nil
+}
+
+// FailTwoFactorChallenge counts a wrong code of the challenge and ends the challenge after
+// twoFactorChallengeMaxFailures of them
+func FailTwoFactorChallenge(s storage.Store, challenge string, now time.Time) {
+	claims, ok := twoFactorClaims(challenge)
+	if !ok {
+		return
+	}
+	lock, _ := signinDurations()
+	if _, locked := countSigninFailure(s, twoFactorChallengeKey(claims), twoFactorChallengeMaxFailures, now, lock); locked {
+		userID, _ := claims["user_id"].(float64)
+		log.WithFields(log.Fields{
+			"event":   "two_factor_challenge_ended",
+			"user_id": uint(userID),
+		}).Warn("two factor sign in is ended after wrong codes")
+	}
+}
+
+// twoFactorClaims returns the claims of a valid sign in challenge
+func twoFactorClaims(challenge string) (jwt.MapClaims, bool) {
+	token, err := verifyToken(challenge)
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+	claims, _ := token.Claims.(jwt.MapClaims)
+	if purpose, _ := claims["purpose"].(string); purpose != "two_factor" {
+		return nil, false
+	}
+	if _, ok := claims["jti"].(string); !ok {
+		return nil, false
+	}
+	return claims, true
+}
+
+// twoFactorChallengeKey is the key of the wrong codes of the challenge in the sign in failures
+func twoFactorChallengeKey(claims jwt.MapClaims) string {
+	return "challenge:" + claims["jti"].(string)
+}
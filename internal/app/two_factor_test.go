@@ -0,0 +1,89 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage/storagetest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// rfcSecret is the key "12345678901234567890" of the test vectors of RFC 6238
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	// The last six digits of the SHA1 vectors
+	tests := []struct {
+		time int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		code, err := TOTPCode(rfcSecret, time.Unix(tt.time, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, tt.code, code, "time %d", tt.time)
+	}
+}
+
+func TestVerifyTOTP(t *testing.T) {
+	user := &model.User{ID: 1, TwoFactorEnabled: true, TOTPSecret: rfcSecret}
+	mocks := storagetest.NewMocks()
+	mocks.Users.On("Save", mock.Anything).Return(user, nil)
+
+	now := time.Unix(1111111111, 0)
+	previous, _ := TOTPCode(rfcSecret, now.Add(-totpPeriod*time.Second))
+	current, _ := TOTPCode(rfcSecret, now)
+	late, _ := TOTPCode(rfcSecret, now.Add(2*totpPeriod*time.Second))
+
+	// Codes of a step of drift pass, older ones don't
+	assert.Equal(t, ErrTOTPCode, VerifyTOTP(mocks.Store, user, late, now))
+	assert.NoError(t, VerifyTOTP(mocks.Store, user, previous, now))
+	assert.NoError(t, VerifyTOTP(mocks.Store, user, current, now))
+
+	// A code works once, and the ones before it are used up with it
+	assert.Equal(t, ErrTOTPCode, VerifyTOTP(mocks.Store, user, current, now))
+	assert.Equal(t, ErrTOTPCode, VerifyTOTP(mocks.Store, user, previous, now))
+	assert.Equal(t, now.Unix()/totpPeriod, user.TOTPLastStep)
+}
+
+func TestTwoFactorChallenge(t *testing.T) {
+	viper.Set("server.secret", "two-factor-test-secret")
+	viper.Set("server.accessTokenExpireDuration", "30m")
+	viper.Set("server.refreshTokenExpireDuration", "15d")
+	viper.Set("server.generatedPasswordLength", 16)
+
+	user := &model.User{ID: 7}
+	mocks := storagetest.NewMocks()
+	mocks.Users.On("FindByID", uint(7)).Return(user, nil)
+	mocks.SigninFailures.On("FindByKey", mock.Anything).Return(nil, gorm.ErrRecordNotFound).Once()
+
+	challenge, err := CreateTwoFactorChallenge(user, true)
+	assert.NoError(t, err)
+	found, duress, err := VerifyTwoFactorChallenge(mocks.Store, challenge)
+	assert.NoError(t, err)
+	assert.Equal(t, user, found)
+	assert.True(t, duress)
+
+	// Session tokens can't skip the master password
+	tokens, err := CreateSessionToken(user, &Session{Start: time.Now()})
+	assert.NoError(t, err)
+	_, _, err = VerifyTwoFactorChallenge(mocks.Store, tokens.AccessToken)
+	assert.Equal(t, ErrTwoFactorChallenge, err)
+	_, _, err = VerifyTwoFactorChallenge(mocks.Store, challenge+"x")
+	assert.Equal(t, ErrTwoFactorChallenge, err)
+
+	// Challenges end after wrong codes
+	until := time.Now().Add(time.Minute)
+	mocks.SigninFailures.On("FindByKey", mock.Anything).Return(&model.SigninFailure{Failures: twoFactorChallengeMaxFailures, LockedUntil: &until}, nil)
+	_, _, err = VerifyTwoFactorChallenge(mocks.Store, challenge)
+	assert.Equal(t, ErrTwoFactorChallenge, err)
+}
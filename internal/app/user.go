@@ -2,17 +2,22 @@ package app
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/passwall/passwall-server/internal/storage"
 	"github.com/passwall/passwall-server/model"
 	uuid "github.com/satori/go.uuid"
+	log "github.com/sirupsen/logrus"
 )
 
 // CreateUser creates a user and saves it to the store
 func CreateUser(s storage.Store, userDTO *model.UserDTO) (*model.User, error) {
 	var err error
-	// Hasing the master password with Bcrypt
-	userDTO.MasterPassword = NewBcrypt([]byte(userDTO.MasterPassword))
+	// Hashing the master password with Argon2id
+	userDTO.MasterPassword, err = NewPasswordHash(userDTO.MasterPassword)
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate secret to use as salt
 	// todo: do not use place in int variables, pass them as const
@@ -38,8 +43,13 @@ func CreateUser(s storage.Store, userDTO *model.UserDTO) (*model.User, error) {
 func UpdateUser(s storage.Store, user *model.User, userDTO *model.UserDTO, isAuthorized bool) (*model.User, error) {
 
 	// TODO: Refactor the contents of updated user with a logical way
-	if userDTO.MasterPassword != "" && NewBcrypt([]byte(userDTO.MasterPassword)) != user.MasterPassword {
-		userDTO.MasterPassword = NewBcrypt([]byte(userDTO.MasterPassword))
+	masterPasswordChanged := userDTO.MasterPassword != ""
+	if masterPasswordChanged {
+		hash, err := NewPasswordHash(userDTO.MasterPassword)
+		if err != nil {
+			return nil, err
+		}
+		userDTO.MasterPassword = hash
 	} else {
 		userDTO.MasterPassword = user.MasterPassword
 	}
@@ -60,6 +70,13 @@ func UpdateUser(s storage.Store, user *model.User, userDTO *model.UserDTO, isAut
 	if err != nil {
 		return nil, err
 	}
+
+	// The vault moves off retired keys and ciphers together with the new master password
+	if masterPasswordChanged {
+		if _, err := QueueReencryption(s, model.ReencryptMasterPassword, user.ID); err != nil {
+			log.WithError(err).WithField("user_id", user.ID).Error("re-encryption couldn't be queued")
+		}
+	}
 	return updatedUser, nil
 }
 
@@ -72,3 +89,71 @@ func GenerateSchema(s storage.Store, user *model.User) (*model.User, error) {
 	}
 	return savedUser, nil
 }
+
+// SetupUser creates the user with its schema and tables
+func SetupUser(s storage.Store, userDTO *model.UserDTO) (*model.User, error) {
+	createdUser, err := CreateUser(s, userDTO)
+	if err != nil {
+		return nil, err
+	}
+
+	updatedUser, err := GenerateSchema(s, createdUser)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.Users().CreateSchema(updatedUser.Schema); err != nil {
+		return nil, err
+	}
+
+	MigrateUserTables(s, updatedUser.Schema)
+
+	return updatedUser, nil
+}
+
+// ResetMasterPassword sets a new master password for the user and ends its sessions
+func ResetMasterPassword(s storage.Store, user *model.User, masterPassword string) (*model.User, error) {
+	userDTO := model.ToUserDTO(user)
+	userDTO.MasterPassword = masterPassword
+	userDTO.EmailVerifiedAt = user.EmailVerifiedAt
+
+	updatedUser, err := UpdateUser(s, user, userDTO, true)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Tokens().Delete(int(user.ID))
+
+	return updatedUser, nil
+}
+
+// PurgeUser deletes the user with its sessions, schema and all data in it
+func PurgeUser(s storage.Store, user *model.User) error {
+	s.Tokens().Delete(int(user.ID))
+	if err := s.MachineAccounts().DeleteByUserID(user.ID); err != nil {
+		return err
+	}
+	if err := s.Policies().DeleteByUserID(user.ID); err != nil {
+		return err
+	}
+	if err := s.SSOIdentities().DeleteByUserID(user.ID); err != nil {
+		return err
+	}
+	if err := s.AccessTokens().DeleteByUserID(user.ID); err != nil {
+		return err
+	}
+	if err := s.TrustedDevices().DeleteByUserID(user.ID); err != nil {
+		return err
+	}
+	if err := s.SigninFailures().DeleteByKey("email:" + strings.ToLower(user.Email)); err != nil {
+		return err
+	}
+	if user.DuressPassword != "" {
+		deleteVaultAttachments(DecoySchema(user.Schema))
+		if err := s.Users().DropSchema(DecoySchema(user.Schema)); err != nil {
+			return err
+		}
+	}
+	deleteVaultAttachments(user.Schema)
+	return s.Users().Delete(user.ID, user.Schema)
+}
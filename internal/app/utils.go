@@ -4,8 +4,13 @@ import (
 	"crypto/md5"
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
+	"strconv"
+	"time"
 )
 
+var errPeriod = errors.New("period should be a number with m, h or d suffix e.g. 30d")
+
 // GetMD5Hash ...
 func GetMD5Hash(text []byte) string {
 	hasher := md5.New()
@@ -19,3 +24,25 @@ func RandomMD5Hash() string {
 	rand.Read(b)
 	return GetMD5Hash(b)
 }
+
+// parsePeriod parses periods like "45m", "12h" and "30d"
+func parsePeriod(period string) (time.Duration, error) {
+	if len(period) < 2 {
+		return 0, errPeriod
+	}
+
+	n, err := strconv.Atoi(period[:len(period)-1])
+	if err != nil || n <= 0 {
+		return 0, errPeriod
+	}
+
+	switch period[len(period)-1] {
+	case 'm':
+		return time.Duration(n) * time.Minute, nil
+	case 'h':
+		return time.Duration(n) * time.Hour, nil
+	case 'd':
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	return 0, errPeriod
+}
@@ -0,0 +1,282 @@
+package app
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app/webauthn"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// webauthnChallengeExpiry limits the time the user has to touch the security key
+const webauthnChallengeExpiry = 5 * time.Minute
+
+var (
+	// ErrSecurityKey is returned for credentials which don't verify, the cause is logged
+	ErrSecurityKey = errors.New("Security key couldn't be verified")
+
+	errWebAuthnConfig       = errors.New("webauthn.rpID or server.domain has to be set for security keys")
+	errNoSecurityKey        = errors.New("no security key is registered")
+	errSecurityKeyDuplicate = errors.New("security key is already registered")
+
+	// webauthnChallenges wait for the response of the browser, each can be used once
+	webauthnChallenges = struct {
+		sync.Mutex
+		m map[string]*webauthnChallenge
+	}{m: map[string]*webauthnChallenge{}}
+)
+
+type webauthnChallenge struct {
+	challenge webauthn.Bytes
+	expires   time.Time
+}
+
+// webauthnConfig returns the relying party of the server, the domain and origin
+// default to server.domain
+func webauthnConfig() (*webauthn.Config, error) {
+	config := &webauthn.Config{
+		RPID:   viper.GetString("webauthn.rpID"),
+		RPName: viper.GetString("webauthn.rpName"),
+	}
+	domain := strings.TrimSuffix(viper.GetString("server.domain"), "/")
+	if config.RPID == "" {
+		if u, err := url.Parse(domain); err == nil {
+			config.RPID = u.Hostname()
+		}
+	}
+	if config.RPID == "" {
+		return nil, errWebAuthnConfig
+	}
+	if config.RPName == "" {
+		config.RPName = "Passwall"
+	}
+
+	for _, origin := range strings.Split(viper.GetString("webauthn.origins"), ",") {
+		if origin = strings.TrimSpace(origin); origin != "" {
+			config.Origins = append(config.Origins, strings.TrimSuffix(origin, "/"))
+		}
+	}
+	if len(config.Origins) == 0 && domain != "" {
+		config.Origins = []string{domain}
+	}
+
+	if timeout := viper.GetString("webauthn.timeout"); timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("webauthn.timeout: %w", err)
+		}
+		config.Timeout = d
+	}
+	return config, nil
+}
+
+// BeginWebAuthnRegistration returns the options of navigator.credentials.create()
+// which register a new security key of the user
+func BeginWebAuthnRegistration(s storage.Store, user *model.User) (*webauthn.CreationOptions, error) {
+	config, err := webauthnConfig()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := webauthnCredentialIDs(s, user)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := newWebAuthnChallenge(fmt.Sprintf("register:%d", user.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	account := webauthn.User{ID: user.UUID.Bytes(), Name: user.Email, DisplayName: user.Name}
+	if account.DisplayName == "" {
+		account.DisplayName = user.Email
+	}
+	return config.CreationOptions(challenge, account, ids), nil
+}
+
+// FinishWebAuthnRegistration verifies the new credential of the browser and saves it
+func FinishWebAuthnRegistration(s storage.Store, user *model.User, dto *model.WebAuthnRegistrationDTO) (*model.WebAuthnCredential, error) {
+	config, err := webauthnConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := new(webauthn.RegistrationResponse)
+	if err := json.Unmarshal(dto.Credential, resp); err != nil {
+		return nil, ErrSecurityKey
+	}
+	challenge := takeWebAuthnChallenge(fmt.Sprintf("register:%d", user.ID))
+	credential, err := config.VerifyRegistration(resp, challenge)
+	if err != nil {
+		log.WithError(err).WithField("user_id", user.ID).Warn("security key couldn't be registered")
+		return nil, ErrSecurityKey
+	}
+
+	id := base64.RawURLEncoding.EncodeToString(credential.ID)
+	if _, err := s.WebAuthnCredentials().FindByCredentialID(id, user.Schema); err == nil {
+		return nil, errSecurityKeyDuplicate
+	}
+
+	saved, err := s.WebAuthnCredentials().Save(&model.WebAuthnCredential{
+		Name:         dto.Name,
+		CredentialID: id,
+		PublicKey:    base64.RawURLEncoding.EncodeToString(credential.PublicKey),
+		AAGUID:       fmt.Sprintf("%x", credential.AAGUID),
+		SignCount:    credential.SignCount,
+	}, user.Schema)
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{
+		"event":   "two_factor_enabled",
+		"user_id": user.ID,
+		"method":  model.TwoFactorSecurityKey,
+		"name":    dto.Name,
+	}).Info("security key is registered")
+	return saved, nil
+}
+
+// RemoveWebAuthnCredential deletes a security key of the user
+func RemoveWebAuthnCredential(s storage.Store, user *model.User, id uint) error {
+	credential, err := s.WebAuthnCredentials().FindByID(id, user.Schema)
+	if err != nil {
+		return err
+	}
+	if err := s.WebAuthnCredentials().Delete(credential.ID, user.Schema); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"event":   "two_factor_disabled",
+		"user_id": user.ID,
+		"method":  model.TwoFactorSecurityKey,
+		"name":    credential.Name,
+	}).Warn("security key is removed")
+	return nil
+}
+
+// BeginWebAuthnSignin returns the options of navigator.credentials.get() which
+// finish the sign in of the user with one of the security keys
+func BeginWebAuthnSignin(s storage.Store, user *model.User) (*webauthn.RequestOptions, error) {
+	config, err := webauthnConfig()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := webauthnCredentialIDs(s, user)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, errNoSecurityKey
+	}
+	challenge, err := newWebAuthnChallenge(fmt.Sprintf("signin:%d", user.ID))
+	if err != nil {
+		return nil, err
+	}
+	return config.RequestOptions(challenge, ids), nil
+}
+
+// FinishWebAuthnSignin verifies the assertion of a security key of the user
+// and moves its signature counter forward
+func FinishWebAuthnSignin(s storage.Store, user *model.User, raw json.RawMessage) error {
+	config, err := webauthnConfig()
+	if err != nil {
+		return err
+	}
+
+	resp := new(webauthn.AssertionResponse)
+	if err := json.Unmarshal(raw, resp); err != nil {
+		return ErrSecurityKey
+	}
+	challenge := takeWebAuthnChallenge(fmt.Sprintf("signin:%d", user.ID))
+
+	saved, err := s.WebAuthnCredentials().FindByCredentialID(base64.RawURLEncoding.EncodeToString(resp.RawID), user.Schema)
+	if err != nil {
+		return ErrSecurityKey
+	}
+	publicKey, err := base64.RawURLEncoding.DecodeString(saved.PublicKey)
+	if err != nil {
+		return err
+	}
+
+	credential := &webauthn.Credential{ID: resp.RawID, PublicKey: publicKey, SignCount: saved.SignCount}
+	signCount, err := config.VerifyAssertion(resp, challenge, credential)
+	if errors.Is(err, webauthn.ErrSignCount) {
+		log.WithFields(log.Fields{
+			"event":   "security_key_cloned",
+			"user_id": user.ID,
+			"name":    saved.Name,
+		}).Error("signature counter of a security key went back, it may be cloned")
+		return ErrSecurityKey
+	}
+	if err != nil {
+		log.WithError(err).WithField("user_id", user.ID).Warn("security key couldn't be verified")
+		return ErrSecurityKey
+	}
+
+	now := time.Now()
+	saved.SignCount = signCount
+	saved.LastUsedAt = &now
+	_, err = s.WebAuthnCredentials().Save(saved, user.Schema)
+	return err
+}
+
+func webauthnCredentialIDs(s storage.Store, user *model.User) ([][]byte, error) {
+	credentials, err := s.WebAuthnCredentials().All(user.Schema)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([][]byte, 0, len(credentials))
+	for i := range credentials {
+		id, err := base64.RawURLEncoding.DecodeString(credentials[i].CredentialID)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// newWebAuthnChallenge replaces the challenge of the key, a ceremony started again wins
+func newWebAuthnChallenge(key string) (webauthn.Bytes, error) {
+	challenge, err := webauthn.NewChallenge()
+	if err != nil {
+		return nil, err
+	}
+
+	webauthnChallenges.Lock()
+	defer webauthnChallenges.Unlock()
+
+	// Drop challenges of ceremonies which were never finished
+	now := time.Now()
+	for k, c := range webauthnChallenges.m {
+		if now.After(c.expires) {
+			delete(webauthnChallenges.m, k)
+		}
+	}
+
+	webauthnChallenges.m[key] = &webauthnChallenge{challenge: challenge, expires: now.Add(webauthnChallengeExpiry)}
+	return challenge, nil
+}
+
+// takeWebAuthnChallenge returns the challenge of the key and forgets it, nil when it expired
+func takeWebAuthnChallenge(key string) webauthn.Bytes {
+	webauthnChallenges.Lock()
+	c, ok := webauthnChallenges.m[key]
+	delete(webauthnChallenges.m, key)
+	webauthnChallenges.Unlock()
+
+	if !ok || time.Now().After(c.expires) {
+		return nil
+	}
+	return c.challenge
+}
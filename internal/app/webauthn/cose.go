@@ -0,0 +1,129 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"errors"
+	"math/big"
+)
+
+// COSE algorithms of the credential keys, in the order the server prefers them
+const (
+	AlgES256 = -7
+	AlgEdDSA = -8
+	AlgRS256 = -257
+)
+
+// COSE key types and the labels of their parameters, see RFC 8152
+const (
+	coseKeyType   = 1
+	coseAlg       = 3
+	coseOKP       = 1
+	coseEC2       = 2
+	coseRSA       = 3
+	coseCurve     = -1
+	coseX         = -2
+	coseY         = -3
+	coseRSAN      = -1
+	coseRSAE      = -2
+	coseP256      = 1
+	coseEd25519   = 6
+	rsaMinKeySize = 2048
+)
+
+var (
+	errPublicKey = errors.New("webauthn: public key is not supported")
+	errSignature = errors.New("webauthn: signature is not valid")
+)
+
+// publicKey is a decoded COSE key of a credential
+type publicKey struct {
+	alg int64
+	key crypto.PublicKey
+}
+
+// parsePublicKey decodes the COSE key of a credential, it returns the bytes after it
+func parsePublicKey(data []byte) (*publicKey, []byte, error) {
+	item, rest, err := decodeCBOR(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	m, ok := item.(map[interface{}]interface{})
+	if !ok {
+		return nil, nil, errPublicKey
+	}
+	kty, _ := m[int64(coseKeyType)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+
+	switch {
+	case kty == coseEC2 && alg == AlgES256:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		y, _ := m[int64(coseY)].([]byte)
+		if crv != coseP256 || len(x) != 32 || len(y) != 32 {
+			return nil, nil, errPublicKey
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, nil, errPublicKey
+		}
+		return &publicKey{alg: alg, key: key}, rest, nil
+
+	case kty == coseOKP && alg == AlgEdDSA:
+		crv, _ := m[int64(coseCurve)].(int64)
+		x, _ := m[int64(coseX)].([]byte)
+		if crv != coseEd25519 || len(x) != ed25519.PublicKeySize {
+			return nil, nil, errPublicKey
+		}
+		return &publicKey{alg: alg, key: ed25519.PublicKey(x)}, rest, nil
+
+	case kty == coseRSA && alg == AlgRS256:
+		n, _ := m[int64(coseRSAN)].([]byte)
+		e, _ := m[int64(coseRSAE)].([]byte)
+		if len(n)*8 < rsaMinKeySize || len(e) == 0 || len(e) > 4 {
+			return nil, nil, errPublicKey
+		}
+		exponent := int(new(big.Int).SetBytes(e).Int64())
+		return &publicKey{alg: alg, key: &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: exponent}}, rest, nil
+	}
+	return nil, nil, errPublicKey
+}
+
+// verifySignature checks the signature of the data with the key for the COSE algorithm
+func verifySignature(key crypto.PublicKey, alg int64, data, signature []byte) error {
+	digest := sha256.Sum256(data)
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if alg != AlgES256 {
+			return errSignature
+		}
+		// Signatures are DER encoded, Go 1.14 has no ecdsa.VerifyASN1
+		var sig struct{ R, S *big.Int }
+		if rest, err := asn1.Unmarshal(signature, &sig); err != nil || len(rest) != 0 {
+			return errSignature
+		}
+		if !ecdsa.Verify(k, digest[:], sig.R, sig.S) {
+			return errSignature
+		}
+		return nil
+
+	case ed25519.PublicKey:
+		if alg != AlgEdDSA || !ed25519.Verify(k, data, signature) {
+			return errSignature
+		}
+		return nil
+
+	case *rsa.PublicKey:
+		if alg != AlgRS256 || rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], signature) != nil {
+			return errSignature
+		}
+		return nil
+	}
+	return errPublicKey
+}
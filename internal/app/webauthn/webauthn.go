@@ -0,0 +1,436 @@
+// Package webauthn verifies the registration and assertion ceremonies of WebAuthn, so users can
+// sign in with security keys and platform authenticators as a second factor. The server asks for
+// no attestation: "none", "packed" and "fido-u2f" statements are checked, but their certificates
+// aren't trusted to tell the model of the authenticator.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// Flags of the authenticator data
+const (
+	flagUserPresent = 0x01
+	flagAttested    = 0x40
+	flagExtensions  = 0x80
+)
+
+var (
+	errClientData        = errors.New("webauthn: client data is not valid")
+	errChallenge         = errors.New("webauthn: challenge doesn't match")
+	errOrigin            = errors.New("webauthn: origin is not allowed")
+	errAuthenticatorData = errors.New("webauthn: authenticator data is not valid")
+	errRPID              = errors.New("webauthn: credential is for another relying party")
+	errUserPresence      = errors.New("webauthn: user wasn't present")
+	errAttestation       = errors.New("webauthn: attestation is not valid")
+	errAttestationFormat = errors.New("webauthn: attestation format is not supported")
+	errCredential        = errors.New("webauthn: credential doesn't match")
+
+	// ErrSignCount is returned when the counter of the authenticator goes back, it may be cloned
+	ErrSignCount = errors.New("webauthn: signature counter went back, the authenticator may be cloned")
+)
+
+// Config is the relying party, the server which the credentials are bound to
+type Config struct {
+	RPID    string        // domain of the credentials, e.g. vault.passwall.io
+	RPName  string        // shown by the browser while registering
+	Origins []string      // pages and extensions which can use the credentials, e.g. https://vault.passwall.io
+	Timeout time.Duration // how long the browser waits for the user
+}
+
+// Bytes are base64url encoded in JSON, as the WebAuthn JSON helpers of the browsers do
+type Bytes []byte
+
+// MarshalJSON ...
+func (b Bytes) MarshalJSON() ([]byte, error) {
+	return json.Marshal(base64.RawURLEncoding.EncodeToString(b))
+}
+
+// UnmarshalJSON accepts base64url with or without padding
+func (b *Bytes) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Credential is a registered credential. The public key is COSE encoded.
+type Credential struct {
+	ID        []byte
+	PublicKey []byte
+	SignCount uint32
+	AAGUID    []byte
+}
+
+// RelyingParty ...
+type RelyingParty struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// User is the account the credential is created for
+type User struct {
+	ID          Bytes  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"displayName"`
+}
+
+// CredentialParameter is a key type the server accepts
+type CredentialParameter struct {
+	Type string `json:"type"`
+	Alg  int    `json:"alg"`
+}
+
+// CredentialDescriptor names a credential
+type CredentialDescriptor struct {
+	Type string `json:"type"`
+	ID   Bytes  `json:"id"`
+}
+
+// AuthenticatorSelection ...
+type AuthenticatorSelection struct {
+	ResidentKey      string `json:"residentKey"`
+	UserVerification string `json:"userVerification"`
+}
+
+// CreationOptions is the publicKey argument of navigator.credentials.create()
+type CreationOptions struct {
+	Challenge              Bytes                  `json:"challenge"`
+	RP                     RelyingParty           `json:"rp"`
+	User                   User                   `json:"user"`
+	PubKeyCredParams       []CredentialParameter  `json:"pubKeyCredParams"`
+	Timeout                int64                  `json:"timeout,omitempty"`
+	ExcludeCredentials     []CredentialDescriptor `json:"excludeCredentials"`
+	AuthenticatorSelection AuthenticatorSelection `json:"authenticatorSelection"`
+	Attestation            string                 `json:"attestation"`
+}
+
+// RequestOptions is the publicKey argument of navigator.credentials.get()
+type RequestOptions struct {
+	Challenge        Bytes                  `json:"challenge"`
+	Timeout          int64                  `json:"timeout,omitempty"`
+	RPID             string                 `json:"rpId"`
+	AllowCredentials []CredentialDescriptor `json:"allowCredentials"`
+	UserVerification string                 `json:"userVerification"`
+}
+
+// RegistrationResponse is the credential navigator.credentials.create() returns
+type RegistrationResponse struct {
+	ID       string              `json:"id"`
+	RawID    Bytes               `json:"rawId"`
+	Type     string              `json:"type"`
+	Response AttestationResponse `json:"response"`
+}
+
+// AttestationResponse ...
+type AttestationResponse struct {
+	ClientDataJSON    Bytes `json:"clientDataJSON"`
+	AttestationObject Bytes `json:"attestationObject"`
+}
+
+// AssertionResponse is the credential navigator.credentials.get() returns
+type AssertionResponse struct {
+	ID       string        `json:"id"`
+	RawID    Bytes         `json:"rawId"`
+	Type     string        `json:"type"`
+	Response AssertionData `json:"response"`
+}
+
+// AssertionData ...
+type AssertionData struct {
+	ClientDataJSON    Bytes `json:"clientDataJSON"`
+	AuthenticatorData Bytes `json:"authenticatorData"`
+	Signature         Bytes `json:"signature"`
+	UserHandle        Bytes `json:"userHandle,omitempty"`
+}
+
+// NewChallenge returns a random challenge for a ceremony, it has to be used once
+func NewChallenge() (Bytes, error) {
+	challenge := make([]byte, 32)
+	if _, err := rand.Read(challenge); err != nil {
+		return nil, err
+	}
+	return challenge, nil
+}
+
+// CreationOptions returns the options which register a new credential of the user.
+// Authenticators with one of the excluded credentials refuse to register another.
+func (c *Config) CreationOptions(challenge Bytes, user User, exclude [][]byte) *CreationOptions {
+	return &CreationOptions{
+		Challenge: challenge,
+		RP:        RelyingParty{ID: c.RPID, Name: c.RPName},
+		User:      user,
+		PubKeyCredParams: []CredentialParameter{
+			{Type: "public-key", Alg: AlgES256},
+			{Type: "public-key", Alg: AlgEdDSA},
+			{Type: "public-key", Alg: AlgRS256},
+		},
+		Timeout:            c.Timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(exclude),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "discouraged",
+			UserVerification: "preferred",
+		},
+		Attestation: "none",
+	}
+}
+
+// RequestOptions returns the options which sign in with one of the credentials
+func (c *Config) RequestOptions(challenge Bytes, allow [][]byte) *RequestOptions {
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          c.Timeout.Milliseconds(),
+		RPID:             c.RPID,
+		AllowCredentials: descriptors(allow),
+		UserVerification: "preferred",
+	}
+}
+
+func descriptors(ids [][]byte) []CredentialDescriptor {
+	list := make([]CredentialDescriptor, 0, len(ids))
+	for _, id := range ids {
+		list = append(list, CredentialDescriptor{Type: "public-key", ID: id})
+	}
+	return list
+}
+
+// VerifyRegistration checks the response of a registration with the challenge
+// of its options and returns the new credential
+func (c *Config) VerifyRegistration(resp *RegistrationResponse, challenge Bytes) (*Credential, error) {
+	if resp.Type != "public-key" {
+		return nil, errCredential
+	}
+	if err := c.verifyClientData(resp.Response.ClientDataJSON, "webauthn.create", challenge); err != nil {
+		return nil, err
+	}
+
+	item, rest, err := decodeCBOR(resp.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	attestation, ok := item.(map[interface{}]interface{})
+	if !ok || len(rest) != 0 {
+		return nil, errAttestation
+	}
+	format, _ := attestation["fmt"].(string)
+	statement, _ := attestation["attStmt"].(map[interface{}]interface{})
+	rawAuthData, _ := attestation["authData"].([]byte)
+
+	authData, err := c.parseAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if authData.flags&flagAttested == 0 || !bytes.Equal(authData.credentialID, resp.RawID) {
+		return nil, errCredential
+	}
+
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	if err := verifyAttestation(format, statement, rawAuthData, clientDataHash[:], authData); err != nil {
+		return nil, err
+	}
+
+	return &Credential{
+		ID:        authData.credentialID,
+		PublicKey: authData.rawPublicKey,
+		SignCount: authData.signCount,
+		AAGUID:    authData.aaguid,
+	}, nil
+}
+
+// VerifyAssertion checks the response of a sign in with the challenge of its options against
+// the registered credential. It returns the new signature counter of the credential.
+func (c *Config) VerifyAssertion(resp *AssertionResponse, challenge Bytes, credential *Credential) (uint32, error) {
+	if resp.Type != "public-key" || !bytes.Equal(resp.RawID, credential.ID) {
+		return 0, errCredential
+	}
+	if err := c.verifyClientData(resp.Response.ClientDataJSON, "webauthn.get", challenge); err != nil {
+		return 0, err
+	}
+
+	authData, err := c.parseAuthenticatorData(resp.Response.AuthenticatorData)
+	if err != nil {
+		return 0, err
+	}
+
+	key, _, err := parsePublicKey(credential.PublicKey)
+	if err != nil {
+		return 0, err
+	}
+	clientDataHash := sha256.Sum256(resp.Response.ClientDataJSON)
+	signed := append(append([]byte{}, resp.Response.AuthenticatorData...), clientDataHash[:]...)
+	if err := verifySignature(key.key, key.alg, signed, resp.Response.Signature); err != nil {
+		return 0, err
+	}
+
+	// Authenticators without a counter always send 0
+	if (authData.signCount != 0 || credential.SignCount != 0) && authData.signCount <= credential.SignCount {
+		return 0, ErrSignCount
+	}
+	return authData.signCount, nil
+}
+
+// verifyClientData checks the type, challenge and origin the browser signed
+func (c *Config) verifyClientData(raw []byte, ceremony string, challenge Bytes) error {
+	var clientData struct {
+		Type      string `json:"type"`
+		Challenge Bytes  `json:"challenge"`
+		Origin    string `json:"origin"`
+	}
+	if err := json.Unmarshal(raw, &clientData); err != nil || clientData.Type != ceremony {
+		return errClientData
+	}
+	if len(challenge) == 0 || subtle.ConstantTimeCompare(clientData.Challenge, challenge) != 1 {
+		return errChallenge
+	}
+	for _, origin := range c.Origins {
+		if clientData.Origin == origin {
+			return nil
+		}
+	}
+	return errOrigin
+}
+
+// authenticatorData is the data the authenticator signs, see section 6.1 of the specification
+type authenticatorData struct {
+	rpIDHash     []byte
+	flags        byte
+	signCount    uint32
+	aaguid       []byte
+	credentialID []byte
+	publicKey    *publicKey
+	rawPublicKey []byte
+}
+
+func (c *Config) parseAuthenticatorData(data []byte) (*authenticatorData, error) {
+	if len(data) < 37 {
+		return nil, errAuthenticatorData
+	}
+	authData := &authenticatorData{
+		rpIDHash:  data[:32],
+		flags:     data[32],
+		signCount: binary.BigEndian.Uint32(data[33:37]),
+	}
+	rpIDHash := sha256.Sum256([]byte(c.RPID))
+	if !bytes.Equal(authData.rpIDHash, rpIDHash[:]) {
+		return nil, errRPID
+	}
+	if authData.flags&flagUserPresent == 0 {
+		return nil, errUserPresence
+	}
+
+	rest := data[37:]
+	if authData.flags&flagAttested != 0 {
+		if len(rest) < 18 {
+			return nil, errAuthenticatorData
+		}
+		authData.aaguid = rest[:16]
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || len(rest) < idLength {
+			return nil, errAuthenticatorData
+		}
+		authData.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		key, after, err := parsePublicKey(rest)
+		if err != nil {
+			return nil, err
+		}
+		authData.publicKey = key
+		authData.rawPublicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+	if authData.flags&flagExtensions != 0 {
+		var err error
+		if _, rest, err = decodeCBOR(rest); err != nil {
+			return nil, err
+		}
+	}
+	if len(rest) != 0 {
+		return nil, errAuthenticatorData
+	}
+	return authData, nil
+}
+
+// verifyAttestation checks the attestation statement, see section 8 of the specification
+func verifyAttestation(format string, statement map[interface{}]interface{}, rawAuthData, clientDataHash []byte, authData *authenticatorData) error {
+	signed := append(append([]byte{}, rawAuthData...), clientDataHash...)
+	sig, _ := statement["sig"].([]byte)
+
+	switch format {
+	case "none":
+		if len(statement) != 0 {
+			return errAttestation
+		}
+		return nil
+
+	case "packed":
+		alg, _ := statement["alg"].(int64)
+		cert, err := attestationCertificate(statement)
+		if err != nil {
+			return err
+		}
+		if cert == nil {
+			// Self attestation is signed with the credential key
+			if alg != authData.publicKey.alg {
+				return errAttestation
+			}
+			return verifySignature(authData.publicKey.key, alg, signed, sig)
+		}
+		return verifySignature(cert.PublicKey, alg, signed, sig)
+
+	case "fido-u2f":
+		cert, err := attestationCertificate(statement)
+		if err != nil || cert == nil || authData.publicKey.alg != AlgES256 {
+			return errAttestation
+		}
+		// The U2F key is the uncompressed point of the COSE key
+		m, _, _ := decodeCBOR(authData.rawPublicKey)
+		key := m.(map[interface{}]interface{})
+		x, _ := key[int64(coseX)].([]byte)
+		y, _ := key[int64(coseY)].([]byte)
+
+		data := []byte{0x00}
+		data = append(data, authData.rpIDHash...)
+		data = append(data, clientDataHash...)
+		data = append(data, authData.credentialID...)
+		data = append(data, 0x04)
+		data = append(data, x...)
+		data = append(data, y...)
+		return verifySignature(cert.PublicKey, AlgES256, data, sig)
+	}
+	return errAttestationFormat
+}
+
+// attestationCertificate returns the first certificate of x5c, or nil without one
+func attestationCertificate(statement map[interface{}]interface{}) (*x509.Certificate, error) {
+	chain, ok := statement["x5c"].([]interface{})
+	if !ok {
+		return nil, nil
+	}
+	if len(chain) == 0 {
+		return nil, errAttestation
+	}
+	der, _ := chain[0].([]byte)
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, errAttestation
+	}
+	return cert, nil
+}
@@ -0,0 +1,118 @@
+package webauthn
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/hex"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	// Examples of appendix A of RFC 8949
+	tests := []struct {
+		hex  string
+		want interface{}
+	}{
+		{"17", int64(23)},
+		{"1903e8", int64(1000)},
+		{"3863", int64(-100)},
+		{"4401020304", []byte{1, 2, 3, 4}},
+		{"6449455446", "IETF"},
+		{"f5", true},
+		{"83010203", []interface{}{int64(1), int64(2), int64(3)}},
+		{"a201020304", map[interface{}]interface{}{int64(1): int64(2), int64(3): int64(4)}},
+		{"a26161016162820203", map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{"c074323031332d30332d32315432303a30343a30305a", "2013-03-21T20:04:00Z"},
+	}
+	for _, tt := range tests {
+		data, _ := hex.DecodeString(tt.hex)
+		item, rest, err := decodeCBOR(data)
+		assert.NoError(t, err, tt.hex)
+		assert.Empty(t, rest, tt.hex)
+		assert.Equal(t, tt.want, item, tt.hex)
+	}
+
+	// Truncated items, indefinite lengths and array keys are rejected
+	for _, bad := range []string{"", "19", "4401", "5f42010243030405ff", "a1800102", "9b00000000ffffffff"} {
+		data, _ := hex.DecodeString(bad)
+		_, _, err := decodeCBOR(data)
+		assert.Equal(t, errCBOR, err, bad)
+	}
+
+	// Items nested deeper than authenticators send are rejected
+	nested := make([]byte, maxCBORDepth+2)
+	for i := range nested {
+		nested[i] = 0x81
+	}
+	_, _, err := decodeCBOR(nested)
+	assert.Equal(t, errCBOR, err)
+}
+
+func TestVerifySignature(t *testing.T) {
+	data := []byte("authenticator data and client data hash")
+	digest := sha256.Sum256(data)
+
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	r, s, _ := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+	ecSignature, _ := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	assert.NoError(t, verifySignature(&ecKey.PublicKey, AlgES256, data, ecSignature))
+	assert.Equal(t, errSignature, verifySignature(&ecKey.PublicKey, AlgES256, []byte("other data"), ecSignature))
+	assert.Equal(t, errSignature, verifySignature(&ecKey.PublicKey, AlgRS256, data, ecSignature))
+	assert.Equal(t, errSignature, verifySignature(&ecKey.PublicKey, AlgES256, data, ecSignature[:len(ecSignature)-1]))
+
+	edPublic, edPrivate, _ := ed25519.GenerateKey(rand.Reader)
+	edSignature := ed25519.Sign(edPrivate, data)
+	assert.NoError(t, verifySignature(edPublic, AlgEdDSA, data, edSignature))
+	edSignature[0] ^= 0xff
+	assert.Equal(t, errSignature, verifySignature(edPublic, AlgEdDSA, data, edSignature))
+}
+
+func TestParsePublicKey(t *testing.T) {
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	x, y := make([]byte, 32), make([]byte, 32)
+	xb, yb := ecKey.X.Bytes(), ecKey.Y.Bytes()
+	copy(x[32-len(xb):], xb)
+	copy(y[32-len(yb):], yb)
+
+	// {1: 2, 3: -7, -1: 1, -2: x, -3: y}
+	cose := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01, 0x21, 0x58, 0x20}
+	cose = append(cose, x...)
+	cose = append(cose, 0x22, 0x58, 0x20)
+	cose = append(cose, y...)
+
+	key, rest, err := parsePublicKey(append(cose, 0xff))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff}, rest)
+	assert.Equal(t, int64(AlgES256), key.alg)
+	if assert.IsType(t, &ecdsa.PublicKey{}, key.key) {
+		assert.Equal(t, 0, ecKey.X.Cmp(key.key.(*ecdsa.PublicKey).X))
+		assert.Equal(t, 0, ecKey.Y.Cmp(key.key.(*ecdsa.PublicKey).Y))
+	}
+
+	// A point which isn't on the curve
+	cose[len(cose)-1] ^= 0x01
+	_, _, err = parsePublicKey(cose)
+	assert.Equal(t, errPublicKey, err)
+}
+
+func TestVerifyClientData(t *testing.T) {
+	config := &Config{RPID: "vault.passwall.io", Origins: []string{"https://vault.passwall.io"}}
+	challenge := Bytes("challenge of the ceremony")
+	clientData := func(ceremony, challenge, origin string) []byte {
+		return []byte(`{"type":"` + ceremony + `","challenge":"` + challenge + `","origin":"` + origin + `"}`)
+	}
+	encoded := "Y2hhbGxlbmdlIG9mIHRoZSBjZXJlbW9ueQ"
+
+	assert.NoError(t, config.verifyClientData(clientData("webauthn.get", encoded, "https://vault.passwall.io"), "webauthn.get", challenge))
+	assert.Equal(t, errClientData, config.verifyClientData(clientData("webauthn.create", encoded, "https://vault.passwall.io"), "webauthn.get", challenge))
+	assert.Equal(t, errChallenge, config.verifyClientData(clientData("webauthn.get", "b3RoZXI", "https://vault.passwall.io"), "webauthn.get", challenge))
+	assert.Equal(t, errChallenge, config.verifyClientData(clientData("webauthn.get", encoded, "https://vault.passwall.io"), "webauthn.get", nil))
+	assert.Equal(t, errOrigin, config.verifyClientData(clientData("webauthn.get", encoded, "https://evil.example"), "webauthn.get", challenge))
+}
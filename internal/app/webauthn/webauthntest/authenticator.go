@@ -0,0 +1,155 @@
+// Package webauthntest is a software authenticator which registers credentials and signs
+// assertions like a security key does, so tests can sign in without a browser
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"math/big"
+
+	"github.com/passwall/passwall-server/internal/app/webauthn"
+)
+
+// Authenticator holds a P-256 credential, it is created by the first registration
+type Authenticator struct {
+	Origin    string
+	SignCount uint32 // incremented by every assertion, set it back to act like a clone
+
+	id  []byte
+	key *ecdsa.PrivateKey
+}
+
+// New returns an authenticator which is used on the page of the origin
+func New(origin string) *Authenticator {
+	return &Authenticator{Origin: origin}
+}
+
+// ID returns the id of the credential
+func (a *Authenticator) ID() []byte {
+	return a.id
+}
+
+// Register creates a new credential for the options with a "none" attestation
+func (a *Authenticator) Register(options *webauthn.CreationOptions) (*webauthn.RegistrationResponse, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	a.id, a.key, a.SignCount = id, key, 0
+
+	clientData := a.clientData("webauthn.create", options.Challenge)
+
+	// COSE key: kty EC2, alg ES256, crv P-256, x, y
+	cose := []byte{0xa5, 0x01, 0x02, 0x03, 0x26, 0x20, 0x01}
+	cose = append(cose, 0x21)
+	cose = append(cose, cborBytes(pad32(key.X.Bytes()))...)
+	cose = append(cose, 0x22)
+	cose = append(cose, cborBytes(pad32(key.Y.Bytes()))...)
+
+	authData := a.authenticatorData(options.RP.ID, 0x01|0x04|0x40)
+	authData = append(authData, make([]byte, 16)...) // aaguid
+	authData = append(authData, byte(len(id)>>8), byte(len(id)))
+	authData = append(authData, id...)
+	authData = append(authData, cose...)
+
+	// {"fmt": "none", "attStmt": {}, "authData": authData}
+	attestation := []byte{0xa3}
+	attestation = append(attestation, cborText("fmt")...)
+	attestation = append(attestation, cborText("none")...)
+	attestation = append(attestation, cborText("attStmt")...)
+	attestation = append(attestation, 0xa0)
+	attestation = append(attestation, cborText("authData")...)
+	attestation = append(attestation, cborBytes(authData)...)
+
+	resp := &webauthn.RegistrationResponse{ID: base64.RawURLEncoding.EncodeToString(id), RawID: id, Type: "public-key"}
+	resp.Response.ClientDataJSON = clientData
+	resp.Response.AttestationObject = attestation
+	return resp, nil
+}
+
+// Assert signs the challenge of the options when they allow the credential
+func (a *Authenticator) Assert(options *webauthn.RequestOptions) (*webauthn.AssertionResponse, error) {
+	if a.key == nil {
+		return nil, errors.New("webauthntest: no credential is registered")
+	}
+	allowed := len(options.AllowCredentials) == 0
+	for _, credential := range options.AllowCredentials {
+		if string(credential.ID) == string(a.id) {
+			allowed = true
+		}
+	}
+	if !allowed {
+		return nil, errors.New("webauthntest: the credential is not allowed")
+	}
+
+	a.SignCount++
+	clientData := a.clientData("webauthn.get", options.Challenge)
+	authData := a.authenticatorData(options.RPID, 0x01|0x04)
+
+	hash := sha256.Sum256(clientData)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), hash[:]...))
+	r, s, err := ecdsa.Sign(rand.Reader, a.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+	signature, err := asn1.Marshal(struct{ R, S *big.Int }{r, s})
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &webauthn.AssertionResponse{ID: base64.RawURLEncoding.EncodeToString(a.id), RawID: a.id, Type: "public-key"}
+	resp.Response.ClientDataJSON = clientData
+	resp.Response.AuthenticatorData = authData
+	resp.Response.Signature = signature
+	return resp, nil
+}
+
+func (a *Authenticator) clientData(ceremony string, challenge []byte) []byte {
+	data, _ := json.Marshal(map[string]string{
+		"type":      ceremony,
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    a.Origin,
+	})
+	return data
+}
+
+func (a *Authenticator) authenticatorData(rpID string, flags byte) []byte {
+	hash := sha256.Sum256([]byte(rpID))
+	data := append(hash[:], flags)
+	counter := make([]byte, 4)
+	binary.BigEndian.PutUint32(counter, a.SignCount)
+	return append(data, counter...)
+}
+
+func cborBytes(b []byte) []byte {
+	return append(cborHead(2, len(b)), b...)
+}
+
+func cborText(s string) []byte {
+	return append(cborHead(3, len(s)), s...)
+}
+
+func cborHead(major byte, length int) []byte {
+	switch {
+	case length < 24:
+		return []byte{major<<5 | byte(length)}
+	case length < 256:
+		return []byte{major<<5 | 24, byte(length)}
+	}
+	return []byte{major<<5 | 25, byte(length >> 8), byte(length)}
+}
+
+func pad32(b []byte) []byte {
+	return append(make([]byte, 32-len(b)), b...)
+}
@@ -0,0 +1,146 @@
+// Package blob keeps large binary objects like export archives and attachments outside
+// the database.
+package blob
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// ErrNotFound is returned when there is no blob with the key
+var ErrNotFound = errors.New("blob not found")
+
+var errKey = errors.New("blob key is not valid")
+
+// Store is a blob backend, keys are paths like "exports/42.pwx"
+type Store interface {
+	Put(key string, r io.Reader) error
+	Get(key string) (io.ReadCloser, error)
+	Delete(key string) error
+	List(prefix string) ([]Object, error)
+}
+
+// Object is a blob of a list
+type Object struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Drivers of blob.driver
+const (
+	DriverDisk = "disk"
+	DriverS3   = "s3"
+)
+
+// FromConfig returns the backend of blob.driver, blobs are kept in blob.dir or in the
+// bucket of the blob.s3 settings
+func FromConfig() (Store, error) {
+	switch driver := viper.GetString("blob.driver"); driver {
+	case "", DriverDisk:
+		return &Disk{Dir: viper.GetString("blob.dir")}, nil
+	case DriverS3:
+		return S3FromConfig("blob")
+	default:
+		return nil, fmt.Errorf("blob.driver: unknown driver %q", driver)
+	}
+}
+
+// Disk keeps blobs as files in a folder
+type Disk struct {
+	Dir string
+}
+
+// Put writes the blob to a temporary file first, so readers never see a partial blob
+func (d *Disk) Put(key string, r io.Reader) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Get ...
+func (d *Disk) Get(key string) (io.ReadCloser, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete ...
+func (d *Disk) Delete(key string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// List returns the blobs whose keys start with the prefix, blobs which are being written
+// aren't listed
+func (d *Disk) List(prefix string) ([]Object, error) {
+	objects := []Object{}
+	err := filepath.Walk(d.Dir, func(path string, info os.FileInfo, err error) error {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasSuffix(path, ".tmp") {
+			return nil
+		}
+		rel, err := filepath.Rel(d.Dir, path)
+		if err != nil {
+			return err
+		}
+		if key := filepath.ToSlash(rel); strings.HasPrefix(key, prefix) {
+			objects = append(objects, Object{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		}
+		return nil
+	})
+	return objects, err
+}
+
+// path keeps the blob in the folder, keys can't go up with ".."
+func (d *Disk) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if key == "" || strings.Contains(key, "..") {
+		return "", errKey
+	}
+	return filepath.Join(d.Dir, clean), nil
+}
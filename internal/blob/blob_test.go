@@ -0,0 +1,70 @@
+package blob
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisk(t *testing.T) {
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	d := &Disk{Dir: dir}
+
+	assert.NoError(t, d.Put("exports/1.pwx", strings.NewReader("archive")))
+	r, err := d.Get("exports/1.pwx")
+	assert.NoError(t, err)
+	data, _ := ioutil.ReadAll(r)
+	r.Close()
+	assert.Equal(t, "archive", string(data))
+
+	assert.NoError(t, d.Put("exports/2.pwx", strings.NewReader("archive")))
+	objects, err := d.List("exports/")
+	assert.NoError(t, err)
+	if assert.Len(t, objects, 2) {
+		assert.Equal(t, "exports/1.pwx", objects[0].Key)
+		assert.Equal(t, int64(7), objects[0].Size)
+	}
+
+	assert.NoError(t, d.Delete("exports/1.pwx"))
+	_, err = d.Get("exports/1.pwx")
+	assert.Equal(t, ErrNotFound, err)
+	assert.NoError(t, d.Delete("exports/1.pwx"))
+
+	assert.Equal(t, errKey, d.Put("../escape", strings.NewReader("")))
+}
+
+func TestFromConfig(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set("blob.dir", "blobs")
+	store, err := FromConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, &Disk{Dir: "blobs"}, store)
+
+	viper.Set("blob.driver", DriverS3)
+	_, err = FromConfig()
+	assert.EqualError(t, err, "blob.s3Bucket and the credentials of the bucket are required")
+
+	viper.Set("blob.s3Bucket", "attachments")
+	viper.Set("blob.s3Region", "eu-west-1")
+	viper.Set("blob.s3AccessKey", "AKIDEXAMPLE")
+	viper.Set("blob.s3SecretKey", "secret")
+	store, err = FromConfig()
+	assert.NoError(t, err)
+	if s3, ok := store.(*S3); assert.True(t, ok) {
+		assert.Equal(t, "https://s3.eu-west-1.amazonaws.com", s3.Endpoint)
+		assert.Equal(t, "attachments", s3.Bucket)
+	}
+
+	viper.Set("blob.driver", "ftp")
+	_, err = FromConfig()
+	assert.EqualError(t, err, `blob.driver: unknown driver "ftp"`)
+}
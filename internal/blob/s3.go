@@ -0,0 +1,399 @@
+package blob
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// S3 keeps blobs as objects of a bucket of AWS S3 or of a compatible service like MinIO or
+// the XML API of Google Cloud Storage with HMAC keys. Requests are signed with Signature
+// Version 4. Uploads send the MD5 and the SHA-256 of the content, so the service rejects
+// objects which were changed on the way. Blobs larger than a part are uploaded in parts,
+// so only one part is held in memory.
+type S3 struct {
+	Endpoint     string // like https://s3.eu-west-1.amazonaws.com, http://minio:9000 or https://storage.googleapis.com
+	Region       string // auto for Google Cloud Storage
+	Bucket       string
+	Prefix       string // of the keys of the objects, like passwall/
+	AccessKey    string
+	SecretKey    string
+	SessionToken string // of temporary credentials
+	PathStyle    bool   // the bucket is in the path instead of the host, MinIO needs it
+	PartSize     int    // of multipart uploads, 8 MiB if 0, S3 needs 5 MiB at least
+	Client       *http.Client
+}
+
+const s3PartSize = 8 << 20
+
+// S3FromConfig returns the bucket of the s3 settings of the section of the configuration,
+// like backup.s3Bucket. The credentials are read from AWS_ACCESS_KEY_ID and
+// AWS_SECRET_ACCESS_KEY if the section has none.
+func S3FromConfig(section string) (*S3, error) {
+	s := &S3{
+		Endpoint:     viper.GetString(section + ".s3Endpoint"),
+		Region:       viper.GetString(section + ".s3Region"),
+		Bucket:       viper.GetString(section + ".s3Bucket"),
+		Prefix:       viper.GetString(section + ".s3Prefix"),
+		AccessKey:    viper.GetString(section + ".s3AccessKey"),
+		SecretKey:    viper.GetString(section + ".s3SecretKey"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		PathStyle:    viper.GetBool(section + ".s3PathStyle"),
+	}
+	if s.AccessKey == "" {
+		s.AccessKey, s.SecretKey = os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	}
+	if s.Region == "" {
+		s.Region = "us-east-1"
+	}
+	if s.Endpoint == "" {
+		s.Endpoint = "https://s3." + s.Region + ".amazonaws.com"
+	}
+	if s.Bucket == "" || s.AccessKey == "" || s.SecretKey == "" {
+		return nil, fmt.Errorf("%s.s3Bucket and the credentials of the bucket are required", section)
+	}
+	return s, nil
+}
+
+// Put uploads the blob as a single object, or with a multipart upload when it is larger
+// than a part
+func (s *S3) Put(key string, r io.Reader) error {
+	size := s.PartSize
+	if size <= 0 {
+		size = s3PartSize
+	}
+	part := make([]byte, size)
+	n, err := io.ReadFull(r, part)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		resp, err := s.do(http.MethodPut, key, nil, part[:n], md5Header(part[:n]))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploads": {""}}, nil, map[string]string{
+		"Content-Type": "application/octet-stream",
+	})
+	if err != nil {
+		return err
+	}
+	var upload struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&upload)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if err := s.putParts(key, upload.UploadID, part, r); err != nil {
+		// Parts of aborted uploads aren't kept, the bucket doesn't bill them
+		if resp, err := s.do(http.MethodDelete, key, url.Values{"uploadId": {upload.UploadID}}, nil, nil); err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+// putParts uploads the first part which was read already and the rest of r, then
+// completes the upload
+func (s *S3) putParts(key, uploadID string, part []byte, r io.Reader) error {
+	type completedPart struct {
+		PartNumber int
+		ETag       string
+	}
+	complete := struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{}
+
+	n := len(part)
+	for number := 1; n > 0; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, err := s.do(http.MethodPut, key, query, part[:n], md5Header(part[:n]))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		complete.Parts = append(complete.Parts, completedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+
+		n, err = io.ReadFull(r, part)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+	}
+
+	body, err := xml.Marshal(complete)
+	if err != nil {
+		return err
+	}
+	resp, err := s.do(http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body, map[string]string{
+		"Content-Type": "application/xml",
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// Completing can fail after the status is sent, the error is in the body then
+	var result struct {
+		XMLName xml.Name
+		Code    string
+		Message string
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("s3: %s %s: %s %s", http.MethodPost, s.Bucket+"/"+key, result.Code, result.Message)
+	}
+	return nil
+}
+
+// Get ...
+func (s *S3) Get(key string) (io.ReadCloser, error) {
+	resp, err := s.do(http.MethodGet, key, nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Delete ...
+func (s *S3) Delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, nil)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// List returns the objects whose keys start with the prefix, with ListObjectsV2
+func (s *S3) List(prefix string) ([]Object, error) {
+	objects := []Object{}
+	query := url.Values{"list-type": {"2"}, "prefix": {s.Prefix + prefix}}
+	for {
+		resp, err := s.do(http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string
+				Size         int64
+				LastModified time.Time
+			}
+			IsTruncated           bool
+			NextContinuationToken string
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, content := range result.Contents {
+			objects = append(objects, Object{
+				Key:     strings.TrimPrefix(content.Key, s.Prefix),
+				Size:    content.Size,
+				ModTime: content.LastModified,
+			})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return objects, nil
+		}
+		query.Set("continuation-token", result.NextContinuationToken)
+	}
+}
+
+// do sends the signed request for the object with the key, or for the bucket without a
+// key. Responses which aren't 2xx are errors, ErrNotFound for missing objects.
+func (s *S3) do(method, key string, query url.Values, body []byte, headers map[string]string) (*http.Response, error) {
+	if key != "" {
+		var err error
+		if key, err = s.key(key); err != nil {
+			return nil, err
+		}
+	}
+
+	u, err := url.Parse(strings.TrimSuffix(s.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	path := "/" + key
+	if s.PathStyle {
+		path = "/" + s.Bucket + path
+	} else {
+		u.Host = s.Bucket + "." + u.Host
+	}
+	u.Path, u.RawPath = path, s3Escape(path)
+	u.RawQuery = canonicalQuery(query)
+
+	req, err := http.NewRequest(method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	req.Header.Set("X-Amz-Content-Sha256", hexSHA256(body))
+	signV4(req, s.Region, s.AccessKey, s.SecretKey, time.Now())
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return resp, nil
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound && key != "" {
+		return nil, ErrNotFound
+	}
+	var e struct {
+		Code    string
+		Message string
+	}
+	xml.NewDecoder(io.LimitReader(resp.Body, 1<<16)).Decode(&e)
+	if e.Code == "" {
+		e.Code = resp.Status
+	}
+	return nil, fmt.Errorf("s3: %s %s: %s %s", method, s.Bucket+"/"+key, e.Code, e.Message)
+}
+
+// key returns the key of the object of the blob
+func (s *S3) key(key string) (string, error) {
+	if key == "" || strings.Contains(key, "..") || strings.HasPrefix(key, "/") {
+		return "", errKey
+	}
+	return s.Prefix + key, nil
+}
+
+// signV4 signs the request and all of its headers with Signature Version 4 of AWS,
+// the hash of the payload is the X-Amz-Content-Sha256 header
+func signV4(req *http.Request, region, accessKey, secretKey string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		canonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		headers["x-amz-content-sha256"],
+	}, "\n")
+
+	scope := date + "/" + region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hexSHA256([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := []string{}
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, s3Escape(key)+"="+strings.Replace(s3Escape(value), "/", "%2F", -1))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// s3Escape percent-encodes all bytes but the unreserved characters of RFC 3986 and "/"
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || strings.IndexByte("-._~/", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func md5Header(data []byte) map[string]string {
+	sum := md5.Sum(data)
+	return map[string]string{
+		"Content-Type": "application/octet-stream",
+		"Content-MD5":  base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
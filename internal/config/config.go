@@ -5,6 +5,7 @@ import (
 	"encoding/base64"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/spf13/viper"
 )
@@ -18,14 +19,36 @@ var (
 
 	storeDirectory    = "./store/"
 	configFileAbsPath = filepath.Join(storeDirectory, configFileName)
+
+	logPath        = "/var/log/passwall/"
+	databaseDriver = "postgres"
 )
 
 // Configuration ...
 type Configuration struct {
-	Server   ServerConfiguration
-	Database DatabaseConfiguration
-	Email    EmailConfiguration
-	Backup   BackupConfiguration
+	Server       ServerConfiguration
+	Database     DatabaseConfiguration
+	Email        EmailConfiguration
+	Backup       BackupConfiguration
+	OIDC         OIDCConfiguration
+	Rotation     RotationConfiguration
+	Alert        AlertConfiguration
+	Audit        AuditConfiguration
+	Budget       BudgetConfiguration
+	Export       ExportConfiguration
+	Blob         BlobConfiguration
+	Attachment   AttachmentConfiguration
+	Retention    RetentionConfiguration
+	I18n         I18nConfiguration
+	Reencryption ReencryptionConfiguration
+	WebAuthn     WebAuthnConfiguration
+	SSO          SSOConfiguration
+	Captcha      CaptchaConfiguration
+	PasswordHash PasswordHashConfiguration
+	Kdf          KdfConfiguration
+	KMS          KMSConfiguration
+	Tracing      TracingConfiguration
+	Health       HealthConfiguration
 }
 
 // ServerConfiguration is the required parameters to set up a server
@@ -35,23 +58,59 @@ type ServerConfiguration struct {
 	Dir                        string `default:"/app/config"`
 	Environment                string `default:"development"` // development,test,production
 	LogPath                    string `default:"/var/log/passwall/"`
+	LogFormat                  string `default:"json"` // json or text
+	LogLevel                   string `default:"info"` // debug, info, warn or error
 	Passphrase                 string `default:"passphrase-for-encrypting-passwords-do-not-forget"`
+	PreviousPassphrase         string `default:""`   // decrypts the rows a key rotation hasn't reached yet
+	Cipher                     string `default:"v1"` // v1, v2 of new encrypted fields
 	Secret                     string `default:"secret-key-for-JWT-TOKEN"`
 	Timeout                    int    `default:"24"`
 	GeneratedPasswordLength    int    `default:"16"`
 	AccessTokenExpireDuration  string `default:"30m"`
 	RefreshTokenExpireDuration string `default:"15d"`
+	MachineTokenExpireDuration string `default:"15m"`
+	SessionIdleTimeout         string `default:""`  // e.g. 15m, empty is no limit
+	SessionAbsoluteTimeout     string `default:""`  // e.g. 12h, empty is no limit
+	CountryHeader              string `default:""`  // e.g. CF-IPCountry, set by a trusted proxy
+	KeyThreshold               int    `default:"0"` // key shares required at startup, 0 reads the passphrase
+	DisposableDomainsFile      string `default:""`  // extra disposable email domains, one per line
+	TrustedProxies             string `default:""`  // e.g. 10.0.0.0/8,172.16.0.0/12, their forwarded headers are used
+	ProxyProtocol              bool   `default:"false"`
+	TwoFactorIssuer            string `default:"Passwall"` // account name prefix in authenticator apps
+	TrustedDeviceDuration      string `default:"30d"`      // trusted devices skip the second factor this long, 0 turns them off
+	SigninMaxFailures          int    `default:"5"`        // failed sign ins in a row which lock an account, 0 never locks
+	SigninIPMaxFailures        int    `default:"20"`       // failed sign ins which lock an address, 0 never locks
+	SigninLockDuration         string `default:"15m"`      // locked accounts and addresses unlock after it
+	SigninDelay                string `default:"1s"`       // wait after the second failed sign in, it doubles with each further one
+	RequireEmailVerification   bool   `default:"true"`     // signups can't sign in before they open the verification link
+	PasswordReset              string `default:"keep"`     // keep re-encrypts the vault at a reset by email, wipe empties it, off turns the reset off
+	PasswordResetURL           string `default:""`         // page of the client which posts the reset token, server.domain/reset-password if empty
 	APIKey                     string `default:"my-secret-api-key"`
+	ReadOnly                   bool   `default:"false"`
+	ZeroKnowledge              bool   `default:"false"` // items are stored as clients encrypted them, the server never decrypts them
+	Pprof                      bool   `default:"false"` // admins can read runtime profiles on /debug/pprof
+	ShutdownTimeout            string `default:"30s"`   // in-flight requests and background jobs finish within it at SIGTERM
+	RateLimit                  int    `default:"5"`     // requests per second of an address to the endpoints without a session, 0 is no limit
+	CORSOrigins                string `default:"*"`     // e.g. https://app.passwall.io,chrome-extension://..., * allows all
+	ItemVersions               int    `default:"10"`    // previous versions kept per item, 0 keeps none
 }
 
 // DatabaseConfiguration is the required parameters to set up a DB instance
 type DatabaseConfiguration struct {
-	Name     string `default:"passwall"`
-	Username string `default:"user"`
-	Password string `default:"password"`
-	Host     string `default:"localhost"`
-	Port     string `default:"5432"`
-	LogMode  bool   `default:"false"`
+	Driver          string `default:"postgres"` // postgres, sqlite or a driver of storage.Register
+	Path            string `default:"./store/passwall.db"`
+	Name            string `default:"passwall"`
+	Username        string `default:"user"`
+	Password        string `default:"password"`
+	Host            string `default:"localhost"`
+	Port            string `default:"5432"`
+	LogMode         bool   `default:"false"`
+	Replicas        string `default:""`     // postgres DSNs of read replicas, comma separated
+	ReplicaLag      string `default:"2s"`   // reads of a request stay on the primary for it after its writes
+	MaxOpenConns    int    `default:"0"`    // connections of a pool, 0 is no limit
+	MaxIdleConns    int    `default:"2"`    // open connections a pool keeps while they are idle
+	ConnMaxLifetime string `default:"0s"`   // connections are closed after it, 0s keeps them
+	AutoMigrate     bool   `default:"true"` // the migrations are applied at startup, else with passwall-server migrate up
 }
 
 // EmailConfiguration is the required parameters to send emails
@@ -67,8 +126,224 @@ type EmailConfiguration struct {
 // BackupConfiguration is the required parameters to backup
 type BackupConfiguration struct {
 	Folder   string `default:"./store/"`
-	Rotation string `default:"7"`
+	Rotation string `default:"7"` // backups kept per vault
 	Period   string `default:"24h"`
+	Schedule string `default:""`       // cron expression like "0 3 * * *", backups are off if empty
+	Target   string `default:"folder"` // folder or s3, a bucket of S3, MinIO or Google Cloud Storage
+	// Bucket of the s3 target, the credentials are read from AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY if empty
+	S3Endpoint  string `default:""` // https://s3.{region}.amazonaws.com if empty
+	S3Region    string `default:"us-east-1"`
+	S3Bucket    string `default:""`
+	S3Prefix    string `default:""` // of the object keys, like passwall/
+	S3AccessKey string `default:""`
+	S3SecretKey string `default:""`
+	S3PathStyle bool   `default:"false"` // bucket in the path instead of the host, for MinIO
+}
+
+// RotationConfiguration is the required parameters to rotate credentials of logins
+type RotationConfiguration struct {
+	Period string `default:"1h"` // how often logins are checked for a due rotation
+}
+
+// AlertConfiguration is the required parameters to send security alerts
+type AlertConfiguration struct {
+	Email      string `default:""` // admin address, email.fromEmail if empty
+	WebhookURL string `default:""` // e.g. a Slack incoming webhook
+}
+
+// AuditConfiguration is the required parameters to keep the audit trail
+type AuditConfiguration struct {
+	CheckpointPeriod string `default:"1h"` // how often a signed checkpoint of the trail is saved
+}
+
+// BudgetConfiguration is the requests each user can make to expensive endpoints, like 10/1h
+type BudgetConfiguration struct {
+	Export       string `default:"10/1h"`
+	Import       string `default:"10/1h"`
+	Report       string `default:"30/1h"`
+	Search       string `default:"120/1m"`
+	Verification string `default:"3/1h"` // verification and password reset emails of each address
+}
+
+// RetentionConfiguration is the required parameters to purge expired data,
+// the retention periods are set in the server policy
+type RetentionConfiguration struct {
+	Period string `default:"1d"` // how often expired data is purged
+}
+
+// ReencryptionConfiguration is the required parameters to re-encrypt vaults in the background,
+// small batches with long pauses keep the load of the database low
+type ReencryptionConfiguration struct {
+	BatchSize  int    `default:"100"`
+	BatchPause string `default:"100ms"`
+}
+
+// WebAuthnConfiguration is the required parameters to sign in with security keys,
+// credentials only work on the domain of the relying party id
+type WebAuthnConfiguration struct {
+	RPID    string `default:""`         // host of server.domain if empty
+	RPName  string `default:"Passwall"` // shown by the browser while registering
+	Origins string `default:""`         // e.g. https://vault.passwall.io,chrome-extension://ID, server.domain if empty
+	Timeout string `default:"2m"`       // how long the browser waits for the user
+}
+
+// CaptchaConfiguration is the required parameters to ask signups for a captcha
+type CaptchaConfiguration struct {
+	Provider  string `default:""` // recaptcha, hcaptcha or turnstile, empty turns the captcha off
+	Secret    string `default:""` // secret key of the site at the provider
+	VerifyURL string `default:""` // siteverify endpoint of the provider if empty
+}
+
+// PasswordHashConfiguration is the required parameters to hash master passwords with Argon2id
+type PasswordHashConfiguration struct {
+	Memory      int `default:"65536"` // KiB each hash uses
+	Iterations  int `default:"3"`     // passes over the memory
+	Parallelism int `default:"4"`     // threads of each hash
+}
+
+// KdfConfiguration is the required parameters clients derive the keys of the vault with,
+// users can have their own
+type KdfConfiguration struct {
+	Type        string `default:"pbkdf2-sha256"` // or argon2id
+	Iterations  int    `default:"0"`             // 600000 for pbkdf2-sha256 and 3 for argon2id if 0
+	Memory      int    `default:"65536"`         // KiB, argon2id only
+	Parallelism int    `default:"4"`             // argon2id only
+}
+
+// KMSConfiguration is the required parameters to wrap the server passphrase with a key
+// management service instead of keeping it in plaintext
+type KMSConfiguration struct {
+	Provider           string `default:""` // aws, gcp or vault, empty keeps the passphrase in plaintext
+	KeyID              string `default:""` // key id, ARN or alias of AWS, resource name of GCP, transit key of Vault
+	Region             string `default:""` // region of AWS, AWS_REGION if empty
+	Endpoint           string `default:""` // url of the API, the public one of the provider or VAULT_ADDR if empty
+	Token              string `default:""` // Vault token or GCP access token, read from the environment if empty
+	Mount              string `default:""` // mount of the Vault transit engine, transit if empty
+	WrappedKey         string `default:""` // server passphrase wrapped by the provider
+	WrappedPreviousKey string `default:""` // previous passphrase of an unfinished key rotation
+}
+
+// I18nConfiguration is the required parameters to translate messages and emails
+type I18nConfiguration struct {
+	Dir           string `default:""`   // catalog files like de.yml, they override the built in ones
+	DefaultLocale string `default:"en"` // when Accept-Language matches no catalog
+}
+
+// ExportConfiguration is the required parameters to build export archives
+type ExportConfiguration struct {
+	Workers   int    `default:"2"`
+	URLExpiry string `default:"15m"` // lifetime of a signed download link
+	Retention string `default:"1d"`  // archives are deleted after it
+}
+
+// BlobConfiguration is the required parameters to keep large objects like export archives
+// and attachments
+type BlobConfiguration struct {
+	Driver string `default:"disk"` // disk or s3, a bucket of S3, MinIO or Google Cloud Storage
+	Dir    string `default:"./store/blobs"`
+	// Bucket of the s3 driver, the credentials are read from AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY if empty
+	S3Endpoint  string `default:""` // https://s3.{region}.amazonaws.com if empty
+	S3Region    string `default:"us-east-1"`
+	S3Bucket    string `default:""`
+	S3Prefix    string `default:""` // of the object keys, like passwall/
+	S3AccessKey string `default:""`
+	S3SecretKey string `default:""`
+	S3PathStyle bool   `default:"false"` // bucket in the path instead of the host, for MinIO
+}
+
+// AttachmentConfiguration is the limits of the files of the items in bytes, admins can
+// change them for a user
+type AttachmentConfiguration struct {
+	MaxSize int64 `default:"26214400"`   // of a single file, 0 is unlimited
+	Quota   int64 `default:"1073741824"` // of all files of a vault, 0 is unlimited
+}
+
+// TracingConfiguration is the required parameters to export spans to an OpenTelemetry collector
+type TracingConfiguration struct {
+	Endpoint    string  `default:""` // OTLP/HTTP url like http://localhost:4318, tracing is off if empty
+	ServiceName string  `default:"passwall-server"`
+	SampleRatio float64 `default:"1"` // share of the traces started by the server
+	Headers     string  `default:""`  // e.g. api-key=secret,x-tenant=passwall
+}
+
+// HealthConfiguration is the required parameters of the readiness checks
+type HealthConfiguration struct {
+	CheckEmail bool `default:"false"` // servers which can't reach the mail API aren't ready
+}
+
+// OIDCConfiguration is the required parameters to act as an OpenID Connect provider
+type OIDCConfiguration struct {
+	Issuer  string       `default:"https://vault.passwall.io"` // server.domain if empty
+	KeyFile string       `default:"./store/oidc.pem"`          // generated on first use
+	Clients []OIDCClient // applications which can sign in with passwall
+}
+
+// OIDCClient is an application which signs its users in with passwall
+type OIDCClient struct {
+	ID           string
+	Name         string
+	Secret       string   // empty for public clients, they have to use PKCE
+	RedirectURIs []string // exact matches
+}
+
+// SSOConfiguration is the required parameters to sign in with external OpenID Connect
+// and SAML identity providers
+type SSOConfiguration struct {
+	Providers []SSOProvider  // e.g. Keycloak, Okta or Google
+	SAML      []SAMLProvider // e.g. ADFS, Azure AD or Shibboleth
+}
+
+// SSOProvider is an OpenID Connect provider users can sign in with instead of the master password
+type SSOProvider struct {
+	ID           string // in the paths of the sign in, e.g. okta
+	Name         string
+	Issuer       string // the metadata is read from /.well-known/openid-configuration of it
+	ClientID     string
+	ClientSecret string   // empty for public clients, PKCE is always used
+	RedirectURL  string   // page of the client which posts the code and state to the server
+	Scopes       []string // openid, email and profile if empty
+}
+
+// SAMLProvider is a SAML 2.0 identity provider users can sign in with instead of the master password.
+// The server is its service provider with the metadata at /auth/saml/{ID}/metadata.
+type SAMLProvider struct {
+	ID             string // in the paths of the sign in, e.g. adfs
+	Name           string
+	EntityID       string // of the server, the url of its metadata if empty
+	IdPEntityID    string // issuer of the responses
+	IdPSSOURL      string // single sign-on service of the HTTP-Redirect binding
+	IdPCertificate string // PEM encoded signing certificate or the path of its file
+	RedirectURL    string // page of the client which posts the code and state to the server
+	EmailAttribute string // email, mail or the emailaddress claim if empty
+	NameAttribute  string // displayName or the name claim if empty
+	CreateUsers    bool   // users without an account are created on their first sign in
+}
+
+// SetDataDir keeps the configuration, SQLite database, logs and backups in dir,
+// so the server runs without any external service. Keys are generated on the
+// first run and saved to the configuration file in dir.
+// It should be called before SetupConfigDefaults.
+func SetDataDir(dir string) error {
+	if strings.HasPrefix(dir, "~") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return err
+		}
+		dir = filepath.Join(home, dir[1:])
+	}
+
+	logDir := filepath.Join(dir, "logs")
+	if err := os.MkdirAll(logDir, 0700); err != nil {
+		return err
+	}
+
+	storeDirectory = dir
+	configFileAbsPath = filepath.Join(storeDirectory, configFileName)
+	logPath = logDir
+	databaseDriver = "sqlite"
+	return nil
 }
 
 // SetupConfigDefaults ...
@@ -80,6 +355,11 @@ func SetupConfigDefaults() (*Configuration, error) {
 	// Bind environment variables
 	bindEnvs()
 
+	// Read the values of the _FILE variables
+	if err := readSecretFiles(); err != nil {
+		return nil, err
+	}
+
 	// Set default values
 	setDefaults()
 
@@ -125,39 +405,159 @@ func initializeConfig() {
 }
 
 func bindEnvs() {
-	viper.BindEnv("server.domain", "DOMAIN")
-	viper.BindEnv("server.port", "PORT")
-	viper.BindEnv("server.environment", "PW_ENVIRONMENT")
-	viper.BindEnv("server.logPath", "PW_LOG_PATH")
-	viper.BindEnv("server.passphrase", "PW_SERVER_PASSPHRASE")
-	viper.BindEnv("server.secret", "PW_SERVER_SECRET")
-	viper.BindEnv("server.timeout", "PW_SERVER_TIMEOUT")
-
-	viper.BindEnv("server.generatedPasswordLength", "PW_SERVER_GENERATED_PASSWORD_LENGTH")
-	viper.BindEnv("server.accessTokenExpireDuration", "PW_SERVER_ACCESS_TOKEN_EXPIRE_DURATION")
-	viper.BindEnv("server.refreshTokenExpireDuration", "PW_SERVER_REFRESH_TOKEN_EXPIRE_DURATION")
-
-	viper.BindEnv("server.apiKey", "PW_SERVER_API_KEY")
-	viper.BindEnv("server.recaptcha", "PW_SERVER_RECAPTCHA")
-
-	viper.BindEnv("database.name", "PW_DB_NAME")
-	viper.BindEnv("database.username", "PW_DB_USERNAME")
-	viper.BindEnv("database.password", "PW_DB_PASSWORD")
-	viper.BindEnv("database.host", "PW_DB_HOST")
-	viper.BindEnv("database.port", "PW_DB_PORT")
-	viper.BindEnv("database.logmode", "PW_DB_LOG_MODE")
-
-	viper.BindEnv("email.host", "PW_EMAIL_HOST")
-	viper.BindEnv("email.port", "PW_EMAIL_PORT")
-	viper.BindEnv("email.username", "PW_EMAIL_USERNAME")
-	viper.BindEnv("email.password", "PW_EMAIL_PASSWORD")
-	viper.BindEnv("email.fromEmail", "PW_EMAIL_FROM_EMAIL")
-	viper.BindEnv("email.fromName", "PW_EMAIL_FROM_NAME")
-	viper.BindEnv("email.apiKey", "PW_EMAIL_API_KEY")
-
-	viper.BindEnv("backup.folder", "PW_BACKUP_FOLDER")
-	viper.BindEnv("backup.rotation", "PW_BACKUP_ROTATION")
-	viper.BindEnv("backup.period", "PW_BACKUP_PERIOD")
+	bindEnv("server.domain", "DOMAIN")
+	bindEnv("server.port", "PORT")
+	bindEnv("server.environment", "PW_ENVIRONMENT")
+	bindEnv("server.logPath", "PW_LOG_PATH")
+	bindEnv("server.logFormat", "PW_LOG_FORMAT")
+	bindEnv("server.logLevel", "PW_LOG_LEVEL")
+	bindEnv("server.passphrase", "PW_SERVER_PASSPHRASE")
+	bindEnv("server.previousPassphrase", "PW_SERVER_PREVIOUS_PASSPHRASE")
+	bindEnv("server.cipher", "PW_SERVER_CIPHER")
+	bindEnv("server.secret", "PW_SERVER_SECRET")
+	bindEnv("server.timeout", "PW_SERVER_TIMEOUT")
+
+	bindEnv("server.generatedPasswordLength", "PW_SERVER_GENERATED_PASSWORD_LENGTH")
+	bindEnv("server.accessTokenExpireDuration", "PW_SERVER_ACCESS_TOKEN_EXPIRE_DURATION")
+	bindEnv("server.refreshTokenExpireDuration", "PW_SERVER_REFRESH_TOKEN_EXPIRE_DURATION")
+	bindEnv("server.machineTokenExpireDuration", "PW_SERVER_MACHINE_TOKEN_EXPIRE_DURATION")
+	bindEnv("server.sessionIdleTimeout", "PW_SERVER_SESSION_IDLE_TIMEOUT")
+	bindEnv("server.sessionAbsoluteTimeout", "PW_SERVER_SESSION_ABSOLUTE_TIMEOUT")
+	bindEnv("server.countryHeader", "PW_SERVER_COUNTRY_HEADER")
+	bindEnv("server.keyThreshold", "PW_SERVER_KEY_THRESHOLD")
+	bindEnv("server.disposableDomainsFile", "PW_SERVER_DISPOSABLE_DOMAINS_FILE")
+	bindEnv("server.trustedProxies", "PW_SERVER_TRUSTED_PROXIES")
+	bindEnv("server.proxyProtocol", "PW_SERVER_PROXY_PROTOCOL")
+	bindEnv("server.twoFactorIssuer", "PW_SERVER_TWO_FACTOR_ISSUER")
+	bindEnv("server.trustedDeviceDuration", "PW_SERVER_TRUSTED_DEVICE_DURATION")
+	bindEnv("server.signinMaxFailures", "PW_SERVER_SIGNIN_MAX_FAILURES")
+	bindEnv("server.signinIPMaxFailures", "PW_SERVER_SIGNIN_IP_MAX_FAILURES")
+	bindEnv("server.signinLockDuration", "PW_SERVER_SIGNIN_LOCK_DURATION")
+	bindEnv("server.signinDelay", "PW_SERVER_SIGNIN_DELAY")
+	bindEnv("server.requireEmailVerification", "PW_SERVER_REQUIRE_EMAIL_VERIFICATION")
+	bindEnv("server.passwordReset", "PW_SERVER_PASSWORD_RESET")
+	bindEnv("server.passwordResetURL", "PW_SERVER_PASSWORD_RESET_URL")
+
+	bindEnv("server.apiKey", "PW_SERVER_API_KEY")
+	bindEnv("server.readOnly", "PW_SERVER_READ_ONLY")
+	bindEnv("server.zeroKnowledge", "PW_SERVER_ZERO_KNOWLEDGE")
+	bindEnv("server.pprof", "PW_SERVER_PPROF")
+	bindEnv("server.shutdownTimeout", "PW_SERVER_SHUTDOWN_TIMEOUT")
+	bindEnv("server.rateLimit", "PW_SERVER_RATE_LIMIT")
+	bindEnv("server.corsOrigins", "PW_SERVER_CORS_ORIGINS")
+	bindEnv("server.itemVersions", "PW_SERVER_ITEM_VERSIONS")
+	bindEnv("server.recaptcha", "PW_SERVER_RECAPTCHA") // older secret of reCAPTCHA, use captcha.secret
+
+	bindEnv("database.driver", "PW_DB_DRIVER")
+	bindEnv("database.path", "PW_DB_PATH")
+	bindEnv("database.name", "PW_DB_NAME")
+	bindEnv("database.username", "PW_DB_USERNAME")
+	bindEnv("database.password", "PW_DB_PASSWORD")
+	bindEnv("database.host", "PW_DB_HOST")
+	bindEnv("database.port", "PW_DB_PORT")
+	bindEnv("database.logmode", "PW_DB_LOG_MODE")
+	bindEnv("database.replicas", "PW_DB_REPLICAS")
+	bindEnv("database.replicaLag", "PW_DB_REPLICA_LAG")
+	bindEnv("database.maxOpenConns", "PW_DB_MAX_OPEN_CONNS")
+	bindEnv("database.maxIdleConns", "PW_DB_MAX_IDLE_CONNS")
+	bindEnv("database.connMaxLifetime", "PW_DB_CONN_MAX_LIFETIME")
+	bindEnv("database.autoMigrate", "PW_DB_AUTO_MIGRATE")
+
+	bindEnv("email.host", "PW_EMAIL_HOST")
+	bindEnv("email.port", "PW_EMAIL_PORT")
+	bindEnv("email.username", "PW_EMAIL_USERNAME")
+	bindEnv("email.password", "PW_EMAIL_PASSWORD")
+	bindEnv("email.fromEmail", "PW_EMAIL_FROM_EMAIL")
+	bindEnv("email.fromName", "PW_EMAIL_FROM_NAME")
+	bindEnv("email.apiKey", "PW_EMAIL_API_KEY")
+
+	bindEnv("oidc.issuer", "PW_OIDC_ISSUER")
+	bindEnv("oidc.keyFile", "PW_OIDC_KEY_FILE")
+
+	bindEnv("rotation.period", "PW_ROTATION_PERIOD")
+
+	bindEnv("alert.email", "PW_ALERT_EMAIL")
+	bindEnv("alert.webhookURL", "PW_ALERT_WEBHOOK_URL")
+
+	bindEnv("audit.checkpointPeriod", "PW_AUDIT_CHECKPOINT_PERIOD")
+
+	bindEnv("budget.export", "PW_BUDGET_EXPORT")
+	bindEnv("budget.import", "PW_BUDGET_IMPORT")
+	bindEnv("budget.report", "PW_BUDGET_REPORT")
+	bindEnv("budget.search", "PW_BUDGET_SEARCH")
+	bindEnv("budget.verification", "PW_BUDGET_VERIFICATION")
+
+	bindEnv("retention.period", "PW_RETENTION_PERIOD")
+
+	bindEnv("reencryption.batchSize", "PW_REENCRYPTION_BATCH_SIZE")
+	bindEnv("reencryption.batchPause", "PW_REENCRYPTION_BATCH_PAUSE")
+
+	bindEnv("webauthn.rpID", "PW_WEBAUTHN_RP_ID")
+	bindEnv("webauthn.rpName", "PW_WEBAUTHN_RP_NAME")
+	bindEnv("webauthn.origins", "PW_WEBAUTHN_ORIGINS")
+	bindEnv("webauthn.timeout", "PW_WEBAUTHN_TIMEOUT")
+
+	bindEnv("captcha.provider", "PW_CAPTCHA_PROVIDER")
+	bindEnv("captcha.secret", "PW_CAPTCHA_SECRET")
+	bindEnv("captcha.verifyURL", "PW_CAPTCHA_VERIFY_URL")
+
+	bindEnv("passwordHash.memory", "PW_PASSWORD_HASH_MEMORY")
+	bindEnv("passwordHash.iterations", "PW_PASSWORD_HASH_ITERATIONS")
+	bindEnv("passwordHash.parallelism", "PW_PASSWORD_HASH_PARALLELISM")
+
+	bindEnv("kdf.type", "PW_KDF_TYPE")
+	bindEnv("kdf.iterations", "PW_KDF_ITERATIONS")
+	bindEnv("kdf.memory", "PW_KDF_MEMORY")
+	bindEnv("kdf.parallelism", "PW_KDF_PARALLELISM")
+
+	bindEnv("kms.provider", "PW_KMS_PROVIDER")
+	bindEnv("kms.keyID", "PW_KMS_KEY_ID")
+	bindEnv("kms.region", "PW_KMS_REGION")
+	bindEnv("kms.endpoint", "PW_KMS_ENDPOINT")
+	bindEnv("kms.token", "PW_KMS_TOKEN")
+	bindEnv("kms.mount", "PW_KMS_MOUNT")
+	bindEnv("kms.wrappedKey", "PW_KMS_WRAPPED_KEY")
+	bindEnv("kms.wrappedPreviousKey", "PW_KMS_WRAPPED_PREVIOUS_KEY")
+
+	bindEnv("i18n.dir", "PW_I18N_DIR")
+	bindEnv("i18n.defaultLocale", "PW_I18N_DEFAULT_LOCALE")
+
+	bindEnv("export.workers", "PW_EXPORT_WORKERS")
+	bindEnv("export.urlExpiry", "PW_EXPORT_URL_EXPIRY")
+	bindEnv("export.retention", "PW_EXPORT_RETENTION")
+
+	bindEnv("blob.driver", "PW_BLOB_DRIVER")
+	bindEnv("blob.dir", "PW_BLOB_DIR")
+	bindEnv("blob.s3Endpoint", "PW_BLOB_S3_ENDPOINT")
+	bindEnv("blob.s3Region", "PW_BLOB_S3_REGION")
+	bindEnv("blob.s3Bucket", "PW_BLOB_S3_BUCKET")
+	bindEnv("blob.s3Prefix", "PW_BLOB_S3_PREFIX")
+	bindEnv("blob.s3AccessKey", "PW_BLOB_S3_ACCESS_KEY")
+	bindEnv("blob.s3SecretKey", "PW_BLOB_S3_SECRET_KEY")
+	bindEnv("blob.s3PathStyle", "PW_BLOB_S3_PATH_STYLE")
+
+	bindEnv("attachment.maxSize", "PW_ATTACHMENT_MAX_SIZE")
+	bindEnv("attachment.quota", "PW_ATTACHMENT_QUOTA")
+
+	bindEnv("tracing.endpoint", "PW_TRACING_ENDPOINT")
+	bindEnv("tracing.serviceName", "PW_TRACING_SERVICE_NAME")
+	bindEnv("tracing.sampleRatio", "PW_TRACING_SAMPLE_RATIO")
+	bindEnv("tracing.headers", "PW_TRACING_HEADERS")
+
+	bindEnv("health.checkEmail", "PW_HEALTH_CHECK_EMAIL")
+
+	bindEnv("backup.folder", "PW_BACKUP_FOLDER")
+	bindEnv("backup.rotation", "PW_BACKUP_ROTATION")
+	bindEnv("backup.period", "PW_BACKUP_PERIOD")
+	bindEnv("backup.schedule", "PW_BACKUP_SCHEDULE")
+	bindEnv("backup.target", "PW_BACKUP_TARGET")
+	bindEnv("backup.s3Endpoint", "PW_BACKUP_S3_ENDPOINT")
+	bindEnv("backup.s3Region", "PW_BACKUP_S3_REGION")
+	bindEnv("backup.s3Bucket", "PW_BACKUP_S3_BUCKET")
+	bindEnv("backup.s3Prefix", "PW_BACKUP_S3_PREFIX")
+	bindEnv("backup.s3AccessKey", "PW_BACKUP_S3_ACCESS_KEY")
+	bindEnv("backup.s3SecretKey", "PW_BACKUP_S3_SECRET_KEY")
+	bindEnv("backup.s3PathStyle", "PW_BACKUP_S3_PATH_STYLE")
 }
 
 func setDefaults() {
@@ -166,23 +566,59 @@ func setDefaults() {
 	viper.SetDefault("server.port", "3625")
 	viper.SetDefault("server.domain", "https://vault.passwall.io")
 	viper.SetDefault("server.environment", "development") // development, test, production
-	viper.SetDefault("server.logPath", "/var/log/passwall/")
+	viper.SetDefault("server.logPath", logPath)
+	viper.SetDefault("server.logFormat", "json") // json, text
+	viper.SetDefault("server.logLevel", "info")
 	viper.SetDefault("server.passphrase", generateKey())
+	viper.SetDefault("server.previousPassphrase", "")
+	viper.SetDefault("server.cipher", "v1")
 	viper.SetDefault("server.secret", generateKey())
 	viper.SetDefault("server.timeout", 24)
 	viper.SetDefault("server.generatedPasswordLength", 16)
 	viper.SetDefault("server.accessTokenExpireDuration", "30m")
 	viper.SetDefault("server.refreshTokenExpireDuration", "15d")
+	viper.SetDefault("server.machineTokenExpireDuration", "15m")
+	viper.SetDefault("server.sessionIdleTimeout", "")
+	viper.SetDefault("server.sessionAbsoluteTimeout", "")
+	viper.SetDefault("server.countryHeader", "")
+	viper.SetDefault("server.keyThreshold", 0)
+	viper.SetDefault("server.disposableDomainsFile", "")
+	viper.SetDefault("server.trustedProxies", "")
+	viper.SetDefault("server.proxyProtocol", false)
+	viper.SetDefault("server.twoFactorIssuer", "Passwall")
+	viper.SetDefault("server.trustedDeviceDuration", "30d")
+	viper.SetDefault("server.signinMaxFailures", 5)
+	viper.SetDefault("server.signinIPMaxFailures", 20)
+	viper.SetDefault("server.signinLockDuration", "15m")
+	viper.SetDefault("server.signinDelay", "1s")
+	viper.SetDefault("server.requireEmailVerification", true)
+	viper.SetDefault("server.passwordReset", "keep")
+	viper.SetDefault("server.passwordResetURL", "")
 	viper.SetDefault("server.apiKey", generateKey())
-	viper.SetDefault("server.recaptcha", "GoogleRecaptchaSecret")
+	viper.SetDefault("server.readOnly", false)
+	viper.SetDefault("server.zeroKnowledge", false)
+	viper.SetDefault("server.pprof", false)
+	viper.SetDefault("server.shutdownTimeout", "30s")
+	viper.SetDefault("server.rateLimit", 5)
+	viper.SetDefault("server.corsOrigins", "*")
+	viper.SetDefault("server.itemVersions", 10)
+	viper.SetDefault("server.recaptcha", "")
 
 	// Database defaults
+	viper.SetDefault("database.driver", databaseDriver)
+	viper.SetDefault("database.path", filepath.Join(storeDirectory, "passwall.db"))
 	viper.SetDefault("database.name", "passwall")
 	viper.SetDefault("database.username", "postgres")
 	viper.SetDefault("database.password", "password")
 	viper.SetDefault("database.host", "localhost")
 	viper.SetDefault("database.port", "5432")
 	viper.SetDefault("database.logmode", false)
+	viper.SetDefault("database.replicas", "")
+	viper.SetDefault("database.replicaLag", "2s")
+	viper.SetDefault("database.maxOpenConns", 0)
+	viper.SetDefault("database.maxIdleConns", 2)
+	viper.SetDefault("database.connMaxLifetime", "0s")
+	viper.SetDefault("database.autoMigrate", true)
 
 	// Email defaults
 	viper.SetDefault("email.host", "smtp.passwall.io")
@@ -193,10 +629,107 @@ func setDefaults() {
 	viper.SetDefault("email.fromEmail", "hello@passwall.io")
 	viper.SetDefault("email.apiKey", "apiKey")
 
+	// OpenID Connect provider defaults
+	viper.SetDefault("oidc.issuer", "")
+	viper.SetDefault("oidc.keyFile", filepath.Join(storeDirectory, "oidc.pem"))
+	viper.SetDefault("oidc.clients", []OIDCClient{})
+
+	// SSO defaults
+	viper.SetDefault("sso.providers", []SSOProvider{})
+	viper.SetDefault("sso.saml", []SAMLProvider{})
+
+	// Credential rotation defaults
+	viper.SetDefault("rotation.period", "1h")
+
+	// Security alert defaults
+	viper.SetDefault("alert.email", "")
+	viper.SetDefault("alert.webhookURL", "")
+
+	// Audit trail defaults
+	viper.SetDefault("audit.checkpointPeriod", "1h")
+
+	// Per user budget defaults, empty is unlimited
+	viper.SetDefault("budget.export", "10/1h")
+	viper.SetDefault("budget.import", "10/1h")
+	viper.SetDefault("budget.report", "30/1h")
+	viper.SetDefault("budget.search", "120/1m")
+	viper.SetDefault("budget.verification", "3/1h")
+
+	// Retention defaults
+	viper.SetDefault("retention.period", "1d")
+
+	// Re-encryption defaults
+	viper.SetDefault("reencryption.batchSize", 100)
+	viper.SetDefault("reencryption.batchPause", "100ms")
+
+	// WebAuthn defaults
+	viper.SetDefault("webauthn.rpID", "")
+	viper.SetDefault("webauthn.rpName", "Passwall")
+	viper.SetDefault("webauthn.origins", "")
+	viper.SetDefault("webauthn.timeout", "2m")
+
+	// Captcha defaults, signups don't solve one
+	viper.SetDefault("captcha.provider", "")
+	viper.SetDefault("captcha.secret", "")
+	viper.SetDefault("captcha.verifyURL", "")
+
+	// Password hash defaults, the Argon2id parameters of RFC 9106 with less memory
+	viper.SetDefault("passwordHash.memory", 65536)
+	viper.SetDefault("passwordHash.iterations", 3)
+	viper.SetDefault("passwordHash.parallelism", 4)
+
+	// Key derivation defaults of clients
+	viper.SetDefault("kdf.type", "pbkdf2-sha256")
+	viper.SetDefault("kdf.iterations", 0)
+	viper.SetDefault("kdf.memory", 65536)
+	viper.SetDefault("kdf.parallelism", 4)
+
+	// Key management service defaults
+	viper.SetDefault("kms.provider", "")
+	viper.SetDefault("kms.keyID", "")
+	viper.SetDefault("kms.region", "")
+	viper.SetDefault("kms.endpoint", "")
+	viper.SetDefault("kms.token", "")
+	viper.SetDefault("kms.mount", "")
+	viper.SetDefault("kms.wrappedKey", "")
+	viper.SetDefault("kms.wrappedPreviousKey", "")
+
+	// Translation defaults
+	viper.SetDefault("i18n.dir", "")
+	viper.SetDefault("i18n.defaultLocale", "en")
+
+	// Export defaults
+	viper.SetDefault("export.workers", 2)
+	viper.SetDefault("export.urlExpiry", "15m")
+	viper.SetDefault("export.retention", "1d")
+
+	// Blob store defaults
+	viper.SetDefault("blob.driver", "disk")
+	viper.SetDefault("blob.dir", filepath.Join(storeDirectory, "blobs"))
+	viper.SetDefault("blob.s3Region", "us-east-1")
+	viper.SetDefault("blob.s3PathStyle", false)
+
+	// Attachment defaults
+	viper.SetDefault("attachment.maxSize", 25<<20)
+	viper.SetDefault("attachment.quota", 1<<30)
+
+	// Tracing defaults, the standard variables of OpenTelemetry work too
+	viper.SetDefault("tracing.endpoint", os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"))
+	viper.SetDefault("tracing.serviceName", "passwall-server")
+	viper.SetDefault("tracing.sampleRatio", 1)
+	viper.SetDefault("tracing.headers", os.Getenv("OTEL_EXPORTER_OTLP_HEADERS"))
+
+	// Health defaults
+	viper.SetDefault("health.checkEmail", false)
+
 	// Backup defaults
 	viper.SetDefault("backup.folder", storeDirectory)
 	viper.SetDefault("backup.rotation", 7)
 	viper.SetDefault("backup.period", "24h")
+	viper.SetDefault("backup.schedule", "")
+	viper.SetDefault("backup.target", "folder")
+	viper.SetDefault("backup.s3Region", "us-east-1")
+	viper.SetDefault("backup.s3PathStyle", false)
 }
 
 func generateKey() string {
@@ -4,6 +4,8 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/passwall/passwall-server/internal/logging"
+
 	log "github.com/sirupsen/logrus"
 )
 
@@ -12,6 +14,13 @@ import (
 func SetupLogger(cfg *Configuration) (*os.File, error) {
 	var err error
 	var logFile *os.File
+
+	// Lines are JSON objects with the fields of the entry and the request id
+	if cfg.Server.LogFormat != "text" {
+		log.SetFormatter(&log.JSONFormatter{})
+	}
+	log.AddHook(logging.Hook{})
+	setLogLevel()
 	if cfg.Server.Environment == "production" {
 		logPath := filepath.Join(cfg.Server.LogPath, "passwall.log")
 		logFile, err = os.OpenFile(logPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
@@ -0,0 +1,94 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// reloadable maps the settings Reload applies at runtime to their environment variables.
+// The port, the database, the keys and the durations of the sessions change at a restart only.
+var reloadable = map[string]string{
+	"server.logLevel":     "PW_LOG_LEVEL",
+	"server.rateLimit":    "PW_SERVER_RATE_LIMIT",
+	"server.corsOrigins":  "PW_SERVER_CORS_ORIGINS",
+	"email.host":          "PW_EMAIL_HOST",
+	"email.port":          "PW_EMAIL_PORT",
+	"email.username":      "PW_EMAIL_USERNAME",
+	"email.password":      "PW_EMAIL_PASSWORD",
+	"email.fromEmail":     "PW_EMAIL_FROM_EMAIL",
+	"email.fromName":      "PW_EMAIL_FROM_NAME",
+	"email.apiKey":        "PW_EMAIL_API_KEY",
+	"alert.email":         "PW_ALERT_EMAIL",
+	"alert.webhookURL":    "PW_ALERT_WEBHOOK_URL",
+	"budget.export":       "PW_BUDGET_EXPORT",
+	"budget.import":       "PW_BUDGET_IMPORT",
+	"budget.report":       "PW_BUDGET_REPORT",
+	"budget.search":       "PW_BUDGET_SEARCH",
+	"budget.verification": "PW_BUDGET_VERIFICATION",
+	"captcha.secret":      "PW_CAPTCHA_SECRET",
+	"health.checkEmail":   "PW_HEALTH_CHECK_EMAIL",
+}
+
+// Reload reads the configuration file and the files of the _FILE variables again and
+// applies the reloadable settings which aren't set by environment variables. It returns the
+// keys of the changed settings, settings removed from the file keep their value. Nothing
+// is applied if a value is invalid.
+func Reload() ([]string, error) {
+	path := viper.ConfigFileUsed()
+	if path == "" {
+		path = configFileAbsPath + configFileExt
+	}
+	fresh := viper.New()
+	fresh.SetConfigFile(path)
+	if err := fresh.ReadInConfig(); err != nil {
+		return nil, err
+	}
+	// Mounted secrets are updated in place, e.g. a new SMTP password
+	for key, env := range reloadable {
+		if path, ok := os.LookupEnv(env + fileSuffix); ok {
+			value, err := readSecretFile(path)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", env+fileSuffix, err)
+			}
+			fresh.Set(key, value)
+		}
+	}
+
+	if fresh.IsSet("server.logLevel") {
+		if _, err := log.ParseLevel(fresh.GetString("server.logLevel")); err != nil {
+			return nil, fmt.Errorf("server.logLevel: %w", err)
+		}
+	}
+	if fresh.IsSet("server.rateLimit") {
+		if _, err := strconv.Atoi(fmt.Sprint(fresh.Get("server.rateLimit"))); err != nil {
+			return nil, fmt.Errorf("server.rateLimit: %w", err)
+		}
+	}
+
+	var changed []string
+	for key, env := range reloadable {
+		if _, ok := os.LookupEnv(env); ok || !fresh.IsSet(key) {
+			continue
+		}
+		if value := fresh.Get(key); fmt.Sprint(value) != fmt.Sprint(viper.Get(key)) {
+			viper.Set(key, value)
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+
+	setLogLevel()
+	return changed, nil
+}
+
+// setLogLevel applies server.logLevel, invalid levels are left out
+func setLogLevel() {
+	if level, err := log.ParseLevel(viper.GetString("server.logLevel")); err == nil {
+		log.SetLevel(level)
+	}
+}
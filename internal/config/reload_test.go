@@ -0,0 +1,60 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReload(t *testing.T) {
+	defer viper.Reset()
+	defer log.SetLevel(log.GetLevel())
+	dir, err := ioutil.TempDir("", "passwall")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.yml")
+	write := func(content string) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(content), 0600))
+	}
+	write("server:\n  port: 3625\n  logLevel: info\n  rateLimit: 5\n")
+	viper.SetConfigFile(path)
+	require.NoError(t, viper.ReadInConfig())
+	os.Setenv("PW_EMAIL_HOST", "smtp.passwall.io")
+	defer os.Unsetenv("PW_EMAIL_HOST")
+
+	// The port changes at a restart only, settings of environment variables aren't overruled
+	write("server:\n  port: 8080\n  logLevel: debug\n  rateLimit: 10\n  corsOrigins: https://app.passwall.io\nemail:\n  host: smtp.example.com\n")
+	changed, err := Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"server.corsOrigins", "server.logLevel", "server.rateLimit"}, changed)
+	assert.Equal(t, 3625, viper.GetInt("server.port"))
+	assert.Equal(t, 10, viper.GetInt("server.rateLimit"))
+	assert.Equal(t, log.DebugLevel, log.GetLevel())
+
+	changed, err = Reload()
+	require.NoError(t, err)
+	assert.Empty(t, changed)
+
+	// Mounted secrets are read again
+	secret := filepath.Join(dir, "email_password")
+	require.NoError(t, ioutil.WriteFile(secret, []byte("rotated\n"), 0600))
+	os.Setenv("PW_EMAIL_PASSWORD_FILE", secret)
+	defer os.Unsetenv("PW_EMAIL_PASSWORD_FILE")
+	changed, err = Reload()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"email.password"}, changed)
+	assert.Equal(t, "rotated", viper.GetString("email.password"))
+
+	// Nothing is applied if a value is invalid
+	write("server:\n  logLevel: loud\n  rateLimit: 20\n")
+	_, err = Reload()
+	assert.Error(t, err)
+	assert.Equal(t, 10, viper.GetInt("server.rateLimit"))
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// fileSuffix marks the variables which name a file holding the value of a variable, e.g.
+// PW_DB_PASSWORD_FILE=/run/secrets/db_password
+const fileSuffix = "_FILE"
+
+// envKeys maps the bound environment variables to their settings
+var envKeys = map[string]string{}
+
+// bindEnv binds the setting to the environment variable and to the file of its _FILE variable
+func bindEnv(key, env string) {
+	viper.BindEnv(key, env)
+	envKeys[env] = key
+}
+
+// readSecretFiles sets the settings of the variables which have a _FILE variable from
+// the files, so Docker and Kubernetes secrets don't need to be in variables or in the
+// configuration file
+func readSecretFiles() error {
+	for env, key := range envKeys {
+		path, ok := os.LookupEnv(env + fileSuffix)
+		// Variables like PW_OIDC_KEY_FILE are settings of their own
+		if !ok || envKeys[env+fileSuffix] != "" {
+			continue
+		}
+		if _, ok := os.LookupEnv(env); ok {
+			return fmt.Errorf("%s and %s are both set", env, env+fileSuffix)
+		}
+		value, err := readSecretFile(path)
+		if err != nil {
+			return fmt.Errorf("%s: %w", env+fileSuffix, err)
+		}
+		viper.Set(key, value)
+	}
+	return nil
+}
+
+// readSecretFile returns the content of the file without the line break at its end
+func readSecretFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadSecretFiles(t *testing.T) {
+	defer viper.Reset()
+	dir, err := ioutil.TempDir("", "passwall")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "db_password")
+	require.NoError(t, ioutil.WriteFile(path, []byte("s3cret\n"), 0600))
+
+	bindEnv("database.password", "PW_TEST_DB_PASSWORD")
+	bindEnv("oidc.keyFile", "PW_TEST_OIDC_KEY_FILE")
+	bindEnv("oidc.key", "PW_TEST_OIDC_KEY")
+	defer delete(envKeys, "PW_TEST_DB_PASSWORD")
+	defer delete(envKeys, "PW_TEST_OIDC_KEY_FILE")
+	defer delete(envKeys, "PW_TEST_OIDC_KEY")
+	os.Setenv("PW_TEST_DB_PASSWORD_FILE", path)
+	defer os.Unsetenv("PW_TEST_DB_PASSWORD_FILE")
+	os.Setenv("PW_TEST_OIDC_KEY_FILE", path)
+	defer os.Unsetenv("PW_TEST_OIDC_KEY_FILE")
+
+	require.NoError(t, readSecretFiles())
+	assert.Equal(t, "s3cret", viper.GetString("database.password"))
+	// A bound _FILE variable is a path and not read
+	assert.Equal(t, path, viper.GetString("oidc.keyFile"))
+	assert.Empty(t, viper.GetString("oidc.key"))
+
+	os.Setenv("PW_TEST_DB_PASSWORD", "other")
+	defer os.Unsetenv("PW_TEST_DB_PASSWORD")
+	assert.EqualError(t, readSecretFiles(), "PW_TEST_DB_PASSWORD and PW_TEST_DB_PASSWORD_FILE are both set")
+
+	os.Unsetenv("PW_TEST_DB_PASSWORD")
+	os.Setenv("PW_TEST_DB_PASSWORD_FILE", filepath.Join(dir, "missing"))
+	assert.Error(t, readSecretFiles())
+}
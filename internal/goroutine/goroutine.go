@@ -0,0 +1,22 @@
+// Package goroutine identifies the running goroutine. GORM v1 and the repositories don't
+// take a context, so the trace and the request id of a request are kept per goroutine.
+package goroutine
+
+import (
+	"bytes"
+	"runtime"
+	"strconv"
+)
+
+// ID reads the id of the goroutine from the first line of its stack,
+// like "goroutine 18 [running]:"
+func ID() uint64 {
+	var buf [64]byte
+	n := runtime.Stack(buf[:], false)
+	b := bytes.TrimPrefix(buf[:n], []byte("goroutine "))
+	if i := bytes.IndexByte(b, ' '); i > 0 {
+		id, _ := strconv.ParseUint(string(b[:i]), 10, 64)
+		return id
+	}
+	return 0
+}
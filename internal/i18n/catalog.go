@@ -0,0 +1,145 @@
+package i18n
+
+// en has the email templates, other English messages are their own ids
+var en = Catalog{
+	"email.confirmation.subject":     "Passwall Email Confirmation",
+	"email.confirmation.body":        "Last step for use Passwall\n\nConfirmation link: {{.Link}}",
+	"email.password_reset.subject":   "Passwall Password Reset",
+	"email.password_reset.body":      "Someone asked to reset the master password of your Passwall account. The link works for 30 minutes, ignore this email if it wasn't you.\n\nReset link: {{.Link}}",
+	"email.password_changed.subject": "Passwall Master Password Changed",
+	"email.password_changed.body":    "The master password of your Passwall account was reset and all devices were signed out. Contact your admin if it wasn't you.",
+}
+
+// tr is the Turkish catalog, keep it in the order of the packages which use the messages
+var tr = Catalog{
+	// Emails
+	"email.confirmation.subject":     "Passwall E-posta Onayı",
+	"email.confirmation.body":        "Passwall'u kullanmak için son adım\n\nOnay bağlantısı: {{.Link}}",
+	"email.password_reset.subject":   "Passwall Parola Sıfırlama",
+	"email.password_reset.body":      "Passwall hesabınızın ana parolasını sıfırlamak için istekte bulunuldu. Bağlantı 30 dakika geçerlidir, bu isteği siz yapmadıysanız bu e-postayı dikkate almayın.\n\nSıfırlama bağlantısı: {{.Link}}",
+	"email.password_changed.subject": "Passwall Ana Parolası Değişti",
+	"email.password_changed.body":    "Passwall hesabınızın ana parolası sıfırlandı ve tüm cihazlarda oturum kapatıldı. Bunu siz yapmadıysanız yöneticinize başvurun.",
+
+	// Alerts
+	"Passwall Canary Alert": "Passwall Tuzak Kayıt Uyarısı",
+	"The canary item %s was %s. Nobody uses it, the vault may be compromised.": "Tuzak kayıt %s için %s işlemi yapıldı. Bu kaydı kimse kullanmaz, kasa ele geçirilmiş olabilir.",
+	"User ID": "Kullanıcı No",
+	"Item":    "Kayıt",
+	"Source":  "Kaynak",
+	"Title":   "Başlık",
+	"Time":    "Zaman",
+
+	// Responses
+	"Success":                                 "Başarılı",
+	"Error":                                   "Hata",
+	"Invalid request payload":                 "İstek içeriği geçersiz",
+	"Invalid resquest payload":                "İstek içeriği geçersiz",
+	"Invalid json provided":                   "Geçersiz json gönderildi",
+	"Only admins can do this operation":       "Bu işlemi yalnızca yöneticiler yapabilir",
+	"User email or master password is wrong.": "E-posta adresi veya ana parola yanlış.",
+	"Account is locked after too many failed sign ins, try again later": "Çok fazla başarısız girişten sonra hesap kilitlendi, daha sonra tekrar deneyin",
+	"Too many failed sign ins, try again later":                         "Çok fazla başarısız giriş, daha sonra tekrar deneyin",
+	"Captcha is required":                                               "Doğrulama kodu gerekli",
+	"Captcha is not valid":                                              "Doğrulama kodu geçerli değil",
+	"Captcha couldn't be verified, try again later":                     "Doğrulama kodu doğrulanamadı, daha sonra tekrar deneyin",
+	"Verification link is expired or not valid":                         "Doğrulama bağlantısının süresi dolmuş veya geçerli değil",
+	"A new verification link is sent if the account isn't verified yet": "Hesap henüz doğrulanmadıysa yeni bir doğrulama bağlantısı gönderildi",
+	"Too many verification emails, try again later":                     "Çok fazla doğrulama e-postası, daha sonra tekrar deneyin",
+	"Password reset is turned off, ask an admin":                        "Parola sıfırlama kapalı, bir yöneticiye başvurun",
+	"Password reset link is expired or not valid":                       "Parola sıfırlama bağlantısının süresi dolmuş veya geçerli değil",
+	"The reset wipes the vault, confirm it with wipe_vault":             "Sıfırlama kasayı siler, wipe_vault ile onaylayın",
+	"A password reset link is sent if the account exists":               "Hesap varsa bir parola sıfırlama bağlantısı gönderildi",
+	"Master password is reset, sign in with the new one":                "Ana parola sıfırlandı, yeni parolayla giriş yapın",
+	"Key rotation is finished, the previous passphrase is dropped":      "Anahtar değişimi bitti, önceki parola kaldırıldı",
+	"Please verify your email first.":                                   "Lütfen önce e-posta adresinizi doğrulayın.",
+	"Invalid user":                                                      "Geçersiz kullanıcı",
+	"Token is valid":                                                    "Token geçerli",
+	"Token is expired or not valid!":                                    "Token süresi dolmuş veya geçersiz!",
+	"Token could not found! ":                                           "Token bulunamadı! ",
+	"Token could not be created":                                        "Token oluşturulamadı",
+	"Token revoked successfully!":                                       "Token başarıyla iptal edildi!",
+	"Session revoked successfully!":                                     "Oturum başarıyla iptal edildi!",
+	"Session not found":                                                 "Oturum bulunamadı",
+	"Trusted device revoked successfully!":                              "Güvenilen cihaz başarıyla iptal edildi!",
+	"Trusted device not found":                                          "Güvenilen cihaz bulunamadı",
+	"Refresh token is already used, the session is revoked":             "Yenileme token'ı zaten kullanılmış, oturum iptal edildi",
+	"User created successfully":                                         "Kullanıcı başarıyla oluşturuldu",
+	"Email verified successfully":                                       "E-posta başarıyla doğrulandı",
+	"User couldn't created!":                                            "Kullanıcı oluşturulamadı!",
+	"Email couldn't confirm!":                                           "E-posta onaylanamadı!",
+	"Login deleted successfully!":                                       "Giriş bilgisi başarıyla silindi!",
+	"BankAccount deleted successfully!":                                 "Banka hesabı başarıyla silindi!",
+	"CreditCard deleted successfully!":                                  "Kredi kartı başarıyla silindi!",
+	"Note deleted successfully!":                                        "Not başarıyla silindi!",
+	"Server deleted successfully!":                                      "Sunucu başarıyla silindi!",
+	"Subscription deleted successfully!":                                "Abonelik başarıyla silindi!",
+	"Equivalent domains deleted successfully!":                          "Eşdeğer alan adları başarıyla silindi!",
+	"Item order updated successfully!":                                  "Kayıt sırası başarıyla güncellendi!",
+	"Machine account deleted successfully!":                             "Makine hesabı başarıyla silindi!",
+	"Machine account not found":                                         "Makine hesabı bulunamadı",
+	"Machine accounts can't be created in this session":                 "Bu oturumda makine hesabı oluşturulamaz",
+	"Personal access token revoked successfully!":                       "Kişisel erişim anahtarı başarıyla iptal edildi!",
+	"Personal access token not found":                                   "Kişisel erişim anahtarı bulunamadı",
+	"Personal access tokens can't be created in this session":           "Bu oturumda kişisel erişim anahtarı oluşturulamaz",
+	"Expiry of the token has to be in the future":                       "Anahtarın bitiş tarihi gelecekte olmalı",
+	"Restore from backup completed successfully!":                       "Yedekten geri yükleme başarıyla tamamlandı!",
+	"Import finished successfully!":                                     "İçe aktarma başarıyla tamamlandı!",
+	"Backup completed successfully!":                                    "Yedekleme başarıyla tamamlandı!",
+	"Locale is not supported":                                           "Dil desteklenmiyor",
+
+	// Validation errors
+	"validation failed on field '%s'": "'%s' alanı doğrulanamadı",
+	", condition: %s":                 ", koşul: %s",
+	", actual: %v":                    ", gelen değer: %v",
+
+	// Errors of the app package
+	"blocked countries should be ISO 3166 codes like TR":                                              "engellenen ülkeler TR gibi ISO 3166 kodları olmalı",
+	"access hours should be like 09:00-18:00":                                                         "erişim saatleri 09:00-18:00 biçiminde olmalı",
+	"access days should be like mon, tue, wed":                                                        "erişim günleri mon, tue, wed biçiminde olmalı",
+	"error occurred while backing up data":                                                            "veriler yedeklenirken hata oluştu",
+	"backup file could not be decrypted, check the passphrase":                                        "yedek dosyasının şifresi çözülemedi, parolayı kontrol edin",
+	"new passphrase should be at least 16 characters and differ from the current one":                 "yeni parola en az 16 karakter olmalı ve mevcut paroladan farklı olmalı",
+	"previous key rotation isn't finished":                                                            "önceki anahtar değişimi bitmedi",
+	"passphrase is set in PW_SERVER_PASSPHRASE, rotate it in the environment and restart":             "parola PW_SERVER_PASSPHRASE içinde, ortamda değiştirip yeniden başlatın",
+	"server key is split, rotate it by splitting a new passphrase":                                    "sunucu anahtarı bölünmüş, yeni bir parolayı bölerek değiştirin",
+	"the server can't read the vault in zero-knowledge mode":                                          "sunucu sıfır bilgi modunda kasayı okuyamaz",
+	"since and until should be dates like 2006-01-02 or RFC 3339 times":                               "since ve until 2006-01-02 gibi tarihler ya da RFC 3339 zamanları olmalı",
+	"format should be csv or jsonl":                                                                   "format csv ya da jsonl olmalı",
+	"action should be create, read, update, delete or signin":                                         "action create, read, update, delete ya da signin olmalı",
+	"card number is not valid":                                                                        "kart numarası geçersiz",
+	"expiry date should be in MM/YY or MM/YYYY format":                                                "son kullanma tarihi AA/YY veya AA/YYYY biçiminde olmalı",
+	"disposable email addresses can't sign up":                                                        "geçici e-posta adresleriyle kayıt olunamaz",
+	"master password is wrong":                                                                        "ana parola yanlış",
+	"duress password should be different from the master password":                                    "baskı parolası ana paroladan farklı olmalı",
+	"Two factor code is wrong":                                                                        "iki aşamalı doğrulama kodu yanlış",
+	"Two factor sign in is expired, sign in again":                                                    "iki aşamalı girişin süresi doldu, yeniden giriş yapın",
+	"TOTP is not enrolled, enroll it first":                                                           "TOTP kaydedilmemiş, önce kaydedin",
+	"two factor authentication is already enabled":                                                    "iki aşamalı doğrulama zaten açık",
+	"two factor authentication is not enabled":                                                        "iki aşamalı doğrulama açık değil",
+	"Security key couldn't be verified":                                                               "Güvenlik anahtarı doğrulanamadı",
+	"Security key deleted successfully!":                                                              "Güvenlik anahtarı başarıyla silindi!",
+	"security key is already registered":                                                              "güvenlik anahtarı zaten kayıtlı",
+	"no security key is registered":                                                                   "kayıtlı güvenlik anahtarı yok",
+	"Add an authenticator app to sign in here, security keys are only supported by the Passwall apps": "Burada giriş yapmak için bir doğrulama uygulaması ekleyin, güvenlik anahtarları yalnızca Passwall uygulamalarında desteklenir",
+	"Single sign-on is expired, sign in again":                                                        "Tek oturum açmanın süresi doldu, yeniden giriş yapın",
+	"No user has the email of the single sign-on account":                                             "Tek oturum açma hesabının e-postasına sahip kullanıcı yok",
+	"Single sign-on provider couldn't sign in the user":                                               "Tek oturum açma sağlayıcısı kullanıcının girişini yapamadı",
+	"unknown single sign-on provider":                                                                 "bilinmeyen tek oturum açma sağlayıcısı",
+	"Identity provider couldn't sign in the user":                                                     "Kimlik sağlayıcısı kullanıcının girişini yapamadı",
+	"unknown SAML identity provider":                                                                  "bilinmeyen SAML kimlik sağlayıcısı",
+	"too many exports are running, try again later":                                                   "çok fazla dışa aktarma çalışıyor, daha sonra tekrar deneyin",
+	"export link is not valid or expired":                                                             "dışa aktarma bağlantısı geçersiz veya süresi dolmuş",
+	"the server restarted before the export finished, start a new export":                             "sunucu dışa aktarma bitmeden yeniden başladı, yeni bir dışa aktarma başlatın",
+	"unknown item type":                                                                               "bilinmeyen kayıt türü",
+	"client id or secret is wrong":                                                                    "istemci kimliği veya gizli anahtarı yanlış",
+	"machine account is not allowed to read the item":                                                 "makine hesabının bu kaydı okuma izni yok",
+	"items should be like logins/3":                                                                   "kayıtlar logins/3 biçiminde olmalı",
+	"format should be dotenv or json":                                                                 "biçim dotenv veya json olmalı",
+	"session is locked after inactivity":                                                              "oturum hareketsizlik nedeniyle kilitlendi",
+	"session is expired, sign in again":                                                               "oturumun süresi doldu, tekrar giriş yapın",
+	"login has no rotation provider":                                                                  "giriş bilgisinin parola yenileme sağlayıcısı yok",
+	"period should be a number with m, h or d suffix e.g. 30d":                                        "süre m, h veya d ekli bir sayı olmalı, örneğin 30d",
+	"Two factor authentication is required outside the office network":                                "Ofis ağı dışında iki adımlı doğrulama gerekli",
+	"Access is not allowed at this time":                                                              "Bu saatte erişime izin verilmiyor",
+	"A security key is required to sign in":                                                           "Giriş yapmak için güvenlik anahtarı gerekli",
+}
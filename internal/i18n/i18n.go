@@ -0,0 +1,206 @@
+// Package i18n translates the messages, validation errors and emails of the server.
+//
+// Messages are looked up by their English text, so untranslated messages and
+// dynamic errors are shown as they are. Emails are templates with ids like
+// "email.confirmation.body". English and Turkish are built in, operators add
+// or override catalogs with files like "de.yml" or "tr.json" in i18n.dir.
+package i18n
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Default is the locale of messages when no catalog matches the request
+const Default = "en"
+
+// Header carries the negotiated locale of a response, handlers read it back from there
+const Header = "Content-Language"
+
+// Catalog maps message ids to their translations
+type Catalog map[string]string
+
+// Decoder decodes a catalog file
+type Decoder func(data []byte) (Catalog, error)
+
+var catalogs = struct {
+	sync.RWMutex
+	m map[string]Catalog
+}{m: map[string]Catalog{"en": en, "tr": tr}}
+
+var decoders = map[string]Decoder{
+	".yml":  decodeYAML,
+	".yaml": decodeYAML,
+	".json": decodeJSON,
+}
+
+// RegisterFormat adds a catalog file format by its extension like ".po"
+func RegisterFormat(ext string, decode Decoder) {
+	decoders[ext] = decode
+}
+
+// Register adds the messages to the catalog of the locale, existing messages are replaced
+func Register(locale string, catalog Catalog) {
+	catalogs.Lock()
+	defer catalogs.Unlock()
+
+	locale = strings.ToLower(locale)
+	merged := Catalog{}
+	for id, message := range catalogs.m[locale] {
+		merged[id] = message
+	}
+	for id, message := range catalog {
+		merged[id] = message
+	}
+	catalogs.m[locale] = merged
+}
+
+// LoadDir registers the catalog files in dir, the name of a file is its locale
+func LoadDir(dir string) error {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		ext := filepath.Ext(file.Name())
+		decode, ok := decoders[ext]
+		if file.IsDir() || !ok {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(dir, file.Name()))
+		if err != nil {
+			return err
+		}
+		catalog, err := decode(data)
+		if err != nil {
+			return fmt.Errorf("%s: %w", file.Name(), err)
+		}
+		Register(strings.TrimSuffix(file.Name(), ext), catalog)
+	}
+	return nil
+}
+
+// Locales returns the locales with a catalog
+func Locales() []string {
+	catalogs.RLock()
+	defer catalogs.RUnlock()
+
+	locales := make([]string, 0, len(catalogs.m))
+	for locale := range catalogs.m {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// Supported reports whether there is a catalog of the locale
+func Supported(locale string) bool {
+	catalogs.RLock()
+	defer catalogs.RUnlock()
+	_, ok := catalogs.m[strings.ToLower(locale)]
+	return ok
+}
+
+// T translates the message to the locale, it is returned as it is when there is no translation
+func T(locale, id string) string {
+	catalogs.RLock()
+	defer catalogs.RUnlock()
+
+	if message, ok := catalogs.m[strings.ToLower(locale)][id]; ok {
+		return message
+	}
+	if message, ok := catalogs.m[Default][id]; ok {
+		return message
+	}
+	return id
+}
+
+// Sprintf translates the format to the locale and formats it with the args
+func Sprintf(locale, format string, args ...interface{}) string {
+	return fmt.Sprintf(T(locale, format), args...)
+}
+
+// Render executes the template with the id like "email.confirmation.body" in the locale
+func Render(locale, id string, data interface{}) (string, error) {
+	t, err := template.New(id).Parse(T(locale, id))
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	err = t.Execute(&sb, data)
+	return sb.String(), err
+}
+
+// Negotiate returns the first supported locale of the preferred ones, like the
+// choice of the user, or else the best match of the Accept-Language header.
+// fallback is returned when nothing matches.
+func Negotiate(acceptLanguage, fallback string, preferred ...string) string {
+	for _, locale := range preferred {
+		if locale != "" && Supported(locale) {
+			return strings.ToLower(locale)
+		}
+	}
+
+	type tag struct {
+		locale string
+		q      float64
+	}
+	tags := []tag{}
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		t := tag{locale: strings.ToLower(strings.TrimSpace(fields[0])), q: 1}
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					t.q = q
+				}
+			}
+		}
+		if t.locale != "" && t.q > 0 {
+			tags = append(tags, t)
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+
+	for _, t := range tags {
+		if Supported(t.locale) {
+			return t.locale
+		}
+		// tr-TR falls back to tr
+		if i := strings.Index(t.locale, "-"); i > 0 && Supported(t.locale[:i]) {
+			return t.locale[:i]
+		}
+	}
+	return fallback
+}
+
+// LocaleOf returns the negotiated locale of the response
+func LocaleOf(w http.ResponseWriter) string {
+	if locale := w.Header().Get(Header); locale != "" {
+		return locale
+	}
+	return Default
+}
+
+func decodeYAML(data []byte) (Catalog, error) {
+	catalog := Catalog{}
+	return catalog, yaml.Unmarshal(data, &catalog)
+}
+
+func decodeJSON(data []byte) (Catalog, error) {
+	catalog := Catalog{}
+	return catalog, json.Unmarshal(data, &catalog)
+}
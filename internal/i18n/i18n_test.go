@@ -0,0 +1,71 @@
+package i18n
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		acceptLanguage string
+		preferred      string
+		want           string
+	}{
+		{"", "", "en"},
+		{"tr", "", "tr"},
+		{"tr-TR,tr;q=0.9,en;q=0.8", "", "tr"},
+		{"de-DE,en;q=0.5,tr;q=0.7", "", "tr"},
+		{"de, fr;q=0.9", "", "en"},
+		{"tr;q=0, en", "", "en"},
+		{"tr", "en", "en"},
+		{"tr", "xx", "tr"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, Negotiate(tt.acceptLanguage, Default, tt.preferred), tt.acceptLanguage)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	assert.Equal(t, "İstek içeriği geçersiz", T("tr", "Invalid request payload"))
+	assert.Equal(t, "İstek içeriği geçersiz", T("TR", "Invalid request payload"))
+	assert.Equal(t, "Invalid request payload", T("en", "Invalid request payload"))
+	assert.Equal(t, "not translated", T("tr", "not translated"))
+	assert.Equal(t, "'email' alanı doğrulanamadı", Sprintf("tr", "validation failed on field '%s'", "email"))
+
+	// Email templates fall back to English
+	assert.Equal(t, "Passwall Email Confirmation", T("xx", "email.confirmation.subject"))
+	body, err := Render("tr", "email.confirmation.body", map[string]string{"Link": "https://vault/confirm"})
+	assert.NoError(t, err)
+	assert.Contains(t, body, "Onay bağlantısı: https://vault/confirm")
+}
+
+func TestLoadDir(t *testing.T) {
+	saved := map[string]Catalog{}
+	for locale, catalog := range catalogs.m {
+		saved[locale] = catalog
+	}
+	defer func() { catalogs.m = saved }()
+	dir, err := ioutil.TempDir("", "i18n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "de.yml"), []byte(`"Invalid request payload": "Ungültige Anfrage"`), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "tr.json"), []byte(`{"Invalid user": "Kullanıcı geçersiz"}`), 0600))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte(`not a catalog`), 0600))
+	assert.NoError(t, LoadDir(dir))
+
+	assert.True(t, Supported("de"))
+	assert.Equal(t, "Ungültige Anfrage", T("de", "Invalid request payload"))
+	assert.Equal(t, "Kullanıcı geçersiz", T("tr", "Invalid user"))
+	assert.Equal(t, "İstek içeriği geçersiz", T("tr", "Invalid request payload"))
+	assert.Equal(t, "de", Negotiate("de-AT", Default))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "fr.json"), []byte(`{`), 0600))
+	assert.Error(t, LoadDir(dir))
+}
@@ -0,0 +1,35 @@
+package logging
+
+import (
+	"fmt"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// GormLogger writes the log of GORM as structured lines. Statements are logged with
+// their placeholders, the values can be secrets of the vaults.
+type GormLogger struct{}
+
+// Print logs the sql, error and log lines of GORM
+func (GormLogger) Print(v ...interface{}) {
+	if len(v) < 2 {
+		return
+	}
+	entry := log.WithField("source", v[1])
+	switch v[0] {
+	case "sql":
+		if len(v) < 6 {
+			return
+		}
+		fields := log.Fields{"rows": v[5]}
+		if duration, ok := v[2].(time.Duration); ok {
+			fields["duration_ms"] = float64(duration) / float64(time.Millisecond)
+		}
+		entry.WithFields(fields).Info(v[3])
+	case "error":
+		entry.Error(v[2:]...)
+	default:
+		entry.Info(fmt.Sprint(v[2:]...))
+	}
+}
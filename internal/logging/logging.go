@@ -0,0 +1,104 @@
+// Package logging tags the log lines of a request with its request id. The id is bound
+// to the goroutine serving the request, so the lines of the services and of the storage
+// layer carry it without passing it down.
+package logging
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+
+	"github.com/passwall/passwall-server/internal/goroutine"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Header carries the request id from the caller and back to it
+const Header = "X-Request-ID"
+
+// Field is the key of the request id in the log lines
+const Field = "request_id"
+
+// maxRequestID limits the ids callers can send
+const maxRequestID = 128
+
+var bound = struct {
+	sync.RWMutex
+	ids map[uint64]string
+}{ids: map[uint64]string{}}
+
+// NewRequestID returns a random request id
+func NewRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID is true for ids a caller can send, they are logged as they are
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestID {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '/', c == '+', c == '=':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Bind tags the log lines of the goroutine with the request id until the returned
+// function is called
+func Bind(id string) func() {
+	g := goroutine.ID()
+	bound.Lock()
+	previous, nested := bound.ids[g]
+	bound.ids[g] = id
+	bound.Unlock()
+	return func() {
+		bound.Lock()
+		if nested {
+			bound.ids[g] = previous
+		} else {
+			delete(bound.ids, g)
+		}
+		bound.Unlock()
+	}
+}
+
+// RequestID returns the request id bound to the goroutine
+func RequestID() string {
+	bound.RLock()
+	defer bound.RUnlock()
+	return bound.ids[goroutine.ID()]
+}
+
+// Hook adds the request id of the goroutine to the log lines
+type Hook struct{}
+
+// Levels are all levels
+func (Hook) Levels() []log.Level {
+	return log.AllLevels
+}
+
+// Fire adds the request_id field. The fields of the entry are shared with the entry the
+// caller logged with, they are copied before.
+func (Hook) Fire(entry *log.Entry) error {
+	if _, ok := entry.Data[Field]; ok {
+		return nil
+	}
+	id := RequestID()
+	if id == "" {
+		return nil
+	}
+	data := make(log.Fields, len(entry.Data)+1)
+	for key, value := range entry.Data {
+		data[key] = value
+	}
+	data[Field] = id
+	entry.Data = data
+	return nil
+}
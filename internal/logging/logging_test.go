@@ -0,0 +1,68 @@
+package logging
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func newLogger() (*log.Logger, *bytes.Buffer) {
+	var out bytes.Buffer
+	logger := log.New()
+	logger.SetOutput(&out)
+	logger.SetFormatter(&log.JSONFormatter{DisableTimestamp: true})
+	logger.AddHook(Hook{})
+	return logger, &out
+}
+
+func TestValidRequestID(t *testing.T) {
+	assert.True(t, ValidRequestID(NewRequestID()))
+	assert.True(t, ValidRequestID("Root=1-5759e988-bd862e3fe1be46a994272793"))
+	assert.False(t, ValidRequestID(""))
+	assert.False(t, ValidRequestID("id\r\nSet-Cookie: session=1"))
+	assert.False(t, ValidRequestID(strings.Repeat("a", maxRequestID+1)))
+}
+
+func TestHook(t *testing.T) {
+	logger, out := newLogger()
+	logger.Info("startup")
+	assert.Equal(t, `{"level":"info","msg":"startup"}`+"\n", out.String())
+
+	unbind := Bind("outer")
+	inner := Bind("inner")
+	out.Reset()
+	entry := logger.WithField("user_id", 7)
+	entry.Error("password hash couldn't be upgraded")
+	assert.Equal(t, `{"level":"error","msg":"password hash couldn't be upgraded","request_id":"inner","user_id":7}`+"\n", out.String())
+
+	// The fields of the caller's entry aren't changed
+	assert.Equal(t, log.Fields{"user_id": 7}, entry.Data)
+
+	inner()
+	assert.Equal(t, "outer", RequestID())
+
+	// Other goroutines aren't tagged
+	done := make(chan string)
+	go func() { done <- RequestID() }()
+	assert.Empty(t, <-done)
+
+	unbind()
+	assert.Empty(t, RequestID())
+}
+
+func TestGormLogger(t *testing.T) {
+	out := new(bytes.Buffer)
+	log.SetOutput(out)
+	log.SetFormatter(&log.JSONFormatter{DisableTimestamp: true})
+	defer log.SetFormatter(&log.TextFormatter{})
+	defer log.SetOutput(os.Stderr)
+
+	GormLogger{}.Print("sql", "login_repository.go:31", 1500*time.Microsecond, "SELECT * FROM logins WHERE (password = $1)", []interface{}{"hunter2"}, int64(1))
+	assert.Equal(t, `{"duration_ms":1.5,"level":"info","msg":"SELECT * FROM logins WHERE (password = $1)","rows":1,"source":"login_repository.go:31"}`+"\n", out.String())
+}
@@ -0,0 +1,189 @@
+// Package proxyproto reads the PROXY protocol header which load balancers like HAProxy
+// or AWS NLB send before the traffic of a connection, so the server sees the address of
+// the client instead of the address of the load balancer. Versions 1 and 2 are supported.
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// headerTimeout limits the wait for the header of a new connection
+const headerTimeout = 5 * time.Second
+
+var (
+	v1Prefix    = []byte("PROXY ")
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	errHeader = errors.New("proxy protocol header is not valid")
+)
+
+// Listener reads the header of connections from trusted peers.
+// Connections of other peers are served as they are.
+type Listener struct {
+	net.Listener
+	Trusted func(ip net.IP) bool
+}
+
+// Accept ...
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	addr, ok := conn.RemoteAddr().(*net.TCPAddr)
+	if !ok || !l.Trusted(addr.IP) {
+		return conn, nil
+	}
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// Conn is a connection from a proxy, the header is read on its first use
+// in the goroutine of the connection, so a slow peer doesn't block Accept
+type Conn struct {
+	net.Conn
+	reader *bufio.Reader
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+// Read ...
+func (c *Conn) Read(b []byte) (int, error) {
+	c.once.Do(c.readHeader)
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(b)
+}
+
+// RemoteAddr returns the address of the client from the header,
+// or the address of the proxy for health checks without a client
+func (c *Conn) RemoteAddr() net.Addr {
+	c.once.Do(c.readHeader)
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) readHeader() {
+	c.Conn.SetReadDeadline(time.Now().Add(headerTimeout))
+	defer c.Conn.SetReadDeadline(time.Time{})
+
+	c.remote, c.err = ReadHeader(c.reader)
+	if c.err != nil {
+		c.Conn.Close()
+	}
+}
+
+// ReadHeader reads a version 1 or 2 header from r and returns the source address of
+// the client. It is nil for LOCAL and UNKNOWN connections which have no client.
+func ReadHeader(r *bufio.Reader) (net.Addr, error) {
+	prefix, err := r.Peek(len(v1Prefix))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(prefix, v1Prefix) {
+		return readV1(r)
+	}
+
+	signature, err := r.Peek(len(v2Signature))
+	if err != nil {
+		return nil, err
+	}
+	if bytes.Equal(signature, v2Signature) {
+		return readV2(r)
+	}
+	return nil, errHeader
+}
+
+// readV1 reads a header like "PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\n"
+func readV1(r *bufio.Reader) (net.Addr, error) {
+	// The longest header is 107 bytes
+	line := make([]byte, 0, 107)
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) == cap(line) {
+			return nil, errHeader
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, errHeader
+	}
+
+	parts := strings.Split(string(line[:len(line)-2]), " ")
+	if len(parts) >= 2 && parts[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(parts) != 6 || (parts[1] != "TCP4" && parts[1] != "TCP6") {
+		return nil, errHeader
+	}
+
+	ip := net.ParseIP(parts[2])
+	port, err := strconv.Atoi(parts[4])
+	if ip == nil || err != nil || port < 0 || port > 65535 {
+		return nil, errHeader
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readV2 reads the binary header, see section 2.2 of the specification
+func readV2(r *bufio.Reader) (net.Addr, error) {
+	header := make([]byte, 16)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	version, command := header[12]>>4, header[12]&0x0f
+	family := header[13]
+	length := binary.BigEndian.Uint16(header[14:16])
+	if version != 2 {
+		return nil, fmt.Errorf("proxy protocol version %d is not supported", version)
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+
+	// LOCAL connections come from the proxy itself, like health checks
+	if command == 0 {
+		return nil, nil
+	}
+	if command != 1 {
+		return nil, errHeader
+	}
+
+	switch family >> 4 {
+	case 1: // IPv4: source, destination addresses and ports
+		if len(body) < 12 {
+			return nil, errHeader
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:4]), Port: int(binary.BigEndian.Uint16(body[8:10]))}, nil
+	case 2: // IPv6
+		if len(body) < 36 {
+			return nil, errHeader
+		}
+		return &net.TCPAddr{IP: net.IP(body[0:16]), Port: int(binary.BigEndian.Uint16(body[32:34]))}, nil
+	}
+	// Unix sockets and unspecified families have no client address
+	return nil, nil
+}
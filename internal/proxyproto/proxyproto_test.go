@@ -0,0 +1,79 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadHeaderV1(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("PROXY TCP4 192.0.2.1 198.51.100.1 56324 443\r\nGET / HTTP/1.1\r\n"))
+	addr, err := ReadHeader(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1:56324", addr.String())
+
+	rest, _ := r.ReadString('\n')
+	assert.Equal(t, "GET / HTTP/1.1\r\n", rest)
+
+	addr, err = ReadHeader(bufio.NewReader(strings.NewReader("PROXY UNKNOWN\r\n")))
+	assert.NoError(t, err)
+	assert.Nil(t, addr)
+
+	_, err = ReadHeader(bufio.NewReader(strings.NewReader("PROXY TCP4 192.0.2.1\r\n")))
+	assert.Equal(t, errHeader, err)
+	_, err = ReadHeader(bufio.NewReader(strings.NewReader("GET / HTTP/1.1\r\n")))
+	assert.Equal(t, errHeader, err)
+}
+
+func TestReadHeaderV2(t *testing.T) {
+	var header bytes.Buffer
+	header.Write(v2Signature)
+	header.Write([]byte{0x21, 0x11}) // version 2 PROXY, TCP over IPv4
+	binary.Write(&header, binary.BigEndian, uint16(12))
+	header.Write(net.ParseIP("192.0.2.1").To4())
+	header.Write(net.ParseIP("198.51.100.1").To4())
+	binary.Write(&header, binary.BigEndian, uint16(56324))
+	binary.Write(&header, binary.BigEndian, uint16(443))
+	header.WriteString("payload")
+
+	r := bufio.NewReader(&header)
+	addr, err := ReadHeader(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "192.0.2.1:56324", addr.String())
+
+	rest, _ := ioutil.ReadAll(r)
+	assert.Equal(t, "payload", string(rest))
+}
+
+func TestListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	proxied := &Listener{Listener: ln, Trusted: func(ip net.IP) bool { return ip.IsLoopback() }}
+
+	go func() {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte("PROXY TCP6 2001:db8::1 2001:db8::2 4000 443\r\nhello"))
+	}()
+
+	conn, err := proxied.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	assert.Equal(t, "[2001:db8::1]:4000", conn.RemoteAddr().String())
+	body, err := ioutil.ReadAll(conn)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(body))
+}
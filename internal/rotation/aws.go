@@ -0,0 +1,161 @@
+package rotation
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	awsIAMEndpoint = "https://iam.amazonaws.com/"
+	awsIAMRegion   = "us-east-1"
+	awsIAMVersion  = "2010-05-08"
+)
+
+// AWSIAM rotates the access key of an IAM user. The username of the login is the
+// access key id and the password is the secret access key.
+// The new key is created with the old key, then the old key is deleted. So the IAM
+// user needs iam:CreateAccessKey and iam:DeleteAccessKey permissions on itself and
+// it can't have a second access key.
+type AWSIAM struct {
+	Endpoint string // https://iam.amazonaws.com/ if empty
+	Client   *http.Client
+}
+
+type awsAccessKey struct {
+	AccessKeyID     string `xml:"CreateAccessKeyResult>AccessKey>AccessKeyId"`
+	SecretAccessKey string `xml:"CreateAccessKeyResult>AccessKey>SecretAccessKey"`
+}
+
+type awsError struct {
+	Code    string `xml:"Error>Code"`
+	Message string `xml:"Error>Message"`
+}
+
+// Rotate creates a new access key and deletes the old one
+func (a *AWSIAM) Rotate(ctx context.Context, c *Credential) (*Credential, error) {
+	body, err := a.call(ctx, c, url.Values{"Action": {"CreateAccessKey"}})
+	if err != nil {
+		return nil, err
+	}
+
+	var key awsAccessKey
+	if err := xml.Unmarshal(body, &key); err != nil {
+		return nil, err
+	}
+	if key.AccessKeyID == "" || key.SecretAccessKey == "" {
+		return nil, fmt.Errorf("aws-iam: access key is missing in the response")
+	}
+
+	rotated := *c
+	rotated.Username = key.AccessKeyID
+	rotated.Password = key.SecretAccessKey
+
+	// The new key may not be usable for a few seconds, the old key is still valid
+	_, err = a.call(ctx, c, url.Values{"Action": {"DeleteAccessKey"}, "AccessKeyId": {c.Username}})
+	if err != nil {
+		return &rotated, &RevokeError{Err: fmt.Errorf("aws-iam: new key %s is created but the old key %s isn't deleted: %w", key.AccessKeyID, c.Username, err)}
+	}
+	return &rotated, nil
+}
+
+// call sends the IAM query api request signed with the credential
+func (a *AWSIAM) call(ctx context.Context, c *Credential, params url.Values) ([]byte, error) {
+	endpoint := a.Endpoint
+	if endpoint == "" {
+		endpoint = awsIAMEndpoint
+	}
+
+	params.Set("Version", awsIAMVersion)
+	payload := params.Encode()
+
+	req, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	signAWSRequest(req, payload, c.Username, c.Password, time.Now().UTC())
+
+	resp, err := client(a.Client).Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		var e awsError
+		if xml.Unmarshal(body, &e) == nil && e.Code != "" {
+			return nil, fmt.Errorf("aws-iam: %s: %s", e.Code, e.Message)
+		}
+		return nil, fmt.Errorf("aws-iam: unexpected status %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// signAWSRequest adds the signature version 4 authorization header of the iam service
+func signAWSRequest(req *http.Request, payload, accessKeyID, secretKey string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	scope := date + "/" + awsIAMRegion + "/iam/aws4_request"
+
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+
+	signedHeaders := "content-type;host;x-amz-date"
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		"content-type:" + req.Header.Get("Content-Type"),
+		"host:" + req.URL.Host,
+		"x-amz-date:" + amzDate,
+		"",
+		signedHeaders,
+		hexSHA256(payload),
+	}, "\n")
+
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hexSHA256(canonicalRequest),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, awsIAMRegion)
+	key = hmacSHA256(key, "iam")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func hexSHA256(data string) string {
+	sum := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(sum[:])
+}
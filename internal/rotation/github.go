@@ -0,0 +1,97 @@
+package rotation
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// GitHubApp issues a new installation access token of a GitHub App and revokes
+// the old token. GitHub has no api to create personal access tokens, so the
+// tokens of an app installation are used in their place.
+// The url of the login is the installation, e.g. https://api.github.com/app/installations/42,
+// the username is the app id and the extra field is the private key of the app in PEM format.
+// Installation tokens expire after an hour, the rotation period should be shorter.
+type GitHubApp struct {
+	Client *http.Client
+}
+
+type githubToken struct {
+	Token string `json:"token"`
+}
+
+// Rotate requests a new installation token and revokes the old one
+func (g *GitHubApp) Rotate(ctx context.Context, c *Credential) (*Credential, error) {
+	installation := strings.TrimRight(c.URL, "/")
+	i := strings.Index(installation, "/app/installations/")
+	if i < 0 {
+		return nil, fmt.Errorf("github-app: url is not an app installation")
+	}
+	apiURL := installation[:i]
+
+	key, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(c.Extra))
+	if err != nil {
+		return nil, fmt.Errorf("github-app: %w", err)
+	}
+
+	// GitHub accepts app tokens up to 10 minutes and allows a minute of clock drift
+	now := time.Now()
+	appToken, err := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.StandardClaims{
+		IssuedAt:  now.Add(-time.Minute).Unix(),
+		ExpiresAt: now.Add(9 * time.Minute).Unix(),
+		Issuer:    c.Username,
+	}).SignedString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.do(ctx, http.MethodPost, installation+"/access_tokens", "Bearer "+appToken)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("github-app: unexpected status %d", resp.StatusCode)
+	}
+
+	var token githubToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	if token.Token == "" {
+		return nil, fmt.Errorf("github-app: token is missing in the response")
+	}
+
+	rotated := *c
+	rotated.Password = token.Token
+
+	// An expired token is already unusable, so only other failures are reported
+	if c.Password != "" {
+		resp, err := g.do(ctx, http.MethodDelete, apiURL+"/installation/token", "token "+c.Password)
+		if err != nil {
+			return &rotated, &RevokeError{Err: err}
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusUnauthorized {
+			return &rotated, &RevokeError{Err: fmt.Errorf("github-app: old token isn't revoked, unexpected status %d", resp.StatusCode)}
+		}
+	}
+	return &rotated, nil
+}
+
+func (g *GitHubApp) do(ctx context.Context, method, url, authorization string) (*http.Response, error) {
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Accept", "application/vnd.github.v3+json")
+	req.Header.Set("Authorization", authorization)
+	return client(g.Client).Do(req)
+}
@@ -0,0 +1,55 @@
+package rotation
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+
+	"github.com/lib/pq"
+)
+
+const postgresPasswordLength = 32
+
+// Postgres changes the password of a PostgreSQL user. The url of the login is the
+// connection url without the user, e.g. postgres://db.example.com:5432/app?sslmode=require,
+// the username and password are the user to rotate. The user changes its own password,
+// so no superuser is needed.
+type Postgres struct {
+	Open func(dsn string) (*sql.DB, error) // sql.Open with the postgres driver if nil
+}
+
+// Rotate sets a new random password of the user
+func (p *Postgres) Rotate(ctx context.Context, c *Credential) (*Credential, error) {
+	u, err := url.Parse(c.URL)
+	if err != nil || (u.Scheme != "postgres" && u.Scheme != "postgresql") {
+		return nil, fmt.Errorf("postgres: url is not a postgres connection url")
+	}
+	u.User = url.UserPassword(c.Username, c.Password)
+
+	open := p.Open
+	if open == nil {
+		open = func(dsn string) (*sql.DB, error) { return sql.Open("postgres", dsn) }
+	}
+
+	db, err := open(u.String())
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	password, err := randomPassword(postgresPasswordLength)
+	if err != nil {
+		return nil, err
+	}
+
+	// ALTER ROLE has no parameters, the password only has letters and digits
+	query := fmt.Sprintf("ALTER ROLE %s WITH PASSWORD '%s'", pq.QuoteIdentifier(c.Username), password)
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		return nil, fmt.Errorf("postgres: %w", err)
+	}
+
+	rotated := *c
+	rotated.Password = password
+	return &rotated, nil
+}
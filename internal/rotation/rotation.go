@@ -0,0 +1,116 @@
+// Package rotation replaces stored credentials at the services they belong to.
+// Providers are registered by name and a login refers to its provider by that name.
+package rotation
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/tracing"
+)
+
+// ErrUnknownProvider is returned for a provider name which is not registered
+var ErrUnknownProvider = errors.New("unknown rotation provider")
+
+var (
+	providers = struct {
+		sync.RWMutex
+		m map[string]Provider
+	}{m: map[string]Provider{}}
+
+	httpClient = &http.Client{Transport: tracing.Transport(nil), Timeout: 30 * time.Second}
+
+	passwordChars = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+)
+
+// Credential is the decrypted credential of a login
+type Credential struct {
+	URL      string
+	Username string
+	Password string
+	Extra    string
+}
+
+// Provider replaces the credential at the service it belongs to and returns
+// the new credential. The old credential shouldn't work after a rotation, when it
+// can't be revoked the new credential is returned with a *RevokeError.
+type Provider interface {
+	Rotate(ctx context.Context, c *Credential) (*Credential, error)
+}
+
+// RevokeError is returned with the new credential when the old one isn't revoked.
+// The new credential works, so it has to be saved anyway.
+type RevokeError struct {
+	Err error
+}
+
+func (e *RevokeError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *RevokeError) Unwrap() error {
+	return e.Err
+}
+
+func init() {
+	Register("aws-iam", &AWSIAM{})
+	Register("github-app", &GitHubApp{})
+	Register("postgres", &Postgres{})
+}
+
+// Register adds the provider with the name, it replaces a provider with the same name
+func Register(name string, p Provider) {
+	providers.Lock()
+	defer providers.Unlock()
+	providers.m[name] = p
+}
+
+// Find returns the provider registered with the name
+func Find(name string) (Provider, error) {
+	providers.RLock()
+	defer providers.RUnlock()
+	p, ok := providers.m[name]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	return p, nil
+}
+
+// Names returns the names of the registered providers in alphabetical order
+func Names() []string {
+	providers.RLock()
+	defer providers.RUnlock()
+	names := make([]string, 0, len(providers.m))
+	for name := range providers.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// randomPassword returns a password which is safe to use in SQL literals and urls
+func randomPassword(length int) (string, error) {
+	b := make([]byte, length)
+	max := big.NewInt(int64(len(passwordChars)))
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		b[i] = passwordChars[n.Int64()]
+	}
+	return string(b), nil
+}
+
+func client(c *http.Client) *http.Client {
+	if c == nil {
+		return httpClient
+	}
+	return c
+}
@@ -0,0 +1,36 @@
+package router
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/urfave/negroni"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AccessLog logs each request with its status and duration. Query strings are left
+// out, they can hold tokens.
+func AccessLog(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	start := time.Now()
+	rw := negroni.NewResponseWriter(w)
+	next(rw, r)
+
+	fields := log.Fields{
+		"method":      r.Method,
+		"path":        r.URL.Path,
+		"status":      rw.Status(),
+		"duration_ms": float64(time.Since(start)) / float64(time.Millisecond),
+		"bytes":       rw.Size(),
+	}
+	if ip := app.ClientIP(r); ip != nil {
+		fields["ip"] = ip.String()
+	}
+	entry := log.WithFields(fields)
+	if rw.Status() >= http.StatusInternalServerError {
+		entry.Error("request failed")
+		return
+	}
+	entry.Info("request")
+}
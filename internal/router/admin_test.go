@@ -0,0 +1,280 @@
+package router_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/model"
+	"github.com/passwall/passwall-server/pkg/client"
+	"github.com/passwall/passwall-server/pkg/servertest"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetentionPurge(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	hooks := log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	defer log.StandardLogger().ReplaceHooks(hooks)
+	log.AddHook(app.NewAuditHook(srv.Store))
+
+	_, err := c.RetentionReport()
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	user, err := srv.Store.Users().FindByEmail("test@passwall.io")
+	assert.NoError(t, err)
+	user.Role = "Admin"
+	_, err = srv.Store.Users().Save(user)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+
+	_, err = c.UpdateServerPolicy(&model.PolicyDTO{TrashRetention: "1m", AuditRetention: "1m"})
+	assert.NoError(t, err)
+
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", Username: "octocat", Password: "secret"})
+	assert.NoError(t, err)
+	assert.NoError(t, c.DeleteLogin(login.ID))
+	log.WithField("event", "test").Warn("audit entry before the purge")
+
+	// Nothing is older than the retention periods yet
+	report, err := c.RetentionReport()
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, 0, report.Trash.Rows)
+	assert.Equal(t, "1m", report.Trash.Retention)
+	assert.Nil(t, report.Sessions.Before)
+
+	later := time.Now().Add(2 * time.Minute)
+	report, err = app.PurgeExpiredData(srv.Store, later, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Trash.Rows)
+	assert.Equal(t, 1, report.AuditLogs.Rows)
+
+	report, err = app.PurgeExpiredData(srv.Store, later, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Trash.Rows)
+	assert.Equal(t, 1, report.AuditLogs.Rows)
+
+	report, err = app.PurgeExpiredData(srv.Store, later, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, report.Trash.Rows)
+
+	// The purge is audited and the chain goes on from the anchor of the purged entries
+	verified, err := c.VerifyAuditLog()
+	assert.NoError(t, err)
+	assert.True(t, verified.Valid, verified.Problems)
+	assert.Equal(t, 1, verified.Entries)
+	assert.NotZero(t, verified.PurgedUntil)
+}
+
+func TestReencryption(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+	defer viper.Set("server.passphrase", servertest.Passphrase)
+	defer viper.Set("server.previousPassphrase", "")
+	defer viper.Set("server.cipher", "")
+
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", Username: "octocat", Password: "first"})
+	assert.NoError(t, err)
+	_, err = c.UpdateLogin(login.ID, &model.LoginDTO{Title: "GitHub", Username: "octocat", Password: "second"})
+	assert.NoError(t, err)
+	trashed, err := c.CreateLogin(&model.LoginDTO{Title: "Old", Password: "old"})
+	assert.NoError(t, err)
+	assert.NoError(t, c.DeleteLogin(trashed.ID))
+	enrollment, err := c.EnrollTOTP()
+	assert.NoError(t, err)
+
+	_, err = c.ReencryptionStatus()
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	user, err := srv.Store.Users().FindByEmail("test@passwall.io")
+	assert.NoError(t, err)
+	user.Role = "Admin"
+	_, err = srv.Store.Users().Save(user)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+
+	// Rows of the old key stay readable while the rotation runs
+	viper.Set("server.previousPassphrase", servertest.Passphrase)
+	viper.Set("server.passphrase", "new-passphrase-of-the-vaults")
+	viper.Set("server.cipher", app.CipherV2)
+	got, err := c.GetLogin(login.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", got.Password)
+
+	job, err := c.StartReencryption(model.ReencryptKeyRotation)
+	assert.NoError(t, err)
+	assert.Equal(t, model.ReencryptionQueued, job.Status)
+
+	ran, err := app.RunNextReencryption(srv.Store, 1, 0)
+	assert.NoError(t, err)
+	assert.True(t, ran)
+
+	status, err := c.ReencryptionStatus()
+	assert.NoError(t, err)
+	assert.False(t, status.Running)
+	assert.Equal(t, app.CipherV2, status.Cipher)
+	if assert.Len(t, status.Jobs, 1) {
+		assert.Equal(t, model.ReencryptionDone, status.Jobs[0].Status)
+		assert.Equal(t, 100, status.Jobs[0].Progress)
+		assert.Equal(t, 5, status.Jobs[0].Total) // the user, two logins, a password history and a version
+		assert.Equal(t, 5, status.Jobs[0].Reencrypted)
+	}
+
+	// Nothing needs the previous passphrase anymore
+	viper.Set("server.previousPassphrase", "")
+	got, err = c.GetLogin(login.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", got.Password)
+	// The blind indexes are of the new passphrase
+	logins, err := c.ListLogins(&client.ListOptions{Search: "octocat"})
+	assert.NoError(t, err)
+	assert.Len(t, logins, 1)
+	history, err := c.LoginPasswordHistory(login.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, "first", history[0].Password)
+	}
+	// The store decrypts the logins, the batches of the job read the stored values
+	rows := []model.Login{}
+	assert.NoError(t, srv.Store.Reencryption().FindBatch(user.Schema+".logins", login.ID-1, 1, &rows))
+	if assert.Len(t, rows, 1) {
+		assert.True(t, strings.HasPrefix(rows[0].Password, "v2:"))
+	}
+	code, err := app.TOTPCode(enrollment.Secret, time.Now())
+	assert.NoError(t, err)
+	assert.NoError(t, c.EnableTOTP(code))
+
+	// A master password change re-encrypts the vault of the user, there is nothing left to do
+	user, err = srv.Store.Users().FindByEmail("test@passwall.io")
+	assert.NoError(t, err)
+	_, err = app.ResetMasterPassword(srv.Store, user, "new-master-password")
+	assert.NoError(t, err)
+	ran, err = app.RunNextReencryption(srv.Store, 100, 0)
+	assert.NoError(t, err)
+	assert.True(t, ran)
+	status, err = app.ReencryptionStatus(srv.Store, time.Now())
+	assert.NoError(t, err)
+	if assert.Len(t, status.Jobs, 2) {
+		assert.Equal(t, model.ReencryptMasterPassword, status.Jobs[0].Reason)
+		assert.Equal(t, model.ReencryptionDone, status.Jobs[0].Status)
+		assert.Equal(t, 0, status.Jobs[0].Reencrypted)
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	_, err := c.ReloadConfig()
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yml")
+	assert.NoError(t, ioutil.WriteFile(path, []byte("budget:\n  search: 5/1m\n"), 0600))
+	// viper can't forget a config file, later tests start from a clean configuration
+	viper.SetConfigFile(path)
+	defer viper.Reset()
+
+	user, _ := srv.Store.Users().FindByEmail("test@passwall.io")
+	user.Role = "Admin"
+	srv.Store.Users().Save(user)
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+	reload, err := c.ReloadConfig()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"budget.search"}, reload.Changed)
+	assert.Equal(t, "5/1m", viper.GetString("budget.search"))
+
+	reload, err = c.ReloadConfig()
+	assert.NoError(t, err)
+	assert.Empty(t, reload.Changed)
+}
+
+func TestDatabasePools(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	_, err := c.DatabasePools()
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	user, _ := srv.Store.Users().FindByEmail("test@passwall.io")
+	user.Role = "Admin"
+	srv.Store.Users().Save(user)
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+	pools, err := c.DatabasePools()
+	assert.NoError(t, err)
+	if assert.Len(t, pools, 1) {
+		assert.Equal(t, "primary", pools[0].Name)
+		assert.Equal(t, 1, pools[0].MaxOpen)
+		assert.Equal(t, 1, pools[0].Open)
+	}
+}
+
+func TestMigrationStatus(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	_, err := c.MigrationStatus()
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	user, _ := srv.Store.Users().FindByEmail("test@passwall.io")
+	user.Role = "Admin"
+	srv.Store.Users().Save(user)
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+	statuses, err := c.MigrationStatus()
+	assert.NoError(t, err)
+	if assert.Len(t, statuses, 2) {
+		assert.Equal(t, app.SystemSchema, statuses[0].Schema)
+		assert.Equal(t, user.Schema, statuses[1].Schema)
+		assert.Equal(t, statuses[1].Latest, statuses[1].Version)
+		assert.Empty(t, statuses[1].Pending)
+	}
+}
+
+func TestRotateServerKey(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+	defer viper.Set("server.passphrase", servertest.Passphrase)
+	defer viper.Set("server.previousPassphrase", "")
+
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", Password: "secret"})
+	assert.NoError(t, err)
+	user, _ := srv.Store.Users().FindByEmail("test@passwall.io")
+	user.Role = "Admin"
+	srv.Store.Users().Save(user)
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+
+	_, err = c.RotateServerKey("short")
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+	job, err := c.RotateServerKey("rotated-passphrase-of-the-vaults")
+	assert.NoError(t, err)
+	assert.Equal(t, model.ReencryptionQueued, job.Status)
+
+	// A second rotation and the finish wait for the job
+	_, err = c.RotateServerKey("another-passphrase-of-the-vaults")
+	assert.Equal(t, http.StatusConflict, err.(*client.Error).StatusCode)
+	assert.Equal(t, http.StatusConflict, c.FinishKeyRotation().(*client.Error).StatusCode)
+
+	ran, err := app.RunNextReencryption(srv.Store, 1, 0)
+	assert.NoError(t, err)
+	assert.True(t, ran)
+	assert.NoError(t, c.FinishKeyRotation())
+	assert.Equal(t, "", viper.GetString("server.previousPassphrase"))
+
+	// The vault is readable with the new passphrase only
+	got, err := c.GetLogin(login.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", got.Password)
+}
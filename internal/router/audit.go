@@ -0,0 +1,73 @@
+package router
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	"github.com/urfave/negroni"
+)
+
+// auditedPath matches the item endpoints, e.g. /api/logins/3/rotate
+var auditedPath = regexp.MustCompile(`^/api/(logins|credit-cards|bank-accounts|notes|emails|servers)(?:/([0-9]+))?(?:/(clone|rotate|restore|purge|password-history|history|versions(?:/[0-9]+/restore)?|attachments(?:/[0-9]+)?|autofill|order|batch))?/?$`)
+
+// Audit records the requests to the items of the vault in its audit log once they are
+// answered. Creates set the id of the new item with app.AuditItem. Successful changes
+// are pushed to the connected sessions of the vault.
+func Audit(s storage.Store) negroni.HandlerFunc {
+	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		match := auditedPath.FindStringSubmatch(r.URL.Path)
+		action := auditAction(r.Method, match)
+		if action == "" {
+			next(w, r)
+			return
+		}
+
+		event := app.NewAuditEvent(r, app.AuditActor(r.Context()), action, match[1])
+		if id, err := strconv.ParseUint(match[2], 10, 64); err == nil {
+			event.ItemID = uint(id)
+		}
+
+		rw := negroni.NewResponseWriter(w)
+		next(rw, app.WithAuditEvent(r, event))
+
+		event.Result = app.AuditSuccess
+		if rw.Status() >= http.StatusBadRequest {
+			event.Result = app.AuditFailure
+		}
+		schema := r.Context().Value("schema").(string)
+		app.RecordAuditEvent(s, event, schema)
+
+		if action != app.AuditRead && event.Result == app.AuditSuccess {
+			app.PublishChange(schema, model.ChangeDTO{Type: match[1], ID: event.ItemID, Operation: action})
+		}
+	})
+}
+
+// auditAction returns the action of an item request, empty for other requests
+func auditAction(method string, match []string) string {
+	if match == nil {
+		return ""
+	}
+	switch method {
+	case http.MethodGet:
+		return app.AuditRead
+	case http.MethodPost:
+		if match[3] == "rotate" || strings.HasSuffix(match[3], "restore") || strings.HasPrefix(match[3], "attachments") {
+			return app.AuditUpdate
+		}
+		return app.AuditCreate
+	case http.MethodPut, http.MethodPatch:
+		return app.AuditUpdate
+	case http.MethodDelete:
+		if strings.HasPrefix(match[3], "attachments") {
+			return app.AuditUpdate
+		}
+		return app.AuditDelete
+	}
+	return ""
+}
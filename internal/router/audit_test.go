@@ -0,0 +1,157 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/model"
+	"github.com/passwall/passwall-server/pkg/client"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuditLogVerify(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	hooks := log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	defer log.StandardLogger().ReplaceHooks(hooks)
+	log.AddHook(app.NewAuditHook(srv.Store))
+
+	_, err := c.VerifyAuditLog()
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	user, err := srv.Store.Users().FindByEmail("test@passwall.io")
+	assert.NoError(t, err)
+	user.Role = "Admin"
+	_, err = srv.Store.Users().Save(user)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+
+	_, err = c.ExemptFromSecurityKey(user.ID, &model.SecurityKeyExemptionDTO{Period: "1d", Reason: "lost key"})
+	assert.NoError(t, err)
+	_, err = app.CreateAuditCheckpoint(srv.Store)
+	assert.NoError(t, err)
+
+	report, err := c.VerifyAuditLog()
+	assert.NoError(t, err)
+	assert.True(t, report.Valid)
+	assert.Equal(t, 1, report.Entries)
+	assert.Equal(t, 1, report.Checkpoints)
+}
+
+func TestAuditEvents(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	assert.Error(t, client.New(srv.URL).Signin("test@passwall.io", "wrong-password"))
+	created, err := c.CreateLogin(&model.LoginDTO{Title: "Passwall", Password: "first"})
+	assert.NoError(t, err)
+	_, err = c.GetLogin(created.ID)
+	assert.NoError(t, err)
+	_, err = c.UpdateLogin(created.ID, &model.LoginDTO{Title: "Passwall", Password: "second"})
+	assert.NoError(t, err)
+	assert.NoError(t, c.DeleteLogin(created.ID))
+	_, err = c.GetLogin(created.ID)
+	assert.Error(t, err)
+
+	events, err := c.ListAuditEvents(nil)
+	assert.NoError(t, err)
+	actions := []string{}
+	for _, event := range events {
+		actions = append(actions, event.Action+" "+event.Result)
+	}
+	assert.Equal(t, []string{"read failure", "delete success", "update success", "read success",
+		"create success", "signin failure", "signin success"}, actions)
+	assert.Equal(t, created.ID, events[0].ItemID)
+	assert.Equal(t, client.LoginItem, events[4].ItemType)
+	assert.Equal(t, created.ID, events[4].ItemID)
+	assert.NotEmpty(t, events[4].IP)
+	assert.NotEmpty(t, events[4].UserAgent)
+
+	reads, err := c.ListAuditEvents(&client.AuditLogOptions{Action: "read", Limit: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, reads, 1) {
+		assert.Equal(t, "failure", reads[0].Result)
+	}
+	later, err := c.ListAuditEvents(&client.AuditLogOptions{Since: time.Now().Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.Empty(t, later)
+	_, err = c.ListAuditEvents(&client.AuditLogOptions{Action: "export"})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+
+	// Reads of machine accounts are in the audit log of their user
+	deploy, err := c.CreateLogin(&model.LoginDTO{Title: "Deploy Key", Password: "s3cret"})
+	assert.NoError(t, err)
+	account, err := c.CreateMachineAccount(&model.MachineAccountDTO{Name: "ci", Items: []string{itemPath(client.LoginItem, deploy.ID)[len("/api/"):]}})
+	assert.NoError(t, err)
+	token, err := c.MachineToken(account.ClientID, account.Secret)
+	assert.NoError(t, err)
+	_, err = c.Inject(token.AccessToken, nil)
+	assert.NoError(t, err)
+
+	reads, err = c.ListAuditEvents(&client.AuditLogOptions{Action: "read", Limit: 1})
+	assert.NoError(t, err)
+	if assert.Len(t, reads, 1) {
+		assert.Equal(t, "machine:"+account.ClientID, reads[0].Actor)
+		assert.Equal(t, deploy.ID, reads[0].ItemID)
+	}
+}
+
+func TestAuditLogExport(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	viper.Set("budget.export", "4/1h")
+	defer viper.Set("budget.export", "")
+
+	_, err := c.ExportAuditLog("csv", nil)
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	user, err := srv.Store.Users().FindByEmail("test@passwall.io")
+	assert.NoError(t, err)
+	user.Role = "Admin"
+	_, err = srv.Store.Users().Save(user)
+	assert.NoError(t, err)
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+	created, err := c.CreateLogin(&model.LoginDTO{Title: "Passwall", Password: "first"})
+	assert.NoError(t, err)
+
+	data, err := c.ExportAuditLog("csv", nil)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if assert.Len(t, lines, 4) {
+		assert.Equal(t, "user_id,id,created_at,actor,action,item_type,item_id,ip,user_agent,result", lines[0])
+		assert.True(t, strings.HasPrefix(lines[3], strconv.Itoa(int(user.ID))+",3,"))
+		assert.Contains(t, lines[3], ",create,logins,"+strconv.Itoa(int(created.ID))+",")
+	}
+
+	data, err = c.ExportAuditLog("jsonl", &client.AuditLogOptions{Action: "signin"})
+	assert.NoError(t, err)
+	lines = strings.Split(strings.TrimSpace(string(data)), "\n")
+	if assert.Len(t, lines, 2) {
+		var event struct {
+			UserID uint   `json:"user_id"`
+			Action string `json:"action"`
+			Result string `json:"result"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(lines[1]), &event))
+		assert.Equal(t, user.ID, event.UserID)
+		assert.Equal(t, "signin", event.Action)
+		assert.Equal(t, "success", event.Result)
+	}
+
+	_, err = c.ExportAuditLog("xml", nil)
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+
+	// Archives spend the export budget like the other exports
+	_, err = c.ExportAuditLog("csv", nil)
+	assert.Equal(t, http.StatusTooManyRequests, err.(*client.Error).StatusCode)
+	assert.Equal(t, []string{"BUDGET_EXCEEDED"}, err.(*client.Error).Errors)
+}
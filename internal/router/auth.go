@@ -4,11 +4,13 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/api"
 	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/i18n"
 	"github.com/passwall/passwall-server/internal/storage"
 	"github.com/urfave/negroni"
 )
@@ -26,6 +28,18 @@ func Auth(s storage.Store) negroni.HandlerFunc {
 			tokenstr = strArr[1]
 		}
 
+		// Browsers can't set headers on WebSockets and event streams, they send the token in
+		// the query
+		if tokenstr == "" && isStream(r) {
+			tokenstr = r.URL.Query().Get("access_token")
+		}
+
+		// Personal access tokens act for their user without a session
+		if app.IsAccessToken(tokenstr) {
+			authAccessToken(s, w, r, next, tokenstr)
+			return
+		}
+
 		token, err := app.TokenValid(tokenstr)
 		if err != nil {
 			if token != nil {
@@ -44,28 +58,113 @@ func Auth(s storage.Store) negroni.HandlerFunc {
 		//check from db
 		tokenRow, tokenExist := s.Tokens().Any(uuid)
 
+		// Revoked sessions and the access tokens of refreshed ones aren't in it
 		if !tokenExist {
-			userid, _ := strconv.Atoi(fmt.Sprintf("%.f", claims["user_id"]))
-			s.Tokens().Delete(userid)
 			w.WriteHeader(http.StatusUnauthorized)
 			return
 		}
 
+		// Refresh tokens only get new tokens
+		if tokenRow.Refresh {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// Locked or expired sessions end with all of their tokens
+		session := app.SessionOf(claims)
+		if err := app.CheckSession(s, uint(tokenRow.UserID), tokenRow.LastUsedAt, session.Start); err != nil {
+			// The primary revokes it with the next request it gets
+			if !app.ReadOnly() {
+				app.RevokeSession(s, &tokenRow)
+			}
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		// Conditional access rules apply to every request, not only to sign ins
+		if err := app.CheckAccess(s, uint(tokenRow.UserID), app.NewAccessRequest(r, session)); err != nil {
+			respondAccessDenied(w, err)
+			return
+		}
+		app.TouchSession(s, &tokenRow)
+
 		ctxAuthorized := claims["authorized"].(bool)
 		ctxUserID := claims["user_id"].(float64)
 		ctxSchema := fmt.Sprintf("user%v", claims["user_id"])
+		if session.Duress {
+			ctxSchema = app.DecoySchema(ctxSchema)
+		}
 		ctxTransmissionKey := tokenRow.TransmissionKey
+		ctxExpiresAt, _ := claims["exp"].(float64)
+
+		// The preference of the user wins over Accept-Language
+		if locale, ok := claims["locale"].(string); ok && i18n.Supported(locale) {
+			w.Header().Set(i18n.Header, locale)
+		}
 
 		ctx := r.Context()
 		ctxWithID := context.WithValue(ctx, "id", ctxUserID)
 		ctxWithAuthorized := context.WithValue(ctxWithID, "authorized", ctxAuthorized)
 		ctxWithSchema := context.WithValue(ctxWithAuthorized, "schema", ctxSchema)
 		ctxWithTransmissionKey := context.WithValue(ctxWithSchema, "transmissionKey", ctxTransmissionKey)
+		ctxWithDuress := context.WithValue(ctxWithTransmissionKey, "duress", session.Duress)
+		ctxWithSession := context.WithValue(ctxWithDuress, "session", tokenRow.Family)
+		ctxWithExpiresAt := context.WithValue(ctxWithSession, "expiresAt", time.Unix(int64(ctxExpiresAt), 0))
 
 		// These context variables can be accesable with
 		// ctxAuthorized := r.Context().Value("authorized").(bool)
 		// ctxID := r.Context().Value("id").(float64)
 
-		next(w, r.WithContext(ctxWithTransmissionKey))
+		next(w, r.WithContext(ctxWithExpiresAt))
 	})
 }
+
+// authAccessToken verifies a personal access token. Its requests are never authorized
+// for the admin endpoints and always use the real vault.
+func authAccessToken(s storage.Store, w http.ResponseWriter, r *http.Request, next http.HandlerFunc, tokenstr string) {
+	token, err := app.FindAccessToken(s, tokenstr)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	user, err := s.Users().FindByID(token.UserID)
+	if err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if err := app.CheckAccess(s, user.ID, app.NewAccessRequest(r, &app.Session{})); err != nil {
+		respondAccessDenied(w, err)
+		return
+	}
+
+	if i18n.Supported(user.Locale) {
+		w.Header().Set(i18n.Header, user.Locale)
+	}
+
+	ctx := context.WithValue(r.Context(), "id", float64(user.ID))
+	ctx = context.WithValue(ctx, "authorized", false)
+	ctx = context.WithValue(ctx, "schema", user.Schema)
+	ctx = context.WithValue(ctx, "transmissionKey", token.TransmissionKey)
+	ctx = context.WithValue(ctx, "duress", false)
+	ctx = context.WithValue(ctx, "accessToken", token.ID)
+	if token.ExpiresAt != nil {
+		ctx = context.WithValue(ctx, "expiresAt", *token.ExpiresAt)
+	}
+	next(w, r.WithContext(ctx))
+}
+
+// isStream is true for the handshake of a WebSocket connection and for event streams
+func isStream(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket") ||
+		strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// respondAccessDenied writes the denial code of a conditional access rule
+func respondAccessDenied(w http.ResponseWriter, err error) {
+	if denied, ok := err.(*app.AccessDeniedError); ok {
+		api.RespondWithErrors(w, http.StatusForbidden, denied.Reason, []string{denied.Code})
+		return
+	}
+	w.WriteHeader(http.StatusInternalServerError)
+}
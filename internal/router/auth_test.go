@@ -0,0 +1,826 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/app/webauthn"
+	"github.com/passwall/passwall-server/internal/app/webauthn/webauthntest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/passwall/passwall-server/pkg/client"
+	"github.com/passwall/passwall-server/pkg/servertest"
+	uuid "github.com/satori/go.uuid"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransmissionKeyPerSession(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+	_, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", Password: "secret"})
+	assert.NoError(t, err)
+
+	// Each sign in and refresh gets a fresh key of 32 bytes
+	other := client.New(srv.URL)
+	assert.NoError(t, other.Signin("test@passwall.io", "master-password"))
+	first := c.Session().TransmissionKey
+	assert.Len(t, first, 44)
+	assert.NotEqual(t, first, other.Session().TransmissionKey)
+	assert.NoError(t, c.Refresh())
+	assert.NotEqual(t, first, c.Session().TransmissionKey)
+
+	// The key of another session doesn't read the payloads of this one
+	mixed := client.New(srv.URL, client.WithSession(&model.AuthLoginResponse{AccessToken: c.Session().AccessToken, TransmissionKey: other.Session().TransmissionKey}))
+	_, err = mixed.ListLogins(nil)
+	assert.Error(t, err)
+	_, err = client.New(srv.URL, client.WithSession(&model.AuthLoginResponse{AccessToken: c.Session().AccessToken, TransmissionKey: first})).ListLogins(nil)
+	assert.Error(t, err)
+	logins, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+	assert.Len(t, logins, 1)
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	stolen := c.Session()
+	assert.NoError(t, c.Refresh())
+	assert.NotEqual(t, stolen.RefreshToken, c.Session().RefreshToken)
+
+	_, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+
+	// Refresh tokens don't authorize requests
+	refreshOnly := client.New(srv.URL, client.WithSession(&model.AuthLoginResponse{AccessToken: c.Session().RefreshToken, TransmissionKey: c.Session().TransmissionKey}))
+	_, err = refreshOnly.ListLogins(nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	time.Sleep(time.Second)
+
+	// Using the rotated refresh token again revokes the session of both holders
+	err = client.New(srv.URL, client.WithSession(stolen)).Refresh()
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	_, err = c.ListLogins(nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	time.Sleep(time.Second)
+
+	// Signing out revokes the tokens of the session
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+	session := c.Session()
+	assert.NoError(t, c.Signout())
+	assert.Nil(t, c.Session())
+	_, err = client.New(srv.URL, client.WithSession(session)).ListLogins(nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+
+	// Rotated refresh tokens are kept until they expire
+	user, err := srv.Store.Users().FindByEmail("test@passwall.io")
+	assert.NoError(t, err)
+	srv.Store.Tokens().Save(&model.Token{UserID: int(user.ID), UUID: uuid.NewV4(), Family: "done", Refresh: true, RotatedAt: &time.Time{}, ExpiryTime: time.Now().Add(-time.Minute)})
+	purged, err := srv.Store.Tokens().DeleteSuperseded(time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, purged)
+	purged, err = srv.Store.Tokens().DeleteSuperseded(time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, purged)
+}
+
+func TestSessions(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	// Signing in on another device keeps the first session
+	phone := client.New(srv.URL)
+	assert.NoError(t, phone.Signin("test@passwall.io", "master-password"))
+	_, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+
+	sessions, err := c.ListSessions()
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 2)
+	var other *model.SessionDTO
+	for i := range sessions {
+		assert.Equal(t, "127.0.0.1", sessions[i].IP)
+		assert.Contains(t, sessions[i].UserAgent, "Go-http-client")
+		assert.False(t, sessions[i].StartedAt.IsZero())
+		if !sessions[i].Current {
+			other = &sessions[i]
+		}
+	}
+	if assert.NotNil(t, other) {
+		assert.NoError(t, c.RevokeSession(other.ID))
+	}
+
+	// The revoked device has to sign in again, the other one goes on
+	_, err = phone.ListLogins(nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	sessions, err = c.ListSessions()
+	assert.NoError(t, err)
+	if assert.Len(t, sessions, 1) {
+		assert.True(t, sessions[0].Current)
+	}
+
+	err = c.RevokeSession("unknown")
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+}
+
+func TestAccessToken(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	created, err := c.CreateAccessToken(&model.PersonalAccessTokenDTO{Name: "backup script"})
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Token, "pw_"))
+	assert.Equal(t, created.Token[:10], created.Prefix)
+	assert.NotEmpty(t, created.TransmissionKey)
+	assert.Nil(t, created.ExpiresAt)
+
+	// Only the hash of the token is stored
+	stored, err := srv.Store.AccessTokens().FindByID(created.ID)
+	assert.NoError(t, err)
+	assert.NotContains(t, stored.Hash, created.Token[3:])
+
+	api := client.New(srv.URL, client.WithAccessToken(created.Token, created.TransmissionKey))
+	login, err := api.CreateLogin(&model.LoginDTO{Title: "GitHub", Password: "s3cret"})
+	assert.NoError(t, err)
+	logins, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+	assert.Len(t, logins, 1)
+	assert.Equal(t, login.ID, logins[0].ID)
+
+	// Tokens can't create other tokens, and are listed without the token
+	_, err = api.CreateAccessToken(&model.PersonalAccessTokenDTO{Name: "other"})
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+	tokens, err := c.ListAccessTokens()
+	assert.NoError(t, err)
+	if assert.Len(t, tokens, 1) {
+		assert.Equal(t, "backup script", tokens[0].Name)
+		assert.Empty(t, tokens[0].Token)
+		assert.Empty(t, tokens[0].TransmissionKey)
+		assert.NotNil(t, tokens[0].LastUsedAt)
+	}
+
+	// A new sign in ends the sessions but not the tokens
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+	_, err = api.ListLogins(nil)
+	assert.NoError(t, err)
+
+	// Expired, revoked and unknown tokens are rejected
+	past := time.Now().Add(-time.Minute)
+	_, err = c.CreateAccessToken(&model.PersonalAccessTokenDTO{Name: "expired", ExpiresAt: &past})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+	future := time.Now().Add(time.Hour)
+	expiring, err := c.CreateAccessToken(&model.PersonalAccessTokenDTO{Name: "expiring", ExpiresAt: &future})
+	assert.NoError(t, err)
+	assert.NotNil(t, expiring.ExpiresAt)
+	stored, err = srv.Store.AccessTokens().FindByID(expiring.ID)
+	assert.NoError(t, err)
+	stored.ExpiresAt = &past
+	_, err = srv.Store.AccessTokens().Save(stored)
+	assert.NoError(t, err)
+	_, err = client.New(srv.URL, client.WithAccessToken(expiring.Token, expiring.TransmissionKey)).ListLogins(nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+
+	assert.NoError(t, c.RevokeAccessToken(created.ID))
+	_, err = api.ListLogins(nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	_, err = client.New(srv.URL, client.WithAccessToken("pw_unknown", created.TransmissionKey)).ListLogins(nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	assert.Equal(t, http.StatusNotFound, c.RevokeAccessToken(created.ID).(*client.Error).StatusCode)
+}
+
+func TestSessionIdleTimeout(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	_, err := c.UpdatePolicy(&model.PolicyDTO{SessionIdleTimeout: "1x"})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+
+	_, err = c.UpdateServerPolicy(&model.PolicyDTO{SessionIdleTimeout: "1h"})
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	_, err = c.UpdatePolicy(&model.PolicyDTO{SessionIdleTimeout: "5m"})
+	assert.NoError(t, err)
+
+	policies, err := c.Policies()
+	assert.NoError(t, err)
+	assert.Equal(t, "5m", policies.Effective.SessionIdleTimeout)
+
+	// The session was used just now
+	_, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+
+	user, err := srv.Store.Users().FindByEmail("test@passwall.io")
+	assert.NoError(t, err)
+	srv.Store.Tokens().Touch(int(user.ID), time.Now().Add(-10*time.Minute))
+
+	// Neither the access nor the refresh token opens an idle session
+	_, err = c.ListLogins(nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+}
+
+func TestConditionalAccess(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	viper.Set("server.countryHeader", "CF-IPCountry")
+	defer viper.Set("server.countryHeader", "")
+
+	_, err := c.UpdatePolicy(&model.PolicyDTO{BlockedCountries: []string{"KP"}})
+	assert.NoError(t, err)
+
+	// Requests without the header pass the country rule
+	_, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+
+	blocked := client.New(srv.URL, client.WithHTTPClient(&http.Client{Transport: countryTransport("KP")}))
+	err = blocked.Signin("test@passwall.io", "master-password")
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+	assert.Equal(t, []string{"COUNTRY_BLOCKED"}, err.(*client.Error).Errors)
+}
+
+// countryTransport sets the country header like a CDN in front of the server
+type countryTransport string
+
+func (t countryTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.Header.Set("CF-IPCountry", string(t))
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestRequireSecurityKey(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	// Users have a week to enroll a key
+	_, err := c.UpdatePolicy(&model.PolicyDTO{RequireSecurityKey: true, SecurityKeyGracePeriod: "7d"})
+	assert.NoError(t, err)
+	policies, err := c.Policies()
+	assert.NoError(t, err)
+	assert.True(t, policies.Effective.RequireSecurityKey)
+	assert.True(t, policies.Effective.SecurityKeyDeadline.After(time.Now().Add(6*24*time.Hour)))
+
+	// Without a grace period sessions without a key are denied at once
+	_, err = c.UpdatePolicy(&model.PolicyDTO{RequireSecurityKey: true})
+	assert.NoError(t, err)
+	_, err = c.ListLogins(nil)
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+	assert.Equal(t, []string{"SECURITY_KEY_REQUIRED"}, err.(*client.Error).Errors)
+
+	_, err = c.ExemptFromSecurityKey(1, &model.SecurityKeyExemptionDTO{Period: "1d", Reason: "lost key"})
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	user, err := srv.Store.Users().FindByEmail("test@passwall.io")
+	assert.NoError(t, err)
+	_, err = app.ExemptFromSecurityKey(srv.Store, user.ID, &model.SecurityKeyExemptionDTO{Period: "1d", Reason: "lost key"}, "test")
+	assert.NoError(t, err)
+	_, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+}
+
+func TestDuressPassword(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	alerts := make(chan *app.Alert, 10)
+	defer func(notifiers []app.Notifier) { app.Notifiers = notifiers }(app.Notifiers)
+	app.Notifiers = []app.Notifier{func(alert *app.Alert) error {
+		alerts <- alert
+		return nil
+	}}
+
+	_, err := c.CreateLogin(&model.LoginDTO{Title: "Real"})
+	assert.NoError(t, err)
+
+	err = c.SetDuress("wrong-password", "duress-password")
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+	assert.NoError(t, c.SetDuress("master-password", "duress-password"))
+	enabled, err := c.Duress()
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+
+	// The duress password opens the decoy vault and alerts the admins only
+	decoy := client.New(srv.URL)
+	assert.NoError(t, decoy.Signin("test@passwall.io", "duress-password"))
+	select {
+	case alert := <-alerts:
+		assert.Equal(t, "duress_signin", alert.Event)
+		assert.Empty(t, alert.Email)
+	case <-time.After(time.Second):
+		t.Fatal("no alert")
+	}
+
+	_, err = decoy.CreateLogin(&model.LoginDTO{Title: "Decoy"})
+	assert.NoError(t, err)
+	logins, err := decoy.ListLogins(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "Decoy", logins[0].Title)
+	}
+	enabled, err = decoy.Duress()
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+	err = decoy.RemoveDuress("duress-password")
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+	logins, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "Real", logins[0].Title)
+	}
+
+	assert.NoError(t, c.RemoveDuress("master-password"))
+	err = client.New(srv.URL).Signin("test@passwall.io", "duress-password")
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+}
+
+func TestLocale(t *testing.T) {
+	srv, _ := newTestClient(t)
+	defer srv.Close()
+
+	c := client.New(srv.URL, client.WithLocale("tr-TR,tr;q=0.9,en;q=0.5"))
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+
+	err := c.SetDuress("wrong-password", "duress-password")
+	assert.Equal(t, "ana parola yanlış", err.(*client.Error).Message)
+
+	locale, err := c.Locale()
+	assert.NoError(t, err)
+	assert.Equal(t, "", locale.Preferred)
+	assert.Equal(t, "tr", locale.Current)
+	assert.Contains(t, locale.Supported, "en")
+
+	_, err = c.SetLocale("xx")
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+
+	// The preference of the user wins over Accept-Language from the next token
+	locale, err = c.SetLocale("en")
+	assert.NoError(t, err)
+	assert.Equal(t, "en", locale.Preferred)
+	assert.NoError(t, c.Refresh())
+	err = c.SetDuress("wrong-password", "duress-password")
+	assert.Equal(t, "master password is wrong", err.(*client.Error).Message)
+}
+
+func TestTwoFactor(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	enrollment, err := c.EnrollTOTP()
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(enrollment.URL, "otpauth://totp/Passwall:test@passwall.io?"))
+	assert.Contains(t, enrollment.URL, "secret="+enrollment.Secret)
+
+	// Nothing changes until a code of the new secret is verified
+	status, err := c.TwoFactor()
+	assert.NoError(t, err)
+	assert.False(t, status.Enabled)
+	wrong, _ := app.TOTPCode(enrollment.Secret, time.Now().Add(time.Hour))
+	err = c.EnableTOTP(wrong)
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	code, _ := app.TOTPCode(enrollment.Secret, time.Now())
+	assert.NoError(t, c.EnableTOTP(code))
+	status, err = c.TwoFactor()
+	assert.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, []string{model.TwoFactorTOTP}, status.Methods)
+
+	// The master password alone gets no tokens
+	other := client.New(srv.URL)
+	err = other.Signin("test@passwall.io", "master-password")
+	required, ok := err.(*client.TwoFactorRequiredError)
+	if !assert.True(t, ok) {
+		return
+	}
+	assert.Equal(t, []string{model.TwoFactorTOTP}, required.Methods)
+	assert.Nil(t, other.Session())
+	_, err = other.ListLogins(nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+
+	// The code of the enrollment can't be used again
+	err = other.SigninTOTP(required.Token, code)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	next, _ := app.TOTPCode(enrollment.Secret, time.Now().Add(30*time.Second))
+	assert.NoError(t, other.SigninTOTP(required.Token, next))
+	_, err = other.ListLogins(nil)
+	assert.NoError(t, err)
+
+	err = other.DisableTOTP(wrong)
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	// Admins reset it for users who lost their phone
+	user, err := srv.Store.Users().FindByEmail("test@passwall.io")
+	assert.NoError(t, err)
+	_, err = app.ResetTwoFactor(srv.Store, user, "test")
+	assert.NoError(t, err)
+	assert.NoError(t, client.New(srv.URL).Signin("test@passwall.io", "master-password"))
+}
+
+func TestTwoFactorLockout(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+	viper.Set("server.signinDelay", "0s")
+	defer viper.Set("server.signinDelay", "1s")
+
+	enrollment, err := c.EnrollTOTP()
+	assert.NoError(t, err)
+	code, _ := app.TOTPCode(enrollment.Secret, time.Now())
+	assert.NoError(t, c.EnableTOTP(code))
+	wrong, _ := app.TOTPCode(enrollment.Secret, time.Now().Add(time.Hour))
+	next, _ := app.TOTPCode(enrollment.Secret, time.Now().Add(30*time.Second))
+
+	// Wrong codes end the challenge, even the right code needs the master password again
+	other := client.New(srv.URL)
+	required, ok := other.Signin("test@passwall.io", "master-password").(*client.TwoFactorRequiredError)
+	if !assert.True(t, ok) {
+		return
+	}
+	for i := 0; i < 3; i++ {
+		err = other.SigninTOTP(required.Token, wrong)
+		assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	}
+	err = other.SigninTOTP(required.Token, next)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	assert.Equal(t, app.ErrTwoFactorChallenge.Error(), err.(*client.Error).Message)
+
+	// The master password doesn't clear the failures of the codes, they lock the account
+	required, ok = other.Signin("test@passwall.io", "master-password").(*client.TwoFactorRequiredError)
+	if !assert.True(t, ok) {
+		return
+	}
+	for i := 0; i < 2; i++ {
+		err = other.SigninTOTP(required.Token, wrong)
+		assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	}
+	err = other.SigninTOTP(required.Token, next)
+	assert.Equal(t, http.StatusLocked, err.(*client.Error).StatusCode)
+	assert.Equal(t, []string{"ACCOUNT_LOCKED"}, err.(*client.Error).Errors)
+}
+
+func TestSigninLockout(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+	if _, err := srv.CreateUser("Other", "other@passwall.io", "master-password"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A typo is free, the next failure makes the account wait
+	for i := 0; i < 2; i++ {
+		err := client.New(srv.URL).Signin("other@passwall.io", "wrong-password")
+		assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	}
+	err := client.New(srv.URL).Signin("other@passwall.io", "master-password")
+	assert.Equal(t, http.StatusTooManyRequests, err.(*client.Error).StatusCode)
+	assert.Equal(t, []string{"SIGNIN_THROTTLED"}, err.(*client.Error).Errors)
+	assert.Equal(t, time.Second, err.(*client.Error).RetryAfter)
+
+	// Failures in a row lock it, even the right password is rejected then
+	viper.Set("server.signinDelay", "0s")
+	defer viper.Set("server.signinDelay", "1s")
+	time.Sleep(time.Second)
+	for i := 0; i < 3; i++ {
+		err = client.New(srv.URL).Signin("Other@passwall.io", "wrong-password")
+		assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	}
+	time.Sleep(time.Second)
+	err = client.New(srv.URL).Signin("other@passwall.io", "master-password")
+	assert.Equal(t, http.StatusLocked, err.(*client.Error).StatusCode)
+	assert.Equal(t, []string{"ACCOUNT_LOCKED"}, err.(*client.Error).Errors)
+	assert.True(t, err.(*client.Error).RetryAfter > 14*time.Minute)
+
+	// Other accounts of the address still sign in
+	assert.NoError(t, client.New(srv.URL).Signin("test@passwall.io", "master-password"))
+	_, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+}
+
+func TestReauthenticateThrottle(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	// Wrong master passwords wait like failed sign ins
+	for i := 0; i < 2; i++ {
+		_, err := c.Reauthenticate("wrong-password")
+		assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+	}
+	_, err := c.Reauthenticate("master-password")
+	assert.Equal(t, http.StatusTooManyRequests, err.(*client.Error).StatusCode)
+	assert.Equal(t, time.Second, err.(*client.Error).RetryAfter)
+	err = client.New(srv.URL).Signin("test@passwall.io", "master-password")
+	assert.Equal(t, http.StatusTooManyRequests, err.(*client.Error).StatusCode)
+
+	time.Sleep(time.Second)
+	_, err = c.Reauthenticate("master-password")
+	assert.NoError(t, err)
+}
+
+func TestEmailVerification(t *testing.T) {
+	srv, err := servertest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	viper.Set("server.requireEmailVerification", true)
+	viper.Set("budget.verification", "2/1h")
+	defer viper.Set("server.requireEmailVerification", false)
+	defer viper.Set("budget.verification", "")
+
+	// Verification links are read from the emails
+	var links []string
+	sender := app.MailSender
+	defer func() { app.MailSender = sender }()
+	app.MailSender = func(name, email, subject, body string) {
+		if i := strings.Index(body, "/api/auth/verify?token="); i >= 0 {
+			links = append(links, srv.URL+strings.Fields(body[i:])[0])
+		}
+	}
+	post := func(path string, body interface{}) int {
+		data, _ := json.Marshal(body)
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(string(data)))
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	verify := func(link string) int {
+		resp, err := http.Get(link)
+		if !assert.NoError(t, err) {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, post("/auth/signup", &model.UserSignup{Name: "New", Email: "new@passwall.io", MasterPassword: "master-password"}))
+	if !assert.Len(t, links, 1) {
+		return
+	}
+
+	// Unverified accounts can't sign in
+	err = client.New(srv.URL).Signin("new@passwall.io", "master-password")
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+	assert.Equal(t, []string{app.SigninEmailNotVerified}, err.(*client.Error).Errors)
+
+	// A new link ends the older ones, unknown addresses get the same answer
+	assert.Equal(t, http.StatusOK, post("/api/auth/verify/resend", &model.VerificationResendDTO{Email: "new@passwall.io"}))
+	assert.Equal(t, http.StatusOK, post("/api/auth/verify/resend", &model.VerificationResendDTO{Email: "nobody@passwall.io"}))
+	if !assert.Len(t, links, 2) {
+		return
+	}
+	time.Sleep(time.Second)
+	assert.Equal(t, http.StatusBadRequest, verify(links[0]))
+	assert.Equal(t, http.StatusBadRequest, verify(links[1]+"x"))
+	assert.Equal(t, http.StatusOK, verify(links[1]))
+	assert.Equal(t, http.StatusBadRequest, verify(links[1]))
+
+	time.Sleep(time.Second)
+	assert.NoError(t, client.New(srv.URL).Signin("new@passwall.io", "master-password"))
+
+	// Each address has a budget of verification emails
+	assert.Equal(t, http.StatusOK, post("/api/auth/verify/resend", &model.VerificationResendDTO{Email: "new@passwall.io"}))
+	assert.Equal(t, http.StatusTooManyRequests, post("/api/auth/verify/resend", &model.VerificationResendDTO{Email: "New@passwall.io"}))
+	assert.Len(t, links, 2)
+}
+
+func TestPasswordReset(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+	_, err := c.CreateLogin(&model.LoginDTO{Title: "Passwall", Password: "secret"})
+	assert.NoError(t, err)
+
+	// Reset tokens are read from the emails
+	var tokens []string
+	sender := app.MailSender
+	defer func() { app.MailSender = sender }()
+	app.MailSender = func(name, email, subject, body string) {
+		if i := strings.Index(body, "?token="); i >= 0 {
+			tokens = append(tokens, strings.Fields(body[i+len("?token="):])[0])
+		}
+	}
+	type result struct {
+		Code   int
+		Errors []string
+	}
+	post := func(path string, body interface{}) *result {
+		data, _ := json.Marshal(body)
+		resp, err := http.Post(srv.URL+path, "application/json", strings.NewReader(string(data)))
+		if !assert.NoError(t, err) {
+			return &result{}
+		}
+		defer resp.Body.Close()
+		response := new(result)
+		json.NewDecoder(resp.Body).Decode(response)
+		response.Code = resp.StatusCode
+		return response
+	}
+
+	// Unknown addresses get the same answer
+	assert.Equal(t, http.StatusOK, post("/api/auth/recover", &model.PasswordRecoverDTO{Email: "nobody@passwall.io"}).Code)
+	assert.Equal(t, http.StatusOK, post("/api/auth/recover", &model.PasswordRecoverDTO{Email: "test@passwall.io"}).Code)
+	if !assert.Len(t, tokens, 1) {
+		return
+	}
+
+	// The vault is kept, the sessions end and the link works once
+	assert.Equal(t, http.StatusOK, post("/api/auth/reset", &model.PasswordResetDTO{Token: tokens[0], MasterPassword: "new-password"}).Code)
+	assert.Equal(t, http.StatusBadRequest, post("/api/auth/reset", &model.PasswordResetDTO{Token: tokens[0], MasterPassword: "other-password"}).Code)
+	_, err = c.ListLogins(nil)
+	assert.Error(t, err)
+	time.Sleep(time.Second)
+	assert.Equal(t, http.StatusUnauthorized, client.New(srv.URL).Signin("test@passwall.io", "master-password").(*client.Error).StatusCode)
+	c = client.New(srv.URL)
+	assert.NoError(t, c.Signin("test@passwall.io", "new-password"))
+	logins, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "secret", logins[0].Password)
+	}
+
+	// The wipe policy empties the vault once the request confirms it
+	viper.Set("server.passwordReset", app.PasswordResetWipe)
+	defer viper.Set("server.passwordReset", "")
+	time.Sleep(time.Second)
+	post("/api/auth/recover", &model.PasswordRecoverDTO{Email: "test@passwall.io"})
+	if !assert.Len(t, tokens, 2) {
+		return
+	}
+	response := post("/api/auth/reset", &model.PasswordResetDTO{Token: tokens[1], MasterPassword: "master-password"})
+	assert.Equal(t, http.StatusConflict, response.Code)
+	assert.Equal(t, []string{app.ResetVaultWipeRequired}, response.Errors)
+	assert.Equal(t, http.StatusOK, post("/api/auth/reset", &model.PasswordResetDTO{Token: tokens[1], MasterPassword: "master-password", WipeVault: true}).Code)
+	time.Sleep(time.Second)
+	c = client.New(srv.URL)
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+	logins, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, logins)
+
+	// The reset by email can be turned off
+	viper.Set("server.passwordReset", app.PasswordResetOff)
+	assert.Equal(t, http.StatusForbidden, post("/api/auth/recover", &model.PasswordRecoverDTO{Email: "test@passwall.io"}).Code)
+}
+
+func TestPasswordHashUpgrade(t *testing.T) {
+	srv, _ := newTestClient(t)
+	defer srv.Close()
+
+	// Users of older versions have bcrypt hashes
+	user, err := srv.Store.Users().FindByEmail("test@passwall.io")
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.True(t, strings.HasPrefix(user.MasterPassword, "$argon2id$"))
+	user.MasterPassword = app.NewBcrypt([]byte("master-password"))
+	_, err = srv.Store.Users().Save(user)
+	assert.NoError(t, err)
+
+	// Signing in replaces the hash, the next sign in checks the new one
+	assert.NoError(t, client.New(srv.URL).Signin("test@passwall.io", "master-password"))
+	user, _ = srv.Store.Users().FindByEmail("test@passwall.io")
+	assert.True(t, strings.HasPrefix(user.MasterPassword, "$argon2id$"))
+	assert.NoError(t, client.New(srv.URL).Signin("test@passwall.io", "master-password"))
+}
+
+func TestPrelogin(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	kdf, err := c.Prelogin("nobody@passwall.io")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.PreloginResponse{Kdf: app.KdfPBKDF2, Iterations: 600000}, kdf)
+
+	user, _ := srv.Store.Users().FindByEmail("test@passwall.io")
+	user.KdfType, user.KdfIterations = app.KdfArgon2id, 4
+	srv.Store.Users().Save(user)
+	kdf, err = client.New(srv.URL).Prelogin("test@passwall.io")
+	assert.NoError(t, err)
+	assert.Equal(t, &model.PreloginResponse{Kdf: app.KdfArgon2id, Iterations: 4, Memory: 65536, Parallelism: 4}, kdf)
+}
+
+func TestTrustedDevice(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	enrollment, err := c.EnrollTOTP()
+	assert.NoError(t, err)
+	code, _ := app.TOTPCode(enrollment.Secret, time.Now())
+	assert.NoError(t, c.EnableTOTP(code))
+
+	// The device is trusted once its second factor is verified
+	laptop := client.New(srv.URL, client.WithDevice("laptop-fingerprint", "Laptop", ""))
+	err = laptop.Signin("test@passwall.io", "master-password")
+	required, ok := err.(*client.TwoFactorRequiredError)
+	if !assert.True(t, ok) {
+		return
+	}
+	next, _ := app.TOTPCode(enrollment.Secret, time.Now().Add(30*time.Second))
+	assert.NoError(t, laptop.SigninTOTP(required.Token, next))
+	token := laptop.DeviceToken()
+	assert.NotEmpty(t, token)
+
+	// Its next sign ins skip the second factor, the token doesn't work on another device
+	again := client.New(srv.URL, client.WithDevice("laptop-fingerprint", "Laptop", token))
+	assert.NoError(t, again.Signin("test@passwall.io", "master-password"))
+	err = client.New(srv.URL, client.WithDevice("other-fingerprint", "Laptop", token)).Signin("test@passwall.io", "master-password")
+	_, ok = err.(*client.TwoFactorRequiredError)
+	assert.True(t, ok)
+	time.Sleep(time.Second)
+
+	devices, err := c.ListTrustedDevices()
+	assert.NoError(t, err)
+	if assert.Len(t, devices, 1) {
+		assert.Equal(t, "Laptop", devices[0].Name)
+		assert.NotNil(t, devices[0].LastUsedAt)
+		assert.True(t, devices[0].ExpiresAt.After(time.Now().Add(29*24*time.Hour)))
+		assert.NoError(t, c.RevokeTrustedDevice(devices[0].ID))
+		err = c.RevokeTrustedDevice(devices[0].ID)
+		assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+	}
+
+	// Revoked devices ask for the second factor again
+	err = again.Signin("test@passwall.io", "master-password")
+	_, ok = err.(*client.TwoFactorRequiredError)
+	assert.True(t, ok)
+}
+
+func TestSecurityKey(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+	viper.Set("webauthn.rpID", "localhost")
+	viper.Set("webauthn.origins", srv.URL)
+	defer viper.Set("webauthn.rpID", "")
+	defer viper.Set("webauthn.origins", "")
+
+	raw, err := c.SecurityKeyRegistration()
+	assert.NoError(t, err)
+	creation := new(webauthn.CreationOptions)
+	assert.NoError(t, json.Unmarshal(raw, creation))
+	assert.Equal(t, "localhost", creation.RP.ID)
+
+	key := webauthntest.New(srv.URL)
+	registration, err := key.Register(creation)
+	assert.NoError(t, err)
+	credential, _ := json.Marshal(registration)
+	saved, err := c.RegisterSecurityKey("YubiKey", credential)
+	assert.NoError(t, err)
+	assert.Equal(t, "YubiKey", saved.Name)
+
+	// The challenge of the options is used once
+	_, err = c.RegisterSecurityKey("YubiKey", credential)
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	status, err := c.TwoFactor()
+	assert.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, []string{model.TwoFactorSecurityKey}, status.Methods)
+
+	signin := func() (*client.Client, *client.TwoFactorRequiredError, *webauthn.RequestOptions) {
+		other := client.New(srv.URL)
+		required, ok := other.Signin("test@passwall.io", "master-password").(*client.TwoFactorRequiredError)
+		if !assert.True(t, ok) {
+			t.FailNow()
+		}
+		raw, err := other.SecurityKeySignin(required.Token)
+		assert.NoError(t, err)
+		request := new(webauthn.RequestOptions)
+		assert.NoError(t, json.Unmarshal(raw, request))
+		return other, required, request
+	}
+
+	other, required, request := signin()
+	assert.Equal(t, []string{model.TwoFactorSecurityKey}, required.Methods)
+	assert.Len(t, request.AllowCredentials, 1)
+	assertion, err := key.Assert(request)
+	assert.NoError(t, err)
+	credential, _ = json.Marshal(assertion)
+	assert.NoError(t, other.SigninSecurityKey(required.Token, credential))
+	c = other // the new session ended the previous one
+
+	keys, err := c.SecurityKeys()
+	assert.NoError(t, err)
+	if assert.Len(t, keys, 1) {
+		assert.NotNil(t, keys[0].LastUsedAt)
+	}
+
+	// A signature counter going back means the key was cloned
+	other, required, request = signin()
+	key.SignCount = 0
+	assertion, err = key.Assert(request)
+	assert.NoError(t, err)
+	credential, _ = json.Marshal(assertion)
+	err = other.SigninSecurityKey(required.Token, credential)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	assert.Nil(t, other.Session())
+
+	assert.NoError(t, c.RemoveSecurityKey(saved.ID))
+	keys, err = c.SecurityKeys()
+	assert.NoError(t, err)
+	assert.Empty(t, keys)
+	assert.NoError(t, client.New(srv.URL).Signin("test@passwall.io", "master-password"))
+}
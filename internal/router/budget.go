@@ -0,0 +1,38 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/api"
+	"github.com/passwall/passwall-server/internal/app"
+)
+
+const budgetMessage = "Too many expensive requests, try again later"
+
+// Budget limits the requests of each user to an expensive endpoint class, so one
+// runaway client can't slow down a shared server. Lists spend the search budget
+// only when they search, with Search or the q of /api/search.
+func Budget(class string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if class == app.BudgetSearch && r.FormValue("Search") == "" && r.FormValue("q") == "" {
+			next(w, r)
+			return
+		}
+
+		userID := uint(r.Context().Value("id").(float64))
+		status := app.SpendBudget(userID, class, time.Now())
+		if status.Limit > 0 {
+			w.Header().Set("X-RateLimit-Limit", strconv.Itoa(status.Limit))
+			w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(status.Remaining))
+			w.Header().Set("X-RateLimit-Reset", strconv.Itoa(int(status.Reset.Seconds())))
+		}
+		if !status.Allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(status.RetryAfter.Seconds())))
+			api.RespondWithErrors(w, http.StatusTooManyRequests, budgetMessage, []string{"BUDGET_EXCEEDED"})
+			return
+		}
+		next(w, r)
+	}
+}
@@ -2,17 +2,37 @@ package router
 
 import (
 	"net/http"
+	"strings"
+
+	"github.com/spf13/viper"
 )
 
-// CORS ...
+// CORS allows the origins of server.corsOrigins, * allows all
 func CORS(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
-	w.Header().Set("Access-Control-Allow-Origin", "*")
+	if origin := allowedOrigin(r.Header.Get("Origin")); origin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", origin)
+	}
+	w.Header().Add("Vary", "Origin")
 	w.Header().Set("Access-Control-Allow-Credentials", "true")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE, HEAD")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, If-Match")
+	w.Header().Set("Access-Control-Expose-Headers", "ETag")
+	w.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE, HEAD")
 	if r.Method == "OPTIONS" {
 		w.WriteHeader(204)
 		return
 	}
 	next(w, r)
 }
+
+// allowedOrigin returns the Access-Control-Allow-Origin of the origin, empty if it isn't allowed
+func allowedOrigin(origin string) string {
+	for _, allowed := range strings.Split(viper.GetString("server.corsOrigins"), ",") {
+		switch allowed = strings.TrimSpace(allowed); {
+		case allowed == "*":
+			return "*"
+		case allowed != "" && strings.EqualFold(allowed, origin):
+			return origin
+		}
+	}
+	return ""
+}
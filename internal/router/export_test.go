@@ -0,0 +1,265 @@
+package router_test
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/model"
+	"github.com/passwall/passwall-server/pkg/client"
+	"github.com/passwall/passwall-server/pkg/servertest"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExport(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viper.Set("blob.dir", dir)
+	viper.Set("export.urlExpiry", "15m")
+	viper.Set("export.retention", "1d")
+	assert.NoError(t, app.StartExportWorkers(srv.Store))
+
+	folder, err := c.CreateFolder(&model.FolderDTO{Name: "Work"})
+	assert.NoError(t, err)
+	tag, err := c.CreateTag(&model.TagDTO{Name: "dev"})
+	assert.NoError(t, err)
+	_, err = c.CreateLogin(&model.LoginDTO{Title: "GitHub", Username: "octocat", Password: "secret", FolderID: folder.ID, Tags: []uint{tag.ID}})
+	assert.NoError(t, err)
+
+	_, err = c.StartExport("short")
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+
+	job, err := c.StartExport("export-passphrase")
+	assert.NoError(t, err)
+	assert.Equal(t, model.ExportQueued, job.Status)
+
+	for deadline := time.Now().Add(10 * time.Second); job.Status != model.ExportDone; time.Sleep(50 * time.Millisecond) {
+		if time.Now().After(deadline) || job.Status == model.ExportFailed {
+			t.Fatalf("export is %s: %s", job.Status, job.Error)
+		}
+		job, err = c.Export(job.ID)
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, 100, job.Progress)
+	assert.Equal(t, 1, job.Items)
+
+	sealed, err := c.DownloadExport(job.DownloadURL)
+	assert.NoError(t, err)
+	archive, err := client.OpenExportArchive(sealed, "export-passphrase")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, archive.Version)
+	if assert.Len(t, archive.Folders, 1) {
+		assert.Equal(t, model.FolderDTO{ID: folder.ID, Name: "Work"}, *archive.Folders[0])
+	}
+	if assert.Len(t, archive.Tags, 1) {
+		assert.Equal(t, "dev", archive.Tags[0].Name)
+	}
+	logins := archive.Items[app.LoginItem].([]interface{})
+	if assert.Len(t, logins, 1) {
+		login := logins[0].(map[string]interface{})
+		assert.Equal(t, "secret", login["password"])
+		assert.Equal(t, float64(folder.ID), login["folder_id"])
+		assert.Equal(t, []interface{}{float64(tag.ID)}, login["tags"])
+	}
+
+	_, err = client.OpenExportArchive(sealed, "wrong-passphrase")
+	assert.EqualError(t, err, "passwall: export archive is not valid or the passphrase is wrong")
+
+	_, err = c.DownloadExport(job.DownloadURL + "0")
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+}
+
+func TestBackups(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viper.Set("backup.folder", dir)
+	viper.Set("backup.rotation", 2)
+	defer viper.Set("backup.rotation", 7)
+
+	_, err = c.CreateLogin(&model.LoginDTO{Title: "GitHub", Username: "octocat", Password: "secret"})
+	assert.NoError(t, err)
+
+	_, err = c.Backups()
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+	user, _ := srv.Store.Users().FindByEmail("test@passwall.io")
+	user.Role = "Admin"
+	srv.Store.Users().Save(user)
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+
+	backups, err := c.CreateBackups()
+	assert.NoError(t, err)
+	if !assert.Len(t, backups, 1) {
+		return
+	}
+	assert.Equal(t, user.Schema, backups[0].Schema)
+	assert.Len(t, backups[0].SHA256, 64)
+
+	// Only the last two backups of the vault are kept
+	for i := 1; i <= 3; i++ {
+		_, err = app.BackupVault(srv.Store, user.Schema, time.Now().Add(time.Duration(i)*time.Hour))
+		assert.NoError(t, err)
+	}
+	list, err := c.Backups()
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	sealed, err := c.DownloadBackup(list[0].Name)
+	assert.NoError(t, err)
+	archive, err := client.OpenExportArchive(sealed, servertest.Passphrase)
+	assert.NoError(t, err)
+	assert.Len(t, archive.Items[client.LoginItem], 1)
+
+	// Damaged files don't match their checksum
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, list[1].Name), []byte("damaged"), 0600))
+	_, err = c.DownloadBackup(list[1].Name)
+	assert.Equal(t, http.StatusInternalServerError, err.(*client.Error).StatusCode)
+
+	_, err = c.DownloadBackup("passwall-missing.bak")
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+	_, err = c.DownloadBackup("config.yml")
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+}
+
+func TestRestore(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "backups")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viper.Set("backup.folder", dir)
+
+	folder, err := c.CreateFolder(&model.FolderDTO{Name: "Work"})
+	assert.NoError(t, err)
+	tag, err := c.CreateTag(&model.TagDTO{Name: "dev"})
+	assert.NoError(t, err)
+	_, err = c.CreateLogin(&model.LoginDTO{Title: "GitHub", Username: "octocat", Password: "secret", FolderID: folder.ID, Tags: []uint{tag.ID}})
+	assert.NoError(t, err)
+	user, _ := srv.Store.Users().FindByEmail("test@passwall.io")
+	backup, err := app.BackupVault(srv.Store, user.Schema, time.Now())
+	assert.NoError(t, err)
+	_, err = c.CreateLogin(&model.LoginDTO{Title: "GitLab", Username: "tanuki", Password: "secret"})
+	assert.NoError(t, err)
+
+	_, err = c.Restore(&model.RestoreDTO{})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+	_, err = c.Restore(&model.RestoreDTO{Name: backup.Name, Mode: "replace"})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+
+	// Merging skips the items of the vault and reuses its folders and tags
+	report, err := c.Restore(&model.RestoreDTO{Name: backup.Name})
+	assert.NoError(t, err)
+	assert.Equal(t, app.RestoreMerge, report.Mode)
+	assert.Equal(t, 0, report.Restored.Folders)
+	assert.Equal(t, 0, report.Restored.Tags)
+	assert.Empty(t, report.Restored.Items)
+	if assert.Len(t, report.Skipped, 1) {
+		assert.Equal(t, "GitHub", report.Skipped[0].Title)
+	}
+
+	// Wiping needs a re-authentication, but its dry run doesn't
+	_, err = c.Restore(&model.RestoreDTO{Name: backup.Name, Mode: app.RestoreWipe})
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+	report, err = c.Restore(&model.RestoreDTO{Name: backup.Name, Mode: app.RestoreWipe, DryRun: true})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{client.LoginItem: 2}, report.Deleted.Items)
+	assert.Equal(t, map[string]int{client.LoginItem: 1}, report.Restored.Items)
+	logins, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+	assert.Len(t, logins, 2)
+
+	_, err = c.Reauthenticate("master-password")
+	assert.NoError(t, err)
+	report, err = c.Restore(&model.RestoreDTO{Name: backup.Name, Mode: app.RestoreWipe})
+	assert.NoError(t, err)
+	assert.Equal(t, 1, report.Deleted.Folders)
+	assert.Equal(t, 1, report.Restored.Folders)
+	assert.Equal(t, 1, report.Restored.Tags)
+	logins, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "GitHub", logins[0].Title)
+		folders, _ := c.ListFolders()
+		tags, _ := c.ListTags()
+		if assert.Len(t, folders, 1) && assert.Len(t, tags, 1) {
+			assert.Equal(t, folders[0].ID, logins[0].FolderID)
+			assert.Equal(t, []uint{tags[0].ID}, logins[0].Tags)
+		}
+	}
+
+	// Uploaded export archives need their passphrase
+	sealed, err := app.SealExportArchive([]byte(`{"version":2,"items":{"notes":[{"title":"Recovery codes","note":"1234"}]}}`), "export-passphrase")
+	assert.NoError(t, err)
+	content := base64.StdEncoding.EncodeToString(sealed)
+	_, err = c.Restore(&model.RestoreDTO{Content: content, Passphrase: "wrong-passphrase"})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+	report, err = c.Restore(&model.RestoreDTO{Content: content, Passphrase: "export-passphrase"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{client.NoteItem: 1}, report.Restored.Items)
+
+	// Backups of other vaults can't be restored
+	_, err = c.Restore(&model.RestoreDTO{Name: "passwall-other-" + time.Now().Format("2006-01-02T15-04-05") + ".bak"})
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+}
+
+func TestExportItems(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	_, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", URL: "https://github.com", Username: "octocat", Password: "secret", Extra: "recovery codes"})
+	assert.NoError(t, err)
+
+	_, err = c.ExportItems(client.LoginItem)
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+	_, err = c.Reauthenticate("wrong-password")
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	token, err := c.Reauthenticate("master-password")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, token.Token)
+
+	data, err := c.ExportItems(client.LoginItem)
+	assert.NoError(t, err)
+	assert.Equal(t, "name,url,username,password,note\nGitHub,https://github.com,octocat,secret,recovery codes\n", string(data))
+
+	// The export of logins is the CSV export of browsers
+	report, err := c.ImportBrowser(string(data))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{client.LoginItem: 1}, report.Imported)
+
+	data, err = c.ExportItems(client.NoteItem)
+	assert.NoError(t, err)
+	assert.Equal(t, "title,note\n", string(data))
+
+	// Re-authentications are of their session
+	other := client.New(srv.URL)
+	assert.NoError(t, other.Signin("test@passwall.io", "master-password"))
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/logins/export", nil)
+	req.Header.Set("Authorization", "Bearer "+other.Session().AccessToken)
+	req.Header.Set("X-Reauth-Token", token.Token)
+	resp, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	}
+}
@@ -0,0 +1,325 @@
+package router_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/passwall/passwall-server/model"
+	"github.com/passwall/passwall-server/pkg/client"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestImportBitwarden(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	work, err := c.CreateFolder(&model.FolderDTO{Name: "Work"})
+	assert.NoError(t, err)
+
+	export := `{
+		"encrypted": false,
+		"folders": [{"id": "f1", "name": "Work"}, {"id": "f2", "name": "Bank"}],
+		"items": [
+			{"type": 1, "folderId": "f1", "name": "GitHub", "notes": "2FA on", "favorite": true,
+				"fields": [{"name": "PIN", "value": "1234", "type": 1}],
+				"login": {"uris": [{"uri": "https://github.com"}], "username": "octocat", "password": "secret", "totp": "JBSWY3DP"}},
+			{"type": 2, "name": "Wifi", "notes": "hunter2", "secureNote": {"type": 0}},
+			{"type": 3, "folderId": "f2", "name": "Visa", "card": {"cardholderName": "Octo Cat", "number": "4111111111111111", "expMonth": "1", "expYear": "2030", "code": "123"}},
+			{"type": 3, "name": "Broken", "card": {"number": "4111111111111112"}},
+			{"type": 4, "name": "Passport", "identity": {}}
+		]
+	}`
+	report, err := c.ImportBitwarden(export)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{client.LoginItem: 1, client.NoteItem: 1, client.CreditCardItem: 1}, report.Imported)
+	assert.Equal(t, 1, report.Folders)
+	if assert.Len(t, report.Skipped, 2) {
+		assert.Equal(t, 4, report.Skipped[0].Entry)
+		assert.Equal(t, "Broken", report.Skipped[0].Title)
+		assert.Equal(t, "Passport", report.Skipped[1].Title)
+	}
+
+	// Folders of the vault with the same name are reused
+	logins, err := c.ListLogins(&client.ListOptions{FolderID: &work.ID})
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "https://github.com", logins[0].URL)
+		assert.Equal(t, "octocat", logins[0].Username)
+		assert.Equal(t, "secret", logins[0].Password)
+		assert.Equal(t, "2FA on\nPIN: 1234\nTOTP: JBSWY3DP", logins[0].Extra)
+		assert.True(t, logins[0].IsFavorite)
+	}
+	cards, err := c.ListCreditCards(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, cards, 1) {
+		assert.Equal(t, "01/2030", cards[0].ExpiryDate)
+		assert.NotZero(t, cards[0].FolderID)
+	}
+
+	csv := "folder,favorite,type,name,notes,fields,reprompt,login_uri,login_username,login_password,login_totp\n" +
+		"Work,,login,Jira,,,0,https://jira.example.com,octo,pass,\n" +
+		",,note,Alarm,\"code 0000\nat the door\",,,,,,\n" +
+		",,identity,Me,,,,,,,\n"
+	report, err = c.ImportBitwarden(csv)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{client.LoginItem: 1, client.NoteItem: 1}, report.Imported)
+	assert.Len(t, report.Skipped, 1)
+	notes, err := c.ListNotes(nil)
+	assert.NoError(t, err)
+	assert.Len(t, notes, 2)
+	for _, note := range notes {
+		if note.Title == "Alarm" {
+			assert.Equal(t, "code 0000\nat the door", note.Note)
+		}
+	}
+
+	_, err = c.ImportBitwarden(`{"encrypted": true, "items": []}`)
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+	_, err = c.ImportBitwarden("not an export")
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
+
+func TestImportLastPass(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	export := "url,username,password,totp,extra,name,grouping,fav\n" +
+		"https://github.com,octocat,secret,,recovery codes,GitHub,Work\\Dev,1\n" +
+		"http://sn,,,,\"NoteType:Credit Card\nName on Card:Octo Cat\nType:Visa\nNumber:4111111111111111\nSecurity Code:123\nExpiration Date:January,2030\nNotes:\",Visa,(none),0\n" +
+		"http://sn,,,,\"NoteType:Bank Account\nBank Name:Octo Bank\nAccount Number:12345678\nSWIFT Code:OCTOUS33\nIBAN Number:DE89370400440532013000\nPin:0000\nNotes:\",Checking,Bank,0\n" +
+		"http://sn,,,,door code 0000,Alarm,(none),0\n"
+	report, err := c.ImportLastPass(export)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{client.LoginItem: 1, client.CreditCardItem: 1, client.BankAccountItem: 1, client.NoteItem: 1}, report.Imported)
+	assert.Equal(t, 2, report.Folders)
+	assert.Empty(t, report.Skipped)
+
+	folders, err := c.ListFolders()
+	assert.NoError(t, err)
+	names := []string{}
+	for _, folder := range folders {
+		names = append(names, folder.Name)
+	}
+	assert.ElementsMatch(t, []string{"Work/Dev", "Bank"}, names)
+
+	logins, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "recovery codes", logins[0].Extra)
+		assert.True(t, logins[0].IsFavorite)
+		assert.NotZero(t, logins[0].FolderID)
+	}
+	cards, err := c.ListCreditCards(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, cards, 1) {
+		assert.Equal(t, "Octo Cat", cards[0].CardholderName)
+		assert.Equal(t, "01/2030", cards[0].ExpiryDate)
+		assert.Zero(t, cards[0].FolderID)
+	}
+	accounts, err := c.ListBankAccounts(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, accounts, 1) {
+		assert.Equal(t, "Octo Bank", accounts[0].BankName)
+		assert.Equal(t, "Checking", accounts[0].AccountName)
+		assert.Equal(t, "OCTOUS33", accounts[0].BankCode)
+		assert.Equal(t, "0000", accounts[0].Password)
+	}
+
+	_, err = c.ImportLastPass("name,password\nGitHub,secret\n")
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
+
+func TestImportOnePassword(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	data := `{"accounts": [{"vaults": [
+		{"attrs": {"name": "Personal"}, "items": [
+			{"categoryUuid": "001", "favIndex": 1, "overview": {"title": "GitHub", "url": "https://github.com"}, "details": {
+				"loginFields": [{"value": "octocat", "designation": "username"}, {"value": "secret", "designation": "password"}],
+				"notesPlain": "2FA on",
+				"sections": [{"fields": [{"title": "one-time password", "id": "otp", "value": {"totp": "otpauth://totp/GitHub"}}]}]}},
+			{"categoryUuid": "002", "overview": {"title": "Visa"}, "details": {"sections": [{"fields": [
+				{"title": "cardholder name", "id": "cardholder", "value": {"string": "Octo Cat"}},
+				{"title": "number", "id": "ccnum", "value": {"creditCardNumber": "4111111111111111"}},
+				{"title": "expiry date", "id": "expiry", "value": {"monthYear": 203001}}]}]}},
+			{"categoryUuid": "006", "overview": {"title": "Scan"}, "details": {}}
+		]},
+		{"attrs": {"name": "Work"}, "items": [
+			{"categoryUuid": "110", "overview": {"title": "Build box"}, "details": {"sections": [{"fields": [
+				{"title": "URL", "id": "url", "value": {"string": "10.0.0.2"}},
+				{"title": "username", "id": "username", "value": {"string": "root"}},
+				{"title": "rack", "id": "rack", "value": {"string": "B12"}}]}]}},
+			{"categoryUuid": "112", "overview": {"title": "Stripe"}, "details": {"sections": [{"fields": [
+				{"title": "credential", "id": "credential", "value": {"concealed": "sk_test"}}]}]}}
+		]}
+	]}]}`
+	archive := new(bytes.Buffer)
+	files := zip.NewWriter(archive)
+	file, err := files.Create("export.data")
+	assert.NoError(t, err)
+	file.Write([]byte(data))
+	assert.NoError(t, files.Close())
+
+	report, err := c.ImportOnePassword(archive.Bytes())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{client.LoginItem: 1, client.CreditCardItem: 1, client.ServerItem: 1, client.NoteItem: 1}, report.Imported)
+	assert.Equal(t, 2, report.Folders)
+	if assert.Len(t, report.Skipped, 1) {
+		assert.Equal(t, "Scan", report.Skipped[0].Title)
+	}
+
+	logins, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "octocat", logins[0].Username)
+		assert.Equal(t, "secret", logins[0].Password)
+		assert.Equal(t, "2FA on\none-time password: otpauth://totp/GitHub", logins[0].Extra)
+		assert.True(t, logins[0].IsFavorite)
+	}
+	cards, err := c.ListCreditCards(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, cards, 1) {
+		assert.Equal(t, "01/2030", cards[0].ExpiryDate)
+	}
+	servers, err := c.ListServers(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, servers, 1) {
+		assert.Equal(t, "10.0.0.2", servers[0].URL)
+		assert.Equal(t, "rack: B12", servers[0].Extra)
+	}
+	notes, err := c.ListNotes(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, notes, 1) {
+		assert.Equal(t, "credential: sk_test", notes[0].Note)
+	}
+
+	csv := "Title,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes\n" +
+		"Jira,https://jira.example.com,octo,pass,,false,false,,\n"
+	report, err = c.ImportOnePassword([]byte(csv))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{client.LoginItem: 1}, report.Imported)
+
+	_, err = c.ImportOnePassword([]byte("PK\x03\x04 broken"))
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
+
+func TestImportKeePass(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	export := `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile>
+	<Meta><RecycleBinUUID>Ymlu</RecycleBinUUID></Meta>
+	<Root>
+		<Group>
+			<UUID>cm9vdA==</UUID>
+			<Name>Passwords</Name>
+			<Group>
+				<UUID>ZGV2</UUID>
+				<Name>Dev</Name>
+				<Entry>
+					<String><Key>Title</Key><Value>GitHub</Value></String>
+					<String><Key>UserName</Key><Value>octocat</Value></String>
+					<String><Key>Password</Key><Value ProtectInMemory="True">secret</Value></String>
+					<String><Key>URL</Key><Value>https://github.com</Value></String>
+					<String><Key>Notes</Key><Value>recovery codes</Value></String>
+					<String><Key>PIN</Key><Value>1234</Value></String>
+					<AutoType><DefaultSequence>{USERNAME}{TAB}{PASSWORD}{ENTER}</DefaultSequence></AutoType>
+				</Entry>
+			</Group>
+			<Group>
+				<UUID>Ymlu</UUID>
+				<Name>Recycle Bin</Name>
+				<Entry><String><Key>Title</Key><Value>Old</Value></String></Entry>
+			</Group>
+		</Group>
+	</Root>
+</KeePassFile>`
+	report, err := c.ImportKeePass([]byte(export), "")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{client.LoginItem: 1}, report.Imported)
+	if assert.Len(t, report.Skipped, 1) {
+		assert.Equal(t, "Old", report.Skipped[0].Title)
+	}
+
+	logins, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "octocat", logins[0].Username)
+		assert.Equal(t, "secret", logins[0].Password)
+		assert.Equal(t, "recovery codes\nPIN: 1234", logins[0].Extra)
+		assert.Equal(t, "{USERNAME}{TAB}{PASSWORD}{ENTER}", logins[0].AutoTypeSequence)
+		assert.NotZero(t, logins[0].FolderID)
+	}
+
+	// The signature of KDBX files
+	_, err = c.ImportKeePass([]byte{0x03, 0xD9, 0xA2, 0x9A, 0x67, 0xFB, 0x4B, 0xB5}, "secret")
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
+
+func TestImportBrowser(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	export := "name,url,username,password,note\n" +
+		"github.com,https://github.com/login,octocat,secret,recovery codes\n" +
+		"example.com,https://example.com/,jane,hunter2,\n"
+	report, err := c.ImportBrowser(export)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{client.LoginItem: 2}, report.Imported)
+	assert.Zero(t, report.Folders)
+
+	logins, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+	assert.Len(t, logins, 2)
+	for _, login := range logins {
+		if login.Title == "github.com" {
+			assert.Equal(t, "octocat", login.Username)
+			assert.Equal(t, "recovery codes", login.Extra)
+		}
+	}
+
+	_, err = c.ImportBrowser("name,password\ngithub.com,secret\n")
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
+
+func TestImportCSV(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	export := "Site,Login,Secret,Comment,Kind\n" +
+		"https://github.com,octocat,secret,recovery codes,login\n" +
+		",,,door code 0000,note\n" +
+		",,,4111111111111111,card\n"
+	mapping := model.ImportMappingDTO{URL: "Site", Username: "Login", Password: "Secret", Notes: "Comment", Type: "Kind"}
+
+	report, err := c.ImportCSV(export, mapping, true)
+	assert.NoError(t, err)
+	assert.True(t, report.DryRun)
+	assert.Equal(t, map[string]int{client.LoginItem: 1, client.NoteItem: 1}, report.Imported)
+	assert.Len(t, report.Skipped, 1)
+	if assert.Len(t, report.Items, 2) {
+		assert.Equal(t, client.LoginItem, report.Items[0].Type)
+		assert.Equal(t, "github.com", report.Items[0].Item.(map[string]interface{})["title"])
+	}
+	logins, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, logins)
+
+	report, err = c.ImportCSV(export, mapping, false)
+	assert.NoError(t, err)
+	assert.False(t, report.DryRun)
+	assert.Empty(t, report.Items)
+	logins, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "octocat", logins[0].Username)
+		assert.Equal(t, "recovery codes", logins[0].Extra)
+	}
+
+	_, err = c.ImportCSV(export, model.ImportMappingDTO{URL: "Address"}, true)
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
@@ -0,0 +1,904 @@
+package router_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/blob"
+	"github.com/passwall/passwall-server/model"
+	"github.com/passwall/passwall-server/pkg/client"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogins(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	created, err := c.CreateLogin(&model.LoginDTO{Title: "Passwall", URL: "https://passwall.io", Password: "first"})
+	assert.NoError(t, err)
+
+	_, err = c.UpdateLogin(created.ID, &model.LoginDTO{Title: "Passwall", URL: "https://passwall.io", Password: "second"})
+	assert.NoError(t, err)
+
+	login, err := c.GetLogin(created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", login.Password)
+
+	history, err := c.LoginPasswordHistory(created.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 1) {
+		assert.Equal(t, "first", history[0].Password)
+	}
+	session, err := srv.Signin("test@passwall.io", "master-password")
+	assert.NoError(t, err)
+	code, err := srv.Do(session, http.MethodGet, itemPath(client.LoginItem, created.ID)+"/history", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	logins, err := c.ListLogins(&client.ListOptions{Search: "Pass", Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, logins, 1)
+
+	clone := new(model.LoginDTO)
+	assert.NoError(t, c.CloneItem(client.LoginItem, created.ID, clone))
+	assert.Equal(t, "Passwall (copy)", clone.Title)
+
+	assert.NoError(t, c.DeleteLogin(created.ID))
+	_, err = c.GetLogin(created.ID)
+	apiErr, ok := err.(*client.Error)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusNotFound, apiErr.StatusCode)
+}
+
+func TestTrash(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", Password: "secret"})
+	assert.NoError(t, err)
+	note, err := c.CreateNote(&model.NoteDTO{Title: "Recovery codes", Note: "1234"})
+	assert.NoError(t, err)
+
+	// Items of the vault can't be restored or purged
+	assert.Equal(t, http.StatusNotFound, c.RestoreItem(client.LoginItem, login.ID).(*client.Error).StatusCode)
+	assert.Equal(t, http.StatusNotFound, c.PurgeItem(client.LoginItem, login.ID).(*client.Error).StatusCode)
+
+	assert.NoError(t, c.DeleteLogin(login.ID))
+	assert.NoError(t, c.DeleteNote(note.ID))
+	trash, err := c.Trash()
+	assert.NoError(t, err)
+	if assert.Len(t, trash, 2) {
+		assert.Equal(t, client.NoteItem, trash[0].Type)
+		assert.Equal(t, "Recovery codes", trash[0].Item.(map[string]interface{})["title"])
+		assert.Equal(t, client.LoginItem, trash[1].Type)
+		assert.False(t, trash[1].DeletedAt.IsZero())
+	}
+
+	assert.NoError(t, c.RestoreItem(client.LoginItem, login.ID))
+	restored, err := c.GetLogin(login.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", restored.Password)
+
+	assert.NoError(t, c.PurgeItem(client.NoteItem, note.ID))
+	trash, err = c.Trash()
+	assert.NoError(t, err)
+	assert.Empty(t, trash)
+	assert.Equal(t, http.StatusNotFound, c.RestoreItem(client.NoteItem, note.ID).(*client.Error).StatusCode)
+}
+
+func TestItemVersions(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+	viper.Set("server.itemVersions", 2)
+	defer viper.Set("server.itemVersions", 10)
+
+	note, err := c.CreateNote(&model.NoteDTO{Title: "Wifi", Note: "first"})
+	assert.NoError(t, err)
+	for _, text := range []string{"second", "third", "fourth"} {
+		_, err = c.UpdateNote(note.ID, &model.NoteDTO{Title: "Wifi", Note: text})
+		assert.NoError(t, err)
+	}
+
+	// The oldest version is dropped beyond server.itemVersions
+	versions, err := c.ItemVersions(client.NoteItem, note.ID)
+	assert.NoError(t, err)
+	if !assert.Len(t, versions, 2) {
+		return
+	}
+	assert.Equal(t, "third", versions[0].Item.(map[string]interface{})["note"])
+	assert.Equal(t, "second", versions[1].Item.(map[string]interface{})["note"])
+
+	restored := new(model.NoteDTO)
+	assert.NoError(t, c.RestoreItemVersion(client.NoteItem, note.ID, versions[1].ID, restored))
+	assert.Equal(t, note.ID, restored.ID)
+	assert.Equal(t, "second", restored.Note)
+	got, err := c.GetNote(note.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "second", got.Note)
+
+	// The restore can be undone, versions of other items aren't restored
+	versions, err = c.ItemVersions(client.NoteItem, note.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "fourth", versions[0].Item.(map[string]interface{})["note"])
+	other, err := c.CreateNote(&model.NoteDTO{Title: "Other"})
+	assert.NoError(t, err)
+	err = c.RestoreItemVersion(client.NoteItem, other.ID, versions[0].ID, nil)
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+
+	// Restoring a login keeps its password in the history
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", Password: "old"})
+	assert.NoError(t, err)
+	_, err = c.UpdateLogin(login.ID, &model.LoginDTO{Title: "GitHub", Password: "new"})
+	assert.NoError(t, err)
+	versions, err = c.ItemVersions(client.LoginItem, login.ID)
+	assert.NoError(t, err)
+	if !assert.Len(t, versions, 1) {
+		return
+	}
+	assert.NoError(t, c.RestoreItemVersion(client.LoginItem, login.ID, versions[0].ID, nil))
+	history, err := c.LoginPasswordHistory(login.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, history, 2) {
+		assert.Equal(t, "new", history[0].Password)
+	}
+}
+
+func TestCloneItemToFolder(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	work, err := c.CreateFolder(&model.FolderDTO{Name: "Work"})
+	assert.NoError(t, err)
+	personal, err := c.CreateFolder(&model.FolderDTO{Name: "Personal"})
+	assert.NoError(t, err)
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "Jira", FolderID: work.ID})
+	assert.NoError(t, err)
+
+	// Without a folder the copy stays next to the item
+	clone := new(model.LoginDTO)
+	assert.NoError(t, c.CloneItem(client.LoginItem, login.ID, clone))
+	assert.Equal(t, work.ID, clone.FolderID)
+
+	assert.NoError(t, c.CloneItemToFolder(client.LoginItem, login.ID, personal.ID, clone))
+	assert.Equal(t, "Jira (copy)", clone.Title)
+	assert.Equal(t, personal.ID, clone.FolderID)
+	assert.NoError(t, c.CloneItemToFolder(client.LoginItem, login.ID, 0, clone))
+	assert.Equal(t, uint(0), clone.FolderID)
+
+	// Folders of other vaults aren't found in this one
+	other, err := srv.CreateUser("Other", "other@passwall.io", "master-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var foreign *model.Folder
+	for i := 0; i < 3; i++ {
+		foreign, err = srv.Store.Folders().Save(&model.Folder{Name: "Foreign"}, other.Schema)
+		assert.NoError(t, err)
+	}
+	err = c.CloneItemToFolder(client.LoginItem, login.ID, foreign.ID, clone)
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+	logins, err := c.ListLogins(nil)
+	assert.NoError(t, err)
+	assert.Len(t, logins, 4)
+}
+
+func TestFolders(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	_, err := c.CreateFolder(&model.FolderDTO{})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+	work, err := c.CreateFolder(&model.FolderDTO{Name: "Work"})
+	assert.NoError(t, err)
+	assert.Equal(t, "Work", work.Name)
+	work, err = c.UpdateFolder(work.ID, &model.FolderDTO{Name: "Office"})
+	assert.NoError(t, err)
+	folders, err := c.ListFolders()
+	assert.NoError(t, err)
+	if assert.Len(t, folders, 1) {
+		assert.Equal(t, "Office", folders[0].Name)
+	}
+
+	filed, err := c.CreateLogin(&model.LoginDTO{Title: "Jira", FolderID: work.ID})
+	assert.NoError(t, err)
+	assert.Equal(t, work.ID, filed.FolderID)
+	_, err = c.CreateLogin(&model.LoginDTO{Title: "GitHub"})
+	assert.NoError(t, err)
+	_, err = c.CreateNote(&model.NoteDTO{Title: "VPN", FolderID: work.ID})
+	assert.NoError(t, err)
+
+	logins, err := c.ListLogins(&client.ListOptions{FolderID: &work.ID})
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "Jira", logins[0].Title)
+	}
+	unfiled := uint(0)
+	logins, err = c.ListLogins(&client.ListOptions{FolderID: &unfiled})
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "GitHub", logins[0].Title)
+	}
+	notes, err := c.ListNotes(&client.ListOptions{FolderID: &work.ID})
+	assert.NoError(t, err)
+	assert.Len(t, notes, 1)
+
+	// The items of a deleted folder stay without a folder
+	assert.NoError(t, c.DeleteFolder(work.ID))
+	_, err = c.GetFolder(work.ID)
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+	logins, err = c.ListLogins(&client.ListOptions{FolderID: &unfiled})
+	assert.NoError(t, err)
+	assert.Len(t, logins, 2)
+}
+
+func TestPatchItem(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	note, err := c.CreateNote(&model.NoteDTO{Title: "VPN", Note: "vpn.example.com", IsFavorite: true})
+	assert.NoError(t, err)
+
+	patched := new(model.NoteDTO)
+	err = c.PatchItem(client.NoteItem, note.ID, map[string]interface{}{"title": "Office VPN"}, patched)
+	assert.NoError(t, err)
+	assert.Equal(t, "Office VPN", patched.Title)
+	assert.Equal(t, "vpn.example.com", patched.Note)
+	assert.True(t, patched.IsFavorite)
+
+	note, err = c.GetNote(note.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Office VPN", note.Title)
+	assert.Equal(t, "vpn.example.com", note.Note)
+	versions, err := c.ItemVersions(client.NoteItem, note.ID)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", Password: "secret"})
+	assert.NoError(t, err)
+	err = c.PatchItem(client.LoginItem, login.ID, map[string]interface{}{"rotation_period": "30d"}, nil)
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+	err = c.PatchItem(client.LoginItem, 1000, map[string]interface{}{"title": "GitLab"}, nil)
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+}
+
+func TestETag(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	note, err := c.CreateNote(&model.NoteDTO{Title: "VPN"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(1), note.Revision)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+itemPath(client.NoteItem, note.ID), nil)
+	req.Header.Set("Authorization", "Bearer "+c.Session().AccessToken)
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, `"1"`, resp.Header.Get("ETag"))
+
+	// The first device updates the revision it read, the second one still has it
+	updated := new(model.NoteDTO)
+	err = c.PatchItem(client.NoteItem, note.ID, client.IfMatch(note.Revision, map[string]string{"title": "Office VPN"}), updated)
+	assert.NoError(t, err)
+	assert.Equal(t, uint(2), updated.Revision)
+	err = c.PatchItem(client.NoteItem, note.ID, client.IfMatch(note.Revision, map[string]string{"title": "Home VPN"}), nil)
+	assert.Equal(t, http.StatusPreconditionFailed, err.(*client.Error).StatusCode)
+	data, err := app.EncryptJSON(c.Session().TransmissionKey, &model.NoteDTO{Title: "Home VPN"})
+	assert.NoError(t, err)
+	body, _ := json.Marshal(model.Payload{Data: string(data)})
+	req, _ = http.NewRequest(http.MethodPut, srv.URL+itemPath(client.NoteItem, note.ID), bytes.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+c.Session().AccessToken)
+	req.Header.Set("If-Match", `"1"`)
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusPreconditionFailed, resp.StatusCode)
+
+	note, err = c.GetNote(note.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Office VPN", note.Title)
+
+	// Updates without If-Match aren't checked
+	note, err = c.UpdateNote(note.ID, &model.NoteDTO{Title: "Home VPN"})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), note.Revision)
+}
+
+func TestConflict(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", Username: "octocat"})
+	assert.NoError(t, err)
+
+	// Both devices edit the revision they synced, the second update conflicts
+	office := *login
+	office.Title = "GitHub Enterprise"
+	_, err = c.UpdateLogin(login.ID, &office)
+	assert.NoError(t, err)
+
+	home := *login
+	home.Username = "monalisa"
+	_, err = c.UpdateLogin(login.ID, &home)
+	apiErr := err.(*client.Error)
+	assert.Equal(t, http.StatusConflict, apiErr.StatusCode)
+	if assert.NotNil(t, apiErr.Conflict) {
+		conflict := apiErr.Conflict
+		assert.Equal(t, client.LoginItem, conflict.Type)
+		assert.Equal(t, login.ID, conflict.ID)
+		assert.Equal(t, uint(1), conflict.BaseRevision)
+		assert.Equal(t, "GitHub", conflict.Base.(map[string]interface{})["title"])
+		assert.Equal(t, "GitHub Enterprise", conflict.Current.(map[string]interface{})["title"])
+		assert.Equal(t, "monalisa", conflict.Proposed.(map[string]interface{})["username"])
+	}
+
+	// Partial updates conflict when they name the revision
+	err = c.PatchItem(client.LoginItem, login.ID, map[string]interface{}{"revision": 1, "username": "monalisa"}, nil)
+	assert.Equal(t, http.StatusConflict, err.(*client.Error).StatusCode)
+
+	// The merge is based on the current revision
+	merged := office
+	merged.Username = "monalisa"
+	merged.Revision = 2
+	updated, err := c.UpdateLogin(login.ID, &merged)
+	assert.NoError(t, err)
+	assert.Equal(t, "GitHub Enterprise", updated.Title)
+	assert.Equal(t, "monalisa", updated.Username)
+
+	// Updates without a revision overwrite
+	updated.Revision = 0
+	updated.Title = "GitHub"
+	_, err = c.UpdateLogin(login.ID, updated)
+	assert.NoError(t, err)
+}
+
+func TestMoveItems(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	work, err := c.CreateFolder(&model.FolderDTO{Name: "Work"})
+	assert.NoError(t, err)
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "Jira"})
+	assert.NoError(t, err)
+	note, err := c.CreateNote(&model.NoteDTO{Title: "VPN"})
+	assert.NoError(t, err)
+
+	items := []model.ItemRefDTO{{Type: client.LoginItem, ID: login.ID}, {Type: client.NoteItem, ID: note.ID}}
+	assert.NoError(t, c.MoveItems(work.ID, items))
+	logins, err := c.ListLogins(&client.ListOptions{FolderID: &work.ID})
+	assert.NoError(t, err)
+	assert.Len(t, logins, 1)
+	notes, err := c.ListNotes(&client.ListOptions{FolderID: &work.ID})
+	assert.NoError(t, err)
+	assert.Len(t, notes, 1)
+
+	// Nothing is moved when an item isn't found
+	err = c.MoveItems(0, append(items, model.ItemRefDTO{Type: client.LoginItem, ID: 1000}))
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+	logins, err = c.ListLogins(&client.ListOptions{FolderID: &work.ID})
+	assert.NoError(t, err)
+	assert.Len(t, logins, 1)
+
+	err = c.MoveItems(1000, items)
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+	err = c.MoveItems(work.ID, []model.ItemRefDTO{{Type: "folders", ID: work.ID}})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+
+	unfiled := uint(0)
+	assert.NoError(t, c.MoveItems(0, items[:1]))
+	logins, err = c.ListLogins(&client.ListOptions{FolderID: &unfiled})
+	assert.NoError(t, err)
+	assert.Len(t, logins, 1)
+}
+
+func TestTags(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	work, err := c.CreateTag(&model.TagDTO{Name: "work"})
+	assert.NoError(t, err)
+	shared, err := c.CreateTag(&model.TagDTO{Name: "shared"})
+	assert.NoError(t, err)
+	tags, err := c.ListTags()
+	assert.NoError(t, err)
+	assert.Len(t, tags, 2)
+
+	// Unknown tags are left out
+	jira, err := c.CreateLogin(&model.LoginDTO{Title: "Jira", Tags: []uint{work.ID, shared.ID, 99}})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{work.ID, shared.ID}, jira.Tags)
+	_, err = c.CreateLogin(&model.LoginDTO{Title: "GitHub", Tags: []uint{work.ID}})
+	assert.NoError(t, err)
+	note, err := c.CreateNote(&model.NoteDTO{Title: "VPN", Tags: []uint{shared.ID}})
+	assert.NoError(t, err)
+
+	logins, err := c.ListLogins(&client.ListOptions{Tags: []uint{work.ID}})
+	assert.NoError(t, err)
+	assert.Len(t, logins, 2)
+	logins, err = c.ListLogins(&client.ListOptions{Tags: []uint{work.ID, shared.ID}})
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "Jira", logins[0].Title)
+		assert.Equal(t, []uint{work.ID, shared.ID}, logins[0].Tags)
+	}
+	notes, err := c.ListNotes(&client.ListOptions{Tags: []uint{shared.ID}})
+	assert.NoError(t, err)
+	assert.Len(t, notes, 1)
+
+	// Updates without tags keep them, an empty list removes them
+	_, err = c.UpdateNote(note.ID, &model.NoteDTO{Title: "VPN", Note: "key"})
+	assert.NoError(t, err)
+	got, err := c.GetNote(note.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{shared.ID}, got.Tags)
+	_, err = c.UpdateNote(note.ID, &model.NoteDTO{Title: "VPN", Tags: []uint{}})
+	assert.NoError(t, err)
+	got, err = c.GetNote(note.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, got.Tags)
+
+	clone := new(model.LoginDTO)
+	assert.NoError(t, c.CloneItem(client.LoginItem, jira.ID, clone))
+	assert.Equal(t, []uint{work.ID, shared.ID}, clone.Tags)
+
+	assert.NoError(t, c.DeleteTag(work.ID))
+	got2, err := c.GetLogin(jira.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{shared.ID}, got2.Tags)
+}
+
+func TestFavorites(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	_, err := c.CreateLogin(&model.LoginDTO{Title: "Jira", IsFavorite: true})
+	assert.NoError(t, err)
+	_, err = c.CreateLogin(&model.LoginDTO{Title: "GitHub"})
+	assert.NoError(t, err)
+	note, err := c.CreateNote(&model.NoteDTO{Title: "VPN", IsFavorite: true})
+	assert.NoError(t, err)
+	assert.True(t, note.IsFavorite)
+
+	favorites, err := c.Favorites()
+	assert.NoError(t, err)
+	if assert.Len(t, favorites, 2) {
+		assert.Equal(t, client.LoginItem, favorites[0].Type)
+		assert.Equal(t, "Jira", favorites[0].Item.(map[string]interface{})["title"])
+		assert.Equal(t, client.NoteItem, favorites[1].Type)
+	}
+	logins, err := c.ListLogins(&client.ListOptions{IsFavorite: true})
+	assert.NoError(t, err)
+	assert.Len(t, logins, 1)
+
+	_, err = c.UpdateNote(note.ID, &model.NoteDTO{Title: "VPN"})
+	assert.NoError(t, err)
+	favorites, err = c.Favorites()
+	assert.NoError(t, err)
+	assert.Len(t, favorites, 1)
+}
+
+func TestCreateItems(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	tag, err := c.CreateTag(&model.TagDTO{Name: "imported"})
+	assert.NoError(t, err)
+
+	results, err := c.CreateItems(client.LoginItem, []model.LoginDTO{
+		{Title: "GitHub", Tags: []uint{tag.ID}},
+		{Title: "Broken", RotationPeriod: "30d"},
+		{Title: "GitLab"},
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, "GitHub", results[0].Item.(map[string]interface{})["title"])
+		assert.Empty(t, results[0].Error)
+		assert.Equal(t, 1, results[1].Index)
+		assert.Nil(t, results[1].Item)
+		assert.NotEmpty(t, results[1].Error)
+		assert.Equal(t, "GitLab", results[2].Item.(map[string]interface{})["title"])
+	}
+
+	logins, err := c.ListLogins(&client.ListOptions{Tags: []uint{tag.ID}})
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "GitHub", logins[0].Title)
+	}
+	logins, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+	assert.Len(t, logins, 2)
+
+	_, err = c.CreateItems(client.LoginItem, []model.LoginDTO{})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
+
+func TestDeleteItems(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	ids := []uint{}
+	for _, title := range []string{"Draft", "Receipt", "Recipe"} {
+		note, err := c.CreateNote(&model.NoteDTO{Title: title})
+		assert.NoError(t, err)
+		ids = append(ids, note.ID)
+	}
+
+	summary, err := c.DeleteItems(client.NoteItem, []uint{ids[0], ids[1], ids[0], 1000})
+	assert.NoError(t, err)
+	assert.Equal(t, []uint{ids[0], ids[1]}, summary.Deleted)
+	if assert.Len(t, summary.Failed, 1) {
+		assert.Equal(t, uint(1000), summary.Failed[0].ID)
+		assert.NotEmpty(t, summary.Failed[0].Error)
+	}
+
+	notes, err := c.ListNotes(nil)
+	assert.NoError(t, err)
+	if assert.Len(t, notes, 1) {
+		assert.Equal(t, "Recipe", notes[0].Title)
+	}
+	trash, err := c.Trash()
+	assert.NoError(t, err)
+	assert.Len(t, trash, 2)
+
+	_, err = c.DeleteItems(client.NoteItem, []uint{})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
+
+func TestSearch(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	_, err := c.CreateLogin(&model.LoginDTO{Title: "Jira", URL: "https://jira.acme.com"})
+	assert.NoError(t, err)
+	_, err = c.CreateLogin(&model.LoginDTO{Title: "GitHub", URL: "https://github.com"})
+	assert.NoError(t, err)
+	_, err = c.CreateBankAccount(&model.BankAccountDTO{BankName: "Acme Bank"})
+	assert.NoError(t, err)
+	_, err = c.CreateServer(&model.ServerDTO{Title: "acme build", IP: "10.0.0.1"})
+	assert.NoError(t, err)
+
+	result, err := c.Search("acme", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Total)
+	if assert.Len(t, result.Items, 3) {
+		assert.Equal(t, client.LoginItem, result.Items[0].Type)
+		assert.Equal(t, client.BankAccountItem, result.Items[1].Type)
+		assert.Equal(t, client.ServerItem, result.Items[2].Type)
+	}
+
+	result, err = c.Search("acme", &client.ListOptions{Offset: 1, Limit: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Total)
+	if assert.Len(t, result.Items, 1) {
+		assert.Equal(t, client.BankAccountItem, result.Items[0].Type)
+	}
+
+	_, err = c.Search("", nil)
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+
+	// Encrypted fields are searched by the words of their blind index
+	_, err = c.CreateLogin(&model.LoginDTO{Title: "Code", Username: "octocat@example.com"})
+	assert.NoError(t, err)
+	logins, err := c.ListLogins(&client.ListOptions{Search: "OCTO"})
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 1) {
+		assert.Equal(t, "Code", logins[0].Title)
+	}
+	logins, err = c.ListLogins(&client.ListOptions{Search: "cat"})
+	assert.NoError(t, err)
+	assert.Empty(t, logins)
+}
+
+func TestCursorPagination(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	for _, title := range []string{"e", "b", "d", "a", "c"} {
+		_, err := c.CreateLogin(&model.LoginDTO{Title: title, Pinned: title == "d"})
+		assert.NoError(t, err)
+	}
+
+	// Items added between pages don't shift the next ones
+	titles := []string{}
+	cursor := ""
+	opts := &client.ListOptions{Sort: "title", Order: "asc", Limit: 2, Cursor: &cursor}
+	for page := 0; page < 5; page++ {
+		logins, err := c.ListLogins(opts)
+		assert.NoError(t, err)
+		for _, login := range logins {
+			titles = append(titles, login.Title)
+		}
+		if page == 0 {
+			_, err = c.CreateLogin(&model.LoginDTO{Title: "0"})
+			assert.NoError(t, err)
+		}
+		if opts.NextCursor == "" {
+			break
+		}
+		cursor = opts.NextCursor
+	}
+	assert.Equal(t, []string{"d", "a", "b", "c", "e"}, titles)
+
+	// Pages of the default order end with the last item
+	cursor = ""
+	opts = &client.ListOptions{Limit: 3, Cursor: &cursor}
+	logins, err := c.ListLogins(opts)
+	assert.NoError(t, err)
+	assert.Len(t, logins, 3)
+	cursor = opts.NextCursor
+	logins, err = c.ListLogins(opts)
+	assert.NoError(t, err)
+	assert.Len(t, logins, 3)
+	assert.Empty(t, opts.NextCursor)
+
+	invalid := "not a cursor"
+	_, err = c.ListNotes(&client.ListOptions{Cursor: &invalid})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
+
+func TestMultiColumnSort(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	ids := map[string]uint{}
+	for _, name := range []string{"b1", "a", "b2", "c"} {
+		login, err := c.CreateLogin(&model.LoginDTO{Title: name[:1], URL: name})
+		assert.NoError(t, err)
+		ids[name] = login.ID
+	}
+	want := []uint{ids["a"], ids["b2"], ids["b1"], ids["c"]}
+
+	logins, err := c.ListLogins(&client.ListOptions{Sort: "title:asc,id:desc"})
+	assert.NoError(t, err)
+	got := []uint{}
+	for _, login := range logins {
+		got = append(got, login.ID)
+	}
+	assert.Equal(t, want, got)
+
+	// Pages of a cursor keep the order
+	cursor := ""
+	opts := &client.ListOptions{Sort: "title,id:desc", Limit: 1, Cursor: &cursor}
+	got = []uint{}
+	for page := 0; page < 5; page++ {
+		logins, err := c.ListLogins(opts)
+		assert.NoError(t, err)
+		for _, login := range logins {
+			got = append(got, login.ID)
+		}
+		if cursor = opts.NextCursor; cursor == "" {
+			break
+		}
+	}
+	assert.Equal(t, want, got)
+
+	// Fields which can't be sorted by fall back to the last updated first
+	logins, err = c.ListLogins(&client.ListOptions{Sort: "title:asc,password:desc"})
+	assert.NoError(t, err)
+	if assert.Len(t, logins, 4) {
+		assert.Equal(t, ids["c"], logins[0].ID)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	_, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", URL: "https://github.com"})
+	assert.NoError(t, err)
+	_, err = c.CreateLogin(&model.LoginDTO{Title: "GitHub", URL: "https://gist.github.com"})
+	assert.NoError(t, err)
+	_, err = c.CreateBankAccount(&model.BankAccountDTO{BankName: "Acme"})
+	assert.NoError(t, err)
+
+	logins, err := c.ListLogins(&client.ListOptions{Fields: map[string]string{"url": "https://github.com"}})
+	assert.NoError(t, err)
+	assert.Len(t, logins, 1)
+	logins, err = c.ListLogins(&client.ListOptions{Fields: map[string]string{"title": "GitHub"}})
+	assert.NoError(t, err)
+	assert.Len(t, logins, 2)
+	// Params which aren't fields of the type don't filter
+	accounts, err := c.ListBankAccounts(&client.ListOptions{Fields: map[string]string{"title": "Acme", "password": "x"}})
+	assert.NoError(t, err)
+	assert.Len(t, accounts, 1)
+
+	now := time.Now()
+	logins, err = c.ListLogins(&client.ListOptions{CreatedAfter: now.Add(-time.Hour), CreatedBefore: now.Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.Len(t, logins, 2)
+	logins, err = c.ListLogins(&client.ListOptions{UpdatedAfter: now.Add(time.Hour)})
+	assert.NoError(t, err)
+	assert.Empty(t, logins)
+
+	// Dates cover the whole day
+	var list []model.LoginDTO
+	query := url.Values{"CreatedBefore": {now.UTC().Format("2006-01-02")}}
+	code, err := srv.Do(c.Session(), http.MethodGet, "/api/logins?"+query.Encode(), nil, &list)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, list, 2)
+	query = url.Values{"CreatedAfter": {"yesterday"}}
+	code, _ = srv.Do(c.Session(), http.MethodGet, "/api/logins?"+query.Encode(), nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestZeroKnowledge(t *testing.T) {
+	viper.Set("server.zeroKnowledge", true)
+	defer viper.Set("server.zeroKnowledge", false)
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	created, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", Username: "client-blob", Password: "U2FsdGVkX1+client"})
+	assert.NoError(t, err)
+	row, err := srv.Store.Logins().FindByID(created.ID, "user1")
+	if assert.NoError(t, err) {
+		assert.Equal(t, "U2FsdGVkX1+client", row.Password)
+	}
+	got, err := c.GetLogin(created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "client-blob", got.Username)
+
+	_, err = c.RotateLogin(created.ID)
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
+
+func TestSearchBudget(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	viper.Set("budget.search", "2/1h")
+	defer viper.Set("budget.search", "120/1m")
+
+	for i := 0; i < 2; i++ {
+		_, err := c.ListLogins(&client.ListOptions{Search: "github"})
+		assert.NoError(t, err)
+	}
+	_, err := c.ListLogins(&client.ListOptions{Search: "github"})
+	assert.Equal(t, http.StatusTooManyRequests, err.(*client.Error).StatusCode)
+	assert.Equal(t, []string{"BUDGET_EXCEEDED"}, err.(*client.Error).Errors)
+	assert.Equal(t, 30*time.Minute, err.(*client.Error).RetryAfter)
+
+	// Listing without a search isn't limited
+	_, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+}
+
+func TestAttachments(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	dir, err := ioutil.TempDir("", "blobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	viper.Set("blob.dir", dir)
+
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub", Username: "octocat", Password: "secret"})
+	assert.NoError(t, err)
+	content := []byte("recovery codes 1234-5678")
+	attachment, err := c.CreateAttachment(client.LoginItem, login.ID, "../codes.txt", content)
+	assert.NoError(t, err)
+	sum := sha256.Sum256(content)
+	assert.Equal(t, "codes.txt", attachment.Name)
+	assert.Equal(t, int64(len(content)), attachment.Size)
+	assert.Equal(t, hex.EncodeToString(sum[:]), attachment.SHA256)
+
+	attachments, err := c.Attachments(client.LoginItem, login.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, []model.AttachmentDTO{*attachment}, attachments)
+	data, err := c.DownloadAttachment(client.LoginItem, login.ID, attachment.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, content, data)
+
+	// The content is encrypted in the blob store
+	files := 0
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			files++
+			stored, _ := ioutil.ReadFile(path)
+			assert.NotContains(t, string(stored), "recovery codes")
+		}
+		return nil
+	})
+	assert.Equal(t, 1, files)
+
+	_, err = c.CreateAttachment(client.LoginItem, login.ID+1, "codes.txt", content)
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+	_, err = c.DownloadAttachment(client.NoteItem, login.ID, attachment.ID)
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+
+	// Admins change the limits of a user
+	user, _ := srv.Store.Users().FindByEmail("test@passwall.io")
+	_, err = c.SetAttachmentLimits(user.ID, &model.AttachmentLimitsDTO{MaxSize: 10})
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+	user.Role = "Admin"
+	srv.Store.Users().Save(user)
+	assert.NoError(t, c.Signin("test@passwall.io", "master-password"))
+
+	_, err = c.SetAttachmentLimits(user.ID, &model.AttachmentLimitsDTO{MaxSize: 10})
+	assert.NoError(t, err)
+	_, err = c.CreateAttachment(client.LoginItem, login.ID, "big.bin", make([]byte, 11))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, err.(*client.Error).StatusCode)
+	_, err = c.SetAttachmentLimits(user.ID, &model.AttachmentLimitsDTO{Quota: int64(len(content)) + 5})
+	assert.NoError(t, err)
+	_, err = c.CreateAttachment(client.LoginItem, login.ID, "big.bin", make([]byte, 6))
+	assert.Equal(t, http.StatusRequestEntityTooLarge, err.(*client.Error).StatusCode)
+	_, err = c.CreateAttachment(client.LoginItem, login.ID, "small.bin", make([]byte, 5))
+	assert.NoError(t, err)
+	usage, err := c.AttachmentUsage()
+	assert.NoError(t, err)
+	assert.Equal(t, model.AttachmentUsageDTO{Used: int64(len(content)) + 5, MaxSize: viper.GetInt64("attachment.maxSize"), Quota: int64(len(content)) + 5}, *usage)
+
+	assert.NoError(t, c.DeleteAttachment(client.LoginItem, login.ID, attachment.ID))
+	_, err = c.DownloadAttachment(client.LoginItem, login.ID, attachment.ID)
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+
+	// Purged items take their attachments along
+	assert.NoError(t, c.DeleteLogin(login.ID))
+	assert.NoError(t, c.PurgeItem(client.LoginItem, login.ID))
+	usage, err = c.AttachmentUsage()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), usage.Used)
+	objects, err := (&blob.Disk{Dir: dir}).List("")
+	assert.NoError(t, err)
+	assert.Empty(t, objects)
+}
+
+func TestCanaryLogin(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	alerts := make(chan *app.Alert, 10)
+	defer func(notifiers []app.Notifier) { app.Notifiers = notifiers }(app.Notifiers)
+	app.Notifiers = []app.Notifier{func(alert *app.Alert) error {
+		alerts <- alert
+		return nil
+	}}
+
+	canary, err := c.CreateLogin(&model.LoginDTO{Title: "AWS root", Username: "root", Password: "honey", Canary: true})
+	assert.NoError(t, err)
+	assert.Len(t, alerts, 0)
+
+	login, err := c.GetLogin(canary.ID)
+	assert.NoError(t, err)
+	assert.True(t, login.Canary)
+
+	select {
+	case alert := <-alerts:
+		assert.Equal(t, "canary_read", alert.Event)
+		assert.Equal(t, "test@passwall.io", alert.Email)
+	case <-time.After(time.Second):
+		t.Fatal("no alert")
+	}
+
+	// Reading a canary in the trash trips it too
+	deleted, err := c.CreateLogin(&model.LoginDTO{Title: "GCP owner", Username: "owner", Password: "honey", Canary: true})
+	assert.NoError(t, err)
+	assert.NoError(t, c.DeleteLogin(deleted.ID))
+	_, err = c.Trash()
+	assert.NoError(t, err)
+	path := "logins/" + strconv.Itoa(int(deleted.ID))
+	for {
+		select {
+		case alert := <-alerts:
+			if alert.Event == "canary_read" && alert.Fields["Item"] == path {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no alert for the trash")
+		}
+	}
+}
@@ -2,16 +2,37 @@ package router
 
 import (
 	"net/http"
+	"sync"
 
 	"github.com/didip/tollbooth"
+	"github.com/didip/tollbooth/limiter"
+	"github.com/spf13/viper"
 	"github.com/urfave/negroni"
 )
 
-// LimitHandler ...
+// LimitHandler limits the requests of an address to server.rateLimit per second. A new
+// limiter starts when the configuration reload changes the rate.
 func LimitHandler() negroni.HandlerFunc {
-	lmt := tollbooth.NewLimiter(5, nil)
+	var mu sync.Mutex
+	var lmt *limiter.Limiter
+	current := func(max int) *limiter.Limiter {
+		mu.Lock()
+		defer mu.Unlock()
+		if lmt == nil || lmt.GetMax() != float64(max) {
+			lmt = tollbooth.NewLimiter(float64(max), nil)
+			// RealIP already resolved the client behind trusted proxies, headers can be forged
+			lmt.SetIPLookups([]string{"RemoteAddr"})
+		}
+		return lmt
+	}
 
 	return negroni.HandlerFunc(func(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+		max := viper.GetInt("server.rateLimit")
+		if max <= 0 {
+			next(w, r)
+			return
+		}
+		lmt := current(max)
 		httpError := tollbooth.LimitByRequest(lmt, w, r)
 		if httpError != nil {
 			w.Header().Add("Content-Type", lmt.GetMessageContentType())
@@ -0,0 +1,19 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/internal/i18n"
+	"github.com/spf13/viper"
+)
+
+// Locale negotiates the language of the response from the Accept-Language header.
+// Auth replaces it with the preference of the user.
+func Locale(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	fallback := viper.GetString("i18n.defaultLocale")
+	if !i18n.Supported(fallback) {
+		fallback = i18n.Default
+	}
+	w.Header().Set(i18n.Header, i18n.Negotiate(r.Header.Get("Accept-Language"), fallback))
+	next(w, r)
+}
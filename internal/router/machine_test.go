@@ -0,0 +1,241 @@
+package router_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/model"
+	"github.com/passwall/passwall-server/pkg/client"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMachineAccountInject(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	deploy, err := c.CreateLogin(&model.LoginDTO{Title: "Deploy Key", Username: "ci", Password: "s3cret"})
+	assert.NoError(t, err)
+	other, err := c.CreateLogin(&model.LoginDTO{Title: "Other", Password: "private"})
+	assert.NoError(t, err)
+
+	deployPath := itemPath(client.LoginItem, deploy.ID)[len("/api/"):]
+	account, err := c.CreateMachineAccount(&model.MachineAccountDTO{Name: "ci", Items: []string{deployPath}})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, account.Secret)
+
+	_, err = c.MachineToken(account.ClientID, "wrong")
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+
+	token, err := c.MachineToken(account.ClientID, account.Secret)
+	assert.NoError(t, err)
+
+	secrets, err := c.Inject(token.AccessToken, nil)
+	assert.NoError(t, err)
+	var login model.LoginDTO
+	assert.NoError(t, json.Unmarshal(secrets[deployPath], &login))
+	assert.Equal(t, "s3cret", login.Password)
+
+	_, err = c.Inject(token.AccessToken, []string{itemPath(client.LoginItem, other.ID)[len("/api/"):]})
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	// Machine tokens can't be used for the vault api and user tokens can't inject
+	machine := client.New(srv.URL, client.WithSession(&model.AuthLoginResponse{AccessToken: token.AccessToken}))
+	_, err = machine.ListLogins(nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	_, err = c.Inject(c.Session().AccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+
+	assert.NoError(t, c.DeleteMachineAccount(account.ID))
+	_, err = c.Inject(token.AccessToken, nil)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+}
+
+func TestK8sSecrets(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "Registry Pull", Username: "bot", Password: "first"})
+	assert.NoError(t, err)
+
+	path := itemPath(client.LoginItem, login.ID)[len("/api/"):]
+	account, err := c.CreateMachineAccount(&model.MachineAccountDTO{Name: "cluster", Items: []string{path}})
+	assert.NoError(t, err)
+	token, err := c.MachineToken(account.ClientID, account.Secret)
+	assert.NoError(t, err)
+
+	list, err := c.K8sSecrets(token.AccessToken, "apps", "", 0)
+	assert.NoError(t, err)
+	if assert.Len(t, list.Secrets, 1) {
+		secret := list.Secrets[0]
+		assert.Equal(t, "registry-pull", secret.Metadata.Name)
+		assert.Equal(t, "apps", secret.Metadata.Namespace)
+		assert.Equal(t, path, secret.Metadata.Annotations["passwall.io/item"])
+		assert.Equal(t, "first", string(secret.Data["password"]))
+	}
+
+	same, err := c.K8sSecrets(token.AccessToken, "apps", list.Version, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, list.Version, same.Version)
+
+	_, err = c.UpdateLogin(login.ID, &model.LoginDTO{Title: "Registry Pull", Username: "bot", Password: "second"})
+	assert.NoError(t, err)
+
+	changed, err := c.K8sSecrets(token.AccessToken, "apps", list.Version, time.Second)
+	assert.NoError(t, err)
+	assert.NotEqual(t, list.Version, changed.Version)
+	assert.Equal(t, "second", string(changed.Secrets[0].Data["password"]))
+
+	_, err = c.K8sSecrets(token.AccessToken, "Not_Valid", "", 0)
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+}
+
+// canaryReads counts the canary read events of the log
+type canaryReads struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (h *canaryReads) Levels() []log.Level {
+	return log.AllLevels
+}
+
+func (h *canaryReads) Fire(entry *log.Entry) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if entry.Data["event"] == "canary_"+app.CanaryRead {
+		h.count++
+	}
+	return nil
+}
+
+func (h *canaryReads) Count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.count
+}
+
+func TestK8sSecretsCanary(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	defer func(notifiers []app.Notifier) { app.Notifiers = notifiers }(app.Notifiers)
+	app.Notifiers = nil
+	reads := &canaryReads{}
+	hooks := log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+	defer log.StandardLogger().ReplaceHooks(hooks)
+	log.AddHook(reads)
+
+	canary, err := c.CreateLogin(&model.LoginDTO{Title: "AWS root", Username: "root", Password: "honey", Canary: true})
+	assert.NoError(t, err)
+	path := itemPath(client.LoginItem, canary.ID)[len("/api/"):]
+	account, err := c.CreateMachineAccount(&model.MachineAccountDTO{Name: "cluster", Items: []string{path}})
+	assert.NoError(t, err)
+	token, err := c.MachineToken(account.ClientID, account.Secret)
+	assert.NoError(t, err)
+
+	list, err := c.K8sSecrets(token.AccessToken, "apps", "", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, reads.Count())
+
+	// A wait without changes reads the canary once, for the list it sends
+	same, err := c.K8sSecrets(token.AccessToken, "apps", list.Version, 2*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, list.Version, same.Version)
+	assert.Equal(t, 2, reads.Count())
+
+	// Changes of other items of the vault don't send the list
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		c.CreateNote(&model.NoteDTO{Title: "VPN"})
+	}()
+	same, err = c.K8sSecrets(token.AccessToken, "apps", list.Version, 2*time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, list.Version, same.Version)
+	assert.Equal(t, 3, reads.Count())
+}
+
+func TestMachineAccountFolder(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	folder, err := c.CreateFolder(&model.FolderDTO{Name: "Cluster"})
+	assert.NoError(t, err)
+	registry, err := c.CreateLogin(&model.LoginDTO{Title: "Registry Pull", Username: "bot", Password: "first"})
+	assert.NoError(t, err)
+	other, err := c.CreateLogin(&model.LoginDTO{Title: "Other", Password: "private"})
+	assert.NoError(t, err)
+	refs := []model.ItemRefDTO{{Type: client.LoginItem, ID: registry.ID}}
+	assert.NoError(t, c.MoveItems(folder.ID, refs))
+
+	_, err = c.CreateMachineAccount(&model.MachineAccountDTO{Name: "cluster", Items: []string{"folders/999"}})
+	assert.Equal(t, http.StatusBadRequest, err.(*client.Error).StatusCode)
+	folderScope := "folders/" + strconv.Itoa(int(folder.ID))
+	account, err := c.CreateMachineAccount(&model.MachineAccountDTO{Name: "cluster", Items: []string{folderScope}})
+	assert.NoError(t, err)
+	token, err := c.MachineToken(account.ClientID, account.Secret)
+	assert.NoError(t, err)
+
+	// The items in the folder are read, others aren't
+	registryPath := itemPath(client.LoginItem, registry.ID)[len("/api/"):]
+	secrets, err := c.Inject(token.AccessToken, nil)
+	assert.NoError(t, err)
+	assert.Len(t, secrets, 1)
+	assert.Contains(t, secrets, registryPath)
+	_, err = c.Inject(token.AccessToken, []string{registryPath})
+	assert.NoError(t, err)
+	_, err = c.Inject(token.AccessToken, []string{itemPath(client.LoginItem, other.ID)[len("/api/"):]})
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+
+	list, err := c.K8sSecrets(token.AccessToken, "apps", "", 0)
+	assert.NoError(t, err)
+	if assert.Len(t, list.Secrets, 1) {
+		assert.Equal(t, registryPath, list.Secrets[0].Metadata.Annotations["passwall.io/item"])
+	}
+
+	// An item moved out of the folder isn't read anymore and its secret is pruned
+	assert.NoError(t, c.MoveItems(0, refs))
+	_, err = c.Inject(token.AccessToken, []string{registryPath})
+	assert.Equal(t, http.StatusForbidden, err.(*client.Error).StatusCode)
+	secrets, err = c.Inject(token.AccessToken, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, secrets)
+	changed, err := c.K8sSecrets(token.AccessToken, "apps", list.Version, time.Second)
+	assert.NoError(t, err)
+	assert.NotEqual(t, list.Version, changed.Version)
+	assert.Empty(t, changed.Secrets)
+}
+
+func TestMachineTokenSession(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "Deploy Key", Password: "s3cret"})
+	assert.NoError(t, err)
+	account, err := c.CreateMachineAccount(&model.MachineAccountDTO{Name: "ci", Items: []string{itemPath(client.LoginItem, login.ID)[len("/api/"):]}})
+	assert.NoError(t, err)
+	token, err := c.MachineToken(account.ClientID, account.Secret)
+	assert.NoError(t, err)
+
+	// Machine tokens have no session to refresh or check
+	data, _ := json.Marshal(map[string]string{"refresh_token": token.AccessToken})
+	resp, err := http.Post(srv.URL+"/auth/refresh", "application/json", bytes.NewReader(data))
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+
+	req, _ := http.NewRequest(http.MethodPost, srv.URL+"/auth/check", nil)
+	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
+	resp, err = http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+}
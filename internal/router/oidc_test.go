@@ -0,0 +1,86 @@
+package router_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/model"
+	"github.com/passwall/passwall-server/pkg/servertest"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOIDCAuthorize(t *testing.T) {
+	srv, err := servertest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+	if _, err := srv.CreateUser("Test", "test@passwall.io", "master-password"); err != nil {
+		t.Fatal(err)
+	}
+
+	viper.Set("oidc.clients", []map[string]interface{}{
+		{"id": "wiki", "secret": "s3cret", "redirectURIs": []string{"https://wiki.example.com/callback"}},
+	})
+	viper.Set("server.signinDelay", "0s")
+	defer viper.Set("oidc.clients", nil)
+	defer viper.Set("server.signinDelay", "1s")
+
+	// The form redirects to the client after a sign in, otherwise it's shown again
+	httpClient := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	authorize := func(email, password string) *http.Response {
+		resp, err := httpClient.PostForm(srv.URL+"/oauth/authorize", url.Values{
+			"response_type":   {"code"},
+			"client_id":       {"wiki"},
+			"redirect_uri":    {"https://wiki.example.com/callback"},
+			"scope":           {"openid email"},
+			"state":           {"xyz"},
+			"email":           {email},
+			"master_password": {password},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := authorize("test@passwall.io", "master-password")
+	assert.Equal(t, http.StatusFound, resp.StatusCode)
+	assert.True(t, strings.HasPrefix(resp.Header.Get("Location"), "https://wiki.example.com/callback?code="))
+
+	// Failures count against the lockout of Signin, the right password is rejected then
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, http.StatusOK, authorize("test@passwall.io", "wrong-password").StatusCode)
+	}
+	resp = authorize("test@passwall.io", "master-password")
+	assert.Equal(t, http.StatusLocked, resp.StatusCode)
+	assert.NotEmpty(t, resp.Header.Get("Retry-After"))
+	assert.Empty(t, resp.Header.Get("Location"))
+
+	// Unverified accounts don't get a code
+	viper.Set("server.requireEmailVerification", true)
+	defer viper.Set("server.requireEmailVerification", false)
+	sender := app.MailSender
+	defer func() { app.MailSender = sender }()
+	app.MailSender = func(name, email, subject, body string) {}
+
+	data, _ := json.Marshal(&model.UserSignup{Name: "New", Email: "new@passwall.io", MasterPassword: "master-password"})
+	signup, err := http.Post(srv.URL+"/auth/signup", "application/json", strings.NewReader(string(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signup.Body.Close()
+	assert.Equal(t, http.StatusOK, signup.StatusCode)
+
+	resp = authorize("new@passwall.io", "master-password")
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Location"))
+}
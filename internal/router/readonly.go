@@ -0,0 +1,33 @@
+package router
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/internal/api"
+	"github.com/passwall/passwall-server/internal/app"
+)
+
+const readOnlyMessage = "Server is in read-only mode, try again when the primary server is back"
+
+// ReadOnly rejects all requests which may change data, used by standby servers
+// which are connected to a read replica of the database
+func ReadOnly(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		next(w, r)
+	default:
+		api.RespondWithError(w, http.StatusServiceUnavailable, readOnlyMessage)
+	}
+}
+
+// Writes wraps the GET handlers which change data, like the email confirmation links,
+// read-only servers reject them as ReadOnly rejects the other methods
+func Writes(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if app.ReadOnly() {
+			api.RespondWithError(w, http.StatusServiceUnavailable, readOnlyMessage)
+			return
+		}
+		handler(w, r)
+	}
+}
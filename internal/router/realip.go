@@ -0,0 +1,17 @@
+package router
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/passwall/passwall-server/internal/app"
+)
+
+// RealIP replaces the remote address of requests through trusted proxies with the
+// address of the client, so rate limits, audit entries and access rules see the client
+func RealIP(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	if ip := app.ResolveClientIP(r); ip != nil && !ip.Equal(app.ClientIP(r)) {
+		r.RemoteAddr = net.JoinHostPort(ip.String(), "0")
+	}
+	next(w, r)
+}
@@ -0,0 +1,21 @@
+package router
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/passwall/passwall-server/internal/logging"
+)
+
+// RequestID gives each request an id, it's sent back in X-Request-ID and tags the log
+// lines of the request. Ids sent by the caller or a proxy are kept.
+func RequestID(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	id := r.Header.Get(logging.Header)
+	if !logging.ValidRequestID(id) {
+		id = logging.NewRequestID()
+	}
+	w.Header().Set(logging.Header, id)
+
+	defer logging.Bind(id)()
+	next(w, r.WithContext(context.WithValue(r.Context(), "requestID", id)))
+}
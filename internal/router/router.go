@@ -4,12 +4,19 @@ import (
 	"net/http"
 
 	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
 	"github.com/urfave/negroni"
 
 	"github.com/passwall/passwall-server/internal/api"
+	"github.com/passwall/passwall-server/internal/app"
 	"github.com/passwall/passwall-server/internal/storage"
+
+	log "github.com/sirupsen/logrus"
 )
 
+// itemType matches all item types in generic item endpoints
+const itemType = "{type:logins|credit-cards|bank-accounts|notes|emails|servers}"
+
 // Router ...
 type Router struct {
 	router *mux.Router
@@ -37,40 +44,69 @@ func (r *Router) initRoutes() {
 
 	// Login endpoints
 	apiRouter.HandleFunc("/login-test", api.TestLogin(r.store)).Methods(http.MethodGet)
-	apiRouter.HandleFunc("/logins", api.FindAllLogins(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/logins", Budget(app.BudgetSearch, api.FindAllLogins(r.store))).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/logins", api.CreateLogin(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/logins/autofill", Budget(app.BudgetSearch, api.FindAutofillLogins(r.store))).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/logins/{id:[0-9]+}", api.FindLoginsByID(r.store)).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/logins/{id:[0-9]+}", api.UpdateLogin(r.store)).Methods(http.MethodPut)
 	apiRouter.HandleFunc("/logins/{id:[0-9]+}", api.DeleteLogin(r.store)).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/logins/{id:[0-9]+}/password-history", api.FindLoginPasswordHistory(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/logins/{id:[0-9]+}/history", api.FindLoginPasswordHistory(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/logins/{id:[0-9]+}/rotate", api.RotateLogin(r.store)).Methods(http.MethodPost)
 
 	// Bank Account endpoints
-	apiRouter.HandleFunc("/bank-accounts", api.FindAllBankAccounts(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/bank-accounts", Budget(app.BudgetSearch, api.FindAllBankAccounts(r.store))).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/bank-accounts", api.CreateBankAccount(r.store)).Methods(http.MethodPost)
 	apiRouter.HandleFunc("/bank-accounts/{id:[0-9]+}", api.FindBankAccountByID(r.store)).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/bank-accounts/{id:[0-9]+}", api.UpdateBankAccount(r.store)).Methods(http.MethodPut)
 	apiRouter.HandleFunc("/bank-accounts/{id:[0-9]+}", api.DeleteBankAccount(r.store)).Methods(http.MethodDelete)
 
 	// Credit Card endpoints
-	apiRouter.HandleFunc("/credit-cards", api.FindAllCreditCards(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/credit-cards", Budget(app.BudgetSearch, api.FindAllCreditCards(r.store))).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/credit-cards", api.CreateCreditCard(r.store)).Methods(http.MethodPost)
 	apiRouter.HandleFunc("/credit-cards/{id:[0-9]+}", api.FindCreditCardByID(r.store)).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/credit-cards/{id:[0-9]+}", api.UpdateCreditCard(r.store)).Methods(http.MethodPut)
 	apiRouter.HandleFunc("/credit-cards/{id:[0-9]+}", api.DeleteCreditCard(r.store)).Methods(http.MethodDelete)
 
 	// Note endpoints
-	apiRouter.HandleFunc("/notes", api.FindAllNotes(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/notes", Budget(app.BudgetSearch, api.FindAllNotes(r.store))).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/notes", api.CreateNote(r.store)).Methods(http.MethodPost)
 	apiRouter.HandleFunc("/notes/{id:[0-9]+}", api.FindNoteByID(r.store)).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/notes/{id:[0-9]+}", api.UpdateNote(r.store)).Methods(http.MethodPut)
 	apiRouter.HandleFunc("/notes/{id:[0-9]+}", api.DeleteNote(r.store)).Methods(http.MethodDelete)
 
 	// Email endpoints
-	apiRouter.HandleFunc("/emails", api.FindAllEmails(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/emails", Budget(app.BudgetSearch, api.FindAllEmails(r.store))).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/emails", api.CreateEmail(r.store)).Methods(http.MethodPost)
 	apiRouter.HandleFunc("/emails/{id:[0-9]+}", api.FindEmailByID(r.store)).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/emails/{id:[0-9]+}", api.UpdateEmail(r.store)).Methods(http.MethodPut)
 	apiRouter.HandleFunc("/emails/{id:[0-9]+}", api.DeleteEmail(r.store)).Methods(http.MethodDelete)
 
+	// Folder endpoints
+	apiRouter.HandleFunc("/folders", api.FindAllFolders(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/folders", api.CreateFolder(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/folders/{id:[0-9]+}", api.FindFolderByID(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/folders/{id:[0-9]+}", api.UpdateFolder(r.store)).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/folders/{id:[0-9]+}", api.DeleteFolder(r.store)).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/items/move", api.MoveItems(r.store)).Methods(http.MethodPost)
+
+	// Tag endpoints
+	apiRouter.HandleFunc("/tags", api.FindAllTags(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/tags", api.CreateTag(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/tags/{id:[0-9]+}", api.FindTagByID(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/tags/{id:[0-9]+}", api.UpdateTag(r.store)).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/tags/{id:[0-9]+}", api.DeleteTag(r.store)).Methods(http.MethodDelete)
+
+	// Equivalent domain endpoints
+	apiRouter.HandleFunc("/equivalent-domains", api.FindAllEquivalentDomains(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/equivalent-domains", api.CreateEquivalentDomain(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/equivalent-domains/{id:[0-9]+}", api.UpdateEquivalentDomain(r.store)).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/equivalent-domains/{id:[0-9]+}", api.DeleteEquivalentDomain(r.store)).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/system/equivalent-domains", api.FindAllEquivalentDomains(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/system/equivalent-domains", api.CreateEquivalentDomain(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/system/equivalent-domains/{id:[0-9]+}", api.UpdateEquivalentDomain(r.store)).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/system/equivalent-domains/{id:[0-9]+}", api.DeleteEquivalentDomain(r.store)).Methods(http.MethodDelete)
+
 	// User endpoints
 	apiRouter.HandleFunc("/users", api.FindAllUsers(r.store)).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/users", api.CreateUser(r.store)).Methods(http.MethodPost)
@@ -79,22 +115,108 @@ func (r *Router) initRoutes() {
 	apiRouter.HandleFunc("/users/{id:[0-9]+}", api.DeleteUser(r.store)).Methods(http.MethodDelete)
 
 	// Server endpoints
-	apiRouter.HandleFunc("/servers", api.FindAllServers(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/servers", Budget(app.BudgetSearch, api.FindAllServers(r.store))).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/servers", api.CreateServer(r.store)).Methods(http.MethodPost)
 	apiRouter.HandleFunc("/servers/{id:[0-9]+}", api.FindServerByID(r.store)).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/servers/{id:[0-9]+}", api.UpdateServer(r.store)).Methods(http.MethodPut)
 	apiRouter.HandleFunc("/servers/{id:[0-9]+}", api.DeleteServer(r.store)).Methods(http.MethodDelete)
 
+	// Machine account endpoints
+	apiRouter.HandleFunc("/machine-accounts", api.FindAllMachineAccounts(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/machine-accounts", api.CreateMachineAccount(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/machine-accounts/{id:[0-9]+}", api.UpdateMachineAccount(r.store)).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/machine-accounts/{id:[0-9]+}", api.DeleteMachineAccount(r.store)).Methods(http.MethodDelete)
+
+	// Session endpoints
+	apiRouter.HandleFunc("/auth/sessions", api.FindAllSessions(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/auth/sessions/{id}", api.DeleteSession(r.store)).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/auth/reauth", api.Reauthenticate(r.store)).Methods(http.MethodPost)
+
+	// Personal access token endpoints
+	apiRouter.HandleFunc("/tokens", api.FindAllAccessTokens(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/tokens", api.CreateAccessToken(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/tokens/{id:[0-9]+}", api.DeleteAccessToken(r.store)).Methods(http.MethodDelete)
+
+	// Policy endpoints
+	apiRouter.HandleFunc("/policies", api.FindPolicies(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/policies", api.UpdatePolicy(r.store)).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/system/policies", api.FindPolicies(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/system/policies", api.UpdatePolicy(r.store)).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/system/users/{id:[0-9]+}/security-key-exemption", api.ExemptFromSecurityKey(r.store)).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/system/users/{id:[0-9]+}/security-key-exemption", api.RemoveSecurityKeyExemption(r.store)).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/system/users/{id:[0-9]+}/attachment-limits", api.SetAttachmentLimits(r.store)).Methods(http.MethodPut)
+
+	// Retention endpoints
+	apiRouter.HandleFunc("/system/retention", api.RetentionReport(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/system/retention/purge", api.PurgeExpiredData(r.store)).Methods(http.MethodPost)
+
+	// Locale endpoints
+	apiRouter.HandleFunc("/locale", api.FindLocale(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/locale", api.UpdateLocale(r.store)).Methods(http.MethodPut)
+
+	// Two factor authentication endpoints
+	apiRouter.HandleFunc("/2fa", api.FindTwoFactor(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/2fa/totp", api.EnrollTOTP(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/2fa/totp/verify", api.VerifyTOTP(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/2fa/totp", api.DisableTOTP(r.store)).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/2fa/webauthn/registration", api.BeginSecurityKeyRegistration(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/2fa/webauthn/registration/verify", api.FinishSecurityKeyRegistration(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/2fa/webauthn/credentials", api.FindAllSecurityKeys(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/2fa/webauthn/credentials/{id:[0-9]+}", api.DeleteSecurityKey(r.store)).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/2fa/devices", api.FindAllTrustedDevices(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/2fa/devices/{id:[0-9]+}", api.DeleteTrustedDevice(r.store)).Methods(http.MethodDelete)
+
+	// Audit log endpoints
+	apiRouter.HandleFunc("/audit-logs", api.FindAuditEvents(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/audit/export", Budget(app.BudgetExport, api.ExportAuditEvents(r.store))).Methods(http.MethodGet)
+
+	// Duress password endpoints
+	apiRouter.HandleFunc("/duress", api.FindDuress(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/duress", api.SetDuress(r.store)).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/duress", api.RemoveDuress(r.store)).Methods(http.MethodDelete)
+
+	// Generic item endpoints
+	apiRouter.HandleFunc("/"+itemType, api.DeleteItems(r.store)).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/"+itemType+"/batch", api.CreateItems(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/"+itemType+"/order", api.UpdateItemOrders(r.store)).Methods(http.MethodPut)
+	apiRouter.HandleFunc("/"+itemType+"/export", Budget(app.BudgetExport, api.ExportItems(r.store))).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/"+itemType+"/{id:[0-9]+}", api.PatchItem(r.store)).Methods(http.MethodPatch)
+	apiRouter.HandleFunc("/"+itemType+"/{id:[0-9]+}/clone", api.CloneItem(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/"+itemType+"/{id:[0-9]+}/restore", api.RestoreItem(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/"+itemType+"/{id:[0-9]+}/purge", api.PurgeItem(r.store)).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/"+itemType+"/{id:[0-9]+}/versions", api.FindItemVersions(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/"+itemType+"/{id:[0-9]+}/versions/{version:[0-9]+}/restore", api.RestoreItemVersion(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/"+itemType+"/{id:[0-9]+}/attachments", api.FindAttachments(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/"+itemType+"/{id:[0-9]+}/attachments", api.CreateAttachment(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/"+itemType+"/{id:[0-9]+}/attachments/{attachment:[0-9]+}", api.DownloadAttachment(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/"+itemType+"/{id:[0-9]+}/attachments/{attachment:[0-9]+}", api.DeleteAttachment(r.store)).Methods(http.MethodDelete)
+	apiRouter.HandleFunc("/attachments/usage", api.AttachmentUsage(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/trash", api.FindTrash(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/favorites", api.FindFavorites(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/sync", api.Sync(r.store)).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/changes", api.WatchChanges).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/changes/events", api.StreamChanges).Methods(http.MethodGet)
+	apiRouter.HandleFunc("/search", Budget(app.BudgetSearch, api.Search(r.store))).Methods(http.MethodGet)
+
 	apiRouter.HandleFunc("/system/generate-password", api.GeneratePassword).Methods(http.MethodPost)
-	apiRouter.HandleFunc("/system/import", api.Import(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/system/import", Budget(app.BudgetImport, api.Import(r.store))).Methods(http.MethodPost)
 
 	// These endpoints designed just for logins. Now we have extra types like bank accounts
 	// apiRouter.HandleFunc("/system/check-password", api.FindSamePassword(r.store)).Methods(http.MethodPost)
-	// apiRouter.HandleFunc("/system/backup", api.Backup(r.store)).Methods(http.MethodPost)
-	// apiRouter.HandleFunc("/system/backup", api.ListBackup).Methods(http.MethodGet)
-	// apiRouter.HandleFunc("/system/restore", api.Restore(r.store)).Methods(http.MethodPost)
 
-	// apiRouter.HandleFunc("/system/export", api.Export(r.store)).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/restore", Budget(app.BudgetImport, api.RestoreBackup(r.store))).Methods(http.MethodPost)
+
+	// Import endpoints, exports of other password managers
+	apiRouter.HandleFunc("/import/bitwarden", Budget(app.BudgetImport, api.ImportBitwarden(r.store))).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/import/lastpass", Budget(app.BudgetImport, api.ImportLastPass(r.store))).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/import/1password", Budget(app.BudgetImport, api.ImportOnePassword(r.store))).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/import/keepass", Budget(app.BudgetImport, api.ImportKeePass(r.store))).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/import/browser", Budget(app.BudgetImport, api.ImportBrowser(r.store))).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/import/csv", Budget(app.BudgetImport, api.ImportCSV(r.store))).Methods(http.MethodPost)
+
+	// Export endpoints, archives are built in the background
+	apiRouter.HandleFunc("/export", Budget(app.BudgetExport, api.CreateExport(r.store))).Methods(http.MethodPost)
+	apiRouter.HandleFunc("/export/{id}", api.FindExport(r.store)).Methods(http.MethodGet)
 
 	apiRouter.HandleFunc("/system/languages", api.Languages(r.store)).Methods(http.MethodGet)
 	apiRouter.HandleFunc("/system/languages/{lang}", api.Language(r.store)).Methods(http.MethodGet)
@@ -102,10 +224,42 @@ func (r *Router) initRoutes() {
 	// Auth endpoints
 	authRouter := mux.NewRouter().PathPrefix("/auth").Subrouter()
 	authRouter.HandleFunc("/signup", api.Signup(r.store)).Methods(http.MethodPost)
-	authRouter.HandleFunc("/confirm/{email}/{code}", api.Confirm(r.store)).Methods(http.MethodGet)
+	authRouter.HandleFunc("/confirm/{email}/{code}", Writes(api.Confirm(r.store))).Methods(http.MethodGet)
 	authRouter.HandleFunc("/signin", api.Signin(r.store)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/signin/totp", api.SigninTOTP(r.store)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/signin/webauthn/options", api.BeginSecurityKeySignin(r.store)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/signin/webauthn", api.SigninSecurityKey(r.store)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/sso", api.FindSSOProviders).Methods(http.MethodGet)
+	authRouter.HandleFunc("/sso/{provider}", api.BeginSSO).Methods(http.MethodGet)
+	authRouter.HandleFunc("/sso/{provider}/callback", api.FinishSSO(r.store)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/saml/{provider}/metadata", api.SAMLMetadata).Methods(http.MethodGet)
+	authRouter.HandleFunc("/saml/{provider}", api.BeginSAML).Methods(http.MethodGet)
+	authRouter.HandleFunc("/saml/{provider}/acs", api.ConsumeSAML(r.store)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/saml/{provider}/callback", api.FinishSAML(r.store)).Methods(http.MethodPost)
 	authRouter.HandleFunc("/refresh", api.RefreshToken(r.store)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/revoke", api.RevokeToken(r.store)).Methods(http.MethodPost)
 	authRouter.HandleFunc("/check", api.CheckToken(r.store)).Methods(http.MethodPost)
+	authRouter.HandleFunc("/machine-token", api.CreateMachineToken(r.store)).Methods(http.MethodPost)
+
+	// OpenID Connect provider endpoints
+	oauthRouter := mux.NewRouter().PathPrefix("/oauth").Subrouter()
+	oauthRouter.HandleFunc("/authorize", api.OIDCAuthorize(r.store)).Methods(http.MethodGet, http.MethodPost)
+	oauthRouter.HandleFunc("/token", api.OIDCToken(r.store)).Methods(http.MethodPost)
+	oauthRouter.HandleFunc("/userinfo", api.OIDCUserInfo(r.store)).Methods(http.MethodGet, http.MethodPost)
+	oauthRouter.HandleFunc("/jwks", api.OIDCJWKS).Methods(http.MethodGet)
+
+	// Admin endpoints
+	adminRouter := mux.NewRouter().PathPrefix("/admin").Subrouter()
+	adminRouter.HandleFunc("/reencryption", api.StartReencryption(r.store)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/reencryption/status", api.ReencryptionStatus(r.store)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/rotate-key", api.RotateServerKey(r.store)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/rotate-key/finish", api.FinishKeyRotation(r.store)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/config/reload", api.ReloadConfig).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/database/pools", api.DatabasePools(r.store)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/migrations", api.MigrationStatus(r.store)).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/backups", api.ListBackups).Methods(http.MethodGet)
+	adminRouter.HandleFunc("/backups", api.CreateBackups(r.store)).Methods(http.MethodPost)
+	adminRouter.HandleFunc("/backups/{name}", api.DownloadBackup).Methods(http.MethodGet)
 
 	// Check Updated
 	webRouter := mux.NewRouter().PathPrefix("/web").Subrouter()
@@ -114,27 +268,105 @@ func (r *Router) initRoutes() {
 	// Subscription endpoints under web
 	webRouter.HandleFunc("/subscriptions", api.PostSubscription(r.store)).Methods(http.MethodPost)
 
-	n := negroni.Classic()
+	// The request id comes first, so panics and the access log are tagged with it
+	recovery := negroni.NewRecovery()
+	recovery.Logger = log.StandardLogger()
+	n := negroni.New(negroni.HandlerFunc(RequestID), recovery)
+	n.Use(negroni.HandlerFunc(RealIP))
+	n.Use(negroni.HandlerFunc(AccessLog))
+	n.Use(negroni.NewStatic(http.Dir("public")))
+	n.Use(negroni.HandlerFunc(Trace))
+	n.Use(negroni.HandlerFunc(Locale))
 	n.Use(negroni.HandlerFunc(CORS))
 	n.Use(negroni.HandlerFunc(Secure))
+	if viper.GetBool("server.readOnly") {
+		n.Use(negroni.HandlerFunc(ReadOnly))
+	}
 
 	r.router.PathPrefix("/web").Handler(n.With(
 		LimitHandler(),
 		negroni.Wrap(webRouter),
 	))
 
+	// Clients ask for the key derivation before they have a session
+	r.router.Handle("/api/auth/prelogin", n.With(
+		LimitHandler(),
+		negroni.Wrap(api.Prelogin(r.store)),
+	)).Methods(http.MethodPost)
+
+	// Verification links are authorized by their signature, unverified users can't sign in
+	r.router.Handle("/api/auth/verify", n.With(
+		LimitHandler(),
+		negroni.Wrap(Writes(api.VerifyEmail(r.store))),
+	)).Methods(http.MethodGet)
+	r.router.Handle("/api/auth/verify/resend", n.With(
+		LimitHandler(),
+		negroni.Wrap(api.ResendVerification(r.store)),
+	)).Methods(http.MethodPost)
+
+	// Users who forgot the master password authorize the reset with the emailed link
+	r.router.Handle("/api/auth/recover", n.With(
+		LimitHandler(),
+		negroni.Wrap(api.RecoverPassword(r.store)),
+	)).Methods(http.MethodPost)
+	r.router.Handle("/api/auth/reset", n.With(
+		LimitHandler(),
+		negroni.Wrap(api.ResetPassword(r.store)),
+	)).Methods(http.MethodPost)
+
 	r.router.PathPrefix("/api").Handler(n.With(
 		Auth(r.store),
+		Audit(r.store),
 		negroni.Wrap(apiRouter),
 	))
 
+	r.router.PathPrefix("/admin").Handler(n.With(
+		Auth(r.store),
+		negroni.Wrap(adminRouter),
+	))
+
 	r.router.PathPrefix("/auth").Handler(n.With(
 		LimitHandler(),
 		negroni.Wrap(authRouter),
 	))
 
+	r.router.PathPrefix("/oauth").Handler(n.With(
+		LimitHandler(),
+		negroni.Wrap(oauthRouter),
+	))
+
+	r.router.Handle("/audit/verify", n.With(
+		Auth(r.store),
+		negroni.Wrap(api.VerifyAuditLog(r.store)),
+	)).Methods(http.MethodGet)
+
+	// Export downloads are authorized by the signature of their link
+	r.router.Handle("/export/download/{id}", n.With(
+		LimitHandler(),
+		negroni.Wrap(api.DownloadExport(r.store)),
+	)).Methods(http.MethodGet)
+
+	// Runtime profiles are off unless the operator turns them on, then admins read them
+	if viper.GetBool("server.pprof") {
+		r.router.PathPrefix("/debug/pprof").Handler(n.With(
+			Auth(r.store),
+			negroni.Wrap(api.Profiles()),
+		)).Methods(http.MethodGet)
+	}
+
+	// Machine accounts authenticate with their own tokens
+	r.router.Handle("/inject", n.With(
+		negroni.Wrap(api.Inject(r.store)),
+	)).Methods(http.MethodGet)
+	r.router.Handle("/k8s/secrets", n.With(
+		negroni.Wrap(api.K8sSecrets(r.store)),
+	)).Methods(http.MethodGet)
+
 	// Insecure endpoints
+	r.router.HandleFunc("/.well-known/openid-configuration", api.OIDCDiscovery).Methods(http.MethodGet)
 	r.router.HandleFunc("/health", api.HealthCheck(r.store)).Methods(http.MethodGet)
+	r.router.HandleFunc("/healthz", api.Liveness).Methods(http.MethodGet)
+	r.router.HandleFunc("/readyz", api.Readiness(r.store)).Methods(http.MethodGet)
 	// r.router.HandleFunc("/check-update/{product:[0-9]+}", api.CheckUpdate).Methods(http.MethodGet)
 
 }
@@ -0,0 +1,109 @@
+package router_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/passwall/passwall-server/pkg/client"
+	"github.com/passwall/passwall-server/pkg/servertest"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(t *testing.T) (*servertest.Server, *client.Client) {
+	srv, err := servertest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := srv.CreateUser("Test", "test@passwall.io", "master-password"); err != nil {
+		t.Fatal(err)
+	}
+
+	c := client.New(srv.URL)
+	if err := c.Signin("test@passwall.io", "master-password"); err != nil {
+		t.Fatal(err)
+	}
+	return srv, c
+}
+
+// itemPath is the API path of the item, like /api/logins/3
+func itemPath(itemType string, id uint) string {
+	return "/api/" + itemType + "/" + strconv.FormatUint(uint64(id), 10)
+}
+
+func TestRequestID(t *testing.T) {
+	srv, err := servertest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	// Ids of the caller are sent back, others get a new one
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/logins", nil)
+	req.Header.Set("X-Request-ID", "lb-4bf92f35")
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, "lb-4bf92f35", resp.Header.Get("X-Request-ID"))
+
+	req.Header.Set("X-Request-ID", "not valid")
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Len(t, resp.Header.Get("X-Request-ID"), 32)
+}
+
+func TestProbes(t *testing.T) {
+	srv, err := servertest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	for path, want := range map[string]string{
+		"/healthz": `{"status":"ok"}`,
+		"/readyz":  `"status":"ok"`,
+	} {
+		resp, err := http.Get(srv.URL + path)
+		assert.NoError(t, err)
+		body, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode, path)
+		assert.Contains(t, string(body), want)
+	}
+}
+
+func TestProfiles(t *testing.T) {
+	viper.Set("server.pprof", true)
+	srv, _ := newTestClient(t)
+	viper.Set("server.pprof", false)
+	defer srv.Close()
+
+	session, err := srv.Signin("test@passwall.io", "master-password")
+	assert.NoError(t, err)
+	code, err := srv.Do(session, http.MethodGet, "/debug/pprof/heap?debug=1", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusForbidden, code)
+
+	user, _ := srv.Store.Users().FindByEmail("test@passwall.io")
+	user.Role = "Admin"
+	srv.Store.Users().Save(user)
+	session, err = srv.Signin("test@passwall.io", "master-password")
+	assert.NoError(t, err)
+	code, err = srv.Do(session, http.MethodGet, "/debug/pprof/heap?debug=1", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	resp, err := http.Get(srv.URL + "/debug/pprof/")
+	assert.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+
+	// Servers without the flag have no profiles
+	other, _ := newTestClient(t)
+	defer other.Close()
+	code, err = other.Do(session, http.MethodGet, "/debug/pprof/heap", nil, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, code)
+}
@@ -0,0 +1,275 @@
+package router_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/app/saml/samltest"
+	"github.com/passwall/passwall-server/model"
+	"github.com/passwall/passwall-server/pkg/client"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeIdP is an OpenID Connect provider which issues an id token with the claims for every code
+type fakeIdP struct {
+	*httptest.Server
+	key    *rsa.PrivateKey
+	claims jwt.MapClaims
+	codes  map[string]url.Values // query of the authorization request of the code
+}
+
+func newFakeIdP(t *testing.T) *fakeIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	idp := &fakeIdP{key: key, codes: map[string]url.Values{}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(model.OIDCDiscoveryDTO{
+			Issuer:                idp.URL,
+			AuthorizationEndpoint: idp.URL + "/authorize",
+			TokenEndpoint:         idp.URL + "/token",
+			JWKSURI:               idp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(model.JWKSDTO{Keys: []model.JWKDTO{{
+			KeyType:  "RSA",
+			Use:      "sig",
+			KeyID:    "idp",
+			Modulus:  base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			Exponent: base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		authorization, ok := idp.codes[r.FormValue("code")]
+		delete(idp.codes, r.FormValue("code"))
+		verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if !ok || id != "passwall" || secret != "idp-secret" ||
+			authorization.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(verifier[:]) {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+		claims := jwt.MapClaims{"iss": idp.URL, "aud": "passwall", "exp": time.Now().Add(time.Minute).Unix(), "nonce": authorization.Get("nonce")}
+		for k, v := range idp.claims {
+			claims[k] = v
+		}
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "idp"
+		signed, _ := token.SignedString(idp.key)
+		json.NewEncoder(w).Encode(model.OIDCTokenDTO{IDToken: signed, TokenType: "Bearer"})
+	})
+	idp.Server = httptest.NewServer(mux)
+	return idp
+}
+
+// authorize signs in at the provider like a browser and returns the code of the redirect
+func (idp *fakeIdP) authorize(t *testing.T, authorization *model.SSOAuthorizationDTO) string {
+	u, err := url.Parse(authorization.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, authorization.State, u.Query().Get("state"))
+	code := strconv.Itoa(len(idp.codes)+1) + authorization.State
+	idp.codes[code] = u.Query()
+	return code
+}
+
+func TestSSO(t *testing.T) {
+	srv, _ := newTestClient(t)
+	defer srv.Close()
+	idp := newFakeIdP(t)
+	defer idp.Close()
+	viper.Set("sso.providers", []map[string]interface{}{{
+		"id": "keycloak", "name": "Keycloak", "issuer": idp.URL,
+		"clientID": "passwall", "clientSecret": "idp-secret", "redirectURL": "https://vault.passwall.io/sso",
+	}})
+	defer viper.Set("sso.providers", nil)
+
+	c := client.New(srv.URL)
+	providers, err := c.SSOProviders()
+	assert.NoError(t, err)
+	assert.Equal(t, []model.SSOProviderDTO{{ID: "keycloak", Name: "Keycloak", Protocol: "oidc"}}, providers)
+	_, err = c.BeginSSO("okta")
+	assert.Equal(t, http.StatusNotFound, err.(*client.Error).StatusCode)
+
+	signin := func() error {
+		// The auth endpoints allow 5 requests a second of a client
+		time.Sleep(400 * time.Millisecond)
+		authorization, err := c.BeginSSO("keycloak")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		return c.SigninSSO("keycloak", idp.authorize(t, authorization), authorization.State)
+	}
+
+	// Unverified emails don't link the account to a user
+	idp.claims = jwt.MapClaims{"sub": "user-1", "email": "test@passwall.io", "email_verified": false}
+	err = signin()
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+
+	idp.claims["email_verified"] = true
+	assert.NoError(t, signin())
+	assert.Equal(t, "test@passwall.io", c.Session().Email)
+	_, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+
+	// The subject stays linked when the email changes at the provider
+	idp.claims = jwt.MapClaims{"sub": "user-1", "email": "renamed@example.com"}
+	assert.NoError(t, signin())
+	assert.Equal(t, "test@passwall.io", c.Session().Email)
+
+	// A state is used once and a code only works with the verifier of its sign in
+	time.Sleep(time.Second)
+	authorization, err := c.BeginSSO("keycloak")
+	assert.NoError(t, err)
+	code := idp.authorize(t, authorization)
+	other, err := c.BeginSSO("keycloak")
+	assert.NoError(t, err)
+	err = c.SigninSSO("keycloak", code, other.State)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+	err = c.SigninSSO("keycloak", code, other.State)
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+
+	// Users with a second factor still verify it
+	enrollment, err := c.EnrollTOTP()
+	assert.NoError(t, err)
+	totp, _ := app.TOTPCode(enrollment.Secret, time.Now())
+	assert.NoError(t, c.EnableTOTP(totp))
+	_, ok := signin().(*client.TwoFactorRequiredError)
+	assert.True(t, ok)
+}
+
+func TestSAML(t *testing.T) {
+	srv, _ := newTestClient(t)
+	defer srv.Close()
+	idp, err := samltest.New("https://idp.corp.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := ioutil.TempFile("", "idp-*.pem")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(certificate.Name())
+	certificate.Write(idp.CertificatePEM())
+	certificate.Close()
+
+	viper.Set("server.domain", srv.URL)
+	defer viper.Set("server.domain", "")
+	provider := map[string]interface{}{
+		"id": "corp", "name": "Corp", "idpEntityID": idp.EntityID, "idpSSOURL": "https://idp.corp.example.com/sso",
+		"idpCertificate": certificate.Name(), "redirectURL": "https://vault.passwall.io/saml",
+	}
+	viper.Set("sso.saml", []map[string]interface{}{provider})
+	defer viper.Set("sso.saml", nil)
+	acsURL := srv.URL + "/auth/saml/corp/acs"
+
+	c := client.New(srv.URL)
+	providers, err := c.SSOProviders()
+	assert.NoError(t, err)
+	assert.Equal(t, []model.SSOProviderDTO{{ID: "corp", Name: "Corp", Protocol: "saml"}}, providers)
+
+	resp, err := http.Get(srv.URL + "/auth/saml/corp/metadata")
+	assert.NoError(t, err)
+	metadata, _ := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(metadata), `entityID="`+srv.URL+`/auth/saml/corp/metadata"`)
+	assert.Contains(t, string(metadata), `Location="`+acsURL+`"`)
+
+	// The browser posts the response to the ACS and follows its redirect to the client
+	browser := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	post := func(response []byte, relayState string) (*http.Response, error) {
+		return browser.PostForm(acsURL, url.Values{"SAMLResponse": {base64.StdEncoding.EncodeToString(response)}, "RelayState": {relayState}})
+	}
+	respond := func(nameID string, attributes map[string]string) ([]byte, string) {
+		// The auth endpoints allow 5 requests a second of a client
+		time.Sleep(700 * time.Millisecond)
+		authorization, err := c.BeginSAML("corp")
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		id, relayState, err := idp.ParseRequest(authorization.URL)
+		assert.NoError(t, err)
+		assert.Equal(t, authorization.State, relayState)
+		response, err := idp.Respond(&samltest.Response{
+			ACSURL: acsURL, Audience: srv.URL + "/auth/saml/corp/metadata", InResponseTo: id, NameID: nameID, Attributes: attributes,
+		})
+		assert.NoError(t, err)
+		return response, relayState
+	}
+	signin := func(nameID string, attributes map[string]string) error {
+		response, relayState := respond(nameID, attributes)
+		resp, err := post(response, relayState)
+		if !assert.NoError(t, err) {
+			t.FailNow()
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusSeeOther {
+			return &client.Error{StatusCode: resp.StatusCode}
+		}
+		redirect, err := url.Parse(resp.Header.Get("Location"))
+		assert.NoError(t, err)
+		assert.Equal(t, "vault.passwall.io", redirect.Host)
+		assert.Equal(t, relayState, redirect.Query().Get("state"))
+		return c.SigninSAML("corp", redirect.Query().Get("code"), redirect.Query().Get("state"))
+	}
+
+	// Users without an account are only created when the provider is allowed to
+	err = signin("jane", map[string]string{"mail": "jane@corp.example.com", "displayName": "Jane"})
+	assert.Equal(t, http.StatusUnauthorized, err.(*client.Error).StatusCode)
+
+	// The name id is linked to the user with the email on the first sign in
+	assert.NoError(t, signin("tester", map[string]string{"email": "test@passwall.io"}))
+	assert.Equal(t, "test@passwall.io", c.Session().Email)
+	_, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+	assert.NoError(t, signin("tester", map[string]string{"email": "renamed@corp.example.com"}))
+	assert.Equal(t, "test@passwall.io", c.Session().Email)
+
+	provider["createUsers"] = true
+	viper.Set("sso.saml", []map[string]interface{}{provider})
+	assert.NoError(t, signin("jane", map[string]string{"mail": "jane@corp.example.com", "displayName": "Jane"}))
+	assert.Equal(t, "jane@corp.example.com", c.Session().Email)
+	_, err = c.ListLogins(nil)
+	assert.NoError(t, err)
+	jane, err := srv.Store.Users().FindByEmail("jane@corp.example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, "Jane", jane.Name)
+	assert.False(t, jane.EmailVerifiedAt.IsZero())
+
+	// Responses are accepted once, for a request of the server and with its relay state
+	response, relayState := respond("jane", nil)
+	resp, err = post(response, "other")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	resp, err = post(response, relayState)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	time.Sleep(time.Second)
+	unsolicited, err := idp.Respond(&samltest.Response{ACSURL: acsURL, Audience: srv.URL + "/auth/saml/corp/metadata", NameID: "jane"})
+	assert.NoError(t, err)
+	resp, err = post(unsolicited, "")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+}
@@ -0,0 +1,230 @@
+package router_test
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/rotation"
+	"github.com/passwall/passwall-server/model"
+	"github.com/passwall/passwall-server/pkg/client"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+func TestSync(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub"})
+	assert.NoError(t, err)
+	note, err := c.CreateNote(&model.NoteDTO{Title: "VPN"})
+	assert.NoError(t, err)
+
+	full, err := c.Sync("")
+	assert.NoError(t, err)
+	assert.Len(t, full.Changed, 2)
+	assert.Empty(t, full.Deleted)
+	assert.False(t, full.Reset)
+	assert.NotEmpty(t, full.Token)
+	assert.NotZero(t, full.Revision)
+
+	// Nothing changed since the token
+	delta, err := c.Sync(full.Token)
+	assert.NoError(t, err)
+	assert.Empty(t, delta.Changed)
+	assert.Equal(t, full.Token, delta.Token)
+
+	_, err = c.UpdateLogin(login.ID, &model.LoginDTO{Title: "GitHub Enterprise"})
+	assert.NoError(t, err)
+	assert.NoError(t, c.DeleteNote(note.ID))
+	_, err = c.CreateServer(&model.ServerDTO{Title: "build"})
+	assert.NoError(t, err)
+
+	delta, err = c.Sync(full.Token)
+	assert.NoError(t, err)
+	types := []string{}
+	for _, item := range delta.Changed {
+		types = append(types, item.Type)
+	}
+	assert.ElementsMatch(t, []string{client.LoginItem, client.ServerItem}, types)
+	assert.Equal(t, []model.ItemRefDTO{{Type: client.NoteItem, ID: note.ID}}, delta.Deleted)
+	assert.NotEqual(t, full.Token, delta.Token)
+	assert.True(t, delta.Revision >= full.Revision)
+
+	// Clients of the sync before tokens still sync since a revision, the times they
+	// have start over
+	var since model.SyncDTO
+	_, err = srv.Do(c.Session(), http.MethodGet, "/api/sync?since="+strconv.FormatInt(full.Revision, 10), nil, &since)
+	assert.NoError(t, err)
+	assert.Equal(t, delta, &since)
+	_, err = srv.Do(c.Session(), http.MethodGet, "/api/sync?since="+strconv.FormatInt(time.Now().UnixNano()/1000, 10), nil, &since)
+	assert.NoError(t, err)
+	assert.True(t, since.Reset)
+	assert.Len(t, since.Changed, 2)
+
+	// Restored items are changed again
+	assert.NoError(t, c.RestoreItem(client.NoteItem, note.ID))
+	restored, err := c.Sync(delta.Token)
+	assert.NoError(t, err)
+	if assert.Len(t, restored.Changed, 1) {
+		assert.Equal(t, client.NoteItem, restored.Changed[0].Type)
+	}
+	assert.Empty(t, restored.Deleted)
+
+	// Deleting a folder changes its items
+	folder, err := c.CreateFolder(&model.FolderDTO{Name: "Work"})
+	assert.NoError(t, err)
+	assert.NoError(t, c.MoveItems(folder.ID, []model.ItemRefDTO{{Type: client.LoginItem, ID: login.ID}}))
+	filed, err := c.Sync(restored.Token)
+	assert.NoError(t, err)
+	assert.NoError(t, c.DeleteFolder(folder.ID))
+	unfiled, err := c.Sync(filed.Token)
+	assert.NoError(t, err)
+	if assert.Len(t, unfiled.Changed, 1) {
+		assert.Equal(t, client.LoginItem, unfiled.Changed[0].Type)
+	}
+
+	// Tokens of another counter, or from before a purge, start over with all items
+	epoch := full.Token[:strings.LastIndex(full.Token, ".")]
+	for _, token := range []string{"0123456789abcdef.1", epoch + ".1000000"} {
+		reset, err := c.Sync(token)
+		assert.NoError(t, err)
+		assert.True(t, reset.Reset)
+		assert.Len(t, reset.Changed, 3)
+	}
+	assert.NoError(t, c.DeleteNote(note.ID))
+	assert.NoError(t, c.PurgeItem(client.NoteItem, note.ID))
+	reset, err := c.Sync(restored.Token)
+	assert.NoError(t, err)
+	assert.True(t, reset.Reset)
+	assert.Len(t, reset.Changed, 2)
+	after, err := c.Sync(reset.Token)
+	assert.NoError(t, err)
+	assert.False(t, after.Reset)
+
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/sync?token=yesterday", nil)
+	req.Header.Set("Authorization", "Bearer "+c.Session().AccessToken)
+	resp, err := http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	}
+	code, _ := srv.Do(c.Session(), http.MethodGet, "/api/sync?since=yesterday", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+type pushProvider struct{}
+
+func (pushProvider) Rotate(ctx context.Context, c *rotation.Credential) (*rotation.Credential, error) {
+	rotated := *c
+	rotated.Password = c.Password + "-rotated"
+	return &rotated, nil
+}
+
+func TestWatchChanges(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	// Another device of the user is told about the edits
+	device := client.New(srv.URL)
+	assert.NoError(t, device.Signin("test@passwall.io", "master-password"))
+	changes, err := device.WatchChanges()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer changes.Close()
+
+	login, err := c.CreateLogin(&model.LoginDTO{Title: "GitHub"})
+	assert.NoError(t, err)
+	change, err := changes.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ChangeDTO{Type: client.LoginItem, ID: login.ID, Operation: "create"}, change)
+
+	_, err = c.UpdateLogin(login.ID, &model.LoginDTO{Title: "GitHub Enterprise"})
+	assert.NoError(t, err)
+	change, err = changes.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ChangeDTO{Type: client.LoginItem, ID: login.ID, Operation: "update"}, change)
+
+	// Rotations of the background job are pushed too
+	rotation.Register("push", pushProvider{})
+	_, err = c.UpdateLogin(login.ID, &model.LoginDTO{Title: "GitHub Enterprise", Password: "hunter2", RotationProvider: "push", RotationPeriod: "1h"})
+	assert.NoError(t, err)
+	_, err = changes.Next()
+	assert.NoError(t, err)
+	row, err := srv.Store.Logins().FindByID(login.ID, "user1")
+	if !assert.NoError(t, err) {
+		return
+	}
+	row.CreatedAt = time.Now().Add(-2 * time.Hour)
+	_, err = srv.Store.Logins().Save(row, "user1")
+	assert.NoError(t, err)
+	app.RotateDueLogins(srv.Store)
+	change, err = changes.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ChangeDTO{Type: client.LoginItem, ID: login.ID, Operation: "update"}, change)
+
+	// Reads aren't changes
+	_, err = c.GetLogin(login.ID)
+	assert.NoError(t, err)
+	assert.NoError(t, c.DeleteLogin(login.ID))
+	change, err = changes.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ChangeDTO{Type: client.LoginItem, ID: login.ID, Operation: "delete"}, change)
+
+	// Browsers send the token in the query
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/api/changes"
+	_, err = websocket.Dial(wsURL, "", srv.URL)
+	assert.Error(t, err)
+	ws, err := websocket.Dial(wsURL+"?access_token="+c.Session().AccessToken, "", srv.URL)
+	if assert.NoError(t, err) {
+		ws.Close()
+	}
+}
+
+func TestStreamChanges(t *testing.T) {
+	srv, c := newTestClient(t)
+	defer srv.Close()
+
+	device := client.New(srv.URL)
+	assert.NoError(t, device.Signin("test@passwall.io", "master-password"))
+	srv.Config.WriteTimeout = time.Second
+	changes, err := device.StreamChanges()
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer changes.Close()
+
+	note, err := c.CreateNote(&model.NoteDTO{Title: "VPN"})
+	assert.NoError(t, err)
+	change, err := changes.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ChangeDTO{Type: client.NoteItem, ID: note.ID, Operation: "create"}, change)
+
+	// The stream outlives the write timeout of the server
+	time.Sleep(1100 * time.Millisecond)
+	assert.NoError(t, c.DeleteNote(note.ID))
+	change, err = changes.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, &model.ChangeDTO{Type: client.NoteItem, ID: note.ID, Operation: "delete"}, change)
+
+	// EventSource sends the token in the query
+	resp, err := http.Get(srv.URL + "/api/changes/events")
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+	}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL+"/api/changes/events?access_token="+c.Session().AccessToken, nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err = http.DefaultClient.Do(req)
+	if assert.NoError(t, err) {
+		resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+	}
+}
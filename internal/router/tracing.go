@@ -0,0 +1,39 @@
+package router
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/tracing"
+	"github.com/urfave/negroni"
+)
+
+// Trace records the server span of each request, it continues the trace of the
+// traceparent header of the caller
+func Trace(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
+	span, r := tracing.StartRequest(r, tracing.SpanName(r))
+	if span == nil {
+		next(w, r)
+		return
+	}
+	defer span.End()
+
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.target", r.URL.Path)
+	span.SetAttribute("http.user_agent", r.UserAgent())
+	if id, ok := r.Context().Value("requestID").(string); ok {
+		span.SetAttribute("http.request_id", id)
+	}
+	if ip := app.ClientIP(r); ip != nil {
+		span.SetAttribute("net.peer.ip", ip.String())
+	}
+
+	rw := negroni.NewResponseWriter(w)
+	next(rw, r)
+
+	span.SetAttribute("http.status_code", rw.Status())
+	if rw.Status() >= http.StatusInternalServerError {
+		span.SetError(errors.New(http.StatusText(rw.Status())))
+	}
+}
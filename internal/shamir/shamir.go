@@ -0,0 +1,135 @@
+// Package shamir splits a secret into shares with Shamir's secret sharing over GF(256).
+// Any threshold of the shares recovers the secret, fewer shares tell nothing about it.
+//
+// A share is one byte longer than the secret. Each byte of the secret is the constant
+// of its own random polynomial, a share has the values of all polynomials at the
+// x coordinate in its last byte.
+package shamir
+
+import (
+	"crypto/rand"
+	"errors"
+)
+
+var (
+	errThreshold   = errors.New("threshold should be at least 2 and at most the number of shares")
+	errShareCount  = errors.New("number of shares should be at most 255")
+	errEmptySecret = errors.New("secret is empty")
+	errFewShares   = errors.New("at least 2 shares are required")
+	errShareLength = errors.New("shares should have the same length")
+	errDuplicate   = errors.New("shares should be different")
+)
+
+// Split splits the secret into n shares, any k of them recover it
+func Split(secret []byte, n, k int) ([][]byte, error) {
+	if k < 2 || k > n {
+		return nil, errThreshold
+	}
+	if n > 255 {
+		return nil, errShareCount
+	}
+	if len(secret) == 0 {
+		return nil, errEmptySecret
+	}
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, len(secret)+1)
+		shares[i][len(secret)] = byte(i + 1)
+	}
+
+	coefficients := make([]byte, k-1)
+	for b, value := range secret {
+		if _, err := rand.Read(coefficients); err != nil {
+			return nil, err
+		}
+		for i := range shares {
+			shares[i][b] = evaluate(value, coefficients, byte(i+1))
+		}
+	}
+	return shares, nil
+}
+
+// Combine recovers the secret from the shares. It can't tell if there are too few
+// shares or they belong to different secrets, the result is a wrong secret then.
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) < 2 {
+		return nil, errFewShares
+	}
+
+	length := len(shares[0])
+	xs := map[byte]bool{}
+	for _, share := range shares {
+		if len(share) != length || length < 2 {
+			return nil, errShareLength
+		}
+		x := share[length-1]
+		if xs[x] || x == 0 {
+			return nil, errDuplicate
+		}
+		xs[x] = true
+	}
+
+	// Lagrange interpolation at x=0, addition and subtraction are xor in GF(256)
+	secret := make([]byte, length-1)
+	for i, share := range shares {
+		xi := share[length-1]
+		basis := byte(1)
+		for j, other := range shares {
+			if i == j {
+				continue
+			}
+			xj := other[length-1]
+			basis = mul(basis, div(xj, xj^xi))
+		}
+		for b := range secret {
+			secret[b] ^= mul(share[b], basis)
+		}
+	}
+	return secret, nil
+}
+
+// evaluate returns the value of the polynomial with the constant and coefficients at x
+func evaluate(constant byte, coefficients []byte, x byte) byte {
+	result := byte(0)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = mul(result, x) ^ coefficients[i]
+	}
+	return mul(result, x) ^ constant
+}
+
+var expTable, logTable = tables()
+
+// tables builds the exponent and logarithm tables of GF(256) with the AES polynomial
+// x^8 + x^4 + x^3 + x + 1 and the generator 3
+func tables() ([255]byte, [256]byte) {
+	var exp [255]byte
+	var log [256]byte
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		exp[i] = x
+		log[x] = byte(i)
+		// x *= 3
+		high := x & 0x80
+		x2 := x << 1
+		if high != 0 {
+			x2 ^= 0x1b
+		}
+		x ^= x2
+	}
+	return exp, log
+}
+
+func mul(a, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])+int(logTable[b]))%255]
+}
+
+func div(a, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[(int(logTable[a])-int(logTable[b])+255)%255]
+}
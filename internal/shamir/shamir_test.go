@@ -0,0 +1,59 @@
+package shamir
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitCombine(t *testing.T) {
+	secret := []byte("passphrase-for-encrypting-passwords")
+
+	shares, err := Split(secret, 5, 3)
+	assert.NoError(t, err)
+	assert.Len(t, shares, 5)
+
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		picked := [][]byte{}
+		for _, i := range subset {
+			picked = append(picked, shares[i])
+		}
+		combined, err := Combine(picked)
+		assert.NoError(t, err)
+		assert.Equal(t, secret, combined)
+	}
+
+	// Below the threshold the result is garbage
+	combined, err := Combine(shares[:2])
+	assert.NoError(t, err)
+	assert.NotEqual(t, secret, combined)
+}
+
+func TestSplitErrors(t *testing.T) {
+	_, err := Split([]byte("key"), 3, 1)
+	assert.Equal(t, errThreshold, err)
+	_, err = Split([]byte("key"), 2, 3)
+	assert.Equal(t, errThreshold, err)
+	_, err = Split([]byte("key"), 256, 3)
+	assert.Equal(t, errShareCount, err)
+	_, err = Split(nil, 3, 2)
+	assert.Equal(t, errEmptySecret, err)
+
+	shares, _ := Split([]byte("key"), 3, 2)
+	_, err = Combine(shares[:1])
+	assert.Equal(t, errFewShares, err)
+	_, err = Combine([][]byte{shares[0], shares[0]})
+	assert.Equal(t, errDuplicate, err)
+	_, err = Combine([][]byte{shares[0], shares[1][1:]})
+	assert.Equal(t, errShareLength, err)
+}
+
+func TestField(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			assert.Equal(t, byte(a), div(mul(byte(a), byte(b)), byte(b)))
+		}
+	}
+	// 0x53 and 0xca are inverses in the AES field
+	assert.Equal(t, byte(1), mul(0x53, 0xca))
+}
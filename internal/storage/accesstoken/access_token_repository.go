@@ -0,0 +1,55 @@
+package accesstoken
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindAllByUserID ...
+func (p *Repository) FindAllByUserID(userID uint) ([]model.PersonalAccessToken, error) {
+	tokens := []model.PersonalAccessToken{}
+	err := p.db.Where(`user_id = ?`, userID).Order("id").Find(&tokens).Error
+	return tokens, err
+}
+
+// FindByID ...
+func (p *Repository) FindByID(id uint) (*model.PersonalAccessToken, error) {
+	token := new(model.PersonalAccessToken)
+	err := p.db.Where(`id = ?`, id).First(&token).Error
+	return token, err
+}
+
+// FindByHash ...
+func (p *Repository) FindByHash(hash string) (*model.PersonalAccessToken, error) {
+	token := new(model.PersonalAccessToken)
+	err := p.db.Where(`hash = ?`, hash).First(&token).Error
+	return token, err
+}
+
+// Save ...
+func (p *Repository) Save(token *model.PersonalAccessToken) (*model.PersonalAccessToken, error) {
+	err := p.db.Save(&token).Error
+	return token, err
+}
+
+// Delete ...
+func (p *Repository) Delete(id uint) error {
+	err := p.db.Delete(&model.PersonalAccessToken{ID: id}).Error
+	return err
+}
+
+// DeleteByUserID ...
+func (p *Repository) DeleteByUserID(userID uint) error {
+	err := p.db.Where(`user_id = ?`, userID).Delete(&model.PersonalAccessToken{}).Error
+	return err
+}
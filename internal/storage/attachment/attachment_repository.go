@@ -0,0 +1,63 @@
+package attachment
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// All ...
+func (p *Repository) All(schema string) ([]model.Attachment, error) {
+	attachments := []model.Attachment{}
+	err := p.db.Table(schema + ".attachments").Order("id").Find(&attachments).Error
+	return attachments, err
+}
+
+// FindByItem ...
+func (p *Repository) FindByItem(itemType string, itemID uint, schema string) ([]model.Attachment, error) {
+	attachments := []model.Attachment{}
+	err := p.db.Table(schema+".attachments").Where(`item_type = ? AND item_id = ?`, itemType, itemID).Order("id").Find(&attachments).Error
+	return attachments, err
+}
+
+// FindByID ...
+func (p *Repository) FindByID(id uint, schema string) (*model.Attachment, error) {
+	attachment := new(model.Attachment)
+	err := p.db.Table(schema+".attachments").Where(`id = ?`, id).First(&attachment).Error
+	return attachment, err
+}
+
+// FindOrphans ...
+func (p *Repository) FindOrphans(itemType, itemTable, schema string) ([]model.Attachment, error) {
+	attachments := []model.Attachment{}
+	err := p.db.Table(schema+".attachments").Where(`item_type = ? AND item_id NOT IN (SELECT id FROM `+itemTable+`)`, itemType).Find(&attachments).Error
+	return attachments, err
+}
+
+// TotalSize ...
+func (p *Repository) TotalSize(schema string) (int64, error) {
+	var total struct {
+		Size int64
+	}
+	err := p.db.Table(schema + ".attachments").Select("COALESCE(SUM(size), 0) AS size").Scan(&total).Error
+	return total.Size, err
+}
+
+// Create ...
+func (p *Repository) Create(attachment *model.Attachment, schema string) error {
+	return p.db.Table(schema + ".attachments").Create(attachment).Error
+}
+
+// Delete ...
+func (p *Repository) Delete(id uint, schema string) error {
+	return p.db.Table(schema+".attachments").Where(`id = ?`, id).Delete(&model.Attachment{}).Error
+}
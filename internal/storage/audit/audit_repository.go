@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// All ...
+func (p *Repository) All() ([]model.AuditLog, error) {
+	entries := []model.AuditLog{}
+	err := p.db.Order("id asc").Find(&entries).Error
+	return entries, err
+}
+
+// Last ...
+func (p *Repository) Last() (*model.AuditLog, error) {
+	entry := new(model.AuditLog)
+	err := p.db.Order("id desc").First(&entry).Error
+	return entry, err
+}
+
+// Create ...
+func (p *Repository) Create(entry *model.AuditLog) (*model.AuditLog, error) {
+	err := p.db.Create(&entry).Error
+	return entry, err
+}
+
+// Checkpoints ...
+func (p *Repository) Checkpoints() ([]model.AuditCheckpoint, error) {
+	checkpoints := []model.AuditCheckpoint{}
+	err := p.db.Order("id asc").Find(&checkpoints).Error
+	return checkpoints, err
+}
+
+// LastCheckpoint ...
+func (p *Repository) LastCheckpoint() (*model.AuditCheckpoint, error) {
+	checkpoint := new(model.AuditCheckpoint)
+	err := p.db.Order("id desc").First(&checkpoint).Error
+	return checkpoint, err
+}
+
+// CreateCheckpoint ...
+func (p *Repository) CreateCheckpoint(checkpoint *model.AuditCheckpoint) (*model.AuditCheckpoint, error) {
+	err := p.db.Create(&checkpoint).Error
+	return checkpoint, err
+}
+
+// LastBefore ...
+func (p *Repository) LastBefore(before time.Time) (*model.AuditLog, error) {
+	entry := new(model.AuditLog)
+	err := p.db.Where(`created_at < ?`, before).Order("id desc").First(&entry).Error
+	return entry, err
+}
+
+// CountUntil ...
+func (p *Repository) CountUntil(id uint) (int, error) {
+	count := 0
+	err := p.db.Model(&model.AuditLog{}).Where(`id <= ?`, id).Count(&count).Error
+	return count, err
+}
+
+// DeleteUntil ...
+func (p *Repository) DeleteUntil(id uint) error {
+	return p.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where(`id <= ?`, id).Delete(&model.AuditLog{}).Error; err != nil {
+			return err
+		}
+		return tx.Where(`audit_log_id < ?`, id).Delete(&model.AuditCheckpoint{}).Error
+	})
+}
@@ -0,0 +1,57 @@
+package auditevent
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindAll ...
+func (p *Repository) FindAll(filter *model.AuditEventFilter, schema string) ([]model.AuditEvent, error) {
+	events := []model.AuditEvent{}
+
+	query := p.filter(filter, schema)
+	if filter.Limit > 0 {
+		// offset can't be declared without a valid limit
+		query = query.Limit(filter.Limit).Offset(filter.Offset)
+	}
+
+	err := query.Order("id desc").Find(&events).Error
+	return events, err
+}
+
+// FindAfter ...
+func (p *Repository) FindAfter(filter *model.AuditEventFilter, afterID uint, schema string) ([]model.AuditEvent, error) {
+	events := []model.AuditEvent{}
+	err := p.filter(filter, schema).Where(`id > ?`, afterID).Order("id asc").Limit(filter.Limit).Find(&events).Error
+	return events, err
+}
+
+// Create ...
+func (p *Repository) Create(event *model.AuditEvent, schema string) (*model.AuditEvent, error) {
+	err := p.db.Table(schema + ".audit_logs").Create(&event).Error
+	return event, err
+}
+
+func (p *Repository) filter(filter *model.AuditEventFilter, schema string) *gorm.DB {
+	query := p.db.Table(schema + ".audit_logs")
+	if !filter.Since.IsZero() {
+		query = query.Where(`created_at >= ?`, filter.Since)
+	}
+	if !filter.Until.IsZero() {
+		query = query.Where(`created_at < ?`, filter.Until)
+	}
+	if filter.Action != "" {
+		query = query.Where(`action = ?`, filter.Action)
+	}
+	return query
+}
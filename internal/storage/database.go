@@ -1,64 +1,138 @@
 package storage
 
 import (
-	"fmt"
+	"errors"
 
 	"github.com/jinzhu/gorm"
-	_ "github.com/jinzhu/gorm/dialects/postgres"
 	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/logging"
+	"github.com/passwall/passwall-server/internal/storage/accesstoken"
+	"github.com/passwall/passwall-server/internal/storage/attachment"
+	"github.com/passwall/passwall-server/internal/storage/audit"
+	"github.com/passwall/passwall-server/internal/storage/auditevent"
 	"github.com/passwall/passwall-server/internal/storage/bankaccount"
 	"github.com/passwall/passwall-server/internal/storage/creditcard"
 	"github.com/passwall/passwall-server/internal/storage/email"
+	"github.com/passwall/passwall-server/internal/storage/equivalentdomain"
+	"github.com/passwall/passwall-server/internal/storage/exportjob"
+	"github.com/passwall/passwall-server/internal/storage/fieldcipher"
+	"github.com/passwall/passwall-server/internal/storage/folder"
+	"github.com/passwall/passwall-server/internal/storage/itemversion"
 	"github.com/passwall/passwall-server/internal/storage/login"
+	"github.com/passwall/passwall-server/internal/storage/machineaccount"
 	"github.com/passwall/passwall-server/internal/storage/note"
+	"github.com/passwall/passwall-server/internal/storage/passwordhistory"
+	"github.com/passwall/passwall-server/internal/storage/policy"
+	"github.com/passwall/passwall-server/internal/storage/reencryption"
+	"github.com/passwall/passwall-server/internal/storage/retention"
+	"github.com/passwall/passwall-server/internal/storage/revision"
+	"github.com/passwall/passwall-server/internal/storage/schemamigration"
 	"github.com/passwall/passwall-server/internal/storage/server"
+	"github.com/passwall/passwall-server/internal/storage/signinfailure"
+	"github.com/passwall/passwall-server/internal/storage/ssoidentity"
 	"github.com/passwall/passwall-server/internal/storage/subscription"
+	"github.com/passwall/passwall-server/internal/storage/synccounter"
+	"github.com/passwall/passwall-server/internal/storage/tag"
 	"github.com/passwall/passwall-server/internal/storage/token"
+	"github.com/passwall/passwall-server/internal/storage/trash"
+	"github.com/passwall/passwall-server/internal/storage/trusteddevice"
 	"github.com/passwall/passwall-server/internal/storage/user"
+	"github.com/passwall/passwall-server/internal/storage/webauthncredential"
+	"github.com/passwall/passwall-server/internal/tracing"
 )
 
 // Database is the concrete store provider.
 type Database struct {
 	db            *gorm.DB
 	logins        LoginRepository
+	histories     PasswordHistoryRepository
+	versions      ItemVersionRepository
+	attachments   AttachmentRepository
+	folders       FolderRepository
+	tags          TagRepository
 	cards         CreditCardRepository
 	accounts      BankAccountRepository
 	notes         NoteRepository
 	emails        EmailRepository
+	domains       EquivalentDomainRepository
+	webauthn      WebAuthnCredentialRepository
 	tokens        TokenRepository
 	users         UserRepository
 	servers       ServerRepository
 	subscriptions SubscriptionRepository
+	machines      MachineAccountRepository
+	policies      PolicyRepository
+	identities    SSOIdentityRepository
+	accessTokens  PersonalAccessTokenRepository
+	devices       TrustedDeviceRepository
+	failures      SigninFailureRepository
+	audits        AuditLogRepository
+	events        AuditEventRepository
+	exports       ExportJobRepository
+	retention     RetentionRepository
+	trash         TrashRepository
+	syncCounters  SyncCounterRepository
+	reencryption  ReencryptionRepository
+	migrations    SchemaMigrationRepository
 }
 
-//DBConn databese connection
+//DBConn databese connection with the registered driver of the configuration
 func DBConn(cfg *config.DatabaseConfiguration) (*gorm.DB, error) {
-	var db *gorm.DB
-	var err error
+	driver, err := findDriver(cfg.Driver)
+	if err != nil {
+		return nil, err
+	}
 
-	db, err = gorm.Open("postgres", "host="+cfg.Host+" port="+cfg.Port+" user="+cfg.Username+" dbname="+cfg.Name+"  sslmode=disable password="+cfg.Password)
+	db, err := driver(cfg)
 	if err != nil {
-		return nil, fmt.Errorf("could not open postgresql connection: %w", err)
+		return nil, err
 	}
 
+	db.SetLogger(logging.GormLogger{})
 	db.LogMode(cfg.LogMode)
 
-	return db, err
+	return db, nil
 }
 
-// New opens a database according to configuration.
+// New opens a database according to configuration. The tagged fields of the models
+// are encrypted with the cipher of fieldcipher.Use, the saves of the items are counted
+// in their revisions and the queries of traced requests are recorded as spans.
 func New(db *gorm.DB) *Database {
+	fieldcipher.Register(db)
+	revision.Register(db)
+	tracing.Register(db)
 	return &Database{
 		db:            db,
 		logins:        login.NewRepository(db),
+		histories:     passwordhistory.NewRepository(db),
+		versions:      itemversion.NewRepository(db),
+		attachments:   attachment.NewRepository(db),
+		folders:       folder.NewRepository(db),
+		tags:          tag.NewRepository(db),
 		cards:         creditcard.NewRepository(db),
 		accounts:      bankaccount.NewRepository(db),
 		notes:         note.NewRepository(db),
 		emails:        email.NewRepository(db),
+		domains:       equivalentdomain.NewRepository(db),
+		webauthn:      webauthncredential.NewRepository(db),
 		tokens:        token.NewRepository(db),
 		users:         user.NewRepository(db),
 		servers:       server.NewRepository(db),
 		subscriptions: subscription.NewRepository(db),
+		machines:      machineaccount.NewRepository(db),
+		policies:      policy.NewRepository(db),
+		identities:    ssoidentity.NewRepository(db),
+		accessTokens:  accesstoken.NewRepository(db),
+		devices:       trusteddevice.NewRepository(db),
+		failures:      signinfailure.NewRepository(db),
+		audits:        audit.NewRepository(db),
+		events:        auditevent.NewRepository(db),
+		exports:       exportjob.NewRepository(db),
+		retention:     retention.NewRepository(db),
+		trash:         trash.NewRepository(db),
+		syncCounters:  synccounter.NewRepository(db),
+		reencryption:  reencryption.NewRepository(db),
+		migrations:    schemamigration.NewRepository(db),
 	}
 }
 
@@ -67,6 +141,31 @@ func (db *Database) Logins() LoginRepository {
 	return db.logins
 }
 
+// PasswordHistories returns the PasswordHistoryRepository.
+func (db *Database) PasswordHistories() PasswordHistoryRepository {
+	return db.histories
+}
+
+// ItemVersions returns the ItemVersionRepository.
+func (db *Database) ItemVersions() ItemVersionRepository {
+	return db.versions
+}
+
+// Attachments returns the AttachmentRepository.
+func (db *Database) Attachments() AttachmentRepository {
+	return db.attachments
+}
+
+// Folders returns the FolderRepository.
+func (db *Database) Folders() FolderRepository {
+	return db.folders
+}
+
+// Tags returns the TagRepository.
+func (db *Database) Tags() TagRepository {
+	return db.tags
+}
+
 // CreditCards returns the CreditCardRepository.
 func (db *Database) CreditCards() CreditCardRepository {
 	return db.cards
@@ -87,6 +186,16 @@ func (db *Database) Emails() EmailRepository {
 	return db.emails
 }
 
+// EquivalentDomains returns the EquivalentDomainRepository.
+func (db *Database) EquivalentDomains() EquivalentDomainRepository {
+	return db.domains
+}
+
+// WebAuthnCredentials returns the WebAuthnCredentialRepository.
+func (db *Database) WebAuthnCredentials() WebAuthnCredentialRepository {
+	return db.webauthn
+}
+
 // Tokens returns the TokenRepository.
 func (db *Database) Tokens() TokenRepository {
 	return db.tokens
@@ -107,7 +216,88 @@ func (db *Database) Subscriptions() SubscriptionRepository {
 	return db.subscriptions
 }
 
-// Ping checks if database is up
+// MachineAccounts returns the MachineAccountRepository.
+func (db *Database) MachineAccounts() MachineAccountRepository {
+	return db.machines
+}
+
+// Policies returns the PolicyRepository.
+func (db *Database) Policies() PolicyRepository {
+	return db.policies
+}
+
+// SSOIdentities returns the SSOIdentityRepository.
+func (db *Database) SSOIdentities() SSOIdentityRepository {
+	return db.identities
+}
+
+// AccessTokens returns the PersonalAccessTokenRepository.
+func (db *Database) AccessTokens() PersonalAccessTokenRepository {
+	return db.accessTokens
+}
+
+// TrustedDevices returns the TrustedDeviceRepository.
+func (db *Database) TrustedDevices() TrustedDeviceRepository {
+	return db.devices
+}
+
+// SigninFailures returns the SigninFailureRepository.
+func (db *Database) SigninFailures() SigninFailureRepository {
+	return db.failures
+}
+
+// AuditLogs returns the AuditLogRepository.
+func (db *Database) AuditLogs() AuditLogRepository {
+	return db.audits
+}
+
+// AuditEvents returns the AuditEventRepository.
+func (db *Database) AuditEvents() AuditEventRepository {
+	return db.events
+}
+
+// ExportJobs returns the ExportJobRepository.
+func (db *Database) ExportJobs() ExportJobRepository {
+	return db.exports
+}
+
+// Retention returns the RetentionRepository.
+func (db *Database) Retention() RetentionRepository {
+	return db.retention
+}
+
+// Trash returns the TrashRepository.
+func (db *Database) Trash() TrashRepository {
+	return db.trash
+}
+
+// SyncCounters returns the SyncCounterRepository.
+func (db *Database) SyncCounters() SyncCounterRepository {
+	return db.syncCounters
+}
+
+// Reencryption returns the ReencryptionRepository.
+func (db *Database) Reencryption() ReencryptionRepository {
+	return db.reencryption
+}
+
+// SchemaMigrations returns the SchemaMigrationRepository.
+func (db *Database) SchemaMigrations() SchemaMigrationRepository {
+	return db.migrations
+}
+
+// Transaction runs fn in a single transaction, which is committed when fn returns nil and
+// rolled back otherwise
+func (db *Database) Transaction(fn TxFunc) error {
+	return db.db.Transaction(func(tx *gorm.DB) error {
+		return fn(New(tx))
+	})
+}
+
+// Ping checks if database is up, with its read replicas
 func (db *Database) Ping() error {
-	return db.db.DB().Ping()
+	if p, ok := db.db.CommonDB().(interface{ Ping() error }); ok {
+		return p.Ping()
+	}
+	return errors.New("database connection can't be pinged")
 }
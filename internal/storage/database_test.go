@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/passwall/passwall-server/internal/storage/revision"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSaveChangedRevision(t *testing.T) {
+	viper.Set("server.passphrase", "passphrase-for-encrypting-fields")
+	db, err := NewMemory()
+	require.NoError(t, err)
+	defer db.Close()
+	require.NoError(t, db.Users().CreateSchema("user1"))
+	require.NoError(t, db.db.Table("user1."+revision.CounterTable).AutoMigrate(&model.SyncCounter{}).Error)
+	require.NoError(t, db.db.Table("user1.logins").AutoMigrate(&model.Login{}).Error)
+
+	created, err := db.Logins().Save(&model.Login{Title: "Mail"}, "user1")
+	require.NoError(t, err)
+
+	// Both writers read the login at revision 1, the second save loses
+	first, err := db.Logins().FindByID(created.ID, "user1")
+	require.NoError(t, err)
+	second, err := db.Logins().FindByID(created.ID, "user1")
+	require.NoError(t, err)
+
+	first.Title = "Work mail"
+	_, err = db.Logins().Save(first, "user1")
+	require.NoError(t, err)
+	second.Title = "Home mail"
+	_, err = db.Logins().Save(second, "user1")
+	assert.Equal(t, revision.ErrChanged, err)
+
+	stored, err := db.Logins().FindByID(created.ID, "user1")
+	require.NoError(t, err)
+	assert.Equal(t, "Work mail", stored.Title)
+	assert.Equal(t, uint(2), stored.Revision)
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jinzhu/gorm"
+	_ "github.com/jinzhu/gorm/dialects/postgres"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage/replica"
+	"github.com/passwall/passwall-server/internal/storage/sqlite"
+)
+
+// ErrUnknownDriver is returned for a database driver which is not registered
+var ErrUnknownDriver = errors.New("unknown database driver")
+
+// defaultDriver is the driver of configurations without one
+const defaultDriver = "postgres"
+
+var drivers = struct {
+	sync.RWMutex
+	m map[string]Driver
+}{m: map[string]Driver{}}
+
+// Driver opens the database of the configuration. The gorm dialect of the database has
+// to understand the "schema.table" names of the user tables.
+type Driver func(cfg *config.DatabaseConfiguration) (*gorm.DB, error)
+
+func init() {
+	Register("postgres", openPostgres)
+	Register("sqlite", openSQLite)
+}
+
+// Register adds the driver with the name, it replaces a driver with the same name.
+// Backends like CockroachDB are compiled in with a package which registers their driver
+// in its init function, then database.driver selects them.
+func Register(name string, driver Driver) {
+	drivers.Lock()
+	defer drivers.Unlock()
+	drivers.m[name] = driver
+}
+
+// Drivers returns the names of the registered drivers in alphabetical order
+func Drivers() []string {
+	drivers.RLock()
+	defer drivers.RUnlock()
+	names := make([]string, 0, len(drivers.m))
+	for name := range drivers.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func findDriver(name string) (Driver, error) {
+	if name == "" {
+		name = defaultDriver
+	}
+	drivers.RLock()
+	driver, ok := drivers.m[name]
+	drivers.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q, use one of %s", ErrUnknownDriver, name, strings.Join(Drivers(), ", "))
+	}
+	return driver, nil
+}
+
+func openPostgres(cfg *config.DatabaseConfiguration) (*gorm.DB, error) {
+	dsn := "host=" + cfg.Host + " port=" + cfg.Port + " user=" + cfg.Username + " dbname=" + cfg.Name + "  sslmode=disable password=" + cfg.Password
+	if cfg.Replicas == "" {
+		db, err := gorm.Open("postgres", dsn)
+		if err != nil {
+			return nil, fmt.Errorf("could not open postgresql connection: %w", err)
+		}
+		if err := SetPool(db.DB(), cfg); err != nil {
+			db.Close()
+			return nil, err
+		}
+		return db, nil
+	}
+
+	lag, err := time.ParseDuration(cfg.ReplicaLag)
+	if err != nil {
+		return nil, fmt.Errorf("database.replicaLag: %w", err)
+	}
+	primary, err := sql.Open("postgres", dsn)
+	if err == nil {
+		err = primary.Ping()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not open postgresql connection: %w", err)
+	}
+	if err := SetPool(primary, cfg); err != nil {
+		return nil, err
+	}
+	var replicas []*sql.DB
+	for _, replicaDSN := range strings.Split(cfg.Replicas, ",") {
+		replicaDB, err := sql.Open("postgres", strings.TrimSpace(replicaDSN))
+		if err != nil {
+			return nil, fmt.Errorf("could not open postgresql replica connection: %w", err)
+		}
+		SetPool(replicaDB, cfg)
+		replicas = append(replicas, replicaDB)
+	}
+	return gorm.Open("postgres", replica.New(primary, replicas, lag))
+}
+
+// openSQLite opens the database file, it has a single connection and no pool settings
+func openSQLite(cfg *config.DatabaseConfiguration) (*gorm.DB, error) {
+	return sqlite.Open(cfg.Path)
+}
@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrivers(t *testing.T) {
+	var opened *config.DatabaseConfiguration
+	Register("test", func(cfg *config.DatabaseConfiguration) (*gorm.DB, error) {
+		opened = cfg
+		return sqlite.Open(sqlite.Memory)
+	})
+	defer func() {
+		drivers.Lock()
+		delete(drivers.m, "test")
+		drivers.Unlock()
+	}()
+	assert.Equal(t, []string{"postgres", "sqlite", "test"}, Drivers())
+
+	cfg := &config.DatabaseConfiguration{Driver: "test", Name: "vault"}
+	db, err := DBConn(cfg)
+	require.NoError(t, err)
+	defer db.Close()
+	assert.Equal(t, cfg, opened)
+	// The store works on the database of the driver
+	assert.NotEmpty(t, New(db).PendingMigrations())
+
+	_, err = DBConn(&config.DatabaseConfiguration{Driver: "spanner"})
+	assert.True(t, errors.Is(err, ErrUnknownDriver))
+	assert.EqualError(t, err, `unknown database driver "spanner", use one of postgres, sqlite, test`)
+}
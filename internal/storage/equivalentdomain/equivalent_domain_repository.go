@@ -0,0 +1,42 @@
+package equivalentdomain
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// All ...
+func (p *Repository) All(schema string) ([]model.EquivalentDomain, error) {
+	equivalentDomains := []model.EquivalentDomain{}
+	err := p.db.Table(schema + ".equivalent_domains").Order("id asc").Find(&equivalentDomains).Error
+	return equivalentDomains, err
+}
+
+// FindByID ...
+func (p *Repository) FindByID(id uint, schema string) (*model.EquivalentDomain, error) {
+	equivalentDomain := new(model.EquivalentDomain)
+	err := p.db.Table(schema+".equivalent_domains").Where(`id = ?`, id).First(&equivalentDomain).Error
+	return equivalentDomain, err
+}
+
+// Save ...
+func (p *Repository) Save(equivalentDomain *model.EquivalentDomain, schema string) (*model.EquivalentDomain, error) {
+	err := p.db.Table(schema + ".equivalent_domains").Save(&equivalentDomain).Error
+	return equivalentDomain, err
+}
+
+// Delete ...
+func (p *Repository) Delete(id uint, schema string) error {
+	err := p.db.Table(schema + ".equivalent_domains").Delete(&model.EquivalentDomain{ID: id}).Error
+	return err
+}
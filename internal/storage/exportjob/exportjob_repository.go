@@ -0,0 +1,50 @@
+package exportjob
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindByUUID ...
+func (p *Repository) FindByUUID(uuid string) (*model.ExportJob, error) {
+	job := new(model.ExportJob)
+	err := p.db.Where(`uuid = ?`, uuid).First(&job).Error
+	return job, err
+}
+
+// FindUnfinished ...
+func (p *Repository) FindUnfinished() ([]model.ExportJob, error) {
+	jobs := []model.ExportJob{}
+	err := p.db.Where(`status IN (?)`, []string{model.ExportQueued, model.ExportRunning}).Find(&jobs).Error
+	return jobs, err
+}
+
+// FindExpired ...
+func (p *Repository) FindExpired(now time.Time) ([]model.ExportJob, error) {
+	jobs := []model.ExportJob{}
+	err := p.db.Where(`expires_at < ?`, now).Find(&jobs).Error
+	return jobs, err
+}
+
+// Save ...
+func (p *Repository) Save(job *model.ExportJob) (*model.ExportJob, error) {
+	err := p.db.Save(&job).Error
+	return job, err
+}
+
+// Delete ...
+func (p *Repository) Delete(id uint) error {
+	return p.db.Delete(&model.ExportJob{ID: id}).Error
+}
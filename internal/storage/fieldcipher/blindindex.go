@@ -0,0 +1,109 @@
+package fieldcipher
+
+import (
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+// tagBlind marks the columns which keep the blind index of an encrypted field, its value is
+// the name of the field, e.g. blind:"Username"
+const tagBlind = "blind"
+
+// minPrefix is the length of the shortest beginning of a word which is indexed, so searches
+// match words as they are typed
+const minPrefix = 3
+
+// words returns the lowercase words of the value, letters and digits between other runes
+func words(value string) []string {
+	return strings.FieldsFunc(strings.ToLower(value), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// index returns the blind index of the value, the hashes of its words and their beginnings
+// between spaces. It's empty when the terms of the vault aren't indexed.
+func index(value string, c Cipher) string {
+	seen := map[string]bool{}
+	hashes := []string{}
+	for _, word := range words(value) {
+		runes := []rune(word)
+		shortest := minPrefix
+		if len(runes) < shortest {
+			shortest = len(runes)
+		}
+		for n := shortest; n <= len(runes); n++ {
+			term := string(runes[:n])
+			if seen[term] {
+				continue
+			}
+			seen[term] = true
+			hash := c.BlindIndex(term)
+			if hash == "" {
+				return ""
+			}
+			hashes = append(hashes, hash)
+		}
+	}
+	if len(hashes) == 0 {
+		return ""
+	}
+	return " " + strings.Join(hashes, " ") + " "
+}
+
+// indexStruct sets the blind index fields of the struct from the plaintext of their fields
+func indexStruct(v reflect.Value, c Cipher) {
+	for i := 0; i < v.NumField(); i++ {
+		name := v.Type().Field(i).Tag.Get(tagBlind)
+		if name == "" || !v.Field(i).CanSet() {
+			continue
+		}
+		if source := v.FieldByName(name); source.Kind() == reflect.String {
+			v.Field(i).SetString(index(source.String(), c))
+		}
+	}
+}
+
+// Indexes returns the blind indexes of the struct v points to by the name of their field,
+// from the plaintext of the fields, e.g. {"UsernameIndex": " 3f0c... "}
+func Indexes(v interface{}) map[string]string {
+	c := loadCipher()
+	rv := indirect(reflect.ValueOf(v))
+	if c == nil || rv.Kind() != reflect.Struct {
+		return nil
+	}
+	indexes := map[string]string{}
+	for i := 0; i < rv.NumField(); i++ {
+		field := rv.Type().Field(i)
+		if name := field.Tag.Get(tagBlind); name != "" {
+			indexes[field.Name] = index(rv.FieldByName(name).String(), c)
+		}
+	}
+	return indexes
+}
+
+// Match returns the condition of the rows whose encrypted column has all of the words of the
+// search, looked up in its blind index column. The column itself is searched while no cipher
+// is used, the values are stored as they are then. Nothing matches when the search has no
+// words or the terms of the vault aren't indexed.
+func Match(column, indexColumn, search string) (string, []interface{}) {
+	c := loadCipher()
+	if c == nil {
+		return column + " LIKE ?", []interface{}{"%" + search + "%"}
+	}
+
+	var conditions []string
+	var args []interface{}
+	for _, word := range words(search) {
+		hash := c.BlindIndex(word)
+		if hash == "" {
+			return "1 = 0", nil
+		}
+		conditions = append(conditions, indexColumn+" LIKE ?")
+		args = append(args, "% "+hash+" %")
+	}
+	if len(conditions) == 0 {
+		return "1 = 0", nil
+	}
+	return "(" + strings.Join(conditions, " AND ") + ")", args
+}
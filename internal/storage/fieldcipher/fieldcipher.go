@@ -0,0 +1,191 @@
+// Package fieldcipher encrypts the tagged fields of the models in the storage layer.
+// Fields tagged encrypt:"true" hold items of the vault, encrypt:"server" ones hold secrets
+// of the server like TOTP seeds. They are encrypted before each insert and update and
+// decrypted after each query, so repositories and handlers only see plaintext. Fields
+// tagged blind:"Field" keep the blind index of an encrypted field, so it can be searched.
+package fieldcipher
+
+import (
+	"io/ioutil"
+	"log"
+	"reflect"
+	"sync"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Values of the encrypt tag
+const (
+	TagVault  = "true"
+	TagServer = "server"
+)
+
+// rawSetting is the gorm setting of queries which read and write the stored values as they are
+const rawSetting = "fieldcipher:raw"
+
+const plainKey = "fieldcipher:plain"
+
+// Cipher encrypts the values of the tagged fields, vault is false for encrypt:"server" fields.
+// BlindIndex returns the keyed hash of a search term, empty when the vault isn't indexed.
+type Cipher interface {
+	EncryptField(value string, vault bool) string
+	DecryptField(value string, vault bool) (string, error)
+	BlindIndex(term string) string
+}
+
+var current = struct {
+	sync.RWMutex
+	cipher Cipher
+}{}
+
+// Use makes c the cipher of all databases, the values are stored as they are until it's set
+func Use(c Cipher) {
+	current.Lock()
+	current.cipher = c
+	current.Unlock()
+}
+
+func loadCipher() Cipher {
+	current.RLock()
+	defer current.RUnlock()
+	return current.cipher
+}
+
+// Register adds the callbacks which encrypt and decrypt the tagged fields to the database
+func Register(db *gorm.DB) {
+	// gorm logs each registration, the callbacks are registered on a quiet copy
+	quiet := db.New()
+	quiet.SetLogger(gorm.Logger{LogWriter: log.New(ioutil.Discard, "", 0)})
+	if quiet.Callback().Query().Get("fieldcipher:decrypt") != nil {
+		return
+	}
+	db = quiet
+	db.Callback().Create().Before("gorm:create").Register("fieldcipher:encrypt", encryptCallback)
+	db.Callback().Create().After("gorm:create").Register("fieldcipher:restore", restoreCallback)
+	db.Callback().Update().Before("gorm:update").Register("fieldcipher:encrypt", encryptCallback)
+	db.Callback().Update().After("gorm:update").Register("fieldcipher:restore", restoreCallback)
+	db.Callback().Query().After("gorm:query").Register("fieldcipher:decrypt", decryptCallback)
+}
+
+// Raw returns the database with the callbacks turned off, for jobs like the re-encryption
+// which work on the stored values
+func Raw(db *gorm.DB) *gorm.DB {
+	return db.Set(rawSetting, true)
+}
+
+// EncryptFields encrypts the tagged fields of the struct v points to
+func EncryptFields(v interface{}) {
+	if c := loadCipher(); c != nil {
+		encryptStruct(reflect.ValueOf(v), c)
+	}
+}
+
+// DecryptFields decrypts the tagged fields of the struct or slice of structs v points to.
+// The fields which can't be decrypted are emptied and the first error is returned.
+func DecryptFields(v interface{}) error {
+	if c := loadCipher(); c != nil {
+		return decryptValue(reflect.ValueOf(v), c)
+	}
+	return nil
+}
+
+// encryptCallback encrypts the fields before they are written and keeps the plaintext
+// for restoreCallback, so the caller's model stays readable
+func encryptCallback(scope *gorm.Scope) {
+	c := loadCipher()
+	if c == nil || isRaw(scope) {
+		return
+	}
+	if plain := encryptStruct(reflect.ValueOf(scope.Value), c); plain != nil {
+		scope.InstanceSet(plainKey, plain)
+	}
+}
+
+func restoreCallback(scope *gorm.Scope) {
+	plain, ok := scope.InstanceGet(plainKey)
+	if !ok {
+		return
+	}
+	v := indirect(reflect.ValueOf(scope.Value))
+	for i, value := range plain.(map[int]string) {
+		v.Field(i).SetString(value)
+	}
+}
+
+func decryptCallback(scope *gorm.Scope) {
+	c := loadCipher()
+	if c == nil || isRaw(scope) || scope.HasError() {
+		return
+	}
+	if err := decryptValue(reflect.ValueOf(scope.Value), c); err != nil {
+		scope.Err(err)
+	}
+}
+
+func isRaw(scope *gorm.Scope) bool {
+	raw, ok := scope.Get(rawSetting)
+	return ok && raw.(bool)
+}
+
+// encryptStruct sets the blind indexes, encrypts the tagged fields and returns their
+// plaintext by field index
+func encryptStruct(v reflect.Value, c Cipher) map[int]string {
+	v = indirect(v)
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	indexStruct(v, c)
+	var plain map[int]string
+	for i := 0; i < v.NumField(); i++ {
+		tag := v.Type().Field(i).Tag.Get("encrypt")
+		if !encrypted(tag, v.Field(i)) {
+			continue
+		}
+		if plain == nil {
+			plain = map[int]string{}
+		}
+		plain[i] = v.Field(i).String()
+		v.Field(i).SetString(c.EncryptField(plain[i], tag == TagVault))
+	}
+	return plain
+}
+
+func decryptValue(v reflect.Value, c Cipher) error {
+	v = indirect(v)
+	switch v.Kind() {
+	case reflect.Slice:
+		var err error
+		for i := 0; i < v.Len(); i++ {
+			if rowErr := decryptValue(v.Index(i), c); rowErr != nil && err == nil {
+				err = rowErr
+			}
+		}
+		return err
+	case reflect.Struct:
+		var err error
+		for i := 0; i < v.NumField(); i++ {
+			tag := v.Type().Field(i).Tag.Get("encrypt")
+			if !encrypted(tag, v.Field(i)) {
+				continue
+			}
+			value, fieldErr := c.DecryptField(v.Field(i).String(), tag == TagVault)
+			if fieldErr != nil && err == nil {
+				err = fieldErr
+			}
+			v.Field(i).SetString(value)
+		}
+		return err
+	}
+	return nil
+}
+
+func encrypted(tag string, field reflect.Value) bool {
+	return (tag == TagVault || tag == TagServer) && field.Kind() == reflect.String && field.CanSet()
+}
+
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		v = v.Elem()
+	}
+	return v
+}
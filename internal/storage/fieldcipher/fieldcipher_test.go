@@ -0,0 +1,131 @@
+package fieldcipher
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/passwall/passwall-server/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type secret struct {
+	ID     uint `gorm:"primary_key"`
+	Title  string
+	Value  string `encrypt:"true"`
+	Seed   string `encrypt:"server"`
+	Public string
+}
+
+type indexed struct {
+	ID         uint   `gorm:"primary_key"`
+	Value      string `encrypt:"true"`
+	ValueIndex string `blind:"Value"`
+}
+
+// prefixCipher marks vault values with vault: and server values with server:
+type prefixCipher struct{}
+
+func (prefixCipher) EncryptField(value string, vault bool) string {
+	if vault {
+		return "vault:" + value
+	}
+	return "server:" + value
+}
+
+func (prefixCipher) DecryptField(value string, vault bool) (string, error) {
+	prefix := "server:"
+	if vault {
+		prefix = "vault:"
+	}
+	if !strings.HasPrefix(value, prefix) {
+		return "", errors.New("not encrypted")
+	}
+	return strings.TrimPrefix(value, prefix), nil
+}
+
+func (prefixCipher) BlindIndex(term string) string {
+	return "h:" + term
+}
+
+func TestCallbacks(t *testing.T) {
+	db, err := sqlite.Open(sqlite.Memory)
+	require.NoError(t, err)
+	defer db.Close()
+	Use(prefixCipher{})
+	defer Use(nil)
+	Register(db)
+	Register(db)
+	require.NoError(t, db.AutoMigrate(&secret{}).Error)
+
+	// The saved model stays readable
+	row := &secret{Title: "API", Value: "hunter2", Seed: "JBSWY3DP", Public: "-"}
+	require.NoError(t, db.Save(row).Error)
+	assert.Equal(t, "hunter2", row.Value)
+	assert.Equal(t, "JBSWY3DP", row.Seed)
+
+	stored := secret{}
+	require.NoError(t, Raw(db).First(&stored, row.ID).Error)
+	assert.Equal(t, "vault:hunter2", stored.Value)
+	assert.Equal(t, "server:JBSWY3DP", stored.Seed)
+	assert.Equal(t, "API", stored.Title)
+
+	row.Value = "hunter3"
+	require.NoError(t, db.Save(row).Error)
+	found := []secret{}
+	require.NoError(t, db.Find(&found).Error)
+	if assert.Len(t, found, 1) {
+		assert.Equal(t, "hunter3", found[0].Value)
+		assert.Equal(t, "JBSWY3DP", found[0].Seed)
+	}
+
+	// Values which can't be decrypted fail the query
+	require.NoError(t, Raw(db).Model(&secret{}).Where("id = ?", row.ID).UpdateColumn("value", "plain").Error)
+	assert.Error(t, db.First(&secret{}, row.ID).Error)
+}
+
+func TestFields(t *testing.T) {
+	Use(prefixCipher{})
+	defer Use(nil)
+
+	row := &secret{Value: "hunter2", Seed: "JBSWY3DP", Public: "-"}
+	EncryptFields(row)
+	assert.Equal(t, &secret{Value: "vault:hunter2", Seed: "server:JBSWY3DP", Public: "-"}, row)
+	assert.NoError(t, DecryptFields(row))
+	assert.Equal(t, &secret{Value: "hunter2", Seed: "JBSWY3DP", Public: "-"}, row)
+
+	// Without a cipher the values are kept as they are
+	Use(nil)
+	EncryptFields(row)
+	assert.Equal(t, "hunter2", row.Value)
+}
+
+func TestBlindIndex(t *testing.T) {
+	db, err := sqlite.Open(sqlite.Memory)
+	require.NoError(t, err)
+	defer db.Close()
+	Use(prefixCipher{})
+	defer Use(nil)
+	Register(db)
+	require.NoError(t, db.AutoMigrate(&indexed{}).Error)
+
+	row := &indexed{Value: "John.Doe@acme.io"}
+	require.NoError(t, db.Save(row).Error)
+	assert.Equal(t, " h:joh h:john h:doe h:acm h:acme h:io ", row.ValueIndex)
+	assert.Equal(t, map[string]string{"ValueIndex": row.ValueIndex}, Indexes(row))
+	require.NoError(t, db.Save(&indexed{Value: "jane@example.com"}).Error)
+
+	for search, want := range map[string]int{"acme": 1, "ACM doe": 1, "jo": 0, "io": 1, "acme jane": 0, "@": 0, "com": 1} {
+		match, args := Match("value", "value_index", search)
+		count := 0
+		require.NoError(t, db.Model(&indexed{}).Where(match, args...).Count(&count).Error)
+		assert.Equal(t, want, count, search)
+	}
+
+	// The column itself is searched without a cipher
+	Use(nil)
+	match, args := Match("value", "value_index", "acme")
+	assert.Equal(t, "value LIKE ?", match)
+	assert.Equal(t, []interface{}{"%acme%"}, args)
+}
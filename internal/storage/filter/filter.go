@@ -0,0 +1,55 @@
+// Package filter applies the filters of the item lists which are the same for all types:
+// the times the items were created and updated at, their sync revisions and the equality
+// of their fields.
+package filter
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// SyncAfter is the argument of the sync revision the items have to be changed after
+const SyncAfter = "sync_after"
+
+// FieldPrefix is the prefix of the arguments of the equality filters, the rest is the
+// column, e.g. field.url. The handlers only set the columns the items can be filtered by.
+const FieldPrefix = "field."
+
+// times are the arguments of the time filters, RFC 3339 times, and their conditions
+var times = []struct {
+	arg       string
+	condition string
+}{
+	{"created_after", "created_at >= ?"},
+	{"created_before", "created_at < ?"},
+	{"updated_after", "updated_at >= ?"},
+	{"updated_before", "updated_at < ?"},
+}
+
+// Apply adds the conditions of the filters in the arguments to the query, invalid times
+// are left out
+func Apply(query *gorm.DB, argsStr map[string]string) *gorm.DB {
+	for _, filter := range times {
+		if t, err := time.Parse(time.RFC3339Nano, argsStr[filter.arg]); err == nil {
+			query = query.Where(filter.condition, t)
+		}
+	}
+	if syncRevision, err := strconv.ParseInt(argsStr[SyncAfter], 10, 64); err == nil {
+		query = query.Where("sync_revision > ?", syncRevision)
+	}
+	fields := []string{}
+	for arg := range argsStr {
+		if strings.HasPrefix(arg, FieldPrefix) {
+			fields = append(fields, arg)
+		}
+	}
+	sort.Strings(fields)
+	for _, arg := range fields {
+		query = query.Where(strings.TrimPrefix(arg, FieldPrefix)+" = ?", argsStr[arg])
+	}
+	return query
+}
@@ -0,0 +1,52 @@
+package folder
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage/revision"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// All ...
+func (p *Repository) All(schema string) ([]model.Folder, error) {
+	folders := []model.Folder{}
+	err := p.db.Table(schema + ".folders").Order("id").Find(&folders).Error
+	return folders, err
+}
+
+// FindByID ...
+func (p *Repository) FindByID(id uint, schema string) (*model.Folder, error) {
+	folder := new(model.Folder)
+	err := p.db.Table(schema+".folders").Where(`id = ?`, id).First(&folder).Error
+	return folder, err
+}
+
+// Save ...
+func (p *Repository) Save(folder *model.Folder, schema string) (*model.Folder, error) {
+	err := p.db.Table(schema + ".folders").Save(&folder).Error
+	return folder, err
+}
+
+// Delete ...
+func (p *Repository) Delete(id uint, schema string) error {
+	err := p.db.Table(schema + ".folders").Delete(&model.Folder{ID: id}).Error
+	return err
+}
+
+// Unfile ...
+func (p *Repository) Unfile(id uint, table string) error {
+	return revision.Change(p.db, table, func(tx *gorm.DB, syncRevision int64) error {
+		return tx.Exec(`UPDATE `+table+` SET folder_id = 0, updated_at = ?, sync_revision = ? WHERE folder_id = ?`, time.Now(), syncRevision, id).Error
+	})
+}
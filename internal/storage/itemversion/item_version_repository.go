@@ -0,0 +1,47 @@
+package itemversion
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindByItem ...
+func (p *Repository) FindByItem(itemType string, itemID uint, schema string) ([]model.ItemVersion, error) {
+	versions := []model.ItemVersion{}
+	err := p.db.Table(schema+".item_versions").Where(`item_type = ? AND item_id = ?`, itemType, itemID).Order("id desc").Find(&versions).Error
+	return versions, err
+}
+
+// FindByID ...
+func (p *Repository) FindByID(id uint, schema string) (*model.ItemVersion, error) {
+	version := new(model.ItemVersion)
+	err := p.db.Table(schema+".item_versions").Where(`id = ?`, id).First(&version).Error
+	return version, err
+}
+
+// Create ...
+func (p *Repository) Create(version *model.ItemVersion, schema string) error {
+	return p.db.Table(schema + ".item_versions").Create(version).Error
+}
+
+// DeleteOlder ...
+func (p *Repository) DeleteOlder(itemType string, itemID uint, keep int, schema string) error {
+	table := schema + ".item_versions"
+	return p.db.Exec(`DELETE FROM `+table+` WHERE item_type = ? AND item_id = ? AND id NOT IN (SELECT id FROM `+table+` WHERE item_type = ? AND item_id = ? ORDER BY id DESC LIMIT ?)`,
+		itemType, itemID, itemType, itemID, keep).Error
+}
+
+// DeleteByItem ...
+func (p *Repository) DeleteByItem(itemType string, itemID uint, schema string) error {
+	return p.db.Table(schema+".item_versions").Where(`item_type = ? AND item_id = ?`, itemType, itemID).Delete(&model.ItemVersion{}).Error
+}
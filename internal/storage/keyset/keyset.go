@@ -0,0 +1,68 @@
+// Package keyset pages the item lists of the repositories after the last row of the previous
+// page instead of an offset. Pages stay stable while items are added or deleted and deep
+// pages are as fast as the first one.
+package keyset
+
+import (
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+// key is a column of the order of a list and its direction
+type key struct {
+	column    string
+	direction string
+}
+
+// After orders the query of the table like the item lists, pinned first and then by the
+// columns of the order, with the id between rows of the same values. Unless the cursor is
+// "0", the first page, it keeps the rows after the row of the cursor id. Lists after an
+// item which was purged are empty.
+func After(query *gorm.DB, table, order, cursor string) *gorm.DB {
+	keys := []key{{"pinned", "desc"}}
+	for _, part := range strings.Split(order, ",") {
+		if fields := strings.Fields(part); len(fields) == 2 {
+			keys = append(keys, key{fields[0], fields[1]})
+		}
+	}
+	if len(keys) == 1 {
+		keys = append(keys, key{"updated_at", "desc"})
+	}
+	for i, k := range keys {
+		if k.column == "id" {
+			keys = keys[:i+1]
+			break
+		}
+		if i == len(keys)-1 {
+			keys = append(keys, key{"id", keys[1].direction})
+			query = query.Order("id " + keys[1].direction)
+		}
+	}
+	if cursor == "0" {
+		return query
+	}
+
+	// The values of the row of the cursor
+	of := func(column string) string {
+		return "(SELECT " + column + " FROM " + table + " WHERE id = ?)"
+	}
+	// Rows after it have the same values up to a column which is after its value
+	var conditions []string
+	var args []interface{}
+	for i, k := range keys {
+		var and []string
+		for _, previous := range keys[:i] {
+			and = append(and, previous.column+" = "+of(previous.column))
+			args = append(args, cursor)
+		}
+		op := "<"
+		if k.direction == "asc" {
+			op = ">"
+		}
+		and = append(and, k.column+" "+op+" "+of(k.column))
+		args = append(args, cursor)
+		conditions = append(conditions, "("+strings.Join(and, " AND ")+")")
+	}
+	return query.Where(strings.Join(conditions, " OR "), args...)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/go-test/deep"
 	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage/revision"
 	"github.com/passwall/passwall-server/model"
 	"github.com/stretchr/testify/assert"
 )
@@ -16,6 +17,7 @@ import (
 func dbSetup() (*gorm.DB, sqlmock.Sqlmock) {
 	db, mock, _ := sqlmock.New()
 	DB, _ := gorm.Open("postgres", db)
+	revision.Register(DB)
 
 	DB.LogMode(true)
 
@@ -101,16 +103,26 @@ func TestSave(t *testing.T) {
 		Extra:    "dummy extra text",
 	}
 
-	const sqlInsert = `INSERT INTO "user-test"."logins" ("created_at","updated_at","deleted_at","title","url","username","password","extra") VALUES ($1,$2,$3,$4,$5,$6,$7,$8) RETURNING "user-test"."logins"."id"`
+	const sqlInsert = `INSERT INTO "user-test"."logins" ("created_at","updated_at","deleted_at","revision","sync_revision","title","url","username","username_index","password","extra","auto_type_sequence","auto_type_window","pinned","is_favorite","sort_order","folder_id","reprompt","canary","rotation_provider","rotation_period","rotated_at") VALUES ($1,$2,$3,$4,$5,$6,$7,$8,$9,$10,$11,$12,$13,$14,$15,$16,$17,$18,$19,$20,$21,$22) RETURNING "user-test"."logins"."id"`
+
+	const sqlNextSyncRevision = `UPDATE "user-test"."sync_counters" SET "value" = value + $1  WHERE "user-test"."sync_counters"."id" = $2`
+	const sqlSyncCounter = `SELECT * FROM "user-test"."sync_counters"  WHERE (id = $1) ORDER BY "user-test"."sync_counters"."id" ASC LIMIT 1`
 
 	mock.ExpectBegin() // start transaction
+	mock.ExpectExec(regexp.QuoteMeta(sqlNextSyncRevision)).
+		WithArgs(1, 1).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta(sqlSyncCounter)).
+		WithArgs(1).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "epoch", "value", "purged"}).AddRow(1, "c0ffee", 7, 0))
 	mock.ExpectQuery(regexp.QuoteMeta(sqlInsert)).
-		WithArgs(AnyTime{}, AnyTime{}, nil, login.Title, login.URL, login.Username, login.Password, login.Extra).
+		WithArgs(AnyTime{}, AnyTime{}, nil, 1, 7, login.Title, login.URL, login.Username, login.UsernameIndex, login.Password, login.Extra, login.AutoTypeSequence, login.AutoTypeWindow, login.Pinned, login.IsFavorite, login.SortOrder, login.FolderID, login.Reprompt, login.Canary, login.RotationProvider, login.RotationPeriod, nil).
 		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(login.ID))
 	mock.ExpectCommit() // commit transaction
 
 	resultLogin, err := loginRepository.Save(login, "user-test")
 	assert.Nil(t, err)
+	assert.Equal(t, int64(7), resultLogin.SyncRevision)
 
 	assert.Nil(t, deep.Equal(login, resultLogin))
 
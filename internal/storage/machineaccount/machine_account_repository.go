@@ -0,0 +1,55 @@
+package machineaccount
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindAllByUserID ...
+func (p *Repository) FindAllByUserID(userID uint) ([]model.MachineAccount, error) {
+	accounts := []model.MachineAccount{}
+	err := p.db.Where(`user_id = ?`, userID).Order("id").Find(&accounts).Error
+	return accounts, err
+}
+
+// FindByID ...
+func (p *Repository) FindByID(id uint) (*model.MachineAccount, error) {
+	account := new(model.MachineAccount)
+	err := p.db.Where(`id = ?`, id).First(&account).Error
+	return account, err
+}
+
+// FindByUUID ...
+func (p *Repository) FindByUUID(uuid string) (*model.MachineAccount, error) {
+	account := new(model.MachineAccount)
+	err := p.db.Where(`uuid = ?`, uuid).First(&account).Error
+	return account, err
+}
+
+// Save ...
+func (p *Repository) Save(account *model.MachineAccount) (*model.MachineAccount, error) {
+	err := p.db.Save(&account).Error
+	return account, err
+}
+
+// Delete ...
+func (p *Repository) Delete(id uint) error {
+	err := p.db.Delete(&model.MachineAccount{ID: id}).Error
+	return err
+}
+
+// DeleteByUserID ...
+func (p *Repository) DeleteByUserID(userID uint) error {
+	err := p.db.Where(`user_id = ?`, userID).Delete(&model.MachineAccount{}).Error
+	return err
+}
@@ -0,0 +1,18 @@
+package storage
+
+import "github.com/passwall/passwall-server/internal/storage/sqlite"
+
+// NewMemory opens a store which keeps all data in memory.
+// It is meant for tests and demos, the data is lost when the store is closed.
+func NewMemory() (*Database, error) {
+	db, err := sqlite.Open(sqlite.Memory)
+	if err != nil {
+		return nil, err
+	}
+	return New(db), nil
+}
+
+// Close closes the database connection
+func (db *Database) Close() error {
+	return db.db.Close()
+}
@@ -0,0 +1,41 @@
+package storage
+
+import "github.com/passwall/passwall-server/model"
+
+// systemModels are the models of the tables app.MigrateSystemTables creates in the
+// default schema
+var systemModels = []interface{}{
+	&model.Token{},
+	&model.User{},
+	&model.Subscription{},
+	&model.MachineAccount{},
+	&model.Policy{},
+	&model.SSOIdentity{},
+	&model.PersonalAccessToken{},
+	&model.TrustedDevice{},
+	&model.SigninFailure{},
+	&model.AuditLog{},
+	&model.AuditCheckpoint{},
+	&model.ExportJob{},
+	&model.ReencryptionJob{},
+}
+
+// PendingMigrations returns the system tables and columns like users.locale which the
+// models have and the database hasn't yet. The migration at startup adds them.
+func (db *Database) PendingMigrations() []string {
+	var pending []string
+	for _, m := range systemModels {
+		scope := db.db.NewScope(m)
+		table := scope.TableName()
+		if !scope.Dialect().HasTable(table) {
+			pending = append(pending, table)
+			continue
+		}
+		for _, field := range scope.GetModelStruct().StructFields {
+			if field.IsNormal && !field.IsIgnored && !scope.Dialect().HasColumn(table, field.DBName) {
+				pending = append(pending, table+"."+field.DBName)
+			}
+		}
+	}
+	return pending
+}
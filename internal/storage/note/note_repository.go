@@ -1,7 +1,12 @@
 package note
 
 import (
+	"strings"
+
 	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage/fieldcipher"
+	"github.com/passwall/passwall-server/internal/storage/filter"
+	"github.com/passwall/passwall-server/internal/storage/keyset"
 	"github.com/passwall/passwall-server/model"
 )
 
@@ -34,11 +39,33 @@ func (p *Repository) FindAll(argsStr map[string]string, argsInt map[string]int,
 		query = query.Offset(argsInt["offset"])
 	}
 
+	// Pinned items always come first
+	query = query.Order("pinned desc")
 	query = query.Order(argsStr["order"])
+	if argsStr["cursor"] != "" {
+		query = keyset.After(query, schema+".notes", argsStr["order"], argsStr["cursor"])
+	}
 
 	// TODO: This is not working because notes are encrypted
 	if argsStr["search"] != "" {
-		query = query.Where("note LIKE ?", "%"+argsStr["search"]+"%")
+		match, args := fieldcipher.Match("note", "note_index", argsStr["search"])
+		query = query.Where(match, args...)
+	}
+
+	if argsStr["is_favorite"] == "true" {
+		query = query.Where("is_favorite = ?", true)
+	}
+
+	query = filter.Apply(query, argsStr)
+
+	if argsStr["folder_id"] != "" {
+		query = query.Where("folder_id = ?", argsStr["folder_id"])
+	}
+
+	// Items with all of the tags
+	if argsStr["tags"] != "" {
+		tags := strings.Split(argsStr["tags"], ",")
+		query = query.Where("id IN (SELECT item_id FROM "+schema+".item_tags WHERE item_type = ? AND tag_id IN (?) GROUP BY item_id HAVING COUNT(*) = ?)", "notes", tags, len(tags))
 	}
 
 	err := query.Find(&notes).Error
@@ -63,8 +90,3 @@ func (p *Repository) Delete(id uint, schema string) error {
 	err := p.db.Table(schema + ".notes").Delete(&model.Note{ID: id}).Error
 	return err
 }
-
-// Migrate ...
-func (p *Repository) Migrate(schema string) error {
-	return p.db.Table(schema + ".notes").AutoMigrate(&model.Note{}).Error
-}
@@ -0,0 +1,35 @@
+package passwordhistory
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindByLoginID ...
+func (p *Repository) FindByLoginID(loginID uint, schema string) ([]model.PasswordHistory, error) {
+	histories := []model.PasswordHistory{}
+	err := p.db.Table(schema+".password_histories").Where(`login_id = ?`, loginID).Order("created_at desc").Find(&histories).Error
+	return histories, err
+}
+
+// Save ...
+func (p *Repository) Save(history *model.PasswordHistory, schema string) (*model.PasswordHistory, error) {
+	err := p.db.Table(schema + ".password_histories").Save(&history).Error
+	return history, err
+}
+
+// DeleteByLoginID ...
+func (p *Repository) DeleteByLoginID(loginID uint, schema string) error {
+	err := p.db.Table(schema+".password_histories").Where(`login_id = ?`, loginID).Delete(&model.PasswordHistory{}).Error
+	return err
+}
@@ -0,0 +1,35 @@
+package policy
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindByUserID ...
+func (p *Repository) FindByUserID(userID uint) (*model.Policy, error) {
+	policy := new(model.Policy)
+	err := p.db.Where(`user_id = ?`, userID).First(&policy).Error
+	return policy, err
+}
+
+// Save ...
+func (p *Repository) Save(policy *model.Policy) (*model.Policy, error) {
+	err := p.db.Save(&policy).Error
+	return policy, err
+}
+
+// DeleteByUserID ...
+func (p *Repository) DeleteByUserID(userID uint) error {
+	err := p.db.Where(`user_id = ?`, userID).Delete(&model.Policy{}).Error
+	return err
+}
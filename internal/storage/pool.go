@@ -0,0 +1,43 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage/replica"
+)
+
+// SetPool applies the pool settings of the configuration to the connections. Drivers
+// call it for the pools they open.
+func SetPool(db *sql.DB, cfg *config.DatabaseConfiguration) error {
+	lifetime := time.Duration(0)
+	if cfg.ConnMaxLifetime != "" {
+		var err error
+		if lifetime, err = time.ParseDuration(cfg.ConnMaxLifetime); err != nil {
+			return fmt.Errorf("database.connMaxLifetime: %w", err)
+		}
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(lifetime)
+	return nil
+}
+
+// PoolStats returns the statistics of the connection pools, of the primary and of the
+// replicas replica-1, replica-2 and so on
+func (db *Database) PoolStats() map[string]sql.DBStats {
+	switch conn := db.db.CommonDB().(type) {
+	case *sql.DB:
+		return map[string]sql.DBStats{"primary": conn.Stats()}
+	case *replica.DB:
+		stats := map[string]sql.DBStats{"primary": conn.Primary().Stats()}
+		for i, replicaDB := range conn.Replicas() {
+			stats["replica-"+strconv.Itoa(i+1)] = replicaDB.Stats()
+		}
+		return stats
+	}
+	return nil
+}
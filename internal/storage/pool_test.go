@@ -0,0 +1,39 @@
+package storage
+
+import (
+	"database/sql"
+	"testing"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/config"
+	"github.com/passwall/passwall-server/internal/storage/replica"
+	"github.com/passwall/passwall-server/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPool(t *testing.T) {
+	primary, err := sql.Open(sqlite.Driver, sqlite.Memory)
+	require.NoError(t, err)
+	replicaDB, err := sql.Open(sqlite.Driver, sqlite.Memory)
+	require.NoError(t, err)
+
+	cfg := &config.DatabaseConfiguration{MaxOpenConns: 4, MaxIdleConns: 1, ConnMaxLifetime: "5m"}
+	require.NoError(t, SetPool(primary, cfg))
+	require.NoError(t, SetPool(replicaDB, cfg))
+	cfg.ConnMaxLifetime = "forever"
+	assert.Error(t, SetPool(primary, cfg))
+
+	db, err := gorm.Open(sqlite.Driver, replica.New(primary, []*sql.DB{replicaDB}, 0))
+	require.NoError(t, err)
+	defer db.Close()
+	stats := New(db).PoolStats()
+	require.Len(t, stats, 2)
+	assert.Equal(t, 4, stats["primary"].MaxOpenConnections)
+	assert.Equal(t, 4, stats["replica-1"].MaxOpenConnections)
+
+	memory, err := NewMemory()
+	require.NoError(t, err)
+	defer memory.Close()
+	assert.Equal(t, 1, memory.PoolStats()["primary"].MaxOpenConnections)
+}
@@ -0,0 +1,70 @@
+package reencryption
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage/fieldcipher"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindByID ...
+func (p *Repository) FindByID(id uint) (*model.ReencryptionJob, error) {
+	job := new(model.ReencryptionJob)
+	err := p.db.Where(`id = ?`, id).First(&job).Error
+	return job, err
+}
+
+// FindUnfinished ...
+func (p *Repository) FindUnfinished() ([]model.ReencryptionJob, error) {
+	jobs := []model.ReencryptionJob{}
+	err := p.db.Where(`status IN (?)`, []string{model.ReencryptionQueued, model.ReencryptionRunning}).Order(`id`).Find(&jobs).Error
+	return jobs, err
+}
+
+// FindRecent ...
+func (p *Repository) FindRecent(limit int) ([]model.ReencryptionJob, error) {
+	jobs := []model.ReencryptionJob{}
+	err := p.db.Order(`id DESC`).Limit(limit).Find(&jobs).Error
+	return jobs, err
+}
+
+// Save ...
+func (p *Repository) Save(job *model.ReencryptionJob) (*model.ReencryptionJob, error) {
+	err := p.db.Save(&job).Error
+	return job, err
+}
+
+// CountRows counts the rows of the table, soft deleted ones included
+func (p *Repository) CountRows(table string) (int, error) {
+	count := 0
+	err := p.db.Table(table).Count(&count).Error
+	return count, err
+}
+
+// FindBatch finds the rows of the table after the id in the order of their ids, soft deleted ones included.
+// The encrypted fields are read as they are stored.
+func (p *Repository) FindBatch(table string, afterID uint, limit int, rows interface{}) error {
+	return fieldcipher.Raw(p.db).Unscoped().Table(table).Where(`id > ?`, afterID).Order(`id`).Limit(limit).Find(rows).Error
+}
+
+// SaveBatch updates the columns of the rows by their ids and the cursor of the job in a transaction.
+// Timestamps of the rows don't change.
+func (p *Repository) SaveBatch(job *model.ReencryptionJob, table string, rows map[uint]map[string]interface{}) error {
+	return fieldcipher.Raw(p.db).Transaction(func(tx *gorm.DB) error {
+		for id, columns := range rows {
+			if err := tx.Table(table).Where(`id = ?`, id).UpdateColumns(columns).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(job).Error
+	})
+}
@@ -0,0 +1,141 @@
+// Package replica sends the reads of the storage layer to read replicas of the database
+// and everything else to the primary.
+//
+// Reads are the SELECT statements outside of transactions. The catalog queries of the
+// migrations and the reads of a goroutine shortly after its writes stay on the primary,
+// so they don't miss rows the replicas haven't received yet.
+package replica
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/goroutine"
+)
+
+// reads match the statements a replica can answer
+var reads = regexp.MustCompile(`(?is)^\s*select\s`)
+
+// primaryOnly match the reads which need the primary
+var primaryOnly = regexp.MustCompile(`(?i)\bfor\s+(update|share)\b|information_schema|pg_catalog`)
+
+// DB is the gorm connection of a primary and its replicas
+type DB struct {
+	primary  *sql.DB
+	replicas []*sql.DB
+	lag      time.Duration
+	next     uint32
+
+	mu     sync.Mutex
+	writes map[uint64]time.Time // last write of a goroutine
+}
+
+// New routes the reads to the replicas in turns. Goroutines read from the primary for
+// lag after their writes.
+func New(primary *sql.DB, replicas []*sql.DB, lag time.Duration) *DB {
+	return &DB{primary: primary, replicas: replicas, lag: lag, writes: map[uint64]time.Time{}}
+}
+
+// Primary returns the connections of the primary
+func (db *DB) Primary() *sql.DB {
+	return db.primary
+}
+
+// Replicas returns the connections of the replicas
+func (db *DB) Replicas() []*sql.DB {
+	return db.replicas
+}
+
+// Exec runs the statement on the primary
+func (db *DB) Exec(query string, args ...interface{}) (sql.Result, error) {
+	db.wrote()
+	return db.primary.Exec(query, args...)
+}
+
+// Prepare prepares the statement on the primary
+func (db *DB) Prepare(query string) (*sql.Stmt, error) {
+	db.wrote()
+	return db.primary.Prepare(query)
+}
+
+// Query runs reads on a replica and other statements like INSERT ... RETURNING on the primary
+func (db *DB) Query(query string, args ...interface{}) (*sql.Rows, error) {
+	return db.conn(query).Query(query, args...)
+}
+
+// QueryRow runs reads on a replica and other statements on the primary
+func (db *DB) QueryRow(query string, args ...interface{}) *sql.Row {
+	return db.conn(query).QueryRow(query, args...)
+}
+
+// Begin starts a transaction on the primary, all its statements run there
+func (db *DB) Begin() (*sql.Tx, error) {
+	db.wrote()
+	return db.primary.Begin()
+}
+
+// BeginTx starts a transaction on the primary, all its statements run there
+func (db *DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	db.wrote()
+	return db.primary.BeginTx(ctx, opts)
+}
+
+// Ping checks the primary and the replicas
+func (db *DB) Ping() error {
+	if err := db.primary.Ping(); err != nil {
+		return err
+	}
+	for _, replica := range db.replicas {
+		if err := replica.Ping(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close closes the connections of the primary and the replicas
+func (db *DB) Close() error {
+	err := db.primary.Close()
+	for _, replica := range db.replicas {
+		if replicaErr := replica.Close(); err == nil {
+			err = replicaErr
+		}
+	}
+	return err
+}
+
+// conn returns the connection of the statement
+func (db *DB) conn(query string) *sql.DB {
+	if !reads.MatchString(query) {
+		db.wrote()
+		return db.primary
+	}
+	if len(db.replicas) == 0 || primaryOnly.MatchString(query) || db.recentlyWrote() {
+		return db.primary
+	}
+	return db.replicas[atomic.AddUint32(&db.next, 1)%uint32(len(db.replicas))]
+}
+
+// wrote records a write of the goroutine and forgets the writes older than the lag
+func (db *DB) wrote() {
+	now := time.Now()
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	for g, at := range db.writes {
+		if now.Sub(at) >= db.lag {
+			delete(db.writes, g)
+		}
+	}
+	db.writes[goroutine.ID()] = now
+}
+
+func (db *DB) recentlyWrote() bool {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	at, ok := db.writes[goroutine.ID()]
+	return ok && time.Since(at) < db.lag
+}
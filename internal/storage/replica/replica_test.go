@@ -0,0 +1,81 @@
+package replica
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func open(t *testing.T) *sql.DB {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	_, err = db.Exec("CREATE TABLE logins (id INTEGER PRIMARY KEY)")
+	require.NoError(t, err)
+	return db
+}
+
+// count reads the logins from another goroutine, which didn't write
+func count(t *testing.T, db *DB, query string) int {
+	counted := make(chan int)
+	go func() {
+		var n int
+		assert.NoError(t, db.QueryRow(query).Scan(&n))
+		counted <- n
+	}()
+	return <-counted
+}
+
+func TestRouting(t *testing.T) {
+	primary, replica := open(t), open(t)
+	db := New(primary, []*sql.DB{replica}, time.Minute)
+	defer db.Close()
+
+	_, err := db.Exec("INSERT INTO logins (id) VALUES (1)")
+	require.NoError(t, err)
+
+	// The replica hasn't received the row yet
+	assert.Equal(t, 0, count(t, db, "SELECT count(*) FROM logins"))
+	// Catalog reads stay on the primary
+	assert.Equal(t, 1, count(t, db, "SELECT count(*) FROM logins WHERE id IN (SELECT id FROM logins) -- information_schema"))
+
+	// The goroutine which wrote reads its writes
+	var n int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM logins").Scan(&n))
+	assert.Equal(t, 1, n)
+
+	// Transactions run on the primary
+	tx, err := db.Begin()
+	require.NoError(t, err)
+	require.NoError(t, tx.QueryRow("SELECT count(*) FROM logins").Scan(&n))
+	assert.Equal(t, 1, n)
+	require.NoError(t, tx.Rollback())
+
+	assert.NoError(t, db.Ping())
+}
+
+func TestLag(t *testing.T) {
+	primary, replica := open(t), open(t)
+	db := New(primary, []*sql.DB{replica}, 0)
+	defer db.Close()
+
+	_, err := db.Exec("INSERT INTO logins (id) VALUES (1)")
+	require.NoError(t, err)
+	var n int
+	require.NoError(t, db.QueryRow("SELECT count(*) FROM logins").Scan(&n))
+	assert.Equal(t, 0, n)
+
+	// Statements which aren't reads go to the primary
+	rows, err := db.Query("INSERT INTO logins (id) VALUES (2)")
+	require.NoError(t, err)
+	rows.Next()
+	rows.Close()
+	n = 0
+	require.NoError(t, primary.QueryRow("SELECT count(*) FROM logins").Scan(&n))
+	assert.Equal(t, 2, n)
+	assert.Len(t, db.writes, 1)
+}
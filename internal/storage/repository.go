@@ -4,7 +4,6 @@ import (
 	"time"
 
 	"github.com/passwall/passwall-server/model"
-	uuid "github.com/satori/go.uuid"
 )
 
 // LoginRepository interface is the common interface for a repository
@@ -20,8 +19,82 @@ type LoginRepository interface {
 	Save(login *model.Login, schema string) (*model.Login, error)
 	// Delete removes the entity from the store
 	Delete(id uint, schema string) error
-	// Migrate migrates the repository
-	Migrate(schema string) error
+}
+
+// PasswordHistoryRepository interface is the common interface for a repository
+// Each method checks the entity type.
+type PasswordHistoryRepository interface {
+	// FindByLoginID finds the previous passwords of the login, newest first.
+	FindByLoginID(loginID uint, schema string) ([]model.PasswordHistory, error)
+	// Save stores the entity to the repository
+	Save(history *model.PasswordHistory, schema string) (*model.PasswordHistory, error)
+	// DeleteByLoginID removes the previous passwords of the login from the store
+	DeleteByLoginID(loginID uint, schema string) error
+}
+
+// FolderRepository interface is the common interface for a repository
+// Each method checks the entity type.
+type FolderRepository interface {
+	// All returns all the data in the repository.
+	All(schema string) ([]model.Folder, error)
+	// FindByID finds the entity regarding to its ID.
+	FindByID(id uint, schema string) (*model.Folder, error)
+	// Save stores the entity to the repository
+	Save(folder *model.Folder, schema string) (*model.Folder, error)
+	// Delete removes the entity from the store
+	Delete(id uint, schema string) error
+	// Unfile moves the items of the folder in the "schema.table" out of it, deleted ones too
+	Unfile(id uint, table string) error
+}
+
+// TagRepository interface is the common interface for a repository
+// Each method checks the entity type.
+type TagRepository interface {
+	// All returns all the data in the repository.
+	All(schema string) ([]model.Tag, error)
+	// FindByID finds the entity regarding to its ID.
+	FindByID(id uint, schema string) (*model.Tag, error)
+	// Save stores the entity to the repository
+	Save(tag *model.Tag, schema string) (*model.Tag, error)
+	// Delete removes the entity and its links to the items from the store
+	Delete(id uint, schema string) error
+	// FindItemTags finds the links of the tags to the items of the type
+	FindItemTags(itemType string, itemIDs []uint, schema string) ([]model.ItemTag, error)
+	// SetItemTags replaces the tags of the item
+	SetItemTags(itemType string, itemID uint, tagIDs []uint, schema string) error
+}
+
+// ItemVersionRepository keeps the snapshots of the items of a vault before their updates
+type ItemVersionRepository interface {
+	// FindByItem finds the versions of the item, newest first.
+	FindByItem(itemType string, itemID uint, schema string) ([]model.ItemVersion, error)
+	// FindByID finds the version regarding to its ID.
+	FindByID(id uint, schema string) (*model.ItemVersion, error)
+	// Create stores the version to the repository
+	Create(version *model.ItemVersion, schema string) error
+	// DeleteOlder removes the versions of the item but the newest keep ones
+	DeleteOlder(itemType string, itemID uint, keep int, schema string) error
+	// DeleteByItem removes all versions of the item
+	DeleteByItem(itemType string, itemID uint, schema string) error
+}
+
+// AttachmentRepository keeps the files of the items of a vault, their content is in the
+// blob store
+type AttachmentRepository interface {
+	// All returns the attachments of all items
+	All(schema string) ([]model.Attachment, error)
+	// FindByItem finds the attachments of the item, oldest first.
+	FindByItem(itemType string, itemID uint, schema string) ([]model.Attachment, error)
+	// FindByID finds the entity regarding to its ID.
+	FindByID(id uint, schema string) (*model.Attachment, error)
+	// FindOrphans finds the attachments of the type whose item isn't in the item table anymore
+	FindOrphans(itemType, itemTable, schema string) ([]model.Attachment, error)
+	// TotalSize returns the bytes of the content of all attachments
+	TotalSize(schema string) (int64, error)
+	// Create stores the attachment to the repository
+	Create(attachment *model.Attachment, schema string) error
+	// Delete removes the attachment from the store
+	Delete(id uint, schema string) error
 }
 
 // CreditCardRepository interface is the common interface for a repository
@@ -37,8 +110,6 @@ type CreditCardRepository interface {
 	Save(card *model.CreditCard, schema string) (*model.CreditCard, error)
 	// Delete removes the entity from the store
 	Delete(id uint, schema string) error
-	// Migrate migrates the repository
-	Migrate(schema string) error
 }
 
 // BankAccountRepository interface is the common interface for a repository
@@ -54,8 +125,6 @@ type BankAccountRepository interface {
 	Save(account *model.BankAccount, schema string) (*model.BankAccount, error)
 	// Delete removes the entity from the store
 	Delete(id uint, schema string) error
-	// Migrate migrates the repository
-	Migrate(schema string) error
 }
 
 // NoteRepository interface is the common interface for a repository
@@ -71,8 +140,6 @@ type NoteRepository interface {
 	Save(account *model.Note, schema string) (*model.Note, error)
 	// Delete removes the entity from the store
 	Delete(id uint, schema string) error
-	// Migrate migrates the repository
-	Migrate(schema string) error
 }
 
 // EmailRepository interface is the common interface for a repository
@@ -88,18 +155,57 @@ type EmailRepository interface {
 	Save(account *model.Email, schema string) (*model.Email, error)
 	// Delete removes the entity from the store
 	Delete(id uint, schema string) error
-	// Migrate migrates the repository
-	Migrate(schema string) error
+}
+
+// EquivalentDomainRepository interface is the common interface for a repository
+// Server wide equivalent domains are stored in the public schema.
+type EquivalentDomainRepository interface {
+	// All returns all the data in the repository.
+	All(schema string) ([]model.EquivalentDomain, error)
+	// FindByID finds the entity regarding to its ID.
+	FindByID(id uint, schema string) (*model.EquivalentDomain, error)
+	// Save stores the entity to the repository
+	Save(equivalentDomain *model.EquivalentDomain, schema string) (*model.EquivalentDomain, error)
+	// Delete removes the entity from the store
+	Delete(id uint, schema string) error
+}
+
+// WebAuthnCredentialRepository keeps the security keys of the user in the user schema
+type WebAuthnCredentialRepository interface {
+	// All returns all the data in the repository.
+	All(schema string) ([]model.WebAuthnCredential, error)
+	// FindByID finds the entity regarding to its ID.
+	FindByID(id uint, schema string) (*model.WebAuthnCredential, error)
+	// FindByCredentialID finds the entity regarding to the base64url id of the credential.
+	FindByCredentialID(credentialID string, schema string) (*model.WebAuthnCredential, error)
+	// Save stores the entity to the repository
+	Save(credential *model.WebAuthnCredential, schema string) (*model.WebAuthnCredential, error)
+	// Delete removes the entity from the store
+	Delete(id uint, schema string) error
+	// DeleteAll removes all entities of the schema
+	DeleteAll(schema string) error
 }
 
 // TokenRepository ...
 // TODO: Add explanation to functions in TokenRepository
 type TokenRepository interface {
 	Any(uuid string) (model.Token, bool)
-	Save(userid int, uuid uuid.UUID, tkn string, expriydate time.Time, transmissionKey string)
+	Save(token *model.Token)
+	// Rotate marks the refresh token as used, it's false when the token already was
+	Rotate(uuid string, rotatedAt time.Time) bool
 	Delete(userid int)
 	DeleteByUUID(uuid string)
-	Migrate() error
+	// DeleteByFamily deletes the tokens of a session and of all its refreshes
+	DeleteByFamily(family string)
+	// DeleteAccessByFamily deletes the access tokens of a session, its refresh tokens are kept
+	DeleteAccessByFamily(family string)
+	// DeleteSuperseded deletes the rotated refresh tokens which expired before the time
+	DeleteSuperseded(before time.Time) (int, error)
+	Touch(userid int, lastUsedAt time.Time) error
+	// TouchByFamily sets the last activity time of the tokens of a session
+	TouchByFamily(family string, lastUsedAt time.Time) error
+	// FindSessions returns the current refresh token of each session of the user
+	FindSessions(userid int) ([]model.Token, error)
 }
 
 // UserRepository interface is the common interface for a repository
@@ -119,10 +225,10 @@ type UserRepository interface {
 	Save(login *model.User) (*model.User, error)
 	// Delete removes the entity from the store
 	Delete(id uint, schema string) error
-	// Migrate migrates the repository
-	Migrate() error
 	// CreateSchema creates schema for user
 	CreateSchema(schema string) error
+	// DropSchema removes the schema with all data in it
+	DropSchema(schema string) error
 }
 
 // ServerRepository interface is the common interface for a repository
@@ -138,10 +244,208 @@ type ServerRepository interface {
 	Save(server *model.Server, schema string) (*model.Server, error)
 	// Delete removes the entity from the store
 	Delete(id uint, schema string) error
-	// Migrate migrates the repository
+}
+
+// MachineAccountRepository interface is the common interface for a repository
+// Each method checks the entity type.
+type MachineAccountRepository interface {
+	// FindAllByUserID returns the machine accounts of the user.
+	FindAllByUserID(userID uint) ([]model.MachineAccount, error)
+	// FindByID finds the entity regarding to its ID.
+	FindByID(id uint) (*model.MachineAccount, error)
+	// FindByUUID finds the entity regarding to its UUID, the client id of the account.
+	FindByUUID(uuid string) (*model.MachineAccount, error)
+	// Save stores the entity to the repository
+	Save(account *model.MachineAccount) (*model.MachineAccount, error)
+	// Delete removes the entity from the store
+	Delete(id uint) error
+	// DeleteByUserID removes the machine accounts of the user from the store
+	DeleteByUserID(userID uint) error
+}
+
+// PersonalAccessTokenRepository interface is the common interface for a repository
+// Each method checks the entity type.
+type PersonalAccessTokenRepository interface {
+	// FindAllByUserID returns the personal access tokens of the user.
+	FindAllByUserID(userID uint) ([]model.PersonalAccessToken, error)
+	// FindByID finds the entity regarding to its ID.
+	FindByID(id uint) (*model.PersonalAccessToken, error)
+	// FindByHash finds the entity regarding to the hash of its token.
+	FindByHash(hash string) (*model.PersonalAccessToken, error)
+	// Save stores the entity to the repository
+	Save(token *model.PersonalAccessToken) (*model.PersonalAccessToken, error)
+	// Delete removes the entity from the store
+	Delete(id uint) error
+	// DeleteByUserID removes the personal access tokens of the user from the store
+	DeleteByUserID(userID uint) error
+}
+
+// TrustedDeviceRepository interface is the common interface for a repository
+// Each method checks the entity type.
+type TrustedDeviceRepository interface {
+	// FindAllByUserID returns the trusted devices of the user.
+	FindAllByUserID(userID uint) ([]model.TrustedDevice, error)
+	// FindByID finds the entity regarding to its ID.
+	FindByID(id uint) (*model.TrustedDevice, error)
+	// FindByHash finds the entity regarding to the hash of its device token.
+	FindByHash(hash string) (*model.TrustedDevice, error)
+	// Save stores the entity to the repository
+	Save(device *model.TrustedDevice) (*model.TrustedDevice, error)
+	// Delete removes the entity from the store
+	Delete(id uint) error
+	// DeleteByUserID removes the trusted devices of the user from the store
+	DeleteByUserID(userID uint) error
+}
+
+// SigninFailureRepository interface is the common interface for a repository
+// Each method checks the entity type.
+type SigninFailureRepository interface {
+	// FindByKey finds the failed sign ins of the account or address key.
+	FindByKey(key string) (*model.SigninFailure, error)
+	// Save stores the entity to the repository
+	Save(failure *model.SigninFailure) (*model.SigninFailure, error)
+	// DeleteByKey removes the failed sign ins of the key from the store
+	DeleteByKey(key string) error
+	// DeleteBefore removes the entities whose last failure was before the time and returns their count
+	DeleteBefore(before time.Time) (int, error)
+}
+
+// SSOIdentityRepository interface is the common interface for a repository
+// Each method checks the entity type.
+type SSOIdentityRepository interface {
+	// FindBySubject finds the identity of the subject at the provider.
+	FindBySubject(provider, subject string) (*model.SSOIdentity, error)
+	// Save stores the entity to the repository
+	Save(identity *model.SSOIdentity) (*model.SSOIdentity, error)
+	// DeleteByUserID removes the identities of the user from the store
+	DeleteByUserID(userID uint) error
+}
+
+// PolicyRepository interface is the common interface for a repository
+// Each method checks the entity type.
+type PolicyRepository interface {
+	// FindByUserID finds the policy of the user, user id 0 is the server policy.
+	FindByUserID(userID uint) (*model.Policy, error)
+	// Save stores the entity to the repository
+	Save(policy *model.Policy) (*model.Policy, error)
+	// DeleteByUserID removes the policy of the user from the store
+	DeleteByUserID(userID uint) error
+}
+
+// AuditLogRepository interface is the common interface for a repository
+// Each method checks the entity type.
+type AuditLogRepository interface {
+	// All returns the entries in the order of the chain
+	All() ([]model.AuditLog, error)
+	// Last returns the newest entry
+	Last() (*model.AuditLog, error)
+	// Create adds the entry to the store
+	Create(entry *model.AuditLog) (*model.AuditLog, error)
+	// Checkpoints returns the checkpoints from oldest to newest
+	Checkpoints() ([]model.AuditCheckpoint, error)
+	// LastCheckpoint returns the newest checkpoint
+	LastCheckpoint() (*model.AuditCheckpoint, error)
+	// CreateCheckpoint adds the checkpoint to the store
+	CreateCheckpoint(checkpoint *model.AuditCheckpoint) (*model.AuditCheckpoint, error)
+	// LastBefore returns the newest entry created before the time
+	LastBefore(before time.Time) (*model.AuditLog, error)
+	// CountUntil counts the entries up to and including the id
+	CountUntil(id uint) (int, error)
+	// DeleteUntil deletes the entries up to and including the id with the checkpoints before it
+	DeleteUntil(id uint) error
+}
+
+// AuditEventRepository keeps the audit log of each vault. It's append-only, events
+// can't be changed or deleted through it.
+type AuditEventRepository interface {
+	// FindAll returns the events matching the filter, newest first
+	FindAll(filter *model.AuditEventFilter, schema string) ([]model.AuditEvent, error)
+	// FindAfter returns up to filter.Limit events after the id matching the filter, oldest first
+	FindAfter(filter *model.AuditEventFilter, afterID uint, schema string) ([]model.AuditEvent, error)
+	// Create adds the event to the store
+	Create(event *model.AuditEvent, schema string) (*model.AuditEvent, error)
+}
+
+// ExportJobRepository interface is the common interface for a repository
+// Each method checks the entity type.
+type ExportJobRepository interface {
+	// FindByUUID finds the job with the public id
+	FindByUUID(uuid string) (*model.ExportJob, error)
+	// FindUnfinished finds the queued and running jobs
+	FindUnfinished() ([]model.ExportJob, error)
+	// FindExpired finds the jobs whose archive expired before now
+	FindExpired(now time.Time) ([]model.ExportJob, error)
+	// Save stores the entity to the repository
+	Save(job *model.ExportJob) (*model.ExportJob, error)
+	// Delete removes the job from the store
+	Delete(id uint) error
+}
+
+// ReencryptionRepository keeps the re-encryption jobs and walks the rows of the vaults.
+// Tables are "schema.table" names.
+type ReencryptionRepository interface {
+	// FindByID finds the entity regarding to its ID.
+	FindByID(id uint) (*model.ReencryptionJob, error)
+	// FindUnfinished finds the queued and running jobs in the order they were created
+	FindUnfinished() ([]model.ReencryptionJob, error)
+	// FindRecent finds the newest jobs
+	FindRecent(limit int) ([]model.ReencryptionJob, error)
+	// Save stores the entity to the repository
+	Save(job *model.ReencryptionJob) (*model.ReencryptionJob, error)
+	// CountRows counts the rows of the table, including soft deleted ones
+	CountRows(table string) (int, error)
+	// FindBatch finds up to limit rows after the id into rows, a pointer to a slice of models
+	FindBatch(table string, afterID uint, limit int, rows interface{}) error
+	// SaveBatch updates the columns of the rows by id together with the cursor of the job
+	SaveBatch(job *model.ReencryptionJob, table string, rows map[uint]map[string]interface{}) error
+}
+
+// SchemaMigrationRepository keeps the versions of the migrations applied to a schema in
+// its schema_migrations table
+type SchemaMigrationRepository interface {
+	// FindAll finds the applied migrations in the order of their versions
+	FindAll(schema string) ([]model.SchemaMigration, error)
+	// Create records the migration as applied
+	Create(migration *model.SchemaMigration, schema string) error
+	// Delete records the migration of the version as reverted
+	Delete(version int, schema string) error
+	// Exec runs a DDL statement of a migration, it is written for PostgreSQL and
+	// translated for SQLite
+	Exec(statement string) error
+	// Migrate creates the schema_migrations table
 	Migrate(schema string) error
 }
 
+// RetentionRepository purges old rows of the tables named by the retention policy,
+// tables are "schema.table" for user schemas and plain names for system tables.
+type RetentionRepository interface {
+	// CountBefore counts the rows whose column is before the time, including soft deleted ones
+	CountBefore(table, column string, before time.Time) (int, error)
+	// DeleteBefore deletes the rows whose column is before the time permanently
+	DeleteBefore(table, column string, before time.Time) (int, error)
+}
+
+// TrashRepository keeps the soft deleted items of the vault tables, tables are
+// "schema.table"
+type TrashRepository interface {
+	// FindDeleted finds the deleted rows into rows, a pointer to a slice of models, the
+	// last deleted first
+	FindDeleted(table string, rows interface{}) error
+	// Restore undeletes the deleted row of the id, it counts as an update of the row
+	Restore(table string, id uint) error
+	// Purge deletes the deleted row of the id permanently
+	Purge(table string, id uint) error
+}
+
+// SyncCounterRepository keeps the counter of the changes of each vault, the saves of the
+// items take the next value
+type SyncCounterRepository interface {
+	// Find returns the counter of the vault, a vault without one gets a new one
+	Find(schema string) (*model.SyncCounter, error)
+	// MarkPurged marks the current value as the last purge of deleted items
+	MarkPurged(schema string) error
+}
+
 // SubscriptionRepository interface is the common interface for a repository
 // Each method checks the entity type.
 type SubscriptionRepository interface {
@@ -159,6 +463,4 @@ type SubscriptionRepository interface {
 	Save(subscription *model.Subscription) (*model.Subscription, error)
 	// Delete removes the entity from the store
 	Delete(id uint) error
-	// Migrate migrates the repository
-	Migrate() error
 }
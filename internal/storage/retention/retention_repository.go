@@ -0,0 +1,30 @@
+package retention
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// CountBefore ...
+func (p *Repository) CountBefore(table, column string, before time.Time) (int, error) {
+	count := 0
+	err := p.db.Table(table).Where(column+` < ?`, before).Count(&count).Error
+	return count, err
+}
+
+// DeleteBefore ...
+func (p *Repository) DeleteBefore(table, column string, before time.Time) (int, error) {
+	result := p.db.Exec(`DELETE FROM `+table+` WHERE `+column+` < ?`, before)
+	return int(result.RowsAffected), result.Error
+}
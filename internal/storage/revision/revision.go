@@ -0,0 +1,72 @@
+// Package revision counts the saves of the models with a Revision field. Inserts start it
+// at 1 and each update of the whole model adds 1, so clients can tell if the model they
+// have is still the stored one. An update only saves the row at the revision the model was
+// read at, so of two concurrent saves of the same revision one fails. Updates of single columns, like a restore from the trash
+// or a re-encryption, keep it.
+//
+// Each change of the models with a SyncRevision field also gets the next value of the
+// sync counter of its vault, so clients sync the changes since the last value they saw.
+package revision
+
+import (
+	"errors"
+	"io/ioutil"
+	"log"
+
+	"github.com/jinzhu/gorm"
+)
+
+// Field is the name of the counted field
+const Field = "Revision"
+
+// ErrChanged is returned by saves of models which were changed since they were read
+var ErrChanged = errors.New("model was changed since it was read")
+
+// Register registers the callbacks which count the revisions on the database
+func Register(db *gorm.DB) {
+	// gorm logs each registration, the callbacks are registered on a quiet copy
+	quiet := db.New()
+	quiet.SetLogger(gorm.Logger{LogWriter: log.New(ioutil.Discard, "", 0)})
+	callback := quiet.Callback()
+	if callback.Create().Get("revision:create") != nil {
+		return
+	}
+	callback.Create().Before("gorm:create").Register("revision:create", createCallback)
+	callback.Update().Before("gorm:update").Register("revision:update", updateCallback)
+	callback.Update().After("gorm:update").Register("revision:check", checkCallback)
+	callback.Create().Before("gorm:create").Register("revision:sync_create", syncCreateCallback)
+	callback.Update().Before("gorm:update").Register("revision:sync_update", syncUpdateCallback)
+	callback.Delete().After("gorm:delete").Register("revision:sync_delete", syncDeleteCallback)
+}
+
+func createCallback(scope *gorm.Scope) {
+	if field, ok := scope.FieldByName(Field); ok {
+		scope.Err(field.Set(1))
+	}
+}
+
+func updateCallback(scope *gorm.Scope) {
+	if _, ok := scope.InstanceGet("gorm:update_attrs"); ok {
+		return
+	}
+	if field, ok := scope.FieldByName(Field); ok {
+		read := field.Field.Uint()
+		// Rows from before the field have no revision to compare
+		if read > 0 {
+			scope.Search.Where(scope.Quote(field.DBName)+" = ?", read)
+			scope.InstanceSet("revision:read", read)
+		}
+		scope.Err(field.Set(read + 1))
+	}
+}
+
+// checkCallback fails updates which didn't find the row at the revision it was read at,
+// Save would otherwise take an update of no rows for a missing row and read the stored one
+func checkCallback(scope *gorm.Scope) {
+	if _, ok := scope.InstanceGet("revision:read"); !ok || scope.HasError() {
+		return
+	}
+	if scope.DB().RowsAffected == 0 {
+		scope.Err(ErrChanged)
+	}
+}
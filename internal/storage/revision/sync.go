@@ -0,0 +1,156 @@
+package revision
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+const (
+	// SyncField is the name of the field which keeps the sync revision of the last change
+	SyncField = "SyncRevision"
+	// CounterTable is the table of the sync counter in each vault schema
+	CounterTable = "sync_counters"
+)
+
+// counterID is the id of the single row of the counter table
+const counterID = 1
+
+// Next counts a change of the vault of the schema and returns its sync revision. The
+// counter row stays locked until the transaction of db ends, so the changes commit in the
+// order of their revisions and no sync sees a revision before the changes below it.
+func Next(db *gorm.DB, schema string) (int64, error) {
+	counter := db.Table(schema + "." + CounterTable).Model(&model.SyncCounter{ID: counterID})
+	result := counter.UpdateColumn("value", gorm.Expr("value + ?", 1))
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := Current(db, schema); err != nil {
+			return 0, err
+		}
+		if err := counter.UpdateColumn("value", gorm.Expr("value + ?", 1)).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	current, err := Current(db, schema)
+	if err != nil {
+		return 0, err
+	}
+	return current.Value, nil
+}
+
+// Current returns the counter of the vault of the schema, a vault without one gets a new one
+func Current(db *gorm.DB, schema string) (*model.SyncCounter, error) {
+	table := schema + "." + CounterTable
+	counter := new(model.SyncCounter)
+	err := db.Table(table).Where(`id = ?`, counterID).First(counter).Error
+	if gorm.IsRecordNotFoundError(err) {
+		counter = &model.SyncCounter{ID: counterID, Epoch: newEpoch()}
+		err = db.Table(table).Create(counter).Error
+	}
+	return counter, err
+}
+
+// MarkPurged sets the purge mark of the counter to its value, call it before deleted items
+// are purged
+func MarkPurged(db *gorm.DB, schema string) error {
+	if _, err := Current(db, schema); err != nil {
+		return err
+	}
+	return db.Table(schema+"."+CounterTable).Model(&model.SyncCounter{ID: counterID}).
+		UpdateColumn("purged", gorm.Expr("value")).Error
+}
+
+// Change runs a change of the rows of the item table, like "user1.logins", which bypasses
+// the callbacks with the next sync revision of its vault. Both are in one transaction.
+func Change(db *gorm.DB, table string, change func(tx *gorm.DB, syncRevision int64) error) error {
+	return transaction(db, func(tx *gorm.DB) error {
+		syncRevision, err := Next(tx, schemaOf(table))
+		if err != nil {
+			return err
+		}
+		return change(tx, syncRevision)
+	})
+}
+
+func syncCreateCallback(scope *gorm.Scope) {
+	if _, ok := scope.FieldByName(SyncField); !ok || scope.HasError() {
+		return
+	}
+	stamp(scope)
+}
+
+func syncUpdateCallback(scope *gorm.Scope) {
+	// Updates of single columns, like re-encryptions, don't change the item for clients
+	if _, ok := scope.Get("gorm:update_column"); ok {
+		return
+	}
+	if _, ok := scope.FieldByName(SyncField); !ok || scope.HasError() {
+		return
+	}
+	stamp(scope)
+}
+
+func syncDeleteCallback(scope *gorm.Scope) {
+	if _, ok := scope.FieldByName(SyncField); !ok || scope.HasError() {
+		return
+	}
+	_, softDelete := scope.FieldByName("DeletedAt")
+	if !softDelete || scope.Search.Unscoped || scope.PrimaryKeyZero() {
+		return
+	}
+
+	schema := schemaOf(scope.TableName())
+	if schema == "" {
+		return
+	}
+	syncRevision, err := Next(scope.NewDB(), schema)
+	if scope.Err(err) != nil {
+		return
+	}
+	scope.Err(scope.NewDB().Exec(`UPDATE `+scope.QuotedTableName()+` SET sync_revision = ? WHERE `+
+		scope.Quote(scope.PrimaryKey())+` = ?`, syncRevision, scope.PrimaryKeyValue()).Error)
+}
+
+// stamp sets the next sync revision of the vault of the table of the scope
+func stamp(scope *gorm.Scope) {
+	schema := schemaOf(scope.TableName())
+	if schema == "" {
+		return
+	}
+	syncRevision, err := Next(scope.NewDB(), schema)
+	if scope.Err(err) != nil {
+		return
+	}
+	scope.Err(scope.SetColumn(SyncField, syncRevision))
+}
+
+// schemaOf returns the schema of a table like "user1.logins", empty without one
+func schemaOf(table string) string {
+	if i := strings.LastIndex(table, "."); i > 0 {
+		return strings.Trim(table[:i], `"`)
+	}
+	return ""
+}
+
+// newEpoch returns a random id of a new counter
+func newEpoch() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// transaction runs fn in a transaction, or in the transaction the db is already in since
+// gorm can't nest them
+func transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if _, ok := db.CommonDB().(*sql.Tx); ok {
+		return fn(db)
+	}
+	return db.Transaction(fn)
+}
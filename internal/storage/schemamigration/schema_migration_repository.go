@@ -0,0 +1,52 @@
+package schemamigration
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage/sqlite"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindAll ...
+func (p *Repository) FindAll(schema string) ([]model.SchemaMigration, error) {
+	migrations := []model.SchemaMigration{}
+	err := p.db.Table(schema + ".schema_migrations").Order("version").Find(&migrations).Error
+	return migrations, err
+}
+
+// Create ...
+func (p *Repository) Create(migration *model.SchemaMigration, schema string) error {
+	return p.db.Table(schema + ".schema_migrations").Create(migration).Error
+}
+
+// Delete ...
+func (p *Repository) Delete(version int, schema string) error {
+	return p.db.Table(schema+".schema_migrations").Where("version = ?", version).Delete(&model.SchemaMigration{}).Error
+}
+
+// Exec ...
+func (p *Repository) Exec(statement string) error {
+	if p.db.Dialect().GetName() == sqlite.Driver {
+		return sqlite.ExecDDL(p.db, statement)
+	}
+	return p.db.Exec(statement).Error
+}
+
+// Migrate ...
+func (p *Repository) Migrate(schema string) error {
+	return p.Exec(`CREATE TABLE IF NOT EXISTS ` + schema + `.schema_migrations (
+		version integer,
+		name text,
+		applied_at timestamp with time zone,
+		PRIMARY KEY (version)
+	)`)
+}
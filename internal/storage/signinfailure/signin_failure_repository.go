@@ -0,0 +1,43 @@
+package signinfailure
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindByKey ...
+func (p *Repository) FindByKey(key string) (*model.SigninFailure, error) {
+	failure := new(model.SigninFailure)
+	err := p.db.Where(`key = ?`, key).First(&failure).Error
+	return failure, err
+}
+
+// Save ...
+func (p *Repository) Save(failure *model.SigninFailure) (*model.SigninFailure, error) {
+	err := p.db.Save(&failure).Error
+	return failure, err
+}
+
+// DeleteByKey ...
+func (p *Repository) DeleteByKey(key string) error {
+	err := p.db.Where(`key = ?`, key).Delete(&model.SigninFailure{}).Error
+	return err
+}
+
+// DeleteBefore ...
+func (p *Repository) DeleteBefore(before time.Time) (int, error) {
+	result := p.db.Where(`last_failure_at < ?`, before).Delete(&model.SigninFailure{})
+	return int(result.RowsAffected), result.Error
+}
@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+var (
+	addColumnRegex  = regexp.MustCompile(`^ALTER TABLE (\S+) ADD COLUMN IF NOT EXISTS (\S+) (.+)$`)
+	dropColumnRegex = regexp.MustCompile(`^ALTER TABLE (\S+) DROP COLUMN IF EXISTS (\S+)$`)
+	indexRegex      = regexp.MustCompile(`^(?i)(CREATE (?:UNIQUE )?INDEX (?:IF NOT EXISTS )?)(\S+) ON (\w+)\.`)
+
+	// PostgreSQL column types of the migrations and their SQLite ones
+	ddlTypes = strings.NewReplacer(
+		"serial PRIMARY KEY", "integer PRIMARY KEY AUTOINCREMENT",
+		"timestamp with time zone", "datetime",
+	)
+)
+
+// ExecDDL runs a DDL statement of the migrations, which are written for PostgreSQL.
+// Column types are translated, indexes of "schema.table" are named after the schema,
+// ADD COLUMN IF NOT EXISTS skips columns the table has and DROP COLUMN IF EXISTS, which
+// SQLite 3.30 doesn't have, rebuilds the table without the column.
+func ExecDDL(db *gorm.DB, statement string) error {
+	statement = strings.TrimSpace(ddlTypes.Replace(statement))
+	statement = indexRegex.ReplaceAllString(statement, "${1}$3.$2 ON $3.")
+	if m := addColumnRegex.FindStringSubmatch(statement); m != nil {
+		if db.Dialect().HasColumn(m[1], strings.Trim(m[2], `"`)) {
+			return nil
+		}
+		return db.Exec("ALTER TABLE " + m[1] + " ADD COLUMN " + m[2] + " " + m[3]).Error
+	}
+	if m := dropColumnRegex.FindStringSubmatch(statement); m != nil {
+		return dropColumn(db, m[1], m[2])
+	}
+	return db.Exec(statement).Error
+}
+
+// dropColumn copies the other columns of the table into a new table in its place and
+// creates its indexes again
+func dropColumn(db *gorm.DB, tableName, column string) error {
+	table := TableName(tableName)
+	var create string
+	err := db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?", table).Row().Scan(&create)
+	if err == sql.ErrNoRows {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	open, end := strings.Index(create, "("), strings.LastIndex(create, ")")
+	if open < 0 || end < open {
+		return fmt.Errorf("table %s can't be rebuilt", tableName)
+	}
+
+	var definitions, columns []string
+	dropped := false
+	for _, definition := range splitDefinitions(create[open+1 : end]) {
+		name := strings.Trim(strings.Fields(definition)[0], "\"`[]")
+		switch {
+		case strings.EqualFold(name, column):
+			dropped = true
+			continue
+		case isConstraint(name):
+		default:
+			columns = append(columns, `"`+name+`"`)
+		}
+		definitions = append(definitions, definition)
+	}
+	if !dropped {
+		return nil
+	}
+
+	rows, err := db.Raw("SELECT sql FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND sql IS NOT NULL", table).Rows()
+	if err != nil {
+		return err
+	}
+	var indexes []string
+	for rows.Next() {
+		var index string
+		if err := rows.Scan(&index); err != nil {
+			rows.Close()
+			return err
+		}
+		indexes = append(indexes, index)
+	}
+	rows.Close()
+
+	rebuilt := `"passwall_rebuild_` + table + `"`
+	list := strings.Join(columns, ", ")
+	for _, statement := range []string{
+		"CREATE TABLE " + rebuilt + " (" + strings.Join(definitions, ", ") + ")",
+		"INSERT INTO " + rebuilt + " (" + list + ") SELECT " + list + ` FROM "` + table + `"`,
+		`DROP TABLE "` + table + `"`,
+		"ALTER TABLE " + rebuilt + ` RENAME TO "` + table + `"`,
+	} {
+		if err := db.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	for _, index := range indexes {
+		// Indexes of the dropped column are gone with it
+		if strings.Contains(index, `"`+column+`"`) || strings.Contains(index, "("+column+")") {
+			continue
+		}
+		if err := db.Exec(index).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitDefinitions splits the column definitions and constraints of a CREATE TABLE at
+// the commas which aren't in parentheses
+func splitDefinitions(body string) []string {
+	var definitions []string
+	depth, start := 0, 0
+	for i, c := range body {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				definitions = append(definitions, strings.TrimSpace(body[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	return append(definitions, strings.TrimSpace(body[start:]))
+}
+
+func isConstraint(name string) bool {
+	switch strings.ToUpper(name) {
+	case "PRIMARY", "UNIQUE", "CHECK", "FOREIGN", "CONSTRAINT":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,310 @@
+// Package sqlite provides the embedded SQLite database of the single binary mode.
+//
+// SQLite has no schemas, so all tables are kept in the one database file and the
+// tables of a schema get its name as a prefix, user1.logins is the table user1__logins.
+// The connections rewrite the "schema.table" names of the statements, this way
+// repositories can keep using them. An in-memory database works the same way.
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+	sqlite3 "github.com/mattn/go-sqlite3"
+)
+
+// Driver is the name of the sql driver and gorm dialect
+const Driver = "passwall-sqlite3"
+
+// Memory is the path of an in-memory database, its data is lost when it is closed
+const Memory = ":memory:"
+
+// Separator joins the schema and the name of its tables and indexes
+const Separator = "__"
+
+var (
+	// Schema names can't contain the separator or end with an underscore, so the
+	// prefix of one schema is never the start of another one
+	schemaRegex = regexp.MustCompile(`^[a-z](?:_?[a-z0-9])*$`)
+)
+
+func init() {
+	sql.Register(Driver, &prefixDriver{})
+	gorm.RegisterDialect(Driver, &dialect{})
+}
+
+// Open opens the database file at path and creates its folder if needed
+func Open(path string) (*gorm.DB, error) {
+	if path != Memory {
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, err
+		}
+	}
+
+	db, err := gorm.Open(Driver, path)
+	if err != nil {
+		return nil, fmt.Errorf("could not open sqlite database: %w", err)
+	}
+
+	// An in-memory database lives as long as its connection and SQLite has a single
+	// writer anyway, so the pool keeps one connection open
+	db.DB().SetMaxOpenConns(1)
+	db.DB().SetMaxIdleConns(1)
+
+	return db, nil
+}
+
+// CreateSchema checks the name of the schema, its tables are created by the migrations
+func CreateSchema(db *gorm.DB, schema string) error {
+	if !schemaRegex.MatchString(schema) {
+		return fmt.Errorf("invalid schema name %q", schema)
+	}
+	return nil
+}
+
+// DropSchema drops the tables of the schema with their indexes
+func DropSchema(db *gorm.DB, schema string) error {
+	if !schemaRegex.MatchString(schema) || schema == "public" {
+		return fmt.Errorf("invalid schema name %q", schema)
+	}
+
+	rows, err := db.Raw(`SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE ? ESCAPE '\'`,
+		strings.Replace(schema+Separator, "_", `\_`, -1)+"%").Rows()
+	if err != nil {
+		return err
+	}
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err := rows.Scan(&table); err != nil {
+			rows.Close()
+			return err
+		}
+		tables = append(tables, table)
+	}
+	rows.Close()
+
+	for _, table := range tables {
+		if err := db.Exec(`DROP TABLE "` + table + `"`).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TableName returns the table of the main database which keeps the "schema.table"
+func TableName(name string) string {
+	return strings.Replace(name, ".", Separator, 1)
+}
+
+// prefixDriver opens go-sqlite3 connections which rewrite the "schema.table" names
+type prefixDriver struct {
+	sqlite3.SQLiteDriver
+}
+
+// Open ...
+func (d *prefixDriver) Open(dsn string) (driver.Conn, error) {
+	conn, err := d.SQLiteDriver.Open(dsn)
+	if err != nil {
+		return nil, err
+	}
+	return &prefixConn{conn.(*sqlite3.SQLiteConn)}, nil
+}
+
+// prefixConn passes the statements with prefixed table names to the SQLite connection
+type prefixConn struct {
+	*sqlite3.SQLiteConn
+}
+
+// Prepare ...
+func (c *prefixConn) Prepare(query string) (driver.Stmt, error) {
+	return c.SQLiteConn.Prepare(prefixTables(query))
+}
+
+// PrepareContext ...
+func (c *prefixConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	return c.SQLiteConn.PrepareContext(ctx, prefixTables(query))
+}
+
+// Exec ...
+func (c *prefixConn) Exec(query string, args []driver.Value) (driver.Result, error) {
+	return c.SQLiteConn.Exec(prefixTables(query), args)
+}
+
+// ExecContext ...
+func (c *prefixConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	return c.SQLiteConn.ExecContext(ctx, prefixTables(query), args)
+}
+
+// Query ...
+func (c *prefixConn) Query(query string, args []driver.Value) (driver.Rows, error) {
+	return c.SQLiteConn.Query(prefixTables(query), args)
+}
+
+// QueryContext ...
+func (c *prefixConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	return c.SQLiteConn.QueryContext(ctx, prefixTables(query), args)
+}
+
+// tableKeywords come before the table and index names of the statements. Other names
+// with two parts are a table and a column, like "users"."deleted_at" of gorm.
+var tableKeywords = map[string]bool{
+	"FROM":   true,
+	"INTO":   true,
+	"UPDATE": true,
+	"JOIN":   true,
+	"TABLE":  true,
+	"INDEX":  true,
+	"EXISTS": true,
+	"ON":     true,
+	"TO":     true,
+}
+
+// prefixTables rewrites the "schema.table" names of the query to the prefixed tables,
+// names with three parts are a schema, a table and a column. Strings are kept as they are.
+func prefixTables(query string) string {
+	if !strings.Contains(query, ".") {
+		return query
+	}
+
+	var b strings.Builder
+	keyword := ""
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case c == '\'':
+			end := i + 1
+			for end < len(query) {
+				if query[end] == '\'' {
+					if end+1 < len(query) && query[end+1] == '\'' {
+						end += 2
+						continue
+					}
+					break
+				}
+				end++
+			}
+			if end < len(query) {
+				end++
+			}
+			b.WriteString(query[i:end])
+			i, keyword = end, ""
+		case c == '"' || c == '_' || isLetter(c):
+			parts, end := readName(query, i)
+			star := len(parts) == 2 && strings.HasPrefix(query[end:], ".*")
+			switch {
+			case len(parts) > 1 && (parts[0] == "main" || parts[0] == "temp"):
+				b.WriteString(query[i:end])
+			case len(parts) == 3 || star || (len(parts) == 2 && tableKeywords[keyword]):
+				b.WriteString(`"` + parts[0] + Separator + parts[1] + `"`)
+				for _, part := range parts[2:] {
+					b.WriteString(`."` + part + `"`)
+				}
+			default:
+				b.WriteString(query[i:end])
+			}
+			keyword = ""
+			if len(parts) == 1 && c != '"' {
+				keyword = strings.ToUpper(parts[0])
+			}
+			i = end
+		default:
+			b.WriteByte(c)
+			if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+				keyword = ""
+			}
+			i++
+		}
+	}
+	return b.String()
+}
+
+// readName reads the parts of the name which starts at i, like "user1"."logins" or
+// user1.logins, and returns them unquoted with the end of the name
+func readName(query string, i int) ([]string, int) {
+	var parts []string
+	for {
+		var part string
+		if query[i] == '"' {
+			end := strings.IndexByte(query[i+1:], '"')
+			if end < 0 {
+				return append(parts, query[i:]), len(query)
+			}
+			part, i = query[i+1:i+1+end], i+end+2
+		} else {
+			start := i
+			for i < len(query) && (query[i] == '_' || isLetter(query[i]) || (query[i] >= '0' && query[i] <= '9')) {
+				i++
+			}
+			part = query[start:i]
+		}
+		parts = append(parts, part)
+
+		if i+1 >= len(query) || query[i] != '.' || !(query[i+1] == '"' || query[i+1] == '_' || isLetter(query[i+1])) {
+			return parts, i
+		}
+		i++
+	}
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// dialect is the gorm sqlite3 dialect which finds the prefixed tables of "schema.table" names
+type dialect struct {
+	gorm.Dialect
+	db gorm.SQLCommon
+}
+
+// SetDB creates the wrapped sqlite3 dialect, gorm creates a new dialect value per connection
+func (d *dialect) SetDB(db gorm.SQLCommon) {
+	sqliteDialect, _ := gorm.GetDialect("sqlite3")
+	d.Dialect = reflect.New(reflect.TypeOf(sqliteDialect).Elem()).Interface().(gorm.Dialect)
+	d.Dialect.SetDB(db)
+	d.db = db
+}
+
+// GetName returns the registered name, so gorm keeps this dialect when it clones the db
+func (d *dialect) GetName() string {
+	return Driver
+}
+
+// HasTable checks if the table exists in its schema
+func (d *dialect) HasTable(tableName string) bool {
+	return d.count("SELECT count(*) FROM sqlite_master WHERE type = 'table' AND name = ?", TableName(tableName))
+}
+
+// HasColumn checks if the column exists in the table of its schema
+func (d *dialect) HasColumn(tableName string, columnName string) bool {
+	return d.count("SELECT count(*) FROM pragma_table_info(?) WHERE name = ?", TableName(tableName), columnName)
+}
+
+// HasIndex checks if the index exists in the schema of the table
+func (d *dialect) HasIndex(tableName string, indexName string) bool {
+	return d.count("SELECT count(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = ? AND name = ?",
+		TableName(tableName), prefixedIndex(tableName, indexName))
+}
+
+func (d *dialect) count(query string, args ...interface{}) bool {
+	var count int
+	d.db.QueryRow(query, args...).Scan(&count)
+	return count > 0
+}
+
+// prefixedIndex returns the prefixed name of the index of the table, indexes are named
+// after the schema of their table
+func prefixedIndex(tableName, index string) string {
+	if i := strings.Index(tableName, "."); i > 0 {
+		return tableName[:i] + Separator + index
+	}
+	return index
+}
@@ -0,0 +1,139 @@
+package sqlite
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type item struct {
+	ID    uint `gorm:"primary_key"`
+	Title string
+}
+
+func TestSchemaTables(t *testing.T) {
+	dir, err := ioutil.TempDir("", "passwall-sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	db, err := Open(filepath.Join(dir, "passwall.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	assert.NoError(t, CreateSchema(db, "user1"))
+	assert.NoError(t, CreateSchema(db, "user1"))
+	assert.Error(t, CreateSchema(db, "user1; DROP TABLE users"))
+
+	// Migrating twice must find the existing table and columns
+	assert.NoError(t, db.Table("user1.items").AutoMigrate(&item{}).Error)
+	assert.NoError(t, db.Table("user1.items").AutoMigrate(&item{}).Error)
+	assert.True(t, db.Dialect().HasTable("user1.items"))
+	assert.True(t, db.Dialect().HasColumn("user1.items", "title"))
+	assert.False(t, db.Dialect().HasTable("public.items"))
+
+	assert.NoError(t, db.Table("user1.items").Create(&item{Title: "passwall"}).Error)
+	found := new(item)
+	assert.NoError(t, db.Table("user1.items").Where("title = ?", "passwall").First(found).Error)
+	assert.Equal(t, uint(1), found.ID)
+
+	// The schema is a prefix of the tables in the one file
+	var name string
+	assert.NoError(t, db.Raw("SELECT name FROM sqlite_master WHERE type = 'table' AND name LIKE 'user1%'").Row().Scan(&name))
+	assert.Equal(t, "user1__items", name)
+	_, err = os.Stat(filepath.Join(dir, "schemas"))
+	assert.True(t, os.IsNotExist(err))
+
+	// Dropping a schema keeps the tables of the schemas which start like it
+	assert.NoError(t, db.Table("user1_decoy.items").AutoMigrate(&item{}).Error)
+	assert.NoError(t, DropSchema(db, "user1"))
+	assert.False(t, db.Dialect().HasTable("user1.items"))
+	assert.True(t, db.Dialect().HasTable("user1_decoy.items"))
+	assert.Error(t, CreateSchema(db, "user1__items"))
+	assert.Error(t, CreateSchema(db, "user1_"))
+}
+
+func TestPrefixTables(t *testing.T) {
+	for query, prefixed := range map[string]string{
+		`SELECT * FROM "user1"."logins" WHERE "user1"."logins"."deleted_at" IS NULL`: `SELECT * FROM "user1__logins" WHERE "user1__logins"."deleted_at" IS NULL`,
+		`SELECT count(*) FROM user1.logins`:                                          `SELECT count(*) FROM "user1__logins"`,
+		`INSERT INTO "public"."schema_migrations" ("version") VALUES (?)`:            `INSERT INTO "public__schema_migrations" ("version") VALUES (?)`,
+		`UPDATE "users" SET "name" = ? WHERE "users"."deleted_at" IS NULL`:           `UPDATE "users" SET "name" = ? WHERE "users"."deleted_at" IS NULL`,
+		`SELECT "user1"."logins".* FROM user1.logins`:                                `SELECT "user1__logins".* FROM "user1__logins"`,
+		`CREATE INDEX IF NOT EXISTS user1.idx_title ON user1.items (title)`:          `CREATE INDEX IF NOT EXISTS "user1__idx_title" ON "user1__items" (title)`,
+		`SELECT id FROM user1.notes WHERE note = 'user1.notes' AND x = 'it''s a.b'`:  `SELECT id FROM "user1__notes" WHERE note = 'user1.notes' AND x = 'it''s a.b'`,
+		`SELECT sql FROM main.sqlite_master WHERE name = ?`:                          `SELECT sql FROM main.sqlite_master WHERE name = ?`,
+		`SELECT 1.5 FROM users`: `SELECT 1.5 FROM users`,
+	} {
+		assert.Equal(t, prefixed, prefixTables(query), query)
+	}
+}
+
+func TestMemorySchemas(t *testing.T) {
+	db, err := Open(Memory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	assert.NoError(t, CreateSchema(db, "user1"))
+	assert.NoError(t, db.Table("public.items").AutoMigrate(&item{}).Error)
+	assert.NoError(t, db.Table("user1.items").AutoMigrate(&item{}).Error)
+	assert.NoError(t, db.Table("user1.items").Create(&item{Title: "passwall"}).Error)
+
+	count := 0
+	assert.NoError(t, db.Table("user1.items").Count(&count).Error)
+	assert.Equal(t, 1, count)
+	assert.NoError(t, db.Table("public.items").Count(&count).Error)
+	assert.Equal(t, 0, count)
+
+	assert.NoError(t, DropSchema(db, "user1"))
+	assert.False(t, db.Dialect().HasTable("user1.items"))
+}
+
+func TestExecDDL(t *testing.T) {
+	db, err := Open(Memory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	assert.NoError(t, CreateSchema(db, "user1"))
+
+	for _, statement := range []string{
+		`CREATE TABLE IF NOT EXISTS user1.items (id serial PRIMARY KEY, created_at timestamp with time zone, title text, "table" text)`,
+		`CREATE INDEX IF NOT EXISTS idx_items_title ON user1.items (title)`,
+		`ALTER TABLE user1.items ADD COLUMN IF NOT EXISTS pinned boolean`,
+		`ALTER TABLE user1.items ADD COLUMN IF NOT EXISTS pinned boolean`,
+		`ALTER TABLE user1.items ADD COLUMN IF NOT EXISTS "table" text`,
+	} {
+		assert.NoError(t, ExecDDL(db, statement))
+	}
+	assert.NoError(t, db.Table("user1.items").Create(&item{Title: "passwall"}).Error)
+	assert.True(t, db.Dialect().HasColumn("user1.items", "pinned"))
+
+	// Dropping a column keeps the rows, the ids and the other columns
+	assert.NoError(t, ExecDDL(db, `ALTER TABLE user1.items DROP COLUMN IF EXISTS pinned`))
+	assert.NoError(t, ExecDDL(db, `ALTER TABLE user1.items DROP COLUMN IF EXISTS pinned`))
+	assert.NoError(t, ExecDDL(db, `ALTER TABLE user1.missing DROP COLUMN IF EXISTS pinned`))
+	assert.False(t, db.Dialect().HasColumn("user1.items", "pinned"))
+	assert.True(t, db.Dialect().HasColumn("user1.items", "table"))
+	found := new(item)
+	assert.NoError(t, db.Table("user1.items").First(found).Error)
+	assert.Equal(t, &item{ID: 1, Title: "passwall"}, found)
+	assert.NoError(t, db.Table("user1.items").Create(&item{Title: "next"}).Error)
+	last := new(item)
+	assert.NoError(t, db.Table("user1.items").Last(last).Error)
+	assert.Equal(t, uint(2), last.ID)
+
+	assert.True(t, db.Dialect().HasIndex("user1.items", "idx_items_title"))
+
+	// Indexes of a dropped column are dropped with it
+	assert.NoError(t, ExecDDL(db, `ALTER TABLE user1.items DROP COLUMN IF EXISTS title`))
+	assert.False(t, db.Dialect().HasIndex("user1.items", "idx_items_title"))
+}
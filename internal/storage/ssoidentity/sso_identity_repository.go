@@ -0,0 +1,35 @@
+package ssoidentity
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindBySubject ...
+func (p *Repository) FindBySubject(provider, subject string) (*model.SSOIdentity, error) {
+	identity := new(model.SSOIdentity)
+	err := p.db.Where(`provider = ? AND subject = ?`, provider, subject).First(&identity).Error
+	return identity, err
+}
+
+// Save ...
+func (p *Repository) Save(identity *model.SSOIdentity) (*model.SSOIdentity, error) {
+	err := p.db.Save(&identity).Error
+	return identity, err
+}
+
+// DeleteByUserID ...
+func (p *Repository) DeleteByUserID(userID uint) error {
+	err := p.db.Where(`user_id = ?`, userID).Delete(&model.SSOIdentity{}).Error
+	return err
+}
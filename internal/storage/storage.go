@@ -1,15 +1,45 @@
 package storage
 
+import "database/sql"
+
+// TxFunc is run by Store.Transaction with a Store whose repositories are bound to the
+// transaction
+type TxFunc func(tx Store) error
+
 // Store is the minimal interface for the various repositories
 type Store interface {
 	Logins() LoginRepository
+	PasswordHistories() PasswordHistoryRepository
+	ItemVersions() ItemVersionRepository
+	Attachments() AttachmentRepository
+	Folders() FolderRepository
+	Tags() TagRepository
 	CreditCards() CreditCardRepository
 	BankAccounts() BankAccountRepository
 	Notes() NoteRepository
 	Emails() EmailRepository
+	EquivalentDomains() EquivalentDomainRepository
+	WebAuthnCredentials() WebAuthnCredentialRepository
 	Tokens() TokenRepository
 	Users() UserRepository
 	Servers() ServerRepository
 	Subscriptions() SubscriptionRepository
+	MachineAccounts() MachineAccountRepository
+	Policies() PolicyRepository
+	SSOIdentities() SSOIdentityRepository
+	AccessTokens() PersonalAccessTokenRepository
+	TrustedDevices() TrustedDeviceRepository
+	SigninFailures() SigninFailureRepository
+	AuditLogs() AuditLogRepository
+	AuditEvents() AuditEventRepository
+	ExportJobs() ExportJobRepository
+	Retention() RetentionRepository
+	Trash() TrashRepository
+	SyncCounters() SyncCounterRepository
+	Reencryption() ReencryptionRepository
+	SchemaMigrations() SchemaMigrationRepository
+	Transaction(fn TxFunc) error
 	Ping() error
+	PendingMigrations() []string
+	PoolStats() map[string]sql.DBStats
 }
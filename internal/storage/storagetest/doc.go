@@ -0,0 +1,10 @@
+// Package storagetest provides mocks of storage.Store and all of its repositories,
+// so the application layer can be unit tested without a database.
+//
+//	mocks := storagetest.NewMocks()
+//	mocks.Logins.On("FindByID", uint(1), "user1").Return(&model.Login{ID: 1}, nil)
+//	login, err := app.FindItem(mocks.Store, app.LoginItem, 1, "user1")
+//	mocks.AssertExpectations(t)
+package storagetest
+
+//go:generate go run gen.go
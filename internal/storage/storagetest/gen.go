@@ -0,0 +1,209 @@
+//go:build ignore
+// +build ignore
+
+// gen writes mocks.go with testify mocks of all interfaces in the storage package.
+// Run it with go generate after changing a repository interface.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const storagePath = "github.com/passwall/passwall-server/internal/storage"
+
+type generator struct {
+	fset    *token.FileSet
+	buf     bytes.Buffer
+	imports map[string]string // name -> path
+	used    map[string]bool
+}
+
+func main() {
+	g := &generator{
+		fset:    token.NewFileSet(),
+		imports: map[string]string{"storage": storagePath},
+		used:    map[string]bool{"mock": true},
+	}
+
+	pkgs, err := parser.ParseDir(g.fset, "..", func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var specs []*ast.TypeSpec
+	for _, file := range pkgs["storage"].Files {
+		for _, imp := range file.Imports {
+			path := strings.Trim(imp.Path.Value, `"`)
+			name := filepath.Base(path)
+			if imp.Name != nil {
+				name = imp.Name.Name
+			}
+			g.imports[name] = path
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if _, ok := ts.Type.(*ast.InterfaceType); ok {
+					specs = append(specs, ts)
+				}
+			}
+		}
+	}
+	sort.Slice(specs, func(i, j int) bool { return specs[i].Name.Name < specs[j].Name.Name })
+
+	for _, ts := range specs {
+		g.writeMock(ts.Name.Name, ts.Type.(*ast.InterfaceType))
+	}
+
+	if err := ioutil.WriteFile("mocks.go", g.source(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (g *generator) writeMock(name string, iface *ast.InterfaceType) {
+	fmt.Fprintf(&g.buf, "// %s is a mock of storage.%s\n", name, name)
+	fmt.Fprintf(&g.buf, "type %s struct {\n\tmock.Mock\n}\n\n", name)
+
+	for _, method := range iface.Methods.List {
+		fn := method.Type.(*ast.FuncType)
+		for _, methodName := range method.Names {
+			g.writeMethod(name, methodName.Name, fn)
+		}
+	}
+}
+
+func (g *generator) writeMethod(mockName, name string, fn *ast.FuncType) {
+	var params, args []string
+	i := 0
+	for _, field := range fn.Params.List {
+		typ := g.typeString(field.Type)
+		names := field.Names
+		if len(names) == 0 {
+			names = []*ast.Ident{ast.NewIdent(fmt.Sprintf("a%d", i))}
+		}
+		for _, n := range names {
+			params = append(params, n.Name+" "+typ)
+			args = append(args, n.Name)
+			i++
+		}
+	}
+
+	var results []string
+	if fn.Results != nil {
+		for _, field := range fn.Results.List {
+			count := len(field.Names)
+			if count == 0 {
+				count = 1
+			}
+			for j := 0; j < count; j++ {
+				results = append(results, g.typeString(field.Type))
+			}
+		}
+	}
+
+	fmt.Fprintf(&g.buf, "// %s mocks storage.%s.%s\n", name, mockName, name)
+	fmt.Fprintf(&g.buf, "func (m *%s) %s(%s)", mockName, name, strings.Join(params, ", "))
+	if len(results) > 1 {
+		fmt.Fprintf(&g.buf, " (%s)", strings.Join(results, ", "))
+	} else if len(results) == 1 {
+		fmt.Fprintf(&g.buf, " %s", results[0])
+	}
+	g.buf.WriteString(" {\n")
+
+	if len(results) == 0 {
+		fmt.Fprintf(&g.buf, "\tm.Called(%s)\n}\n\n", strings.Join(args, ", "))
+		return
+	}
+
+	fmt.Fprintf(&g.buf, "\tret := m.Called(%s)\n", strings.Join(args, ", "))
+	var returns []string
+	for j, typ := range results {
+		r := fmt.Sprintf("r%d", j)
+		returns = append(returns, r)
+		if typ == "error" {
+			fmt.Fprintf(&g.buf, "\t%s := ret.Error(%d)\n", r, j)
+			continue
+		}
+		fmt.Fprintf(&g.buf, "\tvar %s %s\n", r, typ)
+		fmt.Fprintf(&g.buf, "\tif ret.Get(%d) != nil {\n\t\t%s = ret.Get(%d).(%s)\n\t}\n", j, r, j, typ)
+	}
+	fmt.Fprintf(&g.buf, "\treturn %s\n}\n\n", strings.Join(returns, ", "))
+}
+
+// typeString prints the type as it is written in the mocks package
+func (g *generator) typeString(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.Ident:
+		if ast.IsExported(t.Name) {
+			g.used["storage"] = true
+			return "storage." + t.Name
+		}
+		return t.Name
+	case *ast.SelectorExpr:
+		pkg := t.X.(*ast.Ident).Name
+		g.used[pkg] = true
+		return pkg + "." + t.Sel.Name
+	case *ast.StarExpr:
+		return "*" + g.typeString(t.X)
+	case *ast.ArrayType:
+		return "[]" + g.typeString(t.Elt)
+	case *ast.MapType:
+		return "map[" + g.typeString(t.Key) + "]" + g.typeString(t.Value)
+	case *ast.InterfaceType:
+		return "interface{}"
+	}
+	log.Fatalf("unsupported type %T", expr)
+	return ""
+}
+
+func (g *generator) source() []byte {
+	g.imports["mock"] = "github.com/stretchr/testify/mock"
+
+	// Standard library imports come first
+	var std, others []string
+	for name := range g.used {
+		path := g.imports[name]
+		spec := fmt.Sprintf("%q", path)
+		if filepath.Base(path) != name {
+			spec = name + " " + spec
+		}
+		if strings.Contains(strings.Split(path, "/")[0], ".") {
+			others = append(others, spec)
+		} else {
+			std = append(std, spec)
+		}
+	}
+	sort.Strings(std)
+	sort.Strings(others)
+	imports := append(std, "")
+	imports = append(imports, others...)
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by gen.go; DO NOT EDIT.\n\n")
+	out.WriteString("package storagetest\n\n")
+	out.WriteString("import (\n\t" + strings.Join(imports, "\n\t") + "\n)\n\n")
+	out.Write(g.buf.Bytes())
+
+	src, err := format.Source(out.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	return src
+}
@@ -0,0 +1,2016 @@
+// Code generated by gen.go; DO NOT EDIT.
+
+package storagetest
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/mock"
+)
+
+// AttachmentRepository is a mock of storage.AttachmentRepository
+type AttachmentRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.AttachmentRepository.All
+func (m *AttachmentRepository) All(schema string) ([]model.Attachment, error) {
+	ret := m.Called(schema)
+	var r0 []model.Attachment
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Attachment)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByItem mocks storage.AttachmentRepository.FindByItem
+func (m *AttachmentRepository) FindByItem(itemType string, itemID uint, schema string) ([]model.Attachment, error) {
+	ret := m.Called(itemType, itemID, schema)
+	var r0 []model.Attachment
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Attachment)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.AttachmentRepository.FindByID
+func (m *AttachmentRepository) FindByID(id uint, schema string) (*model.Attachment, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.Attachment
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Attachment)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindOrphans mocks storage.AttachmentRepository.FindOrphans
+func (m *AttachmentRepository) FindOrphans(itemType string, itemTable string, schema string) ([]model.Attachment, error) {
+	ret := m.Called(itemType, itemTable, schema)
+	var r0 []model.Attachment
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Attachment)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// TotalSize mocks storage.AttachmentRepository.TotalSize
+func (m *AttachmentRepository) TotalSize(schema string) (int64, error) {
+	ret := m.Called(schema)
+	var r0 int64
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(int64)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Create mocks storage.AttachmentRepository.Create
+func (m *AttachmentRepository) Create(attachment *model.Attachment, schema string) error {
+	ret := m.Called(attachment, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// Delete mocks storage.AttachmentRepository.Delete
+func (m *AttachmentRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// AuditEventRepository is a mock of storage.AuditEventRepository
+type AuditEventRepository struct {
+	mock.Mock
+}
+
+// FindAll mocks storage.AuditEventRepository.FindAll
+func (m *AuditEventRepository) FindAll(filter *model.AuditEventFilter, schema string) ([]model.AuditEvent, error) {
+	ret := m.Called(filter, schema)
+	var r0 []model.AuditEvent
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.AuditEvent)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindAfter mocks storage.AuditEventRepository.FindAfter
+func (m *AuditEventRepository) FindAfter(filter *model.AuditEventFilter, afterID uint, schema string) ([]model.AuditEvent, error) {
+	ret := m.Called(filter, afterID, schema)
+	var r0 []model.AuditEvent
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.AuditEvent)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Create mocks storage.AuditEventRepository.Create
+func (m *AuditEventRepository) Create(event *model.AuditEvent, schema string) (*model.AuditEvent, error) {
+	ret := m.Called(event, schema)
+	var r0 *model.AuditEvent
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.AuditEvent)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// AuditLogRepository is a mock of storage.AuditLogRepository
+type AuditLogRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.AuditLogRepository.All
+func (m *AuditLogRepository) All() ([]model.AuditLog, error) {
+	ret := m.Called()
+	var r0 []model.AuditLog
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.AuditLog)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Last mocks storage.AuditLogRepository.Last
+func (m *AuditLogRepository) Last() (*model.AuditLog, error) {
+	ret := m.Called()
+	var r0 *model.AuditLog
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.AuditLog)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Create mocks storage.AuditLogRepository.Create
+func (m *AuditLogRepository) Create(entry *model.AuditLog) (*model.AuditLog, error) {
+	ret := m.Called(entry)
+	var r0 *model.AuditLog
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.AuditLog)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Checkpoints mocks storage.AuditLogRepository.Checkpoints
+func (m *AuditLogRepository) Checkpoints() ([]model.AuditCheckpoint, error) {
+	ret := m.Called()
+	var r0 []model.AuditCheckpoint
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.AuditCheckpoint)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// LastCheckpoint mocks storage.AuditLogRepository.LastCheckpoint
+func (m *AuditLogRepository) LastCheckpoint() (*model.AuditCheckpoint, error) {
+	ret := m.Called()
+	var r0 *model.AuditCheckpoint
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.AuditCheckpoint)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// CreateCheckpoint mocks storage.AuditLogRepository.CreateCheckpoint
+func (m *AuditLogRepository) CreateCheckpoint(checkpoint *model.AuditCheckpoint) (*model.AuditCheckpoint, error) {
+	ret := m.Called(checkpoint)
+	var r0 *model.AuditCheckpoint
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.AuditCheckpoint)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// LastBefore mocks storage.AuditLogRepository.LastBefore
+func (m *AuditLogRepository) LastBefore(before time.Time) (*model.AuditLog, error) {
+	ret := m.Called(before)
+	var r0 *model.AuditLog
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.AuditLog)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// CountUntil mocks storage.AuditLogRepository.CountUntil
+func (m *AuditLogRepository) CountUntil(id uint) (int, error) {
+	ret := m.Called(id)
+	var r0 int
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(int)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// DeleteUntil mocks storage.AuditLogRepository.DeleteUntil
+func (m *AuditLogRepository) DeleteUntil(id uint) error {
+	ret := m.Called(id)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// BankAccountRepository is a mock of storage.BankAccountRepository
+type BankAccountRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.BankAccountRepository.All
+func (m *BankAccountRepository) All(schema string) ([]model.BankAccount, error) {
+	ret := m.Called(schema)
+	var r0 []model.BankAccount
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.BankAccount)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindAll mocks storage.BankAccountRepository.FindAll
+func (m *BankAccountRepository) FindAll(argsStr map[string]string, argsInt map[string]int, schema string) ([]model.BankAccount, error) {
+	ret := m.Called(argsStr, argsInt, schema)
+	var r0 []model.BankAccount
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.BankAccount)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.BankAccountRepository.FindByID
+func (m *BankAccountRepository) FindByID(id uint, schema string) (*model.BankAccount, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.BankAccount
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.BankAccount)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.BankAccountRepository.Save
+func (m *BankAccountRepository) Save(account *model.BankAccount, schema string) (*model.BankAccount, error) {
+	ret := m.Called(account, schema)
+	var r0 *model.BankAccount
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.BankAccount)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.BankAccountRepository.Delete
+func (m *BankAccountRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// CreditCardRepository is a mock of storage.CreditCardRepository
+type CreditCardRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.CreditCardRepository.All
+func (m *CreditCardRepository) All(schema string) ([]model.CreditCard, error) {
+	ret := m.Called(schema)
+	var r0 []model.CreditCard
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.CreditCard)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindAll mocks storage.CreditCardRepository.FindAll
+func (m *CreditCardRepository) FindAll(argsStr map[string]string, argsInt map[string]int, schema string) ([]model.CreditCard, error) {
+	ret := m.Called(argsStr, argsInt, schema)
+	var r0 []model.CreditCard
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.CreditCard)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.CreditCardRepository.FindByID
+func (m *CreditCardRepository) FindByID(id uint, schema string) (*model.CreditCard, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.CreditCard
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.CreditCard)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.CreditCardRepository.Save
+func (m *CreditCardRepository) Save(card *model.CreditCard, schema string) (*model.CreditCard, error) {
+	ret := m.Called(card, schema)
+	var r0 *model.CreditCard
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.CreditCard)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.CreditCardRepository.Delete
+func (m *CreditCardRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// EmailRepository is a mock of storage.EmailRepository
+type EmailRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.EmailRepository.All
+func (m *EmailRepository) All(schema string) ([]model.Email, error) {
+	ret := m.Called(schema)
+	var r0 []model.Email
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Email)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindAll mocks storage.EmailRepository.FindAll
+func (m *EmailRepository) FindAll(argsStr map[string]string, argsInt map[string]int, schema string) ([]model.Email, error) {
+	ret := m.Called(argsStr, argsInt, schema)
+	var r0 []model.Email
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Email)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.EmailRepository.FindByID
+func (m *EmailRepository) FindByID(id uint, schema string) (*model.Email, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.Email
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Email)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.EmailRepository.Save
+func (m *EmailRepository) Save(account *model.Email, schema string) (*model.Email, error) {
+	ret := m.Called(account, schema)
+	var r0 *model.Email
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Email)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.EmailRepository.Delete
+func (m *EmailRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// EquivalentDomainRepository is a mock of storage.EquivalentDomainRepository
+type EquivalentDomainRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.EquivalentDomainRepository.All
+func (m *EquivalentDomainRepository) All(schema string) ([]model.EquivalentDomain, error) {
+	ret := m.Called(schema)
+	var r0 []model.EquivalentDomain
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.EquivalentDomain)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.EquivalentDomainRepository.FindByID
+func (m *EquivalentDomainRepository) FindByID(id uint, schema string) (*model.EquivalentDomain, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.EquivalentDomain
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.EquivalentDomain)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.EquivalentDomainRepository.Save
+func (m *EquivalentDomainRepository) Save(equivalentDomain *model.EquivalentDomain, schema string) (*model.EquivalentDomain, error) {
+	ret := m.Called(equivalentDomain, schema)
+	var r0 *model.EquivalentDomain
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.EquivalentDomain)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.EquivalentDomainRepository.Delete
+func (m *EquivalentDomainRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// ExportJobRepository is a mock of storage.ExportJobRepository
+type ExportJobRepository struct {
+	mock.Mock
+}
+
+// FindByUUID mocks storage.ExportJobRepository.FindByUUID
+func (m *ExportJobRepository) FindByUUID(uuid string) (*model.ExportJob, error) {
+	ret := m.Called(uuid)
+	var r0 *model.ExportJob
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.ExportJob)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindUnfinished mocks storage.ExportJobRepository.FindUnfinished
+func (m *ExportJobRepository) FindUnfinished() ([]model.ExportJob, error) {
+	ret := m.Called()
+	var r0 []model.ExportJob
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.ExportJob)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindExpired mocks storage.ExportJobRepository.FindExpired
+func (m *ExportJobRepository) FindExpired(now time.Time) ([]model.ExportJob, error) {
+	ret := m.Called(now)
+	var r0 []model.ExportJob
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.ExportJob)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.ExportJobRepository.Save
+func (m *ExportJobRepository) Save(job *model.ExportJob) (*model.ExportJob, error) {
+	ret := m.Called(job)
+	var r0 *model.ExportJob
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.ExportJob)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.ExportJobRepository.Delete
+func (m *ExportJobRepository) Delete(id uint) error {
+	ret := m.Called(id)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// FolderRepository is a mock of storage.FolderRepository
+type FolderRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.FolderRepository.All
+func (m *FolderRepository) All(schema string) ([]model.Folder, error) {
+	ret := m.Called(schema)
+	var r0 []model.Folder
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Folder)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.FolderRepository.FindByID
+func (m *FolderRepository) FindByID(id uint, schema string) (*model.Folder, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.Folder
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Folder)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.FolderRepository.Save
+func (m *FolderRepository) Save(folder *model.Folder, schema string) (*model.Folder, error) {
+	ret := m.Called(folder, schema)
+	var r0 *model.Folder
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Folder)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.FolderRepository.Delete
+func (m *FolderRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// Unfile mocks storage.FolderRepository.Unfile
+func (m *FolderRepository) Unfile(id uint, table string) error {
+	ret := m.Called(id, table)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// ItemVersionRepository is a mock of storage.ItemVersionRepository
+type ItemVersionRepository struct {
+	mock.Mock
+}
+
+// FindByItem mocks storage.ItemVersionRepository.FindByItem
+func (m *ItemVersionRepository) FindByItem(itemType string, itemID uint, schema string) ([]model.ItemVersion, error) {
+	ret := m.Called(itemType, itemID, schema)
+	var r0 []model.ItemVersion
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.ItemVersion)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.ItemVersionRepository.FindByID
+func (m *ItemVersionRepository) FindByID(id uint, schema string) (*model.ItemVersion, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.ItemVersion
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.ItemVersion)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Create mocks storage.ItemVersionRepository.Create
+func (m *ItemVersionRepository) Create(version *model.ItemVersion, schema string) error {
+	ret := m.Called(version, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// DeleteOlder mocks storage.ItemVersionRepository.DeleteOlder
+func (m *ItemVersionRepository) DeleteOlder(itemType string, itemID uint, keep int, schema string) error {
+	ret := m.Called(itemType, itemID, keep, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// DeleteByItem mocks storage.ItemVersionRepository.DeleteByItem
+func (m *ItemVersionRepository) DeleteByItem(itemType string, itemID uint, schema string) error {
+	ret := m.Called(itemType, itemID, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// LoginRepository is a mock of storage.LoginRepository
+type LoginRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.LoginRepository.All
+func (m *LoginRepository) All(schema string) ([]model.Login, error) {
+	ret := m.Called(schema)
+	var r0 []model.Login
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Login)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindAll mocks storage.LoginRepository.FindAll
+func (m *LoginRepository) FindAll(argsStr map[string]string, argsInt map[string]int, schema string) ([]model.Login, error) {
+	ret := m.Called(argsStr, argsInt, schema)
+	var r0 []model.Login
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Login)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.LoginRepository.FindByID
+func (m *LoginRepository) FindByID(id uint, schema string) (*model.Login, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.Login
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Login)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.LoginRepository.Save
+func (m *LoginRepository) Save(login *model.Login, schema string) (*model.Login, error) {
+	ret := m.Called(login, schema)
+	var r0 *model.Login
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Login)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.LoginRepository.Delete
+func (m *LoginRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// MachineAccountRepository is a mock of storage.MachineAccountRepository
+type MachineAccountRepository struct {
+	mock.Mock
+}
+
+// FindAllByUserID mocks storage.MachineAccountRepository.FindAllByUserID
+func (m *MachineAccountRepository) FindAllByUserID(userID uint) ([]model.MachineAccount, error) {
+	ret := m.Called(userID)
+	var r0 []model.MachineAccount
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.MachineAccount)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.MachineAccountRepository.FindByID
+func (m *MachineAccountRepository) FindByID(id uint) (*model.MachineAccount, error) {
+	ret := m.Called(id)
+	var r0 *model.MachineAccount
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.MachineAccount)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByUUID mocks storage.MachineAccountRepository.FindByUUID
+func (m *MachineAccountRepository) FindByUUID(uuid string) (*model.MachineAccount, error) {
+	ret := m.Called(uuid)
+	var r0 *model.MachineAccount
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.MachineAccount)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.MachineAccountRepository.Save
+func (m *MachineAccountRepository) Save(account *model.MachineAccount) (*model.MachineAccount, error) {
+	ret := m.Called(account)
+	var r0 *model.MachineAccount
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.MachineAccount)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.MachineAccountRepository.Delete
+func (m *MachineAccountRepository) Delete(id uint) error {
+	ret := m.Called(id)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// DeleteByUserID mocks storage.MachineAccountRepository.DeleteByUserID
+func (m *MachineAccountRepository) DeleteByUserID(userID uint) error {
+	ret := m.Called(userID)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// NoteRepository is a mock of storage.NoteRepository
+type NoteRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.NoteRepository.All
+func (m *NoteRepository) All(schema string) ([]model.Note, error) {
+	ret := m.Called(schema)
+	var r0 []model.Note
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Note)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindAll mocks storage.NoteRepository.FindAll
+func (m *NoteRepository) FindAll(argsStr map[string]string, argsInt map[string]int, schema string) ([]model.Note, error) {
+	ret := m.Called(argsStr, argsInt, schema)
+	var r0 []model.Note
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Note)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.NoteRepository.FindByID
+func (m *NoteRepository) FindByID(id uint, schema string) (*model.Note, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.Note
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Note)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.NoteRepository.Save
+func (m *NoteRepository) Save(account *model.Note, schema string) (*model.Note, error) {
+	ret := m.Called(account, schema)
+	var r0 *model.Note
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Note)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.NoteRepository.Delete
+func (m *NoteRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// PasswordHistoryRepository is a mock of storage.PasswordHistoryRepository
+type PasswordHistoryRepository struct {
+	mock.Mock
+}
+
+// FindByLoginID mocks storage.PasswordHistoryRepository.FindByLoginID
+func (m *PasswordHistoryRepository) FindByLoginID(loginID uint, schema string) ([]model.PasswordHistory, error) {
+	ret := m.Called(loginID, schema)
+	var r0 []model.PasswordHistory
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.PasswordHistory)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.PasswordHistoryRepository.Save
+func (m *PasswordHistoryRepository) Save(history *model.PasswordHistory, schema string) (*model.PasswordHistory, error) {
+	ret := m.Called(history, schema)
+	var r0 *model.PasswordHistory
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.PasswordHistory)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// DeleteByLoginID mocks storage.PasswordHistoryRepository.DeleteByLoginID
+func (m *PasswordHistoryRepository) DeleteByLoginID(loginID uint, schema string) error {
+	ret := m.Called(loginID, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// PersonalAccessTokenRepository is a mock of storage.PersonalAccessTokenRepository
+type PersonalAccessTokenRepository struct {
+	mock.Mock
+}
+
+// FindAllByUserID mocks storage.PersonalAccessTokenRepository.FindAllByUserID
+func (m *PersonalAccessTokenRepository) FindAllByUserID(userID uint) ([]model.PersonalAccessToken, error) {
+	ret := m.Called(userID)
+	var r0 []model.PersonalAccessToken
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.PersonalAccessToken)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.PersonalAccessTokenRepository.FindByID
+func (m *PersonalAccessTokenRepository) FindByID(id uint) (*model.PersonalAccessToken, error) {
+	ret := m.Called(id)
+	var r0 *model.PersonalAccessToken
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.PersonalAccessToken)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByHash mocks storage.PersonalAccessTokenRepository.FindByHash
+func (m *PersonalAccessTokenRepository) FindByHash(hash string) (*model.PersonalAccessToken, error) {
+	ret := m.Called(hash)
+	var r0 *model.PersonalAccessToken
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.PersonalAccessToken)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.PersonalAccessTokenRepository.Save
+func (m *PersonalAccessTokenRepository) Save(token *model.PersonalAccessToken) (*model.PersonalAccessToken, error) {
+	ret := m.Called(token)
+	var r0 *model.PersonalAccessToken
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.PersonalAccessToken)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.PersonalAccessTokenRepository.Delete
+func (m *PersonalAccessTokenRepository) Delete(id uint) error {
+	ret := m.Called(id)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// DeleteByUserID mocks storage.PersonalAccessTokenRepository.DeleteByUserID
+func (m *PersonalAccessTokenRepository) DeleteByUserID(userID uint) error {
+	ret := m.Called(userID)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// PolicyRepository is a mock of storage.PolicyRepository
+type PolicyRepository struct {
+	mock.Mock
+}
+
+// FindByUserID mocks storage.PolicyRepository.FindByUserID
+func (m *PolicyRepository) FindByUserID(userID uint) (*model.Policy, error) {
+	ret := m.Called(userID)
+	var r0 *model.Policy
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Policy)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.PolicyRepository.Save
+func (m *PolicyRepository) Save(policy *model.Policy) (*model.Policy, error) {
+	ret := m.Called(policy)
+	var r0 *model.Policy
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Policy)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// DeleteByUserID mocks storage.PolicyRepository.DeleteByUserID
+func (m *PolicyRepository) DeleteByUserID(userID uint) error {
+	ret := m.Called(userID)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// ReencryptionRepository is a mock of storage.ReencryptionRepository
+type ReencryptionRepository struct {
+	mock.Mock
+}
+
+// FindByID mocks storage.ReencryptionRepository.FindByID
+func (m *ReencryptionRepository) FindByID(id uint) (*model.ReencryptionJob, error) {
+	ret := m.Called(id)
+	var r0 *model.ReencryptionJob
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.ReencryptionJob)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindUnfinished mocks storage.ReencryptionRepository.FindUnfinished
+func (m *ReencryptionRepository) FindUnfinished() ([]model.ReencryptionJob, error) {
+	ret := m.Called()
+	var r0 []model.ReencryptionJob
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.ReencryptionJob)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindRecent mocks storage.ReencryptionRepository.FindRecent
+func (m *ReencryptionRepository) FindRecent(limit int) ([]model.ReencryptionJob, error) {
+	ret := m.Called(limit)
+	var r0 []model.ReencryptionJob
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.ReencryptionJob)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.ReencryptionRepository.Save
+func (m *ReencryptionRepository) Save(job *model.ReencryptionJob) (*model.ReencryptionJob, error) {
+	ret := m.Called(job)
+	var r0 *model.ReencryptionJob
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.ReencryptionJob)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// CountRows mocks storage.ReencryptionRepository.CountRows
+func (m *ReencryptionRepository) CountRows(table string) (int, error) {
+	ret := m.Called(table)
+	var r0 int
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(int)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindBatch mocks storage.ReencryptionRepository.FindBatch
+func (m *ReencryptionRepository) FindBatch(table string, afterID uint, limit int, rows interface{}) error {
+	ret := m.Called(table, afterID, limit, rows)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// SaveBatch mocks storage.ReencryptionRepository.SaveBatch
+func (m *ReencryptionRepository) SaveBatch(job *model.ReencryptionJob, table string, rows map[uint]map[string]interface{}) error {
+	ret := m.Called(job, table, rows)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// RetentionRepository is a mock of storage.RetentionRepository
+type RetentionRepository struct {
+	mock.Mock
+}
+
+// CountBefore mocks storage.RetentionRepository.CountBefore
+func (m *RetentionRepository) CountBefore(table string, column string, before time.Time) (int, error) {
+	ret := m.Called(table, column, before)
+	var r0 int
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(int)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// DeleteBefore mocks storage.RetentionRepository.DeleteBefore
+func (m *RetentionRepository) DeleteBefore(table string, column string, before time.Time) (int, error) {
+	ret := m.Called(table, column, before)
+	var r0 int
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(int)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// SSOIdentityRepository is a mock of storage.SSOIdentityRepository
+type SSOIdentityRepository struct {
+	mock.Mock
+}
+
+// FindBySubject mocks storage.SSOIdentityRepository.FindBySubject
+func (m *SSOIdentityRepository) FindBySubject(provider string, subject string) (*model.SSOIdentity, error) {
+	ret := m.Called(provider, subject)
+	var r0 *model.SSOIdentity
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.SSOIdentity)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.SSOIdentityRepository.Save
+func (m *SSOIdentityRepository) Save(identity *model.SSOIdentity) (*model.SSOIdentity, error) {
+	ret := m.Called(identity)
+	var r0 *model.SSOIdentity
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.SSOIdentity)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// DeleteByUserID mocks storage.SSOIdentityRepository.DeleteByUserID
+func (m *SSOIdentityRepository) DeleteByUserID(userID uint) error {
+	ret := m.Called(userID)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// SchemaMigrationRepository is a mock of storage.SchemaMigrationRepository
+type SchemaMigrationRepository struct {
+	mock.Mock
+}
+
+// FindAll mocks storage.SchemaMigrationRepository.FindAll
+func (m *SchemaMigrationRepository) FindAll(schema string) ([]model.SchemaMigration, error) {
+	ret := m.Called(schema)
+	var r0 []model.SchemaMigration
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.SchemaMigration)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Create mocks storage.SchemaMigrationRepository.Create
+func (m *SchemaMigrationRepository) Create(migration *model.SchemaMigration, schema string) error {
+	ret := m.Called(migration, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// Delete mocks storage.SchemaMigrationRepository.Delete
+func (m *SchemaMigrationRepository) Delete(version int, schema string) error {
+	ret := m.Called(version, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// Exec mocks storage.SchemaMigrationRepository.Exec
+func (m *SchemaMigrationRepository) Exec(statement string) error {
+	ret := m.Called(statement)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// Migrate mocks storage.SchemaMigrationRepository.Migrate
+func (m *SchemaMigrationRepository) Migrate(schema string) error {
+	ret := m.Called(schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// ServerRepository is a mock of storage.ServerRepository
+type ServerRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.ServerRepository.All
+func (m *ServerRepository) All(schema string) ([]model.Server, error) {
+	ret := m.Called(schema)
+	var r0 []model.Server
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Server)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindAll mocks storage.ServerRepository.FindAll
+func (m *ServerRepository) FindAll(argsStr map[string]string, argsInt map[string]int, schema string) ([]model.Server, error) {
+	ret := m.Called(argsStr, argsInt, schema)
+	var r0 []model.Server
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Server)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.ServerRepository.FindByID
+func (m *ServerRepository) FindByID(id uint, schema string) (*model.Server, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.Server
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Server)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.ServerRepository.Save
+func (m *ServerRepository) Save(server *model.Server, schema string) (*model.Server, error) {
+	ret := m.Called(server, schema)
+	var r0 *model.Server
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Server)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.ServerRepository.Delete
+func (m *ServerRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// SigninFailureRepository is a mock of storage.SigninFailureRepository
+type SigninFailureRepository struct {
+	mock.Mock
+}
+
+// FindByKey mocks storage.SigninFailureRepository.FindByKey
+func (m *SigninFailureRepository) FindByKey(key string) (*model.SigninFailure, error) {
+	ret := m.Called(key)
+	var r0 *model.SigninFailure
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.SigninFailure)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.SigninFailureRepository.Save
+func (m *SigninFailureRepository) Save(failure *model.SigninFailure) (*model.SigninFailure, error) {
+	ret := m.Called(failure)
+	var r0 *model.SigninFailure
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.SigninFailure)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// DeleteByKey mocks storage.SigninFailureRepository.DeleteByKey
+func (m *SigninFailureRepository) DeleteByKey(key string) error {
+	ret := m.Called(key)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// DeleteBefore mocks storage.SigninFailureRepository.DeleteBefore
+func (m *SigninFailureRepository) DeleteBefore(before time.Time) (int, error) {
+	ret := m.Called(before)
+	var r0 int
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(int)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Store is a mock of storage.Store
+type Store struct {
+	mock.Mock
+}
+
+// Logins mocks storage.Store.Logins
+func (m *Store) Logins() storage.LoginRepository {
+	ret := m.Called()
+	var r0 storage.LoginRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.LoginRepository)
+	}
+	return r0
+}
+
+// PasswordHistories mocks storage.Store.PasswordHistories
+func (m *Store) PasswordHistories() storage.PasswordHistoryRepository {
+	ret := m.Called()
+	var r0 storage.PasswordHistoryRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.PasswordHistoryRepository)
+	}
+	return r0
+}
+
+// ItemVersions mocks storage.Store.ItemVersions
+func (m *Store) ItemVersions() storage.ItemVersionRepository {
+	ret := m.Called()
+	var r0 storage.ItemVersionRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.ItemVersionRepository)
+	}
+	return r0
+}
+
+// Attachments mocks storage.Store.Attachments
+func (m *Store) Attachments() storage.AttachmentRepository {
+	ret := m.Called()
+	var r0 storage.AttachmentRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.AttachmentRepository)
+	}
+	return r0
+}
+
+// Folders mocks storage.Store.Folders
+func (m *Store) Folders() storage.FolderRepository {
+	ret := m.Called()
+	var r0 storage.FolderRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.FolderRepository)
+	}
+	return r0
+}
+
+// Tags mocks storage.Store.Tags
+func (m *Store) Tags() storage.TagRepository {
+	ret := m.Called()
+	var r0 storage.TagRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.TagRepository)
+	}
+	return r0
+}
+
+// CreditCards mocks storage.Store.CreditCards
+func (m *Store) CreditCards() storage.CreditCardRepository {
+	ret := m.Called()
+	var r0 storage.CreditCardRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.CreditCardRepository)
+	}
+	return r0
+}
+
+// BankAccounts mocks storage.Store.BankAccounts
+func (m *Store) BankAccounts() storage.BankAccountRepository {
+	ret := m.Called()
+	var r0 storage.BankAccountRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.BankAccountRepository)
+	}
+	return r0
+}
+
+// Notes mocks storage.Store.Notes
+func (m *Store) Notes() storage.NoteRepository {
+	ret := m.Called()
+	var r0 storage.NoteRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.NoteRepository)
+	}
+	return r0
+}
+
+// Emails mocks storage.Store.Emails
+func (m *Store) Emails() storage.EmailRepository {
+	ret := m.Called()
+	var r0 storage.EmailRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.EmailRepository)
+	}
+	return r0
+}
+
+// EquivalentDomains mocks storage.Store.EquivalentDomains
+func (m *Store) EquivalentDomains() storage.EquivalentDomainRepository {
+	ret := m.Called()
+	var r0 storage.EquivalentDomainRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.EquivalentDomainRepository)
+	}
+	return r0
+}
+
+// WebAuthnCredentials mocks storage.Store.WebAuthnCredentials
+func (m *Store) WebAuthnCredentials() storage.WebAuthnCredentialRepository {
+	ret := m.Called()
+	var r0 storage.WebAuthnCredentialRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.WebAuthnCredentialRepository)
+	}
+	return r0
+}
+
+// Tokens mocks storage.Store.Tokens
+func (m *Store) Tokens() storage.TokenRepository {
+	ret := m.Called()
+	var r0 storage.TokenRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.TokenRepository)
+	}
+	return r0
+}
+
+// Users mocks storage.Store.Users
+func (m *Store) Users() storage.UserRepository {
+	ret := m.Called()
+	var r0 storage.UserRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.UserRepository)
+	}
+	return r0
+}
+
+// Servers mocks storage.Store.Servers
+func (m *Store) Servers() storage.ServerRepository {
+	ret := m.Called()
+	var r0 storage.ServerRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.ServerRepository)
+	}
+	return r0
+}
+
+// Subscriptions mocks storage.Store.Subscriptions
+func (m *Store) Subscriptions() storage.SubscriptionRepository {
+	ret := m.Called()
+	var r0 storage.SubscriptionRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.SubscriptionRepository)
+	}
+	return r0
+}
+
+// MachineAccounts mocks storage.Store.MachineAccounts
+func (m *Store) MachineAccounts() storage.MachineAccountRepository {
+	ret := m.Called()
+	var r0 storage.MachineAccountRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.MachineAccountRepository)
+	}
+	return r0
+}
+
+// Policies mocks storage.Store.Policies
+func (m *Store) Policies() storage.PolicyRepository {
+	ret := m.Called()
+	var r0 storage.PolicyRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.PolicyRepository)
+	}
+	return r0
+}
+
+// SSOIdentities mocks storage.Store.SSOIdentities
+func (m *Store) SSOIdentities() storage.SSOIdentityRepository {
+	ret := m.Called()
+	var r0 storage.SSOIdentityRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.SSOIdentityRepository)
+	}
+	return r0
+}
+
+// AccessTokens mocks storage.Store.AccessTokens
+func (m *Store) AccessTokens() storage.PersonalAccessTokenRepository {
+	ret := m.Called()
+	var r0 storage.PersonalAccessTokenRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.PersonalAccessTokenRepository)
+	}
+	return r0
+}
+
+// TrustedDevices mocks storage.Store.TrustedDevices
+func (m *Store) TrustedDevices() storage.TrustedDeviceRepository {
+	ret := m.Called()
+	var r0 storage.TrustedDeviceRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.TrustedDeviceRepository)
+	}
+	return r0
+}
+
+// SigninFailures mocks storage.Store.SigninFailures
+func (m *Store) SigninFailures() storage.SigninFailureRepository {
+	ret := m.Called()
+	var r0 storage.SigninFailureRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.SigninFailureRepository)
+	}
+	return r0
+}
+
+// AuditLogs mocks storage.Store.AuditLogs
+func (m *Store) AuditLogs() storage.AuditLogRepository {
+	ret := m.Called()
+	var r0 storage.AuditLogRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.AuditLogRepository)
+	}
+	return r0
+}
+
+// AuditEvents mocks storage.Store.AuditEvents
+func (m *Store) AuditEvents() storage.AuditEventRepository {
+	ret := m.Called()
+	var r0 storage.AuditEventRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.AuditEventRepository)
+	}
+	return r0
+}
+
+// ExportJobs mocks storage.Store.ExportJobs
+func (m *Store) ExportJobs() storage.ExportJobRepository {
+	ret := m.Called()
+	var r0 storage.ExportJobRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.ExportJobRepository)
+	}
+	return r0
+}
+
+// Retention mocks storage.Store.Retention
+func (m *Store) Retention() storage.RetentionRepository {
+	ret := m.Called()
+	var r0 storage.RetentionRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.RetentionRepository)
+	}
+	return r0
+}
+
+// Trash mocks storage.Store.Trash
+func (m *Store) Trash() storage.TrashRepository {
+	ret := m.Called()
+	var r0 storage.TrashRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.TrashRepository)
+	}
+	return r0
+}
+
+// SyncCounters mocks storage.Store.SyncCounters
+func (m *Store) SyncCounters() storage.SyncCounterRepository {
+	ret := m.Called()
+	var r0 storage.SyncCounterRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.SyncCounterRepository)
+	}
+	return r0
+}
+
+// Reencryption mocks storage.Store.Reencryption
+func (m *Store) Reencryption() storage.ReencryptionRepository {
+	ret := m.Called()
+	var r0 storage.ReencryptionRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.ReencryptionRepository)
+	}
+	return r0
+}
+
+// SchemaMigrations mocks storage.Store.SchemaMigrations
+func (m *Store) SchemaMigrations() storage.SchemaMigrationRepository {
+	ret := m.Called()
+	var r0 storage.SchemaMigrationRepository
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(storage.SchemaMigrationRepository)
+	}
+	return r0
+}
+
+// Transaction mocks storage.Store.Transaction
+func (m *Store) Transaction(fn storage.TxFunc) error {
+	ret := m.Called(fn)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// Ping mocks storage.Store.Ping
+func (m *Store) Ping() error {
+	ret := m.Called()
+	r0 := ret.Error(0)
+	return r0
+}
+
+// PendingMigrations mocks storage.Store.PendingMigrations
+func (m *Store) PendingMigrations() []string {
+	ret := m.Called()
+	var r0 []string
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]string)
+	}
+	return r0
+}
+
+// PoolStats mocks storage.Store.PoolStats
+func (m *Store) PoolStats() map[string]sql.DBStats {
+	ret := m.Called()
+	var r0 map[string]sql.DBStats
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(map[string]sql.DBStats)
+	}
+	return r0
+}
+
+// SubscriptionRepository is a mock of storage.SubscriptionRepository
+type SubscriptionRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.SubscriptionRepository.All
+func (m *SubscriptionRepository) All() ([]model.Subscription, error) {
+	ret := m.Called()
+	var r0 []model.Subscription
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Subscription)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindAll mocks storage.SubscriptionRepository.FindAll
+func (m *SubscriptionRepository) FindAll(argsStr map[string]string, argsInt map[string]int) ([]model.Subscription, error) {
+	ret := m.Called(argsStr, argsInt)
+	var r0 []model.Subscription
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Subscription)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.SubscriptionRepository.FindByID
+func (m *SubscriptionRepository) FindByID(id uint) (*model.Subscription, error) {
+	ret := m.Called(id)
+	var r0 *model.Subscription
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Subscription)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByEmail mocks storage.SubscriptionRepository.FindByEmail
+func (m *SubscriptionRepository) FindByEmail(email string) (*model.Subscription, error) {
+	ret := m.Called(email)
+	var r0 *model.Subscription
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Subscription)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindBySubscriptionID mocks storage.SubscriptionRepository.FindBySubscriptionID
+func (m *SubscriptionRepository) FindBySubscriptionID(id uint) (*model.Subscription, error) {
+	ret := m.Called(id)
+	var r0 *model.Subscription
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Subscription)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.SubscriptionRepository.Save
+func (m *SubscriptionRepository) Save(subscription *model.Subscription) (*model.Subscription, error) {
+	ret := m.Called(subscription)
+	var r0 *model.Subscription
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Subscription)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.SubscriptionRepository.Delete
+func (m *SubscriptionRepository) Delete(id uint) error {
+	ret := m.Called(id)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// SyncCounterRepository is a mock of storage.SyncCounterRepository
+type SyncCounterRepository struct {
+	mock.Mock
+}
+
+// Find mocks storage.SyncCounterRepository.Find
+func (m *SyncCounterRepository) Find(schema string) (*model.SyncCounter, error) {
+	ret := m.Called(schema)
+	var r0 *model.SyncCounter
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.SyncCounter)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// MarkPurged mocks storage.SyncCounterRepository.MarkPurged
+func (m *SyncCounterRepository) MarkPurged(schema string) error {
+	ret := m.Called(schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// TagRepository is a mock of storage.TagRepository
+type TagRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.TagRepository.All
+func (m *TagRepository) All(schema string) ([]model.Tag, error) {
+	ret := m.Called(schema)
+	var r0 []model.Tag
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Tag)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.TagRepository.FindByID
+func (m *TagRepository) FindByID(id uint, schema string) (*model.Tag, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.Tag
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Tag)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.TagRepository.Save
+func (m *TagRepository) Save(tag *model.Tag, schema string) (*model.Tag, error) {
+	ret := m.Called(tag, schema)
+	var r0 *model.Tag
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.Tag)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.TagRepository.Delete
+func (m *TagRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// FindItemTags mocks storage.TagRepository.FindItemTags
+func (m *TagRepository) FindItemTags(itemType string, itemIDs []uint, schema string) ([]model.ItemTag, error) {
+	ret := m.Called(itemType, itemIDs, schema)
+	var r0 []model.ItemTag
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.ItemTag)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// SetItemTags mocks storage.TagRepository.SetItemTags
+func (m *TagRepository) SetItemTags(itemType string, itemID uint, tagIDs []uint, schema string) error {
+	ret := m.Called(itemType, itemID, tagIDs, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// TokenRepository is a mock of storage.TokenRepository
+type TokenRepository struct {
+	mock.Mock
+}
+
+// Any mocks storage.TokenRepository.Any
+func (m *TokenRepository) Any(uuid string) (model.Token, bool) {
+	ret := m.Called(uuid)
+	var r0 model.Token
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(model.Token)
+	}
+	var r1 bool
+	if ret.Get(1) != nil {
+		r1 = ret.Get(1).(bool)
+	}
+	return r0, r1
+}
+
+// Save mocks storage.TokenRepository.Save
+func (m *TokenRepository) Save(token *model.Token) {
+	m.Called(token)
+}
+
+// Rotate mocks storage.TokenRepository.Rotate
+func (m *TokenRepository) Rotate(uuid string, rotatedAt time.Time) bool {
+	ret := m.Called(uuid, rotatedAt)
+	var r0 bool
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(bool)
+	}
+	return r0
+}
+
+// Delete mocks storage.TokenRepository.Delete
+func (m *TokenRepository) Delete(userid int) {
+	m.Called(userid)
+}
+
+// DeleteByUUID mocks storage.TokenRepository.DeleteByUUID
+func (m *TokenRepository) DeleteByUUID(uuid string) {
+	m.Called(uuid)
+}
+
+// DeleteByFamily mocks storage.TokenRepository.DeleteByFamily
+func (m *TokenRepository) DeleteByFamily(family string) {
+	m.Called(family)
+}
+
+// DeleteAccessByFamily mocks storage.TokenRepository.DeleteAccessByFamily
+func (m *TokenRepository) DeleteAccessByFamily(family string) {
+	m.Called(family)
+}
+
+// DeleteSuperseded mocks storage.TokenRepository.DeleteSuperseded
+func (m *TokenRepository) DeleteSuperseded(before time.Time) (int, error) {
+	ret := m.Called(before)
+	var r0 int
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(int)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Touch mocks storage.TokenRepository.Touch
+func (m *TokenRepository) Touch(userid int, lastUsedAt time.Time) error {
+	ret := m.Called(userid, lastUsedAt)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// TouchByFamily mocks storage.TokenRepository.TouchByFamily
+func (m *TokenRepository) TouchByFamily(family string, lastUsedAt time.Time) error {
+	ret := m.Called(family, lastUsedAt)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// FindSessions mocks storage.TokenRepository.FindSessions
+func (m *TokenRepository) FindSessions(userid int) ([]model.Token, error) {
+	ret := m.Called(userid)
+	var r0 []model.Token
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.Token)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// TrashRepository is a mock of storage.TrashRepository
+type TrashRepository struct {
+	mock.Mock
+}
+
+// FindDeleted mocks storage.TrashRepository.FindDeleted
+func (m *TrashRepository) FindDeleted(table string, rows interface{}) error {
+	ret := m.Called(table, rows)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// Restore mocks storage.TrashRepository.Restore
+func (m *TrashRepository) Restore(table string, id uint) error {
+	ret := m.Called(table, id)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// Purge mocks storage.TrashRepository.Purge
+func (m *TrashRepository) Purge(table string, id uint) error {
+	ret := m.Called(table, id)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// TrustedDeviceRepository is a mock of storage.TrustedDeviceRepository
+type TrustedDeviceRepository struct {
+	mock.Mock
+}
+
+// FindAllByUserID mocks storage.TrustedDeviceRepository.FindAllByUserID
+func (m *TrustedDeviceRepository) FindAllByUserID(userID uint) ([]model.TrustedDevice, error) {
+	ret := m.Called(userID)
+	var r0 []model.TrustedDevice
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.TrustedDevice)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.TrustedDeviceRepository.FindByID
+func (m *TrustedDeviceRepository) FindByID(id uint) (*model.TrustedDevice, error) {
+	ret := m.Called(id)
+	var r0 *model.TrustedDevice
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.TrustedDevice)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByHash mocks storage.TrustedDeviceRepository.FindByHash
+func (m *TrustedDeviceRepository) FindByHash(hash string) (*model.TrustedDevice, error) {
+	ret := m.Called(hash)
+	var r0 *model.TrustedDevice
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.TrustedDevice)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.TrustedDeviceRepository.Save
+func (m *TrustedDeviceRepository) Save(device *model.TrustedDevice) (*model.TrustedDevice, error) {
+	ret := m.Called(device)
+	var r0 *model.TrustedDevice
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.TrustedDevice)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.TrustedDeviceRepository.Delete
+func (m *TrustedDeviceRepository) Delete(id uint) error {
+	ret := m.Called(id)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// DeleteByUserID mocks storage.TrustedDeviceRepository.DeleteByUserID
+func (m *TrustedDeviceRepository) DeleteByUserID(userID uint) error {
+	ret := m.Called(userID)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// UserRepository is a mock of storage.UserRepository
+type UserRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.UserRepository.All
+func (m *UserRepository) All() ([]model.User, error) {
+	ret := m.Called()
+	var r0 []model.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.User)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindAll mocks storage.UserRepository.FindAll
+func (m *UserRepository) FindAll(argsStr map[string]string, argsInt map[string]int) ([]model.User, error) {
+	ret := m.Called(argsStr, argsInt)
+	var r0 []model.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.User)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.UserRepository.FindByID
+func (m *UserRepository) FindByID(id uint) (*model.User, error) {
+	ret := m.Called(id)
+	var r0 *model.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.User)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByEmail mocks storage.UserRepository.FindByEmail
+func (m *UserRepository) FindByEmail(email string) (*model.User, error) {
+	ret := m.Called(email)
+	var r0 *model.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.User)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByCredentials mocks storage.UserRepository.FindByCredentials
+func (m *UserRepository) FindByCredentials(email string, masterPassword string) (*model.User, error) {
+	ret := m.Called(email, masterPassword)
+	var r0 *model.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.User)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.UserRepository.Save
+func (m *UserRepository) Save(login *model.User) (*model.User, error) {
+	ret := m.Called(login)
+	var r0 *model.User
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.User)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.UserRepository.Delete
+func (m *UserRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// CreateSchema mocks storage.UserRepository.CreateSchema
+func (m *UserRepository) CreateSchema(schema string) error {
+	ret := m.Called(schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// DropSchema mocks storage.UserRepository.DropSchema
+func (m *UserRepository) DropSchema(schema string) error {
+	ret := m.Called(schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// WebAuthnCredentialRepository is a mock of storage.WebAuthnCredentialRepository
+type WebAuthnCredentialRepository struct {
+	mock.Mock
+}
+
+// All mocks storage.WebAuthnCredentialRepository.All
+func (m *WebAuthnCredentialRepository) All(schema string) ([]model.WebAuthnCredential, error) {
+	ret := m.Called(schema)
+	var r0 []model.WebAuthnCredential
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).([]model.WebAuthnCredential)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByID mocks storage.WebAuthnCredentialRepository.FindByID
+func (m *WebAuthnCredentialRepository) FindByID(id uint, schema string) (*model.WebAuthnCredential, error) {
+	ret := m.Called(id, schema)
+	var r0 *model.WebAuthnCredential
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.WebAuthnCredential)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// FindByCredentialID mocks storage.WebAuthnCredentialRepository.FindByCredentialID
+func (m *WebAuthnCredentialRepository) FindByCredentialID(credentialID string, schema string) (*model.WebAuthnCredential, error) {
+	ret := m.Called(credentialID, schema)
+	var r0 *model.WebAuthnCredential
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.WebAuthnCredential)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Save mocks storage.WebAuthnCredentialRepository.Save
+func (m *WebAuthnCredentialRepository) Save(credential *model.WebAuthnCredential, schema string) (*model.WebAuthnCredential, error) {
+	ret := m.Called(credential, schema)
+	var r0 *model.WebAuthnCredential
+	if ret.Get(0) != nil {
+		r0 = ret.Get(0).(*model.WebAuthnCredential)
+	}
+	r1 := ret.Error(1)
+	return r0, r1
+}
+
+// Delete mocks storage.WebAuthnCredentialRepository.Delete
+func (m *WebAuthnCredentialRepository) Delete(id uint, schema string) error {
+	ret := m.Called(id, schema)
+	r0 := ret.Error(0)
+	return r0
+}
+
+// DeleteAll mocks storage.WebAuthnCredentialRepository.DeleteAll
+func (m *WebAuthnCredentialRepository) DeleteAll(schema string) error {
+	ret := m.Called(schema)
+	r0 := ret.Error(0)
+	return r0
+}
@@ -0,0 +1,193 @@
+package storagetest
+
+import (
+	"database/sql"
+
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/stretchr/testify/mock"
+)
+
+var (
+	_ storage.Store                         = (*Store)(nil)
+	_ storage.LoginRepository               = (*LoginRepository)(nil)
+	_ storage.PasswordHistoryRepository     = (*PasswordHistoryRepository)(nil)
+	_ storage.ItemVersionRepository         = (*ItemVersionRepository)(nil)
+	_ storage.AttachmentRepository          = (*AttachmentRepository)(nil)
+	_ storage.FolderRepository              = (*FolderRepository)(nil)
+	_ storage.TagRepository                 = (*TagRepository)(nil)
+	_ storage.CreditCardRepository          = (*CreditCardRepository)(nil)
+	_ storage.BankAccountRepository         = (*BankAccountRepository)(nil)
+	_ storage.NoteRepository                = (*NoteRepository)(nil)
+	_ storage.EmailRepository               = (*EmailRepository)(nil)
+	_ storage.EquivalentDomainRepository    = (*EquivalentDomainRepository)(nil)
+	_ storage.WebAuthnCredentialRepository  = (*WebAuthnCredentialRepository)(nil)
+	_ storage.TokenRepository               = (*TokenRepository)(nil)
+	_ storage.UserRepository                = (*UserRepository)(nil)
+	_ storage.ServerRepository              = (*ServerRepository)(nil)
+	_ storage.SubscriptionRepository        = (*SubscriptionRepository)(nil)
+	_ storage.MachineAccountRepository      = (*MachineAccountRepository)(nil)
+	_ storage.PolicyRepository              = (*PolicyRepository)(nil)
+	_ storage.SSOIdentityRepository         = (*SSOIdentityRepository)(nil)
+	_ storage.PersonalAccessTokenRepository = (*PersonalAccessTokenRepository)(nil)
+	_ storage.TrustedDeviceRepository       = (*TrustedDeviceRepository)(nil)
+	_ storage.SigninFailureRepository       = (*SigninFailureRepository)(nil)
+	_ storage.AuditLogRepository            = (*AuditLogRepository)(nil)
+	_ storage.AuditEventRepository          = (*AuditEventRepository)(nil)
+	_ storage.ExportJobRepository           = (*ExportJobRepository)(nil)
+	_ storage.RetentionRepository           = (*RetentionRepository)(nil)
+	_ storage.TrashRepository               = (*TrashRepository)(nil)
+	_ storage.SyncCounterRepository         = (*SyncCounterRepository)(nil)
+	_ storage.ReencryptionRepository        = (*ReencryptionRepository)(nil)
+	_ storage.SchemaMigrationRepository     = (*SchemaMigrationRepository)(nil)
+)
+
+// Mocks is a mocked Store with a mock for each of its repositories.
+// Expectations are set on the repositories, Store returns them as they are.
+type Mocks struct {
+	Store               *Store
+	Logins              *LoginRepository
+	PasswordHistories   *PasswordHistoryRepository
+	ItemVersions        *ItemVersionRepository
+	Attachments         *AttachmentRepository
+	Folders             *FolderRepository
+	Tags                *TagRepository
+	CreditCards         *CreditCardRepository
+	BankAccounts        *BankAccountRepository
+	Notes               *NoteRepository
+	Emails              *EmailRepository
+	EquivalentDomains   *EquivalentDomainRepository
+	WebAuthnCredentials *WebAuthnCredentialRepository
+	Tokens              *TokenRepository
+	Users               *UserRepository
+	Servers             *ServerRepository
+	Subscriptions       *SubscriptionRepository
+	MachineAccounts     *MachineAccountRepository
+	Policies            *PolicyRepository
+	SSOIdentities       *SSOIdentityRepository
+	AccessTokens        *PersonalAccessTokenRepository
+	TrustedDevices      *TrustedDeviceRepository
+	SigninFailures      *SigninFailureRepository
+	AuditLogs           *AuditLogRepository
+	AuditEvents         *AuditEventRepository
+	ExportJobs          *ExportJobRepository
+	Retention           *RetentionRepository
+	Trash               *TrashRepository
+	SyncCounters        *SyncCounterRepository
+	Reencryption        *ReencryptionRepository
+	SchemaMigrations    *SchemaMigrationRepository
+}
+
+// NewMocks builds a Store mock which returns a new mock for each repository
+func NewMocks() *Mocks {
+	m := &Mocks{
+		Store:               new(Store),
+		Logins:              new(LoginRepository),
+		PasswordHistories:   new(PasswordHistoryRepository),
+		ItemVersions:        new(ItemVersionRepository),
+		Attachments:         new(AttachmentRepository),
+		Folders:             new(FolderRepository),
+		Tags:                new(TagRepository),
+		CreditCards:         new(CreditCardRepository),
+		BankAccounts:        new(BankAccountRepository),
+		Notes:               new(NoteRepository),
+		Emails:              new(EmailRepository),
+		EquivalentDomains:   new(EquivalentDomainRepository),
+		WebAuthnCredentials: new(WebAuthnCredentialRepository),
+		Tokens:              new(TokenRepository),
+		Users:               new(UserRepository),
+		Servers:             new(ServerRepository),
+		Subscriptions:       new(SubscriptionRepository),
+		MachineAccounts:     new(MachineAccountRepository),
+		Policies:            new(PolicyRepository),
+		SSOIdentities:       new(SSOIdentityRepository),
+		AccessTokens:        new(PersonalAccessTokenRepository),
+		TrustedDevices:      new(TrustedDeviceRepository),
+		SigninFailures:      new(SigninFailureRepository),
+		AuditLogs:           new(AuditLogRepository),
+		AuditEvents:         new(AuditEventRepository),
+		ExportJobs:          new(ExportJobRepository),
+		Retention:           new(RetentionRepository),
+		Trash:               new(TrashRepository),
+		SyncCounters:        new(SyncCounterRepository),
+		Reencryption:        new(ReencryptionRepository),
+		SchemaMigrations:    new(SchemaMigrationRepository),
+	}
+
+	m.Store.On("Logins").Return(m.Logins).Maybe()
+	m.Store.On("PasswordHistories").Return(m.PasswordHistories).Maybe()
+	m.Store.On("ItemVersions").Return(m.ItemVersions).Maybe()
+	m.Store.On("Attachments").Return(m.Attachments).Maybe()
+	m.Store.On("Folders").Return(m.Folders).Maybe()
+	m.Store.On("Tags").Return(m.Tags).Maybe()
+	m.Store.On("CreditCards").Return(m.CreditCards).Maybe()
+	m.Store.On("BankAccounts").Return(m.BankAccounts).Maybe()
+	m.Store.On("Notes").Return(m.Notes).Maybe()
+	m.Store.On("Emails").Return(m.Emails).Maybe()
+	m.Store.On("EquivalentDomains").Return(m.EquivalentDomains).Maybe()
+	m.Store.On("WebAuthnCredentials").Return(m.WebAuthnCredentials).Maybe()
+	m.Store.On("Tokens").Return(m.Tokens).Maybe()
+	m.Store.On("Users").Return(m.Users).Maybe()
+	m.Store.On("Servers").Return(m.Servers).Maybe()
+	m.Store.On("Subscriptions").Return(m.Subscriptions).Maybe()
+	m.Store.On("MachineAccounts").Return(m.MachineAccounts).Maybe()
+	m.Store.On("Policies").Return(m.Policies).Maybe()
+	m.Store.On("SSOIdentities").Return(m.SSOIdentities).Maybe()
+	m.Store.On("AccessTokens").Return(m.AccessTokens).Maybe()
+	m.Store.On("TrustedDevices").Return(m.TrustedDevices).Maybe()
+	m.Store.On("SigninFailures").Return(m.SigninFailures).Maybe()
+	m.Store.On("AuditLogs").Return(m.AuditLogs).Maybe()
+	m.Store.On("AuditEvents").Return(m.AuditEvents).Maybe()
+	m.Store.On("ExportJobs").Return(m.ExportJobs).Maybe()
+	m.Store.On("Retention").Return(m.Retention).Maybe()
+	m.Store.On("Trash").Return(m.Trash).Maybe()
+	m.Store.On("SyncCounters").Return(m.SyncCounters).Maybe()
+	m.Store.On("Reencryption").Return(m.Reencryption).Maybe()
+	m.Store.On("SchemaMigrations").Return(m.SchemaMigrations).Maybe()
+	// Transaction runs its function with the Store itself and returns its error
+	tx := m.Store.On("Transaction", mock.Anything).Maybe()
+	tx.Run(func(args mock.Arguments) {
+		tx.ReturnArguments = mock.Arguments{args.Get(0).(storage.TxFunc)(m.Store)}
+	})
+	m.Store.On("Ping").Return(nil).Maybe()
+	m.Store.On("PendingMigrations").Return([]string(nil)).Maybe()
+	m.Store.On("PoolStats").Return(map[string]sql.DBStats(nil)).Maybe()
+
+	return m
+}
+
+// AssertExpectations asserts the expectations of all repository mocks
+func (m *Mocks) AssertExpectations(t mock.TestingT) bool {
+	return mock.AssertExpectationsForObjects(t,
+		m.Store,
+		m.Logins,
+		m.PasswordHistories,
+		m.ItemVersions,
+		m.Attachments,
+		m.Folders,
+		m.Tags,
+		m.CreditCards,
+		m.BankAccounts,
+		m.Notes,
+		m.Emails,
+		m.EquivalentDomains,
+		m.WebAuthnCredentials,
+		m.Tokens,
+		m.Users,
+		m.Servers,
+		m.Subscriptions,
+		m.MachineAccounts,
+		m.Policies,
+		m.SSOIdentities,
+		m.AccessTokens,
+		m.TrustedDevices,
+		m.SigninFailures,
+		m.AuditLogs,
+		m.AuditEvents,
+		m.ExportJobs,
+		m.Retention,
+		m.Trash,
+		m.SyncCounters,
+		m.Reencryption,
+		m.SchemaMigrations,
+	)
+}
@@ -75,8 +75,3 @@ func (p *Repository) Delete(id uint) error {
 	err := p.db.Delete(&model.Subscription{ID: id}).Error
 	return err
 }
-
-// Migrate ...
-func (p *Repository) Migrate() error {
-	return p.db.AutoMigrate(&model.Subscription{}).Error
-}
@@ -0,0 +1,27 @@
+package synccounter
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage/revision"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// Find ...
+func (p *Repository) Find(schema string) (*model.SyncCounter, error) {
+	return revision.Current(p.db, schema)
+}
+
+// MarkPurged ...
+func (p *Repository) MarkPurged(schema string) error {
+	return revision.MarkPurged(p.db, schema)
+}
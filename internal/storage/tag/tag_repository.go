@@ -0,0 +1,83 @@
+package tag
+
+import (
+	"database/sql"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// All ...
+func (p *Repository) All(schema string) ([]model.Tag, error) {
+	tags := []model.Tag{}
+	err := p.db.Table(schema + ".tags").Order("id").Find(&tags).Error
+	return tags, err
+}
+
+// FindByID ...
+func (p *Repository) FindByID(id uint, schema string) (*model.Tag, error) {
+	tag := new(model.Tag)
+	err := p.db.Table(schema+".tags").Where(`id = ?`, id).First(&tag).Error
+	return tag, err
+}
+
+// Save ...
+func (p *Repository) Save(tag *model.Tag, schema string) (*model.Tag, error) {
+	err := p.db.Table(schema + ".tags").Save(&tag).Error
+	return tag, err
+}
+
+// Delete ...
+func (p *Repository) Delete(id uint, schema string) error {
+	return transaction(p.db, func(tx *gorm.DB) error {
+		if err := tx.Table(schema+".item_tags").Where(`tag_id = ?`, id).Delete(&model.ItemTag{}).Error; err != nil {
+			return err
+		}
+		return tx.Table(schema + ".tags").Delete(&model.Tag{ID: id}).Error
+	})
+}
+
+// FindItemTags ...
+func (p *Repository) FindItemTags(itemType string, itemIDs []uint, schema string) ([]model.ItemTag, error) {
+	itemTags := []model.ItemTag{}
+	if len(itemIDs) == 0 {
+		return itemTags, nil
+	}
+	err := p.db.Table(schema+".item_tags").Where(`item_type = ? AND item_id IN (?)`, itemType, itemIDs).Order("tag_id").Find(&itemTags).Error
+	return itemTags, err
+}
+
+// SetItemTags ...
+func (p *Repository) SetItemTags(itemType string, itemID uint, tagIDs []uint, schema string) error {
+	return transaction(p.db, func(tx *gorm.DB) error {
+		if err := tx.Table(schema+".item_tags").Where(`item_type = ? AND item_id = ?`, itemType, itemID).Delete(&model.ItemTag{}).Error; err != nil {
+			return err
+		}
+		for _, tagID := range tagIDs {
+			itemTag := &model.ItemTag{ItemType: itemType, ItemID: itemID, TagID: tagID}
+			if err := tx.Table(schema + ".item_tags").Create(itemTag).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// transaction runs fn in a transaction, or in the transaction the db is already in since
+// gorm can't nest them
+func transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	if _, ok := db.CommonDB().(*sql.Tx); ok {
+		return fn(db)
+	}
+	return db.Transaction(fn)
+}
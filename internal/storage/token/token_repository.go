@@ -5,7 +5,6 @@ import (
 
 	"github.com/jinzhu/gorm"
 	"github.com/passwall/passwall-server/model"
-	uuid "github.com/satori/go.uuid"
 )
 
 // Repository ...
@@ -18,7 +17,7 @@ func NewRepository(db *gorm.DB) *Repository {
 	return &Repository{db: db}
 }
 
-//Any represents any match
+// Any represents any match
 func (p *Repository) Any(uuid string) (model.Token, bool) {
 
 	token := model.Token{}
@@ -30,31 +29,56 @@ func (p *Repository) Any(uuid string) (model.Token, bool) {
 	return token, false
 }
 
-//Save saves model to database
-func (p *Repository) Save(userid int, uid uuid.UUID, tkn string, expriydate time.Time, transmissionKey string) {
-
-	token := &model.Token{
-		UserID:          userid,
-		UUID:            uid,
-		Token:           tkn,
-		ExpiryTime:      expriydate,
-		TransmissionKey: transmissionKey,
-	}
+// Save saves model to database
+func (p *Repository) Save(token *model.Token) {
 	p.db.Create(token)
+}
 
+// Rotate marks the refresh token as used, only one caller can rotate it
+func (p *Repository) Rotate(uuid string, rotatedAt time.Time) bool {
+	result := p.db.Model(&model.Token{}).Where("uuid = ? AND rotated_at IS NULL", uuid).Update("rotated_at", rotatedAt)
+	return result.Error == nil && result.RowsAffected == 1
 }
 
-//Delete deletes from database
+// Delete deletes from database
 func (p *Repository) Delete(userid int) {
 	p.db.Delete(model.Token{}, "user_id = ?", userid)
 }
 
-//DeleteByUUID deletes from database by uuid
+// DeleteByUUID deletes from database by uuid
 func (p *Repository) DeleteByUUID(uuid string) {
 	p.db.Delete(model.Token{}, "uuid = ?", uuid)
 }
 
-// Migrate ...
-func (p *Repository) Migrate() error {
-	return p.db.AutoMigrate(&model.Token{}).Error
+// DeleteByFamily deletes the tokens of the session from database
+func (p *Repository) DeleteByFamily(family string) {
+	p.db.Delete(model.Token{}, "family = ?", family)
+}
+
+// DeleteAccessByFamily deletes the access tokens of the session from database
+func (p *Repository) DeleteAccessByFamily(family string) {
+	p.db.Delete(model.Token{}, "family = ? AND refresh = ?", family, false)
+}
+
+// DeleteSuperseded deletes the rotated refresh tokens which expired before the time
+func (p *Repository) DeleteSuperseded(before time.Time) (int, error) {
+	result := p.db.Delete(model.Token{}, "rotated_at IS NOT NULL AND expiry_time < ?", before)
+	return int(result.RowsAffected), result.Error
+}
+
+// Touch sets the last activity time of the tokens of the user
+func (p *Repository) Touch(userid int, lastUsedAt time.Time) error {
+	return p.db.Model(&model.Token{}).Where("user_id = ?", userid).Update("last_used_at", lastUsedAt).Error
+}
+
+// TouchByFamily sets the last activity time of the tokens of the session
+func (p *Repository) TouchByFamily(family string, lastUsedAt time.Time) error {
+	return p.db.Model(&model.Token{}).Where("family = ?", family).Update("last_used_at", lastUsedAt).Error
+}
+
+// FindSessions returns the refresh tokens of the sessions of the user which weren't rotated yet
+func (p *Repository) FindSessions(userid int) ([]model.Token, error) {
+	tokens := []model.Token{}
+	err := p.db.Where("user_id = ? AND refresh = ? AND rotated_at IS NULL", userid, true).Order("last_used_at desc").Find(&tokens).Error
+	return tokens, err
 }
@@ -0,0 +1,43 @@
+package trash
+
+import (
+	"time"
+
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/storage/revision"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindDeleted ...
+func (p *Repository) FindDeleted(table string, rows interface{}) error {
+	return p.db.Unscoped().Table(table).Where("deleted_at IS NOT NULL").Order("deleted_at desc").Find(rows).Error
+}
+
+// Restore ...
+func (p *Repository) Restore(table string, id uint) error {
+	return revision.Change(p.db, table, func(tx *gorm.DB, syncRevision int64) error {
+		result := tx.Exec(`UPDATE `+table+` SET deleted_at = NULL, updated_at = ?, sync_revision = ? WHERE id = ? AND deleted_at IS NOT NULL`, time.Now(), syncRevision, id)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
+}
+
+// Purge ...
+func (p *Repository) Purge(table string, id uint) error {
+	result := p.db.Exec(`DELETE FROM `+table+` WHERE id = ? AND deleted_at IS NOT NULL`, id)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
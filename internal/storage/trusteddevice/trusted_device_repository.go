@@ -0,0 +1,55 @@
+package trusteddevice
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// FindAllByUserID ...
+func (p *Repository) FindAllByUserID(userID uint) ([]model.TrustedDevice, error) {
+	devices := []model.TrustedDevice{}
+	err := p.db.Where(`user_id = ?`, userID).Order("id").Find(&devices).Error
+	return devices, err
+}
+
+// FindByID ...
+func (p *Repository) FindByID(id uint) (*model.TrustedDevice, error) {
+	device := new(model.TrustedDevice)
+	err := p.db.Where(`id = ?`, id).First(&device).Error
+	return device, err
+}
+
+// FindByHash ...
+func (p *Repository) FindByHash(hash string) (*model.TrustedDevice, error) {
+	device := new(model.TrustedDevice)
+	err := p.db.Where(`hash = ?`, hash).First(&device).Error
+	return device, err
+}
+
+// Save ...
+func (p *Repository) Save(device *model.TrustedDevice) (*model.TrustedDevice, error) {
+	err := p.db.Save(&device).Error
+	return device, err
+}
+
+// Delete ...
+func (p *Repository) Delete(id uint) error {
+	err := p.db.Delete(&model.TrustedDevice{ID: id}).Error
+	return err
+}
+
+// DeleteByUserID ...
+func (p *Repository) DeleteByUserID(userID uint) error {
+	err := p.db.Where(`user_id = ?`, userID).Delete(&model.TrustedDevice{}).Error
+	return err
+}
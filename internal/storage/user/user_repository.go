@@ -4,8 +4,9 @@ import (
 	log "github.com/sirupsen/logrus"
 
 	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/internal/app/passhash"
+	"github.com/passwall/passwall-server/internal/storage/sqlite"
 	"github.com/passwall/passwall-server/model"
-	"golang.org/x/crypto/bcrypt"
 )
 
 // Repository ...
@@ -72,8 +73,8 @@ func (p *Repository) FindByCredentials(email, masterPassword string) (*model.Use
 		return user, err
 	}
 
-	// Comparing the password with the bcrypt hash
-	err = bcrypt.CompareHashAndPassword([]byte(user.MasterPassword), []byte(masterPassword))
+	// Comparing the password with the Argon2id or older bcrypt hash
+	err = passhash.Verify(user.MasterPassword, masterPassword)
 	if err != nil {
 		return user, err
 	}
@@ -89,25 +90,29 @@ func (p *Repository) Save(user *model.User) (*model.User, error) {
 
 // Delete ...
 func (p *Repository) Delete(id uint, schema string) error {
-
-	err := p.db.Exec("DROP SCHEMA " + schema + " CASCADE").Error
-	if err != nil {
+	if err := p.DropSchema(schema); err != nil {
 		log.Error(err)
 	}
 
-	err = p.db.Delete(&model.User{ID: id}).Error
+	err := p.db.Delete(&model.User{ID: id}).Error
 	return err
 }
 
-// Migrate ...
-func (p *Repository) Migrate() error {
-	return p.db.AutoMigrate(&model.User{}).Error
+// DropSchema ...
+func (p *Repository) DropSchema(schema string) error {
+	if p.db.Dialect().GetName() == sqlite.Driver {
+		return sqlite.DropSchema(p.db, schema)
+	}
+	return p.db.Exec("DROP SCHEMA IF EXISTS " + schema + " CASCADE").Error
 }
 
 // CreateSchema ...
 func (p *Repository) CreateSchema(schema string) error {
 	var err error
 	if schema != "" && schema != "public" {
+		if p.db.Dialect().GetName() == sqlite.Driver {
+			return sqlite.CreateSchema(p.db, schema)
+		}
 		err := p.db.Exec("CREATE SCHEMA IF NOT EXISTS " + schema).Error
 		if err != nil {
 			log.Error(err)
@@ -0,0 +1,53 @@
+package webauthncredential
+
+import (
+	"github.com/jinzhu/gorm"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Repository ...
+type Repository struct {
+	db *gorm.DB
+}
+
+// NewRepository ...
+func NewRepository(db *gorm.DB) *Repository {
+	return &Repository{db: db}
+}
+
+// All ...
+func (p *Repository) All(schema string) ([]model.WebAuthnCredential, error) {
+	credentials := []model.WebAuthnCredential{}
+	err := p.db.Table(schema + ".webauthn_credentials").Order("id asc").Find(&credentials).Error
+	return credentials, err
+}
+
+// FindByID ...
+func (p *Repository) FindByID(id uint, schema string) (*model.WebAuthnCredential, error) {
+	credential := new(model.WebAuthnCredential)
+	err := p.db.Table(schema+".webauthn_credentials").Where(`id = ?`, id).First(&credential).Error
+	return credential, err
+}
+
+// FindByCredentialID ...
+func (p *Repository) FindByCredentialID(credentialID string, schema string) (*model.WebAuthnCredential, error) {
+	credential := new(model.WebAuthnCredential)
+	err := p.db.Table(schema+".webauthn_credentials").Where(`credential_id = ?`, credentialID).First(&credential).Error
+	return credential, err
+}
+
+// Save ...
+func (p *Repository) Save(credential *model.WebAuthnCredential, schema string) (*model.WebAuthnCredential, error) {
+	err := p.db.Table(schema + ".webauthn_credentials").Save(&credential).Error
+	return credential, err
+}
+
+// Delete ...
+func (p *Repository) Delete(id uint, schema string) error {
+	return p.db.Table(schema + ".webauthn_credentials").Delete(&model.WebAuthnCredential{ID: id}).Error
+}
+
+// DeleteAll ...
+func (p *Repository) DeleteAll(schema string) error {
+	return p.db.Table(schema + ".webauthn_credentials").Delete(&model.WebAuthnCredential{}).Error
+}
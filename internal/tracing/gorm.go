@@ -0,0 +1,72 @@
+package tracing
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+
+	"github.com/jinzhu/gorm"
+)
+
+const scopeSpan = "tracing:span"
+
+// Register adds callbacks to the db which record a span for each query of a traced
+// request or job. The statements are recorded with their placeholders, not the values.
+func Register(db *gorm.DB) {
+	// gorm logs each registration, the callbacks are registered on a quiet copy
+	quiet := db.New()
+	quiet.SetLogger(gorm.Logger{LogWriter: log.New(ioutil.Discard, "", 0)})
+	callback := quiet.Callback()
+	if callback.Query().Get("tracing:before_query") != nil {
+		return
+	}
+	callback.Create().Before("gorm:begin_transaction").Register("tracing:before_create", beforeQuery("create"))
+	callback.Create().After("gorm:commit_or_rollback_transaction").Register("tracing:after_create", afterQuery)
+	callback.Update().Before("gorm:begin_transaction").Register("tracing:before_update", beforeQuery("update"))
+	callback.Update().After("gorm:commit_or_rollback_transaction").Register("tracing:after_update", afterQuery)
+	callback.Delete().Before("gorm:begin_transaction").Register("tracing:before_delete", beforeQuery("delete"))
+	callback.Delete().After("gorm:commit_or_rollback_transaction").Register("tracing:after_delete", afterQuery)
+	callback.Query().Before("gorm:query").Register("tracing:before_query", beforeQuery("select"))
+	callback.Query().After("gorm:after_query").Register("tracing:after_query", afterQuery)
+	callback.RowQuery().Before("gorm:row_query").Register("tracing:before_row_query", beforeQuery("select"))
+	callback.RowQuery().After("gorm:row_query").Register("tracing:after_row_query", afterQuery)
+}
+
+func beforeQuery(operation string) func(*gorm.Scope) {
+	return func(scope *gorm.Scope) {
+		table := scope.TableName()
+		span := StartChild("db."+operation+" "+table, KindClient)
+		if span == nil {
+			return
+		}
+		span.SetAttribute("db.system", dbSystem(scope.Dialect().GetName()))
+		span.SetAttribute("db.operation", operation)
+		span.SetAttribute("db.sql.table", table)
+		scope.InstanceSet(scopeSpan, span)
+	}
+}
+
+func afterQuery(scope *gorm.Scope) {
+	value, ok := scope.InstanceGet(scopeSpan)
+	if !ok {
+		return
+	}
+	span := value.(*Span)
+	span.SetAttribute("db.statement", scope.SQL)
+	span.SetAttribute("db.rows_affected", scope.DB().RowsAffected)
+	if scope.HasError() && !gorm.IsRecordNotFoundError(scope.DB().Error) {
+		span.SetError(scope.DB().Error)
+	}
+	span.End()
+}
+
+// dbSystem names the dialect like OpenTelemetry does
+func dbSystem(dialect string) string {
+	switch {
+	case dialect == "postgres":
+		return "postgresql"
+	case strings.Contains(dialect, "sqlite"):
+		return "sqlite"
+	}
+	return dialect
+}
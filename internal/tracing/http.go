@@ -0,0 +1,54 @@
+package tracing
+
+import (
+	"net/http"
+	"regexp"
+)
+
+// idSegment matches the ids in paths, they are left out of the span names
+var idSegment = regexp.MustCompile(`/[0-9]+(/|$)`)
+
+// SpanName names the span of a request by its method and path, ids like the one of
+// /api/logins/3 are replaced so requests to the same endpoint have the same name
+func SpanName(r *http.Request) string {
+	path := r.URL.Path
+	for idSegment.MatchString(path) {
+		path = idSegment.ReplaceAllString(path, "/{id}$1")
+	}
+	return r.Method + " " + path
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// Transport records a client span for each request of base and sends its traceparent,
+// so the services it calls continue the trace. A nil base is http.DefaultTransport.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{base: base}
+}
+
+func (t *transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	span := StartChild(r.Method+" "+r.URL.Host, KindClient)
+	if span == nil {
+		return t.base.RoundTrip(r)
+	}
+	defer span.End()
+
+	// RoundTrippers shouldn't change the request of the caller
+	r = r.Clone(r.Context())
+	r.Header.Set(Header, span.Traceparent())
+	span.SetAttribute("http.method", r.Method)
+	span.SetAttribute("http.url", r.URL.Scheme+"://"+r.URL.Host+r.URL.Path)
+
+	resp, err := t.base.RoundTrip(r)
+	if err != nil {
+		span.SetError(err)
+		return nil, err
+	}
+	span.SetAttribute("http.status_code", resp.StatusCode)
+	return resp, nil
+}
@@ -0,0 +1,267 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	queueSize     = 2048
+	batchSize     = 512
+	flushInterval = 5 * time.Second
+)
+
+// Config is the configuration of the OTLP exporter
+type Config struct {
+	Endpoint    string            // base url of the collector like http://localhost:4318
+	ServiceName string            // service.name of the resource
+	SampleRatio float64           // share of the new traces which are exported, 0 to 1
+	Headers     map[string]string // e.g. the api key of a hosted collector
+}
+
+// Exporter sends the ended spans in batches to an OTLP/HTTP collector. Spans are
+// dropped when its queue is full, tracing never slows down the requests.
+type Exporter struct {
+	cfg    Config
+	url    string
+	client *http.Client
+	spans  chan *Span
+	done   chan struct{}
+	wg     sync.WaitGroup
+}
+
+// NewExporter starts the exporter of the configuration
+func NewExporter(cfg Config) *Exporter {
+	url := strings.TrimSuffix(cfg.Endpoint, "/")
+	if !strings.HasSuffix(url, "/v1/traces") {
+		url += "/v1/traces"
+	}
+	if cfg.ServiceName == "" {
+		cfg.ServiceName = "passwall-server"
+	}
+	e := &Exporter{
+		cfg:    cfg,
+		url:    url,
+		client: &http.Client{Timeout: 10 * time.Second},
+		spans:  make(chan *Span, queueSize),
+		done:   make(chan struct{}),
+	}
+	e.wg.Add(1)
+	go e.run()
+	return e
+}
+
+// Shutdown sends the queued spans and stops the exporter
+func (e *Exporter) Shutdown() {
+	close(e.done)
+	e.wg.Wait()
+}
+
+// ParseHeaders parses headers like "api-key=secret,x-tenant=passwall" of
+// OTEL_EXPORTER_OTLP_HEADERS
+func ParseHeaders(value string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) == 2 && strings.TrimSpace(parts[0]) != "" {
+			headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
+		}
+	}
+	return headers
+}
+
+// sample decides by the trace id, so all services of a trace decide the same
+func (e *Exporter) sample(traceID [16]byte) bool {
+	switch {
+	case e.cfg.SampleRatio >= 1:
+		return true
+	case e.cfg.SampleRatio <= 0:
+		return false
+	}
+	return float64(binary.BigEndian.Uint64(traceID[8:])>>11)/(1<<53) < e.cfg.SampleRatio
+}
+
+func (e *Exporter) queue(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+	}
+}
+
+func (e *Exporter) run() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		if err := e.send(batch); err != nil {
+			log.Warnf("%d spans couldn't be exported: %v", len(batch), err)
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case span := <-e.spans:
+			if batch = append(batch, span); len(batch) == batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case <-e.done:
+			for {
+				select {
+				case span := <-e.spans:
+					batch = append(batch, span)
+				default:
+					flush()
+					return
+				}
+			}
+		}
+	}
+}
+
+// send posts the spans in the JSON encoding of OTLP
+func (e *Exporter) send(spans []*Span) error {
+	encoded := make([]otlpSpan, len(spans))
+	for i, span := range spans {
+		encoded[i] = toOTLPSpan(span)
+	}
+	body, err := json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: toOTLPAttributes(map[string]interface{}{"service.name": e.cfg.ServiceName})},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "github.com/passwall/passwall-server/internal/tracing"},
+			Spans: encoded,
+		}},
+	}}})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("collector responded with %d", resp.StatusCode)
+	}
+	return nil
+}
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string                 `json:"key"`
+	Value map[string]interface{} `json:"value"`
+}
+
+func toOTLPSpan(span *Span) otlpSpan {
+	encoded := otlpSpan{
+		TraceID:           hex.EncodeToString(span.TraceID[:]),
+		SpanID:            hex.EncodeToString(span.SpanID[:]),
+		Name:              span.Name,
+		Kind:              span.Kind,
+		StartTimeUnixNano: strconv.FormatInt(span.StartTime.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(span.EndTime.UnixNano(), 10),
+		Attributes:        toOTLPAttributes(span.Attributes),
+		Status:            otlpStatus{Code: span.Status, Message: span.Message},
+	}
+	if span.ParentID != [8]byte{} {
+		encoded.ParentSpanID = hex.EncodeToString(span.ParentID[:])
+	}
+	return encoded
+}
+
+// toOTLPAttributes encodes the attributes sorted by key, int64 values are strings in OTLP JSON
+func toOTLPAttributes(attributes map[string]interface{}) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	encoded := make([]otlpAttribute, 0, len(keys))
+	for _, key := range keys {
+		var value map[string]interface{}
+		switch v := attributes[key].(type) {
+		case string:
+			value = map[string]interface{}{"stringValue": v}
+		case bool:
+			value = map[string]interface{}{"boolValue": v}
+		case int:
+			value = map[string]interface{}{"intValue": strconv.Itoa(v)}
+		case int64:
+			value = map[string]interface{}{"intValue": strconv.FormatInt(v, 10)}
+		case uint:
+			value = map[string]interface{}{"intValue": strconv.FormatUint(uint64(v), 10)}
+		case float64:
+			value = map[string]interface{}{"doubleValue": v}
+		default:
+			value = map[string]interface{}{"stringValue": fmt.Sprint(v)}
+		}
+		encoded = append(encoded, otlpAttribute{Key: key, Value: value})
+	}
+	return encoded
+}
@@ -0,0 +1,250 @@
+// Package tracing records OpenTelemetry spans of the requests and exports them to an
+// OTLP/HTTP collector. Incoming W3C traceparent headers continue the trace of the caller
+// and outgoing requests of Transport carry it on.
+//
+// GORM v1 and the repositories don't take a context, so the span a request is in is
+// also kept for its goroutine. Start makes the new span a child of it, this way the
+// spans of the services and of the queries are nested under the request span without
+// threading a context through every call. With tracing off Start returns nil, and the
+// methods of a nil span do nothing.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/passwall/passwall-server/internal/goroutine"
+)
+
+// Kinds of the spans, as numbered by OTLP
+const (
+	KindInternal = 1
+	KindServer   = 2
+	KindClient   = 3
+)
+
+// statusError is the OTLP code of the status of failed spans
+const statusError = 2
+
+// Header carries the trace context between services
+const Header = "traceparent"
+
+type spanKey struct{}
+
+// Span is an operation of a trace
+type Span struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	ParentID   [8]byte
+	Name       string
+	Kind       int
+	StartTime  time.Time
+	EndTime    time.Time
+	Attributes map[string]interface{}
+	Status     int
+	Message    string
+
+	sampled   bool
+	parent    *Span
+	goroutine uint64
+	once      sync.Once
+}
+
+var (
+	mu       sync.RWMutex
+	exporter *Exporter
+
+	// active is the innermost open span of each goroutine
+	active = struct {
+		sync.Mutex
+		spans map[uint64]*Span
+	}{spans: map[uint64]*Span{}}
+)
+
+// Enabled is true when spans are exported
+func Enabled() bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return exporter != nil
+}
+
+// Use exports the spans with e, nil turns tracing off
+func Use(e *Exporter) {
+	mu.Lock()
+	exporter = e
+	mu.Unlock()
+}
+
+func currentExporter() *Exporter {
+	mu.RLock()
+	defer mu.RUnlock()
+	return exporter
+}
+
+// Start starts a span as a child of the span the goroutine is in, or a new trace.
+// It's the span of the goroutine until it ends.
+func Start(name string) *Span {
+	e := currentExporter()
+	if e == nil {
+		return nil
+	}
+	id := goroutine.ID()
+	active.Lock()
+	parent := active.spans[id]
+	active.Unlock()
+	return start(e, name, KindInternal, parent, id)
+}
+
+// StartChild starts a span as a child of the span the goroutine is in. Unlike Start it
+// returns nil outside of a span, e.g. for the queries of the startup.
+func StartChild(name string, kind int) *Span {
+	e := currentExporter()
+	if e == nil {
+		return nil
+	}
+	id := goroutine.ID()
+	active.Lock()
+	parent := active.spans[id]
+	active.Unlock()
+	if parent == nil {
+		return nil
+	}
+	return start(e, name, kind, parent, id)
+}
+
+// StartRequest starts the server span of an incoming request. It continues the trace
+// of its traceparent header and is added to the context of the returned request.
+func StartRequest(r *http.Request, name string) (*Span, *http.Request) {
+	e := currentExporter()
+	if e == nil {
+		return nil, r
+	}
+	id := goroutine.ID()
+	var parent *Span
+	if remote, ok := parseTraceparent(r.Header.Get(Header)); ok {
+		parent = remote
+	}
+	span := start(e, name, KindServer, parent, id)
+	return span, r.WithContext(context.WithValue(r.Context(), spanKey{}, span))
+}
+
+// FromContext returns the span of the context, nil if it has none
+func FromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+func start(e *Exporter, name string, kind int, parent *Span, goroutine uint64) *Span {
+	span := &Span{
+		Name:       name,
+		Kind:       kind,
+		StartTime:  time.Now(),
+		Attributes: map[string]interface{}{},
+		goroutine:  goroutine,
+	}
+	rand.Read(span.SpanID[:])
+	if parent != nil {
+		span.TraceID = parent.TraceID
+		span.ParentID = parent.SpanID
+		span.sampled = parent.sampled
+	} else {
+		rand.Read(span.TraceID[:])
+		span.sampled = e.sample(span.TraceID)
+	}
+
+	// Remote parents aren't spans of this goroutine
+	if parent != nil && parent.goroutine == goroutine {
+		span.parent = parent
+	}
+	active.Lock()
+	active.spans[goroutine] = span
+	active.Unlock()
+	return span
+}
+
+// SetAttribute sets an attribute of the span, values are strings, numbers or bools
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.Attributes[key] = value
+}
+
+// SetError marks the span as failed with the error, nil errors are ignored
+func (s *Span) SetError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.Status = statusError
+	s.Message = err.Error()
+}
+
+// End ends the span and queues it for export. The goroutine goes back to its parent,
+// also when children of the span were left open.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.once.Do(func() {
+		s.EndTime = time.Now()
+
+		active.Lock()
+		current := active.spans[s.goroutine]
+		for current != nil && current != s {
+			current = current.parent
+		}
+		if current == s {
+			if s.parent != nil {
+				active.spans[s.goroutine] = s.parent
+			} else {
+				delete(active.spans, s.goroutine)
+			}
+		}
+		active.Unlock()
+
+		if e := currentExporter(); e != nil && s.sampled {
+			e.queue(s)
+		}
+	})
+}
+
+// Traceparent returns the traceparent header of the span
+func (s *Span) Traceparent() string {
+	if s == nil {
+		return ""
+	}
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return fmt.Sprintf("00-%s-%s-%s", hex.EncodeToString(s.TraceID[:]), hex.EncodeToString(s.SpanID[:]), flags)
+}
+
+// parseTraceparent reads the remote parent of a traceparent header like
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01
+func parseTraceparent(header string) (*Span, bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return nil, false
+	}
+	span := new(Span)
+	if _, err := hex.Decode(span.TraceID[:], []byte(parts[1])); err != nil || span.TraceID == [16]byte{} {
+		return nil, false
+	}
+	if _, err := hex.Decode(span.SpanID[:], []byte(parts[2])); err != nil || span.SpanID == [8]byte{} {
+		return nil, false
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil {
+		return nil, false
+	}
+	span.sampled = flags&1 == 1
+	return span, true
+}
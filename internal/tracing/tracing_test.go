@@ -0,0 +1,177 @@
+package tracing
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/passwall/passwall-server/internal/storage/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type secret struct {
+	ID    uint `gorm:"primary_key"`
+	Title string
+}
+
+// collector receives the exported spans like an OTLP/HTTP collector
+func collector(t *testing.T) (*httptest.Server, chan otlpRequest) {
+	requests := make(chan otlpRequest, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/traces", r.URL.Path)
+		assert.Equal(t, "secret", r.Header.Get("api-key"))
+		body, _ := ioutil.ReadAll(r.Body)
+		var req otlpRequest
+		assert.NoError(t, json.Unmarshal(body, &req))
+		requests <- req
+	}))
+	return srv, requests
+}
+
+func TestTraceparent(t *testing.T) {
+	parent, ok := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	require.True(t, ok)
+	assert.True(t, parent.sampled)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", parent.Traceparent())
+
+	for _, header := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e47zz-00f067aa0ba902b7-01",
+	} {
+		_, ok := parseTraceparent(header)
+		assert.False(t, ok, header)
+	}
+}
+
+func TestSpans(t *testing.T) {
+	// Tracing is off until an exporter is used
+	assert.Nil(t, Start("off"))
+	Start("off").SetAttribute("key", "value")
+	Start("off").End()
+
+	srv, requests := collector(t)
+	defer srv.Close()
+	e := NewExporter(Config{Endpoint: srv.URL, SampleRatio: 1, Headers: ParseHeaders("api-key=secret, broken")})
+	Use(e)
+	defer Use(nil)
+
+	// A request continues the trace of its caller
+	r := httptest.NewRequest(http.MethodGet, "/api/logins/3", nil)
+	r.Header.Set(Header, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	request, r := StartRequest(r, SpanName(r))
+	assert.Equal(t, request, FromContext(r.Context()))
+	assert.Equal(t, "GET /api/logins/{id}", request.Name)
+
+	service := Start("app.FindItem")
+	query := StartChild("db.select logins", KindClient)
+	query.SetError(errors.New("connection refused"))
+	query.End()
+
+	// The goroutine is back in the service span
+	assert.Equal(t, service.SpanID, StartChild("db.select notes", KindClient).ParentID)
+	service.End()
+	request.End()
+	assert.Nil(t, StartChild("db.select users", KindClient))
+	e.Shutdown()
+
+	var spans []otlpSpan
+	for len(requests) > 0 {
+		req := <-requests
+		assert.Equal(t, "passwall-server", req.ResourceSpans[0].Resource.Attributes[0].Value["stringValue"])
+		spans = append(spans, req.ResourceSpans[0].ScopeSpans[0].Spans...)
+	}
+	// The span left open isn't exported
+	require.Len(t, spans, 3)
+	assert.Equal(t, "db.select logins", spans[0].Name)
+	assert.Equal(t, otlpStatus{Code: statusError, Message: "connection refused"}, spans[0].Status)
+	assert.Equal(t, "app.FindItem", spans[1].Name)
+	assert.Equal(t, spans[1].SpanID, spans[0].ParentSpanID)
+	assert.Equal(t, "GET /api/logins/{id}", spans[2].Name)
+	assert.Equal(t, KindServer, spans[2].Kind)
+	assert.Equal(t, spans[2].SpanID, spans[1].ParentSpanID)
+	assert.Equal(t, "00f067aa0ba902b7", spans[2].ParentSpanID)
+	for _, span := range spans {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID)
+	}
+}
+
+func TestSampling(t *testing.T) {
+	e := &Exporter{cfg: Config{SampleRatio: 0.25}}
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		span := start(e, "sampled", KindInternal, nil, 0)
+		if span.sampled {
+			sampled++
+		}
+	}
+	assert.InDelta(t, 250, sampled, 60)
+
+	// Callers which didn't sample the trace aren't overruled
+	parent, _ := parseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00")
+	e.cfg.SampleRatio = 1
+	assert.False(t, start(e, "child", KindServer, parent, 0).sampled)
+	delete(active.spans, 0)
+}
+
+func TestTransport(t *testing.T) {
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get(Header)
+	}))
+	defer upstream.Close()
+	client := &http.Client{Transport: Transport(nil)}
+
+	_, err := client.Get(upstream.URL)
+	require.NoError(t, err)
+	assert.Empty(t, traceparent)
+
+	Use(&Exporter{cfg: Config{SampleRatio: 1}, spans: make(chan *Span, 10)})
+	defer Use(nil)
+	span := Start("app.SendAlert")
+	_, err = client.Get(upstream.URL)
+	require.NoError(t, err)
+	span.End()
+
+	call := <-currentExporter().spans
+	assert.Equal(t, call.Traceparent(), traceparent)
+	assert.Equal(t, span.SpanID, call.ParentID)
+	assert.Equal(t, KindClient, call.Kind)
+	assert.Equal(t, 200, call.Attributes["http.status_code"])
+}
+
+func TestQueries(t *testing.T) {
+	db, err := sqlite.Open(sqlite.Memory)
+	require.NoError(t, err)
+	defer db.Close()
+	Register(db)
+	Register(db)
+	require.NoError(t, db.AutoMigrate(&secret{}).Error)
+
+	Use(&Exporter{cfg: Config{SampleRatio: 1}, spans: make(chan *Span, 10)})
+	defer Use(nil)
+	span := Start("app.CreateLogin")
+	require.NoError(t, db.Create(&secret{Title: "API"}).Error)
+	require.NoError(t, db.Where("title = ?", "API").First(&secret{}).Error)
+	span.End()
+
+	spans := currentExporter().spans
+	require.Len(t, spans, 3)
+	create := <-spans
+	assert.Equal(t, "db.create secrets", create.Name)
+	assert.Equal(t, span.SpanID, create.ParentID)
+	assert.Equal(t, "sqlite", create.Attributes["db.system"])
+	assert.Equal(t, int64(1), create.Attributes["db.rows_affected"])
+
+	query := <-spans
+	assert.Equal(t, "db.select secrets", query.Name)
+	assert.Contains(t, query.Attributes["db.statement"], "title = ?")
+	assert.NotContains(t, query.Attributes["db.statement"], "API")
+}
@@ -0,0 +1,53 @@
+package model
+
+import "time"
+
+// PersonalAccessToken is a long-lived API key of a user for scripts and integrations.
+// Only the SHA-256 hash of the token is stored, it is shown once when it's created.
+type PersonalAccessToken struct {
+	ID              uint       `gorm:"primary_key" json:"id"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UserID          uint       `gorm:"index" json:"user_id"`
+	Name            string     `json:"name"`
+	Prefix          string     `json:"prefix"` // first characters of the token to tell the tokens apart
+	Hash            string     `gorm:"unique_index" json:"-"`
+	TransmissionKey string     `gorm:"type:text;" json:"-"`
+	ExpiresAt       *time.Time `json:"expires_at"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+}
+
+// PersonalAccessTokenDTO is the personal access token as seen by its user.
+// Token and TransmissionKey are only set in the response of the creation.
+type PersonalAccessTokenDTO struct {
+	ID              uint       `json:"id"`
+	Name            string     `json:"name" validate:"required,max=100"`
+	Prefix          string     `json:"prefix"`
+	Token           string     `json:"token,omitempty"`
+	TransmissionKey string     `json:"transmission_key,omitempty"`
+	ExpiresAt       *time.Time `json:"expires_at"` // never expires if empty
+	CreatedAt       time.Time  `json:"created_at"`
+	LastUsedAt      *time.Time `json:"last_used_at"`
+}
+
+// ToPersonalAccessTokenDTO ...
+func ToPersonalAccessTokenDTO(token *PersonalAccessToken) *PersonalAccessTokenDTO {
+	return &PersonalAccessTokenDTO{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		ExpiresAt:  token.ExpiresAt,
+		CreatedAt:  token.CreatedAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+}
+
+// ToPersonalAccessTokenDTOs ...
+func ToPersonalAccessTokenDTOs(tokens []PersonalAccessToken) []*PersonalAccessTokenDTO {
+	tokenDTOs := make([]*PersonalAccessTokenDTO, len(tokens))
+
+	for i := range tokens {
+		tokenDTOs[i] = ToPersonalAccessTokenDTO(&tokens[i])
+	}
+
+	return tokenDTOs
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"time"
+)
+
+// Attachment is a file of an item. Its content is encrypted with Key, a key of its own,
+// and kept in the blob store under BlobKey.
+type Attachment struct {
+	ID          uint      `gorm:"primary_key" json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	ItemType    string    `json:"item_type"`
+	ItemID      uint      `json:"item_id"`
+	Name        string    `json:"name" encrypt:"true"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`   // bytes of the decrypted content
+	SHA256      string    `json:"sha256"` // hex of the decrypted content
+	BlobKey     string    `json:"-"`
+	Key         string    `json:"-" encrypt:"server"` // base64 AES-256 key of the content
+}
+
+// AttachmentDTO DTO object for Attachment type
+type AttachmentDTO struct {
+	ID          uint      `json:"id"`
+	ItemType    string    `json:"item_type"`
+	ItemID      uint      `json:"item_id"`
+	Name        string    `json:"name"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// AttachmentLimitsDTO sets the attachment limits of a user in bytes, 0 is the limit of the
+// server configuration
+type AttachmentLimitsDTO struct {
+	MaxSize int64 `json:"max_size" validate:"min=0"` // of a single file
+	Quota   int64 `json:"quota" validate:"min=0"`    // of all files of the vault
+}
+
+// AttachmentUsageDTO is the space the attachments of a vault use and the limits of the user
+type AttachmentUsageDTO struct {
+	Used    int64 `json:"used"`
+	MaxSize int64 `json:"max_size"` // 0 is unlimited
+	Quota   int64 `json:"quota"`    // 0 is unlimited
+}
+
+// ToAttachmentDTO ...
+func ToAttachmentDTO(attachment *Attachment) *AttachmentDTO {
+	return &AttachmentDTO{
+		ID:          attachment.ID,
+		ItemType:    attachment.ItemType,
+		ItemID:      attachment.ItemID,
+		Name:        attachment.Name,
+		ContentType: attachment.ContentType,
+		Size:        attachment.Size,
+		SHA256:      attachment.SHA256,
+		CreatedAt:   attachment.CreatedAt,
+	}
+}
+
+/* EXAMPLE JSON OBJECT
+[
+	{"id": 4, "item_type": "logins", "item_id": 3, "name": "recovery-codes.txt", "content_type": "text/plain", "size": 180, "sha256": "9f86d0...", "created_at": "2020-06-01T12:00:00Z"}
+]
+*/
@@ -0,0 +1,26 @@
+package model
+
+import "time"
+
+// AuditEvent is an entry of the audit log of a vault, like a read of a login or a sign in.
+// The audit_logs table of each schema is append-only, events are never changed or deleted.
+type AuditEvent struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Actor     string    `json:"actor"` // user:7, token:3 or machine:<client id>
+	Action    string    `json:"action"`
+	ItemType  string    `json:"item_type,omitempty"`
+	ItemID    uint      `json:"item_id,omitempty"` // empty for lists
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Result    string    `json:"result"`
+}
+
+// AuditEventFilter selects the audit events of a vault, zero values don't filter
+type AuditEventFilter struct {
+	Since  time.Time
+	Until  time.Time
+	Action string
+	Limit  int
+	Offset int
+}
@@ -0,0 +1,39 @@
+package model
+
+import "time"
+
+// AuditLog is an entry of the audit trail. Hash covers the entry and the hash of the
+// previous entry, so changing or deleting an entry breaks the chain after it.
+type AuditLog struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Level     string    `json:"level"`
+	Event     string    `json:"event"`
+	Message   string    `gorm:"type:text" json:"message"`
+	Fields    string    `gorm:"type:text" json:"fields"` // JSON object
+	PrevHash  string    `json:"prev_hash"`
+	Hash      string    `json:"hash"`
+}
+
+// AuditCheckpoint is a signed hash of the audit trail at an entry.
+// Truncating the trail removes the entry of a checkpoint or changes its hash.
+// An anchor is saved when the retention policy purges the entries up to it,
+// the chain of the remaining entries starts from its hash.
+type AuditCheckpoint struct {
+	ID         uint      `gorm:"primary_key" json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	AuditLogID uint      `json:"audit_log_id"`
+	Hash       string    `json:"hash"`
+	Anchor     bool      `json:"anchor"`
+	Signature  string    `json:"signature"`
+}
+
+// AuditVerifyDTO is the result of the verification of the audit trail
+type AuditVerifyDTO struct {
+	Valid       bool     `json:"valid"`
+	Entries     int      `json:"entries"`
+	Checkpoints int      `json:"checkpoints"`
+	LastID      uint     `json:"last_id"`
+	PurgedUntil uint     `json:"purged_until,omitempty"` // entries up to it are purged by the retention policy
+	Problems    []string `json:"problems"`
+}
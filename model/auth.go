@@ -10,6 +10,7 @@ import (
 type AuthLoginDTO struct {
 	Email          string `validate:"required" json:"email"`
 	MasterPassword string `validate:"required" json:"master_password"`
+	DeviceSigninDTO
 }
 
 //AuthLoginResponse ...
@@ -19,6 +20,15 @@ type AuthLoginResponse struct {
 	TransmissionKey string `json:"transmission_key"`
 	*UserDTO
 	*SubscriptionAuthDTO
+
+	// Set instead of the tokens when the user has to verify a second factor,
+	// the sign in continues with the token at /auth/signin/<method>
+	TwoFactorRequired bool     `json:"two_factor_required,omitempty"`
+	TwoFactorToken    string   `json:"two_factor_token,omitempty"`
+	TwoFactorMethods  []string `json:"two_factor_methods,omitempty"`
+
+	// Set once when the device of the sign in is trusted, later sign ins send it back
+	DeviceToken string `json:"device_token,omitempty"`
 }
 
 //TokenDetailsDTO ...
@@ -31,3 +41,14 @@ type TokenDetailsDTO struct {
 	RtUUID          uuid.UUID
 	TransmissionKey string `json:"transmission_key"`
 }
+
+// ReauthDTO confirms the master password of the user before sensitive requests like exports
+type ReauthDTO struct {
+	MasterPassword string `json:"master_password" validate:"required"`
+}
+
+// ReauthTokenDTO proves the re-authentication in the X-Reauth-Token header until ExpiresAt
+type ReauthTokenDTO struct {
+	Token     string    `json:"reauth_token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
@@ -5,10 +5,49 @@ import "time"
 // Backup Response
 type Backup struct {
 	Name      string    `json:"name"`
+	Schema    string    `json:"schema"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256,omitempty"` // of the file, only set for new backups
 	CreatedAt time.Time `json:"created_at"`
 }
 
-//RestoreDTO file name for restore
+// RestoreDTO restores the backup with the name, or the base64 content of a backup file or
+// an export archive sealed with the passphrase
 type RestoreDTO struct {
-	Name string `json:"name"`
+	Name       string `json:"name"`
+	Content    string `json:"content"`
+	Passphrase string `json:"passphrase"`
+	Mode       string `json:"mode" validate:"omitempty,oneof=merge wipe"`
+	DryRun     bool   `json:"dry_run"`
+}
+
+// RestoreReportDTO is the summary of a restore, the items of a dry run are counted but
+// not changed
+type RestoreReportDTO struct {
+	Mode     string             `json:"mode"`
+	DryRun   bool               `json:"dry_run"`
+	Deleted  RestoreCountDTO    `json:"deleted"`  // of the vault before a wipe
+	Restored RestoreCountDTO    `json:"restored"` // folders and tags which the vault didn't have
+	Skipped  []ImportSkippedDTO `json:"skipped"`
+}
+
+// RestoreCountDTO counts the folders, tags and the items by type like "logins"
+type RestoreCountDTO struct {
+	Folders int            `json:"folders"`
+	Tags    int            `json:"tags"`
+	Items   map[string]int `json:"items"`
+}
+
+// BackupReport is the summary of a verified backup file
+type BackupReport struct {
+	File          string   `json:"file"`
+	Rows          int      `json:"rows"`
+	UnknownFields []string `json:"unknown_fields"`
+	Restored      bool     `json:"restored"`
+	RestoredRows  int      `json:"restored_rows"`
+}
+
+// Compatible tells if all fields of the backup are known by this server version
+func (r *BackupReport) Compatible() bool {
+	return len(r.UnknownFields) == 0
 }
@@ -10,6 +10,8 @@ type BankAccount struct {
 	CreatedAt     time.Time  `json:"created_at"`
 	UpdatedAt     time.Time  `json:"updated_at"`
 	DeletedAt     *time.Time `json:"deleted_at"`
+	Revision      uint       `gorm:"not null;default:0" json:"revision"`
+	SyncRevision  int64      `gorm:"not null;default:0" json:"sync_revision"`
 	BankName      string     `json:"title"`
 	BankCode      string     `json:"bank_code"`
 	AccountName   string     `json:"account_name" encrypt:"true"`
@@ -17,11 +19,19 @@ type BankAccount struct {
 	IBAN          string     `json:"iban" encrypt:"true"`
 	Currency      string     `json:"currency" encrypt:"true"`
 	Password      string     `json:"password" encrypt:"true"`
+	Pinned        bool       `json:"pinned"`
+	IsFavorite    bool       `json:"is_favorite"`
+	SortOrder     int        `json:"sort_order"`
+	FolderID      uint       `json:"folder_id"`
+	Tags          []uint     `gorm:"-" json:"tags"`
+	Reprompt      bool       `json:"reprompt"`
+	Canary        bool       `json:"canary"`
 }
 
 //BankAccountDTO DTO object for BankAccount type
 type BankAccountDTO struct {
 	ID            uint   `json:"id"`
+	Revision      uint   `json:"revision"`
 	BankName      string `json:"title"`
 	BankCode      string `json:"bank_code"`
 	AccountName   string `json:"account_name"`
@@ -29,6 +39,13 @@ type BankAccountDTO struct {
 	IBAN          string `json:"iban"`
 	Currency      string `json:"currency"`
 	Password      string `json:"password"`
+	Pinned        bool   `json:"pinned"`
+	IsFavorite    bool   `json:"is_favorite"`
+	SortOrder     int    `json:"sort_order"`
+	FolderID      uint   `json:"folder_id"`
+	Tags          []uint `json:"tags"`
+	Reprompt      bool   `json:"reprompt"`
+	Canary        bool   `json:"canary"`
 }
 
 // ToBankAccount ...
@@ -41,6 +58,13 @@ func ToBankAccount(bankAccountDTO *BankAccountDTO) *BankAccount {
 		IBAN:          bankAccountDTO.IBAN,
 		Currency:      bankAccountDTO.Currency,
 		Password:      bankAccountDTO.Password,
+		Pinned:        bankAccountDTO.Pinned,
+		IsFavorite:    bankAccountDTO.IsFavorite,
+		SortOrder:     bankAccountDTO.SortOrder,
+		FolderID:      bankAccountDTO.FolderID,
+		Tags:          bankAccountDTO.Tags,
+		Reprompt:      bankAccountDTO.Reprompt,
+		Canary:        bankAccountDTO.Canary,
 	}
 }
 
@@ -48,6 +72,7 @@ func ToBankAccount(bankAccountDTO *BankAccountDTO) *BankAccount {
 func ToBankAccountDTO(bankAccount *BankAccount) *BankAccountDTO {
 	return &BankAccountDTO{
 		ID:            bankAccount.ID,
+		Revision:      bankAccount.Revision,
 		BankName:      bankAccount.BankName,
 		BankCode:      bankAccount.BankCode,
 		AccountName:   bankAccount.AccountName,
@@ -55,6 +80,13 @@ func ToBankAccountDTO(bankAccount *BankAccount) *BankAccountDTO {
 		IBAN:          bankAccount.IBAN,
 		Currency:      bankAccount.Currency,
 		Password:      bankAccount.Password,
+		Pinned:        bankAccount.Pinned,
+		IsFavorite:    bankAccount.IsFavorite,
+		SortOrder:     bankAccount.SortOrder,
+		FolderID:      bankAccount.FolderID,
+		Tags:          bankAccount.Tags,
+		Reprompt:      bankAccount.Reprompt,
+		Canary:        bankAccount.Canary,
 	}
 }
 
@@ -0,0 +1,6 @@
+package model
+
+// ConfigReloadDTO lists the settings a configuration reload changed
+type ConfigReloadDTO struct {
+	Changed []string `json:"changed"`
+}
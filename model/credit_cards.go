@@ -1,6 +1,7 @@
 package model
 
 import (
+	"strings"
 	"time"
 )
 
@@ -10,23 +11,43 @@ type CreditCard struct {
 	CreatedAt          time.Time  `json:"created_at"`
 	UpdatedAt          time.Time  `json:"updated_at"`
 	DeletedAt          *time.Time `json:"deleted_at"`
+	Revision           uint       `gorm:"not null;default:0" json:"revision"`
+	SyncRevision       int64      `gorm:"not null;default:0" json:"sync_revision"`
 	CardName           string     `json:"title"`
 	CardholderName     string     `json:"cardholder_name" encrypt:"true"`
 	Type               string     `json:"type" encrypt:"true"`
 	Number             string     `json:"number" encrypt:"true"`
 	VerificationNumber string     `json:"verification_number" encrypt:"true"`
 	ExpiryDate         string     `json:"expiry_date" encrypt:"true"`
+	Brand              string     `json:"brand" encrypt:"true"`
+	Pinned             bool       `json:"pinned"`
+	IsFavorite         bool       `json:"is_favorite"`
+	SortOrder          int        `json:"sort_order"`
+	FolderID           uint       `json:"folder_id"`
+	Tags               []uint     `gorm:"-" json:"tags"`
+	Reprompt           bool       `json:"reprompt"`
+	Canary             bool       `json:"canary"`
 }
 
 //CreditCardDTO DTO object for CreditCard type
 type CreditCardDTO struct {
 	ID                 uint   `json:"id"`
+	Revision           uint   `json:"revision"`
 	CardName           string `json:"title"`
 	CardholderName     string `json:"cardholder_name"`
 	Type               string `json:"type"`
 	Number             string `json:"number"`
 	VerificationNumber string `json:"verification_number"`
 	ExpiryDate         string `json:"expiry_date"`
+	Brand              string `json:"brand"`
+	MaskedNumber       string `json:"masked_number"`
+	Pinned             bool   `json:"pinned"`
+	IsFavorite         bool   `json:"is_favorite"`
+	SortOrder          int    `json:"sort_order"`
+	FolderID           uint   `json:"folder_id"`
+	Tags               []uint `json:"tags"`
+	Reprompt           bool   `json:"reprompt"`
+	Canary             bool   `json:"canary"`
 }
 
 // ToCreditCard ...
@@ -38,6 +59,14 @@ func ToCreditCard(creditCardDTO *CreditCardDTO) *CreditCard {
 		Number:             creditCardDTO.Number,
 		VerificationNumber: creditCardDTO.VerificationNumber,
 		ExpiryDate:         creditCardDTO.ExpiryDate,
+		Brand:              creditCardDTO.Brand,
+		Pinned:             creditCardDTO.Pinned,
+		IsFavorite:         creditCardDTO.IsFavorite,
+		SortOrder:          creditCardDTO.SortOrder,
+		FolderID:           creditCardDTO.FolderID,
+		Tags:               creditCardDTO.Tags,
+		Reprompt:           creditCardDTO.Reprompt,
+		Canary:             creditCardDTO.Canary,
 	}
 }
 
@@ -45,12 +74,22 @@ func ToCreditCard(creditCardDTO *CreditCardDTO) *CreditCard {
 func ToCreditCardDTO(creditCard *CreditCard) *CreditCardDTO {
 	return &CreditCardDTO{
 		ID:                 creditCard.ID,
+		Revision:           creditCard.Revision,
 		CardName:           creditCard.CardName,
 		CardholderName:     creditCard.CardholderName,
 		Type:               creditCard.Type,
 		Number:             creditCard.Number,
 		VerificationNumber: creditCard.VerificationNumber,
 		ExpiryDate:         creditCard.ExpiryDate,
+		Brand:              creditCard.Brand,
+		MaskedNumber:       MaskCardNumber(creditCard.Number),
+		Pinned:             creditCard.Pinned,
+		IsFavorite:         creditCard.IsFavorite,
+		SortOrder:          creditCard.SortOrder,
+		FolderID:           creditCard.FolderID,
+		Tags:               creditCard.Tags,
+		Reprompt:           creditCard.Reprompt,
+		Canary:             creditCard.Canary,
 	}
 }
 
@@ -65,6 +104,23 @@ func ToCreditCardDTOs(creditCards []*CreditCard) []*CreditCardDTO {
 	return creditCardDTOs
 }
 
+// MaskCardNumber hides all digits of the card number except the last four
+// e.g. 4111 1111 1111 1234 -> ************1234
+func MaskCardNumber(number string) string {
+	digits := []rune{}
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			digits = append(digits, r)
+		}
+	}
+
+	if len(digits) <= 4 {
+		return string(digits)
+	}
+
+	return strings.Repeat("*", len(digits)-4) + string(digits[len(digits)-4:])
+}
+
 /* EXAMPLE JSON OBJECT
 {
 	"card_name":"Bank Bonus",
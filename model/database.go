@@ -0,0 +1,40 @@
+package model
+
+import (
+	"database/sql"
+	"sort"
+	"time"
+)
+
+// DatabasePoolDTO is the utilization of a connection pool of the database
+type DatabasePoolDTO struct {
+	Name              string  `json:"name"`
+	MaxOpen           int     `json:"max_open"`
+	Open              int     `json:"open"`
+	InUse             int     `json:"in_use"`
+	Idle              int     `json:"idle"`
+	WaitCount         int64   `json:"wait_count"`
+	WaitDurationMS    float64 `json:"wait_duration_ms"`
+	MaxIdleClosed     int64   `json:"max_idle_closed"`
+	MaxLifetimeClosed int64   `json:"max_lifetime_closed"`
+}
+
+// ToDatabasePoolDTOs returns the pools sorted by name
+func ToDatabasePoolDTOs(stats map[string]sql.DBStats) []*DatabasePoolDTO {
+	pools := make([]*DatabasePoolDTO, 0, len(stats))
+	for name, s := range stats {
+		pools = append(pools, &DatabasePoolDTO{
+			Name:              name,
+			MaxOpen:           s.MaxOpenConnections,
+			Open:              s.OpenConnections,
+			InUse:             s.InUse,
+			Idle:              s.Idle,
+			WaitCount:         s.WaitCount,
+			WaitDurationMS:    float64(s.WaitDuration) / float64(time.Millisecond),
+			MaxIdleClosed:     s.MaxIdleClosed,
+			MaxLifetimeClosed: s.MaxLifetimeClosed,
+		})
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+	return pools
+}
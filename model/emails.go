@@ -6,39 +6,72 @@ import (
 
 // Email ...
 type Email struct {
-	ID        uint       `gorm:"primary_key" json:"id"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	DeletedAt *time.Time `json:"deleted_at"`
-	Title     string     `json:"title"`
-	Email     string     `json:"email" encrypt:"true"`
-	Password  string     `json:"password" encrypt:"true"`
+	ID           uint       `gorm:"primary_key" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at"`
+	Revision     uint       `gorm:"not null;default:0" json:"revision"`
+	SyncRevision int64      `gorm:"not null;default:0" json:"sync_revision"`
+	Title        string     `json:"title"`
+	Email        string     `json:"email" encrypt:"true"`
+	EmailIndex   string     `gorm:"type:text" json:"-" blind:"Email"`
+	Password     string     `json:"password" encrypt:"true"`
+	Pinned       bool       `json:"pinned"`
+	IsFavorite   bool       `json:"is_favorite"`
+	SortOrder    int        `json:"sort_order"`
+	FolderID     uint       `json:"folder_id"`
+	Tags         []uint     `gorm:"-" json:"tags"`
+	Reprompt     bool       `json:"reprompt"`
+	Canary       bool       `json:"canary"`
 }
 
 // EmailDTO ...
 type EmailDTO struct {
-	ID       uint   `json:"id"`
-	Title    string `json:"title"`
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	ID         uint   `json:"id"`
+	Revision   uint   `json:"revision"`
+	Title      string `json:"title"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	Pinned     bool   `json:"pinned"`
+	IsFavorite bool   `json:"is_favorite"`
+	SortOrder  int    `json:"sort_order"`
+	FolderID   uint   `json:"folder_id"`
+	Tags       []uint `json:"tags"`
+	Reprompt   bool   `json:"reprompt"`
+	Canary     bool   `json:"canary"`
 }
 
 // ToEmail ...
 func ToEmail(emailDTO *EmailDTO) *Email {
 	return &Email{
-		Title:    emailDTO.Title,
-		Email:    emailDTO.Email,
-		Password: emailDTO.Password,
+		Title:      emailDTO.Title,
+		Email:      emailDTO.Email,
+		Password:   emailDTO.Password,
+		Pinned:     emailDTO.Pinned,
+		IsFavorite: emailDTO.IsFavorite,
+		SortOrder:  emailDTO.SortOrder,
+		FolderID:   emailDTO.FolderID,
+		Tags:       emailDTO.Tags,
+		Reprompt:   emailDTO.Reprompt,
+		Canary:     emailDTO.Canary,
 	}
 }
 
 // ToEmailDTO ...
 func ToEmailDTO(email *Email) *EmailDTO {
 	return &EmailDTO{
-		ID:       email.ID,
-		Title:    email.Title,
-		Email:    email.Email,
-		Password: email.Password,
+		ID:         email.ID,
+		Revision:   email.Revision,
+		Title:      email.Title,
+		Email:      email.Email,
+		Password:   email.Password,
+		Pinned:     email.Pinned,
+		IsFavorite: email.IsFavorite,
+		SortOrder:  email.SortOrder,
+		FolderID:   email.FolderID,
+		Tags:       email.Tags,
+		Reprompt:   email.Reprompt,
+		Canary:     email.Canary,
 	}
 }
 
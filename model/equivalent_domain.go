@@ -0,0 +1,68 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// EquivalentDomain is a set of domains which are treated as the same site on autofill
+type EquivalentDomain struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+	Domains   string     `json:"domains"`
+}
+
+// EquivalentDomainDTO ...
+type EquivalentDomainDTO struct {
+	ID      uint     `json:"id"`
+	Domains []string `json:"domains" validate:"required,min=2,dive,required,hostname"`
+}
+
+// EquivalentDomainsDTO lists the equivalent domain sets by their source
+type EquivalentDomainsDTO struct {
+	Defaults [][]string             `json:"defaults"`
+	Server   []*EquivalentDomainDTO `json:"server"`
+	User     []*EquivalentDomainDTO `json:"user"`
+}
+
+// ToEquivalentDomain ...
+func ToEquivalentDomain(dto *EquivalentDomainDTO) *EquivalentDomain {
+	return &EquivalentDomain{
+		Domains: strings.Join(dto.Domains, ","),
+	}
+}
+
+// ToEquivalentDomainDTO ...
+func ToEquivalentDomainDTO(equivalentDomain *EquivalentDomain) *EquivalentDomainDTO {
+	return &EquivalentDomainDTO{
+		ID:      equivalentDomain.ID,
+		Domains: equivalentDomain.DomainList(),
+	}
+}
+
+// ToEquivalentDomainDTOs ...
+func ToEquivalentDomainDTOs(equivalentDomains []EquivalentDomain) []*EquivalentDomainDTO {
+	equivalentDomainDTOs := make([]*EquivalentDomainDTO, len(equivalentDomains))
+
+	for i := range equivalentDomains {
+		equivalentDomainDTOs[i] = ToEquivalentDomainDTO(&equivalentDomains[i])
+	}
+
+	return equivalentDomainDTOs
+}
+
+// DomainList returns the domains of the set as a slice
+func (e *EquivalentDomain) DomainList() []string {
+	if e.Domains == "" {
+		return []string{}
+	}
+	return strings.Split(e.Domains, ",")
+}
+
+/* EXAMPLE JSON OBJECT
+{
+	"domains": ["amazon.com", "amazon.de", "amazon.co.uk"]
+}
+*/
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// Statuses of an export job
+const (
+	ExportQueued  = "queued"
+	ExportRunning = "running"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+// ExportJob builds the encrypted archive of a vault in the background.
+// The archive is kept in the blob store until ExpiresAt.
+type ExportJob struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	UUID      uuid.UUID  `gorm:"type:uuid; type:varchar(100);unique_index"`
+	UserID    uint       `gorm:"index" json:"user_id"`
+	Schema    string     `json:"schema"`
+	Status    string     `json:"status"`
+	Progress  int        `json:"progress"` // percent
+	Items     int        `json:"items"`
+	Error     string     `json:"error"`
+	BlobKey   string     `json:"blob_key"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// ExportRequestDTO starts an export, the archive is encrypted with the passphrase
+type ExportRequestDTO struct {
+	Passphrase string `validate:"required,min=8" json:"passphrase"`
+}
+
+// ExportJobDTO is the state of an export job, DownloadURL is set when it is done
+type ExportJobDTO struct {
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Progress    int        `json:"progress"`
+	Items       int        `json:"items"`
+	Error       string     `json:"error,omitempty"`
+	DownloadURL string     `json:"download_url,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// ExportArchive is the content of an export archive, items are keyed by item type like "logins".
+// Items refer to the folders and tags of the archive by their folder_id and tags, the
+// password history refers to the logins of the archive by its login_id.
+type ExportArchive struct {
+	Version         int                    `json:"version"`
+	CreatedAt       time.Time              `json:"created_at"`
+	Folders         []*FolderDTO           `json:"folders"`
+	Tags            []*TagDTO              `json:"tags"`
+	Items           map[string]interface{} `json:"items"`
+	PasswordHistory []*PasswordHistoryDTO  `json:"password_history"`
+}
+
+// ToExportJobDTO ...
+func ToExportJobDTO(job *ExportJob) *ExportJobDTO {
+	return &ExportJobDTO{
+		ID:        job.UUID.String(),
+		Status:    job.Status,
+		Progress:  job.Progress,
+		Items:     job.Items,
+		Error:     job.Error,
+		ExpiresAt: job.ExpiresAt,
+		CreatedAt: job.CreatedAt,
+	}
+}
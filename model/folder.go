@@ -0,0 +1,77 @@
+package model
+
+import (
+	"time"
+)
+
+// Folder groups the items of all types of a vault, items have its id as folder_id
+type Folder struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+	Name      string     `json:"name" encrypt:"true"`
+}
+
+// FolderDTO ...
+type FolderDTO struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name" validate:"required,max=255"`
+}
+
+// ItemMoveDTO moves the items to the folder of FolderID, 0 is no folder
+type ItemMoveDTO struct {
+	FolderID uint         `json:"folder_id"`
+	Items    []ItemRefDTO `json:"items" validate:"required,min=1,max=1000,dive"`
+}
+
+// ItemCloneDTO is the optional body of a clone, a nil FolderID keeps the folder of the item
+// and 0 is no folder
+type ItemCloneDTO struct {
+	FolderID *uint `json:"folder_id"`
+}
+
+// ItemRefDTO refers to an item of any type
+type ItemRefDTO struct {
+	Type string `json:"type" validate:"required,oneof=logins credit-cards bank-accounts notes emails servers"`
+	ID   uint   `json:"id" validate:"required"`
+}
+
+// ToFolder ...
+func ToFolder(folderDTO *FolderDTO) *Folder {
+	return &Folder{
+		Name: folderDTO.Name,
+	}
+}
+
+// ToFolderDTO ...
+func ToFolderDTO(folder *Folder) *FolderDTO {
+	return &FolderDTO{
+		ID:   folder.ID,
+		Name: folder.Name,
+	}
+}
+
+// ToFolderDTOs ...
+func ToFolderDTOs(folders []Folder) []*FolderDTO {
+	folderDTOs := make([]*FolderDTO, len(folders))
+
+	for i := range folders {
+		folderDTOs[i] = ToFolderDTO(&folders[i])
+	}
+
+	return folderDTOs
+}
+
+/* EXAMPLE JSON OBJECT
+{
+	"name": "Work"
+}
+*/
+
+/* EXAMPLE JSON OBJECT
+{
+	"folder_id": 2,
+	"items": [{"type": "logins", "id": 3}, {"type": "notes", "id": 7}]
+}
+*/
@@ -0,0 +1,60 @@
+package model
+
+// ImportFileDTO is the content of an export file of another password manager, Password
+// opens exports which are encrypted files like KeePass databases. Mapping names the columns
+// of the generic CSV import. A dry run reports what the import would create without
+// creating it.
+type ImportFileDTO struct {
+	Content  string            `json:"content" validate:"required"`
+	Password string            `json:"password,omitempty"`
+	Mapping  *ImportMappingDTO `json:"mapping,omitempty"`
+	DryRun   bool              `json:"dry_run,omitempty"`
+}
+
+// ImportMappingDTO names the columns of a CSV which have the fields of the items, Type is
+// the column of the item type of a row like "login" or "note"
+type ImportMappingDTO struct {
+	Title    string `json:"title"`
+	URL      string `json:"url"`
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Notes    string `json:"notes"`
+	Type     string `json:"type"`
+	Folder   string `json:"folder"`
+}
+
+// ImportReportDTO is the summary of an import, the count of the imported items by item
+// type, the count of the folders it created and the entries it skipped with the reason.
+// The report of a dry run has the items the import would create.
+type ImportReportDTO struct {
+	DryRun   bool               `json:"dry_run"`
+	Imported map[string]int     `json:"imported"`
+	Folders  int                `json:"folders"`
+	Skipped  []ImportSkippedDTO `json:"skipped"`
+	Items    []ImportItemDTO    `json:"items,omitempty"`
+}
+
+// ImportItemDTO is an item a dry run would create, Item is its DTO like a LoginDTO
+type ImportItemDTO struct {
+	Entry  int         `json:"entry"`
+	Type   string      `json:"type"`
+	Folder string      `json:"folder"`
+	Item   interface{} `json:"item"`
+}
+
+// ImportSkippedDTO is an entry of an export file which wasn't imported, Entry is its
+// position in the file starting at 1
+type ImportSkippedDTO struct {
+	Entry  int    `json:"entry"`
+	Title  string `json:"title"`
+	Reason string `json:"reason"`
+}
+
+/* EXAMPLE JSON OBJECT
+{
+	"dry_run": false,
+	"imported": {"logins": 12, "notes": 2, "credit-cards": 1},
+	"folders": 3,
+	"skipped": [{"entry": 7, "title": "Passport", "reason": "identity items can't be imported"}]
+}
+*/
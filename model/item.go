@@ -0,0 +1,97 @@
+package model
+
+import "time"
+
+// ItemOrderDTO is the pin state and manual position of an item
+type ItemOrderDTO struct {
+	ID        uint `json:"id"`
+	Pinned    bool `json:"pinned"`
+	SortOrder int  `json:"sort_order"`
+}
+
+/* EXAMPLE JSON OBJECT
+[
+	{"id": 3, "pinned": true, "sort_order": 0},
+	{"id": 1, "pinned": false, "sort_order": 1}
+]
+*/
+
+// TrashItemDTO is a deleted item of the trash, it can be restored until it's purged
+type TrashItemDTO struct {
+	Type      string      `json:"type"`
+	DeletedAt time.Time   `json:"deleted_at"`
+	Item      interface{} `json:"item"`
+}
+
+// TypedItemDTO is an item of a list of items of all types, like the favorites
+type TypedItemDTO struct {
+	Type string      `json:"type"`
+	Item interface{} `json:"item"`
+}
+
+// SearchResultDTO is a page of the items of all types matching a search
+type SearchResultDTO struct {
+	Total int            `json:"total"`
+	Items []TypedItemDTO `json:"items"`
+}
+
+// SyncDTO is the changes of the vault since the sync token of the previous sync. Token is
+// the one of this sync, the next sync asks for the changes since it. Reset is true when
+// the token was of a vault which was reset or purged since, Changed has all items then
+// and clients replace the items they have with them.
+type SyncDTO struct {
+	Token string `json:"token"`
+	Reset bool   `json:"reset"`
+	// Deprecated: Revision is for the clients which still sync with ?since=, use Token.
+	// It's removed in the next release.
+	Revision int64          `json:"revision"`
+	Changed  []TypedItemDTO `json:"changed"`
+	Deleted  []ItemRefDTO   `json:"deleted"`
+}
+
+// ChangeDTO is a change of an item pushed to the sessions of the vault. ID is 0 when
+// several items of the type changed at once, clients sync then.
+type ChangeDTO struct {
+	Type      string `json:"type"`
+	ID        uint   `json:"id"`
+	Operation string `json:"operation"`
+}
+
+// ConflictDTO is an update of an item which was edited at a revision the item has left.
+// Current is the item as it is now and Proposed the rejected update. Base is the item at
+// the edited revision while its version is kept, so clients can merge the three.
+type ConflictDTO struct {
+	Type         string      `json:"type"`
+	ID           uint        `json:"id"`
+	BaseRevision uint        `json:"base_revision"`
+	Base         interface{} `json:"base,omitempty"`
+	Current      interface{} `json:"current"`
+	Proposed     interface{} `json:"proposed"`
+}
+
+// BatchResultDTO is the result of an item of a batch create, the created item or the
+// reason it wasn't created
+type BatchResultDTO struct {
+	Index int         `json:"index"`
+	Item  interface{} `json:"item,omitempty"`
+	Error string      `json:"error,omitempty"`
+}
+
+// BatchDeleteDTO is the summary of a batch delete, the ids of the deleted items and the
+// ids which weren't deleted with the reason
+type BatchDeleteDTO struct {
+	Deleted []uint            `json:"deleted"`
+	Failed  []BatchFailureDTO `json:"failed"`
+}
+
+// BatchFailureDTO is an id of a batch which wasn't done
+type BatchFailureDTO struct {
+	ID    uint   `json:"id"`
+	Error string `json:"error"`
+}
+
+/* EXAMPLE JSON OBJECT
+[
+	{"type": "logins", "deleted_at": "2020-06-01T12:00:00Z", "item": {"id": 3, "title": "GitHub", ...}}
+]
+*/
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"time"
+)
+
+// ItemVersion is a snapshot of an item before it was updated, Data is the item as JSON
+type ItemVersion struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	ItemType  string    `json:"item_type"`
+	ItemID    uint      `json:"item_id"`
+	Data      string    `gorm:"type:text" json:"data" encrypt:"true"`
+}
+
+// ItemVersionDTO is a version of an item, Item is the DTO of its type
+type ItemVersionDTO struct {
+	ID        uint        `json:"id"`
+	ItemType  string      `json:"item_type"`
+	ItemID    uint        `json:"item_id"`
+	CreatedAt time.Time   `json:"created_at"`
+	Item      interface{} `json:"item"`
+}
+
+/* EXAMPLE JSON OBJECT
+[
+	{"id": 7, "item_type": "logins", "item_id": 3, "created_at": "2020-06-01T12:00:00Z", "item": {"id": 3, "title": "GitHub", ...}}
+]
+*/
@@ -0,0 +1,25 @@
+package model
+
+// K8sSecretListDTO is the rendered set of Kubernetes Secrets of a machine account.
+// Version changes whenever one of the secrets changes.
+type K8sSecretListDTO struct {
+	Version string          `json:"version"`
+	Secrets []*K8sSecretDTO `json:"secrets"`
+}
+
+// K8sSecretDTO is a Kubernetes Secret manifest which can be applied as it is
+type K8sSecretDTO struct {
+	APIVersion string            `json:"apiVersion"`
+	Kind       string            `json:"kind"`
+	Metadata   K8sObjectMetaDTO  `json:"metadata"`
+	Type       string            `json:"type"`
+	Data       map[string][]byte `json:"data"`
+}
+
+// K8sObjectMetaDTO is the metadata of a Kubernetes object
+type K8sObjectMetaDTO struct {
+	Name        string            `json:"name"`
+	Namespace   string            `json:"namespace,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
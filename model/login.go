@@ -5,48 +5,104 @@ import (
 )
 
 // Login ...
+// AutoTypeSequence and AutoTypeWindow are KeePass compatible auto-type settings
+// e.g. "{USERNAME}{TAB}{PASSWORD}{ENTER}" and "*Mozilla Firefox*"
+// RotationProvider rotates the credential every RotationPeriod e.g. "30d", "12h".
+// An empty period means the credential is only rotated on demand.
 type Login struct {
-	ID        uint       `gorm:"primary_key" json:"id"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	DeletedAt *time.Time `json:"deleted_at"`
-	Title     string     `json:"title"`
-	URL       string     `json:"url"`
-	Username  string     `json:"username" encrypt:"true"`
-	Password  string     `json:"password" encrypt:"true"`
-	Extra     string     `json:"extra" encrypt:"true"`
+	ID               uint       `gorm:"primary_key" json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	DeletedAt        *time.Time `json:"deleted_at"`
+	Revision         uint       `gorm:"not null;default:0" json:"revision"`
+	SyncRevision     int64      `gorm:"not null;default:0" json:"sync_revision"`
+	Title            string     `json:"title"`
+	URL              string     `json:"url"`
+	Username         string     `json:"username" encrypt:"true"`
+	UsernameIndex    string     `gorm:"type:text" json:"-" blind:"Username"`
+	Password         string     `json:"password" encrypt:"true"`
+	Extra            string     `json:"extra" encrypt:"true"`
+	AutoTypeSequence string     `json:"auto_type_sequence" encrypt:"true"`
+	AutoTypeWindow   string     `json:"auto_type_window" encrypt:"true"`
+	Pinned           bool       `json:"pinned"`
+	IsFavorite       bool       `json:"is_favorite"`
+	SortOrder        int        `json:"sort_order"`
+	FolderID         uint       `json:"folder_id"`
+	Tags             []uint     `gorm:"-" json:"tags"`
+	Reprompt         bool       `json:"reprompt"`
+	Canary           bool       `json:"canary"`
+	RotationProvider string     `json:"rotation_provider"`
+	RotationPeriod   string     `json:"rotation_period"`
+	RotatedAt        *time.Time `json:"rotated_at"`
 }
 
 //LoginDTO DTO object for Login type
 type LoginDTO struct {
-	ID       uint   `json:"id"`
-	Title    string `json:"title"`
-	URL      string `json:"url"`
-	Username string `json:"username"`
-	Password string `json:"password"`
-	Extra    string `json:"extra"`
+	ID               uint       `json:"id"`
+	Revision         uint       `json:"revision"`
+	Title            string     `json:"title"`
+	URL              string     `json:"url"`
+	Username         string     `json:"username"`
+	Password         string     `json:"password"`
+	Extra            string     `json:"extra"`
+	AutoTypeSequence string     `json:"auto_type_sequence"`
+	AutoTypeWindow   string     `json:"auto_type_window"`
+	Pinned           bool       `json:"pinned"`
+	IsFavorite       bool       `json:"is_favorite"`
+	SortOrder        int        `json:"sort_order"`
+	FolderID         uint       `json:"folder_id"`
+	Tags             []uint     `json:"tags"`
+	Reprompt         bool       `json:"reprompt"`
+	Canary           bool       `json:"canary"`
+	RotationProvider string     `json:"rotation_provider"`
+	RotationPeriod   string     `json:"rotation_period"`
+	RotatedAt        *time.Time `json:"rotated_at"`
 }
 
 // ToLogin ...
 func ToLogin(loginDTO *LoginDTO) *Login {
 	return &Login{
-		Title:    loginDTO.Title,
-		URL:      loginDTO.URL,
-		Username: loginDTO.Username,
-		Password: loginDTO.Password,
-		Extra:    loginDTO.Extra,
+		Title:            loginDTO.Title,
+		URL:              loginDTO.URL,
+		Username:         loginDTO.Username,
+		Password:         loginDTO.Password,
+		Extra:            loginDTO.Extra,
+		AutoTypeSequence: loginDTO.AutoTypeSequence,
+		AutoTypeWindow:   loginDTO.AutoTypeWindow,
+		Pinned:           loginDTO.Pinned,
+		IsFavorite:       loginDTO.IsFavorite,
+		SortOrder:        loginDTO.SortOrder,
+		FolderID:         loginDTO.FolderID,
+		Tags:             loginDTO.Tags,
+		Reprompt:         loginDTO.Reprompt,
+		Canary:           loginDTO.Canary,
+		RotationProvider: loginDTO.RotationProvider,
+		RotationPeriod:   loginDTO.RotationPeriod,
 	}
 }
 
 // ToLoginDTO ...
 func ToLoginDTO(login *Login) *LoginDTO {
 	return &LoginDTO{
-		ID:       login.ID,
-		Title:    login.Title,
-		URL:      login.URL,
-		Username: login.Username,
-		Password: login.Password,
-		Extra:    login.Extra,
+		ID:               login.ID,
+		Revision:         login.Revision,
+		Title:            login.Title,
+		URL:              login.URL,
+		Username:         login.Username,
+		Password:         login.Password,
+		Extra:            login.Extra,
+		AutoTypeSequence: login.AutoTypeSequence,
+		AutoTypeWindow:   login.AutoTypeWindow,
+		Pinned:           login.Pinned,
+		IsFavorite:       login.IsFavorite,
+		SortOrder:        login.SortOrder,
+		FolderID:         login.FolderID,
+		Tags:             login.Tags,
+		Reprompt:         login.Reprompt,
+		Canary:           login.Canary,
+		RotationProvider: login.RotationProvider,
+		RotationPeriod:   login.RotationPeriod,
+		RotatedAt:        login.RotatedAt,
 	}
 }
 
@@ -83,6 +139,8 @@ type Password struct {
 	"URL":"http://dummywebsite.com",
 	"Username": "dummyuser",
 	"Password": "dummypassword"
-	"Extra": "additional information"
+	"Extra": "additional information",
+	"auto_type_sequence": "{USERNAME}{TAB}{PASSWORD}{ENTER}",
+	"auto_type_window": "*Dummy Website*"
 }
 */
@@ -0,0 +1,97 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	uuid "github.com/satori/go.uuid"
+)
+
+// MachineFolderScope is the type of the folder scopes of machine accounts, "folders/7" reads
+// the items in the folder
+const MachineFolderScope = "folders"
+
+// MachineAccount is a non-interactive account of a user, e.g. for CI pipelines.
+// It can only read the items listed in Items like "logins/3,notes/5,folders/7", the items
+// of folders are the ones in the folder at the time of the read.
+type MachineAccount struct {
+	ID         uint       `gorm:"primary_key" json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	DeletedAt  *time.Time `json:"deleted_at"`
+	UUID       uuid.UUID  `gorm:"type:uuid; type:varchar(100);"`
+	UserID     uint       `json:"user_id"`
+	Name       string     `json:"name"`
+	Secret     string     `json:"-"` // bcrypt hash of the client secret
+	Items      string     `json:"items"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// MachineAccountDTO is the machine account as seen by its user.
+// Secret is only set in the response of the creation.
+type MachineAccountDTO struct {
+	ID         uint       `json:"id"`
+	ClientID   string     `json:"client_id"`
+	Secret     string     `json:"client_secret,omitempty"`
+	Name       string     `json:"name" validate:"required,max=100"`
+	Items      []string   `json:"items" validate:"required,min=1"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// MachineTokenRequestDTO is the credential of a machine account
+type MachineTokenRequestDTO struct {
+	ClientID string `json:"client_id" validate:"required"`
+	Secret   string `json:"client_secret" validate:"required"`
+}
+
+// MachineTokenDTO is the short-lived access token of a machine account
+type MachineTokenDTO struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
+// ToMachineAccountDTO ...
+func ToMachineAccountDTO(account *MachineAccount) *MachineAccountDTO {
+	return &MachineAccountDTO{
+		ID:         account.ID,
+		ClientID:   account.UUID.String(),
+		Name:       account.Name,
+		Items:      account.ItemList(),
+		CreatedAt:  account.CreatedAt,
+		LastUsedAt: account.LastUsedAt,
+	}
+}
+
+// ToMachineAccountDTOs ...
+func ToMachineAccountDTOs(accounts []MachineAccount) []*MachineAccountDTO {
+	accountDTOs := make([]*MachineAccountDTO, len(accounts))
+
+	for i := range accounts {
+		accountDTOs[i] = ToMachineAccountDTO(&accounts[i])
+	}
+
+	return accountDTOs
+}
+
+// ItemList returns the items the machine account can read
+func (m *MachineAccount) ItemList() []string {
+	if m.Items == "" {
+		return []string{}
+	}
+	return strings.Split(m.Items, ",")
+}
+
+// CanRead reports whether the machine account can read the item e.g. "logins/3" in the
+// folder, 0 is no folder
+func (m *MachineAccount) CanRead(item string, folderID uint) bool {
+	folder := fmt.Sprintf("%s/%d", MachineFolderScope, folderID)
+	for _, i := range m.ItemList() {
+		if i == item || (folderID != 0 && i == folder) {
+			return true
+		}
+	}
+	return false
+}
@@ -6,35 +6,68 @@ import (
 
 // Note ...
 type Note struct {
-	ID        uint       `gorm:"primary_key" json:"id"`
-	CreatedAt time.Time  `json:"created_at"`
-	UpdatedAt time.Time  `json:"updated_at"`
-	DeletedAt *time.Time `json:"deleted_at"`
-	Title     string     `json:"title"`
-	Note      string     `json:"note" encrypt:"true"`
+	ID           uint       `gorm:"primary_key" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at"`
+	Revision     uint       `gorm:"not null;default:0" json:"revision"`
+	SyncRevision int64      `gorm:"not null;default:0" json:"sync_revision"`
+	Title        string     `json:"title"`
+	Note         string     `json:"note" encrypt:"true"`
+	NoteIndex    string     `gorm:"type:text" json:"-" blind:"Note"`
+	Pinned       bool       `json:"pinned"`
+	IsFavorite   bool       `json:"is_favorite"`
+	SortOrder    int        `json:"sort_order"`
+	FolderID     uint       `json:"folder_id"`
+	Tags         []uint     `gorm:"-" json:"tags"`
+	Reprompt     bool       `json:"reprompt"`
+	Canary       bool       `json:"canary"`
 }
 
 // NoteDTO ...
 type NoteDTO struct {
-	ID    uint   `json:"id"`
-	Title string `json:"title"`
-	Note  string `json:"note"`
+	ID         uint   `json:"id"`
+	Revision   uint   `json:"revision"`
+	Title      string `json:"title"`
+	Note       string `json:"note"`
+	Pinned     bool   `json:"pinned"`
+	IsFavorite bool   `json:"is_favorite"`
+	SortOrder  int    `json:"sort_order"`
+	FolderID   uint   `json:"folder_id"`
+	Tags       []uint `json:"tags"`
+	Reprompt   bool   `json:"reprompt"`
+	Canary     bool   `json:"canary"`
 }
 
 // ToNote ...
 func ToNote(noteDTO *NoteDTO) *Note {
 	return &Note{
-		Title: noteDTO.Title,
-		Note:  noteDTO.Note,
+		Title:      noteDTO.Title,
+		Note:       noteDTO.Note,
+		Pinned:     noteDTO.Pinned,
+		IsFavorite: noteDTO.IsFavorite,
+		SortOrder:  noteDTO.SortOrder,
+		FolderID:   noteDTO.FolderID,
+		Tags:       noteDTO.Tags,
+		Reprompt:   noteDTO.Reprompt,
+		Canary:     noteDTO.Canary,
 	}
 }
 
 // ToNoteDTO ...
 func ToNoteDTO(note *Note) *NoteDTO {
 	return &NoteDTO{
-		ID:    note.ID,
-		Title: note.Title,
-		Note:  note.Note,
+		ID:         note.ID,
+		Revision:   note.Revision,
+		Title:      note.Title,
+		Note:       note.Note,
+		Pinned:     note.Pinned,
+		IsFavorite: note.IsFavorite,
+		SortOrder:  note.SortOrder,
+		FolderID:   note.FolderID,
+		Tags:       note.Tags,
+		Reprompt:   note.Reprompt,
+		Canary:     note.Canary,
 	}
 }
 
@@ -0,0 +1,44 @@
+package model
+
+import (
+	"time"
+)
+
+// PasswordHistory keeps the previous passwords of a login
+type PasswordHistory struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+	LoginID   uint       `json:"login_id"`
+	Password  string     `json:"password" encrypt:"true"`
+}
+
+// PasswordHistoryDTO ...
+type PasswordHistoryDTO struct {
+	ID        uint      `json:"id"`
+	LoginID   uint      `json:"login_id"`
+	Password  string    `json:"password"`
+	ChangedAt time.Time `json:"changed_at"`
+}
+
+// ToPasswordHistoryDTO ...
+func ToPasswordHistoryDTO(history *PasswordHistory) *PasswordHistoryDTO {
+	return &PasswordHistoryDTO{
+		ID:        history.ID,
+		LoginID:   history.LoginID,
+		Password:  history.Password,
+		ChangedAt: history.CreatedAt,
+	}
+}
+
+// ToPasswordHistoryDTOs ...
+func ToPasswordHistoryDTOs(histories []PasswordHistory) []*PasswordHistoryDTO {
+	historyDTOs := make([]*PasswordHistoryDTO, len(histories))
+
+	for i := range histories {
+		historyDTOs[i] = ToPasswordHistoryDTO(&histories[i])
+	}
+
+	return historyDTOs
+}
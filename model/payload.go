@@ -3,4 +3,6 @@ package model
 //Payload ...
 type Payload struct {
 	Data string `json:"data"`
+	// NextCursor is the cursor of the next page of a list paged by cursors, empty on the last page
+	NextCursor string `json:"next_cursor,omitempty"`
 }
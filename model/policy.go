@@ -0,0 +1,144 @@
+package model
+
+import (
+	"strings"
+	"time"
+)
+
+// Policy is a set of security rules of the server or of a user.
+// The server policy has UserID 0, a user policy can only tighten it.
+// Timeouts are periods like "30m", "12h" or "7d", empty means no limit.
+// Lists are stored comma separated like "CN,RU" or "10.0.0.0/8,192.168.1.0/24".
+type Policy struct {
+	ID                            uint       `gorm:"primary_key" json:"id"`
+	CreatedAt                     time.Time  `json:"created_at"`
+	UpdatedAt                     time.Time  `json:"updated_at"`
+	UserID                        uint       `gorm:"unique_index" json:"user_id"`
+	SessionIdleTimeout            string     `json:"session_idle_timeout"`
+	SessionAbsoluteTimeout        string     `json:"session_absolute_timeout"`
+	BlockedCountries              string     `json:"blocked_countries"`
+	OfficeCIDRs                   string     `json:"office_cidrs"`
+	RequireTwoFactorOutsideOffice bool       `json:"require_two_factor_outside_office"`
+	AccessHours                   string     `json:"access_hours"`
+	AccessDays                    string     `json:"access_days"`
+	TimeZone                      string     `json:"time_zone"`
+	RequireSecurityKey            bool       `json:"require_security_key"`
+	SecurityKeyGracePeriod        string     `json:"security_key_grace_period"`
+	SecurityKeyRequiredAt         time.Time  `json:"security_key_required_at"`  // when the requirement was turned on
+	SecurityKeyExemptUntil        *time.Time `json:"security_key_exempt_until"` // admin override of a user policy
+	SecurityKeyExemptReason       string     `json:"security_key_exempt_reason"`
+	BlockDisposableEmails         bool       `json:"block_disposable_emails"` // signup rule, server policy only
+	TrashRetention                string     `json:"trash_retention"`         // retention periods, server policy only
+	AuditRetention                string     `json:"audit_retention"`
+	TombstoneRetention            string     `json:"tombstone_retention"`
+	SessionRetention              string     `json:"session_retention"`
+	AttachmentMaxSize             int64      `json:"attachment_max_size"` // admin override of a user policy, bytes
+	AttachmentQuota               int64      `json:"attachment_quota"`
+}
+
+// PolicyDTO DTO object for Policy type
+type PolicyDTO struct {
+	SessionIdleTimeout            string   `json:"session_idle_timeout"`
+	SessionAbsoluteTimeout        string   `json:"session_absolute_timeout"`
+	BlockedCountries              []string `json:"blocked_countries"`
+	OfficeCIDRs                   []string `json:"office_cidrs"`
+	RequireTwoFactorOutsideOffice bool     `json:"require_two_factor_outside_office"`
+	AccessHours                   string   `json:"access_hours"` // e.g. 09:00-18:00
+	AccessDays                    []string `json:"access_days"`  // e.g. mon, tue
+	TimeZone                      string   `json:"time_zone"`    // e.g. Europe/Istanbul, UTC when empty
+	RequireSecurityKey            bool     `json:"require_security_key"`
+	SecurityKeyGracePeriod        string   `json:"security_key_grace_period"` // e.g. 14d to enroll a key
+	BlockDisposableEmails         bool     `json:"block_disposable_emails"`
+	TrashRetention                string   `json:"trash_retention"`     // e.g. 30d, deleted items are kept forever when empty
+	AuditRetention                string   `json:"audit_retention"`     // e.g. 365d
+	TombstoneRetention            string   `json:"tombstone_retention"` // deleted users, accounts, domains and password histories
+	SessionRetention              string   `json:"session_retention"`   // expired sessions
+
+	// Read only, set by the server
+	SecurityKeyDeadline    *time.Time `json:"security_key_deadline,omitempty"`
+	SecurityKeyExemptUntil *time.Time `json:"security_key_exempt_until,omitempty"`
+	AttachmentMaxSize      int64      `json:"attachment_max_size,omitempty"`
+	AttachmentQuota        int64      `json:"attachment_quota,omitempty"`
+}
+
+// SecurityKeyExemptionDTO is an admin override of the security key requirement of a user
+type SecurityKeyExemptionDTO struct {
+	Period string `json:"period" validate:"required"` // e.g. 7d
+	Reason string `json:"reason" validate:"required,max=255"`
+}
+
+// PoliciesDTO has the server and user policies with the session timeouts which apply to the user
+type PoliciesDTO struct {
+	Server    *PolicyDTO `json:"server"`
+	User      *PolicyDTO `json:"user"`
+	Effective *PolicyDTO `json:"effective"`
+}
+
+// ToPolicy ...
+func ToPolicy(dto *PolicyDTO, policy *Policy) *Policy {
+	policy.SessionIdleTimeout = dto.SessionIdleTimeout
+	policy.SessionAbsoluteTimeout = dto.SessionAbsoluteTimeout
+	policy.BlockedCountries = strings.ToUpper(strings.Join(dto.BlockedCountries, ","))
+	policy.OfficeCIDRs = strings.Join(dto.OfficeCIDRs, ",")
+	policy.RequireTwoFactorOutsideOffice = dto.RequireTwoFactorOutsideOffice
+	policy.AccessHours = dto.AccessHours
+	policy.AccessDays = strings.ToLower(strings.Join(dto.AccessDays, ","))
+	policy.TimeZone = dto.TimeZone
+	if dto.RequireSecurityKey && !policy.RequireSecurityKey {
+		policy.SecurityKeyRequiredAt = time.Now()
+	}
+	policy.RequireSecurityKey = dto.RequireSecurityKey
+	policy.SecurityKeyGracePeriod = dto.SecurityKeyGracePeriod
+	policy.BlockDisposableEmails = dto.BlockDisposableEmails
+	policy.TrashRetention = dto.TrashRetention
+	policy.AuditRetention = dto.AuditRetention
+	policy.TombstoneRetention = dto.TombstoneRetention
+	policy.SessionRetention = dto.SessionRetention
+	return policy
+}
+
+// ToPolicyDTO ...
+func ToPolicyDTO(policy *Policy) *PolicyDTO {
+	return &PolicyDTO{
+		SessionIdleTimeout:            policy.SessionIdleTimeout,
+		SessionAbsoluteTimeout:        policy.SessionAbsoluteTimeout,
+		BlockedCountries:              splitList(policy.BlockedCountries),
+		OfficeCIDRs:                   splitList(policy.OfficeCIDRs),
+		RequireTwoFactorOutsideOffice: policy.RequireTwoFactorOutsideOffice,
+		AccessHours:                   policy.AccessHours,
+		AccessDays:                    splitList(policy.AccessDays),
+		TimeZone:                      policy.TimeZone,
+		RequireSecurityKey:            policy.RequireSecurityKey,
+		SecurityKeyGracePeriod:        policy.SecurityKeyGracePeriod,
+		SecurityKeyExemptUntil:        policy.SecurityKeyExemptUntil,
+		BlockDisposableEmails:         policy.BlockDisposableEmails,
+		TrashRetention:                policy.TrashRetention,
+		AuditRetention:                policy.AuditRetention,
+		TombstoneRetention:            policy.TombstoneRetention,
+		SessionRetention:              policy.SessionRetention,
+		AttachmentMaxSize:             policy.AttachmentMaxSize,
+		AttachmentQuota:               policy.AttachmentQuota,
+	}
+}
+
+// Countries returns the blocked country codes
+func (p *Policy) Countries() []string {
+	return splitList(p.BlockedCountries)
+}
+
+// CIDRs returns the office networks
+func (p *Policy) CIDRs() []string {
+	return splitList(p.OfficeCIDRs)
+}
+
+// Days returns the week days of access like "mon"
+func (p *Policy) Days() []string {
+	return splitList(p.AccessDays)
+}
+
+func splitList(list string) []string {
+	if list == "" {
+		return []string{}
+	}
+	return strings.Split(list, ",")
+}
@@ -0,0 +1,104 @@
+package model
+
+import "time"
+
+// Reasons of a re-encryption job
+const (
+	ReencryptKeyRotation    = "key_rotation"
+	ReencryptMasterPassword = "master_password"
+	ReencryptCipherUpgrade  = "cipher_upgrade"
+	ReencryptBlindIndex     = "blind_index"
+)
+
+// Statuses of a re-encryption job
+const (
+	ReencryptionQueued  = "queued"
+	ReencryptionRunning = "running"
+	ReencryptionDone    = "done"
+	ReencryptionFailed  = "failed"
+)
+
+// ReencryptionJob re-encrypts the vaults with the current server passphrase and cipher.
+// Schema, Table and LastID are the cursor of the job, it resumes from them after a restart.
+type ReencryptionJob struct {
+	ID          uint       `gorm:"primary_key" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+	Reason      string     `json:"reason"`
+	UserID      uint       `gorm:"index" json:"user_id"` // 0 re-encrypts all vaults
+	Status      string     `gorm:"index" json:"status"`
+	Schema      string     `json:"schema"`
+	Table       string     `json:"table"`
+	LastID      uint       `json:"last_id"`
+	Total       int        `json:"total"`       // rows of the vaults when the job started
+	Done        int        `json:"done"`        // rows which are checked
+	Reencrypted int        `json:"reencrypted"` // rows which are written again
+	Error       string     `json:"error"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+// ReencryptionRequestDTO starts a re-encryption job of all vaults
+type ReencryptionRequestDTO struct {
+	Reason string `validate:"required,oneof=key_rotation cipher_upgrade blind_index" json:"reason"`
+}
+
+// KeyRotationDTO is the new server passphrase of a key rotation
+type KeyRotationDTO struct {
+	Passphrase string `validate:"required" json:"passphrase"`
+}
+
+// ReencryptionJobDTO is the progress of a re-encryption job, ETA is set while it runs
+type ReencryptionJobDTO struct {
+	ID          uint       `json:"id"`
+	Reason      string     `json:"reason"`
+	UserID      uint       `json:"user_id,omitempty"`
+	Status      string     `json:"status"`
+	Schema      string     `json:"schema,omitempty"`
+	Table       string     `json:"table,omitempty"`
+	Total       int        `json:"total"`
+	Done        int        `json:"done"`
+	Reencrypted int        `json:"reencrypted"`
+	Progress    int        `json:"progress"` // percent
+	ETA         *time.Time `json:"eta,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// ReencryptionStatusDTO lists the unfinished jobs with the recently finished ones, newest first
+type ReencryptionStatusDTO struct {
+	Running bool                 `json:"running"`
+	Cipher  string               `json:"cipher"`
+	Jobs    []ReencryptionJobDTO `json:"jobs"`
+}
+
+// ToReencryptionJobDTO ...
+func ToReencryptionJobDTO(job *ReencryptionJob) *ReencryptionJobDTO {
+	dto := &ReencryptionJobDTO{
+		ID:          job.ID,
+		Reason:      job.Reason,
+		UserID:      job.UserID,
+		Status:      job.Status,
+		Schema:      job.Schema,
+		Table:       job.Table,
+		Total:       job.Total,
+		Done:        job.Done,
+		Reencrypted: job.Reencrypted,
+		Error:       job.Error,
+		CreatedAt:   job.CreatedAt,
+		StartedAt:   job.StartedAt,
+		FinishedAt:  job.FinishedAt,
+	}
+	switch {
+	case job.Status == ReencryptionDone:
+		dto.Progress = 100
+	case job.Total > 0:
+		dto.Progress = job.Done * 100 / job.Total
+		if dto.Progress > 99 {
+			dto.Progress = 99
+		}
+	}
+	return dto
+}
@@ -0,0 +1,19 @@
+package model
+
+import "time"
+
+// RetentionReportDTO is the result of a purge of the retention policy, or what a dry run would purge
+type RetentionReportDTO struct {
+	DryRun     bool           `json:"dry_run"`
+	Trash      RetentionCount `json:"trash"`
+	AuditLogs  RetentionCount `json:"audit_logs"`
+	Tombstones RetentionCount `json:"tombstones"`
+	Sessions   RetentionCount `json:"sessions"`
+}
+
+// RetentionCount is the rows of a kind of data which are older than its retention period
+type RetentionCount struct {
+	Retention string     `json:"retention"` // empty keeps the data forever
+	Before    *time.Time `json:"before,omitempty"`
+	Rows      int        `json:"rows"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// SchemaMigration is a migration which is applied to the schema it is kept in
+type SchemaMigration struct {
+	Version   int       `gorm:"primary_key;auto_increment:false" json:"version"`
+	Name      string    `json:"name"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// MigrationStatusDTO is the migration version of a schema and the migrations it is behind
+type MigrationStatusDTO struct {
+	Schema  string   `json:"schema"`
+	Version int      `json:"version"`
+	Latest  int      `json:"latest"`
+	Pending []string `json:"pending"`
+}
@@ -10,8 +10,11 @@ type Server struct {
 	CreatedAt       time.Time  `json:"created_at"`
 	UpdatedAt       time.Time  `json:"updated_at"`
 	DeletedAt       *time.Time `json:"deleted_at"`
+	Revision        uint       `gorm:"not null;default:0" json:"revision"`
+	SyncRevision    int64      `gorm:"not null;default:0" json:"sync_revision"`
 	Title           string     `json:"title"`
 	IP              string     `json:"ip" encrypt:"true"`
+	IPIndex         string     `gorm:"type:text" json:"-" blind:"IP"`
 	Username        string     `json:"username" encrypt:"true"`
 	Password        string     `json:"password" encrypt:"true"`
 	URL             string     `json:"url"`
@@ -20,11 +23,19 @@ type Server struct {
 	AdminUsername   string     `json:"admin_username" encrypt:"true"`
 	AdminPassword   string     `json:"admin_password" encrypt:"true"`
 	Extra           string     `json:"extra" encrypt:"true"`
+	Pinned          bool       `json:"pinned"`
+	IsFavorite      bool       `json:"is_favorite"`
+	SortOrder       int        `json:"sort_order"`
+	FolderID        uint       `json:"folder_id"`
+	Tags            []uint     `gorm:"-" json:"tags"`
+	Reprompt        bool       `json:"reprompt"`
+	Canary          bool       `json:"canary"`
 }
 
 //ServerDTO DTO object for Server type
 type ServerDTO struct {
 	ID              uint   `json:"id"`
+	Revision        uint   `json:"revision"`
 	Title           string `json:"title"`
 	IP              string `json:"ip"`
 	Username        string `json:"username"`
@@ -35,6 +46,13 @@ type ServerDTO struct {
 	AdminUsername   string `json:"admin_username"`
 	AdminPassword   string `json:"admin_password"`
 	Extra           string `json:"extra"`
+	Pinned          bool   `json:"pinned"`
+	IsFavorite      bool   `json:"is_favorite"`
+	SortOrder       int    `json:"sort_order"`
+	FolderID        uint   `json:"folder_id"`
+	Tags            []uint `json:"tags"`
+	Reprompt        bool   `json:"reprompt"`
+	Canary          bool   `json:"canary"`
 }
 
 // ToServer ...
@@ -50,6 +68,13 @@ func ToServer(serverDTO *ServerDTO) *Server {
 		AdminUsername:   serverDTO.AdminUsername,
 		AdminPassword:   serverDTO.AdminPassword,
 		Extra:           serverDTO.Extra,
+		Pinned:          serverDTO.Pinned,
+		IsFavorite:      serverDTO.IsFavorite,
+		SortOrder:       serverDTO.SortOrder,
+		FolderID:        serverDTO.FolderID,
+		Tags:            serverDTO.Tags,
+		Reprompt:        serverDTO.Reprompt,
+		Canary:          serverDTO.Canary,
 	}
 }
 
@@ -57,6 +82,7 @@ func ToServer(serverDTO *ServerDTO) *Server {
 func ToServerDTO(server *Server) *ServerDTO {
 	return &ServerDTO{
 		ID:              server.ID,
+		Revision:        server.Revision,
 		Title:           server.Title,
 		IP:              server.IP,
 		Username:        server.Username,
@@ -67,6 +93,13 @@ func ToServerDTO(server *Server) *ServerDTO {
 		AdminUsername:   server.AdminUsername,
 		AdminPassword:   server.AdminPassword,
 		Extra:           server.Extra,
+		Pinned:          server.Pinned,
+		IsFavorite:      server.IsFavorite,
+		SortOrder:       server.SortOrder,
+		FolderID:        server.FolderID,
+		Tags:            server.Tags,
+		Reprompt:        server.Reprompt,
+		Canary:          server.Canary,
 	}
 }
 
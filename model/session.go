@@ -0,0 +1,36 @@
+package model
+
+import "time"
+
+// SessionDTO is a device the user signed in on
+type SessionDTO struct {
+	ID         string    `json:"id"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	StartedAt  time.Time `json:"started_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	ExpiresAt  time.Time `json:"expires_at"` // the refresh token expires, its session can't be continued after it
+	Current    bool      `json:"current"`    // the session of the request
+}
+
+// ToSessionDTO converts the refresh token of a session to its DTO
+func ToSessionDTO(token *Token, current string) *SessionDTO {
+	return &SessionDTO{
+		ID:         token.Family,
+		IP:         token.IP,
+		UserAgent:  token.UserAgent,
+		StartedAt:  token.StartedAt,
+		LastUsedAt: token.LastUsedAt,
+		ExpiresAt:  token.ExpiryTime,
+		Current:    token.Family == current,
+	}
+}
+
+// ToSessionDTOs converts the refresh tokens of sessions to their DTOs
+func ToSessionDTOs(tokens []Token, current string) []*SessionDTO {
+	dtos := make([]*SessionDTO, len(tokens))
+	for i := range tokens {
+		dtos[i] = ToSessionDTO(&tokens[i], current)
+	}
+	return dtos
+}
@@ -0,0 +1,13 @@
+package model
+
+import "time"
+
+// SigninFailure counts the failed sign ins of an account or an address. Key is the
+// lowercase email with an "email:" prefix or the address with an "ip:" one.
+type SigninFailure struct {
+	ID            uint       `gorm:"primary_key" json:"id"`
+	Key           string     `gorm:"type:varchar(320);unique_index" json:"key"`
+	Failures      int        `json:"failures"`
+	LastFailureAt time.Time  `gorm:"index" json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
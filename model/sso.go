@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// SSOIdentity links the subject of an identity provider to a user
+type SSOIdentity struct {
+	ID        uint      `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+}
+
+// SSOProviderDTO is an identity provider users can sign in with
+type SSOProviderDTO struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Protocol string `json:"protocol"` // oidc or saml, the paths of the sign in are /auth/sso or /auth/saml
+}
+
+// SSOAuthorizationDTO is the page of the identity provider the user signs in on,
+// it redirects back with a code and the state
+type SSOAuthorizationDTO struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+}
+
+// SSOCallbackDTO finishes a sign in with the query of the redirect of the identity provider
+type SSOCallbackDTO struct {
+	Code  string `validate:"required" json:"code"`
+	State string `validate:"required" json:"state"`
+}
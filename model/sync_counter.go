@@ -0,0 +1,12 @@
+package model
+
+// SyncCounter counts the changes of the items of a vault, each change gives its item the
+// next Value as its sync revision. Epoch is new with each counter, so clients notice a
+// vault which was reset or restored. Purged is the Value at the last purge of deleted
+// items, changes before it can't be synced anymore.
+type SyncCounter struct {
+	ID     uint   `gorm:"primary_key" json:"-"`
+	Epoch  string `json:"epoch"`
+	Value  int64  `gorm:"not null;default:0" json:"value"`
+	Purged int64  `gorm:"not null;default:0" json:"purged"`
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+)
+
+// Tag labels items of all types, an item has any number of tags
+type Tag struct {
+	ID        uint       `gorm:"primary_key" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+	Name      string     `json:"name" encrypt:"true"`
+}
+
+// ItemTag links a tag to an item of the type like "logins"
+type ItemTag struct {
+	ItemType string `gorm:"primary_key;auto_increment:false" json:"item_type"`
+	ItemID   uint   `gorm:"primary_key;auto_increment:false" json:"item_id"`
+	TagID    uint   `gorm:"primary_key;auto_increment:false" json:"tag_id"`
+}
+
+// TagDTO ...
+type TagDTO struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name" validate:"required,max=100"`
+}
+
+// ToTag ...
+func ToTag(tagDTO *TagDTO) *Tag {
+	return &Tag{
+		Name: tagDTO.Name,
+	}
+}
+
+// ToTagDTO ...
+func ToTagDTO(tag *Tag) *TagDTO {
+	return &TagDTO{
+		ID:   tag.ID,
+		Name: tag.Name,
+	}
+}
+
+// ToTagDTOs ...
+func ToTagDTOs(tags []Tag) []*TagDTO {
+	tagDTOs := make([]*TagDTO, len(tags))
+
+	for i := range tags {
+		tagDTOs[i] = ToTagDTO(&tags[i])
+	}
+
+	return tagDTOs
+}
+
+/* EXAMPLE JSON OBJECT
+{
+	"name": "2fa"
+}
+*/
@@ -14,4 +14,12 @@ type Token struct {
 	Token           string    `gorm:"type:text;"`
 	TransmissionKey string    `gorm:"type:text;"`
 	ExpiryTime      time.Time
+	LastUsedAt      time.Time
+	Family          string `gorm:"type:varchar(100);"` // the session, refreshed tokens keep it
+	Refresh         bool
+	RotatedAt       *time.Time // refresh tokens are used once, presenting them again revokes the family
+	IP              string     `gorm:"type:varchar(45);"` // the device of the session at its last sign in or refresh
+	UserAgent       string     `gorm:"type:text;"`
+	StartedAt       time.Time
+	Duress          bool
 }
@@ -0,0 +1,65 @@
+package model
+
+import "time"
+
+// TrustedDevice is a device on which the user verified a second factor, its sign ins skip
+// the second factor until it expires. Only the SHA-256 hashes of its token and fingerprint are stored.
+type TrustedDevice struct {
+	ID          uint       `gorm:"primary_key" json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UserID      uint       `gorm:"index" json:"user_id"`
+	Name        string     `json:"name"`
+	Fingerprint string     `json:"-"`
+	Hash        string     `gorm:"unique_index" json:"-"`
+	SecurityKey bool       `json:"security_key"` // the second factor was a security key
+	Duress      bool       `json:"-"`            // trusted in a session of the duress password, it only skips for it
+	ExpiresAt   time.Time  `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+}
+
+// TrustedDeviceDTO is the trusted device as seen by its user
+type TrustedDeviceDTO struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	SecurityKey bool       `json:"security_key"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+}
+
+// TrustDeviceDTO asks to trust the device of a sign in once its second factor is verified.
+// The fingerprint is what the client knows the device by, e.g. a hash of its properties.
+type TrustDeviceDTO struct {
+	TrustDevice       bool   `json:"trust_device,omitempty"`
+	DeviceFingerprint string `validate:"required_with=TrustDevice,max=255" json:"device_fingerprint,omitempty"`
+	DeviceName        string `validate:"max=100" json:"device_name,omitempty"`
+}
+
+// DeviceSigninDTO carries the device token of a trusted device to skip the second factor
+type DeviceSigninDTO struct {
+	DeviceToken       string `validate:"max=100" json:"device_token,omitempty"`
+	DeviceFingerprint string `validate:"max=255" json:"device_fingerprint,omitempty"`
+}
+
+// ToTrustedDeviceDTO ...
+func ToTrustedDeviceDTO(device *TrustedDevice) *TrustedDeviceDTO {
+	return &TrustedDeviceDTO{
+		ID:          device.ID,
+		Name:        device.Name,
+		SecurityKey: device.SecurityKey,
+		CreatedAt:   device.CreatedAt,
+		ExpiresAt:   device.ExpiresAt,
+		LastUsedAt:  device.LastUsedAt,
+	}
+}
+
+// ToTrustedDeviceDTOs ...
+func ToTrustedDeviceDTOs(devices []TrustedDevice) []*TrustedDeviceDTO {
+	deviceDTOs := make([]*TrustedDeviceDTO, len(devices))
+
+	for i := range devices {
+		deviceDTOs[i] = ToTrustedDeviceDTO(&devices[i])
+	}
+
+	return deviceDTOs
+}
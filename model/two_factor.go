@@ -0,0 +1,30 @@
+package model
+
+// Methods of the second factor of a sign in
+const (
+	TwoFactorTOTP = "totp"
+)
+
+// TOTPEnrollmentDTO is a new TOTP secret, authenticator apps scan the URL as a QR code
+type TOTPEnrollmentDTO struct {
+	Secret string `json:"secret"`
+	URL    string `json:"url"`
+}
+
+// TOTPCodeDTO is a code of the authenticator app of the user
+type TOTPCodeDTO struct {
+	Code string `validate:"required,len=6,numeric" json:"code"`
+}
+
+// TwoFactorSigninDTO continues a sign in with the code of the second factor
+type TwoFactorSigninDTO struct {
+	TwoFactorToken string `validate:"required" json:"two_factor_token"`
+	Code           string `validate:"required,len=6,numeric" json:"code"`
+	TrustDeviceDTO
+}
+
+// TwoFactorStatusDTO tells which second factors the user has
+type TwoFactorStatusDTO struct {
+	Enabled bool     `json:"enabled"`
+	Methods []string `json:"methods"`
+}
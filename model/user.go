@@ -16,31 +16,101 @@ type User struct {
 	Name             string     `json:"name"`
 	Email            string     `json:"email"`
 	MasterPassword   string     `json:"master_password"`
+	DuressPassword   string     `json:"-"`
 	Secret           string     `json:"secret"`
 	Schema           string     `json:"schema"`
 	Role             string     `json:"role"`
 	ConfirmationCode string     `json:"confirmation_code"`
 	EmailVerifiedAt  time.Time  `json:"email_verified_at"`
+	Locale           string     `json:"locale"`
+	TwoFactorEnabled bool       `json:"two_factor_enabled"`
+	TOTPSecret       string     `json:"-" encrypt:"server"` // base32, set on enrollment
+	TOTPLastStep     int64      `json:"-"`                  // time step of the last code, codes work once
+	KdfType          string     `json:"-"`                  // key derivation of the client, the kdf configuration if empty
+	KdfIterations    int        `json:"-"`
+	KdfMemory        int        `json:"-"` // KiB, argon2id only
+	KdfParallelism   int        `json:"-"` // argon2id only
 }
 
 //UserDTO DTO object for User type
 type UserDTO struct {
-	ID              uint      `json:"id"`
-	UUID            uuid.UUID `json:"uuid"`
-	Name            string    `json:"name" validate:"max=100"`
-	Email           string    `json:"email" validate:"required,email"`
-	MasterPassword  string    `json:"master_password" validate:"required,max=100,min=6"`
-	Secret          string    `json:"secret"`
-	Schema          string    `json:"schema"`
-	Role            string    `json:"role"`
-	EmailVerifiedAt time.Time `json:"email_verified_at"`
+	ID               uint      `json:"id"`
+	UUID             uuid.UUID `json:"uuid"`
+	Name             string    `json:"name" validate:"max=100"`
+	Email            string    `json:"email" validate:"required,email"`
+	MasterPassword   string    `json:"master_password" validate:"required,max=100,min=6"`
+	Secret           string    `json:"secret"`
+	Schema           string    `json:"schema"`
+	Role             string    `json:"role"`
+	EmailVerifiedAt  time.Time `json:"email_verified_at"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
 }
 
 type UserSignup struct {
 	Name           string `json:"name" validate:"max=100"`
 	Email          string `json:"email" validate:"required,email"`
 	MasterPassword string `json:"master_password" validate:"required,max=100,min=6"`
-	Recaptcha      string `json:"g_captcha_value" validate:"required"`
+	CaptchaToken   string `json:"captcha_token" validate:"max=4096"`   // response of the hCaptcha, reCAPTCHA or Turnstile widget
+	Recaptcha      string `json:"g_captcha_value" validate:"max=4096"` // older name of captcha_token
+}
+
+// CaptchaResponse returns the challenge token of the captcha widget
+func (u *UserSignup) CaptchaResponse() string {
+	if u.CaptchaToken != "" {
+		return u.CaptchaToken
+	}
+	return u.Recaptcha
+}
+
+// VerificationResendDTO asks for a new verification link of the account
+type VerificationResendDTO struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PreloginDTO asks for the key derivation function of the account
+type PreloginDTO struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PreloginResponse is the key derivation function clients derive the keys of the vault
+// with before they sign in
+type PreloginResponse struct {
+	Kdf         string `json:"kdf"`
+	Iterations  int    `json:"kdf_iterations"`
+	Memory      int    `json:"kdf_memory,omitempty"`
+	Parallelism int    `json:"kdf_parallelism,omitempty"`
+}
+
+// PasswordRecoverDTO asks for a reset link of the master password
+type PasswordRecoverDTO struct {
+	Email string `json:"email" validate:"required,email"`
+}
+
+// PasswordResetDTO sets the master password of a reset link. WipeVault confirms the
+// vault is emptied when the server wipes it.
+type PasswordResetDTO struct {
+	Token          string `json:"token" validate:"required"`
+	MasterPassword string `json:"master_password" validate:"required,max=100,min=6"`
+	WipeVault      bool   `json:"wipe_vault"`
+}
+
+// LocaleDTO is the language of the messages and emails of the user,
+// an empty preferred locale follows the Accept-Language header
+type LocaleDTO struct {
+	Preferred string   `json:"preferred"`
+	Current   string   `json:"current,omitempty"`
+	Supported []string `json:"supported,omitempty"`
+}
+
+// DuressDTO sets the duress password, the master password confirms the change
+type DuressDTO struct {
+	MasterPassword string `json:"master_password" validate:"required"`
+	DuressPassword string `json:"duress_password" validate:"required,max=100,min=6"`
+}
+
+// DuressStatusDTO tells if the user has a duress password
+type DuressStatusDTO struct {
+	Enabled bool `json:"enabled"`
 }
 
 //UserDTOTable ...
@@ -79,13 +149,14 @@ func ToUser(userDTO *UserDTO) *User {
 // ToUserDTO ...
 func ToUserDTO(user *User) *UserDTO {
 	return &UserDTO{
-		ID:     user.ID,
-		UUID:   user.UUID,
-		Name:   user.Name,
-		Email:  user.Email,
-		Secret: user.Secret,
-		Schema: user.Schema,
-		Role:   user.Role,
+		ID:               user.ID,
+		UUID:             user.UUID,
+		Name:             user.Name,
+		Email:            user.Email,
+		Secret:           user.Secret,
+		Schema:           user.Schema,
+		Role:             user.Role,
+		TwoFactorEnabled: user.TwoFactorEnabled,
 	}
 }
 
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// TwoFactorSecurityKey is the second factor method of WebAuthn credentials
+const TwoFactorSecurityKey = "security_key"
+
+// WebAuthnCredential is a security key or platform authenticator of the user.
+// Ids and public keys are base64url encoded, the public key is a COSE key.
+type WebAuthnCredential struct {
+	ID           uint       `gorm:"primary_key" json:"id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	Name         string     `json:"name"`
+	CredentialID string     `json:"credential_id"`
+	PublicKey    string     `gorm:"type:text" json:"-"`
+	AAGUID       string     `json:"aaguid"`
+	SignCount    uint32     `json:"-"`
+	LastUsedAt   *time.Time `json:"last_used_at"`
+}
+
+// WebAuthnCredentialDTO ...
+type WebAuthnCredentialDTO struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+}
+
+// WebAuthnRegistrationDTO names the credential navigator.credentials.create() returned
+type WebAuthnRegistrationDTO struct {
+	Name       string          `validate:"required,max=64" json:"name"`
+	Credential json.RawMessage `validate:"required" json:"credential"`
+}
+
+// TwoFactorTokenDTO asks for the options of a security key sign in
+type TwoFactorTokenDTO struct {
+	TwoFactorToken string `validate:"required" json:"two_factor_token"`
+}
+
+// WebAuthnSigninDTO continues a sign in with the credential navigator.credentials.get() returned
+type WebAuthnSigninDTO struct {
+	TwoFactorToken string          `validate:"required" json:"two_factor_token"`
+	Credential     json.RawMessage `validate:"required" json:"credential"`
+	TrustDeviceDTO
+}
+
+// ToWebAuthnCredentialDTO ...
+func ToWebAuthnCredentialDTO(credential *WebAuthnCredential) *WebAuthnCredentialDTO {
+	return &WebAuthnCredentialDTO{
+		ID:         credential.ID,
+		Name:       credential.Name,
+		CreatedAt:  credential.CreatedAt,
+		LastUsedAt: credential.LastUsedAt,
+	}
+}
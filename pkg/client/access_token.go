@@ -0,0 +1,35 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// WithAccessToken authorizes the requests with a personal access token instead of a sign in
+func WithAccessToken(token, transmissionKey string) Option {
+	return func(c *Client) {
+		c.session = &model.AuthLoginResponse{AccessToken: token, TransmissionKey: transmissionKey}
+	}
+}
+
+// ListAccessTokens returns the personal access tokens of the user
+func (c *Client) ListAccessTokens() ([]model.PersonalAccessTokenDTO, error) {
+	var list []model.PersonalAccessTokenDTO
+	err := c.call(http.MethodGet, "/api/tokens", nil, true, nil, &list)
+	return list, err
+}
+
+// CreateAccessToken creates a personal access token named dto.Name which expires at
+// dto.ExpiresAt. The token and its transmission key for WithAccessToken are only returned here.
+func (c *Client) CreateAccessToken(dto *model.PersonalAccessTokenDTO) (*model.PersonalAccessTokenDTO, error) {
+	created := new(model.PersonalAccessTokenDTO)
+	err := c.call(http.MethodPost, "/api/tokens", nil, true, dto, created)
+	return created, err
+}
+
+// RevokeAccessToken deletes the personal access token
+func (c *Client) RevokeAccessToken(id uint) error {
+	return c.call(http.MethodDelete, "/api/tokens/"+strconv.FormatUint(uint64(id), 10), nil, false, nil, nil)
+}
@@ -0,0 +1,78 @@
+package client
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// upload is a file which is sent as the file field of a multipart form, it isn't
+// encrypted with the transmission key
+type upload struct {
+	name    string
+	content []byte
+}
+
+// form returns the multipart form of the file and its content type
+func (u *upload) form() (*bytes.Buffer, string, error) {
+	body := new(bytes.Buffer)
+	mw := multipart.NewWriter(body)
+	part, err := mw.CreateFormFile("file", u.name)
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := part.Write(u.content); err != nil {
+		return nil, "", err
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, mw.FormDataContentType(), nil
+}
+
+// Attachments returns the attachments of the item, oldest first
+func (c *Client) Attachments(itemType string, itemID uint) ([]model.AttachmentDTO, error) {
+	var attachments []model.AttachmentDTO
+	err := c.call(http.MethodGet, attachmentsPath(itemType, itemID), nil, true, nil, &attachments)
+	return attachments, err
+}
+
+// CreateAttachment uploads a file to the item, the server encrypts it with a key of its own
+func (c *Client) CreateAttachment(itemType string, itemID uint, name string, content []byte) (*model.AttachmentDTO, error) {
+	attachment := new(model.AttachmentDTO)
+	err := c.call(http.MethodPost, attachmentsPath(itemType, itemID), nil, true, &upload{name: name, content: content}, attachment)
+	return attachment, err
+}
+
+// DownloadAttachment downloads the decrypted content of the attachment
+func (c *Client) DownloadAttachment(itemType string, itemID, id uint) ([]byte, error) {
+	var data []byte
+	err := c.call(http.MethodGet, attachmentsPath(itemType, itemID)+"/"+strconv.FormatUint(uint64(id), 10), nil, false, nil, &data)
+	return data, err
+}
+
+// DeleteAttachment deletes the attachment and its content
+func (c *Client) DeleteAttachment(itemType string, itemID, id uint) error {
+	return c.call(http.MethodDelete, attachmentsPath(itemType, itemID)+"/"+strconv.FormatUint(uint64(id), 10), nil, false, nil, nil)
+}
+
+// AttachmentUsage returns the space the attachments of the vault use and the limits
+func (c *Client) AttachmentUsage() (*model.AttachmentUsageDTO, error) {
+	usage := new(model.AttachmentUsageDTO)
+	err := c.call(http.MethodGet, "/api/attachments/usage", nil, false, nil, usage)
+	return usage, err
+}
+
+// SetAttachmentLimits changes the attachment limits of a user, only admins can do it
+func (c *Client) SetAttachmentLimits(userID uint, limits *model.AttachmentLimitsDTO) (*model.PolicyDTO, error) {
+	policy := new(model.PolicyDTO)
+	err := c.call(http.MethodPut, "/api/system/users/"+strconv.FormatUint(uint64(userID), 10)+"/attachment-limits", nil, false, limits, policy)
+	return policy, err
+}
+
+func attachmentsPath(itemType string, itemID uint) string {
+	return "/api/" + itemType + "/" + strconv.FormatUint(uint64(itemID), 10) + "/attachments"
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// VerifyAuditLog checks if the audit trail is modified or truncated, only admins can do it
+func (c *Client) VerifyAuditLog() (*model.AuditVerifyDTO, error) {
+	report := new(model.AuditVerifyDTO)
+	err := c.call(http.MethodGet, "/audit/verify", nil, false, nil, report)
+	return report, err
+}
+
+// AuditLogOptions filters and paginates the audit log, zero values don't filter
+type AuditLogOptions struct {
+	Since  time.Time
+	Until  time.Time
+	Action string // create, read, update, delete or signin
+	Offset int
+	Limit  int
+}
+
+func (o *AuditLogOptions) values() url.Values {
+	v := url.Values{}
+	if o == nil {
+		return v
+	}
+	if !o.Since.IsZero() {
+		v.Set("Since", o.Since.Format(time.RFC3339))
+	}
+	if !o.Until.IsZero() {
+		v.Set("Until", o.Until.Format(time.RFC3339))
+	}
+	if o.Action != "" {
+		v.Set("Action", o.Action)
+	}
+	if o.Offset > 0 {
+		v.Set("Offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		v.Set("Limit", strconv.Itoa(o.Limit))
+	}
+	return v
+}
+
+// ListAuditEvents returns the audit events of the vault, newest first
+func (c *Client) ListAuditEvents(opts *AuditLogOptions) ([]model.AuditEvent, error) {
+	var events []model.AuditEvent
+	err := c.call(http.MethodGet, "/api/audit-logs", opts.values(), true, nil, &events)
+	return events, err
+}
+
+// ExportAuditLog downloads the audit events of all vaults as csv or jsonl, only admins can do it
+func (c *Client) ExportAuditLog(format string, opts *AuditLogOptions) ([]byte, error) {
+	query := opts.values()
+	query.Set("format", format)
+	var data []byte
+	err := c.call(http.MethodGet, "/api/audit/export", query, false, nil, &data)
+	return data, err
+}
@@ -0,0 +1,39 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// Backups returns the backup files of the vaults, the newest first, only admins can do it
+func (c *Client) Backups() ([]model.Backup, error) {
+	var backups []model.Backup
+	err := c.call(http.MethodGet, "/admin/backups", nil, false, nil, &backups)
+	return backups, err
+}
+
+// CreateBackups backs up every vault now and returns the new backup files
+func (c *Client) CreateBackups() ([]model.Backup, error) {
+	var backups []model.Backup
+	err := c.call(http.MethodPost, "/admin/backups", nil, false, nil, &backups)
+	return backups, err
+}
+
+// DownloadBackup downloads the backup file with the name, it is sealed with the server
+// passphrase like an export archive
+func (c *Client) DownloadBackup(name string) ([]byte, error) {
+	var data []byte
+	err := c.call(http.MethodGet, "/admin/backups/"+url.PathEscape(name), nil, false, nil, &data)
+	return data, err
+}
+
+// Restore restores a backup of the vault by its name, or the content of a backup file or
+// an export archive with its passphrase. Wiping the vault needs a Reauthenticate of the
+// last minutes, dry runs only report what would change.
+func (c *Client) Restore(dto *model.RestoreDTO) (*model.RestoreReportDTO, error) {
+	report := new(model.RestoreReportDTO)
+	err := c.call(http.MethodPost, "/api/restore", nil, true, dto, report)
+	return report, err
+}
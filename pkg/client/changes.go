@@ -0,0 +1,143 @@
+package client
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/passwall/passwall-server/model"
+	"golang.org/x/net/websocket"
+)
+
+// Changes is a connection to the push channel of the vault
+type Changes struct {
+	receive func(payload *model.Payload) error
+	closer  io.Closer
+	key     string
+}
+
+// WatchChanges connects to the push channel of the vault. The server closes it when the
+// access token expires, connect again then and sync the changes in between with Sync.
+func (c *Client) WatchChanges() (*Changes, error) {
+	session := c.Session()
+	changes, err := c.dialChanges(session)
+	if err == websocket.ErrBadStatus && c.refresh(session) == nil {
+		session = c.Session()
+		changes, err = c.dialChanges(session)
+	}
+	return changes, err
+}
+
+func (c *Client) dialChanges(session *model.AuthLoginResponse) (*Changes, error) {
+	if session == nil {
+		return nil, errNoSession
+	}
+
+	url := "ws" + strings.TrimPrefix(c.baseURL, "http") + "/api/changes"
+	config, err := websocket.NewConfig(url, c.baseURL)
+	if err != nil {
+		return nil, err
+	}
+	config.Header.Set("Authorization", "Bearer "+session.AccessToken)
+	if transport, ok := c.httpClient.Transport.(*http.Transport); ok {
+		config.TlsConfig = transport.TLSClientConfig
+	}
+
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		if dialErr, ok := err.(*websocket.DialError); ok {
+			return nil, dialErr.Err
+		}
+		return nil, err
+	}
+	receive := func(payload *model.Payload) error {
+		return websocket.JSON.Receive(ws, payload)
+	}
+	return &Changes{receive: receive, closer: ws, key: session.TransmissionKey}, nil
+}
+
+// StreamChanges is WatchChanges over Server-Sent Events, for networks which don't pass
+// WebSockets
+func (c *Client) StreamChanges() (*Changes, error) {
+	session := c.Session()
+	changes, err := c.openChangeStream(session)
+	if apiErr, ok := err.(*Error); ok && apiErr.StatusCode == http.StatusUnauthorized && apiErr != errNoSession {
+		if c.refresh(session) == nil {
+			session = c.Session()
+			changes, err = c.openChangeStream(session)
+		}
+	}
+	return changes, err
+}
+
+func (c *Client) openChangeStream(session *model.AuthLoginResponse) (*Changes, error) {
+	if session == nil {
+		return nil, errNoSession
+	}
+
+	req, err := http.NewRequest(http.MethodGet, c.baseURL+"/api/changes/events", nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+session.AccessToken)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+	}
+
+	events := bufio.NewReader(resp.Body)
+	receive := func(payload *model.Payload) error {
+		return receiveEvent(events, "change", payload)
+	}
+	return &Changes{receive: receive, closer: resp.Body, key: session.TransmissionKey}, nil
+}
+
+// receiveEvent reads the JSON data of the next event of the type, other events and
+// comments are skipped
+func receiveEvent(events *bufio.Reader, eventType string, v interface{}) error {
+	event, data := "message", ""
+	for {
+		line, err := events.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		switch {
+		case line == "":
+			if event == eventType && data != "" {
+				return json.Unmarshal([]byte(data), v)
+			}
+			event, data = "message", ""
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data += strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")
+		}
+	}
+}
+
+// Next blocks until the next change of the vault, it returns an error once the
+// connection is closed
+func (ch *Changes) Next() (*model.ChangeDTO, error) {
+	var payload model.Payload
+	if err := ch.receive(&payload); err != nil {
+		return nil, err
+	}
+	change := new(model.ChangeDTO)
+	if err := decryptJSON(ch.key, []byte(payload.Data), change); err != nil {
+		return nil, err
+	}
+	return change, nil
+}
+
+// Close closes the connection, a blocked Next returns
+func (ch *Changes) Close() error {
+	return ch.closer.Close()
+}
@@ -0,0 +1,350 @@
+// Package client is the Go SDK of the passwall server API.
+//
+// It signs in, refreshes expired access tokens and encrypts request and
+// response payloads with the transmission key of the session, so callers
+// only deal with the DTOs of the model package. Methods mirror the routes
+// in internal/router and are changed together with them.
+//
+//	c := client.New("https://vault.passwall.io")
+//	if err := c.Signin("hello@passwall.io", "master-password"); err != nil {
+//		return err
+//	}
+//	logins, err := c.ListLogins(nil)
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	openssl "github.com/Luzifer/go-openssl/v4"
+	"github.com/passwall/passwall-server/model"
+)
+
+// Client is a passwall API client, it is safe for concurrent use
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	locale     string
+
+	mu          sync.RWMutex
+	session     *model.AuthLoginResponse
+	device      model.TrustDeviceDTO
+	deviceToken string
+	reauthToken string // of the last Reauthenticate, sensitive requests send it
+
+	// refreshMu lets one refresh use the refresh token, the server revokes
+	// the session when a used one comes again
+	refreshMu sync.Mutex
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sets the http client used for requests
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = httpClient
+	}
+}
+
+// WithSession resumes a session of an earlier sign in
+func WithSession(session *model.AuthLoginResponse) Option {
+	return func(c *Client) {
+		c.session = session
+	}
+}
+
+// WithLocale asks for messages in the locale like "tr", the preference of the user wins
+func WithLocale(locale string) Option {
+	return func(c *Client) {
+		c.locale = locale
+	}
+}
+
+// New creates a client of the server at baseURL
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: http.DefaultClient,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// Error is an error response of the server
+type Error struct {
+	StatusCode int
+	Message    string
+	Errors     []string
+	RetryAfter time.Duration // when to try again after 429 Too Many Requests or 423 Locked
+	RequestID  string        // finds the log lines of the request on the server
+	// Conflict is set when an update was edited at a revision the item has left, the
+	// versions are maps of the fields of the item DTOs
+	Conflict *model.ConflictDTO
+
+	data string // encrypted details of the error
+}
+
+func (e *Error) Error() string {
+	if len(e.Errors) > 0 {
+		return fmt.Sprintf("passwall: %d %s: %s", e.StatusCode, e.Message, strings.Join(e.Errors, ", "))
+	}
+	return fmt.Sprintf("passwall: %d %s", e.StatusCode, e.Message)
+}
+
+// Session returns the tokens and transmission key of the current session
+func (c *Client) Session() *model.AuthLoginResponse {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.session
+}
+
+// Signin signs in with the credentials and starts a new session. It returns a
+// *TwoFactorRequiredError when the user has to verify a second factor, e.g. with SigninTOTP.
+func (c *Client) Signin(email, masterPassword string) error {
+	session := new(model.AuthLoginResponse)
+	dto := model.AuthLoginDTO{Email: email, MasterPassword: masterPassword, DeviceSigninDTO: c.deviceSignin()}
+	if err := c.send(http.MethodPost, "/auth/signin", nil, "", dto, session); err != nil {
+		return err
+	}
+	if session.TwoFactorRequired {
+		return &TwoFactorRequiredError{Token: session.TwoFactorToken, Methods: session.TwoFactorMethods}
+	}
+
+	c.startSession(session)
+	return nil
+}
+
+// Prelogin returns the key derivation function the vault keys of the account are derived with
+func (c *Client) Prelogin(email string) (*model.PreloginResponse, error) {
+	kdf := new(model.PreloginResponse)
+	err := c.send(http.MethodPost, "/api/auth/prelogin", nil, "", model.PreloginDTO{Email: email}, kdf)
+	return kdf, err
+}
+
+// Refresh renews the access token and transmission key with the refresh token.
+// Refresh tokens are used once, the session gets a new one.
+func (c *Client) Refresh() error {
+	return c.refresh(nil)
+}
+
+// refresh renews the session unless it was renewed since stale was current
+func (c *Client) refresh(stale *model.AuthLoginResponse) error {
+	c.refreshMu.Lock()
+	defer c.refreshMu.Unlock()
+
+	current := c.Session()
+	if current == nil {
+		return errNoSession
+	}
+	if stale != nil && current != stale {
+		return nil
+	}
+
+	session := new(model.AuthLoginResponse)
+	body := map[string]string{"refresh_token": current.RefreshToken}
+	if err := c.send(http.MethodPost, "/auth/refresh", nil, "", body, session); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.session = session
+	c.mu.Unlock()
+	return nil
+}
+
+// Signout revokes the session on the server, its access and refresh tokens stop working
+func (c *Client) Signout() error {
+	current := c.Session()
+	if current == nil {
+		return errNoSession
+	}
+	body := map[string]string{"token": current.RefreshToken}
+	if err := c.send(http.MethodPost, "/auth/revoke", nil, "", body, nil); err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	c.session = nil
+	c.mu.Unlock()
+	return nil
+}
+
+var errNoSession = &Error{StatusCode: http.StatusUnauthorized, Message: "not signed in"}
+
+// call sends an authorized request. in is sent and out is read as an encrypted
+// payload when encrypted is true. An expired access token is refreshed once.
+func (c *Client) call(method, path string, query url.Values, encrypted bool, in, out interface{}) error {
+	session := c.Session()
+	err := c.callOnce(method, path, query, encrypted, in, out)
+	if apiErr, ok := err.(*Error); ok && apiErr.StatusCode == http.StatusUnauthorized && apiErr != errNoSession {
+		if c.refresh(session) == nil {
+			return c.callOnce(method, path, query, encrypted, in, out)
+		}
+	}
+	return err
+}
+
+func (c *Client) callOnce(method, path string, query url.Values, encrypted bool, in, out interface{}) error {
+	session := c.Session()
+	if session == nil {
+		return errNoSession
+	}
+
+	if !encrypted {
+		return c.send(method, path, query, session.AccessToken, in, out)
+	}
+
+	var body interface{}
+	cond, isConditional := in.(*conditional)
+	if isConditional {
+		in = cond.in
+	}
+	if file, ok := in.(*upload); ok {
+		body = file
+	} else if in != nil {
+		data, err := encryptJSON(session.TransmissionKey, in)
+		if err != nil {
+			return err
+		}
+		body = model.Payload{Data: string(data)}
+	}
+	if isConditional {
+		body = &conditional{in: body, revision: cond.revision}
+	}
+
+	var payload model.Payload
+	if err := c.send(method, path, query, session.AccessToken, body, &payload); err != nil {
+		if apiErr, ok := err.(*Error); ok && apiErr.StatusCode == http.StatusConflict && apiErr.data != "" {
+			apiErr.Conflict = new(model.ConflictDTO)
+			if err := decryptJSON(session.TransmissionKey, []byte(apiErr.data), apiErr.Conflict); err != nil {
+				return err
+			}
+		}
+		return err
+	}
+	if page, ok := out.(*paged); ok {
+		*page.next = payload.NextCursor
+		out = page.out
+	}
+	if out == nil {
+		return nil
+	}
+	return decryptJSON(session.TransmissionKey, []byte(payload.Data), out)
+}
+
+// send sends in as JSON body and decodes the JSON response into out, a *[]byte gets
+// the response as it is
+func (c *Client) send(method, path string, query url.Values, accessToken string, in, out interface{}) error {
+	ifMatch := ""
+	if cond, ok := in.(*conditional); ok {
+		in, ifMatch = cond.in, fmt.Sprintf(`"%d"`, cond.revision)
+	}
+
+	var body io.Reader = http.NoBody
+	contentType := "application/json"
+	if file, ok := in.(*upload); ok {
+		var err error
+		if body, contentType, err = file.form(); err != nil {
+			return err
+		}
+	} else if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+
+	u := c.baseURL + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	if c.locale != "" {
+		req.Header.Set("Accept-Language", c.locale)
+	}
+	if ifMatch != "" {
+		req.Header.Set("If-Match", ifMatch)
+	}
+	c.mu.RLock()
+	if c.reauthToken != "" {
+		req.Header.Set("X-Reauth-Token", c.reauthToken)
+	}
+	c.mu.RUnlock()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode), RequestID: resp.Header.Get("X-Request-ID")}
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			apiErr.RetryAfter = time.Duration(seconds) * time.Second
+		}
+		var errResp struct {
+			Message string   `json:"message"`
+			Errors  []string `json:"errors"`
+			Data    string   `json:"data"`
+		}
+		if json.Unmarshal(respBody, &errResp) == nil && errResp.Message != "" {
+			apiErr.Message = errResp.Message
+			apiErr.Errors = errResp.Errors
+			apiErr.data = errResp.Data
+		}
+		return apiErr
+	}
+
+	if out == nil || len(respBody) == 0 {
+		return nil
+	}
+	if raw, ok := out.(*[]byte); ok {
+		*raw = respBody
+		return nil
+	}
+	return json.Unmarshal(respBody, out)
+}
+
+// encryptJSON encrypts the payload the same way as the server
+func encryptJSON(key string, v interface{}) ([]byte, error) {
+	text, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return openssl.New().EncryptBytes(key, text, openssl.BytesToKeyMD5)
+}
+
+// decryptJSON decrypts a payload of the server
+func decryptJSON(key string, data []byte, v interface{}) error {
+	text, err := openssl.New().DecryptBytes(key, data, openssl.BytesToKeyMD5)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(text, v)
+}
@@ -0,0 +1,103 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/passwall/passwall-server/model"
+	"github.com/passwall/passwall-server/pkg/servertest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSignin(t *testing.T) {
+	srv, err := servertest.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	c := New(srv.URL)
+	_, err = c.ListLogins(nil)
+	assert.Equal(t, errNoSession, err)
+
+	err = c.Signin("nobody@passwall.io", "master-password")
+	apiErr, ok := err.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusUnauthorized, apiErr.StatusCode)
+}
+
+func TestError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Request-ID", "lb-4bf92f35")
+		w.Header().Set("Retry-After", "30")
+		w.WriteHeader(http.StatusLocked)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Too many failed signins",
+			"errors":  []string{"try again later"},
+		})
+	}))
+	defer srv.Close()
+
+	err := New(srv.URL).Signin("patron@passwall.io", "master-password")
+	apiErr, ok := err.(*Error)
+	assert.True(t, ok)
+	assert.Equal(t, http.StatusLocked, apiErr.StatusCode)
+	assert.Equal(t, "Too many failed signins", apiErr.Message)
+	assert.Equal(t, []string{"try again later"}, apiErr.Errors)
+	assert.Equal(t, 30*time.Second, apiErr.RetryAfter)
+	assert.Equal(t, "lb-4bf92f35", apiErr.RequestID)
+}
+
+func TestIfMatch(t *testing.T) {
+	var ifMatch []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifMatch = append(ifMatch, r.Header.Get("If-Match"))
+		data, _ := encryptJSON("transmission-key", model.LoginDTO{ID: 1, Title: "VPN"})
+		json.NewEncoder(w).Encode(model.Payload{Data: string(data)})
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithSession(&model.AuthLoginResponse{AccessToken: "access-token", TransmissionKey: "transmission-key"}))
+	var login model.LoginDTO
+	fields := map[string]interface{}{"title": "VPN"}
+	assert.NoError(t, c.PatchItem(LoginItem, 1, fields, &login))
+	assert.NoError(t, c.PatchItem(LoginItem, 1, IfMatch(3, fields), &login))
+	assert.Equal(t, []string{"", `"3"`}, ifMatch)
+	assert.Equal(t, "VPN", login.Title)
+}
+
+func TestRefreshExpiredToken(t *testing.T) {
+	refreshed := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/auth/refresh":
+			refreshed++
+			json.NewEncoder(w).Encode(model.AuthLoginResponse{
+				AccessToken:     "new-access-token",
+				RefreshToken:    "new-refresh-token",
+				TransmissionKey: "new-transmission-key",
+			})
+		case r.Header.Get("Authorization") != "Bearer new-access-token":
+			w.WriteHeader(http.StatusUnauthorized)
+		default:
+			data, _ := encryptJSON("new-transmission-key", []model.NoteDTO{{ID: 1, Title: "Wifi"}})
+			json.NewEncoder(w).Encode(model.Payload{Data: string(data)})
+		}
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithSession(&model.AuthLoginResponse{
+		AccessToken:     "expired-access-token",
+		RefreshToken:    "refresh-token",
+		TransmissionKey: "old-transmission-key",
+	}))
+
+	notes, err := c.ListNotes(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, refreshed)
+	assert.Equal(t, []model.NoteDTO{{ID: 1, Title: "Wifi"}}, notes)
+	assert.Equal(t, "new-refresh-token", c.Session().RefreshToken)
+}
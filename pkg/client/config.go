@@ -0,0 +1,15 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// ReloadConfig reads the configuration file of the server again and returns the settings
+// which changed, only admins can do it
+func (c *Client) ReloadConfig() (*model.ConfigReloadDTO, error) {
+	reload := new(model.ConfigReloadDTO)
+	err := c.call(http.MethodPost, "/admin/config/reload", nil, false, nil, reload)
+	return reload, err
+}
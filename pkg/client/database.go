@@ -0,0 +1,15 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// DatabasePools returns the utilization of the connection pools of the primary and the
+// replicas, only admins can do it
+func (c *Client) DatabasePools() ([]model.DatabasePoolDTO, error) {
+	var pools []model.DatabasePoolDTO
+	err := c.call(http.MethodGet, "/admin/database/pools", nil, false, nil, &pools)
+	return pools, err
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// Duress tells if the user has a duress password
+func (c *Client) Duress() (bool, error) {
+	status := new(model.DuressStatusDTO)
+	err := c.call(http.MethodGet, "/api/duress", nil, false, nil, status)
+	return status.Enabled, err
+}
+
+// SetDuress sets the duress password, signing in with it opens an empty decoy vault
+func (c *Client) SetDuress(masterPassword, duressPassword string) error {
+	dto := model.DuressDTO{MasterPassword: masterPassword, DuressPassword: duressPassword}
+	return c.call(http.MethodPut, "/api/duress", nil, false, dto, nil)
+}
+
+// RemoveDuress removes the duress password and deletes the decoy vault
+func (c *Client) RemoveDuress(masterPassword string) error {
+	dto := model.DuressDTO{MasterPassword: masterPassword}
+	return c.call(http.MethodDelete, "/api/duress", nil, false, dto, nil)
+}
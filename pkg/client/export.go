@@ -0,0 +1,95 @@
+package client
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/passwall/passwall-server/model"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	exportMagic      = []byte("PWEXPORT1")
+	errExportArchive = errors.New("passwall: export archive is not valid or the passphrase is wrong")
+)
+
+// StartExport queues an export of the vault, poll it with Export until it is done
+func (c *Client) StartExport(passphrase string) (*model.ExportJobDTO, error) {
+	job := new(model.ExportJobDTO)
+	err := c.call(http.MethodPost, "/api/export", nil, false, model.ExportRequestDTO{Passphrase: passphrase}, job)
+	return job, err
+}
+
+// Export returns the progress of an export, DownloadURL is set when the archive is ready
+func (c *Client) Export(id string) (*model.ExportJobDTO, error) {
+	job := new(model.ExportJobDTO)
+	err := c.call(http.MethodGet, "/api/export/"+id, nil, false, nil, job)
+	return job, err
+}
+
+// DownloadExport downloads the encrypted archive of the signed link, it needs no session
+func (c *Client) DownloadExport(downloadURL string) ([]byte, error) {
+	resp, err := c.httpClient.Get(c.baseURL + downloadURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		apiErr := &Error{StatusCode: resp.StatusCode, Message: http.StatusText(resp.StatusCode)}
+		var errResp struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(body, &errResp) == nil && errResp.Message != "" {
+			apiErr.Message = errResp.Message
+		}
+		return nil, apiErr
+	}
+	return body, nil
+}
+
+// ExportItems downloads the decrypted items of the type like "logins" as CSV, it needs a
+// Reauthenticate of the last minutes
+func (c *Client) ExportItems(itemType string) ([]byte, error) {
+	var data []byte
+	err := c.call(http.MethodGet, "/api/"+itemType+"/export", url.Values{"format": {"csv"}}, false, nil, &data)
+	return data, err
+}
+
+// OpenExportArchive decrypts a downloaded archive with the passphrase of the export
+func OpenExportArchive(sealed []byte, passphrase string) (*model.ExportArchive, error) {
+	header := len(exportMagic) + 16 + 12
+	if len(sealed) < header || !bytes.Equal(sealed[:len(exportMagic)], exportMagic) {
+		return nil, errExportArchive
+	}
+
+	key, err := scrypt.Key([]byte(passphrase), sealed[len(exportMagic):len(exportMagic)+16], 1<<15, 8, 1, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	data, err := gcm.Open(nil, sealed[len(exportMagic)+16:header], sealed[header:], exportMagic)
+	if err != nil {
+		return nil, errExportArchive
+	}
+
+	archive := new(model.ExportArchive)
+	return archive, json.Unmarshal(data, archive)
+}
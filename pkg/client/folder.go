@@ -0,0 +1,51 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+func folderPath(id uint) string {
+	return "/api/folders/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// ListFolders returns the folders of the vault
+func (c *Client) ListFolders() ([]model.FolderDTO, error) {
+	var list []model.FolderDTO
+	err := c.call(http.MethodGet, "/api/folders", nil, true, nil, &list)
+	return list, err
+}
+
+// GetFolder returns the folder with the id
+func (c *Client) GetFolder(id uint) (*model.FolderDTO, error) {
+	folder := new(model.FolderDTO)
+	err := c.call(http.MethodGet, folderPath(id), nil, true, nil, folder)
+	return folder, err
+}
+
+// CreateFolder creates a folder, items are put in it with their folder_id
+func (c *Client) CreateFolder(dto *model.FolderDTO) (*model.FolderDTO, error) {
+	created := new(model.FolderDTO)
+	err := c.call(http.MethodPost, "/api/folders", nil, true, dto, created)
+	return created, err
+}
+
+// UpdateFolder renames the folder
+func (c *Client) UpdateFolder(id uint, dto *model.FolderDTO) (*model.FolderDTO, error) {
+	updated := new(model.FolderDTO)
+	err := c.call(http.MethodPut, folderPath(id), nil, true, dto, updated)
+	return updated, err
+}
+
+// DeleteFolder deletes the folder, its items stay without a folder
+func (c *Client) DeleteFolder(id uint) error {
+	return c.call(http.MethodDelete, folderPath(id), nil, false, nil, nil)
+}
+
+// MoveItems moves items of any type to the folder at once, 0 is no folder
+func (c *Client) MoveItems(folderID uint, items []model.ItemRefDTO) error {
+	dto := &model.ItemMoveDTO{FolderID: folderID, Items: items}
+	return c.call(http.MethodPost, "/api/items/move", nil, true, dto, nil)
+}
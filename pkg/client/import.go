@@ -0,0 +1,54 @@
+package client
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// ImportBitwarden imports the unencrypted JSON or CSV export of a Bitwarden vault
+func (c *Client) ImportBitwarden(content string) (*model.ImportReportDTO, error) {
+	return c.importFile("/api/import/bitwarden", &model.ImportFileDTO{Content: content})
+}
+
+// ImportLastPass imports the CSV export of a LastPass vault
+func (c *Client) ImportLastPass(content string) (*model.ImportReportDTO, error) {
+	return c.importFile("/api/import/lastpass", &model.ImportFileDTO{Content: content})
+}
+
+// ImportOnePassword imports the 1PUX or CSV export of 1Password, 1PUX archives are sent
+// base64 encoded
+func (c *Client) ImportOnePassword(content []byte) (*model.ImportReportDTO, error) {
+	if bytes.HasPrefix(content, []byte("PK\x03\x04")) {
+		return c.importFile("/api/import/1password", &model.ImportFileDTO{Content: base64.StdEncoding.EncodeToString(content)})
+	}
+	return c.importFile("/api/import/1password", &model.ImportFileDTO{Content: string(content)})
+}
+
+// ImportKeePass imports the KDBX file of a KeePass database opened with the password, or
+// the XML export of the database. KDBX files are sent base64 encoded.
+func (c *Client) ImportKeePass(content []byte, password string) (*model.ImportReportDTO, error) {
+	if bytes.HasPrefix(content, []byte{0x03, 0xD9, 0xA2, 0x9A}) {
+		return c.importFile("/api/import/keepass", &model.ImportFileDTO{Content: base64.StdEncoding.EncodeToString(content), Password: password})
+	}
+	return c.importFile("/api/import/keepass", &model.ImportFileDTO{Content: string(content)})
+}
+
+// ImportBrowser imports the CSV export of the passwords of Chrome, Edge, Firefox or Safari
+func (c *Client) ImportBrowser(content string) (*model.ImportReportDTO, error) {
+	return c.importFile("/api/import/browser", &model.ImportFileDTO{Content: content})
+}
+
+// ImportCSV imports a CSV of any layout with the mapping of its columns to the fields of
+// the items, a dry run reports the items it would create without creating them
+func (c *Client) ImportCSV(content string, mapping model.ImportMappingDTO, dryRun bool) (*model.ImportReportDTO, error) {
+	return c.importFile("/api/import/csv", &model.ImportFileDTO{Content: content, Mapping: &mapping, DryRun: dryRun})
+}
+
+func (c *Client) importFile(path string, dto *model.ImportFileDTO) (*model.ImportReportDTO, error) {
+	report := new(model.ImportReportDTO)
+	err := c.call(http.MethodPost, path, nil, true, dto, report)
+	return report, err
+}
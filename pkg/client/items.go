@@ -0,0 +1,461 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// Item types of the generic item endpoints
+const (
+	LoginItem       = "logins"
+	CreditCardItem  = "credit-cards"
+	BankAccountItem = "bank-accounts"
+	NoteItem        = "notes"
+	EmailItem       = "emails"
+	ServerItem      = "servers"
+)
+
+// ListOptions filters, sorts and paginates list endpoints
+type ListOptions struct {
+	Search string
+	Sort   string // field name, e.g. title
+	Order  string // asc or desc
+	Offset int
+	Limit  int
+	// FolderID lists the items of the folder, a pointer to 0 the items without a folder
+	FolderID *uint
+	// Tags lists the items with all of the tags
+	Tags []uint
+	// IsFavorite lists only the favorite items
+	IsFavorite bool
+	// Cursor pages the list by cursors instead of Offset, a pointer to "" is the first page.
+	// Lists set NextCursor to the cursor of the next page, it's empty after the last page.
+	Cursor     *string
+	NextCursor string
+	// CreatedAfter, CreatedBefore, UpdatedAfter and UpdatedBefore list the items created or
+	// updated in the range, zero times don't filter
+	CreatedAfter  time.Time
+	CreatedBefore time.Time
+	UpdatedAfter  time.Time
+	UpdatedBefore time.Time
+	// Fields lists the items with the values, e.g. {"url": "https://github.com"}
+	Fields map[string]string
+}
+
+func (o *ListOptions) values() url.Values {
+	v := url.Values{}
+	if o == nil {
+		return v
+	}
+	if o.Search != "" {
+		v.Set("Search", o.Search)
+	}
+	if o.Sort != "" {
+		v.Set("Sort", o.Sort)
+	}
+	if o.Order != "" {
+		v.Set("Order", o.Order)
+	}
+	if o.Offset > 0 {
+		v.Set("Offset", strconv.Itoa(o.Offset))
+	}
+	if o.Limit > 0 {
+		v.Set("Limit", strconv.Itoa(o.Limit))
+	}
+	if o.FolderID != nil {
+		v.Set("FolderID", strconv.FormatUint(uint64(*o.FolderID), 10))
+	}
+	if len(o.Tags) > 0 {
+		tags := make([]string, len(o.Tags))
+		for i, tag := range o.Tags {
+			tags[i] = strconv.FormatUint(uint64(tag), 10)
+		}
+		v.Set("Tags", strings.Join(tags, ","))
+	}
+	if o.IsFavorite {
+		v.Set("IsFavorite", "true")
+	}
+	if o.Cursor != nil {
+		v.Set("Cursor", *o.Cursor)
+	}
+	for param, t := range map[string]time.Time{
+		"CreatedAfter":  o.CreatedAfter,
+		"CreatedBefore": o.CreatedBefore,
+		"UpdatedAfter":  o.UpdatedAfter,
+		"UpdatedBefore": o.UpdatedBefore,
+	} {
+		if !t.IsZero() {
+			v.Set(param, t.Format(time.RFC3339Nano))
+		}
+	}
+	for field, value := range o.Fields {
+		v.Set(field, value)
+	}
+	return v
+}
+
+// page returns out for the list, with the next cursor of the options when they page by
+// cursors
+func (o *ListOptions) page(out interface{}) interface{} {
+	if o == nil || o.Cursor == nil {
+		return out
+	}
+	return &paged{out: out, next: &o.NextCursor}
+}
+
+// paged is the out of a list paged by cursors, the payload has the cursor of the next page
+type paged struct {
+	out  interface{}
+	next *string
+}
+
+// conditional is the body of an update which is only done while the item has the revision
+type conditional struct {
+	in       interface{}
+	revision uint
+}
+
+// IfMatch wraps the fields of PatchItem so the update is only done while the item still has
+// the revision the client read. Otherwise it fails with 412 Precondition Failed.
+func IfMatch(revision uint, fields interface{}) interface{} {
+	return &conditional{in: fields, revision: revision}
+}
+
+func itemPath(itemType string, id uint) string {
+	return "/api/" + itemType + "/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// PatchItem updates only the fields of the item, like map[string]interface{}{"title": "VPN"},
+// and decodes the updated item into out
+func (c *Client) PatchItem(itemType string, id uint, fields, out interface{}) error {
+	return c.call(http.MethodPatch, itemPath(itemType, id), nil, true, fields, out)
+}
+
+// CloneItem copies the item with a "(copy)" suffix and decodes the copy into out
+func (c *Client) CloneItem(itemType string, id uint, out interface{}) error {
+	return c.call(http.MethodPost, itemPath(itemType, id)+"/clone", nil, true, nil, out)
+}
+
+// CloneItemToFolder copies the item with a "(copy)" suffix into the folder, 0 is no folder,
+// and decodes the copy into out
+func (c *Client) CloneItemToFolder(itemType string, id, folderID uint, out interface{}) error {
+	return c.call(http.MethodPost, itemPath(itemType, id)+"/clone", nil, true, model.ItemCloneDTO{FolderID: &folderID}, out)
+}
+
+// UpdateItemOrders pins and reorders the items of a type
+func (c *Client) UpdateItemOrders(itemType string, orders []model.ItemOrderDTO) error {
+	return c.call(http.MethodPut, "/api/"+itemType+"/order", nil, true, orders, nil)
+}
+
+// CreateItems creates a batch of items of a type in a single transaction. The results are
+// in the order of the dtos, their items decode to maps.
+func (c *Client) CreateItems(itemType string, dtos interface{}) ([]model.BatchResultDTO, error) {
+	var results []model.BatchResultDTO
+	err := c.call(http.MethodPost, "/api/"+itemType+"/batch", nil, true, dtos, &results)
+	return results, err
+}
+
+// DeleteItems moves a batch of items of a type to the trash in a single transaction
+func (c *Client) DeleteItems(itemType string, ids []uint) (*model.BatchDeleteDTO, error) {
+	summary := new(model.BatchDeleteDTO)
+	err := c.call(http.MethodDelete, "/api/"+itemType, nil, true, ids, summary)
+	return summary, err
+}
+
+// Trash returns the deleted items of all types, the last deleted first. Items decode to
+// maps, the type names their DTO.
+func (c *Client) Trash() ([]model.TrashItemDTO, error) {
+	var trash []model.TrashItemDTO
+	err := c.call(http.MethodGet, "/api/trash", nil, true, nil, &trash)
+	return trash, err
+}
+
+// Favorites returns the favorite items of all types. Items decode to maps, the type names
+// their DTO.
+func (c *Client) Favorites() ([]model.TypedItemDTO, error) {
+	var favorites []model.TypedItemDTO
+	err := c.call(http.MethodGet, "/api/favorites", nil, true, nil, &favorites)
+	return favorites, err
+}
+
+// Sync returns the changes of the vault since the token of the previous sync, an empty
+// token returns all items. The next sync asks for the changes since the Token of the
+// result. Keep the token with the items, clients which were offline resume with it.
+func (c *Client) Sync(token string) (*model.SyncDTO, error) {
+	query := url.Values{}
+	if token != "" {
+		query.Set("token", token)
+	}
+	sync := new(model.SyncDTO)
+	err := c.call(http.MethodGet, "/api/sync", query, true, nil, sync)
+	return sync, err
+}
+
+// Search returns a page of the items of all types matching the query and the number of
+// all matching items. Only the offset and limit of the options are used.
+func (c *Client) Search(query string, opts *ListOptions) (*model.SearchResultDTO, error) {
+	v := url.Values{}
+	if opts != nil {
+		v = (&ListOptions{Offset: opts.Offset, Limit: opts.Limit}).values()
+	}
+	v.Set("q", query)
+
+	result := new(model.SearchResultDTO)
+	if err := c.call(http.MethodGet, "/api/search", v, true, nil, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// RestoreItem moves the deleted item back into the vault
+func (c *Client) RestoreItem(itemType string, id uint) error {
+	return c.call(http.MethodPost, itemPath(itemType, id)+"/restore", nil, false, nil, nil)
+}
+
+// PurgeItem deletes the deleted item permanently
+func (c *Client) PurgeItem(itemType string, id uint) error {
+	return c.call(http.MethodDelete, itemPath(itemType, id)+"/purge", nil, false, nil, nil)
+}
+
+// ItemVersions returns the previous versions of the item, newest first. Items decode to
+// maps like the ones of the trash.
+func (c *Client) ItemVersions(itemType string, id uint) ([]model.ItemVersionDTO, error) {
+	var versions []model.ItemVersionDTO
+	err := c.call(http.MethodGet, itemPath(itemType, id)+"/versions", nil, true, nil, &versions)
+	return versions, err
+}
+
+// RestoreItemVersion sets the item back to the version and decodes the item into out
+func (c *Client) RestoreItemVersion(itemType string, id, version uint, out interface{}) error {
+	path := itemPath(itemType, id) + "/versions/" + strconv.FormatUint(uint64(version), 10) + "/restore"
+	return c.call(http.MethodPost, path, nil, true, nil, out)
+}
+
+func (c *Client) deleteItem(itemType string, id uint) error {
+	return c.call(http.MethodDelete, itemPath(itemType, id), nil, false, nil, nil)
+}
+
+// ListLogins returns the logins matching opts, opts may be nil
+func (c *Client) ListLogins(opts *ListOptions) ([]model.LoginDTO, error) {
+	var list []model.LoginDTO
+	err := c.call(http.MethodGet, "/api/"+LoginItem, opts.values(), true, nil, opts.page(&list))
+	return list, err
+}
+
+// GetLogin returns the login with the id
+func (c *Client) GetLogin(id uint) (*model.LoginDTO, error) {
+	dto := new(model.LoginDTO)
+	err := c.call(http.MethodGet, itemPath(LoginItem, id), nil, true, nil, dto)
+	return dto, err
+}
+
+// CreateLogin creates a login
+func (c *Client) CreateLogin(dto *model.LoginDTO) (*model.LoginDTO, error) {
+	created := new(model.LoginDTO)
+	err := c.call(http.MethodPost, "/api/"+LoginItem, nil, true, dto, created)
+	return created, err
+}
+
+// UpdateLogin updates the login with the id
+func (c *Client) UpdateLogin(id uint, dto *model.LoginDTO) (*model.LoginDTO, error) {
+	updated := new(model.LoginDTO)
+	err := c.call(http.MethodPut, itemPath(LoginItem, id), nil, true, dto, updated)
+	return updated, err
+}
+
+// DeleteLogin deletes the login with the id
+func (c *Client) DeleteLogin(id uint) error {
+	return c.deleteItem(LoginItem, id)
+}
+
+// LoginPasswordHistory returns the previous passwords of the login, newest first
+func (c *Client) LoginPasswordHistory(id uint) ([]model.PasswordHistoryDTO, error) {
+	var history []model.PasswordHistoryDTO
+	err := c.call(http.MethodGet, itemPath(LoginItem, id)+"/password-history", nil, true, nil, &history)
+	return history, err
+}
+
+// RotateLogin replaces the credential of the login with its rotation provider
+func (c *Client) RotateLogin(id uint) (*model.LoginDTO, error) {
+	dto := new(model.LoginDTO)
+	err := c.call(http.MethodPost, itemPath(LoginItem, id)+"/rotate", nil, true, nil, dto)
+	return dto, err
+}
+
+// AutofillLogins returns the logins of the site, equivalent domains included
+func (c *Client) AutofillLogins(siteURL string) ([]model.LoginDTO, error) {
+	var list []model.LoginDTO
+	query := url.Values{"url": []string{siteURL}}
+	err := c.call(http.MethodGet, "/api/logins/autofill", query, true, nil, &list)
+	return list, err
+}
+
+// ListCreditCards returns the credit cards matching opts, opts may be nil
+func (c *Client) ListCreditCards(opts *ListOptions) ([]model.CreditCardDTO, error) {
+	var list []model.CreditCardDTO
+	err := c.call(http.MethodGet, "/api/"+CreditCardItem, opts.values(), true, nil, opts.page(&list))
+	return list, err
+}
+
+// GetCreditCard returns the credit card with the id
+func (c *Client) GetCreditCard(id uint) (*model.CreditCardDTO, error) {
+	dto := new(model.CreditCardDTO)
+	err := c.call(http.MethodGet, itemPath(CreditCardItem, id), nil, true, nil, dto)
+	return dto, err
+}
+
+// CreateCreditCard creates a credit card
+func (c *Client) CreateCreditCard(dto *model.CreditCardDTO) (*model.CreditCardDTO, error) {
+	created := new(model.CreditCardDTO)
+	err := c.call(http.MethodPost, "/api/"+CreditCardItem, nil, true, dto, created)
+	return created, err
+}
+
+// UpdateCreditCard updates the credit card with the id
+func (c *Client) UpdateCreditCard(id uint, dto *model.CreditCardDTO) (*model.CreditCardDTO, error) {
+	updated := new(model.CreditCardDTO)
+	err := c.call(http.MethodPut, itemPath(CreditCardItem, id), nil, true, dto, updated)
+	return updated, err
+}
+
+// DeleteCreditCard deletes the credit card with the id
+func (c *Client) DeleteCreditCard(id uint) error {
+	return c.deleteItem(CreditCardItem, id)
+}
+
+// ListBankAccounts returns the bank accounts matching opts, opts may be nil
+func (c *Client) ListBankAccounts(opts *ListOptions) ([]model.BankAccountDTO, error) {
+	var list []model.BankAccountDTO
+	err := c.call(http.MethodGet, "/api/"+BankAccountItem, opts.values(), true, nil, opts.page(&list))
+	return list, err
+}
+
+// GetBankAccount returns the bank account with the id
+func (c *Client) GetBankAccount(id uint) (*model.BankAccountDTO, error) {
+	dto := new(model.BankAccountDTO)
+	err := c.call(http.MethodGet, itemPath(BankAccountItem, id), nil, true, nil, dto)
+	return dto, err
+}
+
+// CreateBankAccount creates a bank account
+func (c *Client) CreateBankAccount(dto *model.BankAccountDTO) (*model.BankAccountDTO, error) {
+	created := new(model.BankAccountDTO)
+	err := c.call(http.MethodPost, "/api/"+BankAccountItem, nil, true, dto, created)
+	return created, err
+}
+
+// UpdateBankAccount updates the bank account with the id
+func (c *Client) UpdateBankAccount(id uint, dto *model.BankAccountDTO) (*model.BankAccountDTO, error) {
+	updated := new(model.BankAccountDTO)
+	err := c.call(http.MethodPut, itemPath(BankAccountItem, id), nil, true, dto, updated)
+	return updated, err
+}
+
+// DeleteBankAccount deletes the bank account with the id
+func (c *Client) DeleteBankAccount(id uint) error {
+	return c.deleteItem(BankAccountItem, id)
+}
+
+// ListNotes returns the notes matching opts, opts may be nil
+func (c *Client) ListNotes(opts *ListOptions) ([]model.NoteDTO, error) {
+	var list []model.NoteDTO
+	err := c.call(http.MethodGet, "/api/"+NoteItem, opts.values(), true, nil, opts.page(&list))
+	return list, err
+}
+
+// GetNote returns the note with the id
+func (c *Client) GetNote(id uint) (*model.NoteDTO, error) {
+	dto := new(model.NoteDTO)
+	err := c.call(http.MethodGet, itemPath(NoteItem, id), nil, true, nil, dto)
+	return dto, err
+}
+
+// CreateNote creates a note
+func (c *Client) CreateNote(dto *model.NoteDTO) (*model.NoteDTO, error) {
+	created := new(model.NoteDTO)
+	err := c.call(http.MethodPost, "/api/"+NoteItem, nil, true, dto, created)
+	return created, err
+}
+
+// UpdateNote updates the note with the id
+func (c *Client) UpdateNote(id uint, dto *model.NoteDTO) (*model.NoteDTO, error) {
+	updated := new(model.NoteDTO)
+	err := c.call(http.MethodPut, itemPath(NoteItem, id), nil, true, dto, updated)
+	return updated, err
+}
+
+// DeleteNote deletes the note with the id
+func (c *Client) DeleteNote(id uint) error {
+	return c.deleteItem(NoteItem, id)
+}
+
+// ListEmails returns the emails matching opts, opts may be nil
+func (c *Client) ListEmails(opts *ListOptions) ([]model.EmailDTO, error) {
+	var list []model.EmailDTO
+	err := c.call(http.MethodGet, "/api/"+EmailItem, opts.values(), true, nil, opts.page(&list))
+	return list, err
+}
+
+// GetEmail returns the email with the id
+func (c *Client) GetEmail(id uint) (*model.EmailDTO, error) {
+	dto := new(model.EmailDTO)
+	err := c.call(http.MethodGet, itemPath(EmailItem, id), nil, true, nil, dto)
+	return dto, err
+}
+
+// CreateEmail creates a email
+func (c *Client) CreateEmail(dto *model.EmailDTO) (*model.EmailDTO, error) {
+	created := new(model.EmailDTO)
+	err := c.call(http.MethodPost, "/api/"+EmailItem, nil, true, dto, created)
+	return created, err
+}
+
+// UpdateEmail updates the email with the id
+func (c *Client) UpdateEmail(id uint, dto *model.EmailDTO) (*model.EmailDTO, error) {
+	updated := new(model.EmailDTO)
+	err := c.call(http.MethodPut, itemPath(EmailItem, id), nil, true, dto, updated)
+	return updated, err
+}
+
+// DeleteEmail deletes the email with the id
+func (c *Client) DeleteEmail(id uint) error {
+	return c.deleteItem(EmailItem, id)
+}
+
+// ListServers returns the servers matching opts, opts may be nil
+func (c *Client) ListServers(opts *ListOptions) ([]model.ServerDTO, error) {
+	var list []model.ServerDTO
+	err := c.call(http.MethodGet, "/api/"+ServerItem, opts.values(), true, nil, opts.page(&list))
+	return list, err
+}
+
+// GetServer returns the server with the id
+func (c *Client) GetServer(id uint) (*model.ServerDTO, error) {
+	dto := new(model.ServerDTO)
+	err := c.call(http.MethodGet, itemPath(ServerItem, id), nil, true, nil, dto)
+	return dto, err
+}
+
+// CreateServer creates a server
+func (c *Client) CreateServer(dto *model.ServerDTO) (*model.ServerDTO, error) {
+	created := new(model.ServerDTO)
+	err := c.call(http.MethodPost, "/api/"+ServerItem, nil, true, dto, created)
+	return created, err
+}
+
+// UpdateServer updates the server with the id
+func (c *Client) UpdateServer(id uint, dto *model.ServerDTO) (*model.ServerDTO, error) {
+	updated := new(model.ServerDTO)
+	err := c.call(http.MethodPut, itemPath(ServerItem, id), nil, true, dto, updated)
+	return updated, err
+}
+
+// DeleteServer deletes the server with the id
+func (c *Client) DeleteServer(id uint) error {
+	return c.deleteItem(ServerItem, id)
+}
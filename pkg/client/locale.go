@@ -0,0 +1,22 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// Locale returns the preferred locale of the user, the locale of the session and the supported ones
+func (c *Client) Locale() (*model.LocaleDTO, error) {
+	dto := new(model.LocaleDTO)
+	err := c.call(http.MethodGet, "/api/locale", nil, false, nil, dto)
+	return dto, err
+}
+
+// SetLocale sets the preferred locale of the user, an empty one follows Accept-Language.
+// It applies to the tokens of the next sign in or refresh.
+func (c *Client) SetLocale(locale string) (*model.LocaleDTO, error) {
+	dto := new(model.LocaleDTO)
+	err := c.call(http.MethodPut, "/api/locale", nil, false, model.LocaleDTO{Preferred: locale}, dto)
+	return dto, err
+}
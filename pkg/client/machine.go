@@ -0,0 +1,78 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// ListMachineAccounts returns the machine accounts of the user
+func (c *Client) ListMachineAccounts() ([]model.MachineAccountDTO, error) {
+	var list []model.MachineAccountDTO
+	err := c.call(http.MethodGet, "/api/machine-accounts", nil, true, nil, &list)
+	return list, err
+}
+
+// CreateMachineAccount creates a machine account which can read dto.Items.
+// The client secret is only returned here.
+func (c *Client) CreateMachineAccount(dto *model.MachineAccountDTO) (*model.MachineAccountDTO, error) {
+	created := new(model.MachineAccountDTO)
+	err := c.call(http.MethodPost, "/api/machine-accounts", nil, true, dto, created)
+	return created, err
+}
+
+// UpdateMachineAccount changes the name and items of the machine account
+func (c *Client) UpdateMachineAccount(id uint, dto *model.MachineAccountDTO) (*model.MachineAccountDTO, error) {
+	updated := new(model.MachineAccountDTO)
+	err := c.call(http.MethodPut, "/api/machine-accounts/"+strconv.FormatUint(uint64(id), 10), nil, true, dto, updated)
+	return updated, err
+}
+
+// DeleteMachineAccount deletes the machine account
+func (c *Client) DeleteMachineAccount(id uint) error {
+	return c.call(http.MethodDelete, "/api/machine-accounts/"+strconv.FormatUint(uint64(id), 10), nil, false, nil, nil)
+}
+
+// MachineToken returns a short-lived token of the machine account, no session is needed
+func (c *Client) MachineToken(clientID, secret string) (*model.MachineTokenDTO, error) {
+	token := new(model.MachineTokenDTO)
+	dto := model.MachineTokenRequestDTO{ClientID: clientID, Secret: secret}
+	err := c.send(http.MethodPost, "/auth/machine-token", nil, "", dto, token)
+	return token, err
+}
+
+// Inject returns the items of the machine account by item path e.g. "logins/3".
+// All items of the machine account are returned when items is empty.
+func (c *Client) Inject(accessToken string, items []string) (map[string]json.RawMessage, error) {
+	query := url.Values{"format": []string{"json"}}
+	if len(items) > 0 {
+		query.Set("items", strings.Join(items, ","))
+	}
+
+	secrets := map[string]json.RawMessage{}
+	err := c.send(http.MethodGet, "/inject", query, accessToken, nil, &secrets)
+	return secrets, err
+}
+
+// K8sSecrets returns the items of the machine account as Kubernetes Secrets in the namespace.
+// With since set to the version of the last list, the server waits up to wait for a change
+// and returns the same version if nothing changed.
+func (c *Client) K8sSecrets(accessToken, namespace, since string, wait time.Duration) (*model.K8sSecretListDTO, error) {
+	query := url.Values{}
+	if namespace != "" {
+		query.Set("namespace", namespace)
+	}
+	if since != "" {
+		query.Set("since", since)
+		query.Set("wait", strconv.Itoa(int(wait.Seconds())))
+	}
+
+	list := new(model.K8sSecretListDTO)
+	err := c.send(http.MethodGet, "/k8s/secrets", query, accessToken, nil, list)
+	return list, err
+}
@@ -0,0 +1,15 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// MigrationStatus returns the migration version and the pending migrations of the system
+// schema and of every user schema, only admins can do it
+func (c *Client) MigrationStatus() ([]model.MigrationStatusDTO, error) {
+	var statuses []model.MigrationStatusDTO
+	err := c.call(http.MethodGet, "/admin/migrations", nil, false, nil, &statuses)
+	return statuses, err
+}
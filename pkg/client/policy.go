@@ -0,0 +1,43 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// Policies returns the server and own policies with the rules which apply to the user
+func (c *Client) Policies() (*model.PoliciesDTO, error) {
+	policies := new(model.PoliciesDTO)
+	err := c.call(http.MethodGet, "/api/policies", nil, false, nil, policies)
+	return policies, err
+}
+
+// UpdatePolicy replaces the own policy, it can only tighten the server policy
+func (c *Client) UpdatePolicy(dto *model.PolicyDTO) (*model.PolicyDTO, error) {
+	updated := new(model.PolicyDTO)
+	err := c.call(http.MethodPut, "/api/policies", nil, false, dto, updated)
+	return updated, err
+}
+
+// UpdateServerPolicy replaces the server policy, only admins can do it
+func (c *Client) UpdateServerPolicy(dto *model.PolicyDTO) (*model.PolicyDTO, error) {
+	updated := new(model.PolicyDTO)
+	err := c.call(http.MethodPut, "/api/system/policies", nil, false, dto, updated)
+	return updated, err
+}
+
+// ExemptFromSecurityKey lets the user sign in without a security key for a while, only admins can do it
+func (c *Client) ExemptFromSecurityKey(userID uint, dto *model.SecurityKeyExemptionDTO) (*model.PolicyDTO, error) {
+	updated := new(model.PolicyDTO)
+	err := c.call(http.MethodPut, fmt.Sprintf("/api/system/users/%d/security-key-exemption", userID), nil, false, dto, updated)
+	return updated, err
+}
+
+// RemoveSecurityKeyExemption ends the exemption of the user, only admins can do it
+func (c *Client) RemoveSecurityKeyExemption(userID uint) (*model.PolicyDTO, error) {
+	updated := new(model.PolicyDTO)
+	err := c.call(http.MethodDelete, fmt.Sprintf("/api/system/users/%d/security-key-exemption", userID), nil, false, nil, updated)
+	return updated, err
+}
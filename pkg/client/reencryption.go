@@ -0,0 +1,34 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// ReencryptionStatus returns the progress of the re-encryption jobs, only admins can do it
+func (c *Client) ReencryptionStatus() (*model.ReencryptionStatusDTO, error) {
+	status := new(model.ReencryptionStatusDTO)
+	err := c.call(http.MethodGet, "/admin/reencryption/status", nil, false, nil, status)
+	return status, err
+}
+
+// StartReencryption queues the re-encryption of all vaults for key_rotation, cipher_upgrade
+// or blind_index
+func (c *Client) StartReencryption(reason string) (*model.ReencryptionJobDTO, error) {
+	job := new(model.ReencryptionJobDTO)
+	err := c.call(http.MethodPost, "/admin/reencryption", nil, false, model.ReencryptionRequestDTO{Reason: reason}, job)
+	return job, err
+}
+
+// RotateServerKey makes passphrase the server passphrase and queues the re-encryption of all vaults
+func (c *Client) RotateServerKey(passphrase string) (*model.ReencryptionJobDTO, error) {
+	job := new(model.ReencryptionJobDTO)
+	err := c.call(http.MethodPost, "/admin/rotate-key", nil, false, model.KeyRotationDTO{Passphrase: passphrase}, job)
+	return job, err
+}
+
+// FinishKeyRotation drops the previous server passphrase once the re-encryption is done
+func (c *Client) FinishKeyRotation() error {
+	return c.call(http.MethodPost, "/admin/rotate-key/finish", nil, false, nil, nil)
+}
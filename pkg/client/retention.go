@@ -0,0 +1,21 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// RetentionReport returns what the retention policy would purge now, only admins can do it
+func (c *Client) RetentionReport() (*model.RetentionReportDTO, error) {
+	report := new(model.RetentionReportDTO)
+	err := c.call(http.MethodGet, "/api/system/retention", nil, false, nil, report)
+	return report, err
+}
+
+// PurgeExpiredData purges the data older than the retention policy right away, only admins can do it
+func (c *Client) PurgeExpiredData() (*model.RetentionReportDTO, error) {
+	report := new(model.RetentionReportDTO)
+	err := c.call(http.MethodPost, "/api/system/retention/purge", nil, false, nil, report)
+	return report, err
+}
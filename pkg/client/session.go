@@ -0,0 +1,33 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// ListSessions returns the devices the user is signed in on, the one of this client is current
+func (c *Client) ListSessions() ([]model.SessionDTO, error) {
+	var list []model.SessionDTO
+	err := c.call(http.MethodGet, "/api/auth/sessions", nil, true, nil, &list)
+	return list, err
+}
+
+// RevokeSession signs out the device of the session
+func (c *Client) RevokeSession(id string) error {
+	return c.call(http.MethodDelete, "/api/auth/sessions/"+url.PathEscape(id), nil, false, nil, nil)
+}
+
+// Reauthenticate confirms the master password, the requests of the next minutes which
+// need a re-authentication like ExportItems send the token of the response
+func (c *Client) Reauthenticate(masterPassword string) (*model.ReauthTokenDTO, error) {
+	token := new(model.ReauthTokenDTO)
+	if err := c.call(http.MethodPost, "/api/auth/reauth", nil, false, model.ReauthDTO{MasterPassword: masterPassword}, token); err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	c.reauthToken = token.Token
+	c.mu.Unlock()
+	return token, nil
+}
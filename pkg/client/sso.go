@@ -0,0 +1,56 @@
+package client
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// SSOProviders lists the single sign-on providers of the server
+func (c *Client) SSOProviders() ([]model.SSOProviderDTO, error) {
+	var providers []model.SSOProviderDTO
+	err := c.send(http.MethodGet, "/auth/sso", nil, "", nil, &providers)
+	return providers, err
+}
+
+// BeginSSO returns the url of the provider the user signs in on, the provider redirects
+// back to the client with the code and state for SigninSSO
+func (c *Client) BeginSSO(provider string) (*model.SSOAuthorizationDTO, error) {
+	authorization := new(model.SSOAuthorizationDTO)
+	err := c.send(http.MethodGet, "/auth/sso/"+url.PathEscape(provider), nil, "", nil, authorization)
+	return authorization, err
+}
+
+// SigninSSO finishes a single sign-on and starts a new session. Like Signin it returns
+// a *TwoFactorRequiredError when the user has to verify a second factor.
+func (c *Client) SigninSSO(provider, code, state string) error {
+	return c.signinCallback("/auth/sso/"+url.PathEscape(provider)+"/callback", code, state)
+}
+
+// BeginSAML returns the url of the SAML identity provider the user signs in on. The identity
+// provider posts to the server, which redirects to the client with the code and state for SigninSAML.
+func (c *Client) BeginSAML(provider string) (*model.SSOAuthorizationDTO, error) {
+	authorization := new(model.SSOAuthorizationDTO)
+	err := c.send(http.MethodGet, "/auth/saml/"+url.PathEscape(provider), nil, "", nil, authorization)
+	return authorization, err
+}
+
+// SigninSAML finishes a sign in with a SAML identity provider like SigninSSO does
+func (c *Client) SigninSAML(provider, code, state string) error {
+	return c.signinCallback("/auth/saml/"+url.PathEscape(provider)+"/callback", code, state)
+}
+
+func (c *Client) signinCallback(path, code, state string) error {
+	session := new(model.AuthLoginResponse)
+	dto := model.SSOCallbackDTO{Code: code, State: state}
+	if err := c.send(http.MethodPost, path, nil, "", dto, session); err != nil {
+		return err
+	}
+	if session.TwoFactorRequired {
+		return &TwoFactorRequiredError{Token: session.TwoFactorToken, Methods: session.TwoFactorMethods}
+	}
+
+	c.startSession(session)
+	return nil
+}
@@ -0,0 +1,41 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// EquivalentDomains returns the built in, server wide and own equivalent domain sets
+func (c *Client) EquivalentDomains() (*model.EquivalentDomainsDTO, error) {
+	domains := new(model.EquivalentDomainsDTO)
+	err := c.call(http.MethodGet, "/api/equivalent-domains", nil, false, nil, domains)
+	return domains, err
+}
+
+// CreateEquivalentDomain adds an own equivalent domain set
+func (c *Client) CreateEquivalentDomain(dto *model.EquivalentDomainDTO) (*model.EquivalentDomainDTO, error) {
+	created := new(model.EquivalentDomainDTO)
+	err := c.call(http.MethodPost, "/api/equivalent-domains", nil, false, dto, created)
+	return created, err
+}
+
+// UpdateEquivalentDomain updates an own equivalent domain set
+func (c *Client) UpdateEquivalentDomain(id uint, dto *model.EquivalentDomainDTO) (*model.EquivalentDomainDTO, error) {
+	updated := new(model.EquivalentDomainDTO)
+	err := c.call(http.MethodPut, "/api/equivalent-domains/"+strconv.FormatUint(uint64(id), 10), nil, false, dto, updated)
+	return updated, err
+}
+
+// DeleteEquivalentDomain deletes an own equivalent domain set
+func (c *Client) DeleteEquivalentDomain(id uint) error {
+	return c.call(http.MethodDelete, "/api/equivalent-domains/"+strconv.FormatUint(uint64(id), 10), nil, false, nil, nil)
+}
+
+// GeneratePassword returns a random password generated by the server
+func (c *Client) GeneratePassword() (string, error) {
+	var response model.Response
+	err := c.call(http.MethodPost, "/api/system/generate-password", nil, false, nil, &response)
+	return response.Message, err
+}
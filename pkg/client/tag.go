@@ -0,0 +1,45 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+func tagPath(id uint) string {
+	return "/api/tags/" + strconv.FormatUint(uint64(id), 10)
+}
+
+// ListTags returns the tags of the vault
+func (c *Client) ListTags() ([]model.TagDTO, error) {
+	var list []model.TagDTO
+	err := c.call(http.MethodGet, "/api/tags", nil, true, nil, &list)
+	return list, err
+}
+
+// GetTag returns the tag with the id
+func (c *Client) GetTag(id uint) (*model.TagDTO, error) {
+	tag := new(model.TagDTO)
+	err := c.call(http.MethodGet, tagPath(id), nil, true, nil, tag)
+	return tag, err
+}
+
+// CreateTag creates a tag, items get it with its id in their tags
+func (c *Client) CreateTag(dto *model.TagDTO) (*model.TagDTO, error) {
+	created := new(model.TagDTO)
+	err := c.call(http.MethodPost, "/api/tags", nil, true, dto, created)
+	return created, err
+}
+
+// UpdateTag renames the tag
+func (c *Client) UpdateTag(id uint, dto *model.TagDTO) (*model.TagDTO, error) {
+	updated := new(model.TagDTO)
+	err := c.call(http.MethodPut, tagPath(id), nil, true, dto, updated)
+	return updated, err
+}
+
+// DeleteTag deletes the tag and removes it from its items
+func (c *Client) DeleteTag(id uint) error {
+	return c.call(http.MethodDelete, tagPath(id), nil, false, nil, nil)
+}
@@ -0,0 +1,54 @@
+package client
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// WithDevice names the device of the client by its fingerprint. SigninTOTP and
+// SigninSecurityKey ask to trust it, later sign ins with the device token of
+// DeviceToken skip the second factor. token is the one of an earlier client, if any.
+func WithDevice(fingerprint, name, token string) Option {
+	return func(c *Client) {
+		c.device = model.TrustDeviceDTO{TrustDevice: true, DeviceFingerprint: fingerprint, DeviceName: name}
+		c.deviceToken = token
+	}
+}
+
+// DeviceToken returns the token of the trusted device, it's empty until a second factor trusts it
+func (c *Client) DeviceToken() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.deviceToken
+}
+
+// ListTrustedDevices returns the devices of the user which skip the second factor
+func (c *Client) ListTrustedDevices() ([]model.TrustedDeviceDTO, error) {
+	var list []model.TrustedDeviceDTO
+	err := c.call(http.MethodGet, "/api/2fa/devices", nil, false, nil, &list)
+	return list, err
+}
+
+// RevokeTrustedDevice makes the device ask for the second factor again
+func (c *Client) RevokeTrustedDevice(id uint) error {
+	return c.call(http.MethodDelete, "/api/2fa/devices/"+strconv.FormatUint(uint64(id), 10), nil, false, nil, nil)
+}
+
+// deviceSignin returns the device of the client for a sign in
+func (c *Client) deviceSignin() model.DeviceSigninDTO {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return model.DeviceSigninDTO{DeviceToken: c.deviceToken, DeviceFingerprint: c.device.DeviceFingerprint}
+}
+
+// startSession keeps the session of a sign in and the device token it trusted
+func (c *Client) startSession(session *model.AuthLoginResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.session = session
+	if session.DeviceToken != "" {
+		c.deviceToken = session.DeviceToken
+	}
+}
@@ -0,0 +1,55 @@
+package client
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// TwoFactorRequiredError is returned by Signin for users with two factor authentication,
+// the sign in continues with the token and a second factor of the methods
+type TwoFactorRequiredError struct {
+	Token   string
+	Methods []string
+}
+
+func (e *TwoFactorRequiredError) Error() string {
+	return "passwall: two factor authentication is required: " + strings.Join(e.Methods, ", ")
+}
+
+// SigninTOTP finishes the sign in with the code of the authenticator app and starts a new session
+func (c *Client) SigninTOTP(token, code string) error {
+	session := new(model.AuthLoginResponse)
+	dto := model.TwoFactorSigninDTO{TwoFactorToken: token, Code: code, TrustDeviceDTO: c.device}
+	if err := c.send(http.MethodPost, "/auth/signin/totp", nil, "", dto, session); err != nil {
+		return err
+	}
+
+	c.startSession(session)
+	return nil
+}
+
+// TwoFactor tells which second factors the user has
+func (c *Client) TwoFactor() (*model.TwoFactorStatusDTO, error) {
+	status := new(model.TwoFactorStatusDTO)
+	err := c.call(http.MethodGet, "/api/2fa", nil, false, nil, status)
+	return status, err
+}
+
+// EnrollTOTP generates the secret of an authenticator app, EnableTOTP turns it on
+func (c *Client) EnrollTOTP() (*model.TOTPEnrollmentDTO, error) {
+	enrollment := new(model.TOTPEnrollmentDTO)
+	err := c.call(http.MethodPost, "/api/2fa/totp", nil, false, nil, enrollment)
+	return enrollment, err
+}
+
+// EnableTOTP turns two factor authentication on with a code of the enrolled secret
+func (c *Client) EnableTOTP(code string) error {
+	return c.call(http.MethodPost, "/api/2fa/totp/verify", nil, false, model.TOTPCodeDTO{Code: code}, nil)
+}
+
+// DisableTOTP turns two factor authentication off, a current code confirms it
+func (c *Client) DisableTOTP(code string) error {
+	return c.call(http.MethodDelete, "/api/2fa/totp", nil, false, model.TOTPCodeDTO{Code: code}, nil)
+}
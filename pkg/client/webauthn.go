@@ -0,0 +1,58 @@
+package client
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/passwall/passwall-server/model"
+)
+
+// SecurityKeyRegistration returns the options of navigator.credentials.create() for a new
+// security key, the credential of the authenticator goes to RegisterSecurityKey
+func (c *Client) SecurityKeyRegistration() (json.RawMessage, error) {
+	var options json.RawMessage
+	err := c.call(http.MethodPost, "/api/2fa/webauthn/registration", nil, false, nil, &options)
+	return options, err
+}
+
+// RegisterSecurityKey saves the credential the authenticator created for the options
+func (c *Client) RegisterSecurityKey(name string, credential json.RawMessage) (*model.WebAuthnCredentialDTO, error) {
+	saved := new(model.WebAuthnCredentialDTO)
+	dto := model.WebAuthnRegistrationDTO{Name: name, Credential: credential}
+	err := c.call(http.MethodPost, "/api/2fa/webauthn/registration/verify", nil, false, dto, saved)
+	return saved, err
+}
+
+// SecurityKeys lists the security keys of the user
+func (c *Client) SecurityKeys() ([]model.WebAuthnCredentialDTO, error) {
+	var keys []model.WebAuthnCredentialDTO
+	err := c.call(http.MethodGet, "/api/2fa/webauthn/credentials", nil, false, nil, &keys)
+	return keys, err
+}
+
+// RemoveSecurityKey deletes a security key of the user
+func (c *Client) RemoveSecurityKey(id uint) error {
+	return c.call(http.MethodDelete, "/api/2fa/webauthn/credentials/"+strconv.FormatUint(uint64(id), 10), nil, false, nil, nil)
+}
+
+// SecurityKeySignin returns the options of navigator.credentials.get() for the token of
+// a TwoFactorRequiredError
+func (c *Client) SecurityKeySignin(token string) (json.RawMessage, error) {
+	var options json.RawMessage
+	dto := model.TwoFactorTokenDTO{TwoFactorToken: token}
+	err := c.send(http.MethodPost, "/auth/signin/webauthn/options", nil, "", dto, &options)
+	return options, err
+}
+
+// SigninSecurityKey finishes the sign in with the assertion of a security key and starts a new session
+func (c *Client) SigninSecurityKey(token string, credential json.RawMessage) error {
+	session := new(model.AuthLoginResponse)
+	dto := model.WebAuthnSigninDTO{TwoFactorToken: token, Credential: credential, TrustDeviceDTO: c.device}
+	if err := c.send(http.MethodPost, "/auth/signin/webauthn", nil, "", dto, session); err != nil {
+		return err
+	}
+
+	c.startSession(session)
+	return nil
+}
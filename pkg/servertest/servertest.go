@@ -0,0 +1,143 @@
+// Package servertest runs a complete passwall server with an in-memory store,
+// so handlers and API clients can be tested end to end without a database.
+//
+//	srv, err := servertest.New()
+//	defer srv.Close()
+//	srv.CreateUser("Test", "test@passwall.io", "master-password")
+//	session, err := srv.Signin("test@passwall.io", "master-password")
+//	code, err := srv.Do(session, http.MethodPost, "/api/logins", &model.LoginDTO{...}, &created)
+package servertest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+
+	"github.com/passwall/passwall-server/internal/app"
+	"github.com/passwall/passwall-server/internal/router"
+	"github.com/passwall/passwall-server/internal/storage"
+	"github.com/passwall/passwall-server/model"
+	"github.com/spf13/viper"
+)
+
+// Fake keys of the test server
+const (
+	Passphrase = "servertest-passphrase-for-encrypting-fields"
+	Secret     = "servertest-secret-for-jwt-tokens"
+	APIKey     = "servertest-api-key"
+)
+
+// Server is a running passwall server with an in-memory store
+type Server struct {
+	*httptest.Server
+	Store storage.Store
+
+	db *storage.Database
+}
+
+// New starts a server with an empty in-memory store
+func New() (*Server, error) {
+	viper.Set("server.passphrase", Passphrase)
+	viper.Set("server.secret", Secret)
+	viper.Set("server.apiKey", APIKey)
+	viper.Set("server.accessTokenExpireDuration", "30m")
+	viper.Set("server.refreshTokenExpireDuration", "15d")
+	viper.Set("server.generatedPasswordLength", 16)
+	viper.Set("server.itemVersions", 10)
+	viper.Set("email.apiKey", "")
+
+	db, err := storage.NewMemory()
+	if err != nil {
+		return nil, err
+	}
+	app.MigrateSystemTables(db)
+
+	return &Server{
+		Server: httptest.NewServer(router.New(db)),
+		Store:  db,
+		db:     db,
+	}, nil
+}
+
+// Close shuts the server down and drops all data
+func (s *Server) Close() {
+	s.Server.Close()
+	s.db.Close()
+}
+
+// CreateUser creates a user with its vault
+func (s *Server) CreateUser(name, email, masterPassword string) (*model.User, error) {
+	return app.SetupUser(s.Store, &model.UserDTO{
+		Name:           name,
+		Email:          email,
+		MasterPassword: masterPassword,
+	})
+}
+
+// Signin signs the user in and returns the tokens and transmission key of the session
+func (s *Server) Signin(email, masterPassword string) (*model.AuthLoginResponse, error) {
+	body, err := json.Marshal(model.AuthLoginDTO{Email: email, MasterPassword: masterPassword})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := http.Post(s.URL+"/auth/signin", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("signin failed with status %d", resp.StatusCode)
+	}
+
+	session := new(model.AuthLoginResponse)
+	if err := json.NewDecoder(resp.Body).Decode(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// Do sends in as an encrypted payload with the session and decrypts the payload of
+// the response into out. in and out may be nil. Responses which are not an encrypted
+// payload, like errors and messages, are decoded into out as they are.
+func (s *Server) Do(session *model.AuthLoginResponse, method, path string, in, out interface{}) (int, error) {
+	var body []byte
+	if in != nil {
+		encrypted, err := app.EncryptJSON(session.TransmissionKey, in)
+		if err != nil {
+			return 0, err
+		}
+		body, err = json.Marshal(model.Payload{Data: string(encrypted)})
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	req, err := http.NewRequest(method, s.URL+path, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+session.AccessToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil || out == nil || len(respBody) == 0 {
+		return resp.StatusCode, err
+	}
+
+	var payload model.Payload
+	if err := json.Unmarshal(respBody, &payload); err == nil && payload.Data != "" {
+		return resp.StatusCode, app.DecryptJSON(session.TransmissionKey, []byte(payload.Data), out)
+	}
+	return resp.StatusCode, json.Unmarshal(respBody, out)
+}
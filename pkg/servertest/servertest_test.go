@@ -0,0 +1,70 @@
+package servertest
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/passwall/passwall-server/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoginLifecycle(t *testing.T) {
+	srv, err := New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Close()
+
+	user, err := srv.CreateUser("Test", "test@passwall.io", "master-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = srv.Signin("test@passwall.io", "wrong-password")
+	assert.Error(t, err)
+
+	session, err := srv.Signin("test@passwall.io", "master-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	created := new(model.LoginDTO)
+	code, err := srv.Do(session, http.MethodPost, "/api/logins", &model.LoginDTO{
+		Title:    "Passwall",
+		URL:      "https://passwall.io",
+		Username: "test",
+		Password: "secret",
+	}, created)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	// Passwords are encrypted at rest, the store decrypts them when they are read
+	stored, err := srv.Store.Logins().FindByID(created.ID, user.Schema)
+	assert.NoError(t, err)
+	assert.Equal(t, "secret", stored.Password)
+	rows := []model.Login{}
+	assert.NoError(t, srv.Store.Reencryption().FindBatch(user.Schema+".logins", 0, 1, &rows))
+	if assert.Len(t, rows, 1) {
+		assert.NotEqual(t, "secret", rows[0].Password)
+	}
+
+	found := new(model.LoginDTO)
+	code, err = srv.Do(session, http.MethodGet, "/api/logins/1", nil, found)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "secret", found.Password)
+
+	var logins []model.LoginDTO
+	code, err = srv.Do(session, http.MethodGet, "/api/logins", nil, &logins)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Len(t, logins, 1)
+
+	var response model.Response
+	code, err = srv.Do(session, http.MethodDelete, "/api/logins/1", nil, &response)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, code)
+
+	code, _ = srv.Do(session, http.MethodGet, "/api/logins/1", nil, nil)
+	assert.Equal(t, http.StatusNotFound, code)
+}